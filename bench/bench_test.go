@@ -0,0 +1,174 @@
+// Package bench measures the API under load, so a performance regression
+// shows up as numbers before a deploy rather than as latency after one.
+//
+// The load scenarios drive the real handlers and services, on in-memory
+// storage, the way a load generator would, and report latency percentiles
+// next to ns/op:
+//
+//	go test ./bench -run '^$' -bench . -benchmem
+//
+// TestBudgets turns the scenarios into assertions, failing when a
+// scenario's p99 is over its budget:
+//
+//	go test ./bench -run TestBudgets -budget
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/handler"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+var enforceBudgets = flag.Bool("budget", false, "run TestBudgets, which fails when a load scenario is over its latency budget")
+
+// server is the API under load: the catalog and borrowing routes, wired as
+// in cmd/library-api on in-memory repos.
+type server struct {
+	router http.Handler
+	repos  repo.Repos
+	auth   service.AuthService
+}
+
+func newServer(tb testing.TB) *server {
+	tb.Helper()
+	log := logger.Discard()
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	templates, err := notify.NewRegistry("en", notify.Builtin())
+	if err != nil {
+		tb.Fatalf("templates: %v", err)
+	}
+	notifier := notify.New(templates, notify.NewLog(log), "library@example.com")
+
+	finePolicySvc := service.NewFinePolicyService(repos.FinePolicy, repos.Audit, repos.Tx, model.FinePolicy{Currency: "usd"}, log)
+	bookSvc := service.NewBookService(repos.Books, repos.Audit, repos.Tx, nil, log)
+	bookingSvc := service.NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, repos.Reservations, repos.Closures,
+		repos.Outbox, repos.Fines, finePolicySvc, notifier, 48*time.Hour, repos.Tx, log)
+	authSvc := service.NewAuthService([]service.SigningKey{{ID: "k1", Secret: []byte("bench-secret-at-least-32-bytes-long")}}, time.Hour,
+		service.TokenPolicy{}, repos.Revocations, repos.Sessions, time.Minute)
+	apiKeySvc := service.NewAPIKeyService(repos.APIKeys, repos.Users, log)
+
+	books := handler.NewBookHandler(bookSvc, log)
+	bookings := handler.NewBookingHandler(bookingSvc, log)
+
+	r := chi.NewRouter()
+	r.Use(handler.RequestIDMiddleware)
+	r.Use(respond.Middleware(false))
+	r.Route("/v1", func(r chi.Router) {
+		r.Get("/books", books.List)
+		r.Group(func(r chi.Router) {
+			r.Use(handler.AuthMiddleware(authSvc, apiKeySvc, handler.CookieAuth{}))
+			r.Get("/books/{id}", books.Get)
+			r.Post("/bookings", bookings.Borrow)
+			r.Post("/bookings/{id}/return", bookings.Return)
+		})
+	})
+	return &server{router: r, repos: repos, auth: authSvc}
+}
+
+// seedBooks adds n books with copies copies each and returns their IDs.
+func (s *server) seedBooks(tb testing.TB, n, copies int) []string {
+	tb.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		b := &model.Book{
+			Title:         fmt.Sprintf("Book %d", i),
+			Author:        fmt.Sprintf("Author %d", i%50),
+			PublishedYear: 1900 + i%120,
+			TotalCopies:   copies,
+			Tags:          []string{"fiction"},
+		}
+		if err := s.repos.Books.Create(context.Background(), b); err != nil {
+			tb.Fatalf("seed book: %v", err)
+		}
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// seedMember adds a member and returns a token for them.
+func (s *server) seedMember(tb testing.TB, name string) string {
+	tb.Helper()
+	u := &model.User{Username: name, Email: name + "@example.com", Password: "unused", Role: model.RoleUser}
+	if err := s.repos.Users.Create(context.Background(), u); err != nil {
+		tb.Fatalf("seed member: %v", err)
+	}
+	token, _, err := s.auth.GenerateToken(u.ID, u.Username, u.Role, "")
+	if err != nil {
+		tb.Fatalf("token: %v", err)
+	}
+	return token
+}
+
+// do serves one request and returns the response, failing when its status
+// isn't want.
+func (s *server) do(tb testing.TB, method, path, token string, body interface{}, want int) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			tb.Fatalf("encode: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != want {
+		tb.Fatalf("%s %s: status %d, want %d: %s", method, path, w.Code, want, w.Body.String())
+	}
+	return w
+}
+
+// latencies collects the duration of each request a scenario sends, from
+// any number of goroutines.
+type latencies struct {
+	mu  sync.Mutex
+	all []time.Duration
+}
+
+// add records the latencies one goroutine measured.
+func (l *latencies) add(d []time.Duration) {
+	l.mu.Lock()
+	l.all = append(l.all, d...)
+	l.mu.Unlock()
+}
+
+// percentile returns the latency p percent of requests were at or under.
+func (l *latencies) percentile(p float64) time.Duration {
+	if len(l.all) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(l.all)
+	slices.Sort(sorted)
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+// report adds the percentiles to b's results, as p50-ns, p95-ns and p99-ns.
+func (l *latencies) report(b *testing.B) {
+	for _, p := range []float64{50, 95, 99} {
+		b.ReportMetric(float64(l.percentile(p).Nanoseconds()), fmt.Sprintf("p%.0f-ns", p))
+	}
+}
@@ -0,0 +1,78 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+)
+
+// bookPage is a full page of books as GET /books returns it.
+func bookPage(n int) model.Page[model.Book] {
+	page := model.Page[model.Book]{Total: 10 * n, NextCursor: "eyJ0IjoiMjAyNi0wMS0wMVQwMDowMDowMFoifQ"}
+	now := time.Now().UTC()
+	for i := range n {
+		page.Items = append(page.Items, model.Book{
+			ID:              fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			Title:           fmt.Sprintf("Book %d", i),
+			Author:          "Frank Herbert",
+			PublishedYear:   1965,
+			ISBN:            "9780441013593",
+			TotalCopies:     3,
+			CopiesAvailable: 2,
+			Available:       true,
+			Categories:      []model.Category{{ID: "c-1", Name: "Science Fiction"}},
+			Tags:            []string{"classic", "desert"},
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+	return page
+}
+
+// BenchmarkEncodeBookPage measures encoding the largest page of books, bare
+// and in the response envelope.
+func BenchmarkEncodeBookPage(b *testing.B) {
+	page := bookPage(100)
+	for _, enveloped := range []bool{false, true} {
+		b.Run(fmt.Sprintf("envelope=%t", enveloped), func(b *testing.B) {
+			ctx := respond.WithEnvelope(context.Background(), enveloped)
+			b.ReportAllocs()
+			for b.Loop() {
+				respond.JSON(ctx, httptest.NewRecorder(), 200, page)
+			}
+		})
+	}
+}
+
+// BenchmarkBookRepoList measures the scan behind GET /books, with and
+// without filters, as the catalog and the bookings it checks availability
+// against grow.
+func BenchmarkBookRepoList(b *testing.B) {
+	for _, size := range []int{100, 1000} {
+		s := newServer(b)
+		ids := s.seedBooks(b, size, 2)
+		// A loan on one book in ten, which List counts for availability.
+		for i := 0; i < size; i += 10 {
+			booking := &model.Booking{BookID: ids[i], UserID: "u-1", Status: "ACTIVE", BorrowedAt: time.Now(), DueDate: time.Now().Add(14 * 24 * time.Hour)}
+			if err := s.repos.Bookings.Create(context.Background(), booking); err != nil {
+				b.Fatalf("seed booking: %v", err)
+			}
+		}
+		for _, f := range []model.BookFilter{{}, {Tag: "fiction"}} {
+			b.Run(fmt.Sprintf("books=%d/tag=%q", size, f.Tag), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := s.repos.Books.List(context.Background(), model.PageRequest{Limit: 20}, f); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
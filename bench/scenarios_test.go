@@ -0,0 +1,134 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// catalogSize is how many books the scenarios run against.
+const catalogSize = 500
+
+// scenario is a load scenario: setup prepares a server and returns the
+// request each worker sends in a loop.
+type scenario struct {
+	name  string
+	setup func(b *testing.B, s *server) func(worker int) func(b *testing.B)
+	// budget is the p99 latency TestBudgets holds the scenario to.
+	budget time.Duration
+}
+
+var scenarios = []scenario{
+	{name: "ListBooks", setup: listBooks, budget: 50 * time.Millisecond},
+	{name: "GetBook", setup: getBook, budget: 10 * time.Millisecond},
+	{name: "BorrowReturnChurn", setup: borrowReturn, budget: 50 * time.Millisecond},
+}
+
+// listBooks pages through the public catalog, as anonymous visitors do.
+func listBooks(b *testing.B, s *server) func(int) func(*testing.B) {
+	s.seedBooks(b, catalogSize, 3)
+	return func(worker int) func(*testing.B) {
+		rng := rand.New(rand.NewPCG(uint64(worker), 1))
+		return func(b *testing.B) {
+			offset := rng.IntN(catalogSize/20) * 20
+			s.do(b, "GET", fmt.Sprintf("/v1/books?limit=20&offset=%d", offset), "", nil, http.StatusOK)
+		}
+	}
+}
+
+// getBook reads single books, as members browsing the catalog do.
+func getBook(b *testing.B, s *server) func(int) func(*testing.B) {
+	ids := s.seedBooks(b, catalogSize, 3)
+	var members atomic.Int64
+	return func(worker int) func(*testing.B) {
+		token := s.seedMember(b, fmt.Sprintf("reader%d", members.Add(1)))
+		rng := rand.New(rand.NewPCG(uint64(worker), 2))
+		return func(b *testing.B) {
+			s.do(b, "GET", "/v1/books/"+ids[rng.IntN(len(ids))], token, nil, http.StatusOK)
+		}
+	}
+}
+
+// borrowReturn has each worker, a member of their own, borrow a book and
+// return it, alternately. A worker has at most one loan at a time, so with
+// 64 copies of each book no borrow is turned away on up to 64 CPUs.
+func borrowReturn(b *testing.B, s *server) func(int) func(*testing.B) {
+	ids := s.seedBooks(b, catalogSize, 64)
+	var members atomic.Int64
+	return func(worker int) func(*testing.B) {
+		token := s.seedMember(b, fmt.Sprintf("borrower%d", members.Add(1)))
+		rng := rand.New(rand.NewPCG(uint64(worker), 3))
+		var onLoan string
+		return func(b *testing.B) {
+			if onLoan != "" {
+				s.do(b, "POST", "/v1/bookings/"+onLoan+"/return", token, nil, http.StatusOK)
+				onLoan = ""
+				return
+			}
+			w := s.do(b, "POST", "/v1/bookings", token, model.BorrowBookRequest{BookID: ids[rng.IntN(len(ids))], BorrowDays: 14}, http.StatusCreated)
+			var resp model.BorrowBookResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				b.Fatalf("decode borrow: %v", err)
+			}
+			onLoan = resp.Booking.ID
+		}
+	}
+}
+
+// run drives sc with GOMAXPROCS workers sending requests concurrently, like
+// a load generator with that many connections, and reports the latency
+// percentiles of the requests.
+func (sc scenario) run(b *testing.B) {
+	s := newServer(b)
+	worker := sc.setup(b, s)
+	var seen latencies
+	var workers atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		send := worker(int(workers.Add(1)))
+		var took []time.Duration
+		for pb.Next() {
+			start := time.Now()
+			send(b)
+			took = append(took, time.Since(start))
+		}
+		seen.add(took)
+	})
+	b.StopTimer()
+	seen.report(b)
+}
+
+func BenchmarkScenario(b *testing.B) {
+	for _, sc := range scenarios {
+		b.Run(sc.name, sc.run)
+	}
+}
+
+// TestBudgets runs each scenario and fails when its p99 latency is over
+// budget. It only runs with -budget: the scenarios take seconds, and shared
+// CI machines are too noisy to hold to the numbers on every push.
+func TestBudgets(t *testing.T) {
+	if !*enforceBudgets {
+		t.Skip("run with -budget to check the latency budgets")
+	}
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			result := testing.Benchmark(sc.run)
+			if result.N == 0 {
+				t.Fatal("scenario failed; run it with -bench for the error")
+			}
+			p99 := time.Duration(result.Extra["p99-ns"])
+			t.Logf("%s: %s/op, p99 %s, budget %s", sc.name, time.Duration(result.NsPerOp()), p99, sc.budget)
+			if p99 > sc.budget {
+				t.Errorf("p99 latency %s is over the %s budget", p99, sc.budget)
+			}
+		})
+	}
+}
@@ -1,22 +1,44 @@
+// The OpenAPI spec in docs/ is generated from the swag annotations below and
+// on the handlers; run `make docs` (or go generate) after changing them.
+//
+//go:generate go run github.com/swaggo/swag/cmd/swag@v1.16.6 init -g main.go -d ./,../../internal/handler,../../internal/model -o ../../docs
+
 package main
 
 import (
     "context"
-    "log"
+    "io/fs"
+    "log/slog"
+    "net"
     "net/http"
     "os"
     "os/signal"
-    "time"
     "strings"
+    "time"
 
     "github.com/go-chi/chi/v5"
-    "github.com/go-chi/chi/v5/middleware"
+    "github.com/jackc/pgx/v5/pgxpool"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/app"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/challenge"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/events"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/grpcserver"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/handler"
-    // "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/i18n"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/jobs"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/metadata"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/payments"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/scheduler"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/seed"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
-    _ "github.com/praveen-anandh-jeyaraman/digicert/docs"
+    "github.com/praveen-anandh-jeyaraman/digicert/docs"
+    httpSwagger "github.com/swaggo/http-swagger"
+    "google.golang.org/grpc"
 )
 
 // @title           DigiCert Book API
@@ -32,7 +54,7 @@ import (
 // @license.url   http://www.apache.org/licenses/LICENSE-2.0.html
 
 // @host      localhost:8080
-// @BasePath  /
+// @BasePath  /v1
 
 // @schemes http https
 
@@ -41,53 +63,337 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key issued by an admin, accepted wherever a JWT is.
+
+// legacyRoutesDeprecatedAt is when the unversioned routes were superseded by /v1.
+var legacyRoutesDeprecatedAt = time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
 func main() {
     ctx := context.Background()
 
-    cfg, err := app.LoadConfigFromEnv()
+    cfg, err := app.LoadConfig()
     if err != nil {
-        log.Fatalf("failed to load config: %v", err)
+        slog.Error("failed to load config", "error", err)
+        os.Exit(1)
     }
 
-    // Initialize CloudWatch logger
-    // if err := logger.Initialize(cfg.CloudWatchLogGroup, cfg.CloudWatchLogStream, cfg.EnableCloudWatch); err != nil {
-    //     log.Printf("Warning: CloudWatch initialization failed: %v", err)
-    // }
-    // defer logger.GetLogger().Close()
-    // log.Printf("Logger initialized - CloudWatch: %v", cfg.EnableCloudWatch)
+    // Initialize CloudWatch logger; Close flushes buffered metrics on shutdown
+    cwErr := logger.Initialize(cfg.CloudWatchLogGroup, cfg.CloudWatchLogStream, cfg.EnableCloudWatch)
+    defer func() { _ = logger.GetLogger().Close() }()
 
-    stdLogger := app.NewStdLogger()
+    // JSON logs go through the CloudWatch writer; slog.SetDefault also routes
+    // any remaining log.Printf output through the same handler.
+    appLogger := app.NewLogger(logger.GetLogger(), cfg.LogLevel)
+    slog.SetDefault(appLogger)
+    if cwErr != nil {
+        appLogger.Warn("CloudWatch initialization failed", "error", cwErr)
+    }
+    appLogger.Info("logger initialized", "cloudwatch", cfg.EnableCloudWatch)
 
-    dbpool, err := app.NewDBPool(ctx, cfg)
-    if err != nil {
-        stdLogger.Fatalf("db connect failed: %v", err)
+    if err := cfg.ResolveSecrets(ctx); err != nil {
+        appLogger.Error("failed to resolve secrets", "error", err)
+        os.Exit(1)
+    }
+
+    port := cfg.Port
+    if port == "" {
+        port = "8080"
+    }
+    if strings.Contains(port, ":") {
+        parts := strings.Split(port, ":")
+        port = parts[len(parts)-1]
     }
-    defer dbpool.Close()
 
     // Initialize repositories
-    bookRepo := repo.NewBookRepo(dbpool)
-    userRepo := repo.NewUserRepo(dbpool)
-    bookingRepo := repo.NewBookingRepo(dbpool)
+    var repos repo.Repos
+    var dbpool, readPool *pgxpool.Pool
+    var replica *repo.Replica
+    // startup answers health probes while the database is waited for, so
+    // orchestrators see the instance as starting rather than down.
+    var startup *app.Server
+    if cfg.DBDriver == "memory" {
+        appLogger.Warn("using in-memory storage; all data is lost when the process exits")
+        repos = repo.NewMemoryRepos(repo.NewMemoryStore())
+    } else {
+        startup = app.NewServer(cfg, port, app.StartupHandler())
+        go func() {
+            if err := startup.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                appLogger.Warn("startup probe server failed", "error", err)
+            }
+        }()
+        dbpool, err = app.NewDBPool(ctx, cfg)
+        if err != nil {
+            appLogger.Error("db connect failed", "error", err)
+            os.Exit(1)
+        }
+        defer dbpool.Close()
+        readPool, err = app.NewReadDBPool(ctx, cfg)
+        if err != nil {
+            appLogger.Error("read replica connect failed", "error", err)
+            os.Exit(1)
+        }
+        if readPool != nil {
+            defer readPool.Close()
+            replica = repo.NewReplica(readPool)
+        }
+        repo.SetTimeouts(repo.Timeouts{Point: cfg.DBPointTimeout, List: cfg.DBListTimeout})
+        repos = repo.NewPostgresRepos(dbpool, replica)
+    }
+    bookRepo := repos.Books
+    userRepo := repos.Users
+    bookingRepo := repos.Bookings
+    loginAttemptRepo := repos.LoginAttempts
+    categoryRepo := repos.Categories
+    branchRepo := repos.Branches
+    loanPolicyRepo := repos.LoanPolicies
+    auditRepo := repos.Audit
+    revocationRepo := repos.Revocations
+    sessionRepo := repos.Sessions
+    identityRepo := repos.Identities
+    apiKeyRepo := repos.APIKeys
+    reviewRepo := repos.Reviews
+    reservationRepo := repos.Reservations
+    closureRepo := repos.Closures
+    extensionRepo := repos.Extensions
+    loginRepo := repos.Logins
+    calendarFeedRepo := repos.CalendarFeeds
+    jobRepo := repos.Jobs
+    outboxRepo := repos.Outbox
+    scheduledRunRepo := repos.ScheduledRuns
+    maintenanceRepo := repos.Maintenance
+    fineRepo := repos.Fines
+    finePolicyRepo := repos.FinePolicy
+    emailChangeRepo := repos.EmailChanges
+    invitationRepo := repos.Invitations
+    inviteCodeRepo := repos.InviteCodes
+    announcementRepo := repos.Announcements
+    readingListRepo := repos.ReadingLists
+    userStatsRepo := repos.UserStats
+    paymentRepo := repos.Payments
+    txMgr := repos.Tx
+
+    passwordPolicy := service.DefaultPasswordPolicy()
+    passwordPolicy.MinLength = cfg.PasswordMinLength
+    passwordPolicy.RequireUpper = cfg.PasswordRequireUpper
+    passwordPolicy.RequireLower = cfg.PasswordRequireLower
+    passwordPolicy.RequireDigit = cfg.PasswordRequireDigit
+    passwordPolicy.RequireSymbol = cfg.PasswordRequireSymbol
+    passwordPolicy.Hasher = service.PasswordHasher{
+        Scheme:     cfg.PasswordHash,
+        BcryptCost: cfg.BcryptCost,
+        Argon2: service.Argon2Params{
+            Memory:  uint32(cfg.Argon2MemoryKiB),
+            Time:    uint32(cfg.Argon2Time),
+            Threads: uint8(cfg.Argon2Threads),
+        },
+    }
+    if cfg.PasswordDenylistFile != "" {
+        denied, err := service.ReadDenylistFile(cfg.PasswordDenylistFile)
+        if err != nil {
+            appLogger.Error("failed to load password denylist", "error", err)
+            os.Exit(1)
+        }
+        passwordPolicy.Deny(denied)
+    }
+
+    // Book metadata lookups by ISBN
+    metadataClient := requestid.Client(&http.Client{Timeout: cfg.MetadataTimeout})
+    var metadataProvider metadata.Provider = metadata.NewOpenLibrary(metadataClient)
+    if cfg.MetadataProvider == "googlebooks" {
+        metadataProvider = metadata.NewGoogleBooks(metadataClient, cfg.GoogleBooksAPIKey)
+    }
+    metadataProvider = metadata.WithRetry(metadataProvider, cfg.MetadataRetries+1, 200*time.Millisecond)
+
+    // Outgoing email
+    templateFS := []fs.FS{notify.Builtin()}
+    if cfg.NotifyTemplateDir != "" {
+        templateFS = append(templateFS, os.DirFS(cfg.NotifyTemplateDir))
+    }
+    emailTemplates, err := notify.NewRegistry(cfg.NotifyLocale, templateFS...)
+    if err != nil {
+        appLogger.Error("failed to load email templates", "error", err)
+        os.Exit(1)
+    }
+    var emailProvider notify.Provider = notify.NewLog(appLogger)
+    switch cfg.NotifyProvider {
+    case "smtp":
+        emailProvider = notify.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+    case "ses":
+        emailProvider = notify.NewSES(cfg.Region, cfg.SMTPUsername, cfg.SMTPPassword)
+    }
+    // Emails and webhooks are queued and delivered by the job worker, so a
+    // slow or failing server is retried instead of failing the request.
+    jobQueue := jobs.NewQueue(jobRepo, cfg.JobMaxAttempts)
+    notifier := notify.New(emailTemplates, jobs.NewMailer(jobQueue), cfg.NotifyFrom)
+    notifier.SetPoster(jobs.NewWebhookQueue(jobQueue))
 
     // Initialize services
-    bookSvc := service.NewBookService(bookRepo)
-    userSvc := service.NewUserService(userRepo)
-    bookingSvc := service.NewBookingService(bookingRepo, bookRepo, userRepo)
-    authSvc := service.NewAuthService("your-secret-key-change-this", 24*time.Hour)
+    finePolicySvc := service.NewFinePolicyService(finePolicyRepo, auditRepo, txMgr, model.FinePolicy{
+        GraceDays:   cfg.FineGraceDays,
+        PerDayCents: cfg.FinePerDayCents,
+        MaxCents:    cfg.FineMaxCents,
+        Currency:    cfg.FineCurrency,
+    }, appLogger)
+    enrichSvc := service.NewEnrichmentService(metadataProvider, appLogger)
+    bookSvc := service.NewBookService(bookRepo, auditRepo, txMgr, enrichSvc, appLogger)
+    bookListingSvc := service.NewBookListingService(bookRepo, cfg.PopularBooksWindow, cfg.BookListingCacheTTL, appLogger)
+    categorySvc := service.NewCategoryService(categoryRepo, appLogger)
+    branchSvc := service.NewBranchService(branchRepo, appLogger)
+    emailPolicy := service.EmailPolicy{CheckMX: cfg.EmailCheckMX}
+    userSvc := service.NewUserService(userRepo, loginAttemptRepo, revocationRepo, outboxRepo, service.LockoutPolicy{
+        MaxFailures:      cfg.LoginMaxFailures,
+        MaxFailuresPerIP: cfg.LoginMaxFailuresPerIP,
+        Window:           cfg.LoginFailureWindow,
+        Duration:         cfg.LoginLockoutDuration,
+    }, passwordPolicy, emailPolicy, txMgr, appLogger)
+    inviteCodeSvc := service.NewInviteCodeService(inviteCodeRepo, appLogger)
+    emailChangeSvc := service.NewEmailChangeService(emailChangeRepo, userRepo, emailPolicy, notifier, cfg.EmailConfirmURL(), cfg.EmailChangeTTL, txMgr, appLogger)
+    userImportSvc := service.NewUserImportService(userRepo, invitationRepo, outboxRepo, passwordPolicy, emailPolicy, notifier, cfg.InvitationURL(), cfg.InvitationTTL, txMgr, appLogger)
+    bookingSvc := service.NewBookingService(bookingRepo, bookRepo, userRepo, loanPolicyRepo, cfg.LoanDuration(), reservationRepo, closureRepo, outboxRepo, fineRepo, finePolicySvc, notifier, cfg.OfferHoldDuration, txMgr, appLogger)
+    reservationSvc := service.NewReservationService(reservationRepo, bookRepo, bookingRepo, userRepo, appLogger)
+    loginHistorySvc := service.NewLoginHistoryService(loginRepo, notifier, appLogger)
+    extensionSvc := service.NewExtensionService(extensionRepo, bookingRepo, bookRepo, userRepo, closureRepo, auditRepo, notifier, txMgr, appLogger)
+    calendarSvc := service.NewCalendarService(closureRepo, appLogger)
+    announcementSvc := service.NewAnnouncementService(announcementRepo, appLogger)
+    bookingCalendarSvc := service.NewBookingCalendarService(bookingRepo, userRepo, calendarFeedRepo, cfg.CalendarFeedSecret, cfg.CalendarFeedURL(), appLogger)
+    userStatsSvc := service.NewUserStatsService(userStatsRepo, cfg.UserStatsCacheTTL, appLogger)
+    readingListSvc := service.NewReadingListService(readingListRepo, bookRepo, cfg.SharedListURL(), appLogger)
+    loanPolicySvc := service.NewLoanPolicyService(loanPolicyRepo, cfg.LoanDuration(), appLogger)
+    var signingKeys []service.SigningKey
+    for _, k := range cfg.SigningKeys() {
+        signingKeys = append(signingKeys, service.SigningKey{ID: k.ID, Secret: []byte(k.Secret)})
+    }
+    authSvc := service.NewAuthService(signingKeys, cfg.JWTExpiry, service.TokenPolicy{
+        Issuer:    cfg.JWTIssuer,
+        Audience:  cfg.JWTAudience,
+        Leeway:    cfg.JWTLeeway,
+        AccessTTL: cfg.AccessTokenTTL,
+    }, revocationRepo, sessionRepo, cfg.SessionCacheTTL)
+    apiKeySvc := service.NewAPIKeyService(apiKeyRepo, userRepo, appLogger)
+    reviewSvc := service.NewReviewService(reviewRepo, bookRepo, bookingRepo, userRepo, auditRepo, txMgr, appLogger)
+    // While registration is invite-only, so is signing up through a login
+    // provider.
+    var oidcInvites repo.InviteCodeRepo
+    if cfg.RegistrationInviteOnly {
+        oidcInvites = inviteCodeRepo
+    }
+    oidcSvc := service.NewOIDCService(userRepo, identityRepo, revocationRepo, outboxRepo, oidcInvites, oidcRoles(cfg), txMgr, appLogger)
+    accountSvc := service.NewAccountService(userRepo, bookingRepo, auditRepo, authSvc, cfg.ImpersonationTTL, txMgr, appLogger)
+    jobSvc := service.NewJobService(jobRepo, appLogger)
+    maintenanceSvc := service.NewMaintenanceService(maintenanceRepo, auditRepo, txMgr, cfg.MaintenanceMode, cfg.MaintenanceCacheTTL, appLogger)
+    var paymentProvider payments.Provider
+    if cfg.PaymentProvider == "stripe" {
+        paymentProvider = payments.NewStripe(requestid.Client(&http.Client{Timeout: 10 * time.Second}), cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+    }
+    fineSvc := service.NewFineService(fineRepo, paymentRepo, paymentProvider, txMgr, appLogger)
+    reportSvc := service.NewReportService(bookingRepo, scheduledRunRepo, finePolicySvc,
+        service.OverdueSchedule{Weekday: cfg.ReportWeekday(), Recipients: cfg.OverdueReportRecipients},
+        notifier, txMgr, appLogger)
+
+    if len(os.Args) > 1 && os.Args[1] == "seed" {
+        seeder := &seed.Seeder{Categories: categorySvc, Users: userSvc, Books: bookSvc, Bookings: bookingSvc, Logger: appLogger}
+        if err := runSeed(ctx, os.Args[2:], cfg.DBDriver, seeder); err != nil {
+            appLogger.Error("seed failed", "error", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    // Closing registration comes after seeding, whose demo users don't
+    // need invite codes.
+    if cfg.RegistrationInviteOnly {
+        userSvc = service.RequireInviteCodes(userSvc, inviteCodeRepo, txMgr, appLogger)
+    }
 
     // Initialize handlers
-    bookHandler := handler.NewBookHandler(bookSvc)
-    userHandler := handler.NewUserHandler(userSvc)
-    bookingHandler := handler.NewBookingHandler(bookingSvc)
-    authHandler := handler.NewAuthHandler(authSvc, userSvc)
+    bookHandler := handler.NewBookHandler(bookSvc, appLogger)
+    categoryHandler := handler.NewCategoryHandler(categorySvc, appLogger)
+    branchHandler := handler.NewBranchHandler(branchSvc, appLogger)
+    loanPolicyHandler := handler.NewLoanPolicyHandler(loanPolicySvc, appLogger)
+    userHandler := handler.NewUserHandler(userSvc, emailChangeSvc, appLogger)
+    userImportHandler := handler.NewUserImportHandler(userImportSvc, appLogger)
+    inviteCodeHandler := handler.NewInviteCodeHandler(inviteCodeSvc, appLogger)
+    accountHandler := handler.NewAccountHandler(accountSvc, appLogger)
+    bookingHandler := handler.NewBookingHandler(bookingSvc, appLogger)
+    // liveEvents hands the events this instance hears of to the admin
+    // dashboards and booking feeds connected to it.
+    liveEvents := events.NewHub()
+    liveHandler := handler.NewLiveHandler(liveEvents, appLogger)
+    cookies := handler.CookieAuth{Token: cfg.AuthCookie}
+    if cfg.AuthMode == "cookie" {
+        cookies.Refresh, cookies.CSRF = cfg.RefreshCookie, cfg.CSRFCookie
+    }
+    authHandler := handler.NewAuthHandler(authSvc, userSvc, loginHistorySvc, handler.LoginRateLimit{
+        PerIP:       cfg.LoginRatePerIP,
+        PerUsername: cfg.LoginRatePerUsername,
+        Period:      cfg.LoginRatePeriod,
+    }, cookies, appLogger)
+    providers, err := oidcProviders(ctx, cfg)
+    if err != nil {
+        appLogger.Error("failed to set up login providers", "error", err)
+        os.Exit(1)
+    }
+    oidcHandler := handler.NewOIDCHandler(providers, oidcSvc, authSvc, loginHistorySvc, cookies, appLogger)
+    apiKeyHandler := handler.NewAPIKeyHandler(apiKeySvc, appLogger)
+    reviewHandler := handler.NewReviewHandler(reviewSvc, appLogger)
+    bookListingHandler := handler.NewBookListingHandler(bookListingSvc, appLogger)
+    reservationHandler := handler.NewReservationHandler(reservationSvc, appLogger)
+    extensionHandler := handler.NewExtensionHandler(extensionSvc, appLogger)
+    loginHistoryHandler := handler.NewLoginHistoryHandler(loginHistorySvc, appLogger)
+    calendarHandler := handler.NewCalendarHandler(calendarSvc, appLogger)
+    announcementHandler := handler.NewAnnouncementHandler(announcementSvc, appLogger)
+    readingListHandler := handler.NewReadingListHandler(readingListSvc, appLogger)
+    userStatsHandler := handler.NewUserStatsHandler(userStatsSvc, appLogger)
+    bookingCalendarHandler := handler.NewBookingCalendarHandler(bookingCalendarSvc, appLogger)
+    jobHandler := handler.NewJobHandler(jobSvc, appLogger)
+    maintenanceHandler := handler.NewMaintenanceHandler(maintenanceSvc, appLogger)
+    reportHandler := handler.NewReportHandler(reportSvc, appLogger)
+    fineHandler := handler.NewFineHandler(fineSvc, appLogger)
+    var registrationChallenge challenge.Verifier
+    switch cfg.RegistrationChallenge {
+    case "hcaptcha":
+        registrationChallenge = challenge.NewHCaptcha(requestid.Client(&http.Client{Timeout: 10 * time.Second}), cfg.ChallengeSiteKey, cfg.ChallengeSecret)
+    case "turnstile":
+        registrationChallenge = challenge.NewTurnstile(requestid.Client(&http.Client{Timeout: 10 * time.Second}), cfg.ChallengeSiteKey, cfg.ChallengeSecret)
+    case "pow":
+        registrationChallenge = challenge.NewPoW([]byte(cfg.ChallengeSecret), cfg.PoWDifficulty)
+    }
+    challengeHandler := handler.NewChallengeHandler(registrationChallenge, appLogger)
+    finePolicyHandler := handler.NewFinePolicyHandler(finePolicySvc, appLogger)
+
+    messages, err := i18n.NewCatalog(i18n.Builtin())
+    if err != nil {
+        appLogger.Error("failed to load message catalogs", "error", err)
+        os.Exit(1)
+    }
+
+    trustedProxies, err := app.ParseTrustedProxies(cfg.TrustedProxies)
+    if err != nil {
+        appLogger.Error("invalid trusted proxies", "error", err)
+        os.Exit(1)
+    }
 
     r := chi.NewRouter()
 
     // Global middleware
-    r.Use(middleware.Logger)
-    r.Use(middleware.Recoverer)
     r.Use(handler.RequestIDMiddleware)
-    r.Use(handler.LoggingMiddleware)
+    r.Use(handler.RealIPMiddleware(trustedProxies))
+    r.Use(handler.LocaleMiddleware(messages))
+    r.Use(respond.Middleware(cfg.ResponseEnvelope))
+    r.Use(handler.LoggingMiddleware(appLogger))
+    r.Use(handler.RecoveryMiddleware(appLogger))
+    if cfg.LogPayloads {
+        appLogger.Warn("logging request and response bodies of failed requests", "max_bytes", cfg.LogPayloadMaxBytes)
+        r.Use(handler.PayloadLoggingMiddleware(appLogger, cfg.LogPayloadMaxBytes))
+    }
+    // The import endpoint takes CSV and multipart uploads with its own limit.
+    r.Use(handler.RequestBodyMiddleware(cfg.MaxBodyBytes, "/v1/admin/books/import", "/admin/books/import", "/v1/admin/users/import", "/admin/users/import"))
+    if cfg.RateLimitRPS > 0 {
+        r.Use(handler.RateLimitMiddleware(cfg.RateLimitRPS))
+    }
 
     // Health checks (PUBLIC)
     r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -97,7 +403,7 @@ func main() {
     })
 
     r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
-        if err := dbpool.Ping(r.Context()); err != nil {
+        if err := repos.Ping(r.Context()); err != nil {
             w.WriteHeader(http.StatusServiceUnavailable)
             _, _ = w.Write([]byte(`{"status":"not_ready"}`))
             return
@@ -107,98 +413,396 @@ func main() {
         _, _ = w.Write([]byte(`{"status":"ready"}`))
     })
 
-    // Auth endpoints (PUBLIC)
-    r.Post("/auth/register", userHandler.Register)
-    r.Post("/auth/login", authHandler.Login)
-    r.Post("/auth/refresh", authHandler.Refresh)
-    r.Post("/auth/admin-register", userHandler.RegisterAdmin) 
-
-    // User endpoints (PROTECTED - ALL USERS)
-    r.Group(func(r chi.Router) {
-        r.Use(handler.AuthMiddleware(authSvc))
-        r.Get("/users/me", userHandler.GetProfile)
-        r.Put("/users/me", userHandler.UpdateProfile)
-    })
+    // API docs (PUBLIC)
+    if cfg.SwaggerEnabled {
+        // Leave the host out of the served spec so "Try it out" calls the
+        // host the UI was loaded from.
+        docs.SwaggerInfo.Host = ""
+        if err := docs.DocumentLoanDuration(cfg.LoanDuration()); err != nil {
+            appLogger.Error("failed to render API docs", "error", err)
+            os.Exit(1)
+        }
+        r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL("/swagger/doc.json")))
+    }
 
-    // Admin endpoints (PROTECTED - ADMIN ONLY)
-    r.Group(func(r chi.Router) {
-        r.Use(handler.AuthMiddleware(authSvc))
-        r.Use(handler.AdminMiddleware)
-
-        // Book CRUD (admin only)
-        r.Route("/admin/books", func(r chi.Router) {
-            r.Get("/", bookHandler.List)
-            r.Post("/", bookHandler.Create)
-            r.Get("/{id}", bookHandler.Get)
-            r.Put("/{id}", bookHandler.Update)
-            r.Delete("/{id}", bookHandler.Delete)
-        })
+    // apiV1 registers version 1 of the REST API. A breaking change gets its
+    // own apiV2 mounted at /v2 beside it, so both versions can be served
+    // while clients migrate.
+    apiV1 := func(r chi.Router) {
+        r.Use(handler.TimeoutMiddleware(cfg.RequestTimeout, cfg.RouteTimeouts))
+        r.Use(handler.MaintenanceMiddleware(maintenanceSvc, cfg.MaintenanceRetryAfter, cfg.MaintenanceAllow))
+        r.Use(handler.TenantMiddleware(branchSvc, cfg.TenantBaseDomain))
+
+        // Auth endpoints (PUBLIC)
+        r.Get("/auth/challenge", challengeHandler.Issue)
+        r.With(challengeHandler.Require).Post("/auth/register", userHandler.Register)
+        r.Post("/auth/login", authHandler.Login)
+        r.Post("/auth/refresh", authHandler.Refresh)
+        if cfg.AuthMode == "cookie" {
+            r.Post("/auth/logout", authHandler.Logout)
+        }
+        r.Get("/auth/confirm-email", userHandler.ConfirmEmail)
+        r.Post("/auth/accept-invitation", userImportHandler.AcceptInvitation)
+        r.Get("/auth/oidc/{provider}/login", oidcHandler.Login)
+        r.Get("/auth/oidc/{provider}/callback", oidcHandler.Callback)
+        // Invite-only registration leaves no way to sign up as an admin;
+        // admins promote invited users instead.
+        if !cfg.RegistrationInviteOnly {
+            r.With(challengeHandler.Require).Post("/auth/admin-register", userHandler.RegisterAdmin)
+        }
 
-        // User management (admin only)
-        r.Route("/admin/users", func(r chi.Router) {
-            r.Get("/", userHandler.ListUsers)
-            r.Get("/{id}", userHandler.GetUser)
-            r.Delete("/{id}", userHandler.DeleteUser)
+        // Payment provider webhook (PUBLIC, signed by the provider)
+        r.Post("/payments/webhook", fineHandler.Webhook)
+
+        // User endpoints (PROTECTED - ALL USERS)
+        r.Group(func(r chi.Router) {
+            r.Use(handler.AuthMiddleware(authSvc, apiKeySvc, cookies))
+            r.Get("/users/me", userHandler.GetProfile)
+            r.Put("/users/me", userHandler.UpdateProfile)
+            r.Delete("/users/me", accountHandler.DeleteMe)
+            r.Post("/users/me/change-password", userHandler.ChangePassword)
+            r.Get("/users/me/preferences", userHandler.GetPreferences)
+            r.Put("/users/me/preferences", userHandler.UpdatePreferences)
+            r.Get("/users/me/sessions", authHandler.ListSessions)
+            r.Delete("/users/me/sessions/{id}", authHandler.RevokeSession)
+            r.Get("/users/me/logins", loginHistoryHandler.ListMine)
+            r.Get("/users/me/fines", fineHandler.ListMine)
+            r.Post("/users/me/fines/{id}/pay", fineHandler.Pay)
+            r.Get("/users/me/fines/{id}/receipt", fineHandler.Receipt)
+            r.Get("/users/me/stats", userStatsHandler.Get)
+            r.Route("/users/me/lists", func(r chi.Router) {
+                r.Get("/", readingListHandler.List)
+                r.Post("/", readingListHandler.Create)
+                r.Get("/{id}", readingListHandler.Get)
+                r.Put("/{id}", readingListHandler.Rename)
+                r.Delete("/{id}", readingListHandler.Delete)
+                r.Put("/{id}/books/{bookId}", readingListHandler.AddBook)
+                r.Delete("/{id}/books/{bookId}", readingListHandler.RemoveBook)
+                r.Post("/{id}/share", readingListHandler.Share)
+                r.Delete("/{id}/share", readingListHandler.Unshare)
+            })
+            r.Get("/users/me/extension-requests", extensionHandler.ListMine)
+            r.Get("/users/me/favorites", readingListHandler.Favorites)
+            r.Put("/users/me/favorites/{bookId}", readingListHandler.AddFavorite)
+            r.Delete("/users/me/favorites/{bookId}", readingListHandler.RemoveFavorite)
         })
 
-        // View all bookings (admin only)
-        r.Get("/admin/bookings", bookingHandler.ListAllBookings)
-    })
-
-    // Public book viewing
-    r.Get("/books", bookHandler.List)
-
-    // User borrowing endpoints (PROTECTED - ALL USERS)
-    r.Group(func(r chi.Router) {
-        r.Use(handler.AuthMiddleware(authSvc))
-
-        // Book viewing (any user)
-        r.Get("/books/{id}", bookHandler.Get)
+        // Admin endpoints (PROTECTED - ADMIN ONLY)
+        r.Group(func(r chi.Router) {
+            r.Use(handler.AuthMiddleware(authSvc, apiKeySvc, cookies))
+            r.Use(handler.AdminMiddleware)
+
+            r.Get("/admin/ws", liveHandler.Feed)
+
+            // Book CRUD (admin only)
+            r.Route("/admin/books", func(r chi.Router) {
+                r.Get("/", bookHandler.List)
+                r.Post("/", bookHandler.Create)
+                r.Post("/import", bookHandler.Import)
+                r.Get("/export", bookHandler.Export)
+                r.Get("/stream", bookHandler.Stream)
+                r.Get("/{id}", bookHandler.Get)
+                r.Put("/{id}", bookHandler.Update)
+                r.Get("/{id}/label", bookHandler.Label)
+                r.Post("/{id}/enrich", bookHandler.Enrich)
+                r.Post("/{id}/merge-into/{targetId}", bookHandler.Merge)
+                r.Get("/{id}/history", bookHandler.History)
+                r.Post("/{id}/restore", bookHandler.Restore)
+                r.Delete("/{id}", bookHandler.Delete)
+            })
+
+            // Category CRUD (admin only)
+            r.Route("/admin/categories", func(r chi.Router) {
+                r.Get("/", categoryHandler.List)
+                r.Post("/", categoryHandler.Create)
+                r.Get("/{id}", categoryHandler.Get)
+                r.Put("/{id}", categoryHandler.Update)
+                r.Delete("/{id}", categoryHandler.Delete)
+            })
+
+            // Branch CRUD (admins not scoped to a branch)
+            r.Route("/admin/branches", func(r chi.Router) {
+                r.Use(handler.GlobalUserMiddleware)
+                r.Get("/", branchHandler.List)
+                r.Post("/", branchHandler.Create)
+                r.Get("/{id}", branchHandler.Get)
+                r.Put("/{id}", branchHandler.Update)
+                r.Delete("/{id}", branchHandler.Delete)
+            })
+
+            // API keys for machine clients (admins not scoped to a branch)
+            r.Route("/admin/api-keys", func(r chi.Router) {
+                r.Use(handler.GlobalUserMiddleware)
+                r.Get("/", apiKeyHandler.List)
+                r.Post("/", apiKeyHandler.Create)
+                r.Delete("/{id}", apiKeyHandler.Revoke)
+            })
+
+            // Loan and fine policies (admin only)
+            r.Route("/admin/policies", func(r chi.Router) {
+                r.Get("/loans", loanPolicyHandler.ListPolicies)
+                r.Put("/loans/{role}", loanPolicyHandler.SetPolicy)
+                r.Get("/books/{id}", loanPolicyHandler.GetBookRestriction)
+                r.Put("/books/{id}", loanPolicyHandler.SetBookRestriction)
+                r.Delete("/books/{id}", loanPolicyHandler.DeleteBookRestriction)
+                r.Get("/fines", finePolicyHandler.Get)
+                r.Put("/fines", finePolicyHandler.Set)
+            })
+
+            // User management (admin only)
+            r.Route("/admin/users", func(r chi.Router) {
+                r.Get("/", userHandler.ListUsers)
+                r.Post("/import", userImportHandler.Import)
+                r.Get("/{id}", userHandler.GetUser)
+                r.Put("/{id}", userHandler.UpdateUser)
+                r.Post("/{id}/suspend", userHandler.SuspendUser)
+                r.Post("/{id}/unsuspend", userHandler.UnsuspendUser)
+                r.Post("/{id}/impersonate", accountHandler.Impersonate)
+                r.Delete("/{id}", userHandler.DeleteUser)
+            })
+
+            // Registration invite codes (admin only)
+            r.Route("/admin/invites", func(r chi.Router) {
+                r.Get("/", inviteCodeHandler.List)
+                r.Post("/", inviteCodeHandler.Create)
+                r.Delete("/{id}", inviteCodeHandler.Revoke)
+            })
+
+            // Library calendar (admin only)
+            r.Route("/admin/calendar/closures", func(r chi.Router) {
+                r.Post("/", calendarHandler.Create)
+                r.Delete("/{id}", calendarHandler.Delete)
+            })
+
+            // Announcements (admin only); they are shown at every branch
+            r.Route("/admin/announcements", func(r chi.Router) {
+                r.Use(handler.GlobalUserMiddleware)
+                r.Get("/", announcementHandler.List)
+                r.Post("/", announcementHandler.Create)
+                r.Get("/{id}", announcementHandler.Get)
+                r.Put("/{id}", announcementHandler.Update)
+                r.Delete("/{id}", announcementHandler.Delete)
+            })
+
+            // Loan extensions beyond the loan policy (admin only)
+            r.Route("/admin/extension-requests", func(r chi.Router) {
+                r.Get("/", extensionHandler.List)
+                r.Post("/{id}/approve", extensionHandler.Approve)
+                r.Post("/{id}/deny", extensionHandler.Deny)
+            })
+
+            // Review moderation (admin only)
+            r.Route("/admin/reviews", func(r chi.Router) {
+                r.Get("/", reviewHandler.List)
+                r.Delete("/{id}", reviewHandler.Delete)
+            })
+
+            // Background job queue (admin only)
+            r.Get("/admin/maintenance", maintenanceHandler.Get)
+            r.Put("/admin/maintenance", maintenanceHandler.Set)
+
+            r.Route("/admin/jobs", func(r chi.Router) {
+                r.Use(handler.GlobalUserMiddleware)
+                r.Get("/", jobHandler.List)
+                r.Get("/{id}", jobHandler.Get)
+                r.Post("/{id}/requeue", jobHandler.Requeue)
+            })
+
+            // Reports (admin only)
+            r.Get("/admin/reports/overdue", reportHandler.Overdue)
+
+            // View all bookings (admin only)
+            r.Get("/admin/bookings", bookingHandler.ListAllBookings)
+            r.Get("/admin/bookings/export", bookingHandler.Export)
+            r.Get("/admin/bookings/stream", bookingHandler.Stream)
+            r.Post("/admin/bookings/{id}/return", bookingHandler.AdminReturn)
+        })
 
-        // Borrowing (any user)
-        r.Route("/bookings", func(r chi.Router) {
-            r.Get("/", bookingHandler.GetMyBookings)
-            r.Post("/", bookingHandler.Borrow)
-            r.Get("/{id}", bookingHandler.GetBooking)
-            r.Post("/{id}/return", bookingHandler.Return)
+        // Public book viewing
+        r.With(handler.CacheControlMiddleware(cfg.BookCacheMaxAge, cookies)).Get("/books", bookHandler.List)
+        r.Get("/books/popular", bookListingHandler.Popular)
+        r.Get("/books/new", bookListingHandler.New)
+        r.Get("/calendar", calendarHandler.List)
+        r.With(handler.OptionalAuthMiddleware(authSvc, apiKeySvc, cookies)).Get("/announcements", announcementHandler.Active)
+        r.Get("/lists/shared/{token}", readingListHandler.Shared)
+        r.Get("/bookings/calendar.ics", bookingCalendarHandler.Feed)
+
+        // User borrowing endpoints (PROTECTED - ALL USERS)
+        r.Group(func(r chi.Router) {
+            r.Use(handler.AuthMiddleware(authSvc, apiKeySvc, cookies))
+
+            // Book viewing (any user)
+            r.With(handler.CacheControlMiddleware(cfg.BookCacheMaxAge, cookies)).Get("/books/{id}", bookHandler.Get)
+            r.Get("/books/{id}/reviews", reviewHandler.ListByBook)
+            r.Post("/books/{id}/reviews", reviewHandler.Create)
+
+            // Borrowing (any user)
+            r.Route("/bookings", func(r chi.Router) {
+                r.Get("/", bookingHandler.GetMyBookings)
+                r.Post("/", bookingHandler.Borrow)
+                r.Post("/scan", bookingHandler.BorrowScanned)
+                r.Get("/calendar", bookingCalendarHandler.FeedURL)
+                r.Post("/calendar/reset", bookingCalendarHandler.ResetFeedURL)
+                r.Get("/events", liveHandler.BookingEvents)
+                r.Get("/{id}", bookingHandler.GetBooking)
+                r.Post("/{id}/return", bookingHandler.Return)
+                r.Post("/{id}/accept", bookingHandler.AcceptOffer)
+                r.Post("/{id}/decline", bookingHandler.DeclineOffer)
+                r.Post("/{id}/extension-requests", extensionHandler.Request)
+            })
+
+            // Waitlists (any user)
+            r.Route("/reservations", func(r chi.Router) {
+                r.Get("/", reservationHandler.ListMine)
+                r.Post("/", reservationHandler.Reserve)
+                r.Delete("/{id}", reservationHandler.Cancel)
+            })
+        })
+    }
+    r.Route("/v1", apiV1)
+
+    // The unversioned paths predate /v1; keep serving them, flagged as
+    // deprecated, until clients have moved.
+    if cfg.LegacyRoutes {
+        r.Group(func(r chi.Router) {
+            r.Use(handler.DeprecationMiddleware(legacyRoutesDeprecatedAt, cfg.LegacySunset(), "/v1"))
+            apiV1(r)
         })
-    })
- port := cfg.Port
-if port == "" { port = "8080" }
-if strings.Contains(port, ":") {
-    parts := strings.Split(port, ":")
-    port = parts[len(parts)-1]
-}
-// Force listen on all interfaces
-addr := ":" + port
-
-    srv := &http.Server{
-        Addr:         addr,
-        Handler:      r,
-        ReadTimeout:  15 * time.Second,
-        WriteTimeout: 15 * time.Second,
-        IdleTimeout:  60 * time.Second,
     }
 
+    // The API's router takes the port over from the startup probes.
+    if startup != nil {
+        if err := startup.Shutdown(ctx); err != nil {
+            appLogger.Warn("startup probe server shutdown failed", "error", err)
+        }
+    }
+    srv := app.NewServer(cfg, port, r)
+
     // Start server
     go func() {
-        log.Printf("starting server on %s", srv.Addr)
+        appLogger.Info("starting server", "addr", srv.Addr, "tls", srv.TLS(), "h2c", cfg.H2C)
         if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-            log.Fatalf("ListenAndServe(): %v", err)
+            appLogger.Error("ListenAndServe failed", "error", err)
+            os.Exit(1)
+        }
+    }()
+
+    // gRPC API on its own port, sharing the services above
+    var grpcSrv *grpc.Server
+    if cfg.GRPCEnabled() {
+        lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+        if err != nil {
+            appLogger.Error("grpc listen failed", "error", err)
+            os.Exit(1)
         }
+        grpcSrv = grpcserver.New(grpcserver.Services{
+            Books:    bookSvc,
+            Users:    userSvc,
+            Bookings: bookingSvc,
+            Auth:     authSvc,
+        }, appLogger)
+        go func() {
+            appLogger.Info("starting grpc server", "addr", lis.Addr().String())
+            if err := grpcSrv.Serve(lis); err != nil {
+                appLogger.Error("grpc Serve failed", "error", err)
+                os.Exit(1)
+            }
+        }()
+    }
+
+    // Background jobs
+    schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+    schedulerDone := make(chan struct{})
+    go func() {
+        defer close(schedulerDone)
+        scheduler.Run(schedulerCtx, cfg.SchedulerInterval, appLogger,
+            scheduler.Job{Name: "mark-overdue", Run: bookingSvc.UpdateOverdue},
+            scheduler.Job{Name: "expire-offers", Run: bookingSvc.ExpireOffers},
+            scheduler.Job{Name: "due-reminders", Run: func(ctx context.Context) error {
+                return bookingSvc.SendDueReminders(ctx, cfg.DueReminderLead)
+            }},
+            scheduler.Job{Name: "overdue-report", Run: reportSvc.SendScheduledOverdue},
+        )
     }()
 
+    worker := jobs.NewWorker(jobRepo, jobs.Options{
+        Batch:       max(10, cfg.JobConcurrency),
+        Timeout:     cfg.JobTimeout,
+        Backoff:     cfg.JobRetryBackoff,
+        MaxBackoff:  cfg.JobMaxBackoff,
+        Concurrency: cfg.JobConcurrency,
+        Metrics:     logger.GetLogger(),
+    }, appLogger)
+    worker.Handle(jobs.KindEmail, jobs.EmailHandler(emailProvider))
+    worker.Handle(jobs.KindWebhook, jobs.WebhookHandler(notifier))
+    workerDone := make(chan struct{})
+    go func() {
+        defer close(workerDone)
+        worker.Run(schedulerCtx, cfg.JobPollInterval)
+    }()
+
+    var eventPublisher events.Publisher = events.NewLog(appLogger)
+    if cfg.EventPublisher == "webhook" {
+        eventPublisher = events.NewWebhook(requestid.Client(&http.Client{Timeout: cfg.EventWebhookTimeout}), cfg.EventWebhookURL, cfg.EventWebhookSecret)
+    }
+    // The relay publishes each event on one instance, so with Postgres it
+    // is broadcast for every instance to hand to its dashboards.
+    if dbpool != nil {
+        eventPublisher = events.Multi{eventPublisher, events.NewNotify(dbpool)}
+        go events.Listen(schedulerCtx, dbpool, liveEvents, appLogger)
+    } else {
+        eventPublisher = events.Multi{eventPublisher, liveEvents}
+    }
+    relay := events.NewRelay(outboxRepo, txMgr, eventPublisher, 100, cfg.OutboxRetention, appLogger)
+    relayDone := make(chan struct{})
+    go func() {
+        defer close(relayDone)
+        relay.Run(schedulerCtx, cfg.OutboxPollInterval)
+    }()
+
+    if dbpool != nil {
+        go app.ReportPoolStats(schedulerCtx, dbpool, "primary", cfg.DBStatsInterval, logger.GetLogger())
+    }
+    if replica != nil {
+        go app.ReportPoolStats(schedulerCtx, readPool, "replica", cfg.DBStatsInterval, logger.GetLogger())
+        go replica.Monitor(schedulerCtx, cfg.DBReadHealthInterval, cfg.DBReadMaxLag, appLogger)
+    }
+
     // Graceful shutdown
     stop := make(chan os.Signal, 1)
     signal.Notify(stop, os.Interrupt)
     <-stop
-    log.Println("shutting down")
+    appLogger.Info("shutting down")
 
-    ctxShutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    ctxShutdown, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
     defer cancel()
 
+    stopScheduler()
+    for _, done := range []chan struct{}{schedulerDone, workerDone, relayDone} {
+        select {
+        case <-done:
+        case <-ctxShutdown.Done():
+        }
+    }
+
+    if grpcSrv != nil {
+        // GracefulStop waits for in-flight calls; cut them off if that
+        // outlasts the shutdown budget.
+        stopped := make(chan struct{})
+        go func() {
+            grpcSrv.GracefulStop()
+            close(stopped)
+        }()
+        select {
+        case <-stopped:
+        case <-ctxShutdown.Done():
+            grpcSrv.Stop()
+        }
+    }
+
     if err := srv.Shutdown(ctxShutdown); err != nil {
-        log.Fatalf("server shutdown failed: %v", err)
+        appLogger.Error("server shutdown failed", "error", err)
+        return
     }
-    log.Println("server stopped")
+    appLogger.Info("server stopped")
 }
\ No newline at end of file
@@ -0,0 +1,49 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/app"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/oidc"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// oidcProviders builds the configured identity providers. Google's
+// endpoints and keys are discovered here, so startup fails if Google can't
+// be reached.
+func oidcProviders(ctx context.Context, cfg *app.Config) (map[string]oidc.Provider, error) {
+    client := requestid.Client(&http.Client{Timeout: 10 * time.Second})
+    providers := map[string]oidc.Provider{}
+    for name, p := range cfg.OIDCProviders {
+        pc := oidc.Config{ClientID: p.ClientID, ClientSecret: p.ClientSecret, RedirectURL: cfg.OIDCRedirectURL(name), Groups: len(p.Roles) > 0}
+        switch name {
+        case "google":
+            g, err := oidc.NewGoogle(ctx, client, pc)
+            if err != nil {
+                return nil, err
+            }
+            providers[name] = g
+        case "github":
+            providers[name] = oidc.NewGitHub(client, pc)
+        default:
+            return nil, fmt.Errorf("unsupported OIDC provider %q", name)
+        }
+    }
+    return providers, nil
+}
+
+// oidcRoles returns the group to role mapping of every provider that has
+// one, keyed by provider name.
+func oidcRoles(cfg *app.Config) map[string]map[string]model.Role {
+    roles := map[string]map[string]model.Role{}
+    for name, p := range cfg.OIDCProviders {
+        if len(p.Roles) > 0 {
+            roles[name] = p.Roles
+        }
+    }
+    return roles
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/seed"
+)
+
+// runSeed implements `library-api seed FILE`, which loads the fixtures in
+// FILE through seeder and exits instead of serving.
+func runSeed(ctx context.Context, args []string, driver string, seeder *seed.Seeder) error {
+    fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "usage: library-api seed FILE\n\nLoads sample categories, users, books and bookings from a YAML or JSON fixture file.")
+    }
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() != 1 {
+        fs.Usage()
+        return errors.New("seed takes exactly one fixture file")
+    }
+    if driver == "memory" {
+        return errors.New("seed needs DB_DRIVER=postgres; the memory driver keeps nothing once the command exits")
+    }
+
+    fixtures, err := seed.Load(fs.Arg(0))
+    if err != nil {
+        return err
+    }
+    _, err = seeder.Run(ctx, fixtures)
+    return err
+}
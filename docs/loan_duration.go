@@ -0,0 +1,37 @@
+package docs
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// DocumentLoanDuration rewrites the served spec to show the loan lengths the
+// library is configured with rather than the built-in ones: every
+// borrow_days property gets d's bounds and default, and the max_borrow_days
+// that loan policy and restriction requests set is capped at d.MaxDays.
+// Set SwaggerInfo.Host first; the spec is rendered once, here.
+func DocumentLoanDuration(d model.LoanDuration) error {
+	var spec map[string]any
+	if err := json.Unmarshal([]byte(SwaggerInfo.ReadDoc()), &spec); err != nil {
+		return err
+	}
+	definitions, _ := spec["definitions"].(map[string]any)
+	for name, definition := range definitions {
+		schema, _ := definition.(map[string]any)
+		properties, _ := schema["properties"].(map[string]any)
+		if p, ok := properties["borrow_days"].(map[string]any); ok {
+			p["minimum"], p["maximum"], p["default"] = d.MinDays, d.MaxDays, d.DefaultDays
+		}
+		if p, ok := properties["max_borrow_days"].(map[string]any); ok && strings.HasSuffix(name, "Request") {
+			p["maximum"] = d.MaxDays
+		}
+	}
+	rendered, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	SwaggerInfo.SwaggerTemplate = string(rendered)
+	return nil
+}
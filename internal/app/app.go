@@ -3,34 +3,41 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
 )
 
 // App is the central application container.
 // It wires together config, db pool, logger and other shared resources.
 type App struct {
 	Config *Config
-	Logger *log.Logger
+	Logger *slog.Logger
 	DB     *pgxpool.Pool
 }
 
-// NewStdLogger returns a simple standard library logger writing to stdout.
-func NewStdLogger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags)
+// NewLogger returns the JSON structured logger shared by handlers and
+// services. Unknown levels fall back to info.
+func NewLogger(w io.Writer, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	return logger.NewJSON(w, lvl)
 }
 
 // New creates a fully initialized App instance.
 // It loads configuration, sets up logging and initializes the DB connection pool.
 func New(ctx context.Context) (*App, error) {
-	cfg, err := LoadConfigFromEnv()
+	cfg, err := LoadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
 
-	logger := NewStdLogger()
+	log := NewLogger(os.Stdout, cfg.LogLevel)
 
 	db, err := NewDBPool(ctx, cfg)
 	if err != nil {
@@ -39,7 +46,7 @@ func New(ctx context.Context) (*App, error) {
 
 	return &App{
 		Config: cfg,
-		Logger: logger,
+		Logger: log,
 		DB:     db,
 	}, nil
 }
@@ -1,46 +1,1359 @@
 package app
 
 import (
-    "errors"
+    "bytes"
+    "context"
+    "fmt"
+    "net/mail"
+    "net/netip"
+    "net/url"
     "os"
+    "path"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/secrets"
+    "golang.org/x/crypto/bcrypt"
+    "gopkg.in/yaml.v3"
 )
 
 type Config struct {
-    DatabaseURL string
-    Port        string
+    // DBDriver is "postgres", which needs DatabaseURL, or "memory", which
+    // keeps everything in process for local development and tests.
+    DBDriver    string `yaml:"db_driver"`
+    DatabaseURL string `yaml:"database_url"`
+    // DatabaseReadURL is an optional read replica for lists, searches and
+    // catalog lookups. It is checked every DBReadHealthInterval; reads go
+    // to the primary while it is down or lags by more than DBReadMaxLag
+    // (0 disables the lag check).
+    DatabaseReadURL      string        `yaml:"database_read_url"`
+    DBReadHealthInterval time.Duration `yaml:"db_read_health_interval"`
+    DBReadMaxLag         time.Duration `yaml:"db_read_max_lag"`
+    Port        string `yaml:"port"`
+    LogLevel    string `yaml:"log_level"`
+
+    // GRPCPort serves the gRPC API alongside HTTP; "0" disables it.
+    GRPCPort string `yaml:"grpc_port"`
+
+    // Auth. JWTKeys takes precedence over JWTSecret; the first key signs new
+    // tokens and every key is accepted when validating.
+    JWTSecret           string        `yaml:"jwt_secret"`
+    JWTKeys             []JWTKey      `yaml:"jwt_keys"`
+    JWTSecretsManagerID string        `yaml:"jwt_secrets_manager_id"`
+    JWTExpiry           time.Duration `yaml:"jwt_expiry"`
+    // JWTIssuer and JWTAudience go in the iss and aud claims of new tokens,
+    // and tokens without them are refused; empty leaves them out.
+    // JWTLeeway is the clock skew tolerated when checking exp and iat.
+    JWTIssuer   string        `yaml:"jwt_issuer"`
+    JWTAudience string        `yaml:"jwt_audience"`
+    JWTLeeway   time.Duration `yaml:"jwt_leeway"`
+    // SessionCacheTTL is how long an instance trusts its list of revoked
+    // sessions before reloading it; sessions revoked on another instance
+    // are honored within this long.
+    SessionCacheTTL time.Duration `yaml:"session_cache_ttl"`
+    // ImpersonationTTL is how long the token an admin gets to act as
+    // another user for support lasts; it can't be refreshed.
+    ImpersonationTTL time.Duration `yaml:"impersonation_ttl"`
+    // AuthCookie names a cookie the API also reads JWTs from when a request
+    // has no Authorization header; empty accepts only the header.
+    AuthCookie string `yaml:"auth_cookie"`
+    // AuthMode is how logins hand out tokens: "header" returns them in the
+    // body for the client to send back in the Authorization header;
+    // "cookie", for browser apps, keeps a refresh token in the HttpOnly
+    // RefreshCookie and returns access tokens lasting AccessTokenTTL, with
+    // a double-submit CSRF token in CSRFCookie.
+    AuthMode       string        `yaml:"auth_mode"`
+    AccessTokenTTL time.Duration `yaml:"access_token_ttl"`
+    RefreshCookie  string        `yaml:"refresh_cookie"`
+    CSRFCookie     string        `yaml:"csrf_cookie"`
+    // CalendarFeedSecret signs the tokens in users' due-date calendar feed
+    // URLs; empty turns the feeds off. Changing it breaks every feed URL
+    // handed out.
+    CalendarFeedSecret string `yaml:"calendar_feed_secret"`
+    // RegistrationChallenge is what /auth/register asks clients to solve
+    // first: "" (nothing), "hcaptcha" or "turnstile", answered with the
+    // widget ChallengeSiteKey and checked with ChallengeSecret, or "pow", a
+    // proof of work of PoWDifficulty bits whose nonces ChallengeSecret
+    // signs.
+    RegistrationChallenge string `yaml:"registration_challenge"`
+    ChallengeSiteKey      string `yaml:"challenge_site_key"`
+    ChallengeSecret       string `yaml:"challenge_secret"`
+    PoWDifficulty         int    `yaml:"pow_difficulty"`
+
+    // RegistrationInviteOnly closes /auth/register to everyone without an
+    // invite code minted by an admin.
+    RegistrationInviteOnly bool `yaml:"registration_invite_only"`
+
+    // Rate limiting (requests per second per client IP; 0 disables it)
+    RateLimitRPS int `yaml:"rate_limit_rps"`
+
+    // Login lockout. Failures within LoginFailureWindow are counted per
+    // username and per client IP; a limit of 0 disables that check.
+    LoginMaxFailures      int           `yaml:"login_max_failures"`
+    LoginMaxFailuresPerIP int           `yaml:"login_max_failures_per_ip"`
+    LoginFailureWindow    time.Duration `yaml:"login_failure_window"`
+    LoginLockoutDuration  time.Duration `yaml:"login_lockout_duration"`
+    // Login rate limits, counted per instance over LoginRatePeriod whether
+    // or not the attempts succeed; 0 disables a limit.
+    LoginRatePerIP       int           `yaml:"login_rate_per_ip"`
+    LoginRatePerUsername int           `yaml:"login_rate_per_username"`
+    LoginRatePeriod      time.Duration `yaml:"login_rate_period"`
+
+    // Password policy for registration and password changes
+    PasswordMinLength     int    `yaml:"password_min_length"`
+    PasswordRequireUpper  bool   `yaml:"password_require_upper"`
+    PasswordRequireLower  bool   `yaml:"password_require_lower"`
+    PasswordRequireDigit  bool   `yaml:"password_require_digit"`
+    PasswordRequireSymbol bool   `yaml:"password_require_symbol"`
+    PasswordDenylistFile  string `yaml:"password_denylist_file"`
+
+    // Passwords are hashed with PasswordHash: "bcrypt" at BcryptCost, or
+    // "argon2id" using Argon2MemoryKiB of memory, Argon2Time passes and
+    // Argon2Threads lanes. A hash made otherwise, as before a change here,
+    // still works and is redone at the user's next login.
+    PasswordHash    string `yaml:"password_hash"`
+    BcryptCost      int    `yaml:"bcrypt_cost"`
+    Argon2MemoryKiB int    `yaml:"argon2_memory_kib"`
+    Argon2Time      int    `yaml:"argon2_time"`
+    Argon2Threads   int    `yaml:"argon2_threads"`
+
+    // EmailCheckMX rejects email addresses whose domain has no mail server
+    EmailCheckMX bool `yaml:"email_check_mx"`
+
+    // Database pool. DBQueryExecMode is one of pgx's query exec modes, such
+    // as exec or simple_protocol behind a transaction-pooling PgBouncer;
+    // empty keeps DATABASE_URL's default_query_exec_mode, else
+    // cache_statement. Pool statistics are recorded as metrics every
+    // DBStatsInterval.
+    DBMaxConns          int32         `yaml:"db_max_conns"`
+    DBMinConns          int32         `yaml:"db_min_conns"`
+    DBMaxConnLifetime   time.Duration `yaml:"db_max_conn_lifetime"`
+    DBMaxConnIdleTime   time.Duration `yaml:"db_max_conn_idle_time"`
+    DBHealthCheckPeriod time.Duration `yaml:"db_health_check_period"`
+    DBConnectTimeout    time.Duration `yaml:"db_connect_timeout"`
+    // DBConnectMaxWait bounds how long startup retries an unreachable
+    // database, with exponential backoff, before giving up; 0 tries once.
+    DBConnectMaxWait    time.Duration `yaml:"db_connect_max_wait"`
+    DBQueryExecMode     string        `yaml:"db_query_exec_mode"`
+    DBStatsInterval     time.Duration `yaml:"db_stats_interval"`
+
+    // DBSlowQueryThreshold is how long a statement runs before it is
+    // logged as slow; 0 logs none.
+    DBSlowQueryThreshold time.Duration `yaml:"db_slow_query_threshold"`
+    // DBPointTimeout bounds each single-row read and DBListTimeout each
+    // list, search and write, so one runaway query can't hold its
+    // connection until the request gives up; 0 leaves them unbounded.
+    DBPointTimeout       time.Duration `yaml:"db_point_timeout"`
+    DBListTimeout        time.Duration `yaml:"db_list_timeout"`
+
+    // HTTP server
+    MaxBodyBytes    int64         `yaml:"max_body_bytes"`
+    ReadTimeout     time.Duration `yaml:"read_timeout"`
+    WriteTimeout    time.Duration `yaml:"write_timeout"`
+    IdleTimeout     time.Duration `yaml:"idle_timeout"`
+    ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+    // TLS. With TLSCertFile and TLSKeyFile the server terminates TLS itself;
+    // with TLSAutocertDomains it gets certificates for those domains from
+    // Let's Encrypt, kept in TLSAutocertCacheDir, and answers the ACME
+    // challenge (and redirects everything else to HTTPS) on
+    // TLSAutocertHTTPPort. HTTPS is served over HTTP/2 as well as HTTP/1.1.
+    // Without TLS, H2C also accepts HTTP/2 in the clear, for a proxy that
+    // terminates TLS and speaks HTTP/2 to the API.
+    TLSCertFile         string   `yaml:"tls_cert_file"`
+    TLSKeyFile          string   `yaml:"tls_key_file"`
+    TLSAutocertDomains  []string `yaml:"tls_autocert_domains"`
+    TLSAutocertEmail    string   `yaml:"tls_autocert_email"`
+    TLSAutocertCacheDir string   `yaml:"tls_autocert_cache_dir"`
+    TLSAutocertHTTPPort string   `yaml:"tls_autocert_http_port"`
+    H2C                 bool     `yaml:"h2c"`
+    // TrustedProxies are the addresses (IPs or CIDRs) of the load balancers
+    // and proxies in front of the API. Only they are believed about the
+    // client's address in X-Forwarded-For.
+    TrustedProxies []string `yaml:"trusted_proxies"`
+
+    // Log redacted request/response bodies of failed requests (staging aid),
+    // capturing at most LogPayloadMaxBytes of each.
+    LogPayloads        bool `yaml:"log_payloads"`
+    LogPayloadMaxBytes int  `yaml:"log_payload_max_bytes"`
+
+    // Wrap JSON responses as {data, meta, error} unless the client asks for
+    // the plain profile; clients can also opt in one request at a time.
+    ResponseEnvelope bool `yaml:"response_envelope"`
+
+    // Per-request budgets. RouteTimeouts overrides RequestTimeout for paths
+    // (relative to /v1, globs allowed) that legitimately run long.
+    RequestTimeout time.Duration            `yaml:"request_timeout"`
+    RouteTimeouts  map[string]time.Duration `yaml:"route_timeouts"`
+
+    // Maintenance mode. Admins switch it at runtime; MaintenanceMode keeps
+    // it on from startup. MaintenanceAllow lists the paths (relative to /v1,
+    // globs allowed) still served besides admin routes and health checks.
+    MaintenanceMode       bool          `yaml:"maintenance_mode"`
+    MaintenanceRetryAfter time.Duration `yaml:"maintenance_retry_after"`
+    MaintenanceAllow      []string      `yaml:"maintenance_allow"`
+    MaintenanceCacheTTL   time.Duration `yaml:"maintenance_cache_ttl"`
+
+    // API versions. The REST API lives under /v1; LegacyRoutes also serves
+    // it at the unversioned paths, marked deprecated, until the sunset date
+    // (YYYY-MM-DD, optional) announced in LegacyRoutesSunset.
+    LegacyRoutes       bool   `yaml:"legacy_routes"`
+    LegacyRoutesSunset string `yaml:"legacy_routes_sunset"`
+
+    // Serve Swagger UI and the OpenAPI spec under /swagger/
+    SwaggerEnabled bool `yaml:"swagger_enabled"`
+
+    // Branches can be addressed by subdomain of TenantBaseDomain, e.g.
+    // north.library.example.com. Empty leaves only the X-Branch header.
+    TenantBaseDomain string `yaml:"tenant_base_domain"`
+
+    // Login through external identity providers, keyed by provider name
+    // ("google" or "github"). Providers redirect back to
+    // OIDCRedirectBaseURL/v1/auth/oidc/{provider}/callback, which must be
+    // registered with them.
+    OIDCRedirectBaseURL string                  `yaml:"oidc_redirect_base_url"`
+    OIDCProviders       map[string]OIDCProvider `yaml:"oidc_providers"`
+
+    // Book metadata lookup by ISBN: "openlibrary" or "googlebooks". Each
+    // request gets MetadataTimeout and failed ones are retried MetadataRetries
+    // times.
+    MetadataProvider  string        `yaml:"metadata_provider"`
+    MetadataTimeout   time.Duration `yaml:"metadata_timeout"`
+    MetadataRetries   int           `yaml:"metadata_retries"`
+    GoogleBooksAPIKey string        `yaml:"google_books_api_key"`
+
+    // Landing-page listings. GET /books/popular ranks books by the loans
+    // started within PopularBooksWindow; both it and GET /books/new are
+    // cached for BookListingCacheTTL.
+    PopularBooksWindow  time.Duration `yaml:"popular_books_window"`
+    BookListingCacheTTL time.Duration `yaml:"book_listing_cache_ttl"`
+    // BookCacheMaxAge is how long clients and CDNs may keep GET /books and
+    // GET /books/{id} responses before revalidating them.
+    BookCacheMaxAge time.Duration `yaml:"book_cache_max_age"`
+    // UserStatsCacheTTL is how long GET /users/me/stats answers are reused.
+    UserStatsCacheTTL time.Duration `yaml:"user_stats_cache_ttl"`
+
+    // Loan lengths. Borrowers ask for between BorrowDaysMin and
+    // BorrowDaysMax days, as far as their loan policy allows, and get
+    // BorrowDaysDefault days when they don't ask. Loan policies can't allow
+    // more than BorrowDaysMax.
+    BorrowDaysMin     int `yaml:"borrow_days_min"`
+    BorrowDaysMax     int `yaml:"borrow_days_max"`
+    BorrowDaysDefault int `yaml:"borrow_days_default"`
+
+    // Waitlists. A returned copy of a reserved book is held for the first
+    // user in line for OfferHoldDuration. Background jobs (lapsing offers,
+    // marking loans overdue) run every SchedulerInterval.
+    OfferHoldDuration time.Duration `yaml:"offer_hold_duration"`
+    SchedulerInterval time.Duration `yaml:"scheduler_interval"`
+
+    // Outgoing email. NotifyProvider is "log", which only logs messages (for
+    // development), "smtp", or "ses", which uses the SMTP interface of Amazon
+    // SES in Region with SES SMTP credentials. Templates in NotifyTemplateDir
+    // (<locale>/<name>.html) replace the builtin ones; NotifyLocale is the
+    // locale emails are written in, and must have every template. Borrowers
+    // are reminded DueReminderLead before a loan is due.
+    NotifyProvider    string        `yaml:"notify_provider"`
+    NotifyFrom        string        `yaml:"notify_from"`
+    NotifyLocale      string        `yaml:"notify_locale"`
+    NotifyTemplateDir string        `yaml:"notify_template_dir"`
+    SMTPHost          string        `yaml:"smtp_host"`
+    SMTPPort          int           `yaml:"smtp_port"`
+    SMTPUsername      string        `yaml:"smtp_username"`
+    SMTPPassword      string        `yaml:"smtp_password"`
+    DueReminderLead   time.Duration `yaml:"due_reminder_lead"`
+    // PublicBaseURL is the API's public URL, which links in emails point
+    // at. A link confirming a user's new email address works for
+    // EmailChangeTTL; the address only changes once it is opened. A link
+    // inviting an imported user to choose a password works for
+    // InvitationTTL.
+    PublicBaseURL  string        `yaml:"public_base_url"`
+    EmailChangeTTL time.Duration `yaml:"email_change_ttl"`
+    InvitationTTL  time.Duration `yaml:"invitation_ttl"`
+
+    // Background job queue (email and webhook delivery). Workers look for
+    // due jobs every JobPollInterval, run up to JobConcurrency at once and
+    // give each attempt JobTimeout. A failed job is retried after
+    // JobRetryBackoff, doubling up to JobMaxBackoff, until it has been
+    // tried JobMaxAttempts times.
+    JobPollInterval time.Duration `yaml:"job_poll_interval"`
+    JobTimeout      time.Duration `yaml:"job_timeout"`
+    JobRetryBackoff time.Duration `yaml:"job_retry_backoff"`
+    JobMaxBackoff   time.Duration `yaml:"job_max_backoff"`
+    JobMaxAttempts  int           `yaml:"job_max_attempts"`
+    JobConcurrency  int           `yaml:"job_concurrency"`
+
+    // Domain events (loans made and returned) are written to an outbox with
+    // the change and relayed every OutboxPollInterval. EventPublisher is
+    // "log", which only logs them (for development), or "webhook", which
+    // POSTs them to EventWebhookURL, signed with EventWebhookSecret when it
+    // is set. Delivered events are kept for OutboxRetention.
+    EventPublisher      string        `yaml:"event_publisher"`
+    EventWebhookURL     string        `yaml:"event_webhook_url"`
+    EventWebhookSecret  string        `yaml:"event_webhook_secret"`
+    EventWebhookTimeout time.Duration `yaml:"event_webhook_timeout"`
+    OutboxPollInterval  time.Duration `yaml:"outbox_poll_interval"`
+    OutboxRetention     time.Duration `yaml:"outbox_retention"`
+
+    // Until admins set a fine policy, overdue loans accrue FinePerDayCents
+    // of FineCurrency for every started day late after the first
+    // FineGraceDays, up to FineMaxCents per loan (0 means no cap). The
+    // overdue report is emailed to OverdueReportRecipients every week on
+    // OverdueReportWeekday (UTC); with no recipients it isn't emailed.
+    FineGraceDays           int      `yaml:"fine_grace_days"`
+    FinePerDayCents         int      `yaml:"fine_per_day_cents"`
+    FineMaxCents            int      `yaml:"fine_max_cents"`
+    FineCurrency            string   `yaml:"fine_currency"`
+    OverdueReportRecipients []string `yaml:"overdue_report_recipients"`
+    OverdueReportWeekday    string   `yaml:"overdue_report_weekday"`
+
+    // Fines for late returns are paid online through PaymentProvider:
+    // "" (fines can't be paid online) or "stripe", with a test-mode
+    // StripeSecretKey (sk_test_...) and the StripeWebhookSecret (whsec_...)
+    // of the endpoint receiving its webhooks. Each fine is charged in the
+    // currency it was assessed in.
+    PaymentProvider     string `yaml:"payment_provider"`
+    StripeSecretKey     string `yaml:"stripe_secret_key"`
+    StripeWebhookSecret string `yaml:"stripe_webhook_secret"`
 
     // AWS CloudWatch
-    Region              string
-    CloudWatchLogGroup  string
-    CloudWatchLogStream string
-    EnableCloudWatch    bool
+    Region              string `yaml:"aws_region"`
+    CloudWatchLogGroup  string `yaml:"cw_log_group"`
+    CloudWatchLogStream string `yaml:"cw_log_stream"`
+    EnableCloudWatch    bool   `yaml:"enable_cloudwatch"`
+}
+
+// JWTKey is one HMAC signing key, identified in tokens by its kid header.
+type JWTKey struct {
+    ID     string `yaml:"id"`
+    Secret string `yaml:"secret"`
+}
+
+// OIDCProvider is the OAuth client registered with an identity provider.
+// Roles maps the provider's groups to the role their members get; when set,
+// a user's role follows their groups at every login through the provider.
+type OIDCProvider struct {
+    ClientID     string                `yaml:"client_id"`
+    ClientSecret string                `yaml:"client_secret"`
+    Roles        map[string]model.Role `yaml:"roles"`
+}
+
+// oidcProviderNames are the identity providers the API can log in with.
+var oidcProviderNames = []string{"google", "github"}
+
+// oidcGroupProviders are the providers that report the groups an account
+// is in, whose groups can be mapped to roles.
+var oidcGroupProviders = []string{"github"}
+
+// OIDCRedirectURL returns the callback URL of the named provider.
+func (c *Config) OIDCRedirectURL(provider string) string {
+    return strings.TrimSuffix(c.OIDCRedirectBaseURL, "/") + "/v1/auth/oidc/" + provider + "/callback"
+}
+
+// EmailConfirmURL returns the URL of the links that confirm a new email
+// address.
+func (c *Config) EmailConfirmURL() string {
+    return strings.TrimSuffix(c.PublicBaseURL, "/") + "/v1/auth/confirm-email"
+}
+
+// InvitationURL is the URL of the links inviting imported users to choose
+// a password, to which the token is added.
+func (c *Config) InvitationURL() string {
+    return strings.TrimSuffix(c.PublicBaseURL, "/") + "/v1/auth/accept-invitation"
 }
 
-func LoadConfigFromEnv() (*Config, error) {
-    dsn := os.Getenv("DATABASE_URL")
-    if dsn == "" {
-        return nil, errors.New("DATABASE_URL required")
+// CalendarFeedURL is the URL of the due-date calendar feeds, to which the
+// user's token is added.
+func (c *Config) CalendarFeedURL() string {
+    return strings.TrimSuffix(c.PublicBaseURL, "/") + "/v1/bookings/calendar.ics"
+}
+
+// SharedListURL is the prefix of reading list share URLs; the list's share
+// token follows it.
+func (c *Config) SharedListURL() string {
+    return strings.TrimSuffix(c.PublicBaseURL, "/") + "/v1/lists/shared/"
+}
+
+// SigningKeys returns the configured JWT keys, active key first. A lone
+// JWT_SECRET is treated as a single key with ID "default".
+func (c *Config) SigningKeys() []JWTKey {
+    if len(c.JWTKeys) > 0 {
+        return c.JWTKeys
     }
-    port := os.Getenv("PORT")
-    if port == "" {
-        port = "8080"
+    if c.JWTSecret != "" {
+        return []JWTKey{{ID: "default", Secret: c.JWTSecret}}
     }
+    return nil
+}
+
+// LoanDuration returns the configured bounds and default of loan lengths.
+func (c *Config) LoanDuration() model.LoanDuration {
+    return model.LoanDuration{MinDays: c.BorrowDaysMin, MaxDays: c.BorrowDaysMax, DefaultDays: c.BorrowDaysDefault}
+}
+
+// LegacySunset returns the date the unversioned routes are due to be removed,
+// or the zero time if none has been announced.
+func (c *Config) LegacySunset() time.Time {
+    t, _ := time.Parse(time.DateOnly, c.LegacyRoutesSunset)
+    return t
+}
+
+// ConfigError lists every missing or invalid setting found while loading, so
+// a misconfigured deployment can be fixed in one pass.
+type ConfigError struct {
+    Problems []string
+}
+
+func (e *ConfigError) Error() string {
+    return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func (e *ConfigError) add(format string, args ...interface{}) {
+    e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
 
+// DefaultConfig returns the settings used when neither the config file nor
+// the environment overrides them.
+func DefaultConfig() *Config {
     return &Config{
-        DatabaseURL: dsn,
-        Port:        port,
+        DBDriver:              "postgres",
+        Port:                  "8080",
+        GRPCPort:              "9090",
+        LogLevel:              "info",
+        JWTExpiry:             24 * time.Hour,
+        JWTLeeway:             30 * time.Second,
+        SessionCacheTTL:       30 * time.Second,
+        ImpersonationTTL:      15 * time.Minute,
+        AuthMode:              "header",
+        AccessTokenTTL:        15 * time.Minute,
+        RefreshCookie:         "library_refresh",
+        CSRFCookie:            "library_csrf",
+        PoWDifficulty:         20,
+        RateLimitRPS:          0,
+        LoginMaxFailures:      5,
+        LoginMaxFailuresPerIP: 20,
+        LoginFailureWindow:    15 * time.Minute,
+        LoginLockoutDuration:  15 * time.Minute,
+        LoginRatePerIP:        30,
+        LoginRatePerUsername:  10,
+        LoginRatePeriod:       time.Minute,
+        PasswordMinLength:     8,
+        PasswordRequireUpper:  true,
+        PasswordRequireLower:  true,
+        PasswordRequireDigit:  true,
+        PasswordHash:          "bcrypt",
+        BcryptCost:            bcrypt.DefaultCost,
+        Argon2MemoryKiB:       64 * 1024,
+        Argon2Time:            3,
+        Argon2Threads:         4,
+        DBMaxConns:            10,
+        DBMinConns:            1,
+        DBMaxConnLifetime:     30 * time.Minute,
+        DBMaxConnIdleTime:     30 * time.Minute,
+        DBHealthCheckPeriod:   1 * time.Minute,
+        DBConnectTimeout:      10 * time.Second,
+        DBConnectMaxWait:      time.Minute,
+        DBStatsInterval:       15 * time.Second,
+        DBSlowQueryThreshold:  500 * time.Millisecond,
+        DBPointTimeout:        2 * time.Second,
+        DBListTimeout:         5 * time.Second,
+        DBReadHealthInterval:  5 * time.Second,
+        DBReadMaxLag:          30 * time.Second,
+        MaxBodyBytes:          1 << 20,
+        ReadTimeout:           15 * time.Second,
+        WriteTimeout:          15 * time.Second,
+        IdleTimeout:           60 * time.Second,
+        ShutdownTimeout:       30 * time.Second,
+        TLSAutocertCacheDir:   "autocert",
+        TLSAutocertHTTPPort:   "80",
+        LogPayloadMaxBytes:    16 << 10,
+        RequestTimeout:        10 * time.Second,
+        RouteTimeouts: map[string]time.Duration{
+            "/admin/books/import":    2 * time.Minute,
+            "/admin/users/import":    2 * time.Minute,
+            "/admin/books/export":    5 * time.Minute,
+            "/admin/bookings/export": 5 * time.Minute,
+            "/admin/books/stream":    5 * time.Minute,
+            "/admin/bookings/stream": 5 * time.Minute,
+            "/bookings/events":       30 * time.Minute,
+        },
+        MaintenanceRetryAfter: 5 * time.Minute,
+        MaintenanceAllow:      []string{"/auth/login", "/auth/refresh", "/announcements"},
+        MaintenanceCacheTTL:   5 * time.Second,
+        LegacyRoutes:          true,
+        SwaggerEnabled:        true,
+        MetadataProvider:      "openlibrary",
+        MetadataTimeout:       5 * time.Second,
+        MetadataRetries:       2,
+        PopularBooksWindow:    30 * 24 * time.Hour,
+        BookListingCacheTTL:   5 * time.Minute,
+        UserStatsCacheTTL:     5 * time.Minute,
+        BookCacheMaxAge:       time.Minute,
+        BorrowDaysMin:         1,
+        BorrowDaysMax:         365,
+        BorrowDaysDefault:     14,
+        OfferHoldDuration:     48 * time.Hour,
+        SchedulerInterval:     time.Minute,
+        NotifyProvider:        "log",
+        NotifyFrom:            "Library <library@localhost>",
+        NotifyLocale:          "en",
+        SMTPPort:              587,
+        DueReminderLead:       24 * time.Hour,
+        PublicBaseURL:         "http://localhost:8080",
+        EmailChangeTTL:        24 * time.Hour,
+        InvitationTTL:         7 * 24 * time.Hour,
+        JobPollInterval:       time.Second,
+        JobTimeout:            time.Minute,
+        JobRetryBackoff:       30 * time.Second,
+        JobMaxBackoff:         time.Hour,
+        JobMaxAttempts:        5,
+        JobConcurrency:        4,
+        EventPublisher:        "log",
+        EventWebhookTimeout:   10 * time.Second,
+        OutboxPollInterval:    time.Second,
+        OutboxRetention:       7 * 24 * time.Hour,
+        FinePerDayCents:       25,
+        FineCurrency:          "usd",
+        OverdueReportWeekday:  "monday",
+        Region:                "us-east-1",
+        CloudWatchLogGroup:    "/aws/ec2/library-api",
+        CloudWatchLogStream:   "library-api",
+        EnableCloudWatch:      true,
+    }
+}
+
+// LoadConfig builds the configuration from defaults, then the YAML file named
+// by CONFIG_FILE (if set), then environment variables, and validates the
+// result. All problems are reported together in a *ConfigError.
+func LoadConfig() (*Config, error) {
+    return loadConfig(os.Getenv)
+}
+
+func loadConfig(getenv func(string) string) (*Config, error) {
+    cfg := DefaultConfig()
+    if path := getenv("CONFIG_FILE"); path != "" {
+        if err := cfg.loadFile(path); err != nil {
+            return nil, err
+        }
+    }
+
+    problems := &ConfigError{}
+    cfg.applyEnv(getenv, problems)
+    cfg.validate(problems)
+    if len(problems.Problems) > 0 {
+        return nil, problems
+    }
+    return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("read config file: %w", err)
+    }
+    dec := yaml.NewDecoder(bytes.NewReader(b))
+    dec.KnownFields(true)
+    if err := dec.Decode(c); err != nil {
+        return fmt.Errorf("parse config file %s: %w", path, err)
+    }
+    return nil
+}
+
+// applyEnv overrides fields from environment variables. Values that fail to
+// parse are recorded in problems rather than aborting the load.
+func (c *Config) applyEnv(getenv func(string) string, problems *ConfigError) {
+    str := func(key string, dst *string) {
+        if v := getenv(key); v != "" {
+            *dst = v
+        }
+    }
+    dur := func(key string, dst *time.Duration) {
+        if v := getenv(key); v != "" {
+            d, err := time.ParseDuration(v)
+            if err != nil {
+                problems.add("%s: invalid duration %q", key, v)
+                return
+            }
+            *dst = d
+        }
+    }
+    boolean := func(key string, dst *bool) {
+        if v := getenv(key); v != "" {
+            b, err := strconv.ParseBool(v)
+            if err != nil {
+                problems.add("%s: invalid boolean %q", key, v)
+                return
+            }
+            *dst = b
+        }
+    }
+    list := func(key string, dst *[]string) {
+        if v := getenv(key); v != "" {
+            *dst = nil
+            for _, item := range strings.Split(v, ",") {
+                *dst = append(*dst, strings.TrimSpace(item))
+            }
+        }
+    }
+    integer := func(key string, set func(int)) {
+        if v := getenv(key); v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil {
+                problems.add("%s: invalid integer %q", key, v)
+                return
+            }
+            set(n)
+        }
+    }
+
+    str("DB_DRIVER", &c.DBDriver)
+    str("DATABASE_URL", &c.DatabaseURL)
+    str("DATABASE_READ_URL", &c.DatabaseReadURL)
+    dur("DB_READ_HEALTH_INTERVAL", &c.DBReadHealthInterval)
+    dur("DB_READ_MAX_LAG", &c.DBReadMaxLag)
+    str("PORT", &c.Port)
+    str("GRPC_PORT", &c.GRPCPort)
+    str("LOG_LEVEL", &c.LogLevel)
+
+    str("JWT_SECRET", &c.JWTSecret)
+    if path := getenv("JWT_SECRET_FILE"); path != "" {
+        secret, err := secrets.ReadSecretFile(path)
+        if err != nil {
+            problems.add("JWT_SECRET_FILE: %v", err)
+        } else {
+            c.JWTSecret = secret
+        }
+    }
+    if v := getenv("JWT_KEYS"); v != "" {
+        keys, err := parseJWTKeys(v)
+        if err != nil {
+            problems.add("JWT_KEYS: %v", err)
+        } else {
+            c.JWTKeys = keys
+        }
+    }
+    str("JWT_SECRETS_MANAGER_ID", &c.JWTSecretsManagerID)
+    dur("JWT_TTL", &c.JWTExpiry)
+    str("JWT_ISSUER", &c.JWTIssuer)
+    str("JWT_AUDIENCE", &c.JWTAudience)
+    dur("JWT_LEEWAY", &c.JWTLeeway)
+    dur("SESSION_CACHE_TTL", &c.SessionCacheTTL)
+    dur("IMPERSONATION_TTL", &c.ImpersonationTTL)
+    str("AUTH_COOKIE", &c.AuthCookie)
+    str("AUTH_MODE", &c.AuthMode)
+    dur("ACCESS_TOKEN_TTL", &c.AccessTokenTTL)
+    str("REFRESH_COOKIE", &c.RefreshCookie)
+    str("CSRF_COOKIE", &c.CSRFCookie)
+    str("REGISTRATION_CHALLENGE", &c.RegistrationChallenge)
+    boolean("REGISTRATION_INVITE_ONLY", &c.RegistrationInviteOnly)
+    str("CHALLENGE_SITE_KEY", &c.ChallengeSiteKey)
+    str("CHALLENGE_SECRET", &c.ChallengeSecret)
+    str("CALENDAR_FEED_SECRET", &c.CalendarFeedSecret)
+    integer("POW_DIFFICULTY", func(n int) { c.PoWDifficulty = n })
+
+    integer("RATE_LIMIT_RPS", func(n int) { c.RateLimitRPS = n })
+
+    integer("LOGIN_MAX_FAILURES", func(n int) { c.LoginMaxFailures = n })
+    integer("LOGIN_MAX_FAILURES_PER_IP", func(n int) { c.LoginMaxFailuresPerIP = n })
+    dur("LOGIN_FAILURE_WINDOW", &c.LoginFailureWindow)
+    dur("LOGIN_LOCKOUT_DURATION", &c.LoginLockoutDuration)
+    integer("LOGIN_RATE_PER_IP", func(n int) { c.LoginRatePerIP = n })
+    integer("LOGIN_RATE_PER_USERNAME", func(n int) { c.LoginRatePerUsername = n })
+    dur("LOGIN_RATE_PERIOD", &c.LoginRatePeriod)
+
+    integer("PASSWORD_MIN_LENGTH", func(n int) { c.PasswordMinLength = n })
+    boolean("PASSWORD_REQUIRE_UPPER", &c.PasswordRequireUpper)
+    boolean("PASSWORD_REQUIRE_LOWER", &c.PasswordRequireLower)
+    boolean("PASSWORD_REQUIRE_DIGIT", &c.PasswordRequireDigit)
+    boolean("PASSWORD_REQUIRE_SYMBOL", &c.PasswordRequireSymbol)
+    str("PASSWORD_DENYLIST_FILE", &c.PasswordDenylistFile)
+    str("PASSWORD_HASH", &c.PasswordHash)
+    integer("BCRYPT_COST", func(n int) { c.BcryptCost = n })
+    integer("ARGON2_MEMORY_KIB", func(n int) { c.Argon2MemoryKiB = n })
+    integer("ARGON2_TIME", func(n int) { c.Argon2Time = n })
+    integer("ARGON2_THREADS", func(n int) { c.Argon2Threads = n })
+    boolean("EMAIL_CHECK_MX", &c.EmailCheckMX)
+
+    integer("DB_MAX_CONNS", func(n int) { c.DBMaxConns = int32(n) })
+    integer("DB_MIN_CONNS", func(n int) { c.DBMinConns = int32(n) })
+    dur("DB_MAX_CONN_LIFETIME", &c.DBMaxConnLifetime)
+    dur("DB_MAX_CONN_IDLE_TIME", &c.DBMaxConnIdleTime)
+    dur("DB_HEALTH_CHECK_PERIOD", &c.DBHealthCheckPeriod)
+    dur("DB_CONNECT_TIMEOUT", &c.DBConnectTimeout)
+    dur("DB_CONNECT_MAX_WAIT", &c.DBConnectMaxWait)
+    str("DB_QUERY_EXEC_MODE", &c.DBQueryExecMode)
+    dur("DB_STATS_INTERVAL", &c.DBStatsInterval)
+    dur("DB_SLOW_QUERY_THRESHOLD", &c.DBSlowQueryThreshold)
+    dur("DB_POINT_TIMEOUT", &c.DBPointTimeout)
+    dur("DB_LIST_TIMEOUT", &c.DBListTimeout)
 
-        // AWS CloudWatch config
-        Region:              getEnv("AWS_REGION", "us-east-1"),
-        CloudWatchLogGroup:  getEnv("CW_LOG_GROUP", "/aws/ec2/library-api"),
-        CloudWatchLogStream: getEnv("CW_LOG_STREAM", "library-api"),
-        EnableCloudWatch:    getEnv("ENABLE_CLOUDWATCH", "true") == "true",
-    }, nil
+    integer("MAX_BODY_BYTES", func(n int) { c.MaxBodyBytes = int64(n) })
+    dur("HTTP_READ_TIMEOUT", &c.ReadTimeout)
+    dur("HTTP_WRITE_TIMEOUT", &c.WriteTimeout)
+    dur("HTTP_IDLE_TIMEOUT", &c.IdleTimeout)
+    dur("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+    str("TLS_CERT_FILE", &c.TLSCertFile)
+    str("TLS_KEY_FILE", &c.TLSKeyFile)
+    list("TLS_AUTOCERT_DOMAINS", &c.TLSAutocertDomains)
+    str("TLS_AUTOCERT_EMAIL", &c.TLSAutocertEmail)
+    str("TLS_AUTOCERT_CACHE_DIR", &c.TLSAutocertCacheDir)
+    str("TLS_AUTOCERT_HTTP_PORT", &c.TLSAutocertHTTPPort)
+    boolean("H2C", &c.H2C)
+    list("TRUSTED_PROXIES", &c.TrustedProxies)
+    boolean("LOG_PAYLOADS", &c.LogPayloads)
+    integer("LOG_PAYLOAD_MAX_BYTES", func(n int) { c.LogPayloadMaxBytes = n })
+    boolean("RESPONSE_ENVELOPE", &c.ResponseEnvelope)
+    dur("REQUEST_TIMEOUT", &c.RequestTimeout)
+    if v := getenv("ROUTE_TIMEOUTS"); v != "" {
+        routes, err := parseRouteTimeouts(v)
+        if err != nil {
+            problems.add("ROUTE_TIMEOUTS: %v", err)
+        }
+        for pattern, d := range routes {
+            if c.RouteTimeouts == nil {
+                c.RouteTimeouts = map[string]time.Duration{}
+            }
+            c.RouteTimeouts[pattern] = d
+        }
+    }
+    boolean("MAINTENANCE_MODE", &c.MaintenanceMode)
+    dur("MAINTENANCE_RETRY_AFTER", &c.MaintenanceRetryAfter)
+    if v := getenv("MAINTENANCE_ALLOW"); v != "" {
+        c.MaintenanceAllow = nil
+        for _, pattern := range strings.Split(v, ",") {
+            c.MaintenanceAllow = append(c.MaintenanceAllow, strings.TrimSpace(pattern))
+        }
+    }
+    dur("MAINTENANCE_CACHE_TTL", &c.MaintenanceCacheTTL)
+
+    boolean("LEGACY_ROUTES", &c.LegacyRoutes)
+    str("LEGACY_ROUTES_SUNSET", &c.LegacyRoutesSunset)
+    boolean("ENABLE_SWAGGER", &c.SwaggerEnabled)
+    str("TENANT_BASE_DOMAIN", &c.TenantBaseDomain)
+
+    str("OIDC_REDIRECT_BASE_URL", &c.OIDCRedirectBaseURL)
+    for _, name := range oidcProviderNames {
+        prefix := "OIDC_" + strings.ToUpper(name) + "_"
+        id, secret, roles := getenv(prefix+"CLIENT_ID"), getenv(prefix+"CLIENT_SECRET"), getenv(prefix+"ROLES")
+        if id == "" && secret == "" && roles == "" {
+            continue
+        }
+        if c.OIDCProviders == nil {
+            c.OIDCProviders = map[string]OIDCProvider{}
+        }
+        p := c.OIDCProviders[name]
+        str(prefix+"CLIENT_ID", &p.ClientID)
+        str(prefix+"CLIENT_SECRET", &p.ClientSecret)
+        if roles != "" {
+            m, err := parseRoleMap(roles)
+            if err != nil {
+                problems.add("%sROLES: %v", prefix, err)
+            }
+            p.Roles = m
+        }
+        c.OIDCProviders[name] = p
+    }
+
+    str("METADATA_PROVIDER", &c.MetadataProvider)
+    dur("METADATA_TIMEOUT", &c.MetadataTimeout)
+    integer("METADATA_RETRIES", func(n int) { c.MetadataRetries = n })
+    str("GOOGLE_BOOKS_API_KEY", &c.GoogleBooksAPIKey)
+
+    dur("POPULAR_BOOKS_WINDOW", &c.PopularBooksWindow)
+    dur("BOOK_LISTING_CACHE_TTL", &c.BookListingCacheTTL)
+    dur("USER_STATS_CACHE_TTL", &c.UserStatsCacheTTL)
+    dur("BOOK_CACHE_MAX_AGE", &c.BookCacheMaxAge)
+
+    integer("BORROW_DAYS_MIN", func(n int) { c.BorrowDaysMin = n })
+    integer("BORROW_DAYS_MAX", func(n int) { c.BorrowDaysMax = n })
+    integer("BORROW_DAYS_DEFAULT", func(n int) { c.BorrowDaysDefault = n })
+
+    dur("RESERVATION_OFFER_HOLD", &c.OfferHoldDuration)
+    dur("SCHEDULER_INTERVAL", &c.SchedulerInterval)
+
+    str("NOTIFY_PROVIDER", &c.NotifyProvider)
+    str("NOTIFY_FROM", &c.NotifyFrom)
+    str("NOTIFY_LOCALE", &c.NotifyLocale)
+    str("NOTIFY_TEMPLATE_DIR", &c.NotifyTemplateDir)
+    str("SMTP_HOST", &c.SMTPHost)
+    integer("SMTP_PORT", func(n int) { c.SMTPPort = n })
+    str("SMTP_USERNAME", &c.SMTPUsername)
+    str("SMTP_PASSWORD", &c.SMTPPassword)
+    dur("DUE_REMINDER_LEAD", &c.DueReminderLead)
+    str("PUBLIC_BASE_URL", &c.PublicBaseURL)
+    dur("EMAIL_CHANGE_TTL", &c.EmailChangeTTL)
+    dur("INVITATION_TTL", &c.InvitationTTL)
+
+    dur("JOB_POLL_INTERVAL", &c.JobPollInterval)
+    dur("JOB_TIMEOUT", &c.JobTimeout)
+    dur("JOB_RETRY_BACKOFF", &c.JobRetryBackoff)
+    dur("JOB_MAX_BACKOFF", &c.JobMaxBackoff)
+    integer("JOB_MAX_ATTEMPTS", func(n int) { c.JobMaxAttempts = n })
+    integer("JOB_CONCURRENCY", func(n int) { c.JobConcurrency = n })
+
+    str("EVENT_PUBLISHER", &c.EventPublisher)
+    str("EVENT_WEBHOOK_URL", &c.EventWebhookURL)
+    str("EVENT_WEBHOOK_SECRET", &c.EventWebhookSecret)
+    dur("EVENT_WEBHOOK_TIMEOUT", &c.EventWebhookTimeout)
+    dur("OUTBOX_POLL_INTERVAL", &c.OutboxPollInterval)
+    dur("OUTBOX_RETENTION", &c.OutboxRetention)
+
+    integer("FINE_PER_DAY_CENTS", func(n int) { c.FinePerDayCents = n })
+    integer("FINE_MAX_CENTS", func(n int) { c.FineMaxCents = n })
+    integer("FINE_GRACE_DAYS", func(n int) { c.FineGraceDays = n })
+    str("FINE_CURRENCY", &c.FineCurrency)
+    if v := getenv("OVERDUE_REPORT_RECIPIENTS"); v != "" {
+        c.OverdueReportRecipients = nil
+        for _, addr := range strings.Split(v, ",") {
+            c.OverdueReportRecipients = append(c.OverdueReportRecipients, strings.TrimSpace(addr))
+        }
+    }
+    str("OVERDUE_REPORT_WEEKDAY", &c.OverdueReportWeekday)
+
+    str("PAYMENT_PROVIDER", &c.PaymentProvider)
+    str("STRIPE_SECRET_KEY", &c.StripeSecretKey)
+    str("STRIPE_WEBHOOK_SECRET", &c.StripeWebhookSecret)
+
+    str("AWS_REGION", &c.Region)
+    str("CW_LOG_GROUP", &c.CloudWatchLogGroup)
+    str("CW_LOG_STREAM", &c.CloudWatchLogStream)
+    boolean("ENABLE_CLOUDWATCH", &c.EnableCloudWatch)
+}
+
+func (c *Config) validateOIDC(problems *ConfigError) {
+    if len(c.OIDCProviders) == 0 {
+        return
+    }
+    for name, p := range c.OIDCProviders {
+        if !slices.Contains(oidcProviderNames, name) {
+            problems.add("OIDC provider %q is not supported (use %s)", name, strings.Join(oidcProviderNames, " or "))
+            continue
+        }
+        prefix := "OIDC_" + strings.ToUpper(name) + "_"
+        if p.ClientID == "" || p.ClientSecret == "" {
+            problems.add("%sCLIENT_ID and %sCLIENT_SECRET are both required", prefix, prefix)
+        }
+        if len(p.Roles) > 0 && !slices.Contains(oidcGroupProviders, name) {
+            problems.add("%sROLES can't be used: %s doesn't report groups; roles can only follow groups from %s", prefix, name, strings.Join(oidcGroupProviders, ", "))
+        }
+        for group, role := range p.Roles {
+            if !model.NormalizeRole(string(role)).Valid() {
+                problems.add("%sROLES maps %q to %q; roles are %s", prefix, group, role, model.RoleNames())
+            }
+        }
+    }
+    u, err := url.Parse(c.OIDCRedirectBaseURL)
+    if c.OIDCRedirectBaseURL == "" || err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+        problems.add("OIDC_REDIRECT_BASE_URL must be the API's public URL, like https://library.example.com (got %q)", c.OIDCRedirectBaseURL)
+    }
 }
 
-func getEnv(key, defaultValue string) string {
-    if value := os.Getenv(key); value != "" {
-        return value
+func (c *Config) validateNotify(problems *ConfigError) {
+    switch c.NotifyProvider {
+    case "log":
+    case "smtp":
+        if c.SMTPHost == "" {
+            problems.add("SMTP_HOST is required when NOTIFY_PROVIDER is smtp")
+        }
+        if c.SMTPPort < 1 || c.SMTPPort > 65535 {
+            problems.add("SMTP_PORT must be between 1 and 65535")
+        }
+    case "ses":
+        if c.SMTPUsername == "" || c.SMTPPassword == "" {
+            problems.add("SMTP_USERNAME and SMTP_PASSWORD (SES SMTP credentials) are required when NOTIFY_PROVIDER is ses")
+        }
+    default:
+        problems.add("NOTIFY_PROVIDER must be log, smtp or ses (got %q)", c.NotifyProvider)
     }
-    return defaultValue
-}
\ No newline at end of file
+    if _, err := mail.ParseAddress(c.NotifyFrom); err != nil {
+        problems.add("NOTIFY_FROM must be an email address like \"Library <library@example.com>\" (got %q)", c.NotifyFrom)
+    }
+    if c.NotifyLocale == "" {
+        problems.add("NOTIFY_LOCALE is required")
+    }
+    if u, err := url.Parse(c.PublicBaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+        problems.add("PUBLIC_BASE_URL must be the API's public URL, like https://library.example.com (got %q)", c.PublicBaseURL)
+    }
+    if c.EmailChangeTTL <= 0 {
+        problems.add("EMAIL_CHANGE_TTL must be positive")
+    }
+    if c.InvitationTTL <= 0 {
+        problems.add("INVITATION_TTL must be positive")
+    }
+}
+
+func (c *Config) validateChallenge(problems *ConfigError) {
+    switch c.RegistrationChallenge {
+    case "":
+    case "hcaptcha", "turnstile":
+        if c.ChallengeSiteKey == "" || c.ChallengeSecret == "" {
+            problems.add("CHALLENGE_SITE_KEY and CHALLENGE_SECRET are required when REGISTRATION_CHALLENGE is %s", c.RegistrationChallenge)
+        }
+    case "pow":
+        if len(c.ChallengeSecret) < minJWTSecretLen {
+            problems.add("CHALLENGE_SECRET must be at least %d characters when REGISTRATION_CHALLENGE is pow", minJWTSecretLen)
+        }
+        if c.PoWDifficulty < 8 || c.PoWDifficulty > 32 {
+            problems.add("POW_DIFFICULTY must be between 8 and 32 (got %d)", c.PoWDifficulty)
+        }
+    default:
+        problems.add("REGISTRATION_CHALLENGE must be empty, hcaptcha, turnstile or pow (got %q)", c.RegistrationChallenge)
+    }
+}
+
+func (c *Config) validatePayments(problems *ConfigError) {
+    switch c.PaymentProvider {
+    case "":
+    case "stripe":
+        // Only Stripe's test mode is supported so far.
+        if !strings.HasPrefix(c.StripeSecretKey, "sk_test_") {
+            problems.add("STRIPE_SECRET_KEY must be a test-mode secret key (sk_test_...) when PAYMENT_PROVIDER is stripe")
+        }
+        if c.StripeWebhookSecret == "" {
+            problems.add("STRIPE_WEBHOOK_SECRET is required when PAYMENT_PROVIDER is stripe")
+        }
+    default:
+        problems.add("PAYMENT_PROVIDER must be empty or stripe (got %q)", c.PaymentProvider)
+    }
+}
+
+// validateAuthMode checks the cookies of AUTH_MODE=cookie.
+func (c *Config) validateAuthMode(problems *ConfigError) {
+    switch c.AuthMode {
+    case "header":
+        return
+    case "cookie":
+    default:
+        problems.add("AUTH_MODE must be header or cookie (got %q)", c.AuthMode)
+        return
+    }
+    if c.AccessTokenTTL <= 0 {
+        problems.add("ACCESS_TOKEN_TTL must be positive")
+    }
+    for name, v := range map[string]string{"REFRESH_COOKIE": c.RefreshCookie, "CSRF_COOKIE": c.CSRFCookie} {
+        if v == "" || strings.ContainsAny(v, " \t\";,=") {
+            problems.add("%s must be a valid cookie name", name)
+        }
+    }
+    if c.RefreshCookie == c.CSRFCookie || (c.AuthCookie != "" && (c.AuthCookie == c.RefreshCookie || c.AuthCookie == c.CSRFCookie)) {
+        problems.add("AUTH_COOKIE, REFRESH_COOKIE and CSRF_COOKIE must be different cookies")
+    }
+}
+
+// maxJWTLeeway bounds JWT_LEEWAY: clocks further apart than this are a
+// problem to fix, not to tolerate, and a long leeway keeps expired tokens
+// working.
+const maxJWTLeeway = 5 * time.Minute
+
+// minJWTSecretLen keeps obviously weak HMAC secrets out of production.
+const minJWTSecretLen = 32
+
+// maxJobConcurrency bounds JOB_CONCURRENCY: each running job may hold a
+// database connection, besides an outgoing one.
+const maxJobConcurrency = 64
+
+func (c *Config) validate(problems *ConfigError) {
+    switch c.DBDriver {
+    case "postgres":
+        if c.DatabaseURL == "" {
+            problems.add("DATABASE_URL is required")
+        }
+    case "memory":
+    default:
+        problems.add("DB_DRIVER must be postgres or memory (got %q)", c.DBDriver)
+    }
+    if c.Port == "" {
+        problems.add("PORT is required")
+    }
+    if c.GRPCPort == "" {
+        problems.add("GRPC_PORT is required (0 disables gRPC)")
+    } else if c.GRPCPort == c.Port {
+        problems.add("GRPC_PORT must differ from PORT")
+    }
+    switch strings.ToLower(c.LogLevel) {
+    case "debug", "info", "warn", "error":
+    default:
+        problems.add("LOG_LEVEL must be one of debug, info, warn, error (got %q)", c.LogLevel)
+    }
+
+    // With Secrets Manager the keys only arrive in ResolveSecrets.
+    if c.JWTSecretsManagerID == "" {
+        c.validateJWTKeys(problems)
+    }
+    if c.JWTExpiry <= 0 {
+        problems.add("JWT_TTL must be positive")
+    }
+    if c.JWTLeeway < 0 || c.JWTLeeway > maxJWTLeeway {
+        problems.add("JWT_LEEWAY must be between 0 and %s", maxJWTLeeway)
+    }
+    if strings.ContainsAny(c.AuthCookie, " \t\";,=") {
+        problems.add("AUTH_COOKIE must be a valid cookie name")
+    }
+    c.validateAuthMode(problems)
+    c.validateChallenge(problems)
+    if c.CalendarFeedSecret != "" && len(c.CalendarFeedSecret) < minJWTSecretLen {
+        problems.add("CALENDAR_FEED_SECRET must be at least %d characters", minJWTSecretLen)
+    }
+
+    if c.RateLimitRPS < 0 {
+        problems.add("RATE_LIMIT_RPS must not be negative")
+    }
+    if c.LoginMaxFailures < 0 || c.LoginMaxFailuresPerIP < 0 {
+        problems.add("LOGIN_MAX_FAILURES and LOGIN_MAX_FAILURES_PER_IP must not be negative")
+    }
+    if c.LoginRatePerIP < 0 || c.LoginRatePerUsername < 0 {
+        problems.add("LOGIN_RATE_PER_IP and LOGIN_RATE_PER_USERNAME must not be negative")
+    }
+    // Request validation already rejects passwords shorter than 8 or longer
+    // than bcrypt's 72-byte limit.
+    if c.PasswordMinLength < 8 || c.PasswordMinLength > 72 {
+        problems.add("PASSWORD_MIN_LENGTH must be between 8 and 72")
+    }
+    if c.PasswordHash != "bcrypt" && c.PasswordHash != "argon2id" {
+        problems.add("PASSWORD_HASH must be bcrypt or argon2id (got %q)", c.PasswordHash)
+    }
+    if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+        problems.add("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+    }
+    // Argon2 needs at least 8 KiB of memory per lane.
+    if c.Argon2Threads < 1 || c.Argon2Threads > 255 || c.Argon2Time < 1 ||
+        c.Argon2MemoryKiB < 8*c.Argon2Threads || c.Argon2MemoryKiB > 4*1024*1024 {
+        problems.add("ARGON2_THREADS must be 1-255, ARGON2_TIME at least 1, and ARGON2_MEMORY_KIB between 8 per thread and 4194304")
+    }
+
+    c.validateTLS(problems)
+
+    if c.MaxBodyBytes < 1 {
+        problems.add("MAX_BODY_BYTES must be positive")
+    }
+    if c.LogPayloads && c.LogPayloadMaxBytes < 1 {
+        problems.add("LOG_PAYLOAD_MAX_BYTES must be positive")
+    }
+    if c.RequestTimeout <= 0 {
+        problems.add("REQUEST_TIMEOUT must be positive")
+    }
+    patterns := make([]string, 0, len(c.RouteTimeouts))
+    for pattern := range c.RouteTimeouts {
+        patterns = append(patterns, pattern)
+    }
+    sort.Strings(patterns)
+    for _, pattern := range patterns {
+        d := c.RouteTimeouts[pattern]
+        if _, err := path.Match(pattern, ""); err != nil || !strings.HasPrefix(pattern, "/") {
+            problems.add("route timeout pattern %q must be an absolute path glob", pattern)
+        }
+        if d <= 0 {
+            problems.add("route timeout for %q must be positive", pattern)
+        }
+    }
+    for _, pattern := range c.MaintenanceAllow {
+        if _, err := path.Match(pattern, ""); err != nil || !strings.HasPrefix(pattern, "/") {
+            problems.add("MAINTENANCE_ALLOW: %q must be an absolute path glob", pattern)
+        }
+    }
+    if c.LegacyRoutesSunset != "" {
+        if _, err := time.Parse(time.DateOnly, c.LegacyRoutesSunset); err != nil {
+            problems.add("LEGACY_ROUTES_SUNSET must be a date like 2027-01-31 (got %q)", c.LegacyRoutesSunset)
+        }
+    }
+    if strings.ContainsAny(c.TenantBaseDomain, "/: ") || strings.HasPrefix(c.TenantBaseDomain, ".") {
+        problems.add("TENANT_BASE_DOMAIN must be a bare domain like library.example.com (got %q)", c.TenantBaseDomain)
+    }
+
+    c.validateOIDC(problems)
+
+    switch c.MetadataProvider {
+    case "openlibrary", "googlebooks":
+    default:
+        problems.add("METADATA_PROVIDER must be openlibrary or googlebooks (got %q)", c.MetadataProvider)
+    }
+    if c.MetadataRetries < 0 {
+        problems.add("METADATA_RETRIES must not be negative")
+    }
+
+    c.validateNotify(problems)
+
+    if c.JobMaxAttempts < 1 {
+        problems.add("JOB_MAX_ATTEMPTS must be at least 1")
+    }
+    if c.JobConcurrency < 1 || c.JobConcurrency > maxJobConcurrency {
+        problems.add("JOB_CONCURRENCY must be between 1 and %d", maxJobConcurrency)
+    }
+    switch c.EventPublisher {
+    case "log":
+    case "webhook":
+        if u, err := url.Parse(c.EventWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            problems.add("EVENT_WEBHOOK_URL must be an http(s) URL when EVENT_PUBLISHER is webhook (got %q)", c.EventWebhookURL)
+        }
+    default:
+        problems.add("EVENT_PUBLISHER must be log or webhook (got %q)", c.EventPublisher)
+    }
+
+    if err := c.LoanDuration().Validate(); err != nil {
+        problems.add("BORROW_DAYS_MIN, BORROW_DAYS_MAX and BORROW_DAYS_DEFAULT: %v", err)
+    }
+
+    if c.FineGraceDays < 0 || c.FinePerDayCents < 0 || c.FineMaxCents < 0 {
+        problems.add("FINE_GRACE_DAYS, FINE_PER_DAY_CENTS and FINE_MAX_CENTS must not be negative")
+    }
+    if len(c.FineCurrency) != 3 || strings.ToLower(c.FineCurrency) != c.FineCurrency {
+        problems.add("FINE_CURRENCY must be a lowercase ISO 4217 code such as usd (got %q)", c.FineCurrency)
+    }
+    for _, addr := range c.OverdueReportRecipients {
+        if _, err := mail.ParseAddress(addr); err != nil {
+            problems.add("OVERDUE_REPORT_RECIPIENTS: %q is not an email address", addr)
+        }
+    }
+    if _, ok := parseWeekday(c.OverdueReportWeekday); !ok {
+        problems.add("OVERDUE_REPORT_WEEKDAY must be a day of the week such as monday (got %q)", c.OverdueReportWeekday)
+    }
+    c.validatePayments(problems)
+
+    if c.DBMaxConns < 1 {
+        problems.add("DB_MAX_CONNS must be at least 1")
+    }
+    if c.DBMinConns < 0 || c.DBMinConns > c.DBMaxConns {
+        problems.add("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS")
+    }
+    if c.BookCacheMaxAge < 0 {
+        problems.add("BOOK_CACHE_MAX_AGE must not be negative")
+    }
+    if c.DBReadMaxLag < 0 {
+        problems.add("DB_READ_MAX_LAG must not be negative")
+    }
+    if c.DBConnectMaxWait < 0 {
+        problems.add("DB_CONNECT_MAX_WAIT must not be negative")
+    }
+    if c.DBSlowQueryThreshold < 0 {
+        problems.add("DB_SLOW_QUERY_THRESHOLD must not be negative")
+    }
+    if c.DBPointTimeout < 0 {
+        problems.add("DB_POINT_TIMEOUT must not be negative")
+    }
+    if c.DBListTimeout < 0 {
+        problems.add("DB_LIST_TIMEOUT must not be negative")
+    }
+    if _, ok := queryExecModes[c.DBQueryExecMode]; c.DBQueryExecMode != "" && !ok {
+        problems.add("DB_QUERY_EXEC_MODE must be cache_statement, cache_describe, describe_exec, exec or simple_protocol (got %q)", c.DBQueryExecMode)
+    }
+    for _, d := range []struct {
+        name  string
+        value time.Duration
+    }{
+        {"SESSION_CACHE_TTL", c.SessionCacheTTL},
+        {"IMPERSONATION_TTL", c.ImpersonationTTL},
+        {"MAINTENANCE_RETRY_AFTER", c.MaintenanceRetryAfter},
+        {"MAINTENANCE_CACHE_TTL", c.MaintenanceCacheTTL},
+        {"LOGIN_FAILURE_WINDOW", c.LoginFailureWindow},
+        {"LOGIN_LOCKOUT_DURATION", c.LoginLockoutDuration},
+        {"LOGIN_RATE_PERIOD", c.LoginRatePeriod},
+        {"DB_MAX_CONN_LIFETIME", c.DBMaxConnLifetime},
+        {"DB_MAX_CONN_IDLE_TIME", c.DBMaxConnIdleTime},
+        {"DB_HEALTH_CHECK_PERIOD", c.DBHealthCheckPeriod},
+        {"DB_CONNECT_TIMEOUT", c.DBConnectTimeout},
+        {"DB_STATS_INTERVAL", c.DBStatsInterval},
+        {"DB_READ_HEALTH_INTERVAL", c.DBReadHealthInterval},
+        {"HTTP_READ_TIMEOUT", c.ReadTimeout},
+        {"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+        {"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+        {"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+        {"METADATA_TIMEOUT", c.MetadataTimeout},
+        {"POPULAR_BOOKS_WINDOW", c.PopularBooksWindow},
+        {"BOOK_LISTING_CACHE_TTL", c.BookListingCacheTTL},
+        {"USER_STATS_CACHE_TTL", c.UserStatsCacheTTL},
+        {"RESERVATION_OFFER_HOLD", c.OfferHoldDuration},
+        {"SCHEDULER_INTERVAL", c.SchedulerInterval},
+        {"DUE_REMINDER_LEAD", c.DueReminderLead},
+        {"JOB_POLL_INTERVAL", c.JobPollInterval},
+        {"JOB_TIMEOUT", c.JobTimeout},
+        {"JOB_RETRY_BACKOFF", c.JobRetryBackoff},
+        {"JOB_MAX_BACKOFF", c.JobMaxBackoff},
+        {"EVENT_WEBHOOK_TIMEOUT", c.EventWebhookTimeout},
+        {"OUTBOX_POLL_INTERVAL", c.OutboxPollInterval},
+        {"OUTBOX_RETENTION", c.OutboxRetention},
+    } {
+        if d.value <= 0 {
+            problems.add("%s must be positive", d.name)
+        }
+    }
+}
+
+func (c *Config) validateTLS(problems *ConfigError) {
+    if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+        problems.add("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+    }
+    if len(c.TLSAutocertDomains) > 0 {
+        if c.TLSCertFile != "" {
+            problems.add("TLS_AUTOCERT_DOMAINS can't be combined with TLS_CERT_FILE")
+        }
+        for _, domain := range c.TLSAutocertDomains {
+            if domain == "" || strings.ContainsAny(domain, "/: *") {
+                problems.add("TLS_AUTOCERT_DOMAINS: %q must be a bare domain like library.example.com", domain)
+            }
+        }
+        if c.TLSAutocertCacheDir == "" {
+            problems.add("TLS_AUTOCERT_CACHE_DIR is required with TLS_AUTOCERT_DOMAINS")
+        }
+        if c.TLSAutocertHTTPPort == "" || c.TLSAutocertHTTPPort == c.Port {
+            problems.add("TLS_AUTOCERT_HTTP_PORT is required with TLS_AUTOCERT_DOMAINS and must differ from PORT")
+        }
+    }
+    if c.H2C && c.TLSEnabled() {
+        problems.add("H2C is for plain HTTP behind a proxy; HTTPS already serves HTTP/2")
+    }
+    if _, err := ParseTrustedProxies(c.TrustedProxies); err != nil {
+        problems.add("TRUSTED_PROXIES: %v", err)
+    }
+}
+
+// TLSEnabled reports whether the API serves HTTPS itself.
+func (c *Config) TLSEnabled() bool {
+    return c.TLSCertFile != "" || len(c.TLSAutocertDomains) > 0
+}
+
+// ParseTrustedProxies reads TRUSTED_PROXIES entries, each an IP address or
+// a CIDR such as 10.0.0.0/8.
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+    prefixes := make([]netip.Prefix, 0, len(entries))
+    for _, entry := range entries {
+        if strings.Contains(entry, "/") {
+            p, err := netip.ParsePrefix(entry)
+            if err != nil {
+                return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+            }
+            prefixes = append(prefixes, p.Masked())
+            continue
+        }
+        addr, err := netip.ParseAddr(entry)
+        if err != nil {
+            return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+        }
+        addr = addr.Unmap()
+        prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+    }
+    return prefixes, nil
+}
+
+// GRPCEnabled reports whether the gRPC server should be started.
+func (c *Config) GRPCEnabled() bool {
+    return c.GRPCPort != "0"
+}
+
+func (c *Config) validateJWTKeys(problems *ConfigError) {
+    keys := c.SigningKeys()
+    if len(keys) == 0 {
+        problems.add("JWT_SECRET, JWT_SECRET_FILE, JWT_KEYS or JWT_SECRETS_MANAGER_ID is required")
+        return
+    }
+    seen := make(map[string]bool, len(keys))
+    for _, k := range keys {
+        if k.ID == "" {
+            problems.add("JWT key IDs must not be empty")
+            continue
+        }
+        if seen[k.ID] {
+            problems.add("JWT key %q is listed more than once", k.ID)
+        }
+        seen[k.ID] = true
+        if len(k.Secret) < minJWTSecretLen {
+            problems.add("JWT key %q must be at least %d characters", k.ID, minJWTSecretLen)
+        }
+    }
+}
+
+// ReportWeekday is the day of the week the overdue report is emailed.
+func (c *Config) ReportWeekday() time.Weekday {
+    day, _ := parseWeekday(c.OverdueReportWeekday)
+    return day
+}
+
+// parseWeekday accepts a day name in any case, such as "Monday".
+func parseWeekday(v string) (time.Weekday, bool) {
+    for d := time.Sunday; d <= time.Saturday; d++ {
+        if strings.EqualFold(v, d.String()) {
+            return d, true
+        }
+    }
+    return time.Sunday, false
+}
+
+// parseJWTKeys parses "kid:secret,kid:secret"; the first entry is active.
+func parseJWTKeys(v string) ([]JWTKey, error) {
+    var keys []JWTKey
+    for _, part := range strings.Split(v, ",") {
+        id, secret, ok := strings.Cut(strings.TrimSpace(part), ":")
+        if !ok {
+            return nil, fmt.Errorf("expected kid:secret, got %q", part)
+        }
+        keys = append(keys, JWTKey{ID: id, Secret: secret})
+    }
+    return keys, nil
+}
+
+// parseRoleMap reads OIDC_<PROVIDER>_ROLES: comma-separated group=role
+// pairs such as "acme/librarians=admin,acme=user". Groups and roles are
+// lower-cased.
+func parseRoleMap(v string) (map[string]model.Role, error) {
+    roles := map[string]model.Role{}
+    for _, part := range strings.Split(v, ",") {
+        group, role, ok := strings.Cut(strings.TrimSpace(part), "=")
+        if !ok || group == "" {
+            return nil, fmt.Errorf("expected group=role, got %q", part)
+        }
+        roles[strings.ToLower(strings.TrimSpace(group))] = model.NormalizeRole(role)
+    }
+    return roles, nil
+}
+
+// parseRouteTimeouts reads ROUTE_TIMEOUTS: comma-separated path=duration
+// pairs such as "/admin/books/import=5m,/admin/books/*/enrich=30s".
+func parseRouteTimeouts(v string) (map[string]time.Duration, error) {
+    routes := map[string]time.Duration{}
+    for _, part := range strings.Split(v, ",") {
+        pattern, budget, ok := strings.Cut(strings.TrimSpace(part), "=")
+        if !ok {
+            return nil, fmt.Errorf("expected path=duration, got %q", part)
+        }
+        d, err := time.ParseDuration(budget)
+        if err != nil {
+            return nil, fmt.Errorf("invalid duration %q for %s", budget, pattern)
+        }
+        routes[pattern] = d
+    }
+    return routes, nil
+}
+
+// ResolveSecrets fetches the JWT key set from AWS Secrets Manager when
+// JWTSecretsManagerID is set. It is a no-op otherwise.
+func (c *Config) ResolveSecrets(ctx context.Context) error {
+    if c.JWTSecretsManagerID == "" {
+        return nil
+    }
+    active, keys, err := secrets.FetchJWTKeys(ctx, c.Region, c.JWTSecretsManagerID)
+    if err != nil {
+        return fmt.Errorf("load JWT keys from Secrets Manager: %w", err)
+    }
+
+    c.JWTKeys = []JWTKey{{ID: active, Secret: keys[active]}}
+    ids := make([]string, 0, len(keys))
+    for id := range keys {
+        if id != active {
+            ids = append(ids, id)
+        }
+    }
+    sort.Strings(ids)
+    for _, id := range ids {
+        c.JWTKeys = append(c.JWTKeys, JWTKey{ID: id, Secret: keys[id]})
+    }
+
+    problems := &ConfigError{}
+    c.validateJWTKeys(problems)
+    if len(problems.Problems) > 0 {
+        return problems
+    }
+    return nil
+}
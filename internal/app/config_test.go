@@ -0,0 +1,720 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestLoadConfig_FileThenEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+database_url: postgres://file
+jwt_secret: `+testSecret+`
+jwt_expiry: 2h
+db_max_conns: 20
+read_timeout: 5s
+`), 0o600))
+
+	cfg, err := loadConfig(envMap(map[string]string{
+		"CONFIG_FILE":  path,
+		"DATABASE_URL": "postgres://env",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "postgres://env", cfg.DatabaseURL)
+	require.Equal(t, 2*time.Hour, cfg.JWTExpiry)
+	require.Equal(t, int32(20), cfg.DBMaxConns)
+	require.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	require.Equal(t, 15*time.Second, cfg.WriteTimeout)
+}
+
+func TestLoadConfig_AggregatesProblems(t *testing.T) {
+	_, err := loadConfig(envMap(map[string]string{
+		"JWT_SECRET":             "short",
+		"JWT_TTL":                "forever",
+		"DB_MAX_CONNS":           "many",
+		"PASSWORD_MIN_LENGTH":    "4",
+		"PASSWORD_REQUIRE_UPPER": "sometimes",
+	}))
+
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.ElementsMatch(t, []string{
+		`JWT_TTL: invalid duration "forever"`,
+		`DB_MAX_CONNS: invalid integer "many"`,
+		`PASSWORD_REQUIRE_UPPER: invalid boolean "sometimes"`,
+		"PASSWORD_MIN_LENGTH must be between 8 and 72",
+		"DATABASE_URL is required",
+		`JWT key "default" must be at least 32 characters`,
+	}, cfgErr.Problems)
+}
+
+func TestLoadConfig_SessionCacheTTL(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"SESSION_CACHE_TTL": "5s",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, cfg.SessionCacheTTL)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"SESSION_CACHE_TTL": "0s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "SESSION_CACHE_TTL must be positive")
+}
+
+func TestLoadConfig_BookListings(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 30*24*time.Hour, cfg.PopularBooksWindow)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":           "postgres://env",
+		"JWT_SECRET":             testSecret,
+		"POPULAR_BOOKS_WINDOW":   "168h",
+		"BOOK_LISTING_CACHE_TTL": "1m",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 7*24*time.Hour, cfg.PopularBooksWindow)
+	require.Equal(t, time.Minute, cfg.BookListingCacheTTL)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":         "postgres://env",
+		"JWT_SECRET":           testSecret,
+		"POPULAR_BOOKS_WINDOW": "0s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "POPULAR_BOOKS_WINDOW must be positive")
+}
+
+func TestLoadConfig_Waitlist(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 48*time.Hour, cfg.OfferHoldDuration)
+	require.Equal(t, time.Minute, cfg.SchedulerInterval)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":           "postgres://env",
+		"JWT_SECRET":             testSecret,
+		"RESERVATION_OFFER_HOLD": "24h",
+		"SCHEDULER_INTERVAL":     "30s",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, cfg.OfferHoldDuration)
+	require.Equal(t, 30*time.Second, cfg.SchedulerInterval)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":       "postgres://env",
+		"JWT_SECRET":         testSecret,
+		"SCHEDULER_INTERVAL": "0s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "SCHEDULER_INTERVAL must be positive")
+}
+
+func TestLoadConfig_Notify(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "log", cfg.NotifyProvider)
+	require.Equal(t, 587, cfg.SMTPPort)
+	require.Equal(t, 24*time.Hour, cfg.DueReminderLead)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"NOTIFY_PROVIDER":   "smtp",
+		"NOTIFY_FROM":       "Library <library@example.com>",
+		"SMTP_HOST":         "smtp.example.com",
+		"SMTP_PORT":         "2525",
+		"DUE_REMINDER_LEAD": "48h",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "smtp.example.com", cfg.SMTPHost)
+	require.Equal(t, 2525, cfg.SMTPPort)
+	require.Equal(t, 48*time.Hour, cfg.DueReminderLead)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":    "postgres://env",
+		"JWT_SECRET":      testSecret,
+		"NOTIFY_PROVIDER": "ses",
+		"NOTIFY_FROM":     "not an address",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "SMTP_USERNAME and SMTP_PASSWORD (SES SMTP credentials) are required when NOTIFY_PROVIDER is ses")
+	require.Contains(t, cfgErr.Problems, `NOTIFY_FROM must be an email address like "Library <library@example.com>" (got "not an address")`)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":    "postgres://env",
+		"JWT_SECRET":      testSecret,
+		"NOTIFY_PROVIDER": "smtp",
+	}))
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "SMTP_HOST is required when NOTIFY_PROVIDER is smtp")
+}
+
+func TestLoadConfig_JobQueue(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"JOB_MAX_ATTEMPTS":  "8",
+		"JOB_RETRY_BACKOFF": "1m",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 8, cfg.JobMaxAttempts)
+	require.Equal(t, time.Minute, cfg.JobRetryBackoff)
+	require.Equal(t, time.Hour, cfg.JobMaxBackoff)
+	require.Equal(t, 4, cfg.JobConcurrency)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"JOB_MAX_ATTEMPTS":  "0",
+		"JOB_POLL_INTERVAL": "0s",
+		"JOB_CONCURRENCY":   "0",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "JOB_MAX_ATTEMPTS must be at least 1")
+	require.Contains(t, cfgErr.Problems, "JOB_POLL_INTERVAL must be positive")
+	require.Contains(t, cfgErr.Problems, "JOB_CONCURRENCY must be between 1 and 64")
+}
+
+func TestLoadConfig_DBPool(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":          "postgres://env",
+		"JWT_SECRET":            testSecret,
+		"DB_MAX_CONNS":          "40",
+		"DB_MAX_CONN_IDLE_TIME": "5m",
+		"DB_QUERY_EXEC_MODE":    "exec",
+		"DATABASE_READ_URL":     "postgres://replica",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "postgres://replica", cfg.DatabaseReadURL)
+	require.Equal(t, 5*time.Second, cfg.DBReadHealthInterval)
+	require.Equal(t, 30*time.Second, cfg.DBReadMaxLag)
+	require.Equal(t, int32(40), cfg.DBMaxConns)
+	require.Equal(t, 5*time.Minute, cfg.DBMaxConnIdleTime)
+	require.Equal(t, "exec", cfg.DBQueryExecMode)
+	require.Equal(t, 15*time.Second, cfg.DBStatsInterval)
+	require.Equal(t, time.Minute, cfg.DBConnectMaxWait)
+	require.Equal(t, 500*time.Millisecond, cfg.DBSlowQueryThreshold)
+	require.Equal(t, 2*time.Second, cfg.DBPointTimeout)
+	require.Equal(t, 5*time.Second, cfg.DBListTimeout)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":            "postgres://env",
+		"JWT_SECRET":              testSecret,
+		"DB_QUERY_EXEC_MODE":      "prepare",
+		"DB_STATS_INTERVAL":       "0s",
+		"DB_READ_MAX_LAG":         "-1s",
+		"DB_CONNECT_MAX_WAIT":     "-5s",
+		"DB_SLOW_QUERY_THRESHOLD": "-1ms",
+		"DB_LIST_TIMEOUT":         "-1s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `DB_QUERY_EXEC_MODE must be cache_statement, cache_describe, describe_exec, exec or simple_protocol (got "prepare")`)
+	require.Contains(t, cfgErr.Problems, "DB_STATS_INTERVAL must be positive")
+	require.Contains(t, cfgErr.Problems, "DB_READ_MAX_LAG must not be negative")
+	require.Contains(t, cfgErr.Problems, "DB_CONNECT_MAX_WAIT must not be negative")
+	require.Contains(t, cfgErr.Problems, "DB_SLOW_QUERY_THRESHOLD must not be negative")
+	require.Contains(t, cfgErr.Problems, "DB_LIST_TIMEOUT must not be negative")
+}
+
+func TestLoadConfig_BookCacheMaxAge(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, cfg.BookCacheMaxAge)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":       "postgres://env",
+		"JWT_SECRET":         testSecret,
+		"BOOK_CACHE_MAX_AGE": "-1s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "BOOK_CACHE_MAX_AGE must not be negative")
+}
+
+func TestLoadConfig_EventPublisher(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"EVENT_PUBLISHER":   "webhook",
+		"EVENT_WEBHOOK_URL": "https://hooks.example.com/library",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "https://hooks.example.com/library", cfg.EventWebhookURL)
+	require.Equal(t, 7*24*time.Hour, cfg.OutboxRetention)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":    "postgres://env",
+		"JWT_SECRET":      testSecret,
+		"EVENT_PUBLISHER": "webhook",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `EVENT_WEBHOOK_URL must be an http(s) URL when EVENT_PUBLISHER is webhook (got "")`)
+}
+
+func TestLoadConfig_OverdueReport(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":              "postgres://env",
+		"JWT_SECRET":                testSecret,
+		"OVERDUE_REPORT_RECIPIENTS": "desk@example.com, Head Librarian <head@example.com>",
+		"OVERDUE_REPORT_WEEKDAY":    "Friday",
+		"FINE_MAX_CENTS":            "500",
+		"FINE_GRACE_DAYS":           "2",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"desk@example.com", "Head Librarian <head@example.com>"}, cfg.OverdueReportRecipients)
+	require.Equal(t, time.Friday, cfg.ReportWeekday())
+	require.Equal(t, 25, cfg.FinePerDayCents)
+	require.Equal(t, 500, cfg.FineMaxCents)
+	require.Equal(t, 2, cfg.FineGraceDays)
+	require.Equal(t, "usd", cfg.FineCurrency)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":              "postgres://env",
+		"JWT_SECRET":                testSecret,
+		"OVERDUE_REPORT_RECIPIENTS": "desk",
+		"OVERDUE_REPORT_WEEKDAY":    "someday",
+		"FINE_CURRENCY":             "USD",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `OVERDUE_REPORT_RECIPIENTS: "desk" is not an email address`)
+	require.Contains(t, cfgErr.Problems, `OVERDUE_REPORT_WEEKDAY must be a day of the week such as monday (got "someday")`)
+	require.Contains(t, cfgErr.Problems, `FINE_CURRENCY must be a lowercase ISO 4217 code such as usd (got "USD")`)
+}
+
+func TestLoadConfig_PasswordHash(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"PASSWORD_HASH":     "argon2id",
+		"ARGON2_MEMORY_KIB": "19456",
+		"ARGON2_TIME":       "2",
+		"ARGON2_THREADS":    "1",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "argon2id", cfg.PasswordHash)
+	require.Equal(t, 10, cfg.BcryptCost)
+	require.Equal(t, 19456, cfg.Argon2MemoryKiB)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":   "postgres://env",
+		"JWT_SECRET":     testSecret,
+		"PASSWORD_HASH":  "scrypt",
+		"BCRYPT_COST":    "3",
+		"ARGON2_THREADS": "0",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `PASSWORD_HASH must be bcrypt or argon2id (got "scrypt")`)
+	require.Contains(t, cfgErr.Problems, "BCRYPT_COST must be between 4 and 31")
+	require.Contains(t, cfgErr.Problems, "ARGON2_THREADS must be 1-255, ARGON2_TIME at least 1, and ARGON2_MEMORY_KIB between 8 per thread and 4194304")
+}
+
+func TestLoadConfig_BorrowDays(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":        "postgres://env",
+		"JWT_SECRET":          testSecret,
+		"BORROW_DAYS_MAX":     "60",
+		"BORROW_DAYS_DEFAULT": "21",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, model.LoanDuration{MinDays: 1, MaxDays: 60, DefaultDays: 21}, cfg.LoanDuration())
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":    "postgres://env",
+		"JWT_SECRET":      testSecret,
+		"BORROW_DAYS_MIN": "7",
+		"BORROW_DAYS_MAX": "10",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "BORROW_DAYS_MIN, BORROW_DAYS_MAX and BORROW_DAYS_DEFAULT: the default loan of 14 days is outside 7-10 days")
+}
+
+func TestLoadConfig_Payments(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":          "postgres://env",
+		"JWT_SECRET":            testSecret,
+		"PAYMENT_PROVIDER":      "stripe",
+		"STRIPE_SECRET_KEY":     "sk_test_123",
+		"STRIPE_WEBHOOK_SECRET": "whsec_123",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "stripe", cfg.PaymentProvider)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"PAYMENT_PROVIDER":  "stripe",
+		"STRIPE_SECRET_KEY": "sk_live_123",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "STRIPE_SECRET_KEY must be a test-mode secret key (sk_test_...) when PAYMENT_PROVIDER is stripe")
+	require.Contains(t, cfgErr.Problems, "STRIPE_WEBHOOK_SECRET is required when PAYMENT_PROVIDER is stripe")
+}
+
+func TestLoadConfig_RegistrationChallenge(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":           "postgres://env",
+		"JWT_SECRET":             testSecret,
+		"REGISTRATION_CHALLENGE": "pow",
+		"CHALLENGE_SECRET":       testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 20, cfg.PoWDifficulty)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":           "postgres://env",
+		"JWT_SECRET":             testSecret,
+		"REGISTRATION_CHALLENGE": "turnstile",
+		"CHALLENGE_SECRET":       "0x4AAA",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "CHALLENGE_SITE_KEY and CHALLENGE_SECRET are required when REGISTRATION_CHALLENGE is turnstile")
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":           "postgres://env",
+		"JWT_SECRET":             testSecret,
+		"REGISTRATION_CHALLENGE": "recaptcha",
+	}))
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `REGISTRATION_CHALLENGE must be empty, hcaptcha, turnstile or pow (got "recaptcha")`)
+}
+
+func TestLoadConfig_UnknownFileKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("databse_url: typo\n"), 0o600))
+
+	_, err := loadConfig(envMap(map[string]string{"CONFIG_FILE": path}))
+	require.Error(t, err)
+}
+
+func TestLoadConfig_JWTKeysFromEnv(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_KEYS":     "new:" + testSecret + ",old:" + testSecret,
+	}))
+	require.NoError(t, err)
+	keys := cfg.SigningKeys()
+	require.Len(t, keys, 2)
+	require.Equal(t, "new", keys[0].ID)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_KEYS":     "a:" + testSecret + ",a:" + testSecret,
+	}))
+	require.ErrorContains(t, err, `JWT key "a" is listed more than once`)
+}
+
+func TestLoadConfig_JWTClaims(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.Empty(t, cfg.JWTIssuer)
+	require.Equal(t, 30*time.Second, cfg.JWTLeeway)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+		"JWT_ISSUER":   "library-api",
+		"JWT_AUDIENCE": "library-clients",
+		"JWT_LEEWAY":   "5s",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "library-api", cfg.JWTIssuer)
+	require.Equal(t, "library-clients", cfg.JWTAudience)
+	require.Equal(t, 5*time.Second, cfg.JWTLeeway)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+		"JWT_LEEWAY":   "1h",
+	}))
+	require.ErrorContains(t, err, "JWT_LEEWAY must be between 0 and 5m0s")
+}
+
+func TestLoadConfig_LegacyRoutesSunset(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":         "postgres://env",
+		"JWT_SECRET":           testSecret,
+		"LEGACY_ROUTES_SUNSET": "2027-04-30",
+	}))
+	require.NoError(t, err)
+	require.True(t, cfg.LegacyRoutes)
+	require.Equal(t, time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC), cfg.LegacySunset())
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":         "postgres://env",
+		"JWT_SECRET":           testSecret,
+		"LEGACY_ROUTES_SUNSET": "next spring",
+	}))
+	require.ErrorContains(t, err, "LEGACY_ROUTES_SUNSET must be a date")
+}
+
+func TestLoadConfig_RouteTimeouts(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":   "postgres://env",
+		"JWT_SECRET":     testSecret,
+		"ROUTE_TIMEOUTS": "/admin/books/import=10m, /admin/books/*/enrich=30s",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Minute, cfg.RouteTimeouts["/admin/books/import"])
+	require.Equal(t, 30*time.Second, cfg.RouteTimeouts["/admin/books/*/enrich"])
+	require.Equal(t, 5*time.Minute, cfg.RouteTimeouts["/admin/books/export"], "defaults are kept")
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":   "postgres://env",
+		"JWT_SECRET":     testSecret,
+		"ROUTE_TIMEOUTS": "admin/[books=1s",
+	}))
+	require.ErrorContains(t, err, `route timeout pattern "admin/[books" must be an absolute path glob`)
+}
+
+func TestLoadConfig_LoginRateLimit(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":            "postgres://env",
+		"JWT_SECRET":              testSecret,
+		"LOGIN_RATE_PER_USERNAME": "0",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 30, cfg.LoginRatePerIP)
+	require.Zero(t, cfg.LoginRatePerUsername)
+	require.Equal(t, time.Minute, cfg.LoginRatePeriod)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"LOGIN_RATE_PER_IP": "-1",
+		"LOGIN_RATE_PERIOD": "0s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "LOGIN_RATE_PER_IP and LOGIN_RATE_PER_USERNAME must not be negative")
+	require.Contains(t, cfgErr.Problems, "LOGIN_RATE_PERIOD must be positive")
+}
+
+func TestLoadConfig_AuthCookie(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+		"AUTH_COOKIE":  "library_token",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "library_token", cfg.AuthCookie)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+		"AUTH_COOKIE":  "library token",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, "AUTH_COOKIE must be a valid cookie name")
+}
+
+func TestLoadConfig_AuthMode(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":     "postgres://env",
+		"JWT_SECRET":       testSecret,
+		"AUTH_MODE":        "cookie",
+		"ACCESS_TOKEN_TTL": "5m",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "cookie", cfg.AuthMode)
+	require.Equal(t, 5*time.Minute, cfg.AccessTokenTTL)
+	require.Equal(t, "library_refresh", cfg.RefreshCookie)
+	require.Equal(t, "library_csrf", cfg.CSRFCookie)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":   "postgres://env",
+		"JWT_SECRET":     testSecret,
+		"AUTH_MODE":      "cookie",
+		"REFRESH_COOKIE": "library_csrf",
+	}))
+	require.ErrorContains(t, err, "AUTH_COOKIE, REFRESH_COOKIE and CSRF_COOKIE must be different cookies")
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+		"AUTH_MODE":    "session",
+	}))
+	require.ErrorContains(t, err, `AUTH_MODE must be header or cookie (got "session")`)
+}
+
+func TestLoadConfig_Maintenance(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL": "postgres://env",
+		"JWT_SECRET":   testSecret,
+	}))
+	require.NoError(t, err)
+	require.False(t, cfg.MaintenanceMode)
+	require.Equal(t, []string{"/auth/login", "/auth/refresh", "/announcements"}, cfg.MaintenanceAllow)
+	require.Equal(t, 5*time.Minute, cfg.MaintenanceRetryAfter)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":      "postgres://env",
+		"JWT_SECRET":        testSecret,
+		"MAINTENANCE_MODE":  "true",
+		"MAINTENANCE_ALLOW": "/auth/login, /books/*",
+	}))
+	require.NoError(t, err)
+	require.True(t, cfg.MaintenanceMode)
+	require.Equal(t, []string{"/auth/login", "/books/*"}, cfg.MaintenanceAllow)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":          "postgres://env",
+		"JWT_SECRET":            testSecret,
+		"MAINTENANCE_ALLOW":     "books",
+		"MAINTENANCE_CACHE_TTL": "0s",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Contains(t, cfgErr.Problems, `MAINTENANCE_ALLOW: "books" must be an absolute path glob`)
+	require.Contains(t, cfgErr.Problems, "MAINTENANCE_CACHE_TTL must be positive")
+}
+
+func TestLoadConfig_TenantBaseDomain(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":       "postgres://env",
+		"JWT_SECRET":         testSecret,
+		"TENANT_BASE_DOMAIN": "library.example.com",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "library.example.com", cfg.TenantBaseDomain)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":       "postgres://env",
+		"JWT_SECRET":         testSecret,
+		"TENANT_BASE_DOMAIN": "https://library.example.com",
+	}))
+	require.ErrorContains(t, err, "TENANT_BASE_DOMAIN must be a bare domain")
+}
+
+func TestLoadConfig_MemoryDriverNeedsNoDatabase(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DB_DRIVER":  "memory",
+		"JWT_SECRET": testSecret,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "memory", cfg.DBDriver)
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DB_DRIVER":  "sqlite",
+		"JWT_SECRET": testSecret,
+	}))
+	require.ErrorContains(t, err, `DB_DRIVER must be postgres or memory (got "sqlite")`)
+}
+
+func TestLoadConfig_OIDCProvidersFromEnv(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":              "postgres://env",
+		"JWT_SECRET":                testSecret,
+		"OIDC_REDIRECT_BASE_URL":    "https://library.example.com/",
+		"OIDC_GITHUB_CLIENT_ID":     "gh-id",
+		"OIDC_GITHUB_CLIENT_SECRET": "gh-secret",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]OIDCProvider{"github": {ClientID: "gh-id", ClientSecret: "gh-secret"}}, cfg.OIDCProviders)
+	require.Equal(t, "https://library.example.com/v1/auth/oidc/github/callback", cfg.OIDCRedirectURL("github"))
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":          "postgres://env",
+		"JWT_SECRET":            testSecret,
+		"OIDC_GOOGLE_CLIENT_ID": "g-id",
+		"OIDC_GOOGLE_ROLES":     "staff=admin",
+		"OIDC_GITHUB_ROLES":     "acme=root,acme/ops",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.ElementsMatch(t, []string{
+		"OIDC_GOOGLE_CLIENT_ID and OIDC_GOOGLE_CLIENT_SECRET are both required",
+		`OIDC_REDIRECT_BASE_URL must be the API's public URL, like https://library.example.com (got "")`,
+		"OIDC_GOOGLE_ROLES can't be used: google doesn't report groups; roles can only follow groups from github",
+		`OIDC_GITHUB_ROLES: expected group=role, got "acme/ops"`,
+		"OIDC_GITHUB_CLIENT_ID and OIDC_GITHUB_CLIENT_SECRET are both required",
+	}, cfgErr.Problems)
+
+	cfg, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":              "postgres://env",
+		"JWT_SECRET":                testSecret,
+		"OIDC_REDIRECT_BASE_URL":    "https://library.example.com/",
+		"OIDC_GITHUB_CLIENT_ID":     "gh-id",
+		"OIDC_GITHUB_CLIENT_SECRET": "gh-secret",
+		"OIDC_GITHUB_ROLES":         "Acme/Librarians=Admin, acme=user",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]model.Role{"acme/librarians": model.RoleAdmin, "acme": model.RoleUser}, cfg.OIDCProviders["github"].Roles)
+}
+
+func TestLoadConfig_TLS(t *testing.T) {
+	cfg, err := loadConfig(envMap(map[string]string{
+		"DATABASE_URL":         "postgres://env",
+		"JWT_SECRET":           testSecret,
+		"PORT":                 "443",
+		"TLS_AUTOCERT_DOMAINS": "library.example.com, api.library.example.com",
+		"TRUSTED_PROXIES":      "10.0.0.0/8, 192.168.1.7",
+	}))
+	require.NoError(t, err)
+	require.True(t, cfg.TLSEnabled())
+	require.Equal(t, []string{"library.example.com", "api.library.example.com"}, cfg.TLSAutocertDomains)
+	require.Equal(t, "80", cfg.TLSAutocertHTTPPort)
+	proxies, err := ParseTrustedProxies(cfg.TrustedProxies)
+	require.NoError(t, err)
+	require.Equal(t, "[10.0.0.0/8 192.168.1.7/32]", fmt.Sprint(proxies))
+
+	_, err = loadConfig(envMap(map[string]string{
+		"DATABASE_URL":    "postgres://env",
+		"JWT_SECRET":      testSecret,
+		"TLS_CERT_FILE":   "/etc/tls/cert.pem",
+		"H2C":             "true",
+		"TRUSTED_PROXIES": "10.0.0.0/33",
+	}))
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.ElementsMatch(t, []string{
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		"H2C is for plain HTTP behind a proxy; HTTPS already serves HTTP/2",
+		`TRUSTED_PROXIES: "10.0.0.0/33" is not an IP address or CIDR`,
+	}, cfgErr.Problems)
+}
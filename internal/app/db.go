@@ -4,24 +4,166 @@ import (
 	"context"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
 )
 
+// queryExecModes maps DB_QUERY_EXEC_MODE values to pgx's modes; the names
+// are those of pgx's default_query_exec_mode connection parameter.
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// defaultApplicationName names the API's sessions in pg_stat_activity
+// when DATABASE_URL doesn't set application_name.
+const defaultApplicationName = "library-api"
+
+// NewDBPool returns a pool on the primary database once it answers,
+// retrying for up to DBConnectMaxWait while it doesn't.
 func NewDBPool(ctx context.Context, cfg *Config) (*pgxpool.Pool, error) {
-	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	pool, err := newDBPool(ctx, cfg, "primary", cfg.DatabaseURL)
 	if err != nil {
 		return nil, err
 	}
-	poolCfg.MaxConns = 10
-	poolCfg.MinConns = 1
-	poolCfg.MaxConnLifetime = 30 * time.Minute
-	poolCfg.HealthCheckPeriod = 1 * time.Minute
-
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	pool, err := pgxpool.NewWithConfig(ctxWithTimeout, poolCfg)
+	// The pool connects lazily; ping so an unreachable database is waited
+	// out here rather than failing the first requests.
+	err = WaitFor(ctx, "database", cfg.DBConnectMaxWait, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, cfg.DBConnectTimeout)
+		defer cancel()
+		return pool.Ping(ctx)
+	})
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
 	return pool, nil
 }
+
+// NewReadDBPool returns a pool on the read replica at DatabaseReadURL, with
+// the same settings as the primary's, or nil when there is none. It doesn't
+// wait for the replica: reads move to the primary once its health check
+// finds the replica unreachable.
+func NewReadDBPool(ctx context.Context, cfg *Config) (*pgxpool.Pool, error) {
+	if cfg.DatabaseReadURL == "" {
+		return nil, nil
+	}
+	return newDBPool(ctx, cfg, "replica", cfg.DatabaseReadURL)
+}
+
+// newDBPool configures a pool on url. name is the Pool dimension of its
+// query metrics.
+func newDBPool(ctx context.Context, cfg *Config, name, url string) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MaxConns = cfg.DBMaxConns
+	poolCfg.MinConns = cfg.DBMinConns
+	poolCfg.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolCfg.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+	if mode, ok := queryExecModes[cfg.DBQueryExecMode]; ok {
+		poolCfg.ConnConfig.DefaultQueryExecMode = mode
+	}
+	appName := poolCfg.ConnConfig.RuntimeParams["application_name"]
+	if appName == "" {
+		appName = defaultApplicationName
+		poolCfg.ConnConfig.RuntimeParams["application_name"] = appName
+	}
+	poolCfg.BeforeAcquire = tagSession(appName)
+	poolCfg.ConnConfig.Tracer = newQueryTracer(name, cfg.DBSlowQueryThreshold, logger.GetLogger())
+
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
+
+// tagSession returns a BeforeAcquire hook that names the session after
+// the request it is acquired for, as "<appName> <request ID>", so a
+// query seen in pg_stat_activity or the server log can be traced to the
+// request's log lines. Work outside a request uses plain appName. The name
+// is only set when it changes; a connection that can't be renamed is
+// discarded.
+func tagSession(appName string) func(context.Context, *pgx.Conn) bool {
+	return func(ctx context.Context, c *pgx.Conn) bool {
+		name := appName
+		if id := requestid.From(ctx); id != "" {
+			name += " " + id
+		}
+		data := c.PgConn().CustomData()
+		current, ok := data["application_name"].(string)
+		if !ok {
+			// A new connection is named appName by its runtime params.
+			current = appName
+		}
+		if current == name {
+			return true
+		}
+		if _, err := c.Exec(ctx, "SELECT set_config('application_name', $1, false)", name); err != nil {
+			return false
+		}
+		data["application_name"] = name
+		return true
+	}
+}
+
+// MetricRecorder buffers metric observations; *logger.CloudWatchLogger is
+// one.
+type MetricRecorder interface {
+	RecordMetric(metricName string, value float64, unit string, dims map[string]string)
+}
+
+// poolSample is the part of a pgxpool.Stat that is reported.
+type poolSample struct {
+	acquired, idle, total, max int32
+	emptyAcquires              int64
+	canceledAcquires           int64
+	acquireDuration            time.Duration
+}
+
+func samplePool(s *pgxpool.Stat) poolSample {
+	return poolSample{
+		acquired:         s.AcquiredConns(),
+		idle:             s.IdleConns(),
+		total:            s.TotalConns(),
+		max:              s.MaxConns(),
+		emptyAcquires:    s.EmptyAcquireCount(),
+		canceledAcquires: s.CanceledAcquireCount(),
+		acquireDuration:  s.AcquireDuration(),
+	}
+}
+
+// ReportPoolStats records pool's statistics with rec every interval until
+// ctx is cancelled, with a Pool dimension of name. Connection counts are gauges; waits (acquires that
+// found no idle connection), cancelled acquires and the time spent
+// acquiring are counted since the previous report.
+func ReportPoolStats(ctx context.Context, pool *pgxpool.Pool, name string, interval time.Duration, rec MetricRecorder) {
+	dims := map[string]string{"Pool": name}
+	prev := samplePool(pool.Stat())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cur := samplePool(pool.Stat())
+			recordPoolStats(rec, dims, prev, cur)
+			prev = cur
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func recordPoolStats(rec MetricRecorder, dims map[string]string, prev, cur poolSample) {
+	rec.RecordMetric("DBPoolAcquiredConns", float64(cur.acquired), "Count", dims)
+	rec.RecordMetric("DBPoolIdleConns", float64(cur.idle), "Count", dims)
+	rec.RecordMetric("DBPoolTotalConns", float64(cur.total), "Count", dims)
+	rec.RecordMetric("DBPoolMaxConns", float64(cur.max), "Count", dims)
+	rec.RecordMetric("DBPoolWaits", float64(cur.emptyAcquires-prev.emptyAcquires), "Count", dims)
+	rec.RecordMetric("DBPoolCanceledAcquires", float64(cur.canceledAcquires-prev.canceledAcquires), "Count", dims)
+	rec.RecordMetric("DBPoolAcquireTime", float64((cur.acquireDuration - prev.acquireDuration).Milliseconds()), "Milliseconds", dims)
+}
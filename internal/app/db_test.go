@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedMetrics map[string]float64
+
+func (m recordedMetrics) RecordMetric(name string, value float64, _ string, dims map[string]string) {
+	m[dims["Pool"]+"/"+name] = value
+}
+
+func TestRecordPoolStats_GaugesAndDeltas(t *testing.T) {
+	prev := poolSample{emptyAcquires: 10, canceledAcquires: 1, acquireDuration: time.Second}
+	cur := poolSample{acquired: 7, idle: 2, total: 9, max: 10, emptyAcquires: 14, canceledAcquires: 1, acquireDuration: 1500 * time.Millisecond}
+
+	m := recordedMetrics{}
+	recordPoolStats(m, map[string]string{"Pool": "primary"}, prev, cur)
+	require.Equal(t, recordedMetrics{
+		"primary/DBPoolAcquiredConns":    7,
+		"primary/DBPoolIdleConns":        2,
+		"primary/DBPoolTotalConns":       9,
+		"primary/DBPoolMaxConns":         10,
+		"primary/DBPoolWaits":            4,
+		"primary/DBPoolCanceledAcquires": 0,
+		"primary/DBPoolAcquireTime":      500,
+	}, m)
+}
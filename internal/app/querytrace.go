@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// repoPackage is the import path of the repositories, whose methods
+// queries are attributed to.
+const repoPackage = "github.com/praveen-anandh-jeyaraman/digicert/internal/repo."
+
+// maxLoggedSQL caps the statement text in slow query logs.
+const maxLoggedSQL = 1000
+
+// queryTracer records each statement's duration, rows and errors as
+// metrics, by the repository method that ran it, and logs statements
+// slower than slow.
+type queryTracer struct {
+	pool string
+	slow time.Duration
+	rec  MetricRecorder
+}
+
+type queryTraceKey struct{}
+
+// queryTrace is what TraceQueryStart hands TraceQueryEnd.
+type queryTrace struct {
+	start  time.Time
+	sql    string
+	method string
+}
+
+func newQueryTracer(pool string, slow time.Duration, rec MetricRecorder) *queryTracer {
+	return &queryTracer{pool: pool, slow: slow, rec: rec}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{start: time.Now(), sql: data.SQL, method: repoMethod()})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	took := time.Since(q.start)
+	rows := data.CommandTag.RowsAffected()
+
+	dims := map[string]string{"Pool": t.pool, "Method": q.method}
+	t.rec.RecordMetric("DBQueryTime", float64(took.Milliseconds()), "Milliseconds", dims)
+	t.rec.RecordMetric("DBQueryRows", float64(rows), "Count", dims)
+	if data.Err != nil {
+		t.rec.RecordMetric("DBQueryErrors", 1, "Count", dims)
+	}
+
+	if t.slow > 0 && took >= t.slow {
+		slog.WarnContext(ctx, "slow query",
+			"pool", t.pool,
+			"method", q.method,
+			"duration_ms", took.Milliseconds(),
+			"rows", rows,
+			"error", data.Err,
+			"sql", compactSQL(q.sql))
+	}
+}
+
+// repoMethod names the repository method, such as pgBookRepo.List, that
+// the running query was sent from, or "other" for queries from elsewhere
+// such as migrations. Only methods of the *Repo types count, so the
+// queries of the helpers and wrappers a method goes through count towards
+// the method.
+func repoMethod() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, repoPackage); ok {
+			// (*pgBookRepo).List.func1 is a closure in pgBookRepo.List.
+			if i := strings.Index(name, ".func"); i > 0 {
+				name = name[:i]
+			}
+			name = strings.NewReplacer("(*", "", ")", "").Replace(name)
+			if typ, _, ok := strings.Cut(name, "."); ok && strings.HasSuffix(typ, "Repo") {
+				return name
+			}
+		}
+		if !more {
+			return "other"
+		}
+	}
+}
+
+// compactSQL folds a statement's whitespace onto one line and truncates it
+// to maxLoggedSQL bytes.
+func compactSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLoggedSQL {
+		sql = sql[:maxLoggedSQL] + "…"
+	}
+	return sql
+}
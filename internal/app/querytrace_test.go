@@ -0,0 +1,59 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTracer_RecordsMetricsAndLogsSlowQueries(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	m := recordedMetrics{}
+	tracer := newQueryTracer("primary", time.Nanosecond, m)
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT id\n\t FROM books"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+	require.Equal(t, float64(3), m["primary/DBQueryRows"])
+	require.Contains(t, m, "primary/DBQueryTime")
+	require.NotContains(t, m, "primary/DBQueryErrors")
+	require.Contains(t, logs.String(), `"msg":"slow query"`)
+	require.Contains(t, logs.String(), `"method":"other"`)
+	require.Contains(t, logs.String(), `"sql":"SELECT id FROM books"`)
+
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+	require.Equal(t, float64(1), m["primary/DBQueryErrors"])
+}
+
+func TestQueryTracer_ThresholdZeroLogsNothing(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	tracer := newQueryTracer("replica", 0, recordedMetrics{})
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	require.Empty(t, logs.String())
+}
+
+func TestCompactSQL(t *testing.T) {
+	require.Equal(t, "SELECT * FROM books WHERE id = $1", compactSQL("\n  SELECT *\n  FROM books\n  WHERE id = $1\n"))
+
+	long := compactSQL(strings.Repeat("x", 2*maxLoggedSQL))
+	require.True(t, strings.HasPrefix(long, strings.Repeat("x", maxLoggedSQL)))
+	require.True(t, strings.HasSuffix(long, "…"))
+	require.Len(t, long, maxLoggedSQL+len("…"))
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+    "context"
+    "errors"
+    "net/http"
+
+    "golang.org/x/crypto/acme/autocert"
+)
+
+// Server is the API's HTTP server, serving HTTPS itself when TLS is
+// configured and plain HTTP otherwise.
+type Server struct {
+    *http.Server
+    certFile, keyFile string
+    // challenge answers Let's Encrypt's HTTP-01 challenges and redirects
+    // other plain HTTP requests to HTTPS; nil without autocert.
+    challenge *http.Server
+}
+
+// NewServer builds the server for handler on ":"+port. HTTPS is offered
+// over HTTP/2 and HTTP/1.1; plain HTTP over HTTP/1.1, and HTTP/2 too when
+// cfg.H2C is set.
+func NewServer(cfg *Config, port string, handler http.Handler) *Server {
+    s := &Server{
+        Server: &http.Server{
+            Addr:         ":" + port,
+            Handler:      handler,
+            ReadTimeout:  cfg.ReadTimeout,
+            WriteTimeout: cfg.WriteTimeout,
+            IdleTimeout:  cfg.IdleTimeout,
+        },
+        certFile: cfg.TLSCertFile,
+        keyFile:  cfg.TLSKeyFile,
+    }
+    if len(cfg.TLSAutocertDomains) > 0 {
+        m := &autocert.Manager{
+            Prompt:     autocert.AcceptTOS,
+            HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+            Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+            Email:      cfg.TLSAutocertEmail,
+        }
+        s.TLSConfig = m.TLSConfig()
+        s.challenge = &http.Server{
+            Addr:         ":" + cfg.TLSAutocertHTTPPort,
+            Handler:      m.HTTPHandler(nil),
+            ReadTimeout:  cfg.ReadTimeout,
+            WriteTimeout: cfg.WriteTimeout,
+        }
+    }
+    if cfg.H2C && !s.TLS() {
+        s.Protocols = new(http.Protocols)
+        s.Protocols.SetHTTP1(true)
+        s.Protocols.SetUnencryptedHTTP2(true)
+    }
+    return s
+}
+
+// TLS reports whether the server speaks HTTPS.
+func (s *Server) TLS() bool {
+    return s.certFile != "" || s.TLSConfig != nil
+}
+
+// ListenAndServe serves until Shutdown, returning http.ErrServerClosed
+// then, or the first error of the server or its ACME challenge listener.
+func (s *Server) ListenAndServe() error {
+    if !s.TLS() {
+        return s.Server.ListenAndServe()
+    }
+    errs := make(chan error, 2)
+    if s.challenge != nil {
+        go func() { errs <- s.challenge.ListenAndServe() }()
+    }
+    go func() { errs <- s.Server.ListenAndServeTLS(s.certFile, s.keyFile) }()
+    err := <-errs
+    if errors.Is(err, http.ErrServerClosed) && s.challenge != nil {
+        // Shutdown closes both; report the API server's own result.
+        err = <-errs
+    }
+    return err
+}
+
+// Shutdown gracefully stops the server and its ACME challenge listener.
+func (s *Server) Shutdown(ctx context.Context) error {
+    var challengeErr error
+    if s.challenge != nil {
+        challengeErr = s.challenge.Shutdown(ctx)
+    }
+    return errors.Join(s.Server.Shutdown(ctx), challengeErr)
+}
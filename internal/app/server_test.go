@@ -0,0 +1,27 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServer(t *testing.T) {
+	cfg := DefaultConfig()
+	srv := NewServer(cfg, "8080", nil)
+	require.False(t, srv.TLS())
+	require.Nil(t, srv.Protocols, "HTTP/1.1 only by default")
+
+	cfg.H2C = true
+	srv = NewServer(cfg, "8080", nil)
+	require.True(t, srv.Protocols.UnencryptedHTTP2())
+	require.True(t, srv.Protocols.HTTP1())
+
+	cfg.H2C = false
+	cfg.TLSAutocertDomains = []string{"library.example.com"}
+	cfg.TLSAutocertCacheDir = t.TempDir()
+	srv = NewServer(cfg, "443", nil)
+	require.True(t, srv.TLS())
+	require.Contains(t, srv.TLSConfig.NextProtos, "h2")
+	require.Equal(t, ":80", srv.challenge.Addr)
+}
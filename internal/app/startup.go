@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Backoff between attempts of WaitFor: it starts at minRetryDelay and
+// doubles up to maxRetryDelay.
+const (
+	minRetryDelay = 250 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+)
+
+// WaitFor calls ready until it succeeds, backing off exponentially between
+// attempts, for up to maxWait; maxWait 0 tries once. It is how startup
+// waits out a dependency, such as the database, that comes up after the
+// API does. The error is ready's last, once no attempt can start within
+// maxWait or ctx is done.
+func WaitFor(ctx context.Context, dependency string, maxWait time.Duration, ready func(context.Context) error) error {
+	deadline := time.Now().Add(maxWait)
+	delay := minRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := ready(ctx)
+		if err == nil {
+			if attempt > 1 {
+				slog.InfoContext(ctx, "dependency ready", "dependency", dependency, "attempts", attempt)
+			}
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("%s not ready after %d attempts: %w", dependency, attempt, err)
+		}
+		slog.WarnContext(ctx, "dependency not ready, retrying", "dependency", dependency, "attempt", attempt, "retry_in", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready: %w", dependency, err)
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// StartupHandler answers probes while the API is still waiting for its
+// dependencies: /healthz is healthy, so the process isn't restarted for
+// waiting, and /readyz and everything else are 503 until the API's own
+// router takes over.
+func StartupHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"healthy"}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status":"starting"}`))
+	})
+	return mux
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitFor_RetriesUntilReady(t *testing.T) {
+	down := errors.New("connection refused")
+	attempts := 0
+	err := WaitFor(context.Background(), "database", time.Second, func(context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return down
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+}
+
+func TestWaitFor_GivesUpAfterMaxWait(t *testing.T) {
+	down := errors.New("connection refused")
+	attempts := 0
+	start := time.Now()
+	err := WaitFor(context.Background(), "database", 500*time.Millisecond, func(context.Context) error {
+		attempts++
+		return down
+	})
+	require.ErrorIs(t, err, down)
+	require.ErrorContains(t, err, "database not ready after 2 attempts")
+	require.Equal(t, 2, attempts, "a third attempt, 750ms in, would be past the wait")
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	attempts = 0
+	err = WaitFor(context.Background(), "database", 0, func(context.Context) error {
+		attempts++
+		return down
+	})
+	require.ErrorIs(t, err, down)
+	require.Equal(t, 1, attempts, "no wait means a single attempt")
+}
+
+func TestWaitFor_StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	down := errors.New("connection refused")
+	err := WaitFor(ctx, "database", time.Minute, func(context.Context) error {
+		cancel()
+		return down
+	})
+	require.ErrorIs(t, err, down)
+}
+
+func TestStartupHandler(t *testing.T) {
+	h := StartupHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, path := range []string{"/readyz", "/v1/books"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+		require.JSONEq(t, `{"status":"starting"}`, w.Body.String())
+		require.Equal(t, "1", w.Header().Get("Retry-After"))
+	}
+}
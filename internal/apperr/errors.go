@@ -0,0 +1,75 @@
+// Package apperr defines the error kinds shared by the repo, service and
+// handler layers so that callers can classify failures with errors.Is
+// instead of matching on message text.
+package apperr
+
+import "errors"
+
+// Sentinel error kinds. Wrap them with the constructors below so the
+// original, human-readable message is preserved.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+	// ErrPreconditionFailed means the caller's expected version of a
+	// resource no longer matches the stored one.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrLocked means the caller is temporarily locked out.
+	ErrLocked = errors.New("locked")
+	// ErrUpstream means an external service the request depends on failed.
+	ErrUpstream = errors.New("upstream failure")
+	// ErrPolicyViolation means a well-formed request breaks a configured
+	// business rule, such as a loan limit.
+	ErrPolicyViolation = errors.New("policy violation")
+)
+
+// Error carries a message together with one of the sentinel kinds.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Unwrap exposes the kind so errors.Is(err, ErrNotFound) works.
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NotFound returns an error of kind ErrNotFound with the given message.
+func NotFound(msg string) error {
+	return &Error{Kind: ErrNotFound, Msg: msg}
+}
+
+// Conflict returns an error of kind ErrConflict with the given message.
+func Conflict(msg string) error {
+	return &Error{Kind: ErrConflict, Msg: msg}
+}
+
+// Forbidden returns an error of kind ErrForbidden with the given message.
+func Forbidden(msg string) error {
+	return &Error{Kind: ErrForbidden, Msg: msg}
+}
+
+// Validation returns an error of kind ErrValidation with the given message.
+func Validation(msg string) error {
+	return &Error{Kind: ErrValidation, Msg: msg}
+}
+
+// PreconditionFailed returns an error of kind ErrPreconditionFailed with the given message.
+func PreconditionFailed(msg string) error {
+	return &Error{Kind: ErrPreconditionFailed, Msg: msg}
+}
+
+// Upstream returns an error of kind ErrUpstream with the given message.
+func Upstream(msg string) error {
+	return &Error{Kind: ErrUpstream, Msg: msg}
+}
+
+// PolicyViolation returns an error of kind ErrPolicyViolation with the given message.
+func PolicyViolation(msg string) error {
+	return &Error{Kind: ErrPolicyViolation, Msg: msg}
+}
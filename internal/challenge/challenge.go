@@ -0,0 +1,36 @@
+// Package challenge makes signing up cost a bot something: a CAPTCHA
+// answered with hCaptcha or Cloudflare Turnstile, or a proof of work
+// computed by the client. The client asks what to solve with Issue and
+// sends its solution with the request Verify checks.
+package challenge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// ErrFailed means the solution was missing, wrong, expired or used
+// already.
+var ErrFailed = errors.New("challenge failed")
+
+// Verifier checks that a client solved a challenge.
+type Verifier interface {
+	// Issue returns a challenge for a client to solve.
+	Issue() model.Challenge
+	// Verify checks the client's solution, returning an error wrapping
+	// ErrFailed when it doesn't hold. remoteIP may be "".
+	Verify(ctx context.Context, solution, remoteIP string) error
+}
+
+// StatusError is an unexpected HTTP response from a verification service.
+type StatusError struct {
+	Service string
+	Code    int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d", e.Service, e.Code)
+}
@@ -0,0 +1,76 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+// solve finds a proof of work for c by brute force, or with valid false
+// an answer that isn't one.
+func solve(c model.Challenge, valid bool) string {
+	for i := 0; ; i++ {
+		solution := c.Nonce + ":" + strconv.Itoa(i)
+		if (leadingZeroBits(sha256.Sum256([]byte(solution))) >= c.Difficulty) == valid {
+			return solution
+		}
+	}
+}
+
+func TestPoW(t *testing.T) {
+	ctx := context.Background()
+	pow := NewPoW([]byte("0123456789abcdef0123456789abcdef"), 8)
+	now := time.Now()
+	pow.now = func() time.Time { return now }
+
+	c := pow.Issue()
+	require.Equal(t, "pow", c.Kind)
+	require.Equal(t, 8, c.Difficulty)
+	solution := solve(c, true)
+
+	require.ErrorIs(t, pow.Verify(ctx, solve(c, false), ""), ErrFailed)
+	require.NoError(t, pow.Verify(ctx, solution, ""))
+	require.ErrorIs(t, pow.Verify(ctx, solution, ""), ErrFailed, "a solution is used once")
+
+	other := NewPoW([]byte("another key, another API instance"), 0)
+	require.ErrorIs(t, other.Verify(ctx, solve(pow.Issue(), true), ""), ErrFailed, "nonces are signed")
+
+	late := solve(pow.Issue(), true)
+	now = now.Add(powTTL)
+	require.ErrorIs(t, pow.Verify(ctx, late, ""), ErrFailed, "nonces expire")
+}
+
+func TestSiteVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "secret", r.PostForm.Get("secret"))
+		switch r.PostForm.Get("response") {
+		case "good":
+			require.Equal(t, "203.0.113.7", r.PostForm.Get("remoteip"))
+			_, _ = w.Write([]byte(`{"success": true}`))
+		case "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer srv.Close()
+	v := NewTurnstile(srv.Client(), "site", "secret")
+	v.url = srv.URL
+	ctx := context.Background()
+
+	require.Equal(t, model.Challenge{Kind: "turnstile", SiteKey: "site"}, v.Issue())
+	require.NoError(t, v.Verify(ctx, "good", "203.0.113.7"))
+	require.ErrorIs(t, v.Verify(ctx, "bad", ""), ErrFailed)
+	require.ErrorIs(t, v.Verify(ctx, "", ""), ErrFailed)
+	err := v.Verify(ctx, "down", "")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrFailed, "an outage isn't the client's fault")
+}
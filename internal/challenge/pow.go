@@ -0,0 +1,101 @@
+package challenge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// powTTL is how long a client has to solve a proof-of-work challenge.
+const powTTL = 5 * time.Minute
+
+// PoW asks clients for a proof of work: a string s such that the
+// SHA-256 of nonce + ":" + s starts with Difficulty zero bits. Nonces are
+// signed, so no state is kept for them until they are solved; solved ones
+// are remembered until they expire so a solution is used once per
+// instance.
+type PoW struct {
+	key        []byte
+	difficulty int
+	now        func() time.Time
+
+	mu    sync.Mutex
+	spent map[string]time.Time // nonce to expiry
+}
+
+// NewPoW returns a verifier signing its nonces with key and asking for
+// difficulty zero bits; each bit doubles the client's work.
+func NewPoW(key []byte, difficulty int) *PoW {
+	return &PoW{key: key, difficulty: difficulty, now: time.Now, spent: map[string]time.Time{}}
+}
+
+func (p *PoW) Issue() model.Challenge {
+	expiresAt := p.now().Add(powTTL).UTC().Truncate(time.Second)
+	payload := make([]byte, 8+16)
+	binary.BigEndian.PutUint64(payload, uint64(expiresAt.Unix()))
+	_, _ = rand.Read(payload[8:])
+	nonce := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload))
+	return model.Challenge{Kind: "pow", Nonce: nonce, Difficulty: p.difficulty, ExpiresAt: &expiresAt}
+}
+
+func (p *PoW) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Verify takes the solution as nonce + ":" + s.
+func (p *PoW) Verify(_ context.Context, solution, _ string) error {
+	nonce, _, ok := strings.Cut(solution, ":")
+	if !ok {
+		return fmt.Errorf("%w: a proof of work is nonce:answer", ErrFailed)
+	}
+	encoded, sig, ok := strings.Cut(nonce, ".")
+	payload, err1 := base64.RawURLEncoding.DecodeString(encoded)
+	mac, err2 := base64.RawURLEncoding.DecodeString(sig)
+	if !ok || err1 != nil || err2 != nil || len(payload) != 24 || !hmac.Equal(mac, p.sign(payload)) {
+		return fmt.Errorf("%w: the nonce wasn't issued by this API", ErrFailed)
+	}
+	now := p.now()
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if !now.Before(expiresAt) {
+		return fmt.Errorf("%w: the nonce expired", ErrFailed)
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(solution))) < p.difficulty {
+		return fmt.Errorf("%w: the proof of work doesn't meet the difficulty", ErrFailed)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n, exp := range p.spent {
+		if !now.Before(exp) {
+			delete(p.spent, n)
+		}
+	}
+	if _, used := p.spent[nonce]; used {
+		return fmt.Errorf("%w: the nonce was used already", ErrFailed)
+	}
+	p.spent[nonce] = expiresAt
+	return nil
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+const (
+	hCaptchaURL  = "https://api.hcaptcha.com/siteverify"
+	turnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// SiteVerify checks CAPTCHA answers with a siteverify API, which hCaptcha
+// and Turnstile share.
+type SiteVerify struct {
+	kind    string
+	client  *http.Client
+	url     string
+	siteKey string
+	secret  string
+}
+
+// NewHCaptcha returns a verifier for the hCaptcha site siteKey, whose
+// secret is secret.
+func NewHCaptcha(client *http.Client, siteKey, secret string) *SiteVerify {
+	return &SiteVerify{kind: "hcaptcha", client: client, url: hCaptchaURL, siteKey: siteKey, secret: secret}
+}
+
+// NewTurnstile returns a verifier for the Cloudflare Turnstile widget
+// siteKey, whose secret is secret.
+func NewTurnstile(client *http.Client, siteKey, secret string) *SiteVerify {
+	return &SiteVerify{kind: "turnstile", client: client, url: turnstileURL, siteKey: siteKey, secret: secret}
+}
+
+func (v *SiteVerify) Issue() model.Challenge {
+	return model.Challenge{Kind: v.kind, SiteKey: v.siteKey}
+}
+
+func (v *SiteVerify) Verify(ctx context.Context, solution, remoteIP string) error {
+	if solution == "" {
+		return fmt.Errorf("%w: no %s response", ErrFailed, v.kind)
+	}
+	form := url.Values{"secret": {v.secret}, "response": {solution}, "sitekey": {v.siteKey}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.kind, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Service: v.kind, Code: resp.StatusCode}
+	}
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: decode response: %w", v.kind, err)
+	}
+	if !result.Success {
+		for _, code := range result.ErrorCodes {
+			if strings.Contains(code, "secret") {
+				return fmt.Errorf("%s: the secret was refused (%s)", v.kind, code)
+			}
+		}
+		return fmt.Errorf("%w: %s refused the response (%s)", ErrFailed, v.kind, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package clientip carries the address of the client a request came from,
+// as the HTTP layer worked it out from behind any proxies, so rate limits,
+// lockouts, logs and audit records all name the same client.
+package clientip
+
+import (
+	"context"
+	"net/netip"
+)
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying the client address ip.
+func With(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// From returns the client address carried by ctx, or "" when there is none.
+func From(ctx context.Context) string {
+	ip, _ := ctx.Value(contextKey{}).(string)
+	return ip
+}
+
+// Normalize returns addr in one canonical form, so a client isn't counted
+// as several: IPv4 addresses mapped into IPv6 are written as IPv4, IPv6
+// zones are dropped and IPv6 is compressed and lower-case. Anything that
+// isn't an IP address is returned unchanged.
+func Normalize(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return addr
+	}
+	return ip.Unmap().WithZone("").String()
+}
@@ -0,0 +1,304 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// specPath is the document swag generates from the handlers' godoc.
+const specPath = "../../docs/swagger.json"
+
+// checker validates responses against the API's Swagger document.
+type checker struct {
+	doc *spec.Swagger
+}
+
+func loadChecker(t *testing.T) *checker {
+	t.Helper()
+	raw, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	doc := &spec.Swagger{}
+	if err := json.Unmarshal(raw, doc); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	return &checker{doc: doc}
+}
+
+// operation finds the documented operation of a chi route pattern such as
+// /v1/books/{id}/.
+func (c *checker) operation(method, pattern string) *spec.Operation {
+	path := strings.TrimPrefix(pattern, c.doc.BasePath)
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	item, ok := c.doc.Paths.Paths[path]
+	if !ok {
+		return nil
+	}
+	switch method {
+	case http.MethodGet:
+		return item.Get
+	case http.MethodPost:
+		return item.Post
+	case http.MethodPut:
+		return item.Put
+	case http.MethodPatch:
+		return item.Patch
+	case http.MethodDelete:
+		return item.Delete
+	}
+	return nil
+}
+
+// check returns how the response to method on pattern departs from the
+// spec: an undocumented route or status, or a JSON body that doesn't match
+// the documented schema.
+func (c *checker) check(method, pattern string, status int, header http.Header, body []byte) []string {
+	op := c.operation(method, pattern)
+	if op == nil {
+		return []string{fmt.Sprintf("%s %s is not documented", method, pattern)}
+	}
+	var resp *spec.Response
+	if op.Responses != nil {
+		if r, ok := op.Responses.StatusCodeResponses[status]; ok {
+			resp = &r
+		} else {
+			resp = op.Responses.Default
+		}
+	}
+	if resp == nil {
+		return []string{fmt.Sprintf("%s %s answered %d, which is not documented", method, pattern, status)}
+	}
+	if resp.Schema == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if mt, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mt != "application/json" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return []string{fmt.Sprintf("%s %s answered %d with invalid JSON: %v", method, pattern, status, err)}
+	}
+	var problems []string
+	for _, p := range c.validate("$", resp.Schema, v) {
+		problems = append(problems, fmt.Sprintf("%s %s answered %d: %s", method, pattern, status, p))
+	}
+	return problems
+}
+
+// resolve follows s's $ref, if any, into the definitions.
+func (c *checker) resolve(s *spec.Schema) (*spec.Schema, error) {
+	for s.Ref.String() != "" {
+		name, ok := strings.CutPrefix(s.Ref.String(), "#/definitions/")
+		def, found := c.doc.Definitions[name]
+		if !ok || !found {
+			return nil, fmt.Errorf("unresolvable $ref %s", s.Ref.String())
+		}
+		s = &def
+	}
+	return s, nil
+}
+
+// flatten merges the members of an allOf, which swag emits to annotate a
+// $ref, into one schema.
+func (c *checker) flatten(s *spec.Schema) (*spec.Schema, error) {
+	s, err := c.resolve(s)
+	if err != nil || len(s.AllOf) == 0 {
+		return s, err
+	}
+	merged := *s
+	merged.AllOf = nil
+	merged.Properties = spec.SchemaProperties{}
+	for name, p := range s.Properties {
+		merged.Properties[name] = p
+	}
+	for i := range s.AllOf {
+		member, err := c.flatten(&s.AllOf[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(merged.Type) == 0 {
+			merged.Type = member.Type
+		}
+		if merged.Items == nil {
+			merged.Items = member.Items
+		}
+		if merged.AdditionalProperties == nil {
+			merged.AdditionalProperties = member.AdditionalProperties
+		}
+		for name, p := range member.Properties {
+			merged.Properties[name] = p
+		}
+		merged.Required = append(merged.Required, member.Required...)
+	}
+	return &merged, nil
+}
+
+// validate returns where v, found at path, departs from s. null is accepted
+// anywhere, as Go encodes nil pointers, slices and maps that way.
+func (c *checker) validate(path string, s *spec.Schema, v interface{}) []string {
+	s, err := c.flatten(s)
+	if err != nil {
+		return []string{path + ": " + err.Error()}
+	}
+	if v == nil {
+		return nil
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", path, v, s.Enum)}
+	}
+
+	typ := ""
+	if len(s.Type) > 0 {
+		typ = s.Type[0]
+	} else if len(s.Properties) > 0 {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %s", path, kind(v))}
+		}
+		return c.validateObject(path, s, obj)
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %s", path, kind(v))}
+		}
+		if s.Items == nil || s.Items.Schema == nil {
+			return nil
+		}
+		var problems []string
+		for i, item := range arr {
+			problems = append(problems, c.validate(fmt.Sprintf("%s[%d]", path, i), s.Items.Schema, item)...)
+		}
+		return problems
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %s", path, kind(v))}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", path, str)}
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected integer, got %s", path, kind(v))}
+		}
+		if _, err := n.Int64(); err != nil {
+			return []string{fmt.Sprintf("%s: %s is not an integer", path, n)}
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %s", path, kind(v))}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %s", path, kind(v))}
+		}
+	}
+	return nil
+}
+
+// validateObject checks obj's properties. A property the schema doesn't
+// list is drift, unless the schema leaves the object open.
+func (c *checker) validateObject(path string, s *spec.Schema, obj map[string]interface{}) []string {
+	var problems []string
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: required property %q is missing", path, name))
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		at := path + "." + name
+		if p, ok := s.Properties[name]; ok {
+			problems = append(problems, c.validate(at, &p, obj[name])...)
+			continue
+		}
+		switch extra := s.AdditionalProperties; {
+		case extra != nil && extra.Schema != nil:
+			problems = append(problems, c.validate(at, extra.Schema, obj[name])...)
+		case extra != nil && extra.Allows:
+		case extra == nil && len(s.Properties) == 0:
+			// A free-form object, such as map[string]interface{}.
+		default:
+			problems = append(problems, fmt.Sprintf("%s: property is not documented", at))
+		}
+	}
+	return problems
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	want := fmt.Sprint(v)
+	return slices.ContainsFunc(enum, func(e interface{}) bool { return fmt.Sprint(e) == want })
+}
+
+func kind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func TestChecker_CatchesDrift(t *testing.T) {
+	c := loadChecker(t)
+	header := http.Header{"Content-Type": {"application/json"}}
+
+	ok := c.check("GET", "/v1/books/{id}", 200, header, []byte(`{"id":"b-1","title":"Dune","total_copies":2,"available":true,"categories":null}`))
+	if len(ok) > 0 {
+		t.Fatalf("a documented payload was refused: %v", ok)
+	}
+
+	for name, tc := range map[string]struct {
+		status int
+		body   string
+		want   string
+	}{
+		"wrong type":        {200, `{"id":"b-1","total_copies":"two"}`, "$.total_copies: expected integer, got string"},
+		"extra property":    {200, `{"id":"b-1","shelf":"A3"}`, "$.shelf: property is not documented"},
+		"nested":            {200, `{"id":"b-1","categories":[{"id":7}]}`, "$.categories[0].id: expected string, got number"},
+		"undocumented code": {418, `{}`, "answered 418, which is not documented"},
+	} {
+		problems := c.check("GET", "/v1/books/{id}", tc.status, header, []byte(tc.body))
+		if len(problems) != 1 || !strings.Contains(problems[0], tc.want) {
+			t.Errorf("%s: got %v, want a problem containing %q", name, problems, tc.want)
+		}
+	}
+	if got := c.check("GET", "/v1/nowhere", 200, header, nil); len(got) != 1 {
+		t.Errorf("an undocumented route passed: %v", got)
+	}
+}
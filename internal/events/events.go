@@ -0,0 +1,91 @@
+// Package events publishes the domain events the services record in the
+// outbox, such as loans being made and returned.
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// Publisher delivers an event to its subscribers.
+type Publisher interface {
+	Publish(ctx context.Context, e model.Event) error
+}
+
+// Log is a Publisher for development that logs events instead of
+// delivering them.
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Publish(ctx context.Context, e model.Event) error {
+	l.logger.DebugContext(ctx, "event not published: log publisher", "event_id", e.ID, "type", e.Type, "subject", e.Subject)
+	return nil
+}
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed
+// with the webhook secret, as "sha256=<hex>".
+const SignatureHeader = "X-Library-Signature"
+
+// Webhook POSTs each event as JSON to a URL. Any response other than a 2xx
+// fails the delivery.
+type Webhook struct {
+	client *http.Client
+	url    string
+	secret []byte
+}
+
+// NewWebhook returns a publisher posting to url. With a secret, requests
+// are signed in SignatureHeader. The client's Timeout bounds each request.
+func NewWebhook(client *http.Client, url, secret string) *Webhook {
+	return &Webhook{client: client, url: url, secret: []byte(secret)}
+}
+
+func (w *Webhook) Publish(ctx context.Context, e model.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Library-Event", e.Type)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of body, for subscribers to check
+// deliveries against.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	failOn     string
+	published  []string
+	requestIDs []string
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, e model.Event) error {
+	if e.Subject == p.failOn {
+		return errors.New("subscriber unavailable")
+	}
+	p.published = append(p.published, e.Subject)
+	p.requestIDs = append(p.requestIDs, requestid.From(ctx))
+	return nil
+}
+
+func TestRelay_PublishesInOrderAndRetriesFailures(t *testing.T) {
+	ctx := context.Background()
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	for _, subject := range []string{"a", "b", "c"} {
+		require.NoError(t, repos.Outbox.Add(ctx, &model.Event{Type: model.EventBookingCreated, Subject: subject}))
+	}
+	pub := &fakePublisher{failOn: "b"}
+	relay := NewRelay(repos.Outbox, repos.Tx, pub, 10, time.Hour, logger.Discard())
+
+	n, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"a"}, pub.published, "a failure holds back the events after it")
+	pending, err := repos.Outbox.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, 1, pending[0].Attempts)
+
+	pub.failOn = ""
+	n, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"a", "b", "c"}, pub.published)
+	n, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	purged, err := repos.Outbox.PurgeDelivered(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 3, purged)
+}
+
+func TestRelay_DeliversWithTheRecordingRequestID(t *testing.T) {
+	ctx := context.Background()
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	require.NoError(t, repos.Outbox.Add(requestid.With(ctx, "req-1"), &model.Event{Type: model.EventBookingCreated, Subject: "a"}))
+	require.NoError(t, repos.Outbox.Add(ctx, &model.Event{Type: model.EventBookingOverdue, Subject: "b"}))
+	pub := &fakePublisher{}
+	relay := NewRelay(repos.Outbox, repos.Tx, pub, 10, time.Hour, logger.Discard())
+
+	_, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"req-1", ""}, pub.requestIDs)
+}
+
+func TestRelay_EventsOfRolledBackChangesAreNotPublished(t *testing.T) {
+	ctx := context.Background()
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	boom := errors.New("boom")
+	err := repos.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, repos.Outbox.Add(ctx, &model.Event{Type: model.EventBookingCreated, Subject: "a"}))
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	pub := &fakePublisher{}
+	n, err := NewRelay(repos.Outbox, repos.Tx, pub, 10, time.Hour, logger.Discard()).RunOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Empty(t, pub.published)
+}
+
+func TestWebhook_PostsSignedEvents(t *testing.T) {
+	var body []byte
+	var header http.Header
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	e := model.Event{ID: "e1", Type: model.EventBookingReturned, Subject: "b1", Data: json.RawMessage(`{"status":"RETURNED"}`), OccurredAt: time.Now().UTC()}
+	wh := NewWebhook(srv.Client(), srv.URL, "s3cret")
+	require.NoError(t, wh.Publish(context.Background(), e))
+	require.Equal(t, model.EventBookingReturned, header.Get("X-Library-Event"))
+	require.Equal(t, Sign([]byte("s3cret"), body), header.Get(SignatureHeader))
+	var got model.Event
+	require.NoError(t, json.Unmarshal(body, &got))
+	require.Equal(t, "e1", got.ID)
+	require.JSONEq(t, `{"status":"RETURNED"}`, string(got.Data))
+
+	status = http.StatusBadGateway
+	require.ErrorContains(t, wh.Publish(context.Background(), e), "502")
+}
+
+func TestHub_FansOutAndSkipsSlowSubscribers(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	fast, stopFast := hub.Subscribe(2)
+	defer stopFast()
+	slow, stopSlow := hub.Subscribe(1)
+
+	require.NoError(t, hub.Publish(ctx, model.Event{Subject: "a"}))
+	require.NoError(t, hub.Publish(ctx, model.Event{Subject: "b"}))
+	require.Equal(t, "a", (<-fast).Subject)
+	require.Equal(t, "b", (<-fast).Subject)
+	require.Equal(t, "a", (<-slow).Subject)
+	require.Empty(t, slow, "a full subscriber misses events")
+
+	stopSlow()
+	require.NoError(t, hub.Publish(ctx, model.Event{Subject: "c"}))
+	require.Empty(t, slow)
+	require.Equal(t, "c", (<-fast).Subject)
+}
+
+func TestMulti_StopsAtTheFirstFailure(t *testing.T) {
+	first, second := &fakePublisher{failOn: "b"}, &fakePublisher{}
+	m := Multi{first, second}
+	require.NoError(t, m.Publish(context.Background(), model.Event{Subject: "a"}))
+	require.Error(t, m.Publish(context.Background(), model.Event{Subject: "b"}))
+	require.Equal(t, []string{"a"}, second.published)
+}
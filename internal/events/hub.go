@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// Hub is a Publisher that hands each event to the subscribers on this
+// instance, such as admin dashboards following live activity. A subscriber
+// that falls behind misses events rather than holding up the others.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[chan model.Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[chan model.Event]struct{}{}}
+}
+
+// Subscribe returns a channel receiving the events published from now on,
+// buffering up to buffer of them, and a function ending the subscription.
+func (h *Hub) Subscribe(buffer int) (<-chan model.Event, func()) {
+	ch := make(chan model.Event, buffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish never fails; subscribers whose buffer is full skip e.
+func (h *Hub) Publish(_ context.Context, e model.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+// Multi publishes each event to every one of publishers in turn. It stops
+// at the first that fails, so the relay retries the event with all of them
+// and the ones before it see it again.
+type Multi []Publisher
+
+func (m Multi) Publish(ctx context.Context, e model.Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// notifyChannel is the Postgres channel events are broadcast on.
+const notifyChannel = "library_events"
+
+// maxNotifyPayload keeps notifications under Postgres' 8000-byte limit.
+const maxNotifyPayload = 7900
+
+// listenRetry is how long Listen waits before reconnecting.
+const listenRetry = 5 * time.Second
+
+// Notify is a Publisher broadcasting each event to every instance with
+// Postgres NOTIFY, for Listen to hand to the instance's Hub. The relay
+// publishes each event on one instance only; this is how dashboards
+// connected to the others see it too. An event too large for a
+// notification is broadcast without its data.
+type Notify struct {
+	db *pgxpool.Pool
+}
+
+func NewNotify(db *pgxpool.Pool) *Notify {
+	return &Notify{db: db}
+}
+
+func (n *Notify) Publish(ctx context.Context, e model.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		e.Data = nil
+		if payload, err = json.Marshal(e); err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+	}
+	_, err = n.db.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+// Listen hands the events Notify broadcasts to hub until ctx is cancelled,
+// holding one connection of db meanwhile. It reconnects after a failure;
+// events broadcast in between are missed.
+func Listen(ctx context.Context, db *pgxpool.Pool, hub *Hub, logger *slog.Logger) {
+	for {
+		err := listen(ctx, db, hub, logger)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.WarnContext(ctx, "event listener failed, reconnecting", "error", err)
+		select {
+		case <-time.After(listenRetry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func listen(ctx context.Context, db *pgxpool.Pool, hub *Hub, logger *slog.Logger) error {
+	c, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// A connection left listening would queue notifications forever.
+		_, _ = c.Exec(context.WithoutCancel(ctx), "UNLISTEN *")
+		c.Release()
+	}()
+	if _, err := c.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e model.Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			logger.WarnContext(ctx, "ignoring malformed event notification", "error", err)
+			continue
+		}
+		_ = hub.Publish(ctx, e)
+	}
+}
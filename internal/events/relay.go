@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// Relay publishes the events in the outbox in the order they were
+// recorded. An event is marked delivered only once it is published, so a
+// crash in between publishes it again on the next run.
+type Relay struct {
+	outbox    repo.OutboxRepo
+	tx        repo.TxManager
+	publisher Publisher
+	batch     int
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+// NewRelay returns a relay publishing up to batch events at a time.
+// Delivered events are deleted after retention.
+func NewRelay(outbox repo.OutboxRepo, tx repo.TxManager, publisher Publisher, batch int, retention time.Duration, logger *slog.Logger) *Relay {
+	return &Relay{outbox: outbox, tx: tx, publisher: publisher, batch: batch, retention: retention, logger: logger}
+}
+
+// Run relays events until ctx is cancelled, checking the outbox every poll
+// interval, or straight away after publishing a full batch.
+func (r *Relay) Run(ctx context.Context, poll time.Duration) {
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "outbox relay failed", "error", err)
+		}
+		if n == r.batch && err == nil {
+			continue
+		}
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce publishes one batch of pending events and returns how many were
+// delivered. The batch stays locked meanwhile, so relays on other instances
+// skip it. A failed delivery ends the batch, holding back the events after
+// it, and is retried on the next run.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	delivered := 0
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		pending, err := r.outbox.Pending(ctx, r.batch)
+		if err != nil {
+			return err
+		}
+		for _, e := range pending {
+			// Deliveries carry the ID of the request that made the change.
+			if err := r.publisher.Publish(requestid.With(ctx, e.RequestID), e); err != nil {
+				r.logger.WarnContext(ctx, "event delivery failed, will retry",
+					"event_id", e.ID, "type", e.Type, "attempt", e.Attempts+1, "error", err)
+				return r.outbox.MarkFailed(ctx, e.ID, err.Error())
+			}
+			if err := r.outbox.MarkDelivered(ctx, e.ID); err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := r.outbox.PurgeDelivered(ctx, time.Now().UTC().Add(-r.retention))
+	if err != nil {
+		return delivered, err
+	}
+	if purged > 0 {
+		r.logger.DebugContext(ctx, "purged delivered events", "count", purged)
+	}
+	return delivered, nil
+}
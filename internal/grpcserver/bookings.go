@@ -0,0 +1,105 @@
+package grpcserver
+
+import (
+    "context"
+    "log/slog"
+    "strings"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+)
+
+type bookingServer struct {
+    libraryv1.UnimplementedBookingServiceServer
+    svc    service.BookingService
+    logger *slog.Logger
+}
+
+func (s *bookingServer) Borrow(ctx context.Context, req *libraryv1.BorrowRequest) (*libraryv1.Booking, error) {
+    borrow := model.BorrowBookRequest{
+        BookID:     req.GetBookId(),
+        BorrowDays: int(req.GetBorrowDays()),
+    }
+    if err := validate(&borrow); err != nil {
+        return nil, err
+    }
+
+    receipt, err := s.svc.Borrow(ctx, userID(ctx), &borrow)
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "borrow failed", err)
+    }
+    booking := receipt.Booking
+    s.logger.InfoContext(ctx, "book borrowed", "book_id", booking.BookID, "booking_id", booking.ID)
+    return toProtoBooking(booking), nil
+}
+
+// Return only lets callers return their own bookings.
+func (s *bookingServer) Return(ctx context.Context, req *libraryv1.ReturnRequest) (*libraryv1.Booking, error) {
+    booking, err := s.svc.Return(ctx, userID(ctx), req.GetBookingId(), false)
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "return failed", err, "booking_id", req.GetBookingId())
+    }
+    s.logger.InfoContext(ctx, "book returned", "booking_id", booking.ID)
+    return toProtoBooking(booking), nil
+}
+
+func (s *bookingServer) GetBooking(ctx context.Context, req *libraryv1.GetBookingRequest) (*libraryv1.Booking, error) {
+    booking, err := s.ownBooking(ctx, req.GetId())
+    if err != nil {
+        return nil, err
+    }
+    return toProtoBooking(booking), nil
+}
+
+func (s *bookingServer) ListMyBookings(ctx context.Context, req *libraryv1.ListBookingsRequest) (*libraryv1.ListBookingsResponse, error) {
+    page, err := s.svc.GetByUser(ctx, userID(ctx), pageRequest(req.GetPage()), bookingFilter(req), bookingExpand(req))
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "list my bookings failed", err)
+    }
+    return toProtoBookings(page), nil
+}
+
+func (s *bookingServer) ListBookings(ctx context.Context, req *libraryv1.ListBookingsRequest) (*libraryv1.ListBookingsResponse, error) {
+    page, err := s.svc.List(ctx, pageRequest(req.GetPage()), bookingFilter(req), bookingExpand(req))
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "list bookings failed", err)
+    }
+    return toProtoBookings(page), nil
+}
+
+// ownBooking loads a booking and checks that it belongs to the caller.
+func (s *bookingServer) ownBooking(ctx context.Context, id string) (*model.Booking, error) {
+    booking, err := s.svc.GetByID(ctx, id)
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "get booking failed", err, "booking_id", id)
+    }
+    if booking.UserID != userID(ctx) {
+        s.logger.WarnContext(ctx, "unauthorized access to booking", "booking_id", id)
+        return nil, status.Error(codes.PermissionDenied, "forbidden")
+    }
+    return booking, nil
+}
+
+func bookingFilter(req *libraryv1.ListBookingsRequest) model.BookingFilter {
+    f := model.BookingFilter{
+        Status: strings.ToUpper(strings.TrimSpace(req.GetStatus())),
+        UserID: strings.TrimSpace(req.GetUserId()),
+        BookID: strings.TrimSpace(req.GetBookId()),
+    }
+    if req.BorrowedFrom != nil {
+        from := req.GetBorrowedFrom().AsTime()
+        f.From = &from
+    }
+    if req.BorrowedTo != nil {
+        to := req.GetBorrowedTo().AsTime()
+        f.To = &to
+    }
+    return f
+}
+
+func bookingExpand(req *libraryv1.ListBookingsRequest) model.BookingExpand {
+    return model.BookingExpand{Book: req.GetExpandBook(), User: req.GetExpandUser()}
+}
@@ -0,0 +1,129 @@
+package grpcserver
+
+import (
+    "context"
+    "log/slog"
+    "strings"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/emptypb"
+)
+
+type bookServer struct {
+    libraryv1.UnimplementedBookServiceServer
+    svc    service.BookService
+    logger *slog.Logger
+}
+
+func (s *bookServer) ListBooks(ctx context.Context, req *libraryv1.ListBooksRequest) (*libraryv1.ListBooksResponse, error) {
+    page, err := s.svc.List(ctx, pageRequest(req.GetPage()), model.BookFilter{
+        Category: strings.TrimSpace(req.GetCategory()),
+        Tag:      strings.TrimSpace(req.GetTag()),
+    })
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "list books failed", err)
+    }
+
+    resp := &libraryv1.ListBooksResponse{
+        Books:         make([]*libraryv1.Book, 0, len(page.Items)),
+        Total:         int32(page.Total),
+        NextPageToken: page.NextCursor,
+    }
+    for i := range page.Items {
+        resp.Books = append(resp.Books, toProtoBook(&page.Items[i]))
+    }
+    return resp, nil
+}
+
+func (s *bookServer) GetBook(ctx context.Context, req *libraryv1.GetBookRequest) (*libraryv1.Book, error) {
+    book, err := s.svc.GetByID(ctx, req.GetId())
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "get book failed", err, "book_id", req.GetId())
+    }
+    return toProtoBook(&book), nil
+}
+
+func (s *bookServer) CreateBook(ctx context.Context, req *libraryv1.CreateBookRequest) (*libraryv1.Book, error) {
+    create := model.CreateBookRequest{
+        Title:         req.GetTitle(),
+        Author:        req.GetAuthor(),
+        PublishedYear: int(req.GetPublishedYear()),
+        ISBN:          req.GetIsbn(),
+        TotalCopies:   optionalInt(req.TotalCopies),
+        CategoryIDs:   req.GetCategoryIds(),
+        Tags:          req.GetTags(),
+    }
+    if err := validate(&create); err != nil {
+        return nil, err
+    }
+
+    book := &model.Book{
+        Title:         create.Title,
+        Author:        create.Author,
+        PublishedYear: create.PublishedYear,
+        ISBN:          create.ISBN,
+        TotalCopies:   create.Copies(),
+        Tags:          create.Tags,
+    }
+    for _, id := range create.CategoryIDs {
+        book.Categories = append(book.Categories, model.Category{ID: id})
+    }
+    if err := s.svc.Create(ctx, userID(ctx), book); err != nil {
+        return nil, serviceError(ctx, s.logger, "create book failed", err)
+    }
+    s.logger.InfoContext(ctx, "book created", "book_id", book.ID)
+    return toProtoBook(book), nil
+}
+
+// UpdateBook requires the version being replaced, like If-Match on
+// PUT /admin/books/{id}.
+func (s *bookServer) UpdateBook(ctx context.Context, req *libraryv1.UpdateBookRequest) (*libraryv1.Book, error) {
+    if req.GetVersion() < 1 {
+        return nil, status.Error(codes.InvalidArgument, "version is required")
+    }
+    update := model.UpdateBookRequest{
+        Title:         req.GetTitle(),
+        Author:        req.GetAuthor(),
+        PublishedYear: int(req.GetPublishedYear()),
+        ISBN:          req.GetIsbn(),
+        TotalCopies:   optionalInt(req.TotalCopies),
+    }
+    // Repeated fields can't say "absent", so explicit flags ask for a change.
+    if req.GetSetCategories() {
+        update.CategoryIDs = append([]string{}, req.GetCategoryIds()...)
+    }
+    if req.GetSetTags() {
+        update.Tags = append([]string{}, req.GetTags()...)
+    }
+    if err := validate(&update); err != nil {
+        return nil, err
+    }
+
+    book, err := s.svc.Update(ctx, userID(ctx), req.GetId(), model.UpdateBookPatch{
+        Title:         &update.Title,
+        Author:        &update.Author,
+        PublishedYear: &update.PublishedYear,
+        ISBN:          &update.ISBN,
+        TotalCopies:   update.TotalCopies,
+        Tags:          update.Tags,
+        CategoryIDs:   update.CategoryIDs,
+        Version:       model.Ptr(int(req.GetVersion())),
+    })
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "update book failed", err, "book_id", req.GetId())
+    }
+    s.logger.InfoContext(ctx, "book updated", "book_id", req.GetId())
+    return toProtoBook(book), nil
+}
+
+func (s *bookServer) DeleteBook(ctx context.Context, req *libraryv1.DeleteBookRequest) (*emptypb.Empty, error) {
+    if err := s.svc.Delete(ctx, userID(ctx), req.GetId()); err != nil {
+        return nil, serviceError(ctx, s.logger, "delete book failed", err, "book_id", req.GetId())
+    }
+    s.logger.InfoContext(ctx, "book deleted", "book_id", req.GetId())
+    return &emptypb.Empty{}, nil
+}
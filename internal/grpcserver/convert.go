@@ -0,0 +1,178 @@
+package grpcserver
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "sort"
+    "strings"
+    "time"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/handler"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+    defaultPageSize = 20
+    maxPageSize     = 100
+)
+
+// codeForError maps a service-layer error to a gRPC status code, the
+// counterpart of handler.StatusForError.
+func codeForError(err error) codes.Code {
+    switch {
+    case errors.Is(err, apperr.ErrNotFound):
+        return codes.NotFound
+    case errors.Is(err, apperr.ErrConflict):
+        return codes.AlreadyExists
+    case errors.Is(err, apperr.ErrForbidden):
+        return codes.PermissionDenied
+    case errors.Is(err, apperr.ErrValidation):
+        return codes.InvalidArgument
+    case errors.Is(err, apperr.ErrPreconditionFailed), errors.Is(err, apperr.ErrPolicyViolation):
+        return codes.FailedPrecondition
+    case errors.Is(err, apperr.ErrLocked):
+        return codes.ResourceExhausted
+    case errors.Is(err, apperr.ErrUpstream):
+        return codes.Unavailable
+    default:
+        return codes.Internal
+    }
+}
+
+// serviceError logs a failed service call and converts it to a status.
+// Typed errors keep their message; anything else becomes a generic Internal
+// error so details don't leak.
+func serviceError(ctx context.Context, logger *slog.Logger, msg string, err error, args ...any) error {
+    code := codeForError(err)
+    level := slog.LevelWarn
+    if code == codes.Internal || code == codes.Unavailable {
+        level = slog.LevelError
+    }
+    logger.Log(ctx, level, msg, append(args, "error", err)...)
+
+    if code == codes.Internal {
+        return status.Error(code, "internal error")
+    }
+    return status.Error(code, err.Error())
+}
+
+// validate runs the REST request validation on req and reports failures as
+// one InvalidArgument status, fields in a stable order.
+func validate(req interface{}) error {
+    errs := handler.Validate(req)
+    if len(errs) == 0 {
+        return nil
+    }
+    msgs := make([]string, 0, len(errs))
+    for _, msg := range errs {
+        msgs = append(msgs, msg)
+    }
+    sort.Strings(msgs)
+    return status.Error(codes.InvalidArgument, strings.Join(msgs, "; "))
+}
+
+func pageRequest(p *libraryv1.PageRequest) model.PageRequest {
+    req := model.PageRequest{Limit: defaultPageSize}
+    if size := p.GetPageSize(); size > 0 && size <= maxPageSize {
+        req.Limit = int(size)
+    }
+    req.Cursor = p.GetPageToken()
+    return req
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+    if t.IsZero() {
+        return nil
+    }
+    return timestamppb.New(t)
+}
+
+func toProtoBook(b *model.Book) *libraryv1.Book {
+    if b == nil {
+        return nil
+    }
+    return &libraryv1.Book{
+        Id:              b.ID,
+        Title:           b.Title,
+        Author:          b.Author,
+        PublishedYear:   int32(b.PublishedYear),
+        Isbn:            b.ISBN,
+        TotalCopies:     int32(b.TotalCopies),
+        CopiesAvailable: int32(b.CopiesAvailable),
+        Available:       b.Available,
+        Version:         int32(b.Version),
+        CreatedAt:       timestamp(b.CreatedAt),
+        UpdatedAt:       timestamp(b.UpdatedAt),
+        CoverUrl:        b.CoverURL,
+        Categories:      toProtoCategories(b.Categories),
+        Tags:            b.Tags,
+    }
+}
+
+func toProtoCategories(cs []model.Category) []*libraryv1.Category {
+    out := make([]*libraryv1.Category, 0, len(cs))
+    for _, c := range cs {
+        out = append(out, &libraryv1.Category{Id: c.ID, Name: c.Name, Description: c.Description})
+    }
+    return out
+}
+
+func toProtoUser(u *model.User) *libraryv1.User {
+    if u == nil {
+        return nil
+    }
+    return &libraryv1.User{
+        Id:        u.ID,
+        Username:  u.Username,
+        Email:     u.Email,
+        Role:      string(u.Role),
+        CreatedAt: timestamp(u.CreatedAt),
+        UpdatedAt: timestamp(u.UpdatedAt),
+    }
+}
+
+func toProtoBooking(b *model.Booking) *libraryv1.Booking {
+    pb := &libraryv1.Booking{
+        Id:         b.ID,
+        UserId:     b.UserID,
+        BookId:     b.BookID,
+        BorrowedAt: timestamp(b.BorrowedAt),
+        DueDate:    timestamp(b.DueDate),
+        Status:     b.Status,
+        CreatedAt:  timestamp(b.CreatedAt),
+        UpdatedAt:  timestamp(b.UpdatedAt),
+        Book:       toProtoBook(b.Book),
+        User:       toProtoUser(b.User),
+    }
+    if b.ReturnedAt != nil {
+        pb.ReturnedAt = timestamp(*b.ReturnedAt)
+    }
+    return pb
+}
+
+func toProtoBookings(page model.Page[model.Booking]) *libraryv1.ListBookingsResponse {
+    resp := &libraryv1.ListBookingsResponse{
+        Bookings:      make([]*libraryv1.Booking, 0, len(page.Items)),
+        Total:         int32(page.Total),
+        NextPageToken: page.NextCursor,
+    }
+    for i := range page.Items {
+        resp.Bookings = append(resp.Bookings, toProtoBooking(&page.Items[i]))
+    }
+    return resp
+}
+
+// optionalInt converts a proto3 optional int32 to the *int used by requests.
+func optionalInt(v *int32) *int {
+    if v == nil {
+        return nil
+    }
+    n := int(*v)
+    return &n
+}
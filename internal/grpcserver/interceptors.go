@@ -0,0 +1,173 @@
+package grpcserver
+
+import (
+    "context"
+    "log/slog"
+    "net"
+    "runtime/debug"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/impersonator"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/peer"
+    "google.golang.org/grpc/status"
+)
+
+const requestIDHeader = "x-request-id"
+
+// publicMethods can be called without a token, like GET /books.
+var publicMethods = map[string]bool{
+    libraryv1.BookService_ListBooks_FullMethodName: true,
+}
+
+// adminMethods mirror the /admin routes of the REST API.
+var adminMethods = map[string]bool{
+    libraryv1.BookService_CreateBook_FullMethodName:      true,
+    libraryv1.BookService_UpdateBook_FullMethodName:      true,
+    libraryv1.BookService_DeleteBook_FullMethodName:      true,
+    libraryv1.UserService_ListUsers_FullMethodName:       true,
+    libraryv1.UserService_GetUser_FullMethodName:         true,
+    libraryv1.BookingService_ListBookings_FullMethodName: true,
+}
+
+type contextKey string
+
+const (
+    userIDKey contextKey = "user_id"
+    roleKey   contextKey = "role"
+)
+
+func userID(ctx context.Context) string {
+    id, _ := ctx.Value(userIDKey).(string)
+    return id
+}
+
+// requestIDInterceptor takes the request ID from x-request-id metadata (or
+// generates one), echoes it in the response header and attaches it, with the
+// caller's address, to every log line written for the call. The address is
+// also kept for audit records, as the HTTP RealIPMiddleware does.
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    requestID := firstMetadata(ctx, requestIDHeader)
+    if requestID == "" {
+        requestID = uuid.New().String()
+    }
+    _ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+    ctx = requestid.With(ctx, requestID)
+    ctx = logger.WithAttrs(ctx, "request_id", requestID)
+    if p, ok := peer.FromContext(ctx); ok {
+        ip := p.Addr.String()
+        if host, _, err := net.SplitHostPort(ip); err == nil {
+            ip = host
+        }
+        ip = clientip.Normalize(ip)
+        ctx = clientip.With(ctx, ip)
+        logger.AddAttrs(ctx, "client_ip", ip)
+    }
+    return handler(ctx, req)
+}
+
+// loggingInterceptor writes one access log line per call, the gRPC
+// counterpart of the HTTP "request" entry.
+func loggingInterceptor(reqLogger *slog.Logger) grpc.UnaryServerInterceptor {
+    return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+        start := time.Now()
+        resp, err := handler(ctx, req)
+        code := status.Code(err)
+
+        attrs := []slog.Attr{
+            slog.String("method", info.FullMethod),
+            slog.String("code", code.String()),
+            slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+        }
+        if p, ok := peer.FromContext(ctx); ok {
+            attrs = append(attrs, slog.String("remote_addr", p.Addr.String()))
+        }
+        reqLogger.LogAttrs(ctx, accessLogLevel(code), "grpc request", attrs...)
+        return resp, err
+    }
+}
+
+func accessLogLevel(code codes.Code) slog.Level {
+    switch code {
+    case codes.OK:
+        return slog.LevelInfo
+    case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+        return slog.LevelError
+    }
+    return slog.LevelWarn
+}
+
+// recoveryInterceptor turns a panic in a handler into an Internal error
+// instead of taking the whole process down.
+func recoveryInterceptor(reqLogger *slog.Logger) grpc.UnaryServerInterceptor {
+    return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+        defer func() {
+            if p := recover(); p != nil {
+                reqLogger.ErrorContext(ctx, "panic in grpc handler", "method", info.FullMethod, "panic", p, "stack", string(debug.Stack()))
+                err = status.Error(codes.Internal, "internal error")
+            }
+        }()
+        return handler(ctx, req)
+    }
+}
+
+// authInterceptor validates the bearer token in the authorization metadata
+// and stores the caller's user ID and role in the context. Tokens of users
+// scoped to a branch scope the call to it.
+func authInterceptor(authSvc service.AuthService) grpc.UnaryServerInterceptor {
+    return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+        if publicMethods[info.FullMethod] {
+            return handler(ctx, req)
+        }
+
+        token, ok := strings.CutPrefix(firstMetadata(ctx, "authorization"), "Bearer ")
+        if !ok || token == "" {
+            return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+        }
+        claims, err := authSvc.ValidateToken(ctx, token)
+        if err != nil {
+            return nil, status.Error(codes.Unauthenticated, "invalid token")
+        }
+
+        role, _ := claims["role"].(string)
+        if adminMethods[info.FullMethod] && model.Role(role) != model.RoleAdmin {
+            return nil, status.Error(codes.PermissionDenied, "admin access required")
+        }
+
+        ctx = context.WithValue(ctx, userIDKey, claims["user_id"])
+        ctx = context.WithValue(ctx, roleKey, role)
+        logger.AddAttrs(ctx, "user_id", claims["user_id"])
+        // Calls are scoped like REST requests made with the same token.
+        if branchID, _ := claims["branch_id"].(string); branchID != "" {
+            ctx = tenant.WithBranch(ctx, branchID)
+            logger.AddAttrs(ctx, "branch_id", branchID)
+        }
+        if adminID, _ := claims["impersonator_id"].(string); adminID != "" {
+            ctx = impersonator.With(ctx, adminID)
+            logger.AddAttrs(ctx, "impersonator_id", adminID)
+        }
+        return handler(ctx, req)
+    }
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+    md, ok := metadata.FromIncomingContext(ctx)
+    if !ok {
+        return ""
+    }
+    if v := md.Get(key); len(v) > 0 {
+        return v[0]
+    }
+    return ""
+}
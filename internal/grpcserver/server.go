@@ -0,0 +1,38 @@
+// Package grpcserver serves the library API over gRPC (api/library/v1). It
+// sits next to the REST handlers and calls the same service layer, so both
+// transports share business rules, validation and error kinds.
+package grpcserver
+
+import (
+    "log/slog"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "google.golang.org/grpc"
+)
+
+// Services bundles the service layer the gRPC API is built on.
+type Services struct {
+    Books    service.BookService
+    Users    service.UserService
+    Bookings service.BookingService
+    Auth     service.AuthService
+}
+
+// New returns a gRPC server with the book, user and booking services
+// registered. Every call passes through request ID, logging, panic recovery
+// and auth interceptors, in that order.
+func New(svcs Services, logger *slog.Logger, opts ...grpc.ServerOption) *grpc.Server {
+    opts = append(opts, grpc.ChainUnaryInterceptor(
+        requestIDInterceptor,
+        loggingInterceptor(logger),
+        recoveryInterceptor(logger),
+        authInterceptor(svcs.Auth),
+    ))
+    s := grpc.NewServer(opts...)
+
+    libraryv1.RegisterBookServiceServer(s, &bookServer{svc: svcs.Books, logger: logger})
+    libraryv1.RegisterUserServiceServer(s, &userServer{svc: svcs.Users, logger: logger})
+    libraryv1.RegisterBookingServiceServer(s, &bookingServer{svc: svcs.Bookings, logger: logger})
+    return s
+}
@@ -0,0 +1,170 @@
+package grpcserver
+
+import (
+    "context"
+    "net"
+    "testing"
+    "time"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/stretchr/testify/require"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/grpc/test/bufconn"
+)
+
+// Only the methods a test sets are implemented; calling any other panics
+// through the nil embedded interface.
+type mockBookService struct {
+    service.BookService
+    listFn   func(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
+    createFn func(ctx context.Context, b *model.Book) error
+    updateFn func(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error)
+}
+
+func (m *mockBookService) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+    return m.listFn(ctx, p, f)
+}
+
+func (m *mockBookService) Create(ctx context.Context, _ string, b *model.Book) error {
+    return m.createFn(ctx, b)
+}
+
+func (m *mockBookService) Update(ctx context.Context, _, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    return m.updateFn(ctx, id, p)
+}
+
+type mockBookingService struct {
+    service.BookingService
+    getByIDFn func(ctx context.Context, id string) (*model.Booking, error)
+    returnFn  func(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error)
+}
+
+func (m *mockBookingService) GetByID(ctx context.Context, id string) (*model.Booking, error) {
+    return m.getByIDFn(ctx, id)
+}
+
+func (m *mockBookingService) Return(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error) {
+    return m.returnFn(ctx, userID, bookingID, asAdmin)
+}
+
+var testAuth = service.NewAuthService([]service.SigningKey{{ID: "test", Secret: []byte("0123456789abcdef0123456789abcdef")}}, time.Hour, service.TokenPolicy{}, nil, nil, 0)
+
+func newTestConn(t *testing.T, svcs Services) *grpc.ClientConn {
+    t.Helper()
+    svcs.Auth = testAuth
+    lis := bufconn.Listen(1 << 20)
+    srv := New(svcs, logger.Discard())
+    go func() { _ = srv.Serve(lis) }()
+    t.Cleanup(srv.Stop)
+
+    conn, err := grpc.NewClient("passthrough:///bufnet",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+        grpc.WithTransportCredentials(insecure.NewCredentials()))
+    require.NoError(t, err)
+    t.Cleanup(func() { _ = conn.Close() })
+    return conn
+}
+
+func withToken(t *testing.T, userID string, role model.Role) context.Context {
+    t.Helper()
+    token, _, err := testAuth.GenerateToken(userID, "someone", role, "")
+    require.NoError(t, err)
+    return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestListBooks_PublicAndEchoesRequestID(t *testing.T) {
+    books := &mockBookService{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            require.Equal(t, 5, p.Limit)
+            require.Equal(t, "next", p.Cursor)
+            return model.Page[model.Book]{Items: []model.Book{{ID: "b1", Title: "Dune", TotalCopies: 2, CopiesAvailable: 1, Available: true}}, Total: 1}, nil
+        },
+    }
+    client := libraryv1.NewBookServiceClient(newTestConn(t, Services{Books: books}))
+
+    ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-123")
+    var header metadata.MD
+    resp, err := client.ListBooks(ctx, &libraryv1.ListBooksRequest{Page: &libraryv1.PageRequest{PageSize: 5, PageToken: "next"}}, grpc.Header(&header))
+    require.NoError(t, err)
+    require.Len(t, resp.Books, 1)
+    require.Equal(t, "Dune", resp.Books[0].Title)
+    require.Equal(t, int32(1), resp.Books[0].CopiesAvailable)
+    require.Equal(t, []string{"req-123"}, header.Get("x-request-id"))
+}
+
+func TestAuth_MissingTokenAndNonAdmin(t *testing.T) {
+    client := libraryv1.NewBookServiceClient(newTestConn(t, Services{Books: &mockBookService{}}))
+
+    _, err := client.GetBook(context.Background(), &libraryv1.GetBookRequest{Id: "b1"})
+    require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+    _, err = client.CreateBook(withToken(t, "u1", "user"), &libraryv1.CreateBookRequest{Title: "Dune", Author: "Herbert"})
+    require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestCreateBook_ValidatesLikeREST(t *testing.T) {
+    var created *model.Book
+    books := &mockBookService{
+        createFn: func(_ context.Context, b *model.Book) error {
+            b.ID = "b1"
+            created = b
+            return nil
+        },
+    }
+    client := libraryv1.NewBookServiceClient(newTestConn(t, Services{Books: books}))
+    ctx := withToken(t, "admin-1", "admin")
+
+    _, err := client.CreateBook(ctx, &libraryv1.CreateBookRequest{Title: "  ", Author: "Herbert"})
+    require.Equal(t, codes.InvalidArgument, status.Code(err))
+    require.Contains(t, status.Convert(err).Message(), "title is required")
+
+    book, err := client.CreateBook(ctx, &libraryv1.CreateBookRequest{Title: " Dune ", Author: "Herbert"})
+    require.NoError(t, err)
+    require.Equal(t, "b1", book.Id)
+    require.Equal(t, "Dune", created.Title)
+    require.Equal(t, 1, created.TotalCopies)
+}
+
+func TestUpdateBook_VersionRequiredAndStale(t *testing.T) {
+    books := &mockBookService{
+        updateFn: func(_ context.Context, _ string, p model.UpdateBookPatch) (*model.Book, error) {
+            require.Equal(t, 3, *p.Version)
+            return nil, apperr.PreconditionFailed("book was modified by another request")
+        },
+    }
+    client := libraryv1.NewBookServiceClient(newTestConn(t, Services{Books: books}))
+    ctx := withToken(t, "admin-1", "admin")
+
+    _, err := client.UpdateBook(ctx, &libraryv1.UpdateBookRequest{Id: "b1", Title: "Dune", Author: "Herbert"})
+    require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+    _, err = client.UpdateBook(ctx, &libraryv1.UpdateBookRequest{Id: "b1", Title: "Dune", Author: "Herbert", Version: 3})
+    require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestReturn_OwnBookingOnlyAndHidesInternalErrors(t *testing.T) {
+    bookings := &mockBookingService{
+        returnFn: func(_ context.Context, userID, _ string, asAdmin bool) (*model.Booking, error) {
+            if asAdmin || userID != "u1" {
+                return nil, apperr.Forbidden("this booking belongs to another user")
+            }
+            return nil, context.DeadlineExceeded
+        },
+    }
+    client := libraryv1.NewBookingServiceClient(newTestConn(t, Services{Bookings: bookings}))
+
+    _, err := client.Return(withToken(t, "u2", "user"), &libraryv1.ReturnRequest{BookingId: "bk1"})
+    require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+    _, err = client.Return(withToken(t, "u1", "user"), &libraryv1.ReturnRequest{BookingId: "bk1"})
+    require.Equal(t, codes.Internal, status.Code(err))
+    require.Equal(t, "internal error", status.Convert(err).Message())
+}
@@ -0,0 +1,49 @@
+package grpcserver
+
+import (
+    "context"
+    "log/slog"
+
+    libraryv1 "github.com/praveen-anandh-jeyaraman/digicert/api/library/v1"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "google.golang.org/protobuf/types/known/emptypb"
+)
+
+type userServer struct {
+    libraryv1.UnimplementedUserServiceServer
+    svc    service.UserService
+    logger *slog.Logger
+}
+
+func (s *userServer) GetMe(ctx context.Context, _ *emptypb.Empty) (*libraryv1.User, error) {
+    user, err := s.svc.GetByID(ctx, userID(ctx))
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "get profile failed", err)
+    }
+    return toProtoUser(user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *libraryv1.ListUsersRequest) (*libraryv1.ListUsersResponse, error) {
+    page, err := s.svc.List(ctx, pageRequest(req.GetPage()))
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "list users failed", err)
+    }
+
+    resp := &libraryv1.ListUsersResponse{
+        Users:         make([]*libraryv1.User, 0, len(page.Items)),
+        Total:         int32(page.Total),
+        NextPageToken: page.NextCursor,
+    }
+    for i := range page.Items {
+        resp.Users = append(resp.Users, toProtoUser(&page.Items[i]))
+    }
+    return resp, nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *libraryv1.GetUserRequest) (*libraryv1.User, error) {
+    user, err := s.svc.GetByID(ctx, req.GetId())
+    if err != nil {
+        return nil, serviceError(ctx, s.logger, "get user failed", err, "target_user_id", req.GetId())
+    }
+    return toProtoUser(user), nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type AccountHandler struct {
+    svc    service.AccountService
+    logger *slog.Logger
+}
+
+func NewAccountHandler(svc service.AccountService, logger *slog.Logger) *AccountHandler {
+    return &AccountHandler{svc: svc, logger: logger}
+}
+
+// DeleteMe godoc
+// @Summary      Delete my account
+// @Description  Delete the current user's account and revoke their tokens. Refused
+// @Description  while they have books out; accounts with booking history are
+// @Description  anonymized rather than removed.
+// @Tags         Users
+// @Security     BearerAuth
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /users/me [delete]
+func (h *AccountHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    if err := h.svc.DeleteAccount(r.Context(), userID); err != nil {
+        logServiceError(r.Context(), h.logger, "delete account failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to delete account")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "account deleted by owner")
+}
+
+// Impersonate godoc
+// @Summary      Impersonate a user
+// @Description  Get a short-lived token acting as the user, for support. Its claims name the admin
+// @Description  (impersonator_id, impersonator) and carry a banner for clients to show; audit entries
+// @Description  made with it record both the user and the admin. It lasts IMPERSONATION_TTL, can't be
+// @Description  refreshed and stops working when the admin's tokens are revoked. Admins can't be
+// @Description  impersonated, and admins scoped to a branch can only impersonate users of their branch.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id   path      string  true  "User ID"
+// @Produce      json
+// @Success      200  {object}  model.ImpersonationResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /admin/users/{id}/impersonate [post]
+func (h *AccountHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+    resp, err := h.svc.Impersonate(r.Context(), GetUserID(r.Context()), chi.URLParam(r, "id"))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "impersonate user failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to impersonate user")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, resp)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type AnnouncementHandler struct {
+    svc    service.AnnouncementService
+    logger *slog.Logger
+}
+
+func NewAnnouncementHandler(svc service.AnnouncementService, logger *slog.Logger) *AnnouncementHandler {
+    return &AnnouncementHandler{svc: svc, logger: logger}
+}
+
+// Active godoc
+// @Summary      Current announcements
+// @Description  The announcements client apps should show now, latest first: notices such as
+// @Description  planned maintenance or policy changes. Anyone may ask; admins who send their
+// @Description  token also get the announcements meant for admins only.
+// @Tags         Announcements
+// @Produce      json
+// @Success      200  {array}   model.Announcement
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /announcements [get]
+func (h *AnnouncementHandler) Active(w http.ResponseWriter, r *http.Request) {
+    claims, _ := ClaimsFromContext(r.Context())
+    announcements, err := h.svc.Active(r.Context(), claims.IsAdmin())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list active announcements failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list announcements")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, announcements)
+}
+
+// List godoc
+// @Summary      List announcements
+// @Description  Every announcement, past, current and scheduled, newest first
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.Announcement
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/announcements [get]
+func (h *AnnouncementHandler) List(w http.ResponseWriter, r *http.Request) {
+    announcements, err := h.svc.List(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list announcements failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list announcements")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, announcements)
+}
+
+// Get godoc
+// @Summary      Get an announcement
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Announcement ID"
+// @Produce      json
+// @Success      200  {object}  model.Announcement
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/announcements/{id} [get]
+func (h *AnnouncementHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    a, err := h.svc.Get(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get announcement failed", err, "announcement_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get announcement")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, a)
+}
+
+// Create godoc
+// @Summary      Publish an announcement
+// @Description  Publish a notice for client apps to show from starts_at (or at once) until
+// @Description  ends_at (or until it is removed), to everyone or, with audience "admins",
+// @Description  to admins only.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.AnnouncementRequest  true  "Announcement"
+// @Produce      json
+// @Success      201  {object}  model.Announcement
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/announcements [post]
+func (h *AnnouncementHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.AnnouncementRequest](w, r)
+    if !ok {
+        return
+    }
+
+    a, err := h.svc.Create(r.Context(), GetUserID(r.Context()), &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create announcement failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create announcement")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, a)
+}
+
+// Update godoc
+// @Summary      Update an announcement
+// @Description  Replace an announcement's text, audience and schedule
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                     true  "Announcement ID"
+// @Param        request  body  model.AnnouncementRequest  true  "Announcement"
+// @Produce      json
+// @Success      200  {object}  model.Announcement
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/announcements/{id} [put]
+func (h *AnnouncementHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    req, ok := Bind[model.AnnouncementRequest](w, r)
+    if !ok {
+        return
+    }
+
+    a, err := h.svc.Update(r.Context(), id, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "update announcement failed", err, "announcement_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to update announcement")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, a)
+}
+
+// Delete godoc
+// @Summary      Remove an announcement
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Announcement ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/announcements/{id} [delete]
+func (h *AnnouncementHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Delete(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete announcement failed", err, "announcement_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete announcement")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
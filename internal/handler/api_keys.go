@@ -0,0 +1,91 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type APIKeyHandler struct {
+    svc    service.APIKeyService
+    logger *slog.Logger
+}
+
+func NewAPIKeyHandler(svc service.APIKeyService, logger *slog.Logger) *APIKeyHandler {
+    return &APIKeyHandler{svc: svc, logger: logger}
+}
+
+// Create godoc
+// @Summary      Issue an API key
+// @Description  Issue a key a machine client sends in X-API-Key instead of a JWT. It acts
+// @Description  as user_id (the caller when omitted); a "read" key may only make GET
+// @Description  requests, a "write" key any request. The key is only shown in this response.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.CreateAPIKeyRequest  true  "Key"
+// @Produce      json
+// @Success      201  {object}  model.CreateAPIKeyResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/api-keys [post]
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.CreateAPIKeyRequest](w, r)
+    if !ok {
+        return
+    }
+
+    k, key, err := h.svc.Create(r.Context(), GetUserID(r.Context()), &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create api key failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create API key")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, model.CreateAPIKeyResponse{APIKey: *k, Key: key})
+}
+
+// List godoc
+// @Summary      List API keys
+// @Description  List every API key, revoked and expired ones included, newest first
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.APIKey
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/api-keys [get]
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+    keys, err := h.svc.List(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list api keys failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list API keys")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, keys)
+}
+
+// Revoke godoc
+// @Summary      Revoke an API key
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "API key ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Revoke(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "revoke api key failed", err, "api_key_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to revoke API key")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "api key revoked", "api_key_id", id)
+}
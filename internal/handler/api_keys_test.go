@@ -0,0 +1,87 @@
+package handler
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/stretchr/testify/require"
+)
+
+type mockAPIKeyService struct {
+    createFn       func(ctx context.Context, createdBy string, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error)
+    authenticateFn func(ctx context.Context, key string) (*model.APIKey, *model.User, error)
+}
+
+func (m *mockAPIKeyService) Create(ctx context.Context, createdBy string, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error) {
+    return m.createFn(ctx, createdBy, req)
+}
+
+func (m *mockAPIKeyService) List(ctx context.Context) ([]model.APIKey, error) {
+    return nil, nil
+}
+
+func (m *mockAPIKeyService) Revoke(ctx context.Context, id string) error {
+    return nil
+}
+
+func (m *mockAPIKeyService) Authenticate(ctx context.Context, key string) (*model.APIKey, *model.User, error) {
+    return m.authenticateFn(ctx, key)
+}
+
+func TestAPIKeyHandler_CreateShowsKeyOnce(t *testing.T) {
+    var gotBy string
+    svc := &mockAPIKeyService{createFn: func(_ context.Context, createdBy string, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error) {
+        gotBy = createdBy
+        return &model.APIKey{ID: "key-1", Name: req.Name, Prefix: "lk_abcdefgh", UserID: createdBy, Scopes: req.Scopes}, "lk_abcdefghsecret", nil
+    }}
+    h := NewAPIKeyHandler(svc, logger.Discard())
+
+    body, _ := json.Marshal(model.CreateAPIKeyRequest{Name: "catalog sync", Scopes: []string{"read"}})
+    req := httptest.NewRequest("POST", "/admin/api-keys", bytes.NewReader(body))
+    req = req.WithContext(WithClaims(req.Context(), AuthContext{UserID: "admin-1", Role: "admin"}))
+    rec := httptest.NewRecorder()
+    h.Create(rec, req)
+
+    require.Equal(t, http.StatusCreated, rec.Code)
+    require.Equal(t, "admin-1", gotBy)
+    var resp map[string]any
+    require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+    require.Equal(t, "lk_abcdefghsecret", resp["key"])
+    require.Equal(t, "lk_abcdefgh", resp["prefix"])
+    require.NotContains(t, resp, "Hash")
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+    keys := &mockAPIKeyService{authenticateFn: func(_ context.Context, key string) (*model.APIKey, *model.User, error) {
+        if key != "lk_good" {
+            return nil, nil, service.ErrInvalidAPIKey
+        }
+        return &model.APIKey{ID: "key-1", UserID: "user-1", Scopes: []string{model.APIKeyScopeRead}},
+            &model.User{ID: "user-1", Username: "bot", Role: "user"}, nil
+    }}
+    var got AuthContext
+    h := AuthMiddleware(&mockAuthService{}, keys, CookieAuth{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got, _ = ClaimsFromContext(r.Context())
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    serve := func(method, key string) int {
+        req := httptest.NewRequest(method, "/bookings", nil)
+        req.Header.Set("X-API-Key", key)
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusNoContent, serve("GET", "lk_good"))
+    require.Equal(t, AuthContext{UserID: "user-1", Username: "bot", Role: "user", APIKeyID: "key-1"}, got)
+
+    require.Equal(t, http.StatusForbidden, serve("POST", "lk_good"), "a read key can't write")
+    require.Equal(t, http.StatusUnauthorized, serve("GET", "lk_bad"))
+}
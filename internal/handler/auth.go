@@ -1,30 +1,89 @@
 package handler
 
 import (
-    "encoding/json"
-    "log"
+    "errors"
+    "log/slog"
     "net/http"
+    "strconv"
+    "strings"
+    "time"
 
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
 )
 
 type AuthHandler struct {
-    authSvc service.AuthService
-    userSvc service.UserService
+    authSvc   service.AuthService
+    userSvc   service.UserService
+    logins    service.LoginHistoryService
+    ipLimit   *RateLimiter
+    userLimit *RateLimiter
+    cookies   CookieAuth
+    logger    *slog.Logger
 }
 
-func NewAuthHandler(authSvc service.AuthService, userSvc service.UserService) *AuthHandler {
-    return &AuthHandler{
+// LoginRateLimit caps login attempts, whatever their outcome, per client IP
+// and per username within each Period, on top of the global per-IP limit
+// and the lockout after failed logins. A zero limit disables that check.
+type LoginRateLimit struct {
+    PerIP       int
+    PerUsername int
+    Period      time.Duration
+}
+
+// NewAuthHandler serves logins, recording them in logins unless it is nil.
+// With cookies.Refresh set, logins keep a refresh token in that cookie and
+// answer with a short-lived access token.
+func NewAuthHandler(authSvc service.AuthService, userSvc service.UserService, logins service.LoginHistoryService, limits LoginRateLimit, cookies CookieAuth, logger *slog.Logger) *AuthHandler {
+    h := &AuthHandler{
         authSvc: authSvc,
         userSvc: userSvc,
+        logins:  logins,
+        cookies: cookies,
+        logger:  logger,
+    }
+    if limits.PerIP > 0 {
+        h.ipLimit = NewRateLimiterPer(limits.PerIP, limits.Period)
+    }
+    if limits.PerUsername > 0 {
+        h.userLimit = NewRateLimiterPer(limits.PerUsername, limits.Period)
+    }
+    return h
+}
+
+// loginAllowed applies the login rate limits to the attempt, answering it
+// with 429 and false when one is exceeded. username is "" before the body
+// has been read.
+func (h *AuthHandler) loginAllowed(w http.ResponseWriter, r *http.Request, username string) bool {
+    limiter, key := h.ipLimit, "ip:"+ClientIP(r)
+    if username != "" {
+        limiter, key = h.userLimit, "user:"+strings.ToLower(username)
+    }
+    if limiter == nil {
+        return true
+    }
+    ok, retry := limiter.allow(key)
+    if ok {
+        return true
+    }
+    h.logger.WarnContext(r.Context(), "login rate limit exceeded", "key", key)
+    if cwLogger := logger.GetLogger(); cwLogger != nil {
+        _ = cwLogger.PutMetric(r.Context(), "LoginRateLimited", 1, "Count")
     }
+    w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+    WriteError(r.Context(), w, http.StatusTooManyRequests, "Too many login attempts, try again later")
+    return false
 }
 
 // Login godoc
 // @Summary      Login user
-// @Description  Login with username and password
+// @Description  Login with username and password. Suspended accounts are refused with 403.
+// @Description  Attempts are rate limited per client IP and per username (429). With AUTH_MODE=cookie
+// @Description  the token is short-lived and an HttpOnly refresh cookie and a CSRF cookie are set.
 // @Tags         Auth
 // @Accept       json
 // @Param        request  body      model.LoginRequest  true  "Login credentials"
@@ -32,20 +91,43 @@ func NewAuthHandler(authSvc service.AuthService, userSvc service.UserService) *A
 // @Success      200  {object}  model.LoginResponse
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      423  {object}  ErrorResponse
+// @Header       423  {integer}  Retry-After  "Seconds until the lockout ends"
+// @Failure      429  {object}  ErrorResponse
+// @Header       429  {integer}  Retry-After  "Seconds until another attempt is allowed"
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    var req model.LoginRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    if !h.loginAllowed(w, r, "") {
+        return
+    }
+    req, ok := Bind[model.LoginRequest](w, r)
+    if !ok {
+        return
+    }
+    if !h.loginAllowed(w, r, req.Username) {
         return
     }
 
-    user, err := h.userSvc.ValidatePassword(r.Context(), req.Username, req.Password)
+    user, err := h.userSvc.Login(r.Context(), req.Username, req.Password, ClientIP(r))
     if err != nil {
-        log.Printf("[%s] Login failed: %v", requestID, err)
+        h.logger.WarnContext(r.Context(), "login failed", "username", req.Username, "error", err)
+
+        var locked *service.LockedError
+        if errors.As(err, &locked) {
+            if cwLogger := logger.GetLogger(); cwLogger != nil {
+                _ = cwLogger.PutMetric(r.Context(), "LoginLocked", 1, "Count")
+            }
+            retry := locked.RetryAfter(time.Now())
+            w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+            WriteError(r.Context(), w, http.StatusLocked, locked.Error())
+            return
+        }
+
+        if errors.Is(err, apperr.ErrForbidden) {
+            WriteError(r.Context(), w, http.StatusForbidden, "Account is suspended")
+            return
+        }
 
         // Track failed login
         cwLogger := logger.GetLogger()
@@ -56,58 +138,66 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    token, expiresAt, err := h.authSvc.GenerateToken(user.ID, user.Username, user.Role)
+    resp, err := h.cookies.startSession(w, r, h.authSvc, user)
     if err != nil {
-        log.Printf("[%s] Token generation failed: %v", requestID, err)
+        h.logger.ErrorContext(r.Context(), "token generation failed", "error", err)
         WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to generate token")
         return
     }
 
-    resp := model.LoginResponse{
-        Token:     token,
-        ExpiresAt: expiresAt,
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _ = json.NewEncoder(w).Encode(resp)
-    log.Printf("[%s] User logged in: %s (role: %s)", requestID, user.Username, user.Role)
+    recordLogin(r, h.logins, h.logger, user)
+    respond.JSON(r.Context(), w, http.StatusOK, resp)
+    h.logger.InfoContext(r.Context(), "user logged in", "username", user.Username, "role", user.Role)
 }
 
 // Refresh godoc
 // @Summary      Refresh token
-// @Description  Get a new token
+// @Description  Get a new token for the same session, extending it. Fails once the
+// @Description  session has been revoked, and for impersonation tokens. With AUTH_MODE=cookie
+// @Description  a request carrying the refresh cookie needs no body but must repeat the CSRF
+// @Description  cookie in X-CSRF-Token; both cookies are replaced.
 // @Tags         Auth
 // @Accept       json
-// @Param        request  body      model.RefreshRequest  true  "Current token"
+// @Param        request       body      model.RefreshRequest  false  "Current token, unless the refresh cookie is sent"
+// @Param        X-CSRF-Token  header    string                false  "The CSRF cookie's value, with the refresh cookie"
 // @Produce      json
 // @Success      200  {object}  model.LoginResponse
 // @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
 // @Router       /auth/refresh [post]
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
+    if refreshToken := h.cookies.refreshToken(r); refreshToken != "" {
+        h.refreshFromCookie(w, r, refreshToken)
+        return
+    }
 
-    var req model.RefreshRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.RefreshRequest](w, r)
+    if !ok {
         return
     }
 
-    claims, err := h.authSvc.ValidateToken(req.Token)
+    claims, err := h.authSvc.ValidateToken(r.Context(), req.Token)
     if err != nil {
-        log.Printf("[%s] Token validation failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusUnauthorized, "Invalid token")
+        writeTokenError(w, r, err)
         return
     }
 
-    userID := claims["user_id"].(string)
-    username := claims["username"].(string)
-    role := claims["role"].(string)
+    username, _ := claims["username"].(string)
 
-    token, expiresAt, err := h.authSvc.GenerateToken(userID, username, role)
+    token, expiresAt, err := h.authSvc.RefreshSession(r.Context(), claims, r.UserAgent(), ClientIP(r))
+    if errors.Is(err, apperr.ErrNotFound) {
+        h.logger.WarnContext(r.Context(), "session ended before refresh", "error", err)
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Invalid token")
+        return
+    }
+    if errors.Is(err, apperr.ErrForbidden) {
+        h.logger.WarnContext(r.Context(), "token refresh refused", "error", err)
+        WriteError(r.Context(), w, http.StatusForbidden, "Impersonation tokens can't be refreshed")
+        return
+    }
     if err != nil {
-        log.Printf("[%s] Token generation failed: %v", requestID, err)
+        h.logger.ErrorContext(r.Context(), "token generation failed", "error", err)
         WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to generate token")
         return
     }
@@ -117,7 +207,119 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
         ExpiresAt: expiresAt,
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(resp)
-    log.Printf("[%s] Token refreshed for user: %s", requestID, username)
-}
\ No newline at end of file
+    respond.JSON(r.Context(), w, http.StatusOK, resp)
+    h.logger.InfoContext(r.Context(), "token refreshed", "username", username)
+}
+
+// refreshFromCookie renews the access token of a refresh cookie, replacing
+// the cookie. A refresh token that is no longer good is removed.
+func (h *AuthHandler) refreshFromCookie(w http.ResponseWriter, r *http.Request, refreshToken string) {
+    if !h.cookies.cookieAllowed(w, r) {
+        return
+    }
+
+    pair, err := h.authSvc.RefreshWithToken(r.Context(), refreshToken, r.UserAgent(), ClientIP(r))
+    if errors.Is(err, apperr.ErrNotFound) {
+        h.logger.WarnContext(r.Context(), "session ended before refresh", "error", err)
+        h.cookies.clearCookies(w, r)
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Invalid token")
+        return
+    }
+    if err != nil {
+        if errors.Is(err, service.ErrTokenInvalid) || errors.Is(err, service.ErrTokenExpired) ||
+            errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrSessionRevoked) {
+            h.cookies.clearCookies(w, r)
+        }
+        writeTokenError(w, r, err)
+        return
+    }
+
+    h.cookies.setCookies(w, r, pair)
+    respond.JSON(r.Context(), w, http.StatusOK, model.LoginResponse{Token: pair.AccessToken, ExpiresAt: pair.AccessExpiresAt})
+    h.logger.InfoContext(r.Context(), "token refreshed from cookie")
+}
+
+// Logout godoc
+// @Summary      Log out
+// @Description  With AUTH_MODE=cookie, end the session of the refresh cookie and remove the
+// @Description  refresh and CSRF cookies. The request must repeat the CSRF cookie in X-CSRF-Token.
+// @Tags         Auth
+// @Param        X-CSRF-Token  header  string  false  "The CSRF cookie's value"
+// @Success      204
+// @Failure      403  {object}  ErrorResponse
+// @Router       /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+    refreshToken := h.cookies.refreshToken(r)
+    if refreshToken != "" {
+        if !h.cookies.cookieAllowed(w, r) {
+            return
+        }
+        if err := h.authSvc.EndSession(r.Context(), refreshToken); err != nil {
+            logServiceError(r.Context(), h.logger, "end session failed", err)
+            WriteServiceError(r.Context(), w, err, "Failed to log out")
+            return
+        }
+    }
+
+    h.cookies.clearCookies(w, r)
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "user logged out")
+}
+
+// ListSessions godoc
+// @Summary      List my sessions
+// @Description  List the current user's active sessions with the device and address each
+// @Description  login came from. The session of the calling token is marked current.
+// @Tags         Users
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.Session
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/sessions [get]
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+    claims, ok := ClaimsFromContext(r.Context())
+    if !ok {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    sessions, err := h.authSvc.ListSessions(r.Context(), claims.UserID, claims.SessionID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list sessions failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list sessions")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary      Revoke one of my sessions
+// @Description  Sign out one of the current user's sessions. Its tokens stop working
+// @Description  straight away on this instance and within SESSION_CACHE_TTL on others.
+// @Tags         Users
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Session ID"
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /users/me/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+    claims, ok := ClaimsFromContext(r.Context())
+    if !ok {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    id := chi.URLParam(r, "id")
+    if err := h.authSvc.RevokeSession(r.Context(), claims.UserID, id); err != nil {
+        logServiceError(r.Context(), h.logger, "revoke session failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to revoke session")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "session revoked", "session_id", id)
+}
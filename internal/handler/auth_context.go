@@ -0,0 +1,75 @@
+package handler
+
+import (
+    "context"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// AuthContext describes the caller, as AuthMiddleware read it from their
+// token.
+type AuthContext struct {
+    UserID   string
+    Username string
+    Role     model.Role
+    // BranchID is the branch the user is scoped to, "" for global users.
+    BranchID string
+    // SessionID is the session the token belongs to, "" for tokens issued
+    // before sessions were recorded.
+    SessionID string
+    // APIKeyID is the key the caller authenticated with, "" for JWTs.
+    APIKeyID string
+    // ExpiresAt is when the caller's token expires; zero for API keys.
+    ExpiresAt time.Time
+    // ImpersonatorID and Impersonator name the admin signed in as the
+    // user for support, "" when the user is acting themselves.
+    ImpersonatorID string
+    Impersonator   string
+}
+
+// IsAdmin reports whether the caller has the admin role.
+func (a AuthContext) IsAdmin() bool {
+    return a.Role == model.RoleAdmin
+}
+
+type authContextKey struct{}
+
+// WithClaims returns a copy of ctx carrying the caller's claims.
+func WithClaims(ctx context.Context, claims AuthContext) context.Context {
+    return context.WithValue(ctx, authContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the caller's claims; ok is false when the
+// request wasn't authenticated.
+func ClaimsFromContext(ctx context.Context) (claims AuthContext, ok bool) {
+    claims, ok = ctx.Value(authContextKey{}).(AuthContext)
+    return claims, ok
+}
+
+type recheckKey struct{}
+
+// withRecheck returns a copy of ctx carrying check, which authenticates the
+// caller's credential again.
+func withRecheck(ctx context.Context, check func(context.Context) error) context.Context {
+    return context.WithValue(ctx, recheckKey{}, check)
+}
+
+// recheckAuth authenticates the caller again with the credential
+// AuthMiddleware accepted, for connections that outlive their request. It
+// fails once the token is revoked, the session ended or the API key
+// withdrawn; without a credential to check it succeeds.
+func recheckAuth(ctx context.Context) error {
+    check, _ := ctx.Value(recheckKey{}).(func(context.Context) error)
+    if check == nil {
+        return nil
+    }
+    return check(ctx)
+}
+
+// GetUserID returns the caller's user ID, or "" when the request wasn't
+// authenticated.
+func GetUserID(ctx context.Context) string {
+    claims, _ := ClaimsFromContext(ctx)
+    return claims.UserID
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+    "crypto/subtle"
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+// csrfHeader carries the double-submit token: a request authenticated by a
+// cookie must repeat the CSRF cookie's value in it, which a cross-site page
+// can't, since it can't read the cookie.
+const csrfHeader = "X-CSRF-Token"
+
+// CookieAuth is how browsers authenticate with cookies. The zero value
+// reads tokens from the Authorization header only and returns them in
+// response bodies.
+type CookieAuth struct {
+    // Token names a cookie AuthMiddleware also reads access tokens from.
+    Token string
+    // Refresh names the HttpOnly cookie logins put a refresh token in.
+    // When set, logins answer with a short-lived access token, which
+    // POST /auth/refresh renews from the cookie.
+    Refresh string
+    // CSRF names the cookie holding the double-submit token. When set,
+    // requests authenticated by a cookie must repeat it in X-CSRF-Token;
+    // otherwise the token cookie only needs X-Requested-With.
+    CSRF string
+}
+
+// startSession logs u in, setting the refresh and CSRF cookies when
+// refresh cookies are on.
+func (c CookieAuth) startSession(w http.ResponseWriter, r *http.Request, authSvc service.AuthService, u *model.User) (model.LoginResponse, error) {
+    if c.Refresh == "" {
+        token, expiresAt, err := authSvc.StartSession(r.Context(), u, r.UserAgent(), ClientIP(r))
+        return model.LoginResponse{Token: token, ExpiresAt: expiresAt}, err
+    }
+    pair, err := authSvc.StartRefreshableSession(r.Context(), u, r.UserAgent(), ClientIP(r))
+    if err != nil {
+        return model.LoginResponse{}, err
+    }
+    c.setCookies(w, r, pair)
+    return model.LoginResponse{Token: pair.AccessToken, ExpiresAt: pair.AccessExpiresAt}, nil
+}
+
+// setCookies stores pair's refresh token, with a new CSRF token to go with
+// it. Both are SameSite=Strict, so browsers don't send them cross-site.
+func (c CookieAuth) setCookies(w http.ResponseWriter, r *http.Request, pair *service.TokenPair) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     c.Refresh,
+        Value:    pair.RefreshToken,
+        Path:     "/",
+        Expires:  pair.RefreshExpiresAt,
+        HttpOnly: true,
+        Secure:   isHTTPS(r),
+        SameSite: http.SameSiteStrictMode,
+    })
+    if c.CSRF != "" {
+        http.SetCookie(w, &http.Cookie{
+            Name: c.CSRF,
+            // Not HttpOnly: the client reads it to send it back in
+            // X-CSRF-Token.
+            Value:    randomToken(),
+            Path:     "/",
+            Expires:  pair.RefreshExpiresAt,
+            Secure:   isHTTPS(r),
+            SameSite: http.SameSiteStrictMode,
+        })
+    }
+}
+
+// clearCookies removes the refresh and CSRF cookies, if refresh cookies
+// are on.
+func (c CookieAuth) clearCookies(w http.ResponseWriter, r *http.Request) {
+    if c.Refresh == "" {
+        return
+    }
+    http.SetCookie(w, &http.Cookie{Name: c.Refresh, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r), SameSite: http.SameSiteStrictMode})
+    if c.CSRF != "" {
+        http.SetCookie(w, &http.Cookie{Name: c.CSRF, Path: "/", MaxAge: -1, Secure: isHTTPS(r), SameSite: http.SameSiteStrictMode})
+    }
+}
+
+// refreshToken returns the refresh token in r's cookie, or "".
+func (c CookieAuth) refreshToken(r *http.Request) string {
+    if c.Refresh == "" {
+        return ""
+    }
+    cookie, err := r.Cookie(c.Refresh)
+    if err != nil {
+        return ""
+    }
+    return cookie.Value
+}
+
+// validCSRF reports whether r repeats its CSRF cookie in X-CSRF-Token.
+func (c CookieAuth) validCSRF(r *http.Request) bool {
+    cookie, err := r.Cookie(c.CSRF)
+    if err != nil || cookie.Value == "" {
+        return false
+    }
+    return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.Header.Get(csrfHeader))) == 1
+}
+
+// cookieAllowed reports whether an unsafe request authenticated by a cookie
+// carries the proof that it comes from the API's own client, answering it
+// with 403 when it doesn't.
+func (c CookieAuth) cookieAllowed(w http.ResponseWriter, r *http.Request) bool {
+    if c.CSRF != "" {
+        if c.validCSRF(r) {
+            return true
+        }
+        slog.WarnContext(r.Context(), "cookie token without matching CSRF token", "method", r.Method)
+        WriteError(r.Context(), w, http.StatusForbidden, csrfHeader+" header must match the CSRF cookie")
+        return false
+    }
+    if r.Header.Get("X-Requested-With") != "" {
+        return true
+    }
+    slog.WarnContext(r.Context(), "cookie token without X-Requested-With", "method", r.Method)
+    WriteError(r.Context(), w, http.StatusForbidden, "X-Requested-With header required with cookie authentication")
+    return false
+}
@@ -2,41 +2,27 @@ package handler
 
 import (
     "context"
-    "log"
+    "errors"
+    "log/slog"
     "net/http"
     "bytes"
     "net/http/httptest"
+    "strings"
+    "time"
 
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/impersonator"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
 )
 
-// Define context key type to avoid collisions (satisfies lint)
-type contextKey string
-
-// Define context keys
-const (
-    userIDKey   contextKey = "user_id"
-    roleKey     contextKey = "role"
-    usernameKey contextKey = "username"
-)
-
-// GetRole retrieves role from context
-func GetRole(r *http.Request) string {
-    role, ok := r.Context().Value(roleKey).(string)
-    if !ok {
-        return ""
-    }
-    return role
-}
-
 // AdminMiddleware checks if user is admin
 func AdminMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        requestID := GetRequestID(r.Context())
-
-        role, ok := r.Context().Value(roleKey).(string)
-        if !ok || role != "admin" {
-            log.Printf("[%s] Admin access denied. Role: %v", requestID, role)
+        claims, _ := ClaimsFromContext(r.Context())
+        if !claims.IsAdmin() {
+            slog.WarnContext(r.Context(), "admin access denied", "role", claims.Role)
             WriteError(r.Context(), w, http.StatusForbidden, "Admin access required")
             return
         }
@@ -45,43 +31,207 @@ func AdminMiddleware(next http.Handler) http.Handler {
     })
 }
 
-// AuthMiddleware checks JWT and extracts user info + role
-func AuthMiddleware(authSvc service.AuthService) func(http.Handler) http.Handler {
+// GlobalUserMiddleware admits only users who aren't scoped to a branch,
+// for endpoints that manage branches themselves.
+func GlobalUserMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if claims, _ := ClaimsFromContext(r.Context()); claims.BranchID != "" {
+            slog.WarnContext(r.Context(), "global access denied", "user_branch_id", claims.BranchID)
+            WriteError(r.Context(), w, http.StatusForbidden, "Only users not scoped to a branch can do this")
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// Codes of the 401s AuthMiddleware writes, so clients can tell a token to
+// refresh from one to replace.
+const (
+    codeTokenMissing   = "token_missing"
+    codeTokenMalformed = "token_malformed"
+    codeTokenInvalid   = "token_invalid"
+    codeTokenExpired   = "token_expired"
+    codeTokenRevoked   = "token_revoked"
+)
+
+// errMalformedAuth means the Authorization header isn't "Bearer <token>".
+var errMalformedAuth = errors.New("malformed authorization header")
+
+// bearerToken returns the token in r's Authorization header or, when it has
+// none and cookie is set, in the cookie of that name. fromCookie reports
+// where it came from. A header with another scheme or no token is
+// errMalformedAuth; no token anywhere is "", nil.
+func bearerToken(r *http.Request, cookie string) (token string, fromCookie bool, err error) {
+    if h := r.Header.Get("Authorization"); h != "" {
+        scheme, token, ok := strings.Cut(strings.TrimSpace(h), " ")
+        token = strings.TrimSpace(token)
+        if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" || strings.ContainsAny(token, " \t") {
+            return "", false, errMalformedAuth
+        }
+        return token, false, nil
+    }
+    if cookie != "" {
+        if c, err := r.Cookie(cookie); err == nil && c.Value != "" {
+            return c.Value, true, nil
+        }
+    }
+    return "", false, nil
+}
+
+// safeMethod reports whether method only reads, so a forged cross-site
+// request can't change anything with it.
+func safeMethod(method string) bool {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return true
+    }
+    return false
+}
+
+// writeTokenError writes the 401 for a token that was refused, or the
+// service error when it couldn't be checked at all.
+func writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+    code, message := codeTokenInvalid, "Invalid token"
+    switch {
+    case errors.Is(err, service.ErrTokenExpired):
+        code, message = codeTokenExpired, "Token has expired"
+    case errors.Is(err, service.ErrTokenRevoked), errors.Is(err, service.ErrSessionRevoked):
+        code, message = codeTokenRevoked, "Token has been revoked"
+    case errors.Is(err, service.ErrTokenInvalid):
+    default:
+        logServiceError(r.Context(), slog.Default(), "token validation failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to validate token")
+        return
+    }
+    slog.WarnContext(r.Context(), "token refused", "code", code, "error", err)
+    w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+    writeCodedError(r.Context(), w, http.StatusUnauthorized, code, message)
+}
+
+// AuthMiddleware checks the caller's JWT, or their API key in X-API-Key,
+// and stores who they are in the request context. The JWT is read from a
+// Bearer Authorization header or, when cookies.Token is set and there is no
+// such header, from the cookie of that name; a cookie only authenticates
+// unsafe methods alongside the CSRF token, or an X-Requested-With header
+// without one, neither of which cross-site forms can send. A caller scoped
+// to a branch scopes the request to it, and is refused for any other
+// branch TenantMiddleware resolved. apiKeys may be nil, in which case only
+// JWTs are accepted.
+func AuthMiddleware(authSvc service.AuthService, apiKeys service.APIKeyService, cookies CookieAuth) func(http.Handler) http.Handler {
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-            requestID := GetRequestID(r.Context())
+            var auth AuthContext
+            var recheck func(context.Context) error
+            if key := r.Header.Get("X-API-Key"); key != "" && apiKeys != nil {
+                k, u, err := apiKeys.Authenticate(r.Context(), key)
+                if err != nil {
+                    slog.WarnContext(r.Context(), "invalid api key", "error", err)
+                    WriteError(r.Context(), w, http.StatusUnauthorized, "Invalid API key")
+                    return
+                }
+                if !k.Allows(r.Method) {
+                    slog.WarnContext(r.Context(), "api key scope denied", "api_key_id", k.ID, "method", r.Method)
+                    WriteError(r.Context(), w, http.StatusForbidden, "API key is read-only")
+                    return
+                }
+                auth = AuthContext{UserID: u.ID, Username: u.Username, Role: u.Role, BranchID: u.BranchID, APIKeyID: k.ID}
+                recheck = func(ctx context.Context) error {
+                    _, u, err := apiKeys.Authenticate(ctx, key)
+                    if err == nil && u.Role != auth.Role {
+                        err = service.ErrTokenRevoked
+                    }
+                    return err
+                }
+            } else {
+                token, fromCookie, err := bearerToken(r, cookies.Token)
+                switch {
+                case err != nil:
+                    slog.WarnContext(r.Context(), "malformed authorization header")
+                    w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+                    writeCodedError(r.Context(), w, http.StatusUnauthorized, codeTokenMalformed, "Authorization header must be \"Bearer <token>\"")
+                    return
+                case token == "":
+                    slog.WarnContext(r.Context(), "missing authorization header")
+                    w.Header().Set("WWW-Authenticate", "Bearer")
+                    writeCodedError(r.Context(), w, http.StatusUnauthorized, codeTokenMissing, "Missing authorization header")
+                    return
+                case fromCookie && !safeMethod(r.Method) && !cookies.cookieAllowed(w, r):
+                    return
+                }
 
-            authHeader := r.Header.Get("Authorization")
-            if authHeader == "" {
-                log.Printf("[%s] Missing authorization header", requestID)
-                WriteError(r.Context(), w, http.StatusUnauthorized, "Missing authorization header")
-                return
-            }
+                claims, err := authSvc.ValidateToken(r.Context(), token)
+                if err != nil {
+                    writeTokenError(w, r, err)
+                    return
+                }
 
-            token := authHeader[7:]
-            claims, err := authSvc.ValidateToken(token)
-            if err != nil {
-                log.Printf("[%s] Invalid token: %v", requestID, err)
-                WriteError(r.Context(), w, http.StatusUnauthorized, "Invalid token")
-                return
+                auth.UserID, _ = claims["user_id"].(string)
+                auth.Username, _ = claims["username"].(string)
+                role, _ := claims["role"].(string)
+                auth.Role = model.Role(role)
+                auth.BranchID, _ = claims["branch_id"].(string)
+                auth.SessionID, _ = claims["session_id"].(string)
+                auth.ExpiresAt, _ = claims["expires_at"].(time.Time)
+                auth.ImpersonatorID, _ = claims["impersonator_id"].(string)
+                auth.Impersonator, _ = claims["impersonator"].(string)
+                recheck = func(ctx context.Context) error {
+                    _, err := authSvc.ValidateToken(ctx, token)
+                    return err
+                }
             }
 
-            // Add user info to context
-            ctx := context.WithValue(r.Context(), userIDKey, claims["user_id"])
-            ctx = context.WithValue(ctx, usernameKey, claims["username"])
-            ctx = context.WithValue(ctx, roleKey, claims["role"])
+            ctx := r.Context()
+            logger.AddAttrs(ctx, "user_id", auth.UserID)
+            if auth.APIKeyID != "" {
+                logger.AddAttrs(ctx, "api_key_id", auth.APIKeyID)
+            }
+            if auth.ImpersonatorID != "" {
+                ctx = impersonator.With(ctx, auth.ImpersonatorID)
+                logger.AddAttrs(ctx, "impersonator_id", auth.ImpersonatorID)
+            }
+            if auth.BranchID != "" {
+                switch tenant.BranchID(ctx) {
+                case "":
+                    ctx = tenant.WithBranch(ctx, auth.BranchID)
+                    logger.AddAttrs(ctx, "branch_id", auth.BranchID)
+                case auth.BranchID:
+                default:
+                    slog.WarnContext(r.Context(), "token used at another branch", "user_branch_id", auth.BranchID)
+                    WriteError(r.Context(), w, http.StatusForbidden, "Token is not valid for this branch")
+                    return
+                }
+            }
+            ctx = withRecheck(WithClaims(ctx, auth), recheck)
 
             next.ServeHTTP(w, r.WithContext(ctx))
         })
     }
 }
 
-func CreateTestRequestWithUser(method, path, body, requestID, userID, role string) *http.Request {
+// OptionalAuthMiddleware is AuthMiddleware for public routes that tell
+// callers apart when they can: a request with credentials must pass
+// AuthMiddleware, and one without is served anonymously.
+func OptionalAuthMiddleware(authSvc service.AuthService, apiKeys service.APIKeyService, cookies CookieAuth) func(http.Handler) http.Handler {
+    auth := AuthMiddleware(authSvc, apiKeys, cookies)
+    return func(next http.Handler) http.Handler {
+        authed := auth(next)
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            token, _, err := bearerToken(r, cookies.Token)
+            if err == nil && token == "" && (r.Header.Get("X-API-Key") == "" || apiKeys == nil) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            authed.ServeHTTP(w, r)
+        })
+    }
+}
+
+func CreateTestRequestWithUser(method, path, body, requestID, userID string, role model.Role) *http.Request {
     req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
     req.Header.Set("Content-Type", "application/json")
     req.Header.Set("X-Test-Bypass-Auth", "true")
-    ctx := context.WithValue(req.Context(), RequestIDKey, requestID)
-    ctx = context.WithValue(ctx, userIDKey, userID)
-    ctx = context.WithValue(ctx, roleKey, role)
+    ctx := WithRequestID(req.Context(), requestID)
+    ctx = WithClaims(ctx, AuthContext{UserID: userID, Role: role})
     return req.WithContext(ctx)
 }
\ No newline at end of file
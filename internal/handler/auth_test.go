@@ -7,10 +7,17 @@ import (
     "errors"
     "net/http"
     "net/http/httptest"
+    "strconv"
     "testing"
+    "strings"
     "time"
 
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
     "github.com/stretchr/testify/require"
 )
 
@@ -18,17 +25,62 @@ var ErrInvalidCredentials = errors.New("invalid credentials")
 
 // Mock auth service
 type mockAuthService struct {
-    generateFn func(userID, username, role string) (string, time.Time, error)
-    validateFn func(token string) (map[string]interface{}, error)
+    generateFn      func(userID, username string, role model.Role, branchID string) (string, time.Time, error)
+    validateFn      func(token string) (map[string]interface{}, error)
+    revokeFn        func(ctx context.Context, userID string) error
+    startFn         func(ctx context.Context, u *model.User, userAgent, ip string) (string, time.Time, error)
+    refreshFn       func(ctx context.Context, claims map[string]interface{}) (string, time.Time, error)
+    listSessionsFn  func(ctx context.Context, userID, currentID string) ([]model.Session, error)
+    revokeSessionFn func(ctx context.Context, userID, sessionID string) error
+    impersonateFn   func(u, admin *model.User, ttl time.Duration) (string, time.Time, error)
+    startPairFn     func(ctx context.Context, u *model.User) (*service.TokenPair, error)
+    refreshPairFn   func(ctx context.Context, refreshToken string) (*service.TokenPair, error)
+    endSessionFn    func(ctx context.Context, refreshToken string) error
 }
 
-func (m *mockAuthService) GenerateToken(userID, username, role string) (string, time.Time, error) {
-    return m.generateFn(userID, username, role)
+func (m *mockAuthService) GenerateToken(userID, username string, role model.Role, branchID string) (string, time.Time, error) {
+    return m.generateFn(userID, username, role, branchID)
 }
 
-func (m *mockAuthService) ValidateToken(token string) (map[string]interface{}, error) {
+func (m *mockAuthService) ValidateToken(_ context.Context, token string) (map[string]interface{}, error) {
     return m.validateFn(token)
 }
+
+func (m *mockAuthService) RevokeTokens(ctx context.Context, userID string) error {
+    return m.revokeFn(ctx, userID)
+}
+
+func (m *mockAuthService) StartSession(ctx context.Context, u *model.User, userAgent, ip string) (string, time.Time, error) {
+    return m.startFn(ctx, u, userAgent, ip)
+}
+
+func (m *mockAuthService) RefreshSession(ctx context.Context, claims map[string]interface{}, _, _ string) (string, time.Time, error) {
+    return m.refreshFn(ctx, claims)
+}
+
+func (m *mockAuthService) ListSessions(ctx context.Context, userID, currentID string) ([]model.Session, error) {
+    return m.listSessionsFn(ctx, userID, currentID)
+}
+
+func (m *mockAuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+    return m.revokeSessionFn(ctx, userID, sessionID)
+}
+
+func (m *mockAuthService) Impersonate(u, admin *model.User, ttl time.Duration) (string, time.Time, error) {
+    return m.impersonateFn(u, admin, ttl)
+}
+
+func (m *mockAuthService) StartRefreshableSession(ctx context.Context, u *model.User, _, _ string) (*service.TokenPair, error) {
+    return m.startPairFn(ctx, u)
+}
+
+func (m *mockAuthService) RefreshWithToken(ctx context.Context, refreshToken, _, _ string) (*service.TokenPair, error) {
+    return m.refreshPairFn(ctx, refreshToken)
+}
+
+func (m *mockAuthService) EndSession(ctx context.Context, refreshToken string) error {
+    return m.endSessionFn(ctx, refreshToken)
+}
 func (m *mockUserServiceForAuth) RegisterAdmin(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
     return &model.User{Username: req.Username, Email: req.Email, Role: "admin"}, nil
 }
@@ -36,12 +88,18 @@ func (m *mockUserServiceForAuth) RegisterAdmin(ctx context.Context, req *model.R
 type mockUserServiceForAuth struct {
     registerFn      func(ctx context.Context, req *model.RegisterRequest) (*model.User, error)
     getByIDFn       func(ctx context.Context, id string) (*model.User, error)
-    updateFn        func(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
+    updateFn        func(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
     validateFn      func(ctx context.Context, username, password string) (*model.User, error)
+    loginFn         func(ctx context.Context, username, password, clientIP string) (*model.User, error)
+    changePwFn      func(ctx context.Context, userID, currentPassword, newPassword string) error
     getByEmailFn    func(ctx context.Context, email string) (*model.User, error)
     getByUsernameFn func(ctx context.Context, username string) (*model.User, error)
-    listFn          func(ctx context.Context, limit, offset int) ([]model.User, error)
+    listFn          func(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
     deleteFn        func(ctx context.Context, id string) error
+    adminUpdateFn   func(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error)
+    suspendFn       func(ctx context.Context, id string, until *time.Time) (*model.User, error)
+    unsuspendFn     func(ctx context.Context, id string) (*model.User, error)
+    updatePrefsFn   func(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error)
 }
 
 func (m *mockUserServiceForAuth) Register(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
@@ -52,14 +110,22 @@ func (m *mockUserServiceForAuth) GetByID(ctx context.Context, id string) (*model
     return m.getByIDFn(ctx, id)
 }
 
-func (m *mockUserServiceForAuth) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockUserServiceForAuth) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    return m.updateFn(ctx, id, p)
 }
 
 func (m *mockUserServiceForAuth) ValidatePassword(ctx context.Context, username, password string) (*model.User, error) {
     return m.validateFn(ctx, username, password)
 }
 
+func (m *mockUserServiceForAuth) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+    return m.changePwFn(ctx, userID, currentPassword, newPassword)
+}
+
+func (m *mockUserServiceForAuth) Login(ctx context.Context, username, password, clientIP string) (*model.User, error) {
+    return m.loginFn(ctx, username, password, clientIP)
+}
+
 func (m *mockUserServiceForAuth) GetByEmail(ctx context.Context, email string) (*model.User, error) {
     return m.getByEmailFn(ctx, email)
 }
@@ -68,58 +134,119 @@ func (m *mockUserServiceForAuth) GetByUsername(ctx context.Context, username str
     return m.getByUsernameFn(ctx, username)
 }
 
-func (m *mockUserServiceForAuth) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockUserServiceForAuth) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    return m.listFn(ctx, p)
 }
 
 func (m *mockUserServiceForAuth) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockUserServiceForAuth) AdminUpdate(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error) {
+    return m.adminUpdateFn(ctx, id, req)
+}
+
+func (m *mockUserServiceForAuth) Suspend(ctx context.Context, id string, until *time.Time) (*model.User, error) {
+    return m.suspendFn(ctx, id, until)
+}
+
+func (m *mockUserServiceForAuth) Unsuspend(ctx context.Context, id string) (*model.User, error) {
+    return m.unsuspendFn(ctx, id)
+}
+
+func (m *mockUserServiceForAuth) GetPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    return model.DefaultNotificationPreferences(userID), nil
+}
+
+func (m *mockUserServiceForAuth) UpdatePreferences(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error) {
+    return m.updatePrefsFn(ctx, userID, req)
+}
+
 // Helper to set request ID in context properly
 func createAuthRequest(method, path string, body string, requestID string) *http.Request {
     req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
     req.Header.Set("Content-Type", "application/json")
-    ctx := context.WithValue(req.Context(), RequestIDKey, requestID)
+    ctx := WithRequestID(req.Context(), requestID)
     return req.WithContext(ctx)
 }
 
 func TestAuthHandler_Login_Success(t *testing.T) {
+    var gotAgent, gotIP string
     mockAuthSvc := &mockAuthService{
-        generateFn: func(userID, username, role string) (string, time.Time, error) {
+        startFn: func(_ context.Context, u *model.User, userAgent, ip string) (string, time.Time, error) {
+            gotAgent, gotIP = userAgent, ip
             return "valid-token", time.Now().Add(24 * time.Hour), nil
         },
     }
     mockUserSvc := &mockUserServiceForAuth{
-        validateFn: func(_ context.Context, username, password string) (*model.User, error) {
+        loginFn: func(_ context.Context, username, password, _ string) (*model.User, error) {
             return &model.User{
                 ID:       "user-1",
                 Username: username,
-                Role:     "USER",
+                Role:     model.RoleUser,
             }, nil
         },
     }
-    h := NewAuthHandler(mockAuthSvc, mockUserSvc)
+    h := NewAuthHandler(mockAuthSvc, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
 
     req := createAuthRequest("POST", "/auth/login", `{"username":"john","password":"SecurePass123"}`, "test-auth-001")
+    req.Header.Set("User-Agent", "test-browser")
+    req.RemoteAddr = "203.0.113.7:51234"
     rec := httptest.NewRecorder()
 
     h.Login(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "test-browser", gotAgent)
+    require.Equal(t, "203.0.113.7", gotIP)
 
     var resp model.LoginResponse
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
     require.NotEmpty(t, resp.Token)
 }
 
+func TestAuthHandler_Login_RecordsHistory(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    john := &model.User{Username: "john", Email: "john@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(context.Background(), john))
+    logins := service.NewLoginHistoryService(repos.Logins, nil, logger.Discard())
+    mockUserSvc := &mockUserServiceForAuth{
+        loginFn: func(context.Context, string, string, string) (*model.User, error) { return john, nil },
+    }
+    mockAuthSvc := &mockAuthService{
+        startFn: func(context.Context, *model.User, string, string) (string, time.Time, error) {
+            return "valid-token", time.Now().Add(time.Hour), nil
+        },
+    }
+    h := NewAuthHandler(mockAuthSvc, mockUserSvc, logins, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    login := func(userAgent string) {
+        req := createAuthRequest("POST", "/auth/login", `{"username":"john","password":"SecurePass123"}`, "test-auth-history")
+        req.Header.Set("User-Agent", userAgent)
+        req.RemoteAddr = "203.0.113.7:51234"
+        rec := httptest.NewRecorder()
+        h.Login(rec, req)
+        require.Equal(t, http.StatusOK, rec.Code)
+    }
+    login("test-browser")
+    login("other-browser")
+
+    history, err := logins.ListMine(context.Background(), john.ID, model.PageRequest{Limit: 10})
+    require.NoError(t, err)
+    require.Len(t, history.Items, 2)
+    require.Equal(t, "other-browser", history.Items[0].UserAgent)
+    require.Equal(t, "203.0.113.7", history.Items[0].IP)
+    require.True(t, history.Items[0].NewDevice)
+    require.False(t, history.Items[1].NewDevice)
+}
+
 func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
     mockAuthSvc := &mockAuthService{}
     mockUserSvc := &mockUserServiceForAuth{
-        validateFn: func(_ context.Context, username, password string) (*model.User, error) {
+        loginFn: func(_ context.Context, username, password, _ string) (*model.User, error) {
             return nil, ErrInvalidCredentials
         },
     }
-    h := NewAuthHandler(mockAuthSvc, mockUserSvc)
+    h := NewAuthHandler(mockAuthSvc, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
 
     req := createAuthRequest("POST", "/auth/login", `{"username":"john","password":"WrongPassword"}`, "test-auth-002")
     rec := httptest.NewRecorder()
@@ -128,21 +255,112 @@ func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
     require.Equal(t, http.StatusUnauthorized, rec.Code)
 }
 
+func TestAuthHandler_Login_Locked(t *testing.T) {
+    var gotIP string
+    mockUserSvc := &mockUserServiceForAuth{
+        loginFn: func(_ context.Context, username, password, clientIP string) (*model.User, error) {
+            gotIP = clientIP
+            return nil, &service.LockedError{Until: time.Now().Add(90 * time.Second)}
+        },
+    }
+    h := NewAuthHandler(&mockAuthService{}, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    req := createAuthRequest("POST", "/auth/login", `{"username":"john","password":"WrongPassword"}`, "test-auth-003")
+    req.RemoteAddr = "203.0.113.7:51234"
+    rec := httptest.NewRecorder()
+
+    h.Login(rec, req)
+    require.Equal(t, http.StatusLocked, rec.Code)
+    require.Equal(t, "203.0.113.7", gotIP)
+    retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+    require.NoError(t, err)
+    require.InDelta(t, 90, retry, 1)
+}
+
+func TestAuthHandler_Login_Suspended(t *testing.T) {
+    mockUserSvc := &mockUserServiceForAuth{
+        loginFn: func(_ context.Context, username, password, clientIP string) (*model.User, error) {
+            return nil, apperr.Forbidden("account is suspended")
+        },
+    }
+    h := NewAuthHandler(&mockAuthService{}, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    req := createAuthRequest("POST", "/auth/login", `{"username":"john","password":"SecurePass123"}`, "test-auth-004")
+    rec := httptest.NewRecorder()
+
+    h.Login(rec, req)
+    require.Equal(t, http.StatusForbidden, rec.Code)
+    require.Contains(t, rec.Body.String(), "Account is suspended")
+}
+
+func TestAuthHandler_Login_RejectsBadInput(t *testing.T) {
+    mockUserSvc := &mockUserServiceForAuth{
+        loginFn: func(_ context.Context, username, password, _ string) (*model.User, error) {
+            t.Fatal("invalid input reached the password check")
+            return nil, nil
+        },
+    }
+    h := NewAuthHandler(&mockAuthService{}, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    for _, body := range []string{
+        ``,
+        `{}`,
+        `{"username":"   ","password":"SecurePass123"}`,
+        `{"username":"john","password":""}`,
+        `{"username":"` + strings.Repeat("j", 51) + `","password":"SecurePass123"}`,
+        `{"username":"john","password":"` + strings.Repeat("p", 73) + `"}`,
+    } {
+        rec := httptest.NewRecorder()
+        h.Login(rec, createAuthRequest("POST", "/auth/login", body, "test-auth-005"))
+        require.Equal(t, http.StatusBadRequest, rec.Code, body)
+    }
+}
+
+func TestAuthHandler_Login_RateLimited(t *testing.T) {
+    calls := 0
+    mockUserSvc := &mockUserServiceForAuth{
+        loginFn: func(_ context.Context, username, password, _ string) (*model.User, error) {
+            calls++
+            return nil, ErrInvalidCredentials
+        },
+    }
+    h := NewAuthHandler(&mockAuthService{}, mockUserSvc, nil, LoginRateLimit{PerIP: 3, PerUsername: 2, Period: time.Minute}, CookieAuth{}, logger.Discard())
+
+    login := func(username, ip string) *httptest.ResponseRecorder {
+        req := createAuthRequest("POST", "/auth/login", `{"username":"`+username+`","password":"WrongPassword"}`, "test-auth-006")
+        req.RemoteAddr = ip + ":51234"
+        rec := httptest.NewRecorder()
+        h.Login(rec, req)
+        return rec
+    }
+
+    require.Equal(t, http.StatusUnauthorized, login("john", "203.0.113.7").Code)
+    require.Equal(t, http.StatusUnauthorized, login("John", "203.0.113.8").Code)
+    rec := login("john", "203.0.113.9")
+    require.Equal(t, http.StatusTooManyRequests, rec.Code, "per username, across IPs and case")
+    require.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+    require.Equal(t, http.StatusUnauthorized, login("ada", "203.0.113.7").Code)
+    require.Equal(t, http.StatusUnauthorized, login("grace", "203.0.113.7").Code)
+    require.Equal(t, http.StatusTooManyRequests, login("linus", "203.0.113.7").Code, "per IP, across usernames")
+    require.Equal(t, 4, calls)
+}
+
 func TestAuthHandler_Refresh_Success(t *testing.T) {
     mockAuthSvc := &mockAuthService{
         validateFn: func(token string) (map[string]interface{}, error) {
             return map[string]interface{}{
                 "user_id":  "user-1",
                 "username": "john",
-                "role":     "USER",
+                "role":     "user",
             }, nil
         },
-        generateFn: func(userID, username, role string) (string, time.Time, error) {
+        refreshFn: func(_ context.Context, claims map[string]interface{}) (string, time.Time, error) {
             return "new-token", time.Now().Add(24 * time.Hour), nil
         },
     }
     mockUserSvc := &mockUserServiceForAuth{}
-    h := NewAuthHandler(mockAuthSvc, mockUserSvc)
+    h := NewAuthHandler(mockAuthSvc, mockUserSvc, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
 
     req := createAuthRequest("POST", "/auth/refresh", `{"token":"old-token"}`, "test-auth-003")
     rec := httptest.NewRecorder()
@@ -153,4 +371,132 @@ func TestAuthHandler_Refresh_Success(t *testing.T) {
     var resp model.LoginResponse
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
     require.Equal(t, "new-token", resp.Token)
-}
\ No newline at end of file
+}
+
+func TestAuthHandler_Refresh_SessionRevoked(t *testing.T) {
+    mockAuthSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            return map[string]interface{}{"user_id": "user-1", "session_id": "s-1"}, nil
+        },
+        refreshFn: func(_ context.Context, claims map[string]interface{}) (string, time.Time, error) {
+            return "", time.Time{}, apperr.NotFound("session not found")
+        },
+    }
+    h := NewAuthHandler(mockAuthSvc, &mockUserServiceForAuth{}, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    req := createAuthRequest("POST", "/auth/refresh", `{"token":"old-token"}`, "test-auth-005")
+    rec := httptest.NewRecorder()
+
+    h.Refresh(rec, req)
+    require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthHandler_CookieMode(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    john := &model.User{Username: "john", Email: "john@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(context.Background(), john))
+    authSvc := service.NewAuthService([]service.SigningKey{{ID: "test", Secret: []byte("0123456789abcdef0123456789abcdef")}},
+        time.Hour, service.TokenPolicy{AccessTTL: time.Minute}, nil, repos.Sessions, time.Hour)
+    userSvc := &mockUserServiceForAuth{
+        loginFn: func(context.Context, string, string, string) (*model.User, error) {
+            return john, nil
+        },
+    }
+    cookies := CookieAuth{Refresh: "library_refresh", CSRF: "library_csrf"}
+    h := NewAuthHandler(authSvc, userSvc, nil, LoginRateLimit{}, cookies, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Login(rec, createAuthRequest("POST", "/auth/login", `{"username":"john","password":"SecurePass123"}`, "test-auth-006"))
+    require.Equal(t, http.StatusOK, rec.Code)
+    var resp model.LoginResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+    require.WithinDuration(t, time.Now().Add(time.Minute), resp.ExpiresAt, 5*time.Second, "the access token isn't short-lived")
+    set := map[string]*http.Cookie{}
+    for _, c := range rec.Result().Cookies() {
+        set[c.Name] = c
+    }
+    refresh, csrf := set["library_refresh"], set["library_csrf"]
+    require.NotNil(t, refresh)
+    require.True(t, refresh.HttpOnly)
+    require.Equal(t, http.SameSiteStrictMode, refresh.SameSite)
+    require.NotNil(t, csrf)
+    require.False(t, csrf.HttpOnly, "the client can't read the CSRF token")
+
+    post := func(handle http.HandlerFunc, csrfHeader string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest("POST", "/auth/refresh", nil)
+        req.AddCookie(refresh)
+        req.AddCookie(csrf)
+        if csrfHeader != "" {
+            req.Header.Set("X-CSRF-Token", csrfHeader)
+        }
+        rec := httptest.NewRecorder()
+        handle(rec, req)
+        return rec
+    }
+    require.Equal(t, http.StatusForbidden, post(h.Refresh, "").Code, "refresh without the CSRF token")
+    require.Equal(t, http.StatusForbidden, post(h.Refresh, "forged").Code)
+    rec = post(h.Refresh, csrf.Value)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+    _, err := authSvc.ValidateToken(context.Background(), resp.Token)
+    require.NoError(t, err)
+
+    rec = post(h.Logout, csrf.Value)
+    require.Equal(t, http.StatusNoContent, rec.Code)
+    for _, c := range rec.Result().Cookies() {
+        require.Negative(t, c.MaxAge, "cookie %s was kept", c.Name)
+    }
+    rec = post(h.Refresh, csrf.Value)
+    require.Equal(t, http.StatusUnauthorized, rec.Code, "the session outlived logout")
+}
+
+func TestAuthHandler_ListSessions_MarksCurrent(t *testing.T) {
+    var gotUser, gotCurrent string
+    mockAuthSvc := &mockAuthService{
+        listSessionsFn: func(_ context.Context, userID, currentID string) ([]model.Session, error) {
+            gotUser, gotCurrent = userID, currentID
+            return []model.Session{{ID: currentID, UserAgent: "test-browser", Current: true}}, nil
+        },
+    }
+    h := NewAuthHandler(mockAuthSvc, &mockUserServiceForAuth{}, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    req := httptest.NewRequest("GET", "/users/me/sessions", nil)
+    req = req.WithContext(WithClaims(req.Context(), AuthContext{UserID: "user-1", SessionID: "s-1"}))
+    rec := httptest.NewRecorder()
+
+    h.ListSessions(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "user-1", gotUser)
+    require.Equal(t, "s-1", gotCurrent)
+
+    var sessions []model.Session
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sessions))
+    require.Len(t, sessions, 1)
+    require.True(t, sessions[0].Current)
+}
+
+func TestAuthHandler_RevokeSession(t *testing.T) {
+    mockAuthSvc := &mockAuthService{
+        revokeSessionFn: func(_ context.Context, userID, sessionID string) error {
+            if userID == "user-1" && sessionID == "s-2" {
+                return nil
+            }
+            return apperr.NotFound("session not found")
+        },
+    }
+    h := NewAuthHandler(mockAuthSvc, &mockUserServiceForAuth{}, nil, LoginRateLimit{}, CookieAuth{}, logger.Discard())
+
+    revoke := func(userID, id string) int {
+        rctx := chi.NewRouteContext()
+        rctx.URLParams.Add("id", id)
+        req := httptest.NewRequest("DELETE", "/users/me/sessions/"+id, nil)
+        ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+        req = req.WithContext(WithClaims(ctx, AuthContext{UserID: userID}))
+        rec := httptest.NewRecorder()
+        h.RevokeSession(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusNoContent, revoke("user-1", "s-2"))
+    require.Equal(t, http.StatusNotFound, revoke("user-2", "s-2"))
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+)
+
+// normalizer is implemented by request types that clean up their fields
+// (e.g. trimming whitespace) before validation runs.
+type normalizer interface {
+    Normalize()
+}
+
+// Bind decodes the JSON body into a T, rejecting unknown fields, trailing
+// data and oversized bodies, then evaluates the struct's validate tags.
+// On failure it writes the error response itself and returns false.
+func Bind[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+    var req T
+    if err := decodeJSON(w, r, &req); err != nil {
+        slog.WarnContext(r.Context(), "invalid request", "error", err)
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Request body too large")
+            return req, false
+        }
+        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+        return req, false
+    }
+
+    if n, ok := any(&req).(normalizer); ok {
+        n.Normalize()
+    }
+
+    if errs := validateStruct(req); len(errs) > 0 {
+        slog.WarnContext(r.Context(), "validation failed", "errors", errs)
+        WriteValidationErrors(r.Context(), w, errs)
+        return req, false
+    }
+    return req, true
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+    r.Body = http.MaxBytesReader(w, r.Body, bodyLimit(r.Context()))
+    dec := json.NewDecoder(r.Body)
+    dec.DisallowUnknownFields()
+
+    if err := dec.Decode(dst); err != nil {
+        if errors.Is(err, io.EOF) {
+            return errors.New("body must not be empty")
+        }
+        return err
+    }
+    if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            return err
+        }
+        return fmt.Errorf("body must contain a single JSON object")
+    }
+    return nil
+}
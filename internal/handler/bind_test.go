@@ -0,0 +1,75 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+func TestBind_Success(t *testing.T) {
+    req := createTestRequest("POST", "/auth/register", `{"username":"  john  ","email":"john@example.com","password":"SecurePass123"}`, "test-bind-001")
+    rec := httptest.NewRecorder()
+
+    got, ok := Bind[model.RegisterRequest](rec, req)
+    require.True(t, ok)
+    require.Equal(t, "john", got.Username)
+}
+
+func TestBind_RejectsUnknownFields(t *testing.T) {
+    req := createTestRequest("POST", "/bookings", `{"book_id":"b1","borrow_days":7,"admin":true}`, "test-bind-002")
+    rec := httptest.NewRecorder()
+
+    _, ok := Bind[model.BorrowBookRequest](rec, req)
+    require.False(t, ok)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+    require.Contains(t, rec.Body.String(), "unknown field")
+}
+
+func TestBind_RejectsTrailingData(t *testing.T) {
+    req := createTestRequest("POST", "/bookings", `{"book_id":"b1","borrow_days":7}{}`, "test-bind-003")
+    rec := httptest.NewRecorder()
+
+    _, ok := Bind[model.BorrowBookRequest](rec, req)
+    require.False(t, ok)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBind_RejectsOversizedBody(t *testing.T) {
+    body := `{"title":"` + strings.Repeat("a", defaultMaxBodyBytes) + `","author":"x"}`
+    req := createTestRequest("POST", "/admin/books", body, "test-bind-004")
+    rec := httptest.NewRecorder()
+
+    _, ok := Bind[model.CreateBookRequest](rec, req)
+    require.False(t, ok)
+    require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestBind_ValidationErrorsByField(t *testing.T) {
+    req := createTestRequest("POST", "/auth/register", `{"username":"jo","email":"nope","password":"short"}`, "test-bind-005")
+    rec := httptest.NewRecorder()
+
+    _, ok := Bind[model.RegisterRequest](rec, req)
+    require.False(t, ok)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+
+    var resp struct {
+        Errors ValidationErrors `json:"errors"`
+    }
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+    require.Equal(t, "username must be at least 3 characters", resp.Errors["username"])
+    require.Equal(t, "invalid email format", resp.Errors["email"])
+    require.Equal(t, "password must be at least 8 characters", resp.Errors["password"])
+}
+
+func TestBind_OmitEmptySkipsRules(t *testing.T) {
+    req := createTestRequest("PUT", "/users/me", `{}`, "test-bind-006")
+    rec := httptest.NewRecorder()
+
+    _, ok := Bind[model.UpdateUserRequest](rec, req)
+    require.True(t, ok)
+}
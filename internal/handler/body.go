@@ -0,0 +1,74 @@
+package handler
+
+import (
+    "context"
+    "mime"
+    "net/http"
+    "strings"
+)
+
+// defaultMaxBodyBytes caps request bodies when RequestBodyMiddleware has not
+// set a limit; bulk uploads set their own.
+const defaultMaxBodyBytes = 1 << 20 // 1 MB
+
+type bodyLimitKey struct{}
+
+// bodyLimit returns the body size limit RequestBodyMiddleware put in ctx.
+func bodyLimit(ctx context.Context) int64 {
+    if n, ok := ctx.Value(bodyLimitKey{}).(int64); ok {
+        return n
+    }
+    return defaultMaxBodyBytes
+}
+
+// RequestBodyMiddleware caps every request body at maxBytes (413) and
+// requires POST, PUT and PATCH requests that carry a body to be JSON (415).
+// Requests to the exempt paths, such as file uploads, skip both checks and
+// must enforce their own limits.
+func RequestBodyMiddleware(maxBytes int64, exempt ...string) func(http.Handler) http.Handler {
+    skip := make(map[string]bool, len(exempt))
+    for _, p := range exempt {
+        skip[p] = true
+    }
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if skip[r.URL.Path] {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            if r.ContentLength > maxBytes {
+                WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Request body too large")
+                return
+            }
+            if hasBody(r) && isWriteMethod(r.Method) && !isJSON(r.Header.Get("Content-Type")) {
+                WriteError(r.Context(), w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+                return
+            }
+
+            r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+            ctx := context.WithValue(r.Context(), bodyLimitKey{}, maxBytes)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+func hasBody(r *http.Request) bool {
+    return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+func isWriteMethod(method string) bool {
+    return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// isJSON accepts application/json and structured +json types such as
+// application/merge-patch+json.
+func isJSON(contentType string) bool {
+    mediaType, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        return false
+    }
+    return mediaType == "application/json" ||
+        (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+// BookListingHandler serves the ready-made listings front-ends build landing
+// pages from.
+type BookListingHandler struct {
+    svc    service.BookListingService
+    logger *slog.Logger
+}
+
+func NewBookListingHandler(svc service.BookListingService, logger *slog.Logger) *BookListingHandler {
+    return &BookListingHandler{svc: svc, logger: logger}
+}
+
+// Popular godoc
+// @Summary      Most popular books
+// @Description  Books ranked by the loans started within POPULAR_BOOKS_WINDOW, most
+// @Description  borrowed first. Results are cached for up to BOOK_LISTING_CACHE_TTL.
+// @Tags         Books
+// @Param        limit  query  int  false  "Number of books (1-100)"  default(20)
+// @Produce      json
+// @Success      200  {array}   model.PopularBook
+// @Failure      500  {object}  ErrorResponse
+// @Router       /books/popular [get]
+func (h *BookListingHandler) Popular(w http.ResponseWriter, r *http.Request) {
+    books, err := h.svc.Popular(r.Context(), parsePageRequest(r).Limit)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list popular books failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list popular books")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, books)
+}
+
+// New godoc
+// @Summary      Recently added books
+// @Description  The newest books in the catalog, most recently added first. Results are
+// @Description  cached for up to BOOK_LISTING_CACHE_TTL.
+// @Tags         Books
+// @Param        limit  query  int  false  "Number of books (1-100)"  default(20)
+// @Produce      json
+// @Success      200  {array}   model.Book
+// @Failure      500  {object}  ErrorResponse
+// @Router       /books/new [get]
+func (h *BookListingHandler) New(w http.ResponseWriter, r *http.Request) {
+    books, err := h.svc.Newest(r.Context(), parsePageRequest(r).Limit)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list new books failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list new books")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, books)
+}
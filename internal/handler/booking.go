@@ -1,23 +1,22 @@
 package handler
 
 import (
-    "encoding/json"
-    "log"
+    "log/slog"
     "net/http"
-    "strconv"
-    "strings"
 
     "github.com/go-chi/chi/v5"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
 )
 
 type BookingHandler struct {
     bookingSvc service.BookingService
+    logger     *slog.Logger
 }
 
-func NewBookingHandler(bookingSvc service.BookingService) *BookingHandler {
-    return &BookingHandler{bookingSvc: bookingSvc}
+func NewBookingHandler(bookingSvc service.BookingService, logger *slog.Logger) *BookingHandler {
+    return &BookingHandler{bookingSvc: bookingSvc, logger: logger}
 }
 
 // isTestRequest checks if this is a test request that should bypass auth
@@ -27,63 +26,82 @@ func isTestRequest(r *http.Request) bool {
 
 // Borrow godoc
 // @Summary      Borrow a book
-// @Description  Borrow a book from the library
+// @Description  Borrow a book from the library. The receipt embeds the book, gives the due date in
+// @Description  the requested time_zone, and reports the loan policy applied and the borrower's loans.
 // @Tags         Bookings
 // @Security     BearerAuth
 // @Accept       json
 // @Param        request  body      model.BorrowBookRequest  true  "Borrow request"
 // @Produce      json
-// @Success      201  {object}  model.Booking
+// @Success      201  {object}  model.BorrowBookResponse
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
 // @Failure      409  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
 // @Router       /bookings [post]
 func (h *BookingHandler) Borrow(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     userID := GetUserID(r.Context())
 
     if userID == "" && !isTestRequest(r) {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
 
-    var req model.BorrowBookRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.BorrowBookRequest](w, r)
+    if !ok {
         return
     }
 
-    errs := ValidationErrors{}
-    if req.BookID == "" {
-        errs["book_id"] = "book_id is required"
+    receipt, err := h.bookingSvc.Borrow(r.Context(), userID, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "borrow failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to borrow book")
+        return
     }
-    if req.BorrowDays < 1 || req.BorrowDays > 30 {
-        errs["borrow_days"] = "borrow_days must be between 1 and 30"
+
+    respond.JSON(r.Context(), w, http.StatusCreated, receipt)
+    h.logger.InfoContext(r.Context(), "book borrowed", "book_id", receipt.Booking.BookID, "booking_id", receipt.Booking.ID)
+}
+
+// BorrowScanned godoc
+// @Summary      Borrow a book by scanning it
+// @Description  Self-checkout: borrow the book behind a scanned ISBN, book label or copy barcode
+// @Description  (book_id/copy) instead of its ID. The receipt is as for POST /bookings.
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body      model.ScanBorrowRequest  true  "Scan request"
+// @Produce      json
+// @Success      201  {object}  model.BorrowBookResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Router       /bookings/scan [post]
+func (h *BookingHandler) BorrowScanned(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
     }
 
-    if len(errs) > 0 {
-        WriteValidationErrors(r.Context(), w, errs)
+    req, ok := Bind[model.ScanBorrowRequest](w, r)
+    if !ok {
         return
     }
 
-    booking, err := h.bookingSvc.Borrow(r.Context(), userID, &req)
+    receipt, err := h.bookingSvc.BorrowScanned(r.Context(), userID, &req)
     if err != nil {
-        if strings.Contains(err.Error(), "already") || strings.Contains(err.Error(), "not found") {
-            log.Printf("[%s] Borrow failed: %v", requestID, err)
-            WriteError(r.Context(), w, http.StatusConflict, err.Error())
-            return
-        }
-        log.Printf("[%s] Borrow failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to borrow book")
+        logServiceError(r.Context(), h.logger, "scan borrow failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to borrow book")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    _ = json.NewEncoder(w).Encode(booking)
-    log.Printf("[%s] Book borrowed: %s by user %s", requestID, booking.BookID, userID)
+    respond.JSON(r.Context(), w, http.StatusCreated, receipt)
+    h.logger.InfoContext(r.Context(), "book borrowed by scan", "book_id", receipt.Booking.BookID, "booking_id", receipt.Booking.ID)
 }
 
 // Return godoc
@@ -97,14 +115,39 @@ func (h *BookingHandler) Borrow(w http.ResponseWriter, r *http.Request) {
 // @Success      200  {object}  model.Booking
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
 // @Router       /bookings/{id}/return [post]
 func (h *BookingHandler) Return(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
+    h.returnBooking(w, r, false)
+}
+
+// AdminReturn godoc
+// @Summary      Return a user's book
+// @Description  Return a book borrowed by any user, as when it is handed in at the desk
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Booking ID"
+// @Produce      json
+// @Success      200  {object}  model.Booking
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /admin/bookings/{id}/return [post]
+func (h *BookingHandler) AdminReturn(w http.ResponseWriter, r *http.Request) {
+    h.returnBooking(w, r, true)
+}
+
+// returnBooking returns the booking in the path for the caller, who must
+// have borrowed it unless asAdmin.
+func (h *BookingHandler) returnBooking(w http.ResponseWriter, r *http.Request, asAdmin bool) {
     userID := GetUserID(r.Context())
 
     if userID == "" && !isTestRequest(r) {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
@@ -115,69 +158,141 @@ func (h *BookingHandler) Return(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    booking, err := h.bookingSvc.Return(r.Context(), bookingID)
+    booking, err := h.bookingSvc.Return(r.Context(), userID, bookingID, asAdmin)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "return failed", err, "booking_id", bookingID)
+        WriteServiceError(r.Context(), w, err, "Failed to return book")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, booking)
+    h.logger.InfoContext(r.Context(), "book returned", "book_id", booking.BookID, "booking_id", booking.ID,
+        "borrower_id", booking.UserID, "by_admin", asAdmin)
+}
+
+// AcceptOffer godoc
+// @Summary      Accept a waitlist offer
+// @Description  Borrow the copy held for you by an OFFERED booking, before the offer expires
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                    true  "Booking ID"
+// @Param        request  body  model.AcceptOfferRequest  true  "Loan length"
+// @Produce      json
+// @Success      200  {object}  model.Booking
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Router       /bookings/{id}/accept [post]
+func (h *BookingHandler) AcceptOffer(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.AcceptOfferRequest](w, r)
+    if !ok {
+        return
+    }
+
+    bookingID := chi.URLParam(r, "id")
+    booking, err := h.bookingSvc.AcceptOffer(r.Context(), userID, bookingID, &req)
     if err != nil {
-        if strings.Contains(err.Error(), "not found") {
-            log.Printf("[%s] Return failed: %v", requestID, err)
-            WriteError(r.Context(), w, http.StatusNotFound, "Booking not found")
-            return
-        }
-        log.Printf("[%s] Return failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to return book")
+        logServiceError(r.Context(), h.logger, "accept offer failed", err, "booking_id", bookingID)
+        WriteServiceError(r.Context(), w, err, "Failed to accept offer")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(booking)
-    log.Printf("[%s] Book returned: %s by user %s", requestID, booking.BookID, userID)
+    respond.JSON(r.Context(), w, http.StatusOK, booking)
+    h.logger.InfoContext(r.Context(), "offer accepted", "book_id", booking.BookID, "booking_id", booking.ID)
+}
+
+// DeclineOffer godoc
+// @Summary      Decline a waitlist offer
+// @Description  Give up the copy held for you, passing it to the next user on the waitlist
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Booking ID"
+// @Produce      json
+// @Success      200  {object}  model.Booking
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /bookings/{id}/decline [post]
+func (h *BookingHandler) DeclineOffer(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    bookingID := chi.URLParam(r, "id")
+    booking, err := h.bookingSvc.DeclineOffer(r.Context(), userID, bookingID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "decline offer failed", err, "booking_id", bookingID)
+        WriteServiceError(r.Context(), w, err, "Failed to decline offer")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, booking)
+    h.logger.InfoContext(r.Context(), "offer declined", "book_id", booking.BookID, "booking_id", booking.ID)
 }
 
 // GetMyBookings godoc
 // @Summary      Get my bookings
-// @Description  Get all bookings for current user
+// @Description  Get the current user's bookings, optionally filtered
 // @Tags         Bookings
 // @Security     BearerAuth
 // @Param        limit   query     int     false  "Items per page"  default(20)
 // @Param        offset  query     int     false  "Pagination offset"  default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Param        expand  query     string  false  "Related records to embed: book, user (comma-separated)"
+// @Param        status   query    string  false  "ACTIVE, RETURNED, OVERDUE, OFFERED, DECLINED or EXPIRED"
+// @Param        book_id  query    string  false  "Only bookings of this book"
+// @Param        from     query    string  false  "Borrowed on or after (YYYY-MM-DD or RFC3339)"
+// @Param        to       query    string  false  "Borrowed before (YYYY-MM-DD or RFC3339)"
 // @Produce      json
-// @Success      200  {array}   model.Booking
+// @Success      200  {object}  model.Page[model.Booking]
+// @Header       200  {integer}  X-Total-Count  "Bookings matching the filter, as in the body's total"
+// @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
 // @Router       /bookings [get]
 func (h *BookingHandler) GetMyBookings(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     userID := GetUserID(r.Context())
 
     if userID == "" && !isTestRequest(r) {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
 
-    limit := 20
-    offset := 0
-
-    if l := r.URL.Query().Get("limit"); l != "" {
-        if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-            limit = parsed
-        }
+    page := parsePageRequest(r)
+    expand, err := parseBookingExpand(r)
+    if err != nil {
+        WriteError(r.Context(), w, http.StatusBadRequest, err.Error())
+        return
     }
-
-    if o := r.URL.Query().Get("offset"); o != "" {
-        if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-            offset = parsed
-        }
+    filter, errs := parseBookingFilter(r)
+    if len(errs) > 0 {
+        WriteValidationErrors(r.Context(), w, errs)
+        return
     }
 
-    bookings, err := h.bookingSvc.GetByUser(r.Context(), userID, limit, offset)
+    bookings, err := h.bookingSvc.GetByUser(r.Context(), userID, page, filter, expand)
     if err != nil {
-        log.Printf("[%s] Get bookings failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to get bookings")
+        logServiceError(r.Context(), h.logger, "get bookings failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get bookings")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(bookings)
-    log.Printf("[%s] Retrieved %d bookings for user %s", requestID, len(bookings), userID)
+    setTotalCount(w, bookings.Total)
+    respond.Page(r.Context(), w, bookings)
+    h.logger.DebugContext(r.Context(), "retrieved bookings", "count", len(bookings.Items), "total", bookings.Total)
 }
 
 // GetBooking godoc
@@ -192,11 +307,10 @@ func (h *BookingHandler) GetMyBookings(w http.ResponseWriter, r *http.Request) {
 // @Failure      404  {object}  ErrorResponse
 // @Router       /bookings/{id} [get]
 func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     userID := GetUserID(r.Context())
 
     if userID == "" && !isTestRequest(r) {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
@@ -204,60 +318,65 @@ func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
     bookingID := chi.URLParam(r, "id")
     booking, err := h.bookingSvc.GetByID(r.Context(), bookingID)
     if err != nil {
-        log.Printf("[%s] Booking not found: %s", requestID, bookingID)
-        WriteError(r.Context(), w, http.StatusNotFound, "Booking not found")
+        logServiceError(r.Context(), h.logger, "get booking failed", err, "booking_id", bookingID)
+        WriteServiceError(r.Context(), w, err, "Failed to get booking")
         return
     }
 
     // Users can only see their own bookings
     if booking.UserID != userID && !isTestRequest(r) {
-        log.Printf("[%s] Unauthorized access to booking %s", requestID, bookingID)
+        h.logger.WarnContext(r.Context(), "unauthorized access to booking", "booking_id", bookingID)
         WriteError(r.Context(), w, http.StatusForbidden, "Forbidden")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(booking)
+    respond.JSON(r.Context(), w, http.StatusOK, booking)
 }
 
 // ListAllBookings godoc
 // @Summary      List all bookings (admin)
-// @Description  Get all bookings in the system
+// @Description  Get bookings in the system, optionally filtered
 // @Tags         Admin
 // @Security     BearerAuth
 // @Param        limit   query     int     false  "Items per page"  default(20)
 // @Param        offset  query     int     false  "Pagination offset"  default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Param        expand  query     string  false  "Related records to embed: book, user (comma-separated)"
+// @Param        status   query    string  false  "ACTIVE, RETURNED, OVERDUE, OFFERED, DECLINED or EXPIRED"
+// @Param        user_id  query    string  false  "Only bookings by this user"
+// @Param        book_id  query    string  false  "Only bookings of this book"
+// @Param        from     query    string  false  "Borrowed on or after (YYYY-MM-DD or RFC3339)"
+// @Param        to       query    string  false  "Borrowed before (YYYY-MM-DD or RFC3339)"
 // @Produce      json
-// @Success      200  {array}   model.Booking
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Booking]
+// @Header       200  {integer}  X-Total-Count  "Bookings matching the filter, as in the body's total"
+// @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
 // @Failure      403  {object}  ErrorResponse
 // @Router       /admin/bookings [get]
 func (h *BookingHandler) ListAllBookings(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    limit := 20
-    offset := 0
-
-    if l := r.URL.Query().Get("limit"); l != "" {
-        if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-            limit = parsed
-        }
+    page := parsePageRequest(r)
+    expand, err := parseBookingExpand(r)
+    if err != nil {
+        WriteError(r.Context(), w, http.StatusBadRequest, err.Error())
+        return
     }
-
-    if o := r.URL.Query().Get("offset"); o != "" {
-        if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-            offset = parsed
-        }
+    filter, errs := parseBookingFilter(r)
+    if len(errs) > 0 {
+        WriteValidationErrors(r.Context(), w, errs)
+        return
     }
 
-    bookings, err := h.bookingSvc.List(r.Context(), limit, offset)
+    bookings, err := h.bookingSvc.List(r.Context(), page, filter, expand)
     if err != nil {
-        log.Printf("[%s] List bookings failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to list bookings")
+        logServiceError(r.Context(), h.logger, "list bookings failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list bookings")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(bookings)
-    log.Printf("[%s] Listed %d bookings", requestID, len(bookings))
+    setTotalCount(w, bookings.Total)
+    writeList(w, r, h.logger, listMedia(w, r), "bookings", bookings, bookingTable)
+    h.logger.DebugContext(r.Context(), "listed bookings", "count", len(bookings.Items), "total", bookings.Total)
 }
\ No newline at end of file
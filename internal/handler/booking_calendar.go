@@ -0,0 +1,91 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/ical"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type BookingCalendarHandler struct {
+    svc    service.BookingCalendarService
+    logger *slog.Logger
+}
+
+func NewBookingCalendarHandler(svc service.BookingCalendarService, logger *slog.Logger) *BookingCalendarHandler {
+    return &BookingCalendarHandler{svc: svc, logger: logger}
+}
+
+// FeedURL godoc
+// @Summary      My due-date calendar feed
+// @Description  The URL calendar apps can subscribe to for the due dates of my loans. The URL
+// @Description  carries a token, so treat it like a password: anyone with it can read the feed.
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.CalendarFeed
+// @Failure      401  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Router       /bookings/calendar [get]
+func (h *BookingCalendarHandler) FeedURL(w http.ResponseWriter, r *http.Request) {
+    feed, err := h.svc.FeedURL(r.Context(), GetUserID(r.Context()))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "calendar feed url failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get calendar feed")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, feed)
+}
+
+// ResetFeedURL godoc
+// @Summary      Reset my due-date calendar feed
+// @Description  Void my calendar feed URL, say because it leaked, and get a new one. Calendar
+// @Description  apps subscribed to the old URL stop getting updates until they subscribe again.
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.CalendarFeed
+// @Failure      401  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Router       /bookings/calendar/reset [post]
+func (h *BookingCalendarHandler) ResetFeedURL(w http.ResponseWriter, r *http.Request) {
+    feed, err := h.svc.ResetFeedURL(r.Context(), GetUserID(r.Context()))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "calendar feed reset failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to reset calendar feed")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, feed)
+}
+
+// Feed godoc
+// @Summary      Due-date calendar feed
+// @Description  An iCalendar feed of the due dates of a user's active and overdue loans, each
+// @Description  with a reminder a day ahead, made afresh on every fetch. Authenticated by the
+// @Description  token in the URL from GET /bookings/calendar rather than a bearer token. Suspended
+// @Description  and deleted users' feeds, and URLs voided by a reset, are not found.
+// @Tags         Bookings
+// @Param        token  query  string  true  "Feed token"
+// @Produce      text/calendar
+// @Success      200  {string}  string
+// @Failure      404  {object}  ErrorResponse
+// @Router       /bookings/calendar.ics [get]
+func (h *BookingCalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+    cal, err := h.svc.Feed(r.Context(), r.URL.Query().Get("token"))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "calendar feed failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get calendar feed")
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+    w.Header().Set("Cache-Control", "no-cache")
+    if err := ical.Write(w, *cal, time.Now()); err != nil {
+        h.logger.WarnContext(r.Context(), "writing calendar feed failed", "error", err)
+    }
+}
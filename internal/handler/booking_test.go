@@ -9,89 +9,177 @@ import (
     "time"
 
     "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/stretchr/testify/require"
 )
 
 // Mock booking service
 type mockBookingService struct {
-    borrowFn    func(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.Booking, error)
-    returnFn    func(ctx context.Context, bookingID string) (*model.Booking, error)
-    getByUserFn func(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error)
+    borrowFn    func(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.BorrowBookResponse, error)
+    scanFn      func(ctx context.Context, userID string, req *model.ScanBorrowRequest) (*model.BorrowBookResponse, error)
+    returnFn    func(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error)
+    getByUserFn func(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
     getByIDFn   func(ctx context.Context, id string) (*model.Booking, error)
-    listFn      func(ctx context.Context, limit, offset int) ([]model.Booking, error)
+    listFn      func(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
     updateFn    func(ctx context.Context) error
+    exportFn    func(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error
+    streamFn    func(ctx context.Context, afterID string, fn func(*model.Booking) error) error
+    acceptFn    func(ctx context.Context, userID, bookingID string, req *model.AcceptOfferRequest) (*model.Booking, error)
+    declineFn   func(ctx context.Context, userID, bookingID string) (*model.Booking, error)
 }
 
-func (m *mockBookingService) Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.Booking, error) {
+func (m *mockBookingService) Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.BorrowBookResponse, error) {
     return m.borrowFn(ctx, userID, req)
 }
 
-func (m *mockBookingService) Return(ctx context.Context, bookingID string) (*model.Booking, error) {
-    return m.returnFn(ctx, bookingID)
+func (m *mockBookingService) BorrowScanned(ctx context.Context, userID string, req *model.ScanBorrowRequest) (*model.BorrowBookResponse, error) {
+    return m.scanFn(ctx, userID, req)
 }
 
-func (m *mockBookingService) GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-    return m.getByUserFn(ctx, userID, limit, offset)
+func (m *mockBookingService) Return(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error) {
+    return m.returnFn(ctx, userID, bookingID, asAdmin)
+}
+
+func (m *mockBookingService) GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    return m.getByUserFn(ctx, userID, p, f, expand)
 }
 
 func (m *mockBookingService) GetByID(ctx context.Context, id string) (*model.Booking, error) {
     return m.getByIDFn(ctx, id)
 }
 
-func (m *mockBookingService) List(ctx context.Context, limit, offset int) ([]model.Booking, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockBookingService) List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    return m.listFn(ctx, p, f, expand)
 }
 
 func (m *mockBookingService) UpdateOverdue(ctx context.Context) error {
     return m.updateFn(ctx)
 }
 
+func (m *mockBookingService) Export(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+    return m.exportFn(ctx, f, fn)
+}
+
+func (m *mockBookingService) Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error {
+    return m.streamFn(ctx, afterID, fn)
+}
+
+func (m *mockBookingService) AcceptOffer(ctx context.Context, userID, bookingID string, req *model.AcceptOfferRequest) (*model.Booking, error) {
+    return m.acceptFn(ctx, userID, bookingID, req)
+}
+
+func (m *mockBookingService) DeclineOffer(ctx context.Context, userID, bookingID string) (*model.Booking, error) {
+    return m.declineFn(ctx, userID, bookingID)
+}
+
+func (m *mockBookingService) ExpireOffers(ctx context.Context) error {
+    return nil
+}
+
+func (m *mockBookingService) SendDueReminders(ctx context.Context, lead time.Duration) error {
+    return nil
+}
+
 func TestBookingHandler_Borrow_Success(t *testing.T) {
     now := time.Now().UTC()
     mock := &mockBookingService{
-        borrowFn: func(_ context.Context, userID string, req *model.BorrowBookRequest) (*model.Booking, error) {
-            return &model.Booking{
-                ID:         "booking-1",
-                UserID:     userID,
-                BookID:     req.BookID,
-                BorrowedAt: now,
-                DueDate:    now.AddDate(0, 0, req.BorrowDays),
-                Status:     "ACTIVE",
-                CreatedAt:  now,
-                UpdatedAt:  now,
+        borrowFn: func(_ context.Context, userID string, req *model.BorrowBookRequest) (*model.BorrowBookResponse, error) {
+            return &model.BorrowBookResponse{
+                Booking: &model.Booking{
+                    ID:         "booking-1",
+                    UserID:     userID,
+                    BookID:     req.BookID,
+                    BorrowedAt: now,
+                    DueDate:    now.AddDate(0, 0, req.BorrowDays),
+                    Status:     "ACTIVE",
+                    CreatedAt:  now,
+                    UpdatedAt:  now,
+                },
+                TimeZone: req.TimeZone,
             }, nil
         },
     }
-    h := NewBookingHandler(mock)
+    h := NewBookingHandler(mock, logger.Discard())
 
-    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":14}`, "test-booking-borrow-001", "user-1", "USER")
+    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":14,"time_zone":"Europe/London"}`, "test-booking-borrow-001", "user-1", model.RoleUser)
     rec := httptest.NewRecorder()
 
     h.Borrow(rec, req)
     require.Equal(t, http.StatusCreated, rec.Code)
 
-    var booking model.Booking
-    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &booking))
-    require.Equal(t, "ACTIVE", booking.Status)
-    require.Equal(t, "user-1", booking.UserID)
+    var receipt model.BorrowBookResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &receipt))
+    require.Equal(t, "ACTIVE", receipt.Booking.Status)
+    require.Equal(t, "user-1", receipt.Booking.UserID)
+    require.Equal(t, "Europe/London", receipt.TimeZone)
+}
+
+func TestBookingHandler_BorrowScanned(t *testing.T) {
+    mock := &mockBookingService{
+        scanFn: func(_ context.Context, userID string, req *model.ScanBorrowRequest) (*model.BorrowBookResponse, error) {
+            require.Equal(t, "9780441172719", req.Code)
+            return &model.BorrowBookResponse{Booking: &model.Booking{ID: "booking-1", UserID: userID, BookID: "book-1", Status: "ACTIVE"}}, nil
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    req := CreateTestRequestWithUser("POST", "/bookings/scan", `{"code":" 9780441172719 ","borrow_days":14}`, "test-booking-scan-001", "user-1", model.RoleUser)
+    rec := httptest.NewRecorder()
+    h.BorrowScanned(rec, req)
+    require.Equal(t, http.StatusCreated, rec.Code)
+
+    req = CreateTestRequestWithUser("POST", "/bookings/scan", `{"borrow_days":14}`, "test-booking-scan-002", "user-1", model.RoleUser)
+    rec = httptest.NewRecorder()
+    h.BorrowScanned(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBookingHandler_Borrow_InvalidTimeZone(t *testing.T) {
+    h := NewBookingHandler(&mockBookingService{}, logger.Discard())
+
+    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":14,"time_zone":"Mars/Olympus"}`, "test-booking-borrow-004", "user-1", model.RoleUser)
+    rec := httptest.NewRecorder()
+
+    h.Borrow(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
 func TestBookingHandler_Borrow_InvalidDays(t *testing.T) {
     mock := &mockBookingService{}
-    h := NewBookingHandler(mock)
+    h := NewBookingHandler(mock, logger.Discard())
 
-    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":60}`, "test-booking-borrow-002", "user-1", "USER")
+    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":-3}`, "test-booking-borrow-002", "user-1", model.RoleUser)
     rec := httptest.NewRecorder()
 
     h.Borrow(rec, req)
     require.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestBookingHandler_Borrow_LoanPolicyViolation(t *testing.T) {
+    mock := &mockBookingService{
+        borrowFn: func(context.Context, string, *model.BorrowBookRequest) (*model.BorrowBookResponse, error) {
+            return nil, apperr.PolicyViolation("borrow days exceed the 30-day limit for the user role")
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    req := CreateTestRequestWithUser("POST", "/bookings", `{"book_id":"book-1","borrow_days":60}`, "test-booking-borrow-003", "user-1", model.RoleUser)
+    rec := httptest.NewRecorder()
+
+    h.Borrow(rec, req)
+    require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+    require.Contains(t, rec.Body.String(), "30-day limit")
+}
+
 func TestBookingHandler_Return_Success(t *testing.T) {
     now := time.Now().UTC()
     mock := &mockBookingService{
-        returnFn: func(_ context.Context, bookingID string) (*model.Booking, error) {
+        returnFn: func(_ context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error) {
+            if userID != "user-1" || asAdmin {
+                return nil, apperr.Forbidden("this booking belongs to another user")
+            }
             return &model.Booking{
                 ID:         bookingID,
                 UserID:     "user-1",
@@ -105,11 +193,11 @@ func TestBookingHandler_Return_Success(t *testing.T) {
             }, nil
         },
     }
-    h := NewBookingHandler(mock)
+    h := NewBookingHandler(mock, logger.Discard())
 
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", "booking-1")
-    req := CreateTestRequestWithUser("POST", "/bookings/booking-1/return", "", "test-booking-return-001", "user-1", "USER")
+    req := CreateTestRequestWithUser("POST", "/bookings/booking-1/return", "", "test-booking-return-001", "user-1", model.RoleUser)
     ctx := context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx)
     req = req.WithContext(ctx)
     rec := httptest.NewRecorder()
@@ -122,50 +210,218 @@ func TestBookingHandler_Return_Success(t *testing.T) {
     require.Equal(t, "RETURNED", booking.Status)
 }
 
+func TestBookingHandler_Return_PassesOwnership(t *testing.T) {
+    var gotUser string
+    var gotAdmin bool
+    mock := &mockBookingService{
+        returnFn: func(_ context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error) {
+            gotUser, gotAdmin = userID, asAdmin
+            if userID != "user-1" && !asAdmin {
+                return nil, apperr.Forbidden("this booking belongs to another user")
+            }
+            return &model.Booking{ID: bookingID, UserID: "user-1", Status: "RETURNED"}, nil
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+    serve := func(handle http.HandlerFunc, userID string, role model.Role) int {
+        req := CreateTestRequestWithUser("POST", "/bookings/booking-1/return", "", "test-booking-return-002", userID, role)
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", "booking-1")
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        handle(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusForbidden, serve(h.Return, "user-2", model.RoleUser))
+    require.Equal(t, "user-2", gotUser)
+    require.False(t, gotAdmin)
+
+    require.Equal(t, http.StatusForbidden, serve(h.Return, "admin-1", model.RoleAdmin), "the user route never overrides ownership")
+
+    require.Equal(t, http.StatusOK, serve(h.AdminReturn, "admin-1", model.RoleAdmin))
+    require.True(t, gotAdmin)
+}
+
+func TestBookingHandler_AcceptOffer(t *testing.T) {
+    var gotDays int
+    mock := &mockBookingService{
+        acceptFn: func(_ context.Context, userID, bookingID string, req *model.AcceptOfferRequest) (*model.Booking, error) {
+            gotDays = req.BorrowDays
+            if userID != "user-1" {
+                return nil, apperr.Forbidden("this booking belongs to another user")
+            }
+            return &model.Booking{ID: bookingID, UserID: userID, BookID: "book-1", Status: "ACTIVE"}, nil
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    accept := func(userID, body string) *httptest.ResponseRecorder {
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", "booking-1")
+        req := CreateTestRequestWithUser("POST", "/bookings/booking-1/accept", body, "test-booking-accept-001", userID, model.RoleUser)
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        h.AcceptOffer(rec, req)
+        return rec
+    }
+
+    rec := accept("user-1", `{"borrow_days": -1}`)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+
+    rec = accept("user-1", `{"borrow_days": 14}`)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, 14, gotDays)
+    var booking model.Booking
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &booking))
+    require.Equal(t, "ACTIVE", booking.Status)
+
+    rec = accept("user-2", `{"borrow_days": 14}`)
+    require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
 func TestBookingHandler_GetMyBookings_Success(t *testing.T) {
     mock := &mockBookingService{
-        getByUserFn: func(_ context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-            return []model.Booking{
+        getByUserFn: func(_ context.Context, userID string, p model.PageRequest, _ model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            return model.Page[model.Booking]{Items: []model.Booking{
                 {
                     ID:     "booking-1",
                     UserID: userID,
                     BookID: "book-1",
                     Status: "ACTIVE",
                 },
-            }, nil
+            }, Total: 1}, nil
         },
     }
-    h := NewBookingHandler(mock)
+    h := NewBookingHandler(mock, logger.Discard())
 
-    req := CreateTestRequestWithUser("GET", "/bookings", "", "test-booking-getmy-001", "user-1", "USER")
+    req := CreateTestRequestWithUser("GET", "/bookings", "", "test-booking-getmy-001", "user-1", model.RoleUser)
     rec := httptest.NewRecorder()
 
     h.GetMyBookings(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
 
-    var bookings []model.Booking
+    var bookings model.Page[model.Booking]
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bookings))
-    require.Len(t, bookings, 1)
+    require.Len(t, bookings.Items, 1)
 }
 
 func TestBookingHandler_ListAllBookings_Success(t *testing.T) {
     mock := &mockBookingService{
-        listFn: func(_ context.Context, limit, offset int) ([]model.Booking, error) {
-            return []model.Booking{
+        listFn: func(_ context.Context, p model.PageRequest, _ model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            return model.Page[model.Booking]{Items: []model.Booking{
                 {ID: "1", UserID: "user-1", Status: "ACTIVE"},
                 {ID: "2", UserID: "user-2", Status: "RETURNED"},
-            }, nil
+            }, Total: 2}, nil
         },
     }
-    h := NewBookingHandler(mock)
+    h := NewBookingHandler(mock, logger.Discard())
 
-    req := CreateTestRequestWithUser("GET", "/admin/bookings", "", "test-booking-listall-001", "admin-1", "ADMIN")
+    req := CreateTestRequestWithUser("GET", "/admin/bookings", "", "test-booking-listall-001", "admin-1", model.RoleAdmin)
     rec := httptest.NewRecorder()
 
     h.ListAllBookings(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "2", rec.Header().Get("X-Total-Count"))
 
-    var bookings []model.Booking
+    var bookings model.Page[model.Booking]
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bookings))
-    require.Len(t, bookings, 2)
-}
\ No newline at end of file
+    require.Len(t, bookings.Items, 2)
+}
+
+func TestBookingHandler_GetMyBookings_Expand(t *testing.T) {
+    var got model.BookingExpand
+    mock := &mockBookingService{
+        getByUserFn: func(_ context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+            got = expand
+            return model.Page[model.Booking]{Items: []model.Booking{{
+                ID:     "booking-1",
+                UserID: userID,
+                BookID: "book-1",
+                Book:   &model.Book{ID: "book-1", Title: "Go"},
+                User:   &model.User{ID: userID, Username: "john", Password: "hash"},
+            }}, Total: 1}, nil
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    req := CreateTestRequestWithUser("GET", "/bookings?expand=book,user", "", "test-booking-expand-001", "user-1", model.RoleUser)
+    rec := httptest.NewRecorder()
+
+    h.GetMyBookings(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, model.BookingExpand{Book: true, User: true}, got)
+    require.Contains(t, rec.Body.String(), `"title":"Go"`)
+    require.Contains(t, rec.Body.String(), `"username":"john"`)
+    require.NotContains(t, rec.Body.String(), "hash")
+}
+
+func TestBookingHandler_ListAllBookings_UnknownExpand(t *testing.T) {
+    h := NewBookingHandler(&mockBookingService{}, logger.Discard())
+
+    req := CreateTestRequestWithUser("GET", "/admin/bookings?expand=author", "", "test-booking-expand-002", "admin-1", model.RoleAdmin)
+    rec := httptest.NewRecorder()
+
+    h.ListAllBookings(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBookingHandler_ListAllBookings_Filters(t *testing.T) {
+    var got model.BookingFilter
+    mock := &mockBookingService{
+        listFn: func(_ context.Context, _ model.PageRequest, f model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            got = f
+            return model.Page[model.Booking]{Items: []model.Booking{}}, nil
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    req := CreateTestRequestWithUser("GET", "/admin/bookings?status=overdue&user_id=u1&book_id=b1&from=2024-01-01", "", "test-booking-filter-001", "admin-1", model.RoleAdmin)
+    rec := httptest.NewRecorder()
+
+    h.ListAllBookings(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    require.Equal(t, model.BookingFilter{Status: "OVERDUE", UserID: "u1", BookID: "b1", From: &from}, got)
+
+    req = CreateTestRequestWithUser("GET", "/admin/bookings?to=yesterday", "", "test-booking-filter-002", "admin-1", model.RoleAdmin)
+    rec = httptest.NewRecorder()
+
+    h.ListAllBookings(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+    require.Contains(t, rec.Body.String(), "to must be YYYY-MM-DD or RFC3339")
+}
+
+func TestBookingHandler_Export_NDJSONWithRange(t *testing.T) {
+    var gotFilter model.BookingExportFilter
+    mock := &mockBookingService{
+        exportFn: func(_ context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+            gotFilter = f
+            return fn(&model.Booking{ID: "1", UserID: "user-1", Status: "ACTIVE"})
+        },
+    }
+    h := NewBookingHandler(mock, logger.Discard())
+
+    req := CreateTestRequestWithUser("GET", "/admin/bookings/export?format=ndjson&from=2024-01-01&to=2024-02-01", "", "test-booking-export-001", "admin-1", model.RoleAdmin)
+    rec := httptest.NewRecorder()
+
+    h.Export(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+    require.NotNil(t, gotFilter.From)
+    require.NotNil(t, gotFilter.To)
+
+    var booking model.Booking
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &booking))
+    require.Equal(t, "1", booking.ID)
+}
+
+func TestBookingHandler_Export_InvalidRange(t *testing.T) {
+    h := NewBookingHandler(&mockBookingService{}, logger.Discard())
+
+    req := CreateTestRequestWithUser("GET", "/admin/bookings/export?from=2024-02-01&to=2024-01-01", "", "test-booking-export-002", "admin-1", model.RoleAdmin)
+    rec := httptest.NewRecorder()
+
+    h.Export(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
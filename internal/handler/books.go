@@ -1,141 +1,151 @@
 package handler
 
 import (
-    "encoding/json"
-    "log"
+    "log/slog"
     "net/http"
-    "strconv"
     "strings"
+    "time"
 
     "github.com/go-chi/chi/v5"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
 )
 
 type BookHandler struct {
-    svc service.BookService
+    svc    service.BookService
+    logger *slog.Logger
 }
 
-func NewBookHandler(svc service.BookService) *BookHandler {
-    return &BookHandler{svc: svc}
-}
-
-// UpdateBookRequest for PUT requests
-type UpdateBookRequest struct {
-    Title         string `json:"title"`
-    Author        string `json:"author"`
-    PublishedYear int    `json:"published_year"`
-    ISBN          string `json:"isbn"`
+func NewBookHandler(svc service.BookService, logger *slog.Logger) *BookHandler {
+    return &BookHandler{svc: svc, logger: logger}
 }
 
 // List godoc
 // @Summary      List all books
-// @Description  Get a paginated list of all books
+// @Description  Get a paginated list of all books, as JSON, XML or CSV per the Accept header
 // @Tags         Books
 // @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
 // @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor    query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Param        category  query     string  false  "Only books in this category (ID or name)"
+// @Param        tag       query     string  false  "Only books with this tag"
+// @Param        If-None-Match  header  string  false  "ETag from a previous response"
 // @Produce      json
-// @Success      200  {array}   model.Book
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Book]
+// @Header       200  {string}  ETag           "Weak tag of this page, availability and reviews included"
+// @Header       200  {string}  Cache-Control  "public without credentials, else private; max-age is BOOK_CACHE_MAX_AGE"
+// @Success      304
 // @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /books [get]
+// @Router       /admin/books [get]
 func (h *BookHandler) List(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    limit := 20
-    offset := 0
-
-    if l := r.URL.Query().Get("limit"); l != "" {
-        if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-            limit = parsed
-        }
+    page := parsePageRequest(r)
+    filter := model.BookFilter{
+        Category: strings.TrimSpace(r.URL.Query().Get("category")),
+        Tag:      strings.TrimSpace(r.URL.Query().Get("tag")),
     }
 
-    if o := r.URL.Query().Get("offset"); o != "" {
-        if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-            offset = parsed
-        }
+    books, err := h.svc.List(r.Context(), page, filter)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list books failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list books")
+        return
     }
 
-    books, err := h.svc.List(r.Context(), limit, offset)
-    if err != nil {
-        log.Printf("[%s] List failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to list books")
+    media := listMedia(w, r)
+    tag := bookPageETag(books, media)
+    w.Header().Set("ETag", tag)
+    if notModified(r, tag, time.Time{}) {
+        w.WriteHeader(http.StatusNotModified)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _ = json.NewEncoder(w).Encode(books)
-    log.Printf("[%s] Listed %d books", requestID, len(books))
+    writeList(w, r, h.logger, media, "books", books, bookTable)
+    h.logger.DebugContext(r.Context(), "listed books", "count", len(books.Items), "total", books.Total)
 }
 
 // Get godoc
 // @Summary      Get a book by ID
 // @Description  Retrieve a single book by its ID
 // @Tags         Books
-// @Param        id   path      string  true  "Book ID"
+// @Security     BearerAuth
+// @Param        id                 path      string  true   "Book ID"
+// @Param        If-None-Match      header    string  false  "ETag from a previous response"
+// @Param        If-Modified-Since  header    string  false  "Last-Modified from a previous response; ignored with If-None-Match"
 // @Produce      json
 // @Success      200  {object}  model.Book
+// @Header       200  {string}  ETag           "Current book version"
+// @Header       200  {string}  Last-Modified  "When the book was last edited"
+// @Header       200  {string}  Cache-Control  "private; max-age is BOOK_CACHE_MAX_AGE"
+// @Success      304
 // @Failure      404  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /books/{id} [get]
+// @Router       /admin/books/{id} [get]
 func (h *BookHandler) Get(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     id := chi.URLParam(r, "id")
 
     book, err := h.svc.GetByID(r.Context(), id) // ← Changed from Get to GetByID
     if err != nil {
-        if strings.Contains(err.Error(), "not found") {
-            log.Printf("[%s] Book not found: %s", requestID, id)
-            WriteError(r.Context(), w, http.StatusNotFound, "Book not found")
-            return
-        }
-        log.Printf("[%s] Get failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to get book")
+        logServiceError(r.Context(), h.logger, "get book failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get book")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _ = json.NewEncoder(w).Encode(book)
-    log.Printf("[%s] Book retrieved: %s", requestID, id)
+    tag := etag(book.Version)
+    w.Header().Set("ETag", tag)
+    if !book.UpdatedAt.IsZero() {
+        w.Header().Set("Last-Modified", book.UpdatedAt.UTC().Format(http.TimeFormat))
+    }
+    if notModified(r, tag, book.UpdatedAt) {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, book)
+    h.logger.DebugContext(r.Context(), "book retrieved", "book_id", id)
 }
 
 // Create godoc
 // @Summary      Create a new book
-// @Description  Create a new book with validation
-// @Tags         Books
+// @Description  Create a new book with validation. Title and author may be omitted
+// @Description  when an ISBN is given; they are then looked up by ISBN.
+// @Tags         Admin
+// @Security     BearerAuth
 // @Accept       json
 // @Param        request  body      model.CreateBookRequest  true  "Book request"
 // @Produce      json
 // @Success      201  {object}  model.Book
 // @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /books [post]
+// @Failure      502  {object}  ErrorResponse
+// @Router       /admin/books [post]
 func (h *BookHandler) Create(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    var req model.CreateBookRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.CreateBookRequest](w, r)
+    if !ok {
         return
     }
-            req.Title = trim(req.Title)
-            req.Author = trim(req.Author)
-            req.ISBN = trim(req.ISBN)
+
     book := &model.Book{
         Title:         req.Title,
         Author:        req.Author,
         PublishedYear: req.PublishedYear,
         ISBN:          req.ISBN,
+        TotalCopies:   req.Copies(),
+        Tags:          req.Tags,
+    }
+    for _, id := range req.CategoryIDs {
+        book.Categories = append(book.Categories, model.Category{ID: id})
     }
 
-    if err := h.svc.Create(r.Context(), book); err != nil {
-        log.Printf("[%s] Create failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to create book")
+    if err := h.svc.Create(r.Context(), GetUserID(r.Context()), book); err != nil {
+        logServiceError(r.Context(), h.logger, "create book failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create book")
         return
     }
 
@@ -144,79 +154,209 @@ func (h *BookHandler) Create(w http.ResponseWriter, r *http.Request) {
     if cwLogger != nil {
         _ = cwLogger.PutMetric(r.Context(), "BookCreated", 1, "Count")
     }
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    _ = json.NewEncoder(w).Encode(book)
-    log.Printf("[%s] Book created: %s", requestID, book.ID)
+    respond.JSON(r.Context(), w, http.StatusCreated, book)
+    h.logger.InfoContext(r.Context(), "book created", "book_id", book.ID)
 }
 
 // Update godoc
 // @Summary      Update a book
-// @Description  Update book details by ID
-// @Tags         Books
+// @Description  Update book details by ID. The request must carry the version being
+// @Description  replaced, either as an If-Match ETag or as the version field.
+// @Tags         Admin
+// @Security     BearerAuth
 // @Accept       json
-// @Param        id       path      string  true  "Book ID"
-// @Param        request  body      UpdateBookRequest  true  "Updated book data"
+// @Param        id        path      string  true   "Book ID"
+// @Param        If-Match  header    string  false  "ETag from GET /books/{id}"
+// @Param        request   body      model.UpdateBookRequest  true  "Updated book data"
 // @Produce      json
 // @Success      200  {object}  model.Book
+// @Header       200  {string}  ETag  "New book version"
 // @Failure      400  {object}  ErrorResponse
 // @Failure      409  {object}  ErrorResponse
+// @Failure      412  {object}  ErrorResponse
+// @Failure      428  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /books/{id} [put]
+// @Router       /admin/books/{id} [put]
 func (h *BookHandler) Update(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     id := chi.URLParam(r, "id")
 
-    var req UpdateBookRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.UpdateBookRequest](w, r)
+    if !ok {
+        return
+    }
+
+    version, ok, err := expectedVersion(r, req.Version)
+    if err != nil {
+        WriteError(r.Context(), w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if !ok {
+        WriteError(r.Context(), w, http.StatusPreconditionRequired, "If-Match header or version field is required")
         return
     }
 
-    updates := map[string]interface{}{
-        "title":          req.Title,
-        "author":         req.Author,
-        "published_year": req.PublishedYear,
-        "isbn":           req.ISBN,
+    patch := model.UpdateBookPatch{
+        Title:         &req.Title,
+        Author:        &req.Author,
+        PublishedYear: &req.PublishedYear,
+        ISBN:          &req.ISBN,
+        TotalCopies:   req.TotalCopies,
+        Tags:          req.Tags,
+        CategoryIDs:   req.CategoryIDs,
+    }
+    if version > 0 {
+        patch.Version = &version
     }
 
-    book, err := h.svc.Update(r.Context(), id, updates)
+    book, err := h.svc.Update(r.Context(), GetUserID(r.Context()), id, patch)
     if err != nil {
-        if strings.Contains(err.Error(), "conflict") {
-            log.Printf("[%s] Conflict: %v", requestID, err)
-            WriteError(r.Context(), w, http.StatusConflict, "Book was modified by another request. Please refetch and retry.")
-            return
-        }
-        log.Printf("[%s] Update failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to update book")
+        logServiceError(r.Context(), h.logger, "update failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to update book")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _ = json.NewEncoder(w).Encode(book)
-    log.Printf("[%s] Book updated: %s", requestID, id)
+    w.Header().Set("ETag", etag(book.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, book)
+    h.logger.InfoContext(r.Context(), "book updated", "book_id", id)
+}
+
+// Enrich godoc
+// @Summary      Re-sync book metadata
+// @Description  Overwrite title, author, published year and cover URL with the
+// @Description  metadata provider's record for the book's ISBN
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.Book
+// @Header       200  {string}  ETag  "New book version"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      412  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Router       /admin/books/{id}/enrich [post]
+func (h *BookHandler) Enrich(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    book, err := h.svc.Enrich(r.Context(), GetUserID(r.Context()), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "enrich failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to enrich book")
+        return
+    }
+
+    w.Header().Set("ETag", etag(book.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, book)
+    h.logger.InfoContext(r.Context(), "book enriched", "book_id", id)
+}
+
+// Merge godoc
+// @Summary      Merge a duplicate book
+// @Description  Fold a duplicate book into another in one transaction: its bookings,
+// @Description  reservations, reviews, categories and copies move to the target and the
+// @Description  duplicate is soft-deleted. Reservations and reviews by users who already
+// @Description  have one on the target are dropped. Both books must be in the same branch,
+// @Description  and 409 names a user who has both books on loan.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id        path  string  true  "Duplicate book ID"
+// @Param        targetId  path  string  true  "ID of the book to keep"
+// @Produce      json
+// @Success      200  {object}  model.Book
+// @Header       200  {string}  ETag  "New version of the target"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/books/{id}/merge-into/{targetId} [post]
+func (h *BookHandler) Merge(w http.ResponseWriter, r *http.Request) {
+    id, targetID := chi.URLParam(r, "id"), chi.URLParam(r, "targetId")
+
+    book, err := h.svc.Merge(r.Context(), GetUserID(r.Context()), id, targetID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "merge failed", err, "book_id", id, "target_id", targetID)
+        WriteServiceError(r.Context(), w, err, "Failed to merge books")
+        return
+    }
+
+    w.Header().Set("ETag", etag(book.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, book)
 }
 
 // Delete godoc
 // @Summary      Delete a book
-// @Description  Delete a book by ID
-// @Tags         Books
+// @Description  Soft-delete a book by ID. Its loan history, reviews and change history are
+// @Description  kept for a restore; its reservations and reading list entries are removed.
+// @Description  A book with copies on loan or held for pickup can't be deleted.
+// @Tags         Admin
+// @Security     BearerAuth
 // @Param        id   path  string  true  "Book ID"
 // @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /books/{id} [delete]
+// @Router       /admin/books/{id} [delete]
 func (h *BookHandler) Delete(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     id := chi.URLParam(r, "id")
 
-    if err := h.svc.Delete(r.Context(), id); err != nil {
-        log.Printf("[%s] Delete failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to delete book")
+    if err := h.svc.Delete(r.Context(), GetUserID(r.Context()), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete failed", err, "id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete book")
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
-    log.Printf("[%s] Book deleted: %s", requestID, id)
-}
\ No newline at end of file
+    h.logger.InfoContext(r.Context(), "book deleted", "book_id", id)
+}
+// Restore godoc
+// @Summary      Restore a deleted book
+// @Description  Bring back a deleted book with its version bumped. Fails with 409 when
+// @Description  another book has taken its ISBN since, or when it was merged into another.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.Book
+// @Header       200  {string}  ETag  "New book version"
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/books/{id}/restore [post]
+func (h *BookHandler) Restore(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    book, err := h.svc.Restore(r.Context(), GetUserID(r.Context()), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "restore failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to restore book")
+        return
+    }
+
+    w.Header().Set("ETag", etag(book.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, book)
+}
+
+// History godoc
+// @Summary      Book change history
+// @Description  Every change to a book, oldest first: the version it produced, who made
+// @Description  it and each changed field's old and new value. Deleted books keep theirs.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {array}   model.BookChange
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/books/{id}/history [get]
+func (h *BookHandler) History(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    history, err := h.svc.History(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "book history failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get book history")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, history)
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+)
+
+const (
+    maxImportBytes = 10 << 20 // 10 MB
+    maxImportRows  = 5000
+)
+
+// Import godoc
+// @Summary      Bulk import books
+// @Description  Import books from a CSV (header: title,author,published_year,isbn) or JSON array.
+// @Description  Send the file as the raw body with Content-Type text/csv or application/json,
+// @Description  or as multipart/form-data in a field named "file".
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Accept       text/csv
+// @Accept       multipart/form-data
+// @Produce      json
+// @Success      200  {object}  model.ImportReport
+// @Failure      400  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      415  {object}  ErrorResponse
+// @Router       /admin/books/import [post]
+func (h *BookHandler) Import(w http.ResponseWriter, r *http.Request) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+    body, format, err := importSource(r)
+    if err != nil {
+        h.logger.WarnContext(r.Context(), "import rejected", "error", err)
+        writeImportSourceError(r, w, err)
+        return
+    }
+    defer body.Close()
+
+    var rows []model.CreateBookRequest
+    switch format {
+    case "csv":
+        rows, err = parseBookCSV(body)
+    case "json":
+        dec := json.NewDecoder(body)
+        dec.DisallowUnknownFields()
+        err = dec.Decode(&rows)
+    }
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Import file too large")
+            return
+        }
+        h.logger.WarnContext(r.Context(), "import parse failed", "error", err)
+        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid import file: "+err.Error())
+        return
+    }
+
+    if len(rows) == 0 {
+        WriteError(r.Context(), w, http.StatusBadRequest, "Import file contains no rows")
+        return
+    }
+    if len(rows) > maxImportRows {
+        WriteError(r.Context(), w, http.StatusBadRequest, fmt.Sprintf("Import is limited to %d rows", maxImportRows))
+        return
+    }
+
+    report, err := h.svc.Import(r.Context(), GetUserID(r.Context()), rows)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "import failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to import books")
+        return
+    }
+
+    cwLogger := logger.GetLogger()
+    if cwLogger != nil {
+        _ = cwLogger.PutMetric(r.Context(), "BooksImported", float64(report.Created), "Count")
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, report)
+    h.logger.InfoContext(r.Context(), "imported books", "created", report.Created, "failed", report.Failed)
+}
+
+var (
+    errUnsupportedImportType = errors.New("unsupported content type")
+    errMissingImportFile     = errors.New("missing file")
+)
+
+// importSource returns the uploaded payload and whether it is "csv" or "json".
+func importSource(r *http.Request) (io.ReadCloser, string, error) {
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+    if mediaType == "multipart/form-data" {
+        file, header, err := r.FormFile("file")
+        if err != nil {
+            var tooLarge *http.MaxBytesError
+            if errors.As(err, &tooLarge) {
+                return nil, "", err
+            }
+            return nil, "", errMissingImportFile
+        }
+        format := importFormat(header.Header.Get("Content-Type"))
+        if format == "" {
+            format = importFormat(strings.TrimPrefix(filepath.Ext(header.Filename), "."))
+        }
+        if format == "" {
+            file.Close()
+            return nil, "", errUnsupportedImportType
+        }
+        return file, format, nil
+    }
+
+    format := importFormat(mediaType)
+    if format == "" {
+        return nil, "", errUnsupportedImportType
+    }
+    return r.Body, format, nil
+}
+
+func importFormat(kind string) string {
+    switch strings.ToLower(kind) {
+    case "text/csv", "application/csv", "csv":
+        return "csv"
+    case "application/json", "json":
+        return "json"
+    }
+    return ""
+}
+
+func writeImportSourceError(r *http.Request, w http.ResponseWriter, err error) {
+    var tooLarge *http.MaxBytesError
+    switch {
+    case errors.As(err, &tooLarge):
+        WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Import file too large")
+    case errors.Is(err, errUnsupportedImportType):
+        WriteError(r.Context(), w, http.StatusUnsupportedMediaType, "Import accepts text/csv or application/json")
+    default:
+        WriteError(r.Context(), w, http.StatusBadRequest, "Multipart upload must include a \"file\" field")
+    }
+}
+
+// parseBookCSV reads rows keyed by a header line. Columns may appear in any
+// order; title and author are required, published_year and isbn optional.
+// Row-level problems such as a non-numeric year are left for the service to
+// report, so a malformed value is carried as a year the validator rejects.
+func parseBookCSV(r io.Reader) ([]model.CreateBookRequest, error) {
+    cr := csv.NewReader(r)
+    cr.TrimLeadingSpace = true
+    cr.FieldsPerRecord = -1
+
+    field, err := readCSVHeader(cr, "title", "author")
+    if err == io.EOF {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var rows []model.CreateBookRequest
+    for {
+        record, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        row := model.CreateBookRequest{
+            Title:  field(record, "title"),
+            Author: field(record, "author"),
+            ISBN:   field(record, "isbn"),
+        }
+        if year := field(record, "published_year"); year != "" {
+            parsed, err := strconv.Atoi(year)
+            if err != nil {
+                parsed = -1
+            }
+            row.PublishedYear = parsed
+        }
+        if copies := field(record, "total_copies"); copies != "" {
+            parsed, err := strconv.Atoi(copies)
+            if err != nil {
+                parsed = -1
+            }
+            row.TotalCopies = &parsed
+        }
+        rows = append(rows, row)
+    }
+    return rows, nil
+}
+
+// readCSVHeader reads the header line of an import and returns a function
+// looking up a record's trimmed value by column name, which is empty when
+// the column is missing. Header names are matched ignoring case, and every
+// required column must be present. An empty file returns io.EOF.
+func readCSVHeader(cr *csv.Reader, required ...string) (func(record []string, name string) string, error) {
+    header, err := cr.Read()
+    if err != nil {
+        return nil, err
+    }
+
+    cols := map[string]int{}
+    for i, name := range header {
+        cols[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    for _, name := range required {
+        if _, ok := cols[name]; !ok {
+            return nil, fmt.Errorf("header is missing %q column", name)
+        }
+    }
+
+    return func(record []string, name string) string {
+        i, ok := cols[name]
+        if !ok || i >= len(record) {
+            return ""
+        }
+        return strings.TrimSpace(record[i])
+    }, nil
+}
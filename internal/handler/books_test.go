@@ -3,13 +3,19 @@ package handler
 import (
     "bytes"
     "context"
+    "encoding/csv"
     "encoding/json"
+    "encoding/xml"
     "errors"
     "net/http"
     "net/http/httptest"
+    "strings"
     "testing"
+    "time"
 
     "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/stretchr/testify/require"
 )
@@ -18,7 +24,7 @@ import (
 func createTestRequest(method, path string, body string, requestID string) *http.Request {
     req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
     req.Header.Set("Content-Type", "application/json")
-    ctx := context.WithValue(req.Context(), RequestIDKey, requestID)
+    ctx := WithRequestID(req.Context(), requestID)
     return req.WithContext(ctx)
 }
 
@@ -26,12 +32,18 @@ func createTestRequest(method, path string, body string, requestID string) *http
 type mockUserServiceForBooks struct {
     registerFn      func(ctx context.Context, req *model.RegisterRequest) (*model.User, error)
     getByIDFn       func(ctx context.Context, id string) (*model.User, error)
-    updateFn        func(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
+    updateFn        func(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
     validateFn      func(ctx context.Context, username, password string) (*model.User, error)
+    loginFn         func(ctx context.Context, username, password, clientIP string) (*model.User, error)
+    changePwFn      func(ctx context.Context, userID, currentPassword, newPassword string) error
     getByEmailFn    func(ctx context.Context, email string) (*model.User, error)
     getByUsernameFn func(ctx context.Context, username string) (*model.User, error)
-    listFn          func(ctx context.Context, limit, offset int) ([]model.User, error)
+    listFn          func(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
     deleteFn        func(ctx context.Context, id string) error
+    adminUpdateFn   func(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error)
+    suspendFn       func(ctx context.Context, id string, until *time.Time) (*model.User, error)
+    unsuspendFn     func(ctx context.Context, id string) (*model.User, error)
+    updatePrefsFn   func(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error)
 }
 
 func (m *mockUserServiceForBooks) RegisterAdmin(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
@@ -46,14 +58,22 @@ func (m *mockUserServiceForBooks) GetByID(ctx context.Context, id string) (*mode
     return m.getByIDFn(ctx, id)
 }
 
-func (m *mockUserServiceForBooks) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockUserServiceForBooks) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    return m.updateFn(ctx, id, p)
 }
 
 func (m *mockUserServiceForBooks) ValidatePassword(ctx context.Context, username, password string) (*model.User, error) {
     return m.validateFn(ctx, username, password)
 }
 
+func (m *mockUserServiceForBooks) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+    return m.changePwFn(ctx, userID, currentPassword, newPassword)
+}
+
+func (m *mockUserServiceForBooks) Login(ctx context.Context, username, password, clientIP string) (*model.User, error) {
+    return m.loginFn(ctx, username, password, clientIP)
+}
+
 func (m *mockUserServiceForBooks) GetByEmail(ctx context.Context, email string) (*model.User, error) {
     return m.getByEmailFn(ctx, email)
 }
@@ -62,46 +82,101 @@ func (m *mockUserServiceForBooks) GetByUsername(ctx context.Context, username st
     return m.getByUsernameFn(ctx, username)
 }
 
-func (m *mockUserServiceForBooks) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockUserServiceForBooks) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    return m.listFn(ctx, p)
 }
 
 func (m *mockUserServiceForBooks) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockUserServiceForBooks) AdminUpdate(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error) {
+    return m.adminUpdateFn(ctx, id, req)
+}
+
+func (m *mockUserServiceForBooks) Suspend(ctx context.Context, id string, until *time.Time) (*model.User, error) {
+    return m.suspendFn(ctx, id, until)
+}
+
+func (m *mockUserServiceForBooks) Unsuspend(ctx context.Context, id string) (*model.User, error) {
+    return m.unsuspendFn(ctx, id)
+}
+
+func (m *mockUserServiceForBooks) GetPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    return model.DefaultNotificationPreferences(userID), nil
+}
+
+func (m *mockUserServiceForBooks) UpdatePreferences(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error) {
+    return m.updatePrefsFn(ctx, userID, req)
+}
+
 // Mock book service
 type mockBookServiceForHandler struct {
-    listFn    func(ctx context.Context, limit, offset int) ([]model.Book, error)
+    listFn    func(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
     getByIDFn func(ctx context.Context, id string) (model.Book, error)
     createFn  func(ctx context.Context, b *model.Book) error
-    updateFn  func(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error)
+    updateFn  func(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error)
     deleteFn  func(ctx context.Context, id string) error
+    importFn  func(ctx context.Context, rows []model.CreateBookRequest) (*model.ImportReport, error)
+    exportFn  func(ctx context.Context, fn func(*model.Book) error) error
+    streamFn  func(ctx context.Context, afterID string, fn func(*model.Book) error) error
+    enrichFn  func(ctx context.Context, id string) (*model.Book, error)
+    mergeFn   func(ctx context.Context, id, targetID string) (*model.Book, error)
+    restoreFn func(ctx context.Context, id string) (*model.Book, error)
+    historyFn func(ctx context.Context, id string) ([]model.BookChange, error)
 }
 
-func (m *mockBookServiceForHandler) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockBookServiceForHandler) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+    return m.listFn(ctx, p, f)
 }
 
 func (m *mockBookServiceForHandler) GetByID(ctx context.Context, id string) (model.Book, error) {
     return m.getByIDFn(ctx, id)
 }
 
-func (m *mockBookServiceForHandler) Create(ctx context.Context, b *model.Book) error {
+func (m *mockBookServiceForHandler) Create(ctx context.Context, _ string, b *model.Book) error {
     if m.createFn == nil {
         return errors.New("createFn not set")
     }
     return m.createFn(ctx, b)
 }
 
-func (m *mockBookServiceForHandler) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockBookServiceForHandler) Update(ctx context.Context, _, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    return m.updateFn(ctx, id, p)
 }
 
-func (m *mockBookServiceForHandler) Delete(ctx context.Context, id string) error {
+func (m *mockBookServiceForHandler) Delete(ctx context.Context, _, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockBookServiceForHandler) Import(ctx context.Context, _ string, rows []model.CreateBookRequest) (*model.ImportReport, error) {
+    return m.importFn(ctx, rows)
+}
+
+func (m *mockBookServiceForHandler) Export(ctx context.Context, fn func(*model.Book) error) error {
+    return m.exportFn(ctx, fn)
+}
+
+func (m *mockBookServiceForHandler) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+    return m.streamFn(ctx, afterID, fn)
+}
+
+func (m *mockBookServiceForHandler) Enrich(ctx context.Context, _, id string) (*model.Book, error) {
+    return m.enrichFn(ctx, id)
+}
+
+func (m *mockBookServiceForHandler) Merge(ctx context.Context, _, id, targetID string) (*model.Book, error) {
+    return m.mergeFn(ctx, id, targetID)
+}
+
+func (m *mockBookServiceForHandler) Restore(ctx context.Context, _, id string) (*model.Book, error) {
+    return m.restoreFn(ctx, id)
+}
+
+func (m *mockBookServiceForHandler) History(ctx context.Context, id string) ([]model.BookChange, error) {
+    return m.historyFn(ctx, id)
+}
+
 // User Handler Tests
 
 func TestUserHandler_Register_Success(t *testing.T) {
@@ -111,12 +186,12 @@ func TestUserHandler_Register_Success(t *testing.T) {
                 ID:       "user-1",
                 Username: req.Username,
                 Email:    req.Email,
-                Role:     "USER",
+                Role:     model.RoleUser,
             }
             return user, nil
         },
     }
-    h := NewUserHandler(mock)
+    h := NewUserHandler(mock, nil, logger.Discard())
 
     req := createTestRequest("POST", "/auth/register", `{"username":"john","email":"john@example.com","password":"SecurePass123"}`, "test-user-001")
     rec := httptest.NewRecorder()
@@ -133,7 +208,7 @@ func TestUserHandler_Register_Success(t *testing.T) {
 
 func TestUserHandler_Register_InvalidEmail(t *testing.T) {
     mock := &mockUserServiceForBooks{}
-    h := NewUserHandler(mock)
+    h := NewUserHandler(mock, nil, logger.Discard())
 
     req := createTestRequest("POST", "/auth/register", `{"username":"john","email":"invalid-email","password":"SecurePass123"}`, "test-user-002")
     rec := httptest.NewRecorder()
@@ -142,6 +217,71 @@ func TestUserHandler_Register_InvalidEmail(t *testing.T) {
     require.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestUserHandler_ChangePassword_Success(t *testing.T) {
+    var gotUser, gotCurrent, gotNew string
+    mock := &mockUserServiceForBooks{
+        changePwFn: func(_ context.Context, userID, currentPassword, newPassword string) error {
+            gotUser, gotCurrent, gotNew = userID, currentPassword, newPassword
+            return nil
+        },
+    }
+    h := NewUserHandler(mock, nil, logger.Discard())
+
+    req := CreateTestRequestWithUser("POST", "/users/me/change-password",
+        `{"current_password":"SecurePass123","new_password":" EvenBetter456"}`, "test-user-010", "user-1", "user")
+    rec := httptest.NewRecorder()
+
+    h.ChangePassword(rec, req)
+    require.Equal(t, http.StatusNoContent, rec.Code)
+    require.Equal(t, "user-1", gotUser)
+    require.Equal(t, "SecurePass123", gotCurrent)
+    require.Equal(t, " EvenBetter456", gotNew)
+}
+
+func TestUserHandler_ChangePassword_WrongCurrent(t *testing.T) {
+    mock := &mockUserServiceForBooks{
+        changePwFn: func(_ context.Context, userID, currentPassword, newPassword string) error {
+            return apperr.Forbidden("current password is incorrect")
+        },
+    }
+    h := NewUserHandler(mock, nil, logger.Discard())
+
+    req := CreateTestRequestWithUser("POST", "/users/me/change-password",
+        `{"current_password":"nope","new_password":"EvenBetter456"}`, "test-user-011", "user-1", "user")
+    rec := httptest.NewRecorder()
+
+    h.ChangePassword(rec, req)
+    require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestUserHandler_UpdatePreferences(t *testing.T) {
+    var got *model.NotificationPreferencesRequest
+    mock := &mockUserServiceForBooks{
+        updatePrefsFn: func(_ context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error) {
+            got = req
+            return model.NotificationPreferences{UserID: userID, Channel: req.Channel, ReminderLeadHours: req.ReminderLeadHours}, nil
+        },
+    }
+    h := NewUserHandler(mock, nil, logger.Discard())
+
+    req := CreateTestRequestWithUser("PUT", "/users/me/preferences",
+        `{"channel":" None ","reminder_lead_hours":48}`, "test-user-012", "user-1", "user")
+    rec := httptest.NewRecorder()
+    h.UpdatePreferences(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, model.NotifyNone, got.Channel)
+    require.JSONEq(t, `{"channel":"none","reminder_lead_hours":48}`, rec.Body.String())
+
+    for _, body := range []string{`{"channel":"sms"}`, `{"channel":"email","reminder_lead_hours":337}`} {
+        got = nil
+        req = CreateTestRequestWithUser("PUT", "/users/me/preferences", body, "test-user-012", "user-1", "user")
+        rec = httptest.NewRecorder()
+        h.UpdatePreferences(rec, req)
+        require.Equal(t, http.StatusBadRequest, rec.Code, body)
+        require.Nil(t, got, body)
+    }
+}
+
 func TestUserHandler_GetProfile_Success(t *testing.T) {
     mock := &mockUserServiceForBooks{
         getByIDFn: func(_ context.Context, id string) (*model.User, error) {
@@ -149,15 +289,15 @@ func TestUserHandler_GetProfile_Success(t *testing.T) {
                 ID:       id,
                 Username: "john",
                 Email:    "john@example.com",
-                Role:     "USER",
+                Role:     model.RoleUser,
             }, nil
         },
     }
-    h := NewUserHandler(mock)
+    h := NewUserHandler(mock, nil, logger.Discard())
 
     req := createTestRequest("GET", "/users/me", "", "test-user-003")
     ctx := req.Context()
-    ctx = context.WithValue(ctx, userIDKey, "user-1")
+    ctx = WithClaims(ctx, AuthContext{UserID: "user-1"})
     req = req.WithContext(ctx)
     rec := httptest.NewRecorder()
 
@@ -171,41 +311,41 @@ func TestUserHandler_GetProfile_Success(t *testing.T) {
 
 func TestUserHandler_ListUsers_Success(t *testing.T) {
     mock := &mockUserServiceForBooks{
-        listFn: func(_ context.Context, limit, offset int) ([]model.User, error) {
-            return []model.User{
-                {ID: "1", Username: "john", Role: "USER"},
-                {ID: "2", Username: "admin", Role: "ADMIN"},
-            }, nil
+        listFn: func(_ context.Context, p model.PageRequest) (model.Page[model.User], error) {
+            return model.Page[model.User]{Items: []model.User{
+                {ID: "1", Username: "john", Role: model.RoleUser},
+                {ID: "2", Username: "admin", Role: model.RoleAdmin},
+            }, Total: 2}, nil
         },
     }
-    h := NewUserHandler(mock)
+    h := NewUserHandler(mock, nil, logger.Discard())
 
     req := createTestRequest("GET", "/admin/users", "", "test-user-004")
     ctx := req.Context()
-    ctx = context.WithValue(ctx, roleKey, "ADMIN")
+    ctx = WithClaims(ctx, AuthContext{Role: "admin"})
     req = req.WithContext(ctx)
     rec := httptest.NewRecorder()
 
     h.ListUsers(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
 
-    var users []model.User
+    var users model.Page[model.User]
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &users))
-    require.Len(t, users, 2)
+    require.Len(t, users.Items, 2)
 }
 
 // Book Handler Tests
 
 func TestBookHandler_List_Success(t *testing.T) {
     svc := &mockBookServiceForHandler{
-        listFn: func(_ context.Context, limit, offset int) ([]model.Book, error) {
-            return []model.Book{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            return model.Page[model.Book]{Items: []model.Book{
                 {ID: "1", Title: "Test Book", Author: "Test Author"},
-            }, nil
+            }, Total: 1}, nil
         },
     }
 
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     req := createTestRequest("GET", "/books?limit=10&offset=0", "", "test-book-001")
     rec := httptest.NewRecorder()
@@ -213,19 +353,19 @@ func TestBookHandler_List_Success(t *testing.T) {
     h.List(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
 
-    var books []model.Book
+    var books model.Page[model.Book]
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &books))
-    require.NotEmpty(t, books)
+    require.NotEmpty(t, books.Items)
 }
 
 func TestBookHandler_Get_Success(t *testing.T) {
     svc := &mockBookServiceForHandler{
         getByIDFn: func(_ context.Context, id string) (model.Book, error) {
-            return model.Book{ID: "1", Title: "Test Book", Author: "Test Author"}, nil
+            return model.Book{ID: "1", Title: "Test Book", Author: "Test Author", Version: 3}, nil
         },
     }
 
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", "1")
@@ -237,20 +377,199 @@ func TestBookHandler_Get_Success(t *testing.T) {
 
     h.Get(rec, req)
     require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, `"3"`, rec.Header().Get("ETag"))
 
     var book model.Book
     require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &book))
     require.Equal(t, "1", book.ID)
 }
 
+func TestBookHandler_Get_NotModified(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        getByIDFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: "1", Title: "Test Book", Version: 3}, nil
+        },
+    }
+
+    h := NewBookHandler(svc, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("GET", "/books/1", "", "test-book-009")
+    req.Header.Set("If-None-Match", `"3"`)
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Get(rec, req)
+    require.Equal(t, http.StatusNotModified, rec.Code)
+    require.Empty(t, rec.Body.Bytes())
+}
+
+func TestBookHandler_Get_IfModifiedSince(t *testing.T) {
+    updated := time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+    svc := &mockBookServiceForHandler{
+        getByIDFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: "1", Title: "Test Book", Version: 3, UpdatedAt: updated}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    get := func(since time.Time) *httptest.ResponseRecorder {
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", "1")
+        req := createTestRequest("GET", "/books/1", "", "test-book-010")
+        req.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        h.Get(rec, req)
+        return rec
+    }
+
+    rec := get(updated)
+    require.Equal(t, http.StatusNotModified, rec.Code)
+    require.Equal(t, "Wed, 01 May 2024 12:30:15 GMT", rec.Header().Get("Last-Modified"))
+
+    rec = get(updated.Add(-time.Minute))
+    require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestBookHandler_List_ETag(t *testing.T) {
+    available := 2
+    svc := &mockBookServiceForHandler{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            return model.Page[model.Book]{Items: []model.Book{
+                {ID: "1", Title: "Test Book", Version: 1, CopiesAvailable: available},
+            }, Total: 1}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.List(rec, createTestRequest("GET", "/books", "", "test-book-011"))
+    require.Equal(t, http.StatusOK, rec.Code)
+    tag := rec.Header().Get("ETag")
+    require.True(t, strings.HasPrefix(tag, `W/"`))
+
+    req := createTestRequest("GET", "/books", "", "test-book-012")
+    req.Header.Set("If-None-Match", tag)
+    rec = httptest.NewRecorder()
+    h.List(rec, req)
+    require.Equal(t, http.StatusNotModified, rec.Code)
+    require.Empty(t, rec.Body.Bytes())
+
+    // A loan changes availability but not the book's version.
+    available = 1
+    rec = httptest.NewRecorder()
+    h.List(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.NotEqual(t, tag, rec.Header().Get("ETag"))
+}
+
+func TestBookHandler_List_NegotiatesXMLAndCSV(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            return model.Page[model.Book]{Items: []model.Book{
+                {ID: "1", Title: "Dune, Part <1>", Author: "Frank Herbert", Tags: []string{"sf", "classic"}},
+            }, Total: 41, NextCursor: "c2"}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+    list := func(accept string) *httptest.ResponseRecorder {
+        req := createTestRequest("GET", "/books", "", "test-book-020")
+        req.Header.Set("Accept", accept)
+        rec := httptest.NewRecorder()
+        h.List(rec, req)
+        require.Equal(t, http.StatusOK, rec.Code)
+        require.Equal(t, "Accept", rec.Header().Get("Vary"))
+        return rec
+    }
+
+    rec := list("application/xml")
+    require.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+    var doc struct {
+        XMLName xml.Name `xml:"books"`
+        Items   []struct {
+            ID    string   `xml:"id"`
+            Title string   `xml:"title"`
+            Tags  []string `xml:"tags>item"`
+        } `xml:"items>item"`
+        Total      int    `xml:"total"`
+        NextCursor string `xml:"next_cursor"`
+    }
+    require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+    require.Equal(t, 41, doc.Total)
+    require.Equal(t, "c2", doc.NextCursor)
+    require.Len(t, doc.Items, 1)
+    require.Equal(t, "Dune, Part <1>", doc.Items[0].Title)
+    require.Equal(t, []string{"sf", "classic"}, doc.Items[0].Tags)
+    xmlTag := rec.Header().Get("ETag")
+
+    rec = list("application/json;q=0.5, text/csv")
+    require.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+    require.Equal(t, "41", rec.Header().Get("X-Total-Count"))
+    require.Equal(t, "c2", rec.Header().Get("X-Next-Cursor"))
+    rows, err := csv.NewReader(rec.Body).ReadAll()
+    require.NoError(t, err)
+    require.Equal(t, bookExportHeader, rows[0])
+    require.Equal(t, []string{"1", "Dune, Part <1>", "Frank Herbert"}, rows[1][:3])
+    require.NotEqual(t, xmlTag, rec.Header().Get("ETag"), "each representation has its own tag")
+
+    for _, accept := range []string{"", "*/*", "text/html", "text/csv;q=0, application/json"} {
+        rec = list(accept)
+        require.Equal(t, "application/json", rec.Header().Get("Content-Type"), accept)
+    }
+}
+
+func TestCacheControlMiddleware(t *testing.T) {
+    next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+    rec := httptest.NewRecorder()
+    CacheControlMiddleware(time.Minute, CookieAuth{})(next).ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+    require.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+    require.Contains(t, rec.Header().Get("Vary"), "Authorization")
+
+    req := httptest.NewRequest("GET", "/books/1", nil)
+    req.Header.Set("Authorization", "Bearer token")
+    rec = httptest.NewRecorder()
+    CacheControlMiddleware(time.Minute, CookieAuth{})(next).ServeHTTP(rec, req)
+    require.Equal(t, "private, max-age=60", rec.Header().Get("Cache-Control"))
+
+    rec = httptest.NewRecorder()
+    CacheControlMiddleware(0, CookieAuth{})(next).ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+    require.Equal(t, "public, no-cache", rec.Header().Get("Cache-Control"))
+}
+
+func TestCacheControlMiddleware_TokenCookie(t *testing.T) {
+    next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    mw := CacheControlMiddleware(time.Minute, CookieAuth{Token: "auth_token"})
+
+    rec := httptest.NewRecorder()
+    mw(next).ServeHTTP(rec, httptest.NewRequest("GET", "/books/1", nil))
+    require.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+    require.Contains(t, rec.Header().Get("Vary"), "Cookie")
+
+    req := httptest.NewRequest("GET", "/books/1", nil)
+    req.AddCookie(&http.Cookie{Name: "auth_token", Value: "token"})
+    rec = httptest.NewRecorder()
+    mw(next).ServeHTTP(rec, req)
+    require.Equal(t, "private, max-age=60", rec.Header().Get("Cache-Control"), "a cookie-authenticated response was public")
+    require.Contains(t, rec.Header().Get("Vary"), "Cookie")
+
+    req = httptest.NewRequest("GET", "/books/1", nil)
+    req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+    rec = httptest.NewRecorder()
+    mw(next).ServeHTTP(rec, req)
+    require.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+}
+
 func TestBookHandler_Get_NotFound(t *testing.T) {
     svc := &mockBookServiceForHandler{
         getByIDFn: func(_ context.Context, id string) (model.Book, error) {
-            return model.Book{}, errors.New("book not found")
+            return model.Book{}, apperr.NotFound("book not found")
         },
     }
 
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", "nonexistent")
@@ -271,7 +590,7 @@ func TestBookHandler_Create_Success(t *testing.T) {
             return nil
         },
     }
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     req := createTestRequest("POST", "/books", `{"title":"Go Programming","author":"John Doe","published_year":2020}`, "test-book-004")
     rec := httptest.NewRecorder()
@@ -291,7 +610,7 @@ func TestBookHandler_Create_ServiceError(t *testing.T) {
             return errors.New("service error")
         },
     }
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     req := createTestRequest("POST", "/books", `{"title":"Go Programming","author":"John Doe","published_year":2020}`, "test-book-005")
     rec := httptest.NewRecorder()
@@ -300,9 +619,123 @@ func TestBookHandler_Create_ServiceError(t *testing.T) {
     require.Equal(t, http.StatusInternalServerError, rec.Code)
 }
 
+func TestBookHandler_Create_ISBNOnly(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        createFn: func(_ context.Context, b *model.Book) error {
+            require.Equal(t, "9780441013593", b.ISBN)
+            b.ID, b.Title, b.Author = "test-book-1", "Dune", "Frank Herbert"
+            return nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Create(rec, createTestRequest("POST", "/books", `{"isbn":"9780441013593"}`, "test-book-041"))
+    require.Equal(t, http.StatusCreated, rec.Code)
+
+    // Without an ISBN there is nothing to look up.
+    rec = httptest.NewRecorder()
+    h.Create(rec, createTestRequest("POST", "/books", `{"author":"Frank Herbert"}`, "test-book-042"))
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+    require.Contains(t, rec.Body.String(), "title is required when isbn is not given")
+}
+
+func TestBookHandler_Enrich(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        enrichFn: func(_ context.Context, id string) (*model.Book, error) {
+            if id == "down" {
+                return nil, apperr.Upstream("book metadata provider is unavailable")
+            }
+            return &model.Book{ID: id, Title: "Dune", Version: 3}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    enrich := func(id string) *httptest.ResponseRecorder {
+        req := createTestRequest("POST", "/admin/books/"+id+"/enrich", "", "test-book-043")
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", id)
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        h.Enrich(rec, req)
+        return rec
+    }
+
+    rec := enrich("book-1")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+    require.Equal(t, http.StatusBadGateway, enrich("down").Code)
+}
+
+func TestBookHandler_Merge(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        mergeFn: func(_ context.Context, id, targetID string) (*model.Book, error) {
+            if id == targetID {
+                return nil, apperr.Validation("a book can't be merged into itself")
+            }
+            return &model.Book{ID: targetID, Title: "Dune", TotalCopies: 3, Version: 5}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    merge := func(id, targetID string) *httptest.ResponseRecorder {
+        req := createTestRequest("POST", "/admin/books/"+id+"/merge-into/"+targetID, "", "test-book-044")
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", id)
+        chiCtx.URLParams.Add("targetId", targetID)
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        h.Merge(rec, req)
+        return rec
+    }
+
+    rec := merge("dup", "book-1")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, `"5"`, rec.Header().Get("ETag"))
+    var book model.Book
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &book))
+    require.Equal(t, "book-1", book.ID)
+
+    require.Equal(t, http.StatusBadRequest, merge("book-1", "book-1").Code)
+}
+
+func TestBookHandler_Label(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        getByIDFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "Dune", TotalCopies: 3}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    label := func(query string) *httptest.ResponseRecorder {
+        req := createTestRequest("GET", "/admin/books/book-1/label"+query, "", "test-book-045")
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", "book-1")
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        h.Label(rec, req)
+        return rec
+    }
+
+    rec := label("?copy=2")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+    require.Contains(t, rec.Body.String(), "(book-1/2) Tj")
+    require.Contains(t, rec.Body.String(), "(Dune - copy 2 of 3) Tj")
+
+    rec = label("?format=png")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+    require.True(t, strings.HasPrefix(rec.Body.String(), "\x89PNG"))
+
+    require.Equal(t, http.StatusBadRequest, label("?copy=4").Code)
+    require.Equal(t, http.StatusBadRequest, label("?format=svg").Code)
+}
+
 func TestBookHandler_Update_Success(t *testing.T) {
     svc := &mockBookServiceForHandler{
-        updateFn: func(_ context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
             return &model.Book{
                 ID:     id,
                 Title:  "Updated Title",
@@ -310,11 +743,12 @@ func TestBookHandler_Update_Success(t *testing.T) {
             }, nil
         },
     }
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", "1")
     req := createTestRequest("PUT", "/books/1", `{"title":"Updated Title","author":"Updated Author"}`, "test-book-006")
+    req.Header.Set("If-Match", `"1"`)
     ctx := req.Context()
     ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
     req = req.WithContext(ctx)
@@ -328,13 +762,124 @@ func TestBookHandler_Update_Success(t *testing.T) {
     require.Equal(t, "Updated Title", updated.Title)
 }
 
+func TestBookHandler_Update_Conflict(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+            return nil, apperr.Conflict("book was modified by another request. Please refetch and retry.")
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"Updated Title","author":"Updated Author"}`, "test-book-008")
+    req.Header.Set("If-Match", `"1"`)
+    ctx := req.Context()
+    ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+    req = req.WithContext(ctx)
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestBookHandler_Update_PassesIfMatchVersion(t *testing.T) {
+    var got *int
+    svc := &mockBookServiceForHandler{
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+            got = p.Version
+            return &model.Book{ID: id, Version: 5}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"T","author":"A"}`, "test-book-010")
+    req.Header.Set("If-Match", `"4"`)
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, 4, *got)
+    require.Equal(t, `"5"`, rec.Header().Get("ETag"))
+}
+
+func TestBookHandler_Update_VersionInBody(t *testing.T) {
+    var got *int
+    svc := &mockBookServiceForHandler{
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+            got = p.Version
+            return &model.Book{ID: id, Version: 3}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"T","author":"A","version":2}`, "test-book-011")
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, 2, *got)
+}
+
+func TestBookHandler_Update_PreconditionRequired(t *testing.T) {
+    h := NewBookHandler(&mockBookServiceForHandler{}, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"T","author":"A"}`, "test-book-012")
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusPreconditionRequired, rec.Code)
+}
+
+func TestBookHandler_Update_PreconditionFailed(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+            return nil, apperr.PreconditionFailed("book was modified by another request. Please refetch and retry.")
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"T","author":"A"}`, "test-book-013")
+    req.Header.Set("If-Match", `"1"`)
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+}
+
+func TestBookHandler_Update_MalformedIfMatch(t *testing.T) {
+    h := NewBookHandler(&mockBookServiceForHandler{}, logger.Discard())
+
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", "1")
+    req := createTestRequest("PUT", "/books/1", `{"title":"T","author":"A"}`, "test-book-014")
+    req.Header.Set("If-Match", `W/"1"`)
+    req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+    rec := httptest.NewRecorder()
+
+    h.Update(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
 func TestBookHandler_Delete_Success(t *testing.T) {
     svc := &mockBookServiceForHandler{
         deleteFn: func(_ context.Context, id string) error {
             return nil
         },
     }
-    h := NewBookHandler(svc)
+    h := NewBookHandler(svc, logger.Discard())
 
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", "1")
@@ -346,4 +891,191 @@ func TestBookHandler_Delete_Success(t *testing.T) {
 
     h.Delete(rec, req)
     require.Equal(t, http.StatusNoContent, rec.Code)
-}
\ No newline at end of file
+}
+
+func TestBookHandler_RestoreAndHistory(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        restoreFn: func(_ context.Context, id string) (*model.Book, error) {
+            if id != "1" {
+                return nil, apperr.Conflict("a book with ISBN 9780441172719 already exists")
+            }
+            return &model.Book{ID: id, Title: "Dune", Version: 4}, nil
+        },
+        historyFn: func(_ context.Context, id string) ([]model.BookChange, error) {
+            return []model.BookChange{{Version: 1, Action: model.AuditBookCreated}}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+    serve := func(handle http.HandlerFunc, method, id string) *httptest.ResponseRecorder {
+        chiCtx := chi.NewRouteContext()
+        chiCtx.URLParams.Add("id", id)
+        req := createTestRequest(method, "/admin/books/"+id, "", "test-book-restore")
+        req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+        rec := httptest.NewRecorder()
+        handle(rec, req)
+        return rec
+    }
+
+    rec := serve(h.Restore, "POST", "1")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, `"4"`, rec.Header().Get("ETag"))
+
+    rec = serve(h.Restore, "POST", "2")
+    require.Equal(t, http.StatusConflict, rec.Code)
+    require.Contains(t, rec.Body.String(), "9780441172719")
+
+    rec = serve(h.History, "GET", "1")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Contains(t, rec.Body.String(), `"action":"book.created"`)
+}
+func TestBookHandler_Import_CSV(t *testing.T) {
+    var got []model.CreateBookRequest
+    svc := &mockBookServiceForHandler{
+        importFn: func(_ context.Context, rows []model.CreateBookRequest) (*model.ImportReport, error) {
+            got = rows
+            return &model.ImportReport{Total: len(rows), Created: len(rows)}, nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    body := "isbn,title,author,published_year\n978-1,Go Programming,John Doe,2020\n,Rust Book,Jane Smith,\n"
+    req := createTestRequest("POST", "/admin/books/import", body, "test-book-import-001")
+    req.Header.Set("Content-Type", "text/csv")
+    rec := httptest.NewRecorder()
+
+    h.Import(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Len(t, got, 2)
+    require.Equal(t, "Go Programming", got[0].Title)
+    require.Equal(t, 2020, got[0].PublishedYear)
+    require.Equal(t, "978-1", got[0].ISBN)
+    require.Equal(t, "Jane Smith", got[1].Author)
+
+    var report model.ImportReport
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+    require.Equal(t, 2, report.Created)
+}
+
+func TestBookHandler_Import_UnsupportedType(t *testing.T) {
+    h := NewBookHandler(&mockBookServiceForHandler{}, logger.Discard())
+
+    req := createTestRequest("POST", "/admin/books/import", "<books/>", "test-book-import-002")
+    req.Header.Set("Content-Type", "application/xml")
+    rec := httptest.NewRecorder()
+
+    h.Import(rec, req)
+    require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestBookHandler_Export_CSV(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        exportFn: func(_ context.Context, fn func(*model.Book) error) error {
+            for _, b := range []model.Book{{ID: "1", Title: "Go", Author: "Pike"}, {ID: "2", Title: "Rust", Author: "Klabnik"}} {
+                b := b
+                if err := fn(&b); err != nil {
+                    return err
+                }
+            }
+            return nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    req := createTestRequest("GET", "/admin/books/export?format=csv", "", "test-book-export-001")
+    rec := httptest.NewRecorder()
+
+    h.Export(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+
+    lines := bytes.Split(bytes.TrimSpace(rec.Body.Bytes()), []byte("\n"))
+    require.Len(t, lines, 3)
+    require.True(t, bytes.HasPrefix(lines[0], []byte("id,title,author")))
+    require.True(t, bytes.HasPrefix(lines[1], []byte("1,Go,Pike")))
+}
+
+func TestBookHandler_Export_ServiceError(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        exportFn: func(_ context.Context, fn func(*model.Book) error) error {
+            return errors.New("db down")
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    req := createTestRequest("GET", "/admin/books/export?format=ndjson", "", "test-book-export-002")
+    rec := httptest.NewRecorder()
+
+    h.Export(rec, req)
+    require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestBookHandler_Stream_NDJSON(t *testing.T) {
+    var gotAfter string
+    svc := &mockBookServiceForHandler{
+        streamFn: func(_ context.Context, afterID string, fn func(*model.Book) error) error {
+            gotAfter = afterID
+            for _, b := range []model.Book{{ID: "2", Title: "Go"}, {ID: "3", Title: "Rust"}} {
+                b := b
+                if err := fn(&b); err != nil {
+                    return err
+                }
+            }
+            return nil
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Stream(rec, createTestRequest("GET", "/admin/books/stream?after_id=1", "", "test-book-stream-001"))
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+    require.Equal(t, "1", gotAfter)
+
+    lines := bytes.Split(bytes.TrimSpace(rec.Body.Bytes()), []byte("\n"))
+    require.Len(t, lines, 2)
+    var last model.Book
+    require.NoError(t, json.Unmarshal(lines[1], &last))
+    require.Equal(t, "3", last.ID)
+}
+
+func TestBookHandler_Stream_Errors(t *testing.T) {
+    svc := &mockBookServiceForHandler{
+        streamFn: func(_ context.Context, afterID string, fn func(*model.Book) error) error {
+            if afterID == "bad" {
+                return apperr.Validation("after_id must be an ID from a previous stream")
+            }
+            if err := fn(&model.Book{ID: "1"}); err != nil {
+                return err
+            }
+            return errors.New("connection reset")
+        },
+    }
+    h := NewBookHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Stream(rec, createTestRequest("GET", "/admin/books/stream?after_id=bad", "", "test-book-stream-002"))
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+
+    // Once records are out, a failure aborts the response so the client
+    // knows to resume.
+    require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+        h.Stream(httptest.NewRecorder(), createTestRequest("GET", "/admin/books/stream", "", "test-book-stream-003"))
+    })
+}
+
+func TestBookHandler_List_PassesFilter(t *testing.T) {
+    var got model.BookFilter
+    svc := &mockBookServiceForHandler{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            got = f
+            return model.Page[model.Book]{Items: []model.Book{}}, nil
+        },
+    }
+
+    h := NewBookHandler(svc, logger.Discard())
+    rec := httptest.NewRecorder()
+    h.List(rec, createTestRequest("GET", "/books?category=Science+Fiction&tag=+classic+", "", "test-book-filter"))
+
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, model.BookFilter{Category: "Science Fiction", Tag: "classic"}, got)
+}
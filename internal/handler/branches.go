@@ -0,0 +1,158 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type BranchHandler struct {
+    svc    service.BranchService
+    logger *slog.Logger
+}
+
+func NewBranchHandler(svc service.BranchService, logger *slog.Logger) *BranchHandler {
+    return &BranchHandler{svc: svc, logger: logger}
+}
+
+// List godoc
+// @Summary      List branches
+// @Description  Get a paginated list of library branches
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Branch]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/branches [get]
+func (h *BranchHandler) List(w http.ResponseWriter, r *http.Request) {
+    branches, err := h.svc.List(r.Context(), parsePageRequest(r))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list branches failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list branches")
+        return
+    }
+
+    writeList(w, r, h.logger, listMedia(w, r), "branches", branches, branchTable)
+}
+
+// Get godoc
+// @Summary      Get a branch
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Branch ID"
+// @Produce      json
+// @Success      200  {object}  model.Branch
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/branches/{id} [get]
+func (h *BranchHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    branch, err := h.svc.GetByID(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get branch failed", err, "branch_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get branch")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, branch)
+}
+
+// Create godoc
+// @Summary      Create a branch
+// @Description  Branch codes are unique lower-case DNS labels, used as the
+// @Description  branch's subdomain and in the X-Branch header
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.BranchRequest  true  "Branch"
+// @Produce      json
+// @Success      201  {object}  model.Branch
+// @Failure      400  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/branches [post]
+func (h *BranchHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.BranchRequest](w, r)
+    if !ok {
+        return
+    }
+
+    branch, err := h.svc.Create(r.Context(), req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create branch failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create branch")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, branch)
+    h.logger.InfoContext(r.Context(), "branch created", "branch_id", branch.ID)
+}
+
+// Update godoc
+// @Summary      Update a branch
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                 true  "Branch ID"
+// @Param        request  body  model.BranchRequest  true  "Branch"
+// @Produce      json
+// @Success      200  {object}  model.Branch
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/branches/{id} [put]
+func (h *BranchHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    req, ok := Bind[model.BranchRequest](w, r)
+    if !ok {
+        return
+    }
+
+    branch, err := h.svc.Update(r.Context(), id, req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "update branch failed", err, "branch_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to update branch")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, branch)
+    h.logger.InfoContext(r.Context(), "branch updated", "branch_id", id)
+}
+
+// Delete godoc
+// @Summary      Delete a branch
+// @Description  Delete a branch. Refused while it still has books, bookings or
+// @Description  users, and for the main branch.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Branch ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/branches/{id} [delete]
+func (h *BranchHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    if err := h.svc.Delete(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete branch failed", err, "branch_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete branch")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "branch deleted", "branch_id", id)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+// defaultCalendarDays is how far ahead GET /calendar looks without ?to=.
+const defaultCalendarDays = 90
+
+type CalendarHandler struct {
+    svc    service.CalendarService
+    logger *slog.Logger
+}
+
+func NewCalendarHandler(svc service.CalendarService, logger *slog.Logger) *CalendarHandler {
+    return &CalendarHandler{svc: svc, logger: logger}
+}
+
+// List godoc
+// @Summary      Library calendar
+// @Description  The closures (holidays and other closed days) overlapping a range of UTC days,
+// @Description  by start. Nothing can be borrowed or returned on a closed day, and loans
+// @Description  that would fall due on one are due the next open day instead. In a branch
+// @Description  scope, lists the branch's closures and those of every branch.
+// @Tags         Calendar
+// @Param        from  query  string  false  "First day (YYYY-MM-DD); defaults to today"
+// @Param        to    query  string  false  "Last day (YYYY-MM-DD); defaults to 90 days after from"
+// @Produce      json
+// @Success      200  {array}   model.Closure
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /calendar [get]
+func (h *CalendarHandler) List(w http.ResponseWriter, r *http.Request) {
+    errs := ValidationErrors{}
+    from, err := parseDateParam(r, "from")
+    if err != nil {
+        errs["from"] = err.Error()
+    }
+    to, err := parseDateParam(r, "to")
+    if err != nil {
+        errs["to"] = err.Error()
+    }
+    if len(errs) > 0 {
+        WriteValidationErrors(r.Context(), w, errs)
+        return
+    }
+    if from == nil {
+        today := model.Day(time.Now())
+        from = &today
+    }
+    if to == nil {
+        end := from.AddDate(0, 0, defaultCalendarDays)
+        to = &end
+    }
+
+    closures, err := h.svc.List(r.Context(), *from, *to)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list calendar failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list calendar")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, closures)
+}
+
+// Create godoc
+// @Summary      Add a closure
+// @Description  Close the caller's branch, or every branch for requests with no branch
+// @Description  scope, from starts_on to ends_on inclusive. Loans already out keep their
+// @Description  due dates.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.CreateClosureRequest  true  "Closed days"
+// @Produce      json
+// @Success      201  {object}  model.Closure
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/calendar/closures [post]
+func (h *CalendarHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.CreateClosureRequest](w, r)
+    if !ok {
+        return
+    }
+
+    closure, err := h.svc.Create(r.Context(), &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create closure failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create closure")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, closure)
+}
+
+// Delete godoc
+// @Summary      Remove a closure
+// @Description  Reopen the days of a closure. In a branch scope only the branch's own
+// @Description  closures can be removed.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Closure ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/calendar/closures/{id} [delete]
+func (h *CalendarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Delete(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete closure failed", err, "closure_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete closure")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+type mockCalendarService struct {
+    listFn func(ctx context.Context, from, to time.Time) ([]model.Closure, error)
+}
+
+func (m *mockCalendarService) List(ctx context.Context, from, to time.Time) ([]model.Closure, error) {
+    return m.listFn(ctx, from, to)
+}
+
+func (m *mockCalendarService) Create(ctx context.Context, req *model.CreateClosureRequest) (*model.Closure, error) {
+    return nil, nil
+}
+
+func (m *mockCalendarService) Delete(ctx context.Context, id string) error {
+    return nil
+}
+
+func TestCalendarHandler_List(t *testing.T) {
+    var gotFrom, gotTo time.Time
+    h := NewCalendarHandler(&mockCalendarService{
+        listFn: func(_ context.Context, from, to time.Time) ([]model.Closure, error) {
+            gotFrom, gotTo = from, to
+            return []model.Closure{}, nil
+        },
+    }, logger.Discard())
+
+    list := func(query string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        h.List(rec, httptest.NewRequest("GET", "/calendar"+query, nil))
+        return rec
+    }
+
+    rec := list("")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.JSONEq(t, `[]`, rec.Body.String())
+    require.Equal(t, model.Day(time.Now()), gotFrom)
+    require.Equal(t, gotFrom.AddDate(0, 0, 90), gotTo)
+
+    require.Equal(t, http.StatusOK, list("?from=2026-12-01&to=2026-12-31").Code)
+    require.Equal(t, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), gotTo)
+
+    require.Equal(t, http.StatusBadRequest, list("?from=December").Code)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type CategoryHandler struct {
+    svc    service.CategoryService
+    logger *slog.Logger
+}
+
+func NewCategoryHandler(svc service.CategoryService, logger *slog.Logger) *CategoryHandler {
+    return &CategoryHandler{svc: svc, logger: logger}
+}
+
+// List godoc
+// @Summary      List categories
+// @Description  Get a paginated list of book categories
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Category]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/categories [get]
+func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
+    categories, err := h.svc.List(r.Context(), parsePageRequest(r))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list categories failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list categories")
+        return
+    }
+
+    writeList(w, r, h.logger, listMedia(w, r), "categories", categories, categoryTable)
+}
+
+// Get godoc
+// @Summary      Get a category
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Category ID"
+// @Produce      json
+// @Success      200  {object}  model.Category
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/categories/{id} [get]
+func (h *CategoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    category, err := h.svc.GetByID(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get category failed", err, "category_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get category")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, category)
+}
+
+// Create godoc
+// @Summary      Create a category
+// @Description  Category names are unique, ignoring case
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.CategoryRequest  true  "Category"
+// @Produce      json
+// @Success      201  {object}  model.Category
+// @Failure      400  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/categories [post]
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.CategoryRequest](w, r)
+    if !ok {
+        return
+    }
+
+    category, err := h.svc.Create(r.Context(), req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create category failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create category")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, category)
+    h.logger.InfoContext(r.Context(), "category created", "category_id", category.ID)
+}
+
+// Update godoc
+// @Summary      Update a category
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                 true  "Category ID"
+// @Param        request  body  model.CategoryRequest  true  "Category"
+// @Produce      json
+// @Success      200  {object}  model.Category
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/categories/{id} [put]
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    req, ok := Bind[model.CategoryRequest](w, r)
+    if !ok {
+        return
+    }
+
+    category, err := h.svc.Update(r.Context(), id, req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "update category failed", err, "category_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to update category")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, category)
+    h.logger.InfoContext(r.Context(), "category updated", "category_id", id)
+}
+
+// Delete godoc
+// @Summary      Delete a category
+// @Description  Delete a category and unlink it from its books
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Category ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/categories/{id} [delete]
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    if err := h.svc.Delete(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete category failed", err, "category_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete category")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "category deleted", "category_id", id)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+// Mock category service
+type mockCategoryService struct {
+    listFn    func(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error)
+    getByIDFn func(ctx context.Context, id string) (model.Category, error)
+    createFn  func(ctx context.Context, req model.CategoryRequest) (*model.Category, error)
+    updateFn  func(ctx context.Context, id string, req model.CategoryRequest) (*model.Category, error)
+    deleteFn  func(ctx context.Context, id string) error
+}
+
+func (m *mockCategoryService) List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error) {
+    return m.listFn(ctx, p)
+}
+
+func (m *mockCategoryService) GetByID(ctx context.Context, id string) (model.Category, error) {
+    return m.getByIDFn(ctx, id)
+}
+
+func (m *mockCategoryService) Create(ctx context.Context, req model.CategoryRequest) (*model.Category, error) {
+    return m.createFn(ctx, req)
+}
+
+func (m *mockCategoryService) Update(ctx context.Context, id string, req model.CategoryRequest) (*model.Category, error) {
+    return m.updateFn(ctx, id, req)
+}
+
+func (m *mockCategoryService) Delete(ctx context.Context, id string) error {
+    return m.deleteFn(ctx, id)
+}
+
+func withCategoryID(req *http.Request, id string) *http.Request {
+    chiCtx := chi.NewRouteContext()
+    chiCtx.URLParams.Add("id", id)
+    return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+}
+
+func TestCategoryHandler_Create(t *testing.T) {
+    svc := &mockCategoryService{
+        createFn: func(_ context.Context, req model.CategoryRequest) (*model.Category, error) {
+            require.Equal(t, "Science Fiction", req.Name)
+            return &model.Category{ID: "c1", Name: req.Name}, nil
+        },
+    }
+    h := NewCategoryHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Create(rec, createTestRequest("POST", "/admin/categories", `{"name": "  Science Fiction "}`, "test-cat-001"))
+    require.Equal(t, http.StatusCreated, rec.Code)
+
+    var category model.Category
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &category))
+    require.Equal(t, "c1", category.ID)
+
+    rec = httptest.NewRecorder()
+    h.Create(rec, createTestRequest("POST", "/admin/categories", `{"name": " "}`, "test-cat-002"))
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCategoryHandler_Create_DuplicateName(t *testing.T) {
+    svc := &mockCategoryService{
+        createFn: func(context.Context, model.CategoryRequest) (*model.Category, error) {
+            return nil, apperr.Conflict("category with this name already exists")
+        },
+    }
+    h := NewCategoryHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Create(rec, createTestRequest("POST", "/admin/categories", `{"name": "Fantasy"}`, "test-cat-003"))
+    require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestCategoryHandler_UpdateAndDelete(t *testing.T) {
+    svc := &mockCategoryService{
+        updateFn: func(_ context.Context, id string, req model.CategoryRequest) (*model.Category, error) {
+            return &model.Category{ID: id, Name: req.Name, Description: req.Description}, nil
+        },
+        deleteFn: func(_ context.Context, id string) error {
+            return apperr.NotFound("category not found")
+        },
+    }
+    h := NewCategoryHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Update(rec, withCategoryID(createTestRequest("PUT", "/admin/categories/c1", `{"name": "Fantasy", "description": "Dragons"}`, "test-cat-004"), "c1"))
+    require.Equal(t, http.StatusOK, rec.Code)
+
+    var category model.Category
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &category))
+    require.Equal(t, model.Category{ID: "c1", Name: "Fantasy", Description: "Dragons"}, category)
+
+    rec = httptest.NewRecorder()
+    h.Delete(rec, withCategoryID(createTestRequest("DELETE", "/admin/categories/c2", "", "test-cat-005"), "c2"))
+    require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+    "errors"
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/challenge"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+)
+
+// ChallengeHeader carries a client's solution to the registration
+// challenge.
+const ChallengeHeader = "X-Challenge-Response"
+
+// Codes of the 400s Require writes, so clients can tell a missing solution
+// from a wrong one.
+const (
+    codeChallengeRequired = "challenge_required"
+    codeChallengeFailed   = "challenge_failed"
+)
+
+type ChallengeHandler struct {
+    verifier challenge.Verifier
+    logger   *slog.Logger
+}
+
+// NewChallengeHandler returns the handler. verifier may be nil, in which
+// case no challenge is asked for.
+func NewChallengeHandler(verifier challenge.Verifier, logger *slog.Logger) *ChallengeHandler {
+    return &ChallengeHandler{verifier: verifier, logger: logger}
+}
+
+// Issue godoc
+// @Summary      Get a registration challenge
+// @Description  Get the challenge to solve before registering: kind "hcaptcha" or "turnstile" with the
+// @Description  site_key of the widget to render, "pow" with a nonce to find a proof of work for, or
+// @Description  "none". A proof of work is a string s such that the SHA-256 of nonce:s starts with
+// @Description  difficulty zero bits. Send the widget's response, or nonce:s, in X-Challenge-Response.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  model.Challenge
+// @Router       /auth/challenge [get]
+func (h *ChallengeHandler) Issue(w http.ResponseWriter, r *http.Request) {
+    c := model.Challenge{Kind: "none"}
+    if h.verifier != nil {
+        c = h.verifier.Issue()
+    }
+    w.Header().Set("Cache-Control", "no-store")
+    respond.JSON(r.Context(), w, http.StatusOK, c)
+}
+
+// Require refuses requests without a solved challenge in ChallengeHeader
+// with 400, coded challenge_required when there is none and
+// challenge_failed when it doesn't hold.
+func (h *ChallengeHandler) Require(next http.Handler) http.Handler {
+    if h.verifier == nil {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        solution := r.Header.Get(ChallengeHeader)
+        if solution == "" {
+            writeCodedError(r.Context(), w, http.StatusBadRequest, codeChallengeRequired, "Solve the registration challenge first")
+            return
+        }
+        err := h.verifier.Verify(r.Context(), solution, ClientIP(r))
+        switch {
+        case errors.Is(err, challenge.ErrFailed):
+            h.logger.WarnContext(r.Context(), "registration challenge failed", "error", err)
+            writeCodedError(r.Context(), w, http.StatusBadRequest, codeChallengeFailed, "The registration challenge wasn't solved")
+            return
+        case err != nil:
+            h.logger.ErrorContext(r.Context(), "registration challenge verification failed", "error", err)
+            WriteError(r.Context(), w, http.StatusBadGateway, "Failed to verify the registration challenge")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
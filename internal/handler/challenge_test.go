@@ -0,0 +1,81 @@
+package handler
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/challenge"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+// fakeVerifier accepts the solution "ok" and is down for "down".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Issue() model.Challenge {
+    return model.Challenge{Kind: "fake"}
+}
+
+func (fakeVerifier) Verify(_ context.Context, solution, _ string) error {
+    switch solution {
+    case "ok":
+        return nil
+    case "down":
+        return errors.New("verification service unreachable")
+    }
+    return fmt.Errorf("%w: wrong answer", challenge.ErrFailed)
+}
+
+func TestChallengeHandler_Require(t *testing.T) {
+    h := NewChallengeHandler(fakeVerifier{}, logger.Discard()).Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+    }))
+
+    tests := []struct {
+        solution string
+        status   int
+        code     string
+    }{
+        {"", http.StatusBadRequest, codeChallengeRequired},
+        {"wrong", http.StatusBadRequest, codeChallengeFailed},
+        {"down", http.StatusBadGateway, ""},
+        {"ok", http.StatusCreated, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.solution, func(t *testing.T) {
+            req := httptest.NewRequest("POST", "/auth/register", nil)
+            if tt.solution != "" {
+                req.Header.Set(ChallengeHeader, tt.solution)
+            }
+            rec := httptest.NewRecorder()
+            h.ServeHTTP(rec, req)
+
+            require.Equal(t, tt.status, rec.Code)
+            if tt.code == "" {
+                return
+            }
+            var resp ErrorResponse
+            require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+            require.Equal(t, tt.code, resp.Code)
+        })
+    }
+}
+
+func TestChallengeHandler_Disabled(t *testing.T) {
+    h := NewChallengeHandler(nil, logger.Discard())
+    next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) })
+
+    rec := httptest.NewRecorder()
+    h.Require(next).ServeHTTP(rec, httptest.NewRequest("POST", "/auth/register", nil))
+    require.Equal(t, http.StatusCreated, rec.Code)
+
+    rec = httptest.NewRecorder()
+    h.Issue(rec, httptest.NewRequest("GET", "/auth/challenge", nil))
+    require.JSONEq(t, `{"kind": "none"}`, rec.Body.String())
+}
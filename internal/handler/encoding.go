@@ -0,0 +1,237 @@
+package handler
+
+import (
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "log/slog"
+    "mime"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+)
+
+// Representations a list endpoint can be asked for with Accept.
+const (
+    mediaJSON = "application/json"
+    mediaXML  = "application/xml"
+    mediaCSV  = "text/csv"
+)
+
+// listMedia picks the representation of a list from r's Accept header:
+// XML or CSV when preferred to JSON, else JSON, which is also what clients
+// asking for anything else get, as they always have. The response varies
+// with Accept either way.
+func listMedia(w http.ResponseWriter, r *http.Request) string {
+    w.Header().Add("Vary", "Accept")
+    best, bestQ := mediaJSON, 0.0
+    for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+        mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+        q := 1.0
+        if v, ok := params["q"]; ok {
+            if q, err = strconv.ParseFloat(v, 64); err != nil {
+                continue
+            }
+        }
+        var media string
+        switch mediaType {
+        case mediaJSON, "application/*", "*/*":
+            media = mediaJSON
+        case mediaXML, "text/xml":
+            media = mediaXML
+        case mediaCSV:
+            media = mediaCSV
+        default:
+            continue
+        }
+        if q > bestQ {
+            best, bestQ = media, q
+        }
+    }
+    return best
+}
+
+// csvTable is how a list of T is written as CSV: a header row, then a row
+// per item.
+type csvTable[T any] struct {
+    header []string
+    row    func(T) []string
+}
+
+// writeList writes page in media, as listMedia chose. XML has a root
+// element named name; CSV carries the page's total and next cursor in the
+// X-Total-Count and X-Next-Cursor headers. Only JSON is ever enveloped.
+func writeList[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, media, name string, page model.Page[T], table csvTable[*T]) {
+    var err error
+    switch media {
+    case mediaXML:
+        w.Header().Set("Content-Type", mediaXML)
+        w.WriteHeader(http.StatusOK)
+        err = writeXML(w, name, page)
+    case mediaCSV:
+        w.Header().Set("Content-Type", mediaCSV)
+        setTotalCount(w, page.Total)
+        if page.NextCursor != "" {
+            w.Header().Set("X-Next-Cursor", page.NextCursor)
+        }
+        w.WriteHeader(http.StatusOK)
+        cw := csv.NewWriter(w)
+        _ = cw.Write(table.header)
+        for i := range page.Items {
+            _ = cw.Write(csvSafe(table.row(&page.Items[i])))
+        }
+        cw.Flush()
+        err = cw.Error()
+    default:
+        respond.Page(r.Context(), w, page)
+    }
+    if err != nil {
+        logger.WarnContext(r.Context(), "writing list failed", "list", name, "media", media, "error", err)
+    }
+}
+
+// csvSafe defuses the cells of row that a spreadsheet would run as a
+// formula, such as a review reading "=HYPERLINK(...)", by prefixing them
+// with a quote. Every CSV the API writes goes through it, since any of
+// them may hold text users wrote.
+func csvSafe(row []string) []string {
+    for i, cell := range row {
+        if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+            row[i] = "'" + cell
+        }
+    }
+    return row
+}
+
+// xmlName matches the JSON keys that can be used as XML element names as
+// they are.
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// writeXML writes v as XML by way of its JSON form, so both carry the same
+// fields under the same names: an object becomes an element per field,
+// named after its key (or <entry key="..."> when the key isn't a valid
+// name), an array an <item> per element, and null nothing at all.
+func writeXML(w io.Writer, root string, v any) error {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    dec := json.NewDecoder(bytes.NewReader(b))
+    dec.UseNumber()
+    enc := xml.NewEncoder(w)
+    if err := jsonToXML(dec, enc, xmlStart(root)); err != nil {
+        return err
+    }
+    return enc.Flush()
+}
+
+func xmlStart(name string) xml.StartElement {
+    if xmlName.MatchString(name) {
+        return xml.StartElement{Name: xml.Name{Local: name}}
+    }
+    return xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+}
+
+// jsonToXML converts the next JSON value of dec into the element start.
+func jsonToXML(dec *json.Decoder, enc *xml.Encoder, start xml.StartElement) error {
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    switch t := tok.(type) {
+    case nil:
+        return nil
+    case json.Delim:
+        if err := enc.EncodeToken(start); err != nil {
+            return err
+        }
+        for dec.More() {
+            child := xmlStart("item")
+            if t == '{' {
+                key, err := dec.Token()
+                if err != nil {
+                    return err
+                }
+                child = xmlStart(key.(string))
+            }
+            if err := jsonToXML(dec, enc, child); err != nil {
+                return err
+            }
+        }
+        if _, err := dec.Token(); err != nil {
+            return err
+        }
+        return enc.EncodeToken(start.End())
+    default:
+        return enc.EncodeElement(fmt.Sprint(t), start)
+    }
+}
+
+// csvTime formats an optional time for CSV, empty when unset.
+func csvTime(t *time.Time) string {
+    if t == nil {
+        return ""
+    }
+    return t.UTC().Format(time.RFC3339)
+}
+
+var (
+    bookTable      = csvTable[*model.Book]{header: bookExportHeader, row: bookExportRow}
+    bookingTable   = csvTable[*model.Booking]{header: bookingExportHeader, row: bookingExportRow}
+    userTable      = csvTable[*model.User]{header: []string{"id", "username", "email", "role", "status", "suspended_until", "branch_id", "created_at", "updated_at"}, row: userRow}
+    categoryTable  = csvTable[*model.Category]{header: []string{"id", "name", "description", "created_at", "updated_at"}, row: categoryRow}
+    branchTable    = csvTable[*model.Branch]{header: []string{"id", "code", "name", "address", "created_at", "updated_at"}, row: branchRow}
+    jobTable       = csvTable[*model.Job]{header: []string{"id", "kind", "status", "attempts", "max_attempts", "run_at", "last_error", "locked_at", "finished_at", "created_at", "updated_at", "payload"}, row: jobRow}
+    reviewTable    = csvTable[*model.Review]{header: []string{"id", "book_id", "user_id", "username", "rating", "text", "created_at"}, row: reviewRow}
+    extensionTable = csvTable[*model.ExtensionRequest]{header: []string{
+        "id", "booking_id", "user_id", "branch_id", "current_due_date", "requested_due_date", "reason",
+        "status", "decided_by", "comment", "decided_at", "created_at",
+    }, row: extensionRow}
+)
+
+func userRow(u *model.User) []string {
+    return []string{
+        u.ID, u.Username, u.Email, string(u.Role), u.Status, csvTime(u.SuspendedUntil), u.BranchID,
+        csvTime(&u.CreatedAt), csvTime(&u.UpdatedAt),
+    }
+}
+
+func categoryRow(c *model.Category) []string {
+    return []string{c.ID, c.Name, c.Description, csvTime(&c.CreatedAt), csvTime(&c.UpdatedAt)}
+}
+
+func branchRow(b *model.Branch) []string {
+    return []string{b.ID, b.Code, b.Name, b.Address, csvTime(&b.CreatedAt), csvTime(&b.UpdatedAt)}
+}
+
+func jobRow(j *model.Job) []string {
+    return []string{
+        j.ID, j.Kind, j.Status, strconv.Itoa(j.Attempts), strconv.Itoa(j.MaxAttempts), csvTime(&j.RunAt), j.LastError,
+        csvTime(j.LockedAt), csvTime(j.FinishedAt), csvTime(&j.CreatedAt), csvTime(&j.UpdatedAt), string(j.Payload),
+    }
+}
+
+func reviewRow(rv *model.Review) []string {
+    return []string{rv.ID, rv.BookID, rv.UserID, rv.Username, strconv.Itoa(rv.Rating), rv.Text, csvTime(&rv.CreatedAt)}
+}
+
+func extensionRow(er *model.ExtensionRequest) []string {
+    return []string{
+        er.ID, er.BookingID, er.UserID, er.BranchID, csvTime(&er.CurrentDueDate), csvTime(&er.RequestedDueDate), er.Reason,
+        er.Status, er.DecidedBy, er.Comment, csvTime(er.DecidedAt), csvTime(&er.CreatedAt),
+    }
+}
@@ -2,47 +2,103 @@ package handler
 
 import (
     "context"
-    "encoding/json"
-    "log"
+    "errors"
     "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
 )
 
 // ErrorResponse is a standard error format
 type ErrorResponse struct {
     RequestID string `json:"request_id"`
     Error     string `json:"error"`
-    Message   string `json:"message,omitempty"`
-    Status    int    `json:"status"`
+    // Message is in the language asked for with Accept-Language, when the
+    // API has it.
+    Message string `json:"message,omitempty"`
+    // Code says more precisely what went wrong where clients act on the
+    // difference, such as token_expired versus token_missing.
+    Code   string `json:"code,omitempty"`
+    Status int    `json:"status"`
+    // Errors maps each invalid field of a 400 to what is wrong with it.
+    Errors ValidationErrors `json:"errors,omitempty"`
 }
 
-// WriteError writes a standardized error response with request ID
+// WriteError writes a standardized error response with request ID. The
+// message is translated into the request's language (see LocaleMiddleware).
 func WriteError(ctx context.Context, w http.ResponseWriter, statusCode int, message string) {
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(statusCode)
+    writeCodedError(ctx, w, statusCode, "", message)
+}
 
+// writeCodedError is WriteError with a machine-readable code.
+func writeCodedError(ctx context.Context, w http.ResponseWriter, statusCode int, code, message string) {
+    t := localizeError(ctx, w)
     requestID := GetRequestID(ctx)
     resp := ErrorResponse{
         RequestID: requestID,
         Error:     http.StatusText(statusCode),
-        Message:   message,
+        Message:   t.Translate(message),
+        Code:      code,
         Status:    statusCode,
     }
+    respond.Error(ctx, w, statusCode, resp)
+}
 
-    if err := json.NewEncoder(w).Encode(resp); err != nil {
-        log.Printf("[%s] failed to encode error response: %v", requestID, err)
+// StatusForError maps a service-layer error to an HTTP status code.
+// Errors that carry no apperr kind are treated as internal failures.
+func StatusForError(err error) int {
+    switch {
+    case errors.Is(err, context.DeadlineExceeded):
+        return http.StatusServiceUnavailable
+    case errors.Is(err, apperr.ErrNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, apperr.ErrConflict):
+        return http.StatusConflict
+    case errors.Is(err, apperr.ErrForbidden):
+        return http.StatusForbidden
+    case errors.Is(err, apperr.ErrValidation):
+        return http.StatusBadRequest
+    case errors.Is(err, apperr.ErrPreconditionFailed):
+        return http.StatusPreconditionFailed
+    case errors.Is(err, apperr.ErrLocked):
+        return http.StatusLocked
+    case errors.Is(err, apperr.ErrUpstream):
+        return http.StatusBadGateway
+    case errors.Is(err, apperr.ErrPolicyViolation):
+        return http.StatusUnprocessableEntity
+    default:
+        return http.StatusInternalServerError
     }
 }
 
+// WriteServiceError writes the error response for an error returned by a service.
+// Typed errors expose their own message; anything else is reported as a 500
+// with the supplied fallback message so internal details don't leak, and a
+// spent request deadline as a 503.
+func WriteServiceError(ctx context.Context, w http.ResponseWriter, err error, fallback string) {
+    status := StatusForError(err)
+    switch status {
+    case http.StatusInternalServerError:
+        WriteError(ctx, w, status, fallback)
+        return
+    case http.StatusServiceUnavailable:
+        WriteError(ctx, w, status, "Request timed out")
+        return
+    }
+    WriteError(ctx, w, status, err.Error())
+}
+
 // WriteValidationErrors writes validation errors with request ID
 func WriteValidationErrors(ctx context.Context, w http.ResponseWriter, errs ValidationErrors) {
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusBadRequest)
-
+    t := localizeError(ctx, w)
+    translated := make(ValidationErrors, len(errs))
+    for field, msg := range errs {
+        translated[field] = t.Translate(msg)
+    }
     requestID := GetRequestID(ctx)
     response := map[string]interface{}{
         "request_id": requestID,
-        "errors":     errs,
+        "errors":     translated,
     }
-
-    _ = json.NewEncoder(w).Encode(response)
+    respond.Error(ctx, w, http.StatusBadRequest, response)
 }
\ No newline at end of file
@@ -0,0 +1,125 @@
+package handler
+
+import (
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// etag formats a resource version as a strong entity tag, e.g. "3".
+func etag(version int) string {
+    return `"` + strconv.Itoa(version) + `"`
+}
+
+// expectedVersion works out which version a write is conditioned on, from the
+// If-Match header or, failing that, the version field of the body. ok is
+// false when the client sent neither. "If-Match: *" matches any version and
+// is reported as version 0.
+func expectedVersion(r *http.Request, bodyVersion *int) (version int, ok bool, err error) {
+    header := strings.TrimSpace(r.Header.Get("If-Match"))
+    if header == "" {
+        if bodyVersion == nil {
+            return 0, false, nil
+        }
+        return *bodyVersion, true, nil
+    }
+    if header == "*" {
+        return 0, true, nil
+    }
+
+    // Weak tags never satisfy If-Match, and only a single tag is supported.
+    unquoted, found := strings.CutPrefix(header, `"`)
+    unquoted, closed := strings.CutSuffix(unquoted, `"`)
+    if !found || !closed {
+        return 0, false, errors.New("If-Match must be a single strong entity tag")
+    }
+    version, err = strconv.Atoi(unquoted)
+    if err != nil || version < 1 {
+        return 0, false, errors.New("If-Match must be a single strong entity tag")
+    }
+    if bodyVersion != nil && *bodyVersion != version {
+        return 0, false, errors.New("If-Match and version disagree")
+    }
+    return version, true, nil
+}
+
+// bookPageETag is a weak entity tag for a page of books. Unlike a book's
+// version it also changes with availability and reviews, and with books
+// added to or removed from the matches. Each representation (media) has its
+// own tags.
+func bookPageETag(page model.Page[model.Book], media string) string {
+    h := fnv.New64a()
+    fmt.Fprintf(h, "%d|%s", page.Total, page.NextCursor)
+    if media != mediaJSON {
+        fmt.Fprintf(h, "|%s", media)
+    }
+    for _, b := range page.Items {
+        fmt.Fprintf(h, "|%s:%d:%d:%d", b.ID, b.Version, b.CopiesAvailable, b.ReviewCount)
+    }
+    return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// notModified evaluates a GET's preconditions against the current entity
+// tag and, when known, modification time (RFC 9110 section 13.2.2):
+// If-None-Match is compared weakly and, when present, If-Modified-Since is
+// ignored.
+func notModified(r *http.Request, tag string, lastModified time.Time) bool {
+    if inm := r.Header.Get("If-None-Match"); inm != "" {
+        for _, candidate := range strings.Split(inm, ",") {
+            candidate = strings.TrimSpace(candidate)
+            if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+                return true
+            }
+        }
+        return false
+    }
+    if lastModified.IsZero() {
+        return false
+    }
+    since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+    return err == nil && !lastModified.Truncate(time.Second).After(since)
+}
+
+// CacheControlMiddleware lets caches keep GET responses for maxAge, after
+// which they revalidate with the ETag or Last-Modified of the response.
+// Responses to requests without credentials may be kept by shared caches
+// such as CDNs; the rest only by the client. With cookies.Token set, a
+// request carrying that cookie has credentials too. A maxAge of 0 makes
+// every use revalidate.
+func CacheControlMiddleware(maxAge time.Duration, cookies CookieAuth) func(http.Handler) http.Handler {
+    seconds := strconv.Itoa(int(maxAge.Seconds()))
+    vary := "Authorization, X-API-Key, " + BranchHeader
+    if cookies.Token != "" {
+        vary += ", Cookie"
+    }
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            scope := "public"
+            if r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" || hasCookie(r, cookies.Token) {
+                scope = "private"
+            }
+            if maxAge > 0 {
+                w.Header().Set("Cache-Control", scope+", max-age="+seconds)
+            } else {
+                w.Header().Set("Cache-Control", scope+", no-cache")
+            }
+            w.Header().Add("Vary", vary)
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// hasCookie reports whether r carries the cookie name, if name is set.
+func hasCookie(r *http.Request, name string) bool {
+    if name == "" {
+        return false
+    }
+    _, err := r.Cookie(name)
+    return err == nil
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// exportFlushEvery controls how many records are written between flushes so
+// clients see data arriving while large exports are still running.
+const exportFlushEvery = 100
+
+var (
+    bookExportHeader    = []string{"id", "title", "author", "published_year", "isbn", "total_copies", "copies_available", "created_at", "updated_at", "version"}
+    bookingExportHeader = []string{"id", "user_id", "book_id", "borrowed_at", "due_date", "returned_at", "status", "created_at", "updated_at"}
+)
+
+// Export godoc
+// @Summary      Export the book catalog
+// @Description  Stream every book as CSV or NDJSON
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        format  query  string  false  "csv or ndjson"  default(csv)
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Success      200
+// @Failure      400  {object}  ErrorResponse
+// @Router       /admin/books/export [get]
+func (h *BookHandler) Export(w http.ResponseWriter, r *http.Request) {
+    format, ok := exportFormat(r)
+    if !ok {
+        WriteError(r.Context(), w, http.StatusBadRequest, "format must be csv or ndjson")
+        return
+    }
+
+    streamExport(w, r, h.logger, format, "books", bookExportHeader, bookExportRow, func(fn func(*model.Book) error) error {
+        return h.svc.Export(r.Context(), fn)
+    })
+}
+
+// Export godoc
+// @Summary      Export bookings
+// @Description  Stream bookings as CSV or NDJSON, optionally limited to a borrowed_at range
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        format  query  string  false  "csv or ndjson"  default(csv)
+// @Param        from    query  string  false  "Borrowed on or after (YYYY-MM-DD or RFC3339)"
+// @Param        to      query  string  false  "Borrowed before (YYYY-MM-DD or RFC3339)"
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Success      200
+// @Failure      400  {object}  ErrorResponse
+// @Router       /admin/bookings/export [get]
+func (h *BookingHandler) Export(w http.ResponseWriter, r *http.Request) {
+    format, ok := exportFormat(r)
+    if !ok {
+        WriteError(r.Context(), w, http.StatusBadRequest, "format must be csv or ndjson")
+        return
+    }
+
+    errs := ValidationErrors{}
+    var filter model.BookingExportFilter
+    if from, err := parseDateParam(r, "from"); err != nil {
+        errs["from"] = err.Error()
+    } else {
+        filter.From = from
+    }
+    if to, err := parseDateParam(r, "to"); err != nil {
+        errs["to"] = err.Error()
+    } else {
+        filter.To = to
+    }
+    if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+        errs["to"] = "to must be after from"
+    }
+    if len(errs) > 0 {
+        WriteValidationErrors(r.Context(), w, errs)
+        return
+    }
+
+    streamExport(w, r, h.logger, format, "bookings", bookingExportHeader, bookingExportRow, func(fn func(*model.Booking) error) error {
+        return h.bookingSvc.Export(r.Context(), filter, fn)
+    })
+}
+
+func exportFormat(r *http.Request) (string, bool) {
+    switch format := r.URL.Query().Get("format"); format {
+    case "", "csv":
+        return "csv", true
+    case "ndjson":
+        return "ndjson", true
+    }
+    return "", false
+}
+
+// parseDateParam accepts either a calendar date or a full RFC3339 timestamp.
+func parseDateParam(r *http.Request, name string) (*time.Time, error) {
+    v := r.URL.Query().Get(name)
+    if v == "" {
+        return nil, nil
+    }
+    if t, err := time.Parse("2006-01-02", v); err == nil {
+        return &t, nil
+    }
+    t, err := time.Parse(time.RFC3339, v)
+    if err != nil {
+        return nil, fmt.Errorf("%s must be YYYY-MM-DD or RFC3339", name)
+    }
+    return &t, nil
+}
+
+// streamExport writes records as they are produced by run. Once the first
+// byte is sent the status is committed, so later failures can only be logged
+// and signalled by truncating the stream.
+func streamExport[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, format, name string, header []string, toRow func(T) []string, run func(func(T) error) error) {
+    flusher, _ := w.(http.Flusher)
+
+    filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102T150405Z"), format)
+    w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+    var write func(T) error
+    var flush func() error
+    if format == "csv" {
+        w.Header().Set("Content-Type", "text/csv")
+        cw := csv.NewWriter(w)
+        if err := cw.Write(header); err != nil {
+            logServiceError(r.Context(), logger, "export failed", err, "export", name)
+            return
+        }
+        write = func(v T) error { return cw.Write(csvSafe(toRow(v))) }
+        flush = func() error {
+            cw.Flush()
+            return cw.Error()
+        }
+    } else {
+        w.Header().Set("Content-Type", "application/x-ndjson")
+        enc := json.NewEncoder(w)
+        write = func(v T) error { return enc.Encode(v) }
+        flush = func() error { return nil }
+    }
+
+    count := 0
+    err := run(func(v T) error {
+        if err := write(v); err != nil {
+            return err
+        }
+        count++
+        if count%exportFlushEvery == 0 {
+            if err := flush(); err != nil {
+                return err
+            }
+            if flusher != nil {
+                flusher.Flush()
+            }
+        }
+        return nil
+    })
+    if err != nil && count == 0 {
+        // Nothing has reached the client yet (the CSV header is still
+        // buffered), so a proper error response can still be sent.
+        logServiceError(r.Context(), logger, "export failed", err, "export", name)
+        WriteServiceError(r.Context(), w, err, "Failed to export "+name)
+        return
+    }
+    if flushErr := flush(); err == nil {
+        err = flushErr
+    }
+    if err != nil {
+        logger.ErrorContext(r.Context(), "export aborted", "export", name, "records", count, "error", err)
+        return
+    }
+    logger.InfoContext(r.Context(), "export finished", "export", name, "records", count, "format", format)
+}
+
+func bookExportRow(b *model.Book) []string {
+    return []string{
+        b.ID, b.Title, b.Author, strconv.Itoa(b.PublishedYear), b.ISBN, strconv.Itoa(b.TotalCopies), strconv.Itoa(b.CopiesAvailable),
+        b.CreatedAt.UTC().Format(time.RFC3339), b.UpdatedAt.UTC().Format(time.RFC3339), strconv.Itoa(b.Version),
+    }
+}
+
+func bookingExportRow(b *model.Booking) []string {
+    returnedAt := ""
+    if b.ReturnedAt != nil {
+        returnedAt = b.ReturnedAt.UTC().Format(time.RFC3339)
+    }
+    return []string{
+        b.ID, b.UserID, b.BookID,
+        b.BorrowedAt.UTC().Format(time.RFC3339), b.DueDate.UTC().Format(time.RFC3339), returnedAt, b.Status,
+        b.CreatedAt.UTC().Format(time.RFC3339), b.UpdatedAt.UTC().Format(time.RFC3339),
+    }
+}
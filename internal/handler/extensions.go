@@ -0,0 +1,185 @@
+package handler
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+    "strings"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type ExtensionHandler struct {
+    svc    service.ExtensionService
+    logger *slog.Logger
+}
+
+func NewExtensionHandler(svc service.ExtensionService, logger *slog.Logger) *ExtensionHandler {
+    return &ExtensionHandler{svc: svc, logger: logger}
+}
+
+// Request godoc
+// @Summary      Ask for a longer loan
+// @Description  Ask to keep a loan extra_days past its current due date, beyond what the loan
+// @Description  policy lets you choose when borrowing. An admin approves or denies the request
+// @Description  and you are emailed the decision. A loan has one request waiting at a time.
+// @Tags         Bookings
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                        true  "Booking ID"
+// @Param        request  body  model.CreateExtensionRequest  true  "Extra days and reason"
+// @Produce      json
+// @Success      201  {object}  model.ExtensionRequest
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /bookings/{id}/extension-requests [post]
+func (h *ExtensionHandler) Request(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.CreateExtensionRequest](w, r)
+    if !ok {
+        return
+    }
+
+    bookingID := chi.URLParam(r, "id")
+    er, err := h.svc.Request(r.Context(), userID, bookingID, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "extension request failed", err, "booking_id", bookingID)
+        WriteServiceError(r.Context(), w, err, "Failed to request extension")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, er)
+}
+
+// ListMine godoc
+// @Summary      List my extension requests
+// @Description  Get the caller's requests for longer loans, newest first, with their decisions
+// @Tags         Users
+// @Security     BearerAuth
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Success      200  {object}  model.Page[model.ExtensionRequest]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/extension-requests [get]
+func (h *ExtensionHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    requests, err := h.svc.ListMine(r.Context(), userID, parsePageRequest(r))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list extension requests failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list extension requests")
+        return
+    }
+
+    respond.Page(r.Context(), w, requests)
+}
+
+// List godoc
+// @Summary      List extension requests
+// @Description  Get a paginated list of requests for longer loans, newest first
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        status   query     string  false  "Only requests in this status"  Enums(PENDING, APPROVED, DENIED)
+// @Param        user_id  query     string  false  "Only requests by this user"
+// @Param        limit    query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset   query     int     false  "Pagination offset"       default(0)
+// @Param        cursor   query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.ExtensionRequest]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/extension-requests [get]
+func (h *ExtensionHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    f := model.ExtensionRequestFilter{
+        Status: strings.ToUpper(strings.TrimSpace(q.Get("status"))),
+        UserID: strings.TrimSpace(q.Get("user_id")),
+    }
+    requests, err := h.svc.List(r.Context(), parsePageRequest(r), f)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list extension requests failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list extension requests")
+        return
+    }
+
+    writeList(w, r, h.logger, listMedia(w, r), "extension_requests", requests, extensionTable)
+}
+
+// Approve godoc
+// @Summary      Approve an extension request
+// @Description  Move the loan's due date to the requested one, bringing an overdue loan back to
+// @Description  ACTIVE, and email the borrower with the comment. Loans already returned can't be
+// @Description  extended; deny their requests instead.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                        true  "Extension request ID"
+// @Param        request  body  model.DecideExtensionRequest  true  "Comment for the borrower"
+// @Produce      json
+// @Success      200  {object}  model.ExtensionRequest
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/extension-requests/{id}/approve [post]
+func (h *ExtensionHandler) Approve(w http.ResponseWriter, r *http.Request) {
+    h.decide(w, r, "approve", h.svc.Approve)
+}
+
+// Deny godoc
+// @Summary      Deny an extension request
+// @Description  Leave the loan's due date as it is and email the borrower with the comment
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                        true  "Extension request ID"
+// @Param        request  body  model.DecideExtensionRequest  true  "Comment for the borrower"
+// @Produce      json
+// @Success      200  {object}  model.ExtensionRequest
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/extension-requests/{id}/deny [post]
+func (h *ExtensionHandler) Deny(w http.ResponseWriter, r *http.Request) {
+    h.decide(w, r, "deny", h.svc.Deny)
+}
+
+type extensionDecision func(ctx context.Context, actorID, id string, req *model.DecideExtensionRequest) (*model.ExtensionRequest, error)
+
+func (h *ExtensionHandler) decide(w http.ResponseWriter, r *http.Request, verb string, decide extensionDecision) {
+    req, ok := Bind[model.DecideExtensionRequest](w, r)
+    if !ok {
+        return
+    }
+
+    id := chi.URLParam(r, "id")
+    er, err := decide(r.Context(), GetUserID(r.Context()), id, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, verb+" extension request failed", err, "extension_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to "+verb+" extension request")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, er)
+}
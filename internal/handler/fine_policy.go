@@ -0,0 +1,70 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type FinePolicyHandler struct {
+    svc    service.FinePolicyService
+    logger *slog.Logger
+}
+
+func NewFinePolicyHandler(svc service.FinePolicyService, logger *slog.Logger) *FinePolicyHandler {
+    return &FinePolicyHandler{svc: svc, logger: logger}
+}
+
+// Get godoc
+// @Summary      Get the fine policy
+// @Description  Get the fine policy late returns are priced by: the one admins set, or the configured
+// @Description  default (without updated_at) when they never set one
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.FinePolicy
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/fines [get]
+func (h *FinePolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
+    p, err := h.svc.Get(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get fine policy failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get fine policy")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, p)
+}
+
+// Set godoc
+// @Summary      Set the fine policy
+// @Description  Set the days a loan may be late without a fine, the fine per further started day,
+// @Description  its cap per loan (0 for none) and the currency. Fines are priced when the book comes
+// @Description  back, so the policy applies to later returns only; fines already assessed keep their amount.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.FinePolicyRequest  true  "Policy"
+// @Produce      json
+// @Success      200  {object}  model.FinePolicy
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/fines [put]
+func (h *FinePolicyHandler) Set(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.FinePolicyRequest](w, r)
+    if !ok {
+        return
+    }
+
+    p, err := h.svc.Set(r.Context(), GetUserID(r.Context()), req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "set fine policy failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to set fine policy")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, p)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+    "io"
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type FineHandler struct {
+    svc    service.FineService
+    logger *slog.Logger
+}
+
+func NewFineHandler(svc service.FineService, logger *slog.Logger) *FineHandler {
+    return &FineHandler{svc: svc, logger: logger}
+}
+
+// ListMine godoc
+// @Summary      List my fines
+// @Description  Get the caller's fines for late returns, newest first. A fine is recorded
+// @Description  when a loan comes back after its due date; amounts are in cents.
+// @Tags         Fines
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.Fine
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/fines [get]
+func (h *FineHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    fines, err := h.svc.ListMine(r.Context(), userID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list fines failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list fines")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, fines)
+}
+
+// Pay godoc
+// @Summary      Pay a fine
+// @Description  Start paying one of the caller's unpaid fines. The response carries the payment
+// @Description  provider's client secret, with which the client completes the payment (for Stripe,
+// @Description  with Stripe.js). The fine becomes PAID, with a receipt, once the provider confirms
+// @Description  the payment through its webhook.
+// @Tags         Fines
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Fine ID"
+// @Produce      json
+// @Success      201  {object}  model.PaymentIntent
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Router       /users/me/fines/{id}/pay [post]
+func (h *FineHandler) Pay(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    id := chi.URLParam(r, "id")
+    intent, err := h.svc.Pay(r.Context(), userID, id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "pay fine failed", err, "fine_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to start payment")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, intent)
+}
+
+// Receipt godoc
+// @Summary      Get a fine's receipt
+// @Description  Get the receipt of one of the caller's paid fines
+// @Tags         Fines
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Fine ID"
+// @Produce      json
+// @Success      200  {object}  model.Receipt
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /users/me/fines/{id}/receipt [get]
+func (h *FineHandler) Receipt(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    id := chi.URLParam(r, "id")
+    receipt, err := h.svc.Receipt(r.Context(), userID, id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get receipt failed", err, "fine_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get receipt")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, receipt)
+}
+
+// Webhook godoc
+// @Summary      Payment provider webhook
+// @Description  Receives the payment provider's events (for Stripe, payment_intent.succeeded and
+// @Description  payment_intent.payment_failed). Requests must carry the provider's signature.
+// @Tags         Fines
+// @Accept       json
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /payments/webhook [post]
+func (h *FineHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+    payload, err := io.ReadAll(r.Body)
+    if err != nil {
+        WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Request body too large")
+        return
+    }
+
+    if err := h.svc.HandleWebhook(r.Context(), payload, r.Header); err != nil {
+        logServiceError(r.Context(), h.logger, "payment webhook failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to process payment event")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
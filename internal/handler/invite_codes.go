@@ -0,0 +1,91 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type InviteCodeHandler struct {
+    svc    service.InviteCodeService
+    logger *slog.Logger
+}
+
+func NewInviteCodeHandler(svc service.InviteCodeService, logger *slog.Logger) *InviteCodeHandler {
+    return &InviteCodeHandler{svc: svc, logger: logger}
+}
+
+// Create godoc
+// @Summary      Mint an invite code
+// @Description  Mint a code people register with while registration is closed, sent as invite_code
+// @Description  to POST /auth/register. It works max_uses times (once when omitted) until expires_at
+// @Description  (a week from now when omitted). The code is only shown in this response.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.CreateInviteCodeRequest  true  "Invite code"
+// @Produce      json
+// @Success      201  {object}  model.CreateInviteCodeResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/invites [post]
+func (h *InviteCodeHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.CreateInviteCodeRequest](w, r)
+    if !ok {
+        return
+    }
+
+    c, code, err := h.svc.Create(r.Context(), GetUserID(r.Context()), &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create invite code failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create invite code")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, model.CreateInviteCodeResponse{InviteCode: *c, Code: code})
+}
+
+// List godoc
+// @Summary      List invite codes
+// @Description  List every invite code with its uses, revoked and expired ones included, newest first
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.InviteCode
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/invites [get]
+func (h *InviteCodeHandler) List(w http.ResponseWriter, r *http.Request) {
+    codes, err := h.svc.List(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list invite codes failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list invite codes")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, codes)
+}
+
+// Revoke godoc
+// @Summary      Revoke an invite code
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Invite code ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/invites/{id} [delete]
+func (h *InviteCodeHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Revoke(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "revoke invite code failed", err, "invite_code_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to revoke invite code")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "invite code revoked", "invite_code_id", id)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+    "strings"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type JobHandler struct {
+    svc    service.JobService
+    logger *slog.Logger
+}
+
+func NewJobHandler(svc service.JobService, logger *slog.Logger) *JobHandler {
+    return &JobHandler{svc: svc, logger: logger}
+}
+
+// List godoc
+// @Summary      List background jobs
+// @Description  Get a paginated list of the background job queue (such as email deliveries),
+// @Description  newest first. Jobs that failed every attempt are "dead" and can be requeued.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        status  query     string  false  "pending, running, done or dead"
+// @Param        kind    query     string  false  "Only jobs of this kind, e.g. email"
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Job]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/jobs [get]
+func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    f := model.JobFilter{
+        Status: strings.TrimSpace(q.Get("status")),
+        Kind:   strings.TrimSpace(q.Get("kind")),
+    }
+    jobs, err := h.svc.List(r.Context(), parsePageRequest(r), f)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list jobs failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list jobs")
+        return
+    }
+
+    writeList(w, r, h.logger, listMedia(w, r), "jobs", jobs, jobTable)
+}
+
+// Get godoc
+// @Summary      Get a background job
+// @Description  Get a job with its payload and the error of its latest failed attempt
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Job ID"
+// @Produce      json
+// @Success      200  {object}  model.Job
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/jobs/{id} [get]
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    job, err := h.svc.Get(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get job failed", err, "job_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get job")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, job)
+}
+
+// Requeue godoc
+// @Summary      Requeue a dead job
+// @Description  Give a job that failed every attempt a fresh set of attempts, starting now
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Job ID"
+// @Produce      json
+// @Success      200  {object}  model.Job
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "The job isn't dead"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/jobs/{id}/requeue [post]
+func (h *JobHandler) Requeue(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    job, err := h.svc.Requeue(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "requeue job failed", err, "job_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to requeue job")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, job)
+}
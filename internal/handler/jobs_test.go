@@ -0,0 +1,69 @@
+package handler
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+type mockJobService struct {
+    listFn    func(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error)
+    requeueFn func(ctx context.Context, id string) (*model.Job, error)
+}
+
+func (m *mockJobService) List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error) {
+    return m.listFn(ctx, p, f)
+}
+
+func (m *mockJobService) Get(ctx context.Context, id string) (*model.Job, error) {
+    return nil, apperr.NotFound("job not found")
+}
+
+func (m *mockJobService) Requeue(ctx context.Context, id string) (*model.Job, error) {
+    return m.requeueFn(ctx, id)
+}
+
+func TestJobHandler_ListPassesFilters(t *testing.T) {
+    var got model.JobFilter
+    svc := &mockJobService{listFn: func(_ context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error) {
+        got = f
+        return model.Page[model.Job]{Items: []model.Job{{ID: "j1", Kind: "email", Payload: []byte(`{"to":"ada@example.com"}`)}}, Total: 1}, nil
+    }}
+    h := NewJobHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.List(rec, httptest.NewRequest("GET", "/admin/jobs?status=dead&kind=email", nil))
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, model.JobFilter{Status: "dead", Kind: "email"}, got)
+    require.Contains(t, rec.Body.String(), `"payload":{"to":"ada@example.com"}`)
+}
+
+func TestJobHandler_Requeue(t *testing.T) {
+    svc := &mockJobService{requeueFn: func(_ context.Context, id string) (*model.Job, error) {
+        if id == "j2" {
+            return nil, apperr.Conflict("only dead jobs can be requeued")
+        }
+        return &model.Job{ID: id, Status: model.JobPending}, nil
+    }}
+    h := NewJobHandler(svc, logger.Discard())
+    requeue := func(id string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest("POST", "/admin/jobs/"+id+"/requeue", nil)
+        rctx := chi.NewRouteContext()
+        rctx.URLParams.Add("id", id)
+        rec := httptest.NewRecorder()
+        h.Requeue(rec, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+        return rec
+    }
+
+    rec := requeue("j1")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Contains(t, rec.Body.String(), `"status":"pending"`)
+    require.Equal(t, http.StatusConflict, requeue("j2").Code)
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "golang.org/x/net/websocket"
+)
+
+const (
+    // liveBuffer is how far a slow dashboard may fall behind before it
+    // misses events.
+    liveBuffer = 64
+    // livePingEvery keeps idle feeds open through proxies. The caller is
+    // authenticated again as often, so a revoked token, ended session or
+    // suspended user stops receiving events within the interval.
+    livePingEvery = 30 * time.Second
+    // liveMaxMessage bounds what a dashboard may send; it has nothing to say.
+    liveMaxMessage = 4 << 10
+    // liveRetry is how long a browser waits before reconnecting a booking
+    // feed that ended.
+    liveRetry = 5 * time.Second
+)
+
+// bookingEvents are the events a user's booking feed passes on.
+var bookingEvents = map[string]bool{
+    model.EventBookingCreated:  true,
+    model.EventBookingReturned: true,
+    model.EventBookingOverdue:  true,
+    model.EventBookingOffered:  true,
+}
+
+// EventSource hands out the domain events as they are published;
+// *events.Hub is one.
+type EventSource interface {
+    Subscribe(buffer int) (<-chan model.Event, func())
+}
+
+type LiveHandler struct {
+    events    EventSource
+    logger    *slog.Logger
+    pingEvery time.Duration
+}
+
+func NewLiveHandler(events EventSource, logger *slog.Logger) *LiveHandler {
+    return &LiveHandler{events: events, logger: logger, pingEvery: livePingEvery}
+}
+
+// stillAuthorized authenticates the caller of a feed again, logging why
+// the feed closes when they no longer are.
+func (h *LiveHandler) stillAuthorized(ctx context.Context) bool {
+    ctx, cancel := context.WithTimeout(ctx, h.pingEvery)
+    defer cancel()
+    if err := recheckAuth(ctx); err != nil {
+        h.logger.InfoContext(ctx, "feed closed: caller no longer authenticated", "error", err)
+        return false
+    }
+    return true
+}
+
+// Feed godoc
+// @Summary      Live activity feed
+// @Description  Upgrade to a WebSocket receiving every domain event (booking.created, booking.returned,
+// @Description  booking.overdue, booking.offered, user.registered) as a JSON text message as it is
+// @Description  published. The upgrade request is authenticated like any other; browsers may use the auth
+// @Description  cookie from the API's own origin only. The socket is closed when the token expires, and
+// @Description  within 30 seconds of it being revoked or the caller being suspended or demoted. Admins
+// @Description  scoped to a branch only receive that branch's events.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Success      101  {object}  model.Event  "One per message"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/ws [get]
+func (h *LiveHandler) Feed(w http.ResponseWriter, r *http.Request) {
+    if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+        WriteError(r.Context(), w, http.StatusBadRequest, "WebSocket upgrade required")
+        return
+    }
+    // Browsers send cookies with cross-site WebSocket requests, so only
+    // a Bearer token, which a page can't borrow, may come from elsewhere.
+    if r.Header.Get("Authorization") == "" && !sameOrigin(r) {
+        h.logger.WarnContext(r.Context(), "cross-origin live feed refused", "origin", r.Header.Get("Origin"))
+        WriteError(r.Context(), w, http.StatusForbidden, "Cross-origin connections must authenticate with a Bearer token")
+        return
+    }
+
+    claims, _ := ClaimsFromContext(r.Context())
+    branchID := tenant.BranchID(r.Context())
+    // The request's context ends with its time budget; the feed lasts
+    // until the socket closes, the token expires or the caller is refused.
+    ctx := context.WithoutCancel(r.Context())
+    srv := websocket.Server{
+        Handshake: func(*websocket.Config, *http.Request) error { return nil },
+        Handler: func(ws *websocket.Conn) {
+            h.serve(ctx, ws, claims.ExpiresAt, branchID)
+        },
+    }
+    srv.ServeHTTP(hijackable{w}, r)
+}
+
+func (h *LiveHandler) serve(ctx context.Context, ws *websocket.Conn, expiresAt time.Time, branchID string) {
+    // Clear the deadlines the server set for the upgrade request.
+    _ = ws.SetDeadline(time.Time{})
+    ws.MaxPayloadBytes = liveMaxMessage
+    events, unsubscribe := h.events.Subscribe(liveBuffer)
+    defer unsubscribe()
+
+    // Reading answers pings and notices the dashboard closing the socket.
+    closed := make(chan struct{})
+    go func() {
+        defer close(closed)
+        var msg []byte
+        for websocket.Message.Receive(ws, &msg) == nil {
+        }
+    }()
+
+    var expired <-chan time.Time
+    if !expiresAt.IsZero() {
+        t := time.NewTimer(time.Until(expiresAt))
+        defer t.Stop()
+        expired = t.C
+    }
+    ping := time.NewTicker(h.pingEvery)
+    defer ping.Stop()
+    ws.PayloadType = websocket.PingFrame
+
+    h.logger.InfoContext(ctx, "live feed opened")
+    for {
+        select {
+        case e := <-events:
+            if !inBranch(e, branchID) {
+                continue
+            }
+            if err := websocket.JSON.Send(ws, e); err != nil {
+                h.logger.InfoContext(ctx, "live feed closed", "error", err)
+                return
+            }
+        case <-ping.C:
+            if !h.stillAuthorized(ctx) {
+                return
+            }
+            if _, err := ws.Write(nil); err != nil {
+                h.logger.InfoContext(ctx, "live feed closed", "error", err)
+                return
+            }
+        case <-expired:
+            h.logger.InfoContext(ctx, "live feed closed: token expired")
+            return
+        case <-closed:
+            h.logger.InfoContext(ctx, "live feed closed by client")
+            return
+        }
+    }
+}
+
+// BookingEvents godoc
+// @Summary      Follow your bookings
+// @Description  Server-sent events for changes to the caller's bookings: booking.created (borrowed or offer
+// @Description  accepted), booking.returned, booking.overdue and booking.offered (a waitlisted copy is held
+// @Description  for you). Each event's id is the event ID, its name the event type and its data the booking
+// @Description  as JSON. The stream ends when the route's time budget or the token runs out, or within 30
+// @Description  seconds of the token being revoked, and browsers reconnect by themselves; changes made while
+// @Description  disconnected aren't replayed, so refetch GET /bookings after reconnecting.
+// @Tags         Bookings
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Success      200  {object}  model.Booking  "The data of each event"
+// @Failure      401  {object}  ErrorResponse
+// @Router       /bookings/events [get]
+func (h *LiveHandler) BookingEvents(w http.ResponseWriter, r *http.Request) {
+    claims, _ := ClaimsFromContext(r.Context())
+    if claims.UserID == "" {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    events, unsubscribe := h.events.Subscribe(liveBuffer)
+    defer unsubscribe()
+
+    rc := http.NewResponseController(w)
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    // Ask proxies such as nginx not to hold events back.
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    if _, err := fmt.Fprintf(w, "retry: %d\n\n", liveRetry.Milliseconds()); err != nil {
+        return
+    }
+    if err := rc.Flush(); err != nil {
+        h.logger.ErrorContext(r.Context(), "booking feed can't be flushed", "error", err)
+        return
+    }
+
+    var expired <-chan time.Time
+    if !claims.ExpiresAt.IsZero() {
+        t := time.NewTimer(time.Until(claims.ExpiresAt))
+        defer t.Stop()
+        expired = t.C
+    }
+    ping := time.NewTicker(h.pingEvery)
+    defer ping.Stop()
+
+    for {
+        var err error
+        select {
+        case e := <-events:
+            if !bookingEvents[e.Type] || !ownBooking(e, claims.UserID) {
+                continue
+            }
+            err = writeSSE(w, e)
+        case <-ping.C:
+            if !h.stillAuthorized(r.Context()) {
+                return
+            }
+            _, err = fmt.Fprint(w, ": ping\n\n")
+        case <-expired:
+            return
+        case <-r.Context().Done():
+            return
+        }
+        if err == nil {
+            err = rc.Flush()
+        }
+        if err != nil {
+            h.logger.InfoContext(r.Context(), "booking feed closed", "error", err)
+            return
+        }
+    }
+}
+
+// ownBooking reports whether e's data is a booking of userID's.
+func ownBooking(e model.Event, userID string) bool {
+    var data struct {
+        UserID string `json:"user_id"`
+    }
+    _ = json.Unmarshal(e.Data, &data)
+    return data.UserID == userID
+}
+
+// writeSSE writes e as a server-sent event, its data compacted onto the one
+// line.
+func writeSSE(w http.ResponseWriter, e model.Event) error {
+    var data bytes.Buffer
+    if err := json.Compact(&data, e.Data); err != nil {
+        return err
+    }
+    _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data.Bytes())
+    return err
+}
+
+// inBranch reports whether e concerns branchID, judging by the branch_id
+// of its data. Outside a branch every event does.
+func inBranch(e model.Event, branchID string) bool {
+    if branchID == "" {
+        return true
+    }
+    var data struct {
+        BranchID string `json:"branch_id"`
+    }
+    _ = json.Unmarshal(e.Data, &data)
+    return data.BranchID == branchID
+}
+
+// sameOrigin reports whether r has no Origin or one on its own host.
+func sameOrigin(r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return true
+    }
+    u, err := url.Parse(origin)
+    return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// hijackable lets the websocket package take over connections whose
+// ResponseWriter is wrapped by middleware, finding the Hijacker through
+// Unwrap.
+type hijackable struct {
+    http.ResponseWriter
+}
+
+func (h hijackable) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    return http.NewResponseController(h.ResponseWriter).Hijack()
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/events"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/stretchr/testify/require"
+    "golang.org/x/net/websocket"
+)
+
+// liveServer serves the feed to a caller with claims, at branchID when set,
+// behind the timeout middleware.
+func liveServer(t *testing.T, hub *events.Hub, claims AuthContext, branchID string) *httptest.Server {
+    t.Helper()
+    h := NewLiveHandler(hub, logger.Discard())
+    srv := httptest.NewServer(TimeoutMiddleware(time.Second, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ctx := WithClaims(r.Context(), claims)
+        if branchID != "" {
+            ctx = tenant.WithBranch(ctx, branchID)
+        }
+        h.Feed(w, r.WithContext(ctx))
+    })))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func dialLive(t *testing.T, srv *httptest.Server, origin string) *websocket.Conn {
+    t.Helper()
+    ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", origin)
+    require.NoError(t, err)
+    t.Cleanup(func() { ws.Close() })
+    return ws
+}
+
+// publishUntilSubscribed publishes e until the feed, which subscribes once
+// the socket is open, passes it on.
+func publishUntilSubscribed(t *testing.T, hub *events.Hub, ws *websocket.Conn, e model.Event) model.Event {
+    t.Helper()
+    got := make(chan model.Event, 1)
+    go func() {
+        var msg model.Event
+        if websocket.JSON.Receive(ws, &msg) == nil {
+            got <- msg
+        }
+    }()
+    for {
+        require.NoError(t, hub.Publish(context.Background(), e))
+        select {
+        case msg := <-got:
+            return msg
+        case <-time.After(10 * time.Millisecond):
+        }
+    }
+}
+
+func TestLiveHandler_Feed_StreamsEventsPastTheRequestTimeout(t *testing.T) {
+    hub := events.NewHub()
+    srv := liveServer(t, hub, AuthContext{UserID: "admin-1", Role: model.RoleAdmin}, "")
+    ws := dialLive(t, srv, srv.URL)
+
+    time.Sleep(1100 * time.Millisecond)
+    e := model.Event{ID: "e1", Type: model.EventBookingCreated, Subject: "b1", Data: json.RawMessage(`{"id":"b1"}`)}
+    got := publishUntilSubscribed(t, hub, ws, e)
+    require.Equal(t, model.EventBookingCreated, got.Type)
+    require.Equal(t, "b1", got.Subject)
+    require.JSONEq(t, `{"id":"b1"}`, string(got.Data))
+}
+
+func TestLiveHandler_Feed_OnlyTheAdminsBranch(t *testing.T) {
+    hub := events.NewHub()
+    srv := liveServer(t, hub, AuthContext{UserID: "admin-1", Role: model.RoleAdmin, BranchID: "north"}, "north")
+    ws := dialLive(t, srv, srv.URL)
+    north, south := json.RawMessage(`{"branch_id":"north"}`), json.RawMessage(`{"branch_id":"south"}`)
+
+    publishUntilSubscribed(t, hub, ws, model.Event{Subject: "ready", Data: north})
+    require.NoError(t, hub.Publish(context.Background(), model.Event{Subject: "south", Data: south}))
+    require.NoError(t, hub.Publish(context.Background(), model.Event{Subject: "north", Data: north}))
+    require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+    for {
+        var msg model.Event
+        require.NoError(t, websocket.JSON.Receive(ws, &msg))
+        require.NotEqual(t, "south", msg.Subject)
+        if msg.Subject == "north" {
+            return
+        }
+    }
+}
+
+func TestLiveHandler_Feed_ClosesWhenTheTokenExpires(t *testing.T) {
+    hub := events.NewHub()
+    srv := liveServer(t, hub, AuthContext{UserID: "admin-1", Role: model.RoleAdmin, ExpiresAt: time.Now().Add(200 * time.Millisecond)}, "")
+    ws := dialLive(t, srv, srv.URL)
+
+    require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+    var msg model.Event
+    require.Error(t, websocket.JSON.Receive(ws, &msg), "the server closes the socket")
+}
+
+func TestLiveHandler_Feed_ClosesWhenTheCallerIsRefused(t *testing.T) {
+    hub := events.NewHub()
+    var revoked atomic.Bool
+    h := NewLiveHandler(hub, logger.Discard())
+    h.pingEvery = 50 * time.Millisecond
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ctx := withRecheck(WithClaims(r.Context(), AuthContext{UserID: "admin-1", Role: model.RoleAdmin}), func(context.Context) error {
+            if revoked.Load() {
+                return service.ErrTokenRevoked
+            }
+            return nil
+        })
+        h.Feed(w, r.WithContext(ctx))
+    }))
+    t.Cleanup(srv.Close)
+    ws := dialLive(t, srv, srv.URL)
+
+    publishUntilSubscribed(t, hub, ws, model.Event{Subject: "ready"})
+    revoked.Store(true)
+    require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+    var msg model.Event
+    require.Error(t, websocket.JSON.Receive(ws, &msg), "the server closes the socket")
+}
+
+func TestLiveHandler_Feed_RefusesCrossOriginCookies(t *testing.T) {
+    hub := events.NewHub()
+    srv := liveServer(t, hub, AuthContext{UserID: "admin-1", Role: model.RoleAdmin}, "")
+
+    _, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", "https://evil.example.com")
+    require.Error(t, err)
+
+    cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http"), "https://dashboard.example.com")
+    require.NoError(t, err)
+    cfg.Header.Set("Authorization", "Bearer token")
+    ws, err := websocket.DialConfig(cfg)
+    require.NoError(t, err, "a Bearer token may come from any origin")
+    ws.Close()
+
+    req := httptest.NewRequest(http.MethodGet, "/admin/ws", nil)
+    rec := httptest.NewRecorder()
+    NewLiveHandler(hub, logger.Discard()).Feed(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code, "plain requests aren't upgraded")
+}
+
+func TestLiveHandler_BookingEvents_StreamsTheUsersBookings(t *testing.T) {
+    hub := events.NewHub()
+    h := NewLiveHandler(hub, logger.Discard())
+    srv := httptest.NewServer(TimeoutMiddleware(5*time.Second, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        h.BookingEvents(w, r.WithContext(WithClaims(r.Context(), AuthContext{UserID: "user-1", Role: model.RoleUser})))
+    })))
+    t.Cleanup(srv.Close)
+
+    resp, err := http.Get(srv.URL)
+    require.NoError(t, err)
+    defer resp.Body.Close()
+    require.Equal(t, http.StatusOK, resp.StatusCode)
+    require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+    // The feed subscribes before it answers, so nothing is missed from here.
+    ctx := context.Background()
+    require.NoError(t, hub.Publish(ctx, model.Event{ID: "e1", Type: model.EventBookingReturned, Data: json.RawMessage(`{"id":"b9","user_id":"user-2"}`)}))
+    require.NoError(t, hub.Publish(ctx, model.Event{ID: "e2", Type: model.EventUserRegistered, Data: json.RawMessage(`{"id":"user-1","user_id":"user-1"}`)}))
+    require.NoError(t, hub.Publish(ctx, model.Event{ID: "e3", Type: model.EventBookingOverdue, Data: json.RawMessage("{\n  \"id\": \"b1\",\n  \"user_id\": \"user-1\"\n}")}))
+
+    lines := bufio.NewScanner(resp.Body)
+    var got []string
+    for len(got) < 6 && lines.Scan() {
+        got = append(got, lines.Text())
+    }
+    require.Equal(t, []string{"retry: 5000", "", "id: e3", "event: booking.overdue", `data: {"id":"b1","user_id":"user-1"}`, ""}, got)
+}
+
+func TestLiveHandler_BookingEvents_EndsWhenTheTokenIsRevoked(t *testing.T) {
+    h := NewLiveHandler(events.NewHub(), logger.Discard())
+    h.pingEvery = 50 * time.Millisecond
+    ctx := withRecheck(WithClaims(context.Background(), AuthContext{UserID: "user-1", Role: model.RoleUser}), func(context.Context) error {
+        return service.ErrTokenRevoked
+    })
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        h.BookingEvents(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bookings/events", nil).WithContext(ctx))
+    }()
+
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("the stream is still open")
+    }
+}
+
+func TestLiveHandler_BookingEvents_Unauthorized(t *testing.T) {
+    rec := httptest.NewRecorder()
+    NewLiveHandler(events.NewHub(), logger.Discard()).BookingEvents(rec, httptest.NewRequest(http.MethodGet, "/bookings/events", nil))
+    require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
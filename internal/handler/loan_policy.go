@@ -0,0 +1,149 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type LoanPolicyHandler struct {
+    svc    service.LoanPolicyService
+    logger *slog.Logger
+}
+
+func NewLoanPolicyHandler(svc service.LoanPolicyService, logger *slog.Logger) *LoanPolicyHandler {
+    return &LoanPolicyHandler{svc: svc, logger: logger}
+}
+
+// ListPolicies godoc
+// @Summary      List loan policies
+// @Description  Get the loan policy in force for each role
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.LoanPolicy
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/loans [get]
+func (h *LoanPolicyHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+    policies, err := h.svc.ListPolicies(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list loan policies failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list loan policies")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, policies)
+}
+
+// SetPolicy godoc
+// @Summary      Set a role's loan policy
+// @Description  Set the most bookings a user with the role may have out at once
+// @Description  (0 for no limit) and the longest loan they may take
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        role     path  string                   true  "Role (user or admin)"
+// @Param        request  body  model.LoanPolicyRequest  true  "Limits"
+// @Produce      json
+// @Success      200  {object}  model.LoanPolicy
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/loans/{role} [put]
+func (h *LoanPolicyHandler) SetPolicy(w http.ResponseWriter, r *http.Request) {
+    role := model.NormalizeRole(chi.URLParam(r, "role"))
+
+    req, ok := Bind[model.LoanPolicyRequest](w, r)
+    if !ok {
+        return
+    }
+
+    policy, err := h.svc.SetPolicy(r.Context(), role, req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "set loan policy failed", err, "role", role)
+        WriteServiceError(r.Context(), w, err, "Failed to set loan policy")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, policy)
+}
+
+// GetBookRestriction godoc
+// @Summary      Get a book's loan restriction
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.BookLoanRestriction
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/books/{id} [get]
+func (h *LoanPolicyHandler) GetBookRestriction(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    restriction, err := h.svc.GetBookRestriction(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get book loan restriction failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get book loan restriction")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, restriction)
+}
+
+// SetBookRestriction godoc
+// @Summary      Restrict loans of a book
+// @Description  Mark a book reference-only or cap its loan length below the role limits
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                            true  "Book ID"
+// @Param        request  body  model.BookLoanRestrictionRequest  true  "Restriction"
+// @Produce      json
+// @Success      200  {object}  model.BookLoanRestriction
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/books/{id} [put]
+func (h *LoanPolicyHandler) SetBookRestriction(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    req, ok := Bind[model.BookLoanRestrictionRequest](w, r)
+    if !ok {
+        return
+    }
+
+    restriction, err := h.svc.SetBookRestriction(r.Context(), id, req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "set book loan restriction failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to set book loan restriction")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, restriction)
+}
+
+// DeleteBookRestriction godoc
+// @Summary      Lift a book's loan restriction
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Book ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/policies/books/{id} [delete]
+func (h *LoanPolicyHandler) DeleteBookRestriction(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    if err := h.svc.DeleteBookRestriction(r.Context(), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete book loan restriction failed", err, "book_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete book loan restriction")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "book loan restriction removed", "book_id", id)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/i18n"
+)
+
+type translatorKey struct{}
+
+// WithTranslator returns a copy of ctx whose error messages are written by t.
+func WithTranslator(ctx context.Context, t *i18n.Translator) context.Context {
+    return context.WithValue(ctx, translatorKey{}, t)
+}
+
+// translatorFrom returns the request's translator; without one, messages
+// stay in English.
+func translatorFrom(ctx context.Context) *i18n.Translator {
+    t, _ := ctx.Value(translatorKey{}).(*i18n.Translator)
+    return t
+}
+
+// LocaleMiddleware picks the language of each request's error messages
+// from its Accept-Language header, out of the locales in catalog.
+func LocaleMiddleware(catalog *i18n.Catalog) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            t := catalog.Negotiate(r.Header.Get("Accept-Language"))
+            next.ServeHTTP(w, r.WithContext(WithTranslator(r.Context(), t)))
+        })
+    }
+}
+
+// localizeError marks an error response as depending on Accept-Language
+// and returns the translator to write it with.
+func localizeError(ctx context.Context, w http.ResponseWriter) *i18n.Translator {
+    t := translatorFrom(ctx)
+    w.Header().Set("Content-Language", t.Locale())
+    w.Header().Add("Vary", "Accept-Language")
+    return t
+}
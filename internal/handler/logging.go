@@ -0,0 +1,17 @@
+package handler
+
+import (
+    "context"
+    "log/slog"
+)
+
+// logServiceError logs a failed service call. Errors the client caused (typed
+// apperr kinds, which map to 4xx) are logged at warn; anything else is an
+// internal failure and logged at error.
+func logServiceError(ctx context.Context, logger *slog.Logger, msg string, err error, args ...any) {
+    level := slog.LevelError
+    if StatusForError(err) < 500 {
+        level = slog.LevelWarn
+    }
+    logger.Log(ctx, level, msg, append(args, "error", err)...)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type LoginHistoryHandler struct {
+    svc    service.LoginHistoryService
+    logger *slog.Logger
+}
+
+func NewLoginHistoryHandler(svc service.LoginHistoryService, logger *slog.Logger) *LoginHistoryHandler {
+    return &LoginHistoryHandler{svc: svc, logger: logger}
+}
+
+// ListMine godoc
+// @Summary      List my logins
+// @Description  Get the caller's successful logins, newest first, with the device and address each
+// @Description  was made from. new_device marks a login from an IP or user agent not used before,
+// @Description  which the account's email address was alerted to.
+// @Tags         Users
+// @Security     BearerAuth
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Success      200  {object}  model.Page[model.LoginRecord]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/logins [get]
+func (h *LoginHistoryHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    logins, err := h.svc.ListMine(r.Context(), userID, parsePageRequest(r))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list logins failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list logins")
+        return
+    }
+
+    respond.Page(r.Context(), w, logins)
+}
+
+// recordLogin adds u's login to their history once it has succeeded. The
+// login stands whether or not that works, so failures are only logged.
+// logins may be nil, in which case nothing is recorded.
+func recordLogin(r *http.Request, logins service.LoginHistoryService, logger *slog.Logger, u *model.User) {
+    if logins == nil {
+        return
+    }
+    if _, err := logins.Record(r.Context(), u, r.UserAgent(), ClientIP(r)); err != nil {
+        logger.ErrorContext(r.Context(), "record login failed", "user_id", u.ID, "error", err)
+    }
+}
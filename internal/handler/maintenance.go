@@ -0,0 +1,113 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type MaintenanceHandler struct {
+    svc    service.MaintenanceService
+    logger *slog.Logger
+}
+
+func NewMaintenanceHandler(svc service.MaintenanceService, logger *slog.Logger) *MaintenanceHandler {
+    return &MaintenanceHandler{svc: svc, logger: logger}
+}
+
+// Get godoc
+// @Summary      Get maintenance mode
+// @Description  Get whether the API is in maintenance mode, and who last changed it
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.Maintenance
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/maintenance [get]
+func (h *MaintenanceHandler) Get(w http.ResponseWriter, r *http.Request) {
+    m, err := h.svc.Get(r.Context())
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get maintenance mode failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get maintenance mode")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, m)
+}
+
+// Set godoc
+// @Summary      Switch maintenance mode
+// @Description  Turn maintenance mode on or off for every instance. While it is on, requests other than
+// @Description  admin, health and allowlisted ones get 503 with the message and a Retry-After header.
+// @Description  Other instances follow within MAINTENANCE_CACHE_TTL.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.MaintenanceRequest  true  "Mode"
+// @Produce      json
+// @Success      200  {object}  model.Maintenance
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/maintenance [put]
+func (h *MaintenanceHandler) Set(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.MaintenanceRequest](w, r)
+    if !ok {
+        return
+    }
+
+    m, err := h.svc.Set(r.Context(), GetUserID(r.Context()), req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "set maintenance mode failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to set maintenance mode")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, m)
+}
+
+// MaintenanceMiddleware answers requests with 503 and Retry-After while
+// maintenance mode is on. Admin routes, and routes matching one of the
+// allow patterns, are let through so admins can still sign in and switch
+// it off. Patterns are path.Match globs relative to the API version, as for
+// TimeoutMiddleware. retryAfter is sent when the mode doesn't name its own.
+func MaintenanceMiddleware(svc service.MaintenanceService, retryAfter time.Duration, allow []string) func(http.Handler) http.Handler {
+    exempt := func(p string) bool {
+        if p == "/admin" || strings.HasPrefix(p, "/admin/") {
+            return true
+        }
+        for _, pattern := range allow {
+            if ok, _ := path.Match(pattern, p); ok {
+                return true
+            }
+        }
+        return false
+    }
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            m := svc.Current(r.Context())
+            if !m.Enabled || exempt(routePath(r)) {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            seconds := m.RetryAfterSeconds
+            if seconds == 0 {
+                seconds = int(retryAfter.Seconds())
+            }
+            message := m.Message
+            if message == "" {
+                message = "The API is down for maintenance"
+            }
+            w.Header().Set("Retry-After", strconv.Itoa(seconds))
+            WriteError(r.Context(), w, http.StatusServiceUnavailable, message)
+        })
+    }
+}
@@ -2,76 +2,150 @@ package handler
 
 import (
     "context"
-    "log"
+    "fmt"
+    "log/slog"
+    "net"
     "net/http"
+    "net/netip"
+    "runtime/debug"
+    "strings"
     "time"
 
+    "github.com/go-chi/chi/v5"
     "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
 )
 
-type ContextKey string
-
-const RequestIDKey ContextKey = "request-id"
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+    return respond.WithRequestID(ctx, requestID)
+}
 
-// RequestIDMiddleware adds unique request ID to all requests
+// RequestIDMiddleware adds unique request ID to all requests. The ID is
+// echoed in the response, written with every log line and error body, sent
+// on to the services the request calls (see requestid.Transport) and shown
+// in the application_name of the database sessions it uses.
 func RequestIDMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        requestID := r.Header.Get("X-Request-ID")
+        requestID := r.Header.Get(requestid.Header)
         if requestID == "" {
             requestID = uuid.New().String()
         }
 
-        w.Header().Set("X-Request-ID", requestID)
-        ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+        w.Header().Set(requestid.Header, requestID)
+        ctx := WithRequestID(r.Context(), requestID)
+        ctx = logger.WithAttrs(ctx, "request_id", requestID)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
 
-// LoggingMiddleware logs HTTP requests with timing and request ID
-func LoggingMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        start := time.Now()
-        requestID := GetRequestID(r.Context())
+// LoggingMiddleware writes one structured access log line per request with
+// method, route, status and latency. request_id and user_id are attached by
+// the context-aware handler behind reqLogger.
+func LoggingMiddleware(reqLogger *slog.Logger) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
 
-        wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-        next.ServeHTTP(wrapped, r)
+            wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+            next.ServeHTTP(wrapped, r)
 
-        duration := time.Since(start)
+            duration := time.Since(start)
+            route := routePattern(r)
 
-        log.Printf("[%s] %s %s %s - %d (%dms)",
-            requestID, r.Method, r.RequestURI, r.RemoteAddr, wrapped.statusCode, duration.Milliseconds())
+            reqLogger.LogAttrs(r.Context(), accessLogLevel(wrapped.statusCode), "request",
+                slog.String("method", r.Method),
+                slog.String("path", r.URL.Path),
+                slog.String("route", route),
+                slog.Int("status", wrapped.statusCode),
+                slog.Int64("latency_ms", duration.Milliseconds()),
+                slog.String("remote_addr", r.RemoteAddr),
+            )
 
-        // Send metrics to CloudWatch
-        cwLogger := logger.GetLogger()
-if cwLogger != nil {
-    _ = cwLogger.PutMetric(r.Context(), "ClientErrors", 1, "Count")
+            recordRequestMetrics(route, wrapped.statusCode, duration)
+        })
+    }
 }
 
-// And for ServerErrors:
-if cwLogger != nil {
-    _ = cwLogger.PutMetric(r.Context(), "ServerErrors", 1, "Count")
+func accessLogLevel(status int) slog.Level {
+    switch {
+    case status >= 500:
+        return slog.LevelError
+    case status >= 400:
+        return slog.LevelWarn
+    }
+    return slog.LevelInfo
 }
 
-// And for RequestCount:
-if cwLogger != nil {
-    _ = cwLogger.PutMetric(r.Context(), "RequestCount", 1, "Count")
+// recordRequestMetrics buffers per-request metrics; the CloudWatch logger
+// publishes them in batches.
+func recordRequestMetrics(route string, status int, duration time.Duration) {
+
+    cwLogger := logger.GetLogger()
+    dims := map[string]string{
+        "Route":       route,
+        "StatusClass": fmt.Sprintf("%dxx", status/100),
+    }
+    cwLogger.RecordMetric("RequestCount", 1, "Count", dims)
+    cwLogger.RecordMetric("Latency", float64(duration.Milliseconds()), "Milliseconds", dims)
+    switch {
+    case status >= 500:
+        cwLogger.RecordMetric("ServerErrors", 1, "Count", dims)
+    case status >= 400:
+        cwLogger.RecordMetric("ClientErrors", 1, "Count", dims)
+    }
 }
-    })
+
+// routePattern returns the matched chi route (e.g. /books/{id}) so metric
+// dimensions don't explode with one series per ID.
+func routePattern(r *http.Request) string {
+    if rctx := chi.RouteContext(r.Context()); rctx != nil {
+        if pattern := rctx.RoutePattern(); pattern != "" {
+            return pattern
+        }
+    }
+    return "unmatched"
 }
 
-// RecoveryMiddleware handles panics gracefully
-func RecoveryMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        defer func() {
-            if err := recover(); err != nil {
-                requestID := GetRequestID(r.Context())
-                log.Printf("[%s] [PANIC] %v", requestID, err)
-                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-            }
-        }()
-        next.ServeHTTP(w, r)
-    })
+// RecoveryMiddleware turns a panic in a handler into a 500 JSON error, logs
+// it with its stack and counts it in the Panics metric. It belongs after
+// RequestIDMiddleware and LoggingMiddleware, so the log entries carry the
+// request ID and the access log sees the 500. A handler that had already
+// started its response can't be answered; its connection is closed
+// instead.
+func RecoveryMiddleware(log *slog.Logger) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            sw := &startedWriter{ResponseWriter: w}
+            defer func() {
+                rec := recover()
+                if rec == nil {
+                    return
+                }
+                if rec == http.ErrAbortHandler {
+                    // Deliberate aborts are how handlers drop a connection.
+                    panic(rec)
+                }
+
+                log.ErrorContext(r.Context(), "panic recovered",
+                    "panic", fmt.Sprint(rec),
+                    "method", r.Method,
+                    "path", r.URL.Path,
+                    "stack", string(debug.Stack()),
+                )
+                logger.GetLogger().RecordMetric("Panics", 1, "Count", map[string]string{"Route": routePattern(r)})
+
+                if sw.wrote {
+                    panic(http.ErrAbortHandler)
+                }
+                WriteError(r.Context(), w, http.StatusInternalServerError, "Internal server error")
+            }()
+            next.ServeHTTP(sw, r)
+        })
+    }
 }
 
 // RateLimitMiddleware implements simple rate limiting per IP
@@ -80,10 +154,9 @@ func RateLimitMiddleware(requestsPerSecond int) func(http.Handler) http.Handler
 
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-            clientIP := r.RemoteAddr
+            clientIP := ClientIP(r)
             if !limiter.Allow(clientIP) {
-                requestID := GetRequestID(r.Context())
-                log.Printf("[%s] Rate limit exceeded for IP: %s", requestID, clientIP)
+                slog.WarnContext(r.Context(), "rate limit exceeded", "client_ip", clientIP)
                 http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
                 return
             }
@@ -92,6 +165,94 @@ func RateLimitMiddleware(requestsPerSecond int) func(http.Handler) http.Handler
     }
 }
 
+// DeprecationMiddleware marks every response as coming from a deprecated
+// API version: Deprecation carries the date it was deprecated (RFC 9745),
+// Sunset the date it goes away if one is set (RFC 8594), and Link points at
+// the same path under successor, e.g. "/v1".
+func DeprecationMiddleware(deprecatedAt, sunset time.Time, successor string) func(http.Handler) http.Handler {
+    deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Deprecation", deprecation)
+            if !sunset.IsZero() {
+                w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+            }
+            w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", successor, r.URL.Path))
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// RealIPMiddleware works out the address of the client that sent each
+// request and keeps it for ClientIP, the services (as clientip.From) and
+// every log line of the request. A peer in trusted is a proxy, and its
+// X-Forwarded-For is believed: the client is the rightmost entry that isn't
+// another trusted proxy. Anything a client put in the header itself sits
+// left of that and is ignored. Without trusted proxies the header is
+// ignored altogether, since any client could send it. The address is
+// normalized, so one client always has the same key.
+func RealIPMiddleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ip := clientip.Normalize(realIP(r, trusted))
+            ctx := clientip.With(r.Context(), ip)
+            logger.AddAttrs(ctx, "client_ip", ip)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+func realIP(r *http.Request, trusted []netip.Prefix) string {
+    peer := remoteHost(r)
+    addr, err := netip.ParseAddr(peer)
+    if err != nil || !isTrusted(addr, trusted) {
+        return peer
+    }
+    hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+        if err != nil {
+            // A garbled entry can't be followed further.
+            break
+        }
+        if !isTrusted(hop, trusted) {
+            return hop.String()
+        }
+        peer = hop.String()
+    }
+    return peer
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+    addr = addr.Unmap()
+    for _, p := range trusted {
+        if p.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
+
+// ClientIP returns the address of the client that sent r, as
+// RealIPMiddleware found it, or the connection's peer address without it.
+// Rate limits and login lockouts are keyed on it.
+func ClientIP(r *http.Request) string {
+    if ip := clientip.From(r.Context()); ip != "" {
+        return ip
+    }
+    return clientip.Normalize(remoteHost(r))
+}
+
+// remoteHost is the address of the connection's peer, without its port.
+func remoteHost(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
 type responseWriter struct {
     http.ResponseWriter
     statusCode int
@@ -102,10 +263,15 @@ func (rw *responseWriter) WriteHeader(code int) {
     rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the connection's writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+    return rw.ResponseWriter
+}
+
 // GetRequestID retrieves request ID from context
 func GetRequestID(ctx context.Context) string {
-    id, ok := ctx.Value(RequestIDKey).(string)
-    if !ok {
+    id := respond.RequestID(ctx)
+    if id == "" {
         return "unknown"
     }
     return id
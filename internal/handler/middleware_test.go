@@ -0,0 +1,661 @@
+package handler
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "net/netip"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/i18n"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/stretchr/testify/require"
+)
+
+func TestLoggingMiddleware_WritesJSONAccessLog(t *testing.T) {
+    var buf bytes.Buffer
+    log := logger.NewJSON(&buf, nil)
+
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            return map[string]interface{}{"user_id": "user-42", "username": "john", "role": "user"}, nil
+        },
+    }
+
+    r := chi.NewRouter()
+    r.Use(RequestIDMiddleware)
+    r.Use(LoggingMiddleware(log))
+    r.With(AuthMiddleware(authSvc, nil, CookieAuth{})).Get("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    req := httptest.NewRequest("GET", "/books/abc", nil)
+    req.Header.Set("X-Request-ID", "req-123")
+    req.Header.Set("Authorization", "Bearer token")
+    r.ServeHTTP(httptest.NewRecorder(), req)
+
+    var entry map[string]interface{}
+    require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+    require.Equal(t, "request", entry["msg"])
+    require.Equal(t, "req-123", entry["request_id"])
+    require.Equal(t, "user-42", entry["user_id"])
+    require.Equal(t, "/books/{id}", entry["route"])
+    require.Equal(t, float64(http.StatusNoContent), entry["status"])
+    require.Contains(t, entry, "latency_ms")
+}
+
+func TestRecoveryMiddleware_RespondsWithJSON(t *testing.T) {
+    var buf bytes.Buffer
+    log := logger.NewJSON(&buf, nil)
+
+    r := chi.NewRouter()
+    r.Use(RequestIDMiddleware)
+    r.Use(RecoveryMiddleware(log))
+    r.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+        panic("nil map")
+    })
+    r.Get("/partial", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusOK)
+        panic("half way")
+    })
+    r.Get("/abort", func(w http.ResponseWriter, r *http.Request) {
+        panic(http.ErrAbortHandler)
+    })
+
+    req := httptest.NewRequest("GET", "/boom", nil)
+    req.Header.Set("X-Request-ID", "req-123")
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, req)
+
+    require.Equal(t, http.StatusInternalServerError, rec.Code)
+    require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+    var resp ErrorResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+    require.Equal(t, "req-123", resp.RequestID)
+    require.Equal(t, "Internal server error", resp.Message)
+
+    var entry map[string]interface{}
+    require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+    require.Equal(t, "panic recovered", entry["msg"])
+    require.Equal(t, "req-123", entry["request_id"])
+    require.Equal(t, "nil map", entry["panic"])
+    require.Contains(t, entry["stack"], "runtime/debug.Stack")
+
+    // Once the response has started, the connection is aborted instead.
+    require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+        r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/partial", nil))
+    })
+    buf.Reset()
+    require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+        r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+    })
+    require.Empty(t, buf.String(), "deliberate aborts aren't logged")
+}
+
+func TestRequestBodyMiddleware(t *testing.T) {
+    var gotLimit int64
+    h := RequestBodyMiddleware(16, "/admin/books/import")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotLimit = bodyLimit(r.Context())
+        w.WriteHeader(http.StatusNoContent)
+    }))
+
+    tests := []struct {
+        name        string
+        method      string
+        path        string
+        body        string
+        contentType string
+        want        int
+    }{
+        {"json within limit", "POST", "/books", `{"a":1}`, "application/json; charset=utf-8", http.StatusNoContent},
+        {"too large", "POST", "/books", `{"title":"much too long"}`, "application/json", http.StatusRequestEntityTooLarge},
+        {"not json", "PUT", "/books/1", `title=x`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+        {"missing content type", "POST", "/books", `{"a":1}`, "", http.StatusUnsupportedMediaType},
+        {"plus json", "PATCH", "/books/1", `{"a":1}`, "application/merge-patch+json", http.StatusNoContent},
+        {"empty post", "POST", "/bookings/1/return", "", "", http.StatusNoContent},
+        {"exempt path", "POST", "/admin/books/import", "title,author\nlong enough to exceed", "text/csv", http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+            if tt.contentType != "" {
+                req.Header.Set("Content-Type", tt.contentType)
+            }
+            rec := httptest.NewRecorder()
+            h.ServeHTTP(rec, req)
+            require.Equal(t, tt.want, rec.Code)
+        })
+    }
+    require.Equal(t, int64(defaultMaxBodyBytes), gotLimit, "exempt paths keep the default limit")
+}
+
+func TestRequestBodyMiddleware_ChunkedBodyOverLimit(t *testing.T) {
+    r := chi.NewRouter()
+    r.Use(RequestBodyMiddleware(16))
+    r.Post("/books", func(w http.ResponseWriter, r *http.Request) {
+        _, ok := Bind[struct {
+            Title string `json:"title"`
+        }](w, r)
+        if ok {
+            w.WriteHeader(http.StatusNoContent)
+        }
+    })
+
+    req := httptest.NewRequest("POST", "/books", bytes.NewBufferString(`{"title":"much too long"}`))
+    req.Header.Set("Content-Type", "application/json")
+    req.ContentLength = -1
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, req)
+    require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+    deprecatedAt := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+    sunset := time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC)
+    next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    rec := httptest.NewRecorder()
+    DeprecationMiddleware(deprecatedAt, sunset, "/v1")(next).ServeHTTP(rec, httptest.NewRequest("GET", "/books/42", nil))
+    require.Equal(t, http.StatusNoContent, rec.Code)
+    require.Equal(t, "@1792108800", rec.Header().Get("Deprecation"))
+    require.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+    require.Equal(t, `</v1/books/42>; rel="successor-version"`, rec.Header().Get("Link"))
+
+    rec = httptest.NewRecorder()
+    DeprecationMiddleware(deprecatedAt, time.Time{}, "/v1")(next).ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+    require.Empty(t, rec.Header().Get("Sunset"), "no sunset header until a date is announced")
+}
+
+func TestRealIPMiddleware(t *testing.T) {
+    trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+    tests := []struct {
+        name       string
+        remoteAddr string
+        xff        []string
+        want       string
+    }{
+        {"direct client", "203.0.113.9:51234", nil, "203.0.113.9"},
+        {"untrusted peer can't claim an address", "203.0.113.9:51234", []string{"198.51.100.1"}, "203.0.113.9"},
+        {"through the load balancer", "10.0.0.5:443", []string{"198.51.100.1"}, "198.51.100.1"},
+        {"spoofed entries left of the client", "10.0.0.5:443", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+        {"chain of trusted proxies", "10.0.0.5:443", []string{"198.51.100.1, 10.1.1.1", "10.2.2.2"}, "198.51.100.1"},
+        {"garbled header", "10.0.0.5:443", []string{"unknown"}, "10.0.0.5"},
+        {"IPv4-mapped peer", "[::ffff:203.0.113.9]:51234", nil, "203.0.113.9"},
+        {"IPv6 zone dropped", "[fe80::1%eth0]:51234", nil, "fe80::1"},
+        {"IPv6 written canonically", "10.0.0.5:443", []string{"2001:DB8:0:0::1"}, "2001:db8::1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got string
+            h := RealIPMiddleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                got = ClientIP(r)
+                require.Equal(t, got, clientip.From(r.Context()))
+            }))
+            req := httptest.NewRequest(http.MethodGet, "/", nil)
+            req.RemoteAddr = tt.remoteAddr
+            for _, v := range tt.xff {
+                req.Header.Add("X-Forwarded-For", v)
+            }
+            h.ServeHTTP(httptest.NewRecorder(), req)
+            require.Equal(t, tt.want, got)
+        })
+    }
+}
+
+func TestTimeoutMiddleware_RespondsWhenBudgetIsSpent(t *testing.T) {
+    h := TimeoutMiddleware(20*time.Millisecond, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        <-r.Context().Done()
+    }))
+
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+    require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+    var body ErrorResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+    require.Equal(t, "Request timed out", body.Message)
+}
+
+func TestTimeoutMiddleware_ServiceErrorFromCancelledQuery(t *testing.T) {
+    h := TimeoutMiddleware(20*time.Millisecond, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        <-r.Context().Done()
+        WriteServiceError(r.Context(), w, fmt.Errorf("list books: %w", r.Context().Err()), "Failed to list books")
+    }))
+
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest("GET", "/books", nil))
+    require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+    require.Contains(t, rec.Body.String(), "Request timed out")
+}
+
+func TestTimeoutMiddleware_RouteBudgetBelowVersionPrefix(t *testing.T) {
+    var remaining time.Duration
+    r := chi.NewRouter()
+    r.Route("/v1", func(r chi.Router) {
+        r.Use(TimeoutMiddleware(10*time.Millisecond, map[string]time.Duration{"/admin/books/*/enrich": time.Minute}))
+        r.Post("/admin/books/{id}/enrich", func(w http.ResponseWriter, r *http.Request) {
+            deadline, _ := r.Context().Deadline()
+            remaining = time.Until(deadline)
+            w.WriteHeader(http.StatusOK)
+        })
+    })
+
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/admin/books/42/enrich", nil))
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Greater(t, remaining, 30*time.Second)
+}
+
+func TestPayloadLoggingMiddleware_RedactsFailedRequests(t *testing.T) {
+    var buf bytes.Buffer
+    log := logger.NewJSON(&buf, nil)
+
+    r := chi.NewRouter()
+    r.Use(RequestIDMiddleware)
+    r.Use(PayloadLoggingMiddleware(log, 1024))
+    r.Post("/auth/login", func(w http.ResponseWriter, r *http.Request) {
+        _, _ = io.ReadAll(r.Body)
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusUnauthorized)
+        _, _ = w.Write([]byte(`{"error":"Unauthorized","token":"leaked"}`))
+    })
+    r.Post("/books", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+    })
+
+    req := httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"username":"john","password":"hunter2","nested":[{"refresh_token":"x"}]}`))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer abc")
+    req.Header.Set("X-Request-ID", "req-7")
+    r.ServeHTTP(httptest.NewRecorder(), req)
+
+    var entry map[string]interface{}
+    require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+    require.Equal(t, "request payload", entry["msg"])
+    require.Equal(t, "req-7", entry["request_id"])
+    require.Equal(t, map[string]interface{}{
+        "username": "john",
+        "password": "[REDACTED]",
+        "nested":   []interface{}{map[string]interface{}{"refresh_token": "[REDACTED]"}},
+    }, entry["request_body"])
+    require.Equal(t, "[REDACTED]", entry["request_headers"].(map[string]interface{})["Authorization"])
+    require.Equal(t, "[REDACTED]", entry["response_body"].(map[string]interface{})["token"])
+    require.NotContains(t, buf.String(), "hunter2")
+
+    buf.Reset()
+    req = httptest.NewRequest("POST", "/books", bytes.NewBufferString(`{"title":"x"}`))
+    req.Header.Set("Content-Type", "application/json")
+    r.ServeHTTP(httptest.NewRecorder(), req)
+    require.Empty(t, buf.String(), "successful requests are not logged")
+}
+
+func TestPayloadLoggingMiddleware_OmitsTruncatedBodies(t *testing.T) {
+    var buf bytes.Buffer
+    h := PayloadLoggingMiddleware(logger.NewJSON(&buf, nil), 8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _, _ = io.ReadAll(r.Body)
+        w.WriteHeader(http.StatusBadRequest)
+    }))
+
+    req := httptest.NewRequest("POST", "/books", bytes.NewBufferString(`{"password":"far too long to capture"}`))
+    req.Header.Set("Content-Type", "application/json")
+    h.ServeHTTP(httptest.NewRecorder(), req)
+
+    require.NotContains(t, buf.String(), "far too long")
+    require.Contains(t, buf.String(), `"omitted":true`)
+}
+
+// branchesByCode resolves branches by code for TenantMiddleware.
+type branchesByCode struct {
+    service.BranchService
+    ids map[string]string
+}
+
+func (b branchesByCode) Resolve(_ context.Context, ref string) (model.Branch, error) {
+    id, ok := b.ids[ref]
+    if !ok {
+        return model.Branch{}, apperr.NotFound("branch not found")
+    }
+    return model.Branch{ID: id, Code: ref}, nil
+}
+
+func TestTenantMiddleware(t *testing.T) {
+    branches := branchesByCode{ids: map[string]string{"north": "b-north", "south": "b-south"}}
+    var got string
+    h := TenantMiddleware(branches, "library.example.com")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got = tenant.BranchID(r.Context())
+        w.WriteHeader(http.StatusNoContent)
+    }))
+
+    tests := []struct {
+        name   string
+        host   string
+        header string
+        want   string
+        code   int
+    }{
+        {"no branch", "library.example.com", "", "", http.StatusNoContent},
+        {"subdomain", "north.library.example.com:8080", "", "b-north", http.StatusNoContent},
+        {"header wins", "north.library.example.com", "south", "b-south", http.StatusNoContent},
+        {"other domain", "north.example.org", "", "", http.StatusNoContent},
+        {"unknown branch", "east.library.example.com", "", "", http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got = ""
+            req := httptest.NewRequest("GET", "/books", nil)
+            req.Host = tt.host
+            if tt.header != "" {
+                req.Header.Set(BranchHeader, tt.header)
+            }
+            rec := httptest.NewRecorder()
+            h.ServeHTTP(rec, req)
+            require.Equal(t, tt.code, rec.Code)
+            require.Equal(t, tt.want, got)
+        })
+    }
+}
+
+func TestAuthMiddleware_BranchScopedToken(t *testing.T) {
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            branch := token
+            if token == "global" {
+                branch = ""
+            }
+            return map[string]interface{}{"user_id": "user-1", "username": "john", "role": "user", "branch_id": branch}, nil
+        },
+    }
+    var got string
+    h := AuthMiddleware(authSvc, nil, CookieAuth{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got = tenant.BranchID(r.Context())
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    serve := func(token, branch string) int {
+        got = ""
+        req := httptest.NewRequest("GET", "/bookings", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        if branch != "" {
+            req = req.WithContext(tenant.WithBranch(req.Context(), branch))
+        }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusNoContent, serve("b-north", ""))
+    require.Equal(t, "b-north", got, "a scoped token scopes the request")
+
+    require.Equal(t, http.StatusNoContent, serve("b-north", "b-north"))
+    require.Equal(t, http.StatusForbidden, serve("b-north", "b-south"))
+
+    require.Equal(t, http.StatusNoContent, serve("global", "b-south"))
+    require.Equal(t, "b-south", got, "global users may use any branch")
+}
+
+func TestAuthMiddleware_StoresClaims(t *testing.T) {
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            return map[string]interface{}{"user_id": "user-1", "username": "john", "role": "admin", "branch_id": "b-north"}, nil
+        },
+    }
+    var got AuthContext
+    var ok bool
+    h := AuthMiddleware(authSvc, nil, CookieAuth{})(AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got, ok = ClaimsFromContext(r.Context())
+        w.WriteHeader(http.StatusNoContent)
+    })))
+
+    req := httptest.NewRequest("GET", "/admin/users", nil)
+    req.Header.Set("Authorization", "Bearer token")
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, req)
+
+    require.Equal(t, http.StatusNoContent, rec.Code)
+    require.True(t, ok)
+    require.Equal(t, AuthContext{UserID: "user-1", Username: "john", Role: "admin", BranchID: "b-north"}, got)
+
+    _, ok = ClaimsFromContext(req.Context())
+    require.False(t, ok, "the caller's request is left untouched")
+    require.Empty(t, GetUserID(req.Context()))
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            if token == "expired" {
+                return nil, service.ErrTokenExpired
+            }
+            return map[string]interface{}{"user_id": "user-1", "username": "john", "role": "admin"}, nil
+        },
+    }
+    var admin bool
+    h := OptionalAuthMiddleware(authSvc, nil, CookieAuth{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        claims, _ := ClaimsFromContext(r.Context())
+        admin = claims.IsAdmin()
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    serve := func(header string) int {
+        admin = false
+        req := httptest.NewRequest("GET", "/announcements", nil)
+        if header != "" {
+            req.Header.Set("Authorization", header)
+        }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusNoContent, serve(""))
+    require.False(t, admin, "an anonymous caller got claims")
+    require.Equal(t, http.StatusNoContent, serve("Bearer good"))
+    require.True(t, admin)
+    require.Equal(t, http.StatusUnauthorized, serve("Bearer expired"), "a bad token was served anonymously")
+    require.Equal(t, http.StatusUnauthorized, serve("Basic dXNlcjpwYXNz"))
+}
+
+func TestAuthMiddleware_TokenErrors(t *testing.T) {
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            switch token {
+            case "expired":
+                return nil, service.ErrTokenExpired
+            case "revoked":
+                return nil, service.ErrSessionRevoked
+            case "forged":
+                return nil, service.ErrTokenInvalid
+            case "db-down":
+                return nil, fmt.Errorf("load sessions: %w", io.ErrUnexpectedEOF)
+            }
+            return map[string]interface{}{"user_id": "user-1", "role": "user"}, nil
+        },
+    }
+    h := AuthMiddleware(authSvc, nil, CookieAuth{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }))
+
+    tests := []struct {
+        header string
+        status int
+        code   string
+    }{
+        {"", http.StatusUnauthorized, "token_missing"},
+        {"Bear", http.StatusUnauthorized, "token_malformed"},
+        {"Basic dXNlcjpwYXNz", http.StatusUnauthorized, "token_malformed"},
+        {"Bearer", http.StatusUnauthorized, "token_malformed"},
+        {"Bearer ", http.StatusUnauthorized, "token_malformed"},
+        {"Bearer two parts", http.StatusUnauthorized, "token_malformed"},
+        {"Bearer expired", http.StatusUnauthorized, "token_expired"},
+        {"Bearer revoked", http.StatusUnauthorized, "token_revoked"},
+        {"Bearer forged", http.StatusUnauthorized, "token_invalid"},
+        {"Bearer db-down", http.StatusInternalServerError, ""},
+        {"Bearer good", http.StatusNoContent, ""},
+        {"bearer  good", http.StatusNoContent, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.header, func(t *testing.T) {
+            req := httptest.NewRequest("GET", "/bookings", nil)
+            if tt.header != "" {
+                req.Header.Set("Authorization", tt.header)
+            }
+            rec := httptest.NewRecorder()
+            require.NotPanics(t, func() { h.ServeHTTP(rec, req) })
+
+            require.Equal(t, tt.status, rec.Code)
+            if tt.code == "" {
+                return
+            }
+            var resp ErrorResponse
+            require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+            require.Equal(t, tt.code, resp.Code)
+            require.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+        })
+    }
+}
+
+func TestAuthMiddleware_Cookie(t *testing.T) {
+    authSvc := &mockAuthService{
+        validateFn: func(token string) (map[string]interface{}, error) {
+            if token != "from-cookie" {
+                return nil, service.ErrTokenInvalid
+            }
+            return map[string]interface{}{"user_id": "user-1", "role": "user"}, nil
+        },
+    }
+    handler := func(cookie string) http.Handler {
+        return AuthMiddleware(authSvc, nil, CookieAuth{Token: cookie})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.WriteHeader(http.StatusNoContent)
+        }))
+    }
+    serve := func(h http.Handler, method string, header map[string]string) int {
+        req := httptest.NewRequest(method, "/bookings", nil)
+        req.AddCookie(&http.Cookie{Name: "library_token", Value: "from-cookie"})
+        for k, v := range header {
+            req.Header.Set(k, v)
+        }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    require.Equal(t, http.StatusUnauthorized, serve(handler(""), "GET", nil), "cookies are ignored unless configured")
+
+    h := handler("library_token")
+    require.Equal(t, http.StatusNoContent, serve(h, "GET", nil))
+    require.Equal(t, http.StatusForbidden, serve(h, "POST", nil), "unsafe methods need X-Requested-With")
+    require.Equal(t, http.StatusNoContent, serve(h, "POST", map[string]string{"X-Requested-With": "XMLHttpRequest"}))
+    require.Equal(t, http.StatusUnauthorized, serve(h, "GET", map[string]string{"Authorization": "Bearer other"}), "the header wins over the cookie")
+
+    h = AuthMiddleware(authSvc, nil, CookieAuth{Token: "library_token", CSRF: "library_csrf"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    csrf := func(method, token string) int {
+        req := httptest.NewRequest(method, "/bookings", nil)
+        req.AddCookie(&http.Cookie{Name: "library_token", Value: "from-cookie"})
+        req.AddCookie(&http.Cookie{Name: "library_csrf", Value: "csrf-1"})
+        req.Header.Set("X-Requested-With", "XMLHttpRequest")
+        if token != "" {
+            req.Header.Set("X-CSRF-Token", token)
+        }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Code
+    }
+    require.Equal(t, http.StatusNoContent, csrf("GET", ""))
+    require.Equal(t, http.StatusForbidden, csrf("POST", ""), "with a CSRF cookie, X-Requested-With isn't enough")
+    require.Equal(t, http.StatusForbidden, csrf("POST", "csrf-2"))
+    require.Equal(t, http.StatusNoContent, csrf("POST", "csrf-1"))
+}
+
+type fakeMaintenance struct {
+    service.MaintenanceService
+    mode model.Maintenance
+}
+
+func (f *fakeMaintenance) Current(context.Context) model.Maintenance {
+    return f.mode
+}
+
+func TestMaintenanceMiddleware(t *testing.T) {
+    maintenance := &fakeMaintenance{}
+    r := chi.NewRouter()
+    r.Route("/v1", func(r chi.Router) {
+        r.Use(MaintenanceMiddleware(maintenance, 5*time.Minute, []string{"/auth/login"}))
+        ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+        r.Get("/books", ok)
+        r.Post("/auth/login", ok)
+        r.Put("/admin/maintenance", ok)
+    })
+    serve := func(method, path string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+        return rec
+    }
+
+    require.Equal(t, http.StatusOK, serve("GET", "/v1/books").Code)
+
+    maintenance.mode = model.Maintenance{Enabled: true, Message: "Upgrading the database"}
+    rec := serve("GET", "/v1/books")
+    require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+    require.Equal(t, "300", rec.Header().Get("Retry-After"))
+    var body ErrorResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+    require.Equal(t, "Upgrading the database", body.Message)
+
+    require.Equal(t, http.StatusOK, serve("POST", "/v1/auth/login").Code, "allowlisted")
+    require.Equal(t, http.StatusOK, serve("PUT", "/v1/admin/maintenance").Code, "admin routes stay up")
+
+    maintenance.mode.RetryAfterSeconds = 60
+    require.Equal(t, "60", serve("GET", "/v1/books").Header().Get("Retry-After"))
+}
+
+func TestLocaleMiddleware_TranslatesErrors(t *testing.T) {
+    catalog, err := i18n.NewCatalog(i18n.Builtin())
+    require.NoError(t, err)
+    h := LocaleMiddleware(catalog)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/bookings" {
+            _, _ = Bind[model.BorrowBookRequest](w, r)
+            return
+        }
+        WriteServiceError(r.Context(), w, apperr.NotFound("book not found"), "Failed to get book")
+    }))
+    serve := func(path, body, lang string) *httptest.ResponseRecorder {
+        req := createTestRequest("POST", path, body, "test-locale")
+        req.Header.Set("Accept-Language", lang)
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec
+    }
+
+    rec := serve("/books/b1", "", "es-ES,es;q=0.9,en;q=0.8")
+    var resp ErrorResponse
+    require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+    require.Equal(t, "libro no encontrado", resp.Message)
+    require.Equal(t, "Not Found", resp.Error, "the status text stays as it is")
+    require.Equal(t, "es", rec.Header().Get("Content-Language"))
+    require.Equal(t, "Accept-Language", rec.Header().Get("Vary"))
+
+    rec = serve("/bookings", `{"borrow_days":-1}`, "es")
+    var verrs struct {
+        Errors map[string]string `json:"errors"`
+    }
+    require.NoError(t, json.NewDecoder(rec.Body).Decode(&verrs))
+    require.Equal(t, map[string]string{"book_id": "book_id es obligatorio", "borrow_days": "borrow_days debe ser como mínimo 1"}, verrs.Errors)
+
+    rec = serve("/books/b1", "", "de")
+    require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+    require.Equal(t, "book not found", resp.Message)
+    require.Equal(t, "en", rec.Header().Get("Content-Language"))
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "log/slog"
+    "net/http"
+    "strings"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/oidc"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+// oidcStateCookie carries the state, nonce and invite code of a login in
+// progress from the redirect to the callback.
+const oidcStateCookie = "oidc_state"
+
+// oidcStateMaxAge is how long a user has to finish logging in at the
+// provider, in seconds.
+const oidcStateMaxAge = 600
+
+type OIDCHandler struct {
+    providers map[string]oidc.Provider
+    svc       service.OIDCService
+    authSvc   service.AuthService
+    logins    service.LoginHistoryService
+    cookies   CookieAuth
+    logger    *slog.Logger
+}
+
+// NewOIDCHandler serves logins through providers, keyed by the name used
+// in the URL. logins and cookies are as for NewAuthHandler.
+func NewOIDCHandler(providers map[string]oidc.Provider, svc service.OIDCService, authSvc service.AuthService, logins service.LoginHistoryService, cookies CookieAuth, logger *slog.Logger) *OIDCHandler {
+    return &OIDCHandler{providers: providers, svc: svc, authSvc: authSvc, logins: logins, cookies: cookies, logger: logger}
+}
+
+// Login godoc
+// @Summary      Log in with an identity provider
+// @Description  Redirect to the provider's login page. The provider sends the user back
+// @Description  to the callback, which answers with a token. While registration is
+// @Description  invite-only, an account that is new to the library needs an invite_code.
+// @Tags         Auth
+// @Param        provider     path   string  true   "Provider: google or github"
+// @Param        invite_code  query  string  false  "Invite code, for a new account while registration is invite-only"
+// @Success      302
+// @Failure      404  {object}  ErrorResponse
+// @Router       /auth/oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+    name := chi.URLParam(r, "provider")
+    p, ok := h.providers[name]
+    if !ok {
+        WriteError(r.Context(), w, http.StatusNotFound, "Unknown login provider")
+        return
+    }
+
+    // The invite code rides along in the state cookie, encoded since it may
+    // hold anything the user typed, to be used if the callback provisions
+    // a user.
+    state, nonce := randomToken(), randomToken()
+    invite := base64.RawURLEncoding.EncodeToString([]byte(r.URL.Query().Get("invite_code")))
+    http.SetCookie(w, &http.Cookie{
+        Name:     oidcStateCookie,
+        Value:    state + "." + nonce + "." + invite,
+        Path:     "/",
+        MaxAge:   oidcStateMaxAge,
+        HttpOnly: true,
+        Secure:   isHTTPS(r),
+        // Lax lets the cookie through on the provider's top-level redirect
+        // back to the callback.
+        SameSite: http.SameSiteLaxMode,
+    })
+    http.Redirect(w, r, p.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+// Callback godoc
+// @Summary      Finish logging in with an identity provider
+// @Description  The provider redirects here after login. The account is matched to the
+// @Description  user it logged in as before, or to the user with its verified email, or
+// @Description  a new user is created for it, using up the invite code given to the login
+// @Description  while registration is invite-only. Answers like /auth/login.
+// @Tags         Auth
+// @Param        provider  path   string  true  "Provider: google or github"
+// @Param        code      query  string  true  "Authorization code"
+// @Param        state     query  string  true  "State from the login redirect"
+// @Produce      json
+// @Success      200  {object}  model.LoginResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /auth/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+    name := chi.URLParam(r, "provider")
+    p, ok := h.providers[name]
+    if !ok {
+        WriteError(r.Context(), w, http.StatusNotFound, "Unknown login provider")
+        return
+    }
+
+    q := r.URL.Query()
+    if e := q.Get("error"); e != "" {
+        h.logger.WarnContext(r.Context(), "provider refused login", "provider", name, "error", e)
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Login was cancelled or refused by the provider")
+        return
+    }
+
+    cookie, err := r.Cookie(oidcStateCookie)
+    var state, nonce, invite string
+    if err == nil {
+        var rest string
+        state, rest, _ = strings.Cut(cookie.Value, ".")
+        nonce, invite, _ = strings.Cut(rest, ".")
+    }
+    // The state is single-use.
+    http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r)})
+    if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+        h.logger.WarnContext(r.Context(), "login state mismatch", "provider", name)
+        WriteError(r.Context(), w, http.StatusBadRequest, "Login expired or was started elsewhere; try again")
+        return
+    }
+    if q.Get("code") == "" {
+        WriteError(r.Context(), w, http.StatusBadRequest, "Missing authorization code")
+        return
+    }
+
+    identity, err := p.Identify(r.Context(), q.Get("code"), nonce)
+    if err != nil {
+        h.logger.WarnContext(r.Context(), "identifying login failed", "provider", name, "error", err)
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Login with the provider failed")
+        return
+    }
+
+    inviteCode, _ := base64.RawURLEncoding.DecodeString(invite)
+    user, err := h.svc.Login(r.Context(), identity, string(inviteCode))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "external login failed", err)
+        WriteServiceError(r.Context(), w, err, "Login failed")
+        return
+    }
+
+    resp, err := h.cookies.startSession(w, r, h.authSvc, user)
+    if err != nil {
+        h.logger.ErrorContext(r.Context(), "token generation failed", "error", err)
+        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to generate token")
+        return
+    }
+
+    recordLogin(r, h.logins, h.logger, user)
+    respond.JSON(r.Context(), w, http.StatusOK, resp)
+    h.logger.InfoContext(r.Context(), "user logged in", "username", user.Username, "role", user.Role, "provider", name)
+}
+
+// randomToken returns 32 random bytes, base64url encoded.
+func randomToken() string {
+    b := make([]byte, 32)
+    _, _ = rand.Read(b)
+    return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// isHTTPS reports whether the client reached the API over HTTPS, directly
+// or through the load balancer.
+func isHTTPS(r *http.Request) bool {
+    return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/oidc"
+    "github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+    gotNonce string
+}
+
+func (p *fakeProvider) AuthCodeURL(state, nonce string) string {
+    return "https://provider.example/auth?" + url.Values{"state": {state}}.Encode()
+}
+
+func (p *fakeProvider) Identify(_ context.Context, code, nonce string) (model.ExternalIdentity, error) {
+    p.gotNonce = nonce
+    return model.ExternalIdentity{Provider: "fake", Subject: code, Email: "alice@example.com", EmailVerified: true}, nil
+}
+
+type mockOIDCService struct {
+    loginFn   func(ctx context.Context, id model.ExternalIdentity) (*model.User, error)
+    gotInvite string
+}
+
+func (m *mockOIDCService) Login(ctx context.Context, id model.ExternalIdentity, inviteCode string) (*model.User, error) {
+    m.gotInvite = inviteCode
+    return m.loginFn(ctx, id)
+}
+
+func newOIDCRouter(p oidc.Provider) (http.Handler, *mockOIDCService) {
+    svc := &mockOIDCService{loginFn: func(_ context.Context, id model.ExternalIdentity) (*model.User, error) {
+        return &model.User{ID: "user-1", Username: "alice", Email: id.Email, Role: "user"}, nil
+    }}
+    authSvc := &mockAuthService{startFn: func(_ context.Context, u *model.User, _, _ string) (string, time.Time, error) {
+        return "token-for-" + u.ID, time.Now().Add(time.Hour), nil
+    }}
+    h := NewOIDCHandler(map[string]oidc.Provider{"fake": p}, svc, authSvc, nil, CookieAuth{}, logger.Discard())
+    r := chi.NewRouter()
+    r.Get("/auth/oidc/{provider}/login", h.Login)
+    r.Get("/auth/oidc/{provider}/callback", h.Callback)
+    return r, svc
+}
+
+func TestOIDCHandler_LoginThenCallback(t *testing.T) {
+    p := &fakeProvider{}
+    router, _ := newOIDCRouter(p)
+
+    rec := httptest.NewRecorder()
+    router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/fake/login", nil))
+    require.Equal(t, http.StatusFound, rec.Code)
+    loc, err := url.Parse(rec.Header().Get("Location"))
+    require.NoError(t, err)
+    state := loc.Query().Get("state")
+    require.NotEmpty(t, state)
+    cookies := rec.Result().Cookies()
+    require.Len(t, cookies, 1)
+    require.True(t, cookies[0].HttpOnly)
+
+    req := httptest.NewRequest("GET", "/auth/oidc/fake/callback?"+url.Values{"state": {state}, "code": {"sub-1"}}.Encode(), nil)
+    req.AddCookie(cookies[0])
+    rec = httptest.NewRecorder()
+    router.ServeHTTP(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+
+    var resp model.LoginResponse
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+    require.Equal(t, "token-for-user-1", resp.Token)
+    require.NotEmpty(t, p.gotNonce, "the nonce from the cookie reaches the provider")
+}
+
+func TestOIDCHandler_CallbackRejectsStateMismatch(t *testing.T) {
+    router, _ := newOIDCRouter(&fakeProvider{})
+
+    req := httptest.NewRequest("GET", "/auth/oidc/fake/callback?state=forged&code=sub-1", nil)
+    req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: "real.nonce"})
+    rec := httptest.NewRecorder()
+    router.ServeHTTP(rec, req)
+    require.Equal(t, http.StatusBadRequest, rec.Code)
+
+    rec = httptest.NewRecorder()
+    router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/fake/callback?state=&code=sub-1", nil))
+    require.Equal(t, http.StatusBadRequest, rec.Code, "no cookie, no login")
+}
+
+func TestOIDCHandler_UnknownProvider(t *testing.T) {
+    rec := httptest.NewRecorder()
+    router, _ := newOIDCRouter(&fakeProvider{})
+    router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/myspace/login", nil))
+    require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestOIDCHandler_CarriesInviteCodeToCallback(t *testing.T) {
+    router, svc := newOIDCRouter(&fakeProvider{})
+
+    rec := httptest.NewRecorder()
+    router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/fake/login?invite_code="+url.QueryEscape("abcd efgh.1"), nil))
+    require.Equal(t, http.StatusFound, rec.Code)
+    loc, err := url.Parse(rec.Header().Get("Location"))
+    require.NoError(t, err)
+    cookies := rec.Result().Cookies()
+    require.Len(t, cookies, 1)
+
+    req := httptest.NewRequest("GET", "/auth/oidc/fake/callback?"+url.Values{"state": {loc.Query().Get("state")}, "code": {"sub-1"}}.Encode(), nil)
+    req.AddCookie(cookies[0])
+    rec = httptest.NewRecorder()
+    router.ServeHTTP(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "abcd efgh.1", svc.gotInvite)
+}
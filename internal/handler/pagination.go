@@ -0,0 +1,87 @@
+package handler
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+const (
+    defaultPageLimit = 20
+    maxPageLimit     = 100
+)
+
+// parsePageRequest reads limit, offset and cursor from the query string.
+// Out-of-range values fall back to the defaults, matching the old behaviour.
+func parsePageRequest(r *http.Request) model.PageRequest {
+    p := model.PageRequest{Limit: defaultPageLimit}
+    q := r.URL.Query()
+
+    if l := q.Get("limit"); l != "" {
+        if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= maxPageLimit {
+            p.Limit = parsed
+        }
+    }
+
+    if o := q.Get("offset"); o != "" {
+        if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+            p.Offset = parsed
+        }
+    }
+
+    p.Cursor = q.Get("cursor")
+    return p
+}
+
+// setTotalCount repeats a page's total in the X-Total-Count header, for
+// clients that size their pager before reading the body.
+func setTotalCount(w http.ResponseWriter, total int) {
+    w.Header().Set("X-Total-Count", strconv.Itoa(total))
+}
+
+// parseBookingExpand reads ?expand=book,user. Unknown names are rejected so
+// typos don't silently return unexpanded bookings.
+func parseBookingExpand(r *http.Request) (model.BookingExpand, error) {
+    var e model.BookingExpand
+    v := r.URL.Query().Get("expand")
+    if v == "" {
+        return e, nil
+    }
+    for _, name := range strings.Split(v, ",") {
+        switch strings.TrimSpace(name) {
+        case "book":
+            e.Book = true
+        case "user":
+            e.User = true
+        default:
+            return e, fmt.Errorf("unknown expand %q (allowed: book, user)", name)
+        }
+    }
+    return e, nil
+}
+
+// parseBookingFilter reads ?status=&user_id=&book_id=&from=&to=. Status is
+// case-insensitive; from and to take the same formats as the exports.
+func parseBookingFilter(r *http.Request) (model.BookingFilter, ValidationErrors) {
+    q := r.URL.Query()
+    f := model.BookingFilter{
+        Status: strings.ToUpper(strings.TrimSpace(q.Get("status"))),
+        UserID: strings.TrimSpace(q.Get("user_id")),
+        BookID: strings.TrimSpace(q.Get("book_id")),
+    }
+    errs := ValidationErrors{}
+    if from, err := parseDateParam(r, "from"); err != nil {
+        errs["from"] = err.Error()
+    } else {
+        f.From = from
+    }
+    if to, err := parseDateParam(r, "to"); err != nil {
+        errs["to"] = err.Error()
+    } else {
+        f.To = to
+    }
+    return f, errs
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "log/slog"
+    "net/http"
+    "strings"
+)
+
+// redacted replaces the value of every sensitive field in a logged payload.
+const redacted = "[REDACTED]"
+
+// sensitiveFields are matched case-insensitively against JSON keys and
+// header names, ignoring "-" and "_", so "refresh_token" and "X-Auth-Token"
+// are both caught by "token".
+var sensitiveFields = []string{"password", "token", "authorization", "secret", "cookie", "apikey"}
+
+func isSensitive(name string) bool {
+    name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+    for _, f := range sensitiveFields {
+        if strings.Contains(name, f) {
+            return true
+        }
+    }
+    return false
+}
+
+// PayloadLoggingMiddleware logs the request and response bodies of requests
+// that fail with a 4xx or 5xx, for diagnosing client integrations. Sensitive
+// fields and headers are redacted, non-JSON bodies are summarised rather
+// than logged, and at most maxBytes of each body are captured. The entry
+// carries the request ID like every other log line for the request.
+//
+// It buffers bodies, so enable it in staging rather than production.
+func PayloadLoggingMiddleware(reqLogger *slog.Logger, maxBytes int) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            reqBody := &cappedBuffer{max: maxBytes}
+            if r.Body != nil && r.Body != http.NoBody {
+                r.Body = struct {
+                    io.Reader
+                    io.Closer
+                }{io.TeeReader(r.Body, reqBody), r.Body}
+            }
+
+            pw := &payloadWriter{ResponseWriter: w, status: http.StatusOK, body: cappedBuffer{max: maxBytes}}
+            next.ServeHTTP(pw, r)
+
+            if pw.status < 400 {
+                return
+            }
+            reqLogger.LogAttrs(r.Context(), slog.LevelInfo, "request payload",
+                slog.String("method", r.Method),
+                slog.String("path", r.URL.Path),
+                slog.Int("status", pw.status),
+                slog.Any("request_headers", redactHeaders(r.Header)),
+                slog.Any("request_body", loggedBody(r.Header.Get("Content-Type"), reqBody)),
+                slog.Any("response_body", loggedBody(pw.Header().Get("Content-Type"), &pw.body)),
+            )
+        })
+    }
+}
+
+// redactHeaders flattens h for logging with sensitive values replaced.
+func redactHeaders(h http.Header) map[string]string {
+    out := make(map[string]string, len(h))
+    for name, values := range h {
+        if isSensitive(name) {
+            out[name] = redacted
+            continue
+        }
+        out[name] = strings.Join(values, ", ")
+    }
+    return out
+}
+
+// loggedBody returns a JSON body as a value with sensitive fields redacted.
+// Anything else, or JSON cut off at the capture limit, is only described,
+// since it can't be redacted reliably.
+func loggedBody(contentType string, b *cappedBuffer) interface{} {
+    if b.Len() == 0 {
+        return nil
+    }
+    if !isJSON(contentType) || b.truncated {
+        return map[string]interface{}{
+            "content_type": contentType,
+            "bytes":        b.total,
+            "omitted":      true,
+        }
+    }
+
+    var v interface{}
+    if err := json.Unmarshal(b.Bytes(), &v); err != nil {
+        return map[string]interface{}{"content_type": contentType, "bytes": b.total, "invalid_json": true}
+    }
+    return redactJSON(v)
+}
+
+func redactJSON(v interface{}) interface{} {
+    switch t := v.(type) {
+    case map[string]interface{}:
+        for k, val := range t {
+            if isSensitive(k) {
+                t[k] = redacted
+                continue
+            }
+            t[k] = redactJSON(val)
+        }
+    case []interface{}:
+        for i, val := range t {
+            t[i] = redactJSON(val)
+        }
+    }
+    return v
+}
+
+// cappedBuffer keeps the first max bytes written to it and counts the rest.
+type cappedBuffer struct {
+    bytes.Buffer
+    max       int
+    total     int
+    truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+    b.total += len(p)
+    if room := b.max - b.Len(); room > 0 {
+        if len(p) > room {
+            b.Buffer.Write(p[:room])
+            b.truncated = true
+        } else {
+            b.Buffer.Write(p)
+        }
+    } else if len(p) > 0 {
+        b.truncated = true
+    }
+    return len(p), nil
+}
+
+// payloadWriter copies the response body into a cappedBuffer as it is
+// written.
+type payloadWriter struct {
+    http.ResponseWriter
+    status int
+    body   cappedBuffer
+}
+
+func (pw *payloadWriter) WriteHeader(code int) {
+    pw.status = code
+    pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *payloadWriter) Write(b []byte) (int, error) {
+    _, _ = pw.body.Write(b)
+    return pw.ResponseWriter.Write(b)
+}
+
+func (pw *payloadWriter) Flush() {
+    _ = http.NewResponseController(pw.ResponseWriter).Flush()
+}
+
+func (pw *payloadWriter) Unwrap() http.ResponseWriter {
+    return pw.ResponseWriter
+}
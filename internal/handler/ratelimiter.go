@@ -1,14 +1,21 @@
 package handler
 
 import (
+    "math"
     "sync"
     "time"
 )
 
+// rateLimiterPruneEvery is how often idle keys are dropped, so a limiter
+// keyed by client-chosen values (such as usernames) can't grow unbounded.
+const rateLimiterPruneEvery = time.Minute
+
 type RateLimiter struct {
     mu       sync.RWMutex
     limits   map[string]*clientLimit
-    rpsLimit int
+    rate     float64 // tokens added per second
+    burst    float64
+    prunedAt time.Time
 }
 
 type clientLimit struct {
@@ -18,45 +25,75 @@ type clientLimit struct {
 
 // NewRateLimiter creates a token bucket rate limiter
 func NewRateLimiter(requestsPerSecond int) *RateLimiter {
+    return NewRateLimiterPer(requestsPerSecond, time.Second)
+}
+
+// NewRateLimiterPer creates a token bucket rate limiter allowing n requests
+// per period for each key, in bursts of up to n.
+func NewRateLimiterPer(n int, period time.Duration) *RateLimiter {
     return &RateLimiter{
-        limits:   make(map[string]*clientLimit),
-        rpsLimit: requestsPerSecond,
+        limits: make(map[string]*clientLimit),
+        rate:   float64(n) / period.Seconds(),
+        burst:  float64(n),
     }
 }
 
 // Allow checks if a request from clientIP should be allowed
 func (rl *RateLimiter) Allow(clientIP string) bool {
+    ok, _ := rl.allow(clientIP)
+    return ok
+}
+
+// allow is Allow for any key, also returning how long until the key's next
+// request would be allowed when this one isn't.
+func (rl *RateLimiter) allow(key string) (bool, time.Duration) {
     rl.mu.Lock()
     defer rl.mu.Unlock()
 
     now := time.Now()
-    limit, exists := rl.limits[clientIP]
+    rl.prune(now)
+    limit, exists := rl.limits[key]
 
     if !exists {
-        rl.limits[clientIP] = &clientLimit{
-            tokens:    float64(rl.rpsLimit),
+        rl.limits[key] = &clientLimit{
+            tokens:    rl.burst - 1,
             lastCheck: now,
         }
-        return true
+        return true, 0
     }
 
     // Add tokens based on elapsed time
     elapsed := now.Sub(limit.lastCheck).Seconds()
-    limit.tokens += elapsed * float64(rl.rpsLimit)
+    limit.tokens += elapsed * rl.rate
 
     // Cap tokens at limit
-    if limit.tokens > float64(rl.rpsLimit) {
-        limit.tokens = float64(rl.rpsLimit)
+    if limit.tokens > rl.burst {
+        limit.tokens = rl.burst
     }
 
     limit.lastCheck = now
 
     if limit.tokens >= 1.0 {
         limit.tokens--
-        return true
+        return true, 0
     }
 
-    return false
+    wait := math.Ceil((1 - limit.tokens) / rl.rate)
+    return false, time.Duration(wait) * time.Second
+}
+
+// prune drops the keys whose buckets have refilled, which behave exactly
+// like keys never seen.
+func (rl *RateLimiter) prune(now time.Time) {
+    if now.Sub(rl.prunedAt) < rateLimiterPruneEvery {
+        return
+    }
+    rl.prunedAt = now
+    for key, limit := range rl.limits {
+        if limit.tokens+now.Sub(limit.lastCheck).Seconds()*rl.rate >= rl.burst {
+            delete(rl.limits, key)
+        }
+    }
 }
 
 // Reset clears rate limit data (useful for testing)
@@ -64,4 +101,4 @@ func (rl *RateLimiter) Reset() {
     rl.mu.Lock()
     defer rl.mu.Unlock()
     rl.limits = make(map[string]*clientLimit)
-}
\ No newline at end of file
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type ReadingListHandler struct {
+    svc    service.ReadingListService
+    logger *slog.Logger
+}
+
+func NewReadingListHandler(svc service.ReadingListService, logger *slog.Logger) *ReadingListHandler {
+    return &ReadingListHandler{svc: svc, logger: logger}
+}
+
+// List godoc
+// @Summary      My reading lists
+// @Description  The caller's reading lists, favorites first, each with its books and their
+// @Description  current availability
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists [get]
+func (h *ReadingListHandler) List(w http.ResponseWriter, r *http.Request) {
+    lists, err := h.svc.List(r.Context(), GetUserID(r.Context()))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list reading lists failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list reading lists")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, lists)
+}
+
+// Create godoc
+// @Summary      Create a reading list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.ReadingListRequest  true  "List"
+// @Produce      json
+// @Success      201  {object}  model.ReadingList
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists [post]
+func (h *ReadingListHandler) Create(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.ReadingListRequest](w, r)
+    if !ok {
+        return
+    }
+
+    l, err := h.svc.Create(r.Context(), GetUserID(r.Context()), &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create reading list failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to create reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, l)
+}
+
+// Get godoc
+// @Summary      Get one of my reading lists
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id  path  string  true  "List ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id} [get]
+func (h *ReadingListHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    l, err := h.svc.Get(r.Context(), GetUserID(r.Context()), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get reading list failed", err, "list_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Rename godoc
+// @Summary      Rename a reading list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                    true  "List ID"
+// @Param        request  body  model.ReadingListRequest  true  "List"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id} [put]
+func (h *ReadingListHandler) Rename(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    req, ok := Bind[model.ReadingListRequest](w, r)
+    if !ok {
+        return
+    }
+
+    l, err := h.svc.Rename(r.Context(), GetUserID(r.Context()), id, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "rename reading list failed", err, "list_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to rename reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Delete godoc
+// @Summary      Delete a reading list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id  path  string  true  "List ID"
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id} [delete]
+func (h *ReadingListHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Delete(r.Context(), GetUserID(r.Context()), id); err != nil {
+        logServiceError(r.Context(), h.logger, "delete reading list failed", err, "list_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete reading list")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// AddBook godoc
+// @Summary      Add a book to a reading list
+// @Description  Adding a book already on the list changes nothing
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id      path  string  true  "List ID"
+// @Param        bookId  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id}/books/{bookId} [put]
+func (h *ReadingListHandler) AddBook(w http.ResponseWriter, r *http.Request) {
+    id, bookID := chi.URLParam(r, "id"), chi.URLParam(r, "bookId")
+    l, err := h.svc.AddBook(r.Context(), GetUserID(r.Context()), id, bookID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "add book to reading list failed", err, "list_id", id, "book_id", bookID)
+        WriteServiceError(r.Context(), w, err, "Failed to add book to reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// RemoveBook godoc
+// @Summary      Remove a book from a reading list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id      path  string  true  "List ID"
+// @Param        bookId  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id}/books/{bookId} [delete]
+func (h *ReadingListHandler) RemoveBook(w http.ResponseWriter, r *http.Request) {
+    id, bookID := chi.URLParam(r, "id"), chi.URLParam(r, "bookId")
+    l, err := h.svc.RemoveBook(r.Context(), GetUserID(r.Context()), id, bookID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "remove book from reading list failed", err, "list_id", id, "book_id", bookID)
+        WriteServiceError(r.Context(), w, err, "Failed to remove book from reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Share godoc
+// @Summary      Share a reading list
+// @Description  Make the list readable by anyone with its share_url. Sharing a shared list keeps
+// @Description  its URL.
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id  path  string  true  "List ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id}/share [post]
+func (h *ReadingListHandler) Share(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    l, err := h.svc.Share(r.Context(), GetUserID(r.Context()), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "share reading list failed", err, "list_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to share reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Unshare godoc
+// @Summary      Stop sharing a reading list
+// @Description  The list's share_url stops working; sharing it again gives a new one.
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        id  path  string  true  "List ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/lists/{id}/share [delete]
+func (h *ReadingListHandler) Unshare(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    l, err := h.svc.Unshare(r.Context(), GetUserID(r.Context()), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "unshare reading list failed", err, "list_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to stop sharing reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Shared godoc
+// @Summary      Read a shared reading list
+// @Description  The list shared under token, with its books and their availability. No login needed.
+// @Tags         Reading lists
+// @Param        token  path  string  true  "Share token"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /lists/shared/{token} [get]
+func (h *ReadingListHandler) Shared(w http.ResponseWriter, r *http.Request) {
+    l, err := h.svc.Shared(r.Context(), chi.URLParam(r, "token"))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get shared reading list failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get reading list")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// Favorites godoc
+// @Summary      My favorites
+// @Description  The caller's favorites list, made empty on first use
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/favorites [get]
+func (h *ReadingListHandler) Favorites(w http.ResponseWriter, r *http.Request) {
+    l, err := h.svc.Favorites(r.Context(), GetUserID(r.Context()))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get favorites failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get favorites")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, l)
+}
+
+// AddFavorite godoc
+// @Summary      Favorite a book
+// @Description  Add the book to the caller's favorites list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        bookId  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/favorites/{bookId} [put]
+func (h *ReadingListHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+    h.favorite(w, r, h.svc.AddBook, "Failed to add favorite")
+}
+
+// RemoveFavorite godoc
+// @Summary      Unfavorite a book
+// @Description  Take the book off the caller's favorites list
+// @Tags         Reading lists
+// @Security     BearerAuth
+// @Param        bookId  path  string  true  "Book ID"
+// @Produce      json
+// @Success      200  {object}  model.ReadingList
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/favorites/{bookId} [delete]
+func (h *ReadingListHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+    h.favorite(w, r, h.svc.RemoveBook, "Failed to remove favorite")
+}
+
+// favorite applies change to the book on the caller's favorites list.
+func (h *ReadingListHandler) favorite(w http.ResponseWriter, r *http.Request,
+    change func(ctx context.Context, userID, id, bookID string) (*model.ReadingList, error), msg string) {
+    userID, bookID := GetUserID(r.Context()), chi.URLParam(r, "bookId")
+    favorites, err := h.svc.Favorites(r.Context(), userID)
+    if err == nil {
+        favorites, err = change(r.Context(), userID, favorites.ID, bookID)
+    }
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "update favorites failed", err, "book_id", bookID)
+        WriteServiceError(r.Context(), w, err, msg)
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, favorites)
+}
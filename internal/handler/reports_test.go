@@ -0,0 +1,64 @@
+package handler
+
+import (
+    "context"
+    "encoding/csv"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+type mockReportService struct {
+    report *model.OverdueReport
+}
+
+func (m *mockReportService) Overdue(ctx context.Context) (*model.OverdueReport, error) {
+    return m.report, nil
+}
+
+func (m *mockReportService) SendScheduledOverdue(ctx context.Context) error {
+    return nil
+}
+
+func TestReportHandler_Overdue(t *testing.T) {
+    due := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+    h := NewReportHandler(&mockReportService{report: &model.OverdueReport{
+        FinePerDayCents: 25,
+        Borrowers: []model.OverdueUser{{
+            UserID: "u1", Username: "ada", Email: "ada@example.com", FineCents: 100,
+            Loans: []model.OverdueLoan{
+                {BookingID: "b1", BranchID: "br", BookID: "k1", Title: "Dune", DueDate: due, DaysLate: 3, FineCents: 75},
+                {BookingID: "b2", BranchID: "br", BookID: "k2", Title: "Emma", DueDate: due.AddDate(0, 0, 2), DaysLate: 1, FineCents: 25},
+            },
+        }},
+        TotalLoans:     2,
+        TotalFineCents: 100,
+    }}, logger.Discard())
+
+    get := func(query string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        h.Overdue(rec, httptest.NewRequest("GET", "/admin/reports/overdue"+query, nil))
+        return rec
+    }
+
+    rec := get("")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Contains(t, rec.Body.String(), `"total_fine_cents":100`)
+
+    rec = get("?format=csv")
+    require.Equal(t, http.StatusOK, rec.Code)
+    require.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+    require.Contains(t, rec.Header().Get("Content-Disposition"), `filename="overdue-`)
+    rows, err := csv.NewReader(rec.Body).ReadAll()
+    require.NoError(t, err)
+    require.Len(t, rows, 3)
+    require.Equal(t, overdueReportHeader, rows[0])
+    require.Equal(t, []string{"u1", "ada", "ada@example.com", "b1", "br", "k1", "Dune", "2026-03-02T12:00:00Z", "3", "75"}, rows[1])
+
+    require.Equal(t, http.StatusBadRequest, get("?format=xml").Code)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type ReservationHandler struct {
+    svc    service.ReservationService
+    logger *slog.Logger
+}
+
+func NewReservationHandler(svc service.ReservationService, logger *slog.Logger) *ReservationHandler {
+    return &ReservationHandler{svc: svc, logger: logger}
+}
+
+// Reserve godoc
+// @Summary      Join a book's waitlist
+// @Description  Reserve a book with no free copies. When a copy comes back it is offered
+// @Description  to the first user in line as an OFFERED booking, to accept or decline.
+// @Tags         Reservations
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.ReserveBookRequest  true  "Book to reserve"
+// @Produce      json
+// @Success      201  {object}  model.Reservation
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /reservations [post]
+func (h *ReservationHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.ReserveBookRequest](w, r)
+    if !ok {
+        return
+    }
+
+    res, err := h.svc.Reserve(r.Context(), userID, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "reserve failed", err, "book_id", req.BookID)
+        WriteServiceError(r.Context(), w, err, "Failed to reserve book")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, res)
+}
+
+// ListMine godoc
+// @Summary      List my reservations
+// @Description  Get the caller's waitlist places, oldest first, with their position in line
+// @Tags         Reservations
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}   model.Reservation
+// @Failure      401  {object}  ErrorResponse
+// @Router       /reservations [get]
+func (h *ReservationHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    reservations, err := h.svc.ListMine(r.Context(), userID)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list reservations failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list reservations")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, reservations)
+}
+
+// Cancel godoc
+// @Summary      Leave a waitlist
+// @Description  Cancel one of the caller's reservations
+// @Tags         Reservations
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Reservation ID"
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /reservations/{id} [delete]
+func (h *ReservationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Cancel(r.Context(), userID, id); err != nil {
+        logServiceError(r.Context(), h.logger, "cancel reservation failed", err, "reservation_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to cancel reservation")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+    "strings"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type ReviewHandler struct {
+    svc    service.ReviewService
+    logger *slog.Logger
+}
+
+func NewReviewHandler(svc service.ReviewService, logger *slog.Logger) *ReviewHandler {
+    return &ReviewHandler{svc: svc, logger: logger}
+}
+
+// Create godoc
+// @Summary      Review a book
+// @Description  Rate a book from 1 to 5, with optional text. Only books the caller has
+// @Description  borrowed and returned can be reviewed, once each.
+// @Tags         Books
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                     true  "Book ID"
+// @Param        request  body  model.CreateReviewRequest  true  "Review"
+// @Produce      json
+// @Success      201  {object}  model.Review
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /books/{id}/reviews [post]
+func (h *ReviewHandler) Create(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.CreateReviewRequest](w, r)
+    if !ok {
+        return
+    }
+
+    bookID := chi.URLParam(r, "id")
+    review, err := h.svc.Create(r.Context(), userID, bookID, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "create review failed", err, "book_id", bookID)
+        WriteServiceError(r.Context(), w, err, "Failed to create review")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusCreated, review)
+}
+
+// ListByBook godoc
+// @Summary      List a book's reviews
+// @Description  Get a paginated list of a book's reviews, newest first
+// @Tags         Books
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Book ID"
+// @Param        limit   query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset  query     int     false  "Pagination offset"       default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Success      200  {object}  model.Page[model.Review]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /books/{id}/reviews [get]
+func (h *ReviewHandler) ListByBook(w http.ResponseWriter, r *http.Request) {
+    bookID := chi.URLParam(r, "id")
+    reviews, err := h.svc.ListByBook(r.Context(), bookID, parsePageRequest(r))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list reviews failed", err, "book_id", bookID)
+        WriteServiceError(r.Context(), w, err, "Failed to list reviews")
+        return
+    }
+
+    respond.Page(r.Context(), w, reviews)
+}
+
+// List godoc
+// @Summary      List reviews
+// @Description  Get a paginated list of all reviews, newest first, for moderation
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        book_id  query     string  false  "Only reviews of this book"
+// @Param        user_id  query     string  false  "Only reviews by this user"
+// @Param        limit    query     int     false  "Items per page (1-100)"  default(20)
+// @Param        offset   query     int     false  "Pagination offset"       default(0)
+// @Param        cursor   query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
+// @Produce      json
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.Review]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/reviews [get]
+func (h *ReviewHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    f := model.ReviewFilter{
+        BookID: strings.TrimSpace(q.Get("book_id")),
+        UserID: strings.TrimSpace(q.Get("user_id")),
+    }
+    reviews, err := h.svc.List(r.Context(), parsePageRequest(r), f)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "list reviews failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list reviews")
+        return
+    }
+
+    writeList(w, r, h.logger, listMedia(w, r), "reviews", reviews, reviewTable)
+}
+
+// Delete godoc
+// @Summary      Remove a review
+// @Description  Remove an abusive review. Its content is kept in the audit log.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Review ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/reviews/{id} [delete]
+func (h *ReviewHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    if err := h.svc.Remove(r.Context(), GetUserID(r.Context()), id); err != nil {
+        logServiceError(r.Context(), h.logger, "remove review failed", err, "review_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to remove review")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "review removed", "review_id", id)
+}
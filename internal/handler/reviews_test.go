@@ -0,0 +1,109 @@
+package handler
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+type mockReviewService struct {
+    createFn func(ctx context.Context, userID, bookID string, req *model.CreateReviewRequest) (*model.Review, error)
+    removeFn func(ctx context.Context, actorID, id string) error
+    reviews  []model.Review
+}
+
+func (m *mockReviewService) Create(ctx context.Context, userID, bookID string, req *model.CreateReviewRequest) (*model.Review, error) {
+    return m.createFn(ctx, userID, bookID, req)
+}
+
+func (m *mockReviewService) ListByBook(ctx context.Context, bookID string, p model.PageRequest) (model.Page[model.Review], error) {
+    return model.Page[model.Review]{Items: []model.Review{}}, nil
+}
+
+func (m *mockReviewService) List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error) {
+    return model.Page[model.Review]{Items: append([]model.Review{}, m.reviews...), Total: len(m.reviews)}, nil
+}
+
+func (m *mockReviewService) Remove(ctx context.Context, actorID, id string) error {
+    return m.removeFn(ctx, actorID, id)
+}
+
+func reviewRequest(method, path, body, userID string) *http.Request {
+    req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+    rctx := chi.NewRouteContext()
+    rctx.URLParams.Add("id", "b1")
+    ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+    if userID != "" {
+        ctx = WithClaims(ctx, AuthContext{UserID: userID, Role: "user"})
+    }
+    return req.WithContext(ctx)
+}
+
+func TestReviewHandler_Create(t *testing.T) {
+    svc := &mockReviewService{createFn: func(_ context.Context, userID, bookID string, req *model.CreateReviewRequest) (*model.Review, error) {
+        if userID == "stranger" {
+            return nil, apperr.Forbidden("you can only review books you have borrowed and returned")
+        }
+        return &model.Review{ID: "r1", BookID: bookID, UserID: userID, Rating: req.Rating}, nil
+    }}
+    h := NewReviewHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Create(rec, reviewRequest("POST", "/books/b1/reviews", `{"rating":4,"text":"good"}`, "u1"))
+    require.Equal(t, http.StatusCreated, rec.Code)
+    require.Contains(t, rec.Body.String(), `"book_id":"b1"`)
+
+    rec = httptest.NewRecorder()
+    h.Create(rec, reviewRequest("POST", "/books/b1/reviews", `{"rating":6}`, "u1"))
+    require.Equal(t, http.StatusBadRequest, rec.Code, "ratings run from 1 to 5")
+
+    rec = httptest.NewRecorder()
+    h.Create(rec, reviewRequest("POST", "/books/b1/reviews", `{"rating":3}`, "stranger"))
+    require.Equal(t, http.StatusForbidden, rec.Code)
+
+    rec = httptest.NewRecorder()
+    h.Create(rec, reviewRequest("POST", "/books/b1/reviews", `{"rating":3}`, ""))
+    require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestReviewHandler_DeleteRecordsModerator(t *testing.T) {
+    var gotActor string
+    svc := &mockReviewService{removeFn: func(_ context.Context, actorID, id string) error {
+        gotActor = actorID
+        return nil
+    }}
+    h := NewReviewHandler(svc, logger.Discard())
+
+    rec := httptest.NewRecorder()
+    h.Delete(rec, reviewRequest("DELETE", "/admin/reviews/b1", "", "admin-1"))
+    require.Equal(t, http.StatusNoContent, rec.Code)
+    require.Equal(t, "admin-1", gotActor)
+}
+
+func TestReviewHandler_List_CSVDefusesFormulas(t *testing.T) {
+    svc := &mockReviewService{reviews: []model.Review{
+        {ID: "r1", BookID: "b1", UserID: "u1", Username: "@eve", Rating: 1, Text: `=HYPERLINK("https://evil.example","click")`},
+        {ID: "r2", BookID: "b1", UserID: "u2", Username: "bob", Rating: 5, Text: "fine - really"},
+    }}
+    req := httptest.NewRequest(http.MethodGet, "/admin/reviews", nil)
+    req.Header.Set("Accept", "text/csv")
+    rec := httptest.NewRecorder()
+    NewReviewHandler(svc, logger.Discard()).List(rec, req)
+    require.Equal(t, http.StatusOK, rec.Code)
+
+    rows, err := csv.NewReader(rec.Body).ReadAll()
+    require.NoError(t, err)
+    require.Len(t, rows, 3)
+    require.Equal(t, "'@eve", rows[1][3])
+    require.Equal(t, `'=HYPERLINK("https://evil.example","click")`, rows[1][5])
+    require.Equal(t, "fine - really", rows[2][5], "only leading characters count")
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+    "encoding/json"
+    "log/slog"
+    "net/http"
+    "strings"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// Stream godoc
+// @Summary      Stream the book catalog
+// @Description  Stream every book as NDJSON, one per line in ID order, without buffering the catalog.
+// @Description  A stream that breaks off ends without a clean finish; resume it by passing the ID of the
+// @Description  last complete line as after_id.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        after_id  query  string  false  "Only books after this ID"
+// @Produce      application/x-ndjson
+// @Success      200  {object}  model.Book  "One per line"
+// @Failure      400  {object}  ErrorResponse
+// @Router       /admin/books/stream [get]
+func (h *BookHandler) Stream(w http.ResponseWriter, r *http.Request) {
+    afterID := strings.TrimSpace(r.URL.Query().Get("after_id"))
+    streamNDJSON(w, r, h.logger, "books", func(fn func(*model.Book) error) error {
+        return h.svc.Stream(r.Context(), afterID, fn)
+    })
+}
+
+// Stream godoc
+// @Summary      Stream bookings
+// @Description  Stream every booking as NDJSON, one per line in ID order, without buffering them.
+// @Description  A stream that breaks off ends without a clean finish; resume it by passing the ID of the
+// @Description  last complete line as after_id.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        after_id  query  string  false  "Only bookings after this ID"
+// @Produce      application/x-ndjson
+// @Success      200  {object}  model.Booking  "One per line"
+// @Failure      400  {object}  ErrorResponse
+// @Router       /admin/bookings/stream [get]
+func (h *BookingHandler) Stream(w http.ResponseWriter, r *http.Request) {
+    afterID := strings.TrimSpace(r.URL.Query().Get("after_id"))
+    streamNDJSON(w, r, h.logger, "bookings", func(fn func(*model.Booking) error) error {
+        return h.bookingSvc.Stream(r.Context(), afterID, fn)
+    })
+}
+
+// streamNDJSON writes records as JSON lines as run produces them, flushing
+// every exportFlushEvery records. Unlike an export, a stream that fails part
+// way is aborted rather than ended cleanly, so the client can tell it is
+// incomplete and resume after the last record it read.
+func streamNDJSON[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, name string, run func(func(T) error) error) {
+    rc := http.NewResponseController(w)
+    w.Header().Set("Content-Type", "application/x-ndjson")
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    enc := json.NewEncoder(w)
+
+    count := 0
+    err := run(func(v T) error {
+        if err := enc.Encode(v); err != nil {
+            return err
+        }
+        count++
+        if count%exportFlushEvery == 0 {
+            return rc.Flush()
+        }
+        return nil
+    })
+    if err != nil && count == 0 {
+        logServiceError(r.Context(), logger, "stream failed", err, "stream", name)
+        WriteServiceError(r.Context(), w, err, "Failed to stream "+name)
+        return
+    }
+    if err != nil {
+        logger.ErrorContext(r.Context(), "stream aborted", "stream", name, "records", count, "error", err)
+        panic(http.ErrAbortHandler)
+    }
+    logger.InfoContext(r.Context(), "stream finished", "stream", name, "records", count)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+    "log/slog"
+    "net"
+    "net/http"
+    "strings"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+// BranchHeader names the branch a request is made for, by ID or code.
+const BranchHeader = "X-Branch"
+
+// TenantMiddleware scopes each request to a branch, named by the X-Branch
+// header or, when baseDomain is set, by the subdomain of the Host, e.g.
+// "north" in north.library.example.com. The header wins if both are given.
+// An unknown branch is a 404. Requests naming no branch span all of them,
+// unless AuthMiddleware scopes them to the caller's branch.
+func TenantMiddleware(branches service.BranchService, baseDomain string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ref := strings.TrimSpace(r.Header.Get(BranchHeader))
+            if ref == "" {
+                ref = subdomain(r.Host, baseDomain)
+            }
+            if ref == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            branch, err := branches.Resolve(r.Context(), ref)
+            if err != nil {
+                logServiceError(r.Context(), slog.Default(), "resolve branch failed", err, "branch", ref)
+                WriteServiceError(r.Context(), w, err, "Failed to resolve branch")
+                return
+            }
+
+            ctx := tenant.WithBranch(r.Context(), branch.ID)
+            logger.AddAttrs(ctx, "branch_id", branch.ID)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+// subdomain returns the single label host has in front of baseDomain, or ""
+// when baseDomain is empty or host isn't directly below it.
+func subdomain(host, baseDomain string) string {
+    if baseDomain == "" {
+        return ""
+    }
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+    if !ok || strings.Contains(label, ".") {
+        return ""
+    }
+    return label
+}
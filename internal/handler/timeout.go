@@ -0,0 +1,107 @@
+package handler
+
+import (
+    "bufio"
+    "context"
+    "log/slog"
+    "net"
+    "net/http"
+    "path"
+    "sort"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+)
+
+// timeoutWriteGrace is added to a request's budget when moving the
+// connection's write deadline, leaving time to send the timeout response.
+const timeoutWriteGrace = 5 * time.Second
+
+// TimeoutMiddleware cancels each request's context once its budget is spent,
+// so the service and repository calls made with it (pgx queries included)
+// abort instead of running on. The budget is the one for the first pattern
+// in routes, in sorted order, that matches the path, else budget. Patterns
+// are path.Match globs relative to the API version, e.g.
+// "/admin/books/*/enrich".
+//
+// A handler that gives up without responding, or never notices the deadline,
+// is answered with a 503 JSON error. The connection's write deadline is moved
+// to the budget too, so slow routes aren't cut off by the server's
+// WriteTimeout first.
+func TimeoutMiddleware(budget time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+    patterns := make([]string, 0, len(routes))
+    for p := range routes {
+        patterns = append(patterns, p)
+    }
+    sort.Strings(patterns)
+
+    budgetFor := func(p string) time.Duration {
+        for _, pattern := range patterns {
+            if ok, _ := path.Match(pattern, p); ok {
+                return routes[pattern]
+            }
+        }
+        return budget
+    }
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            d := budgetFor(routePath(r))
+            _ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + timeoutWriteGrace))
+
+            ctx, cancel := context.WithTimeout(r.Context(), d)
+            defer cancel()
+
+            tw := &startedWriter{ResponseWriter: w}
+            next.ServeHTTP(tw, r.WithContext(ctx))
+
+            if ctx.Err() == context.DeadlineExceeded && !tw.wrote {
+                slog.WarnContext(r.Context(), "request timed out", "path", r.URL.Path, "budget", d.String())
+                WriteError(r.Context(), w, http.StatusServiceUnavailable, "Request timed out")
+            }
+        })
+    }
+}
+
+// routePath is the request path below the router the middleware is mounted
+// on, e.g. "/books" for /v1/books, and the full path at the root router.
+func routePath(r *http.Request) string {
+    if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+        return rctx.RoutePath
+    }
+    return r.URL.Path
+}
+
+// startedWriter records whether the handler has started its response, after
+// which a middleware can no longer send its own.
+type startedWriter struct {
+    http.ResponseWriter
+    wrote bool
+}
+
+func (tw *startedWriter) WriteHeader(code int) {
+    tw.wrote = true
+    tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *startedWriter) Write(b []byte) (int, error) {
+    tw.wrote = true
+    return tw.ResponseWriter.Write(b)
+}
+
+// Flush passes through to the connection so streamed exports keep working.
+func (tw *startedWriter) Flush() {
+    tw.wrote = true
+    _ = http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+// Hijack passes through to the connection for WebSocket upgrades, after
+// which the middleware must not respond.
+func (tw *startedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    tw.wrote = true
+    return http.NewResponseController(tw.ResponseWriter).Hijack()
+}
+
+func (tw *startedWriter) Unwrap() http.ResponseWriter {
+    return tw.ResponseWriter
+}
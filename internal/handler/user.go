@@ -1,41 +1,50 @@
 package handler
 
 import (
-    "encoding/json"
-    "log"
+    "log/slog"
     "net/http"    
-    "strconv"
-    "strings"
-    "context"
 
     "github.com/go-chi/chi/v5"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
 )
 
 type UserHandler struct {
-    userSvc service.UserService
+    userSvc      service.UserService
+    emailChanges service.EmailChangeService
+    logger       *slog.Logger
 }
 
-func NewUserHandler(userSvc service.UserService) *UserHandler {
-    return &UserHandler{userSvc: userSvc}
+func NewUserHandler(userSvc service.UserService, emailChanges service.EmailChangeService, logger *slog.Logger) *UserHandler {
+    return &UserHandler{userSvc: userSvc, emailChanges: emailChanges, logger: logger}
 }
 
+// RegisterAdmin godoc
+// @Summary      Register a new admin
+// @Description  Create an account with the admin role. When a registration challenge
+// @Description  is configured, send its solution (see GET /auth/challenge).
+// @Tags         Auth
+// @Accept       json
+// @Param        request               body      model.RegisterRequest  true   "Registration data"
+// @Param        X-Challenge-Response  header    string                 false  "Solution to the registration challenge"
+// @Produce      json
+// @Success      201  {object}  model.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Router       /auth/admin-register [post]
 func (h *UserHandler) RegisterAdmin(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    var req model.RegisterRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.RegisterRequest](w, r)
+    if !ok {
         return
     }
 
     user, err := h.userSvc.RegisterAdmin(r.Context(), &req)
     if err != nil {
-        log.Printf("[%s] Admin registration failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to register admin")
+        logServiceError(r.Context(), h.logger, "admin registration failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to register admin")
         return
     }
 
@@ -45,73 +54,37 @@ if cwLogger != nil {
     _ = cwLogger.PutMetric(r.Context(), "AdminRegistered", 1, "Count")
 }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    _ = json.NewEncoder(w).Encode(user)
-    log.Printf("[%s] Admin registered: %s", requestID, user.Username)
+    respond.JSON(r.Context(), w, http.StatusCreated, user)
+    h.logger.InfoContext(r.Context(), "admin registered", "username", user.Username)
 }
 // Register godoc
 // @Summary      Register a new user
-// @Description  Create a new user account
+// @Description  Create a new user account. Signing up at a branch, through its
+// @Description  subdomain or the X-Branch header, scopes the account to that
+// @Description  branch; otherwise it is global. When a registration challenge
+// @Description  is configured, send its solution (see GET /auth/challenge).
+// @Description  While registration is invite-only, send an invite_code from an admin.
 // @Tags         Auth
 // @Accept       json
-// @Param        request  body      model.RegisterRequest  true  "Registration data"
+// @Param        request               body      model.RegisterRequest  true   "Registration data"
+// @Param        X-Challenge-Response  header    string                 false  "Solution to the registration challenge"
 // @Produce      json
 // @Success      201  {object}  model.RegisterResponse
 // @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
 // @Failure      409  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
 // @Router       /auth/register [post]
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    var req model.RegisterRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
-        return
-    }
-
-    // Validate input
-    errs := ValidationErrors{}
-    req.Username = strings.TrimSpace(req.Username)
-    req.Email = strings.TrimSpace(req.Email)
-    req.Password = strings.TrimSpace(req.Password)
-
-    if req.Username == "" {
-        errs["username"] = "username is required"
-    } else if len(req.Username) < 3 {
-        errs["username"] = "username must be at least 3 characters"
-    } else if len(req.Username) > 50 {
-        errs["username"] = "username must be at most 50 characters"
-    }
-
-    if req.Email == "" {
-        errs["email"] = "email is required"
-    } else if !isValidEmail(req.Email) {
-        errs["email"] = "invalid email format"
-    }
-
-    if req.Password == "" {
-        errs["password"] = "password is required"
-    } else if len(req.Password) < 8 {
-        errs["password"] = "password must be at least 8 characters"
-    }
-
-    if len(errs) > 0 {
-        log.Printf("[%s] Validation failed: %v", requestID, errs)
-        WriteValidationErrors(r.Context(), w, errs)
+    req, ok := Bind[model.RegisterRequest](w, r)
+    if !ok {
         return
     }
 
     user, err := h.userSvc.Register(r.Context(), &req)
     if err != nil {
-        if strings.Contains(err.Error(), "already exists") {
-            log.Printf("[%s] Registration failed: %v", requestID, err)
-            WriteError(r.Context(), w, http.StatusConflict, err.Error())
-            return
-        }
-        log.Printf("[%s] Registration failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to register user")
+        logServiceError(r.Context(), h.logger, "registration failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to register user")
         return
     }
 
@@ -119,12 +92,12 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
         ID:       user.ID,
         Username: user.Username,
         Email:    user.Email,
+        Role:     user.Role,
+        BranchID: user.BranchID,
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    _ = json.NewEncoder(w).Encode(resp)
-    log.Printf("[%s] User registered successfully: %s", requestID, user.ID)
+    respond.JSON(r.Context(), w, http.StatusCreated, resp)
+    h.logger.InfoContext(r.Context(), "user registered", "new_user_id", user.ID)
 }
 
 // GetProfile godoc
@@ -134,97 +107,231 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Security     BearerAuth
 // @Produce      json
 // @Success      200  {object}  model.User
+// @Header       200  {string}  ETag  "Current user version"
 // @Failure      401  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
 // @Router       /users/me [get]
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     userID := GetUserID(r.Context())
 
     if userID == "" {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
 
     user, err := h.userSvc.GetByID(r.Context(), userID)
     if err != nil {
-        log.Printf("[%s] User not found: %s", requestID, userID)
-        WriteError(r.Context(), w, http.StatusNotFound, "User not found")
+        logServiceError(r.Context(), h.logger, "get profile failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get profile")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(user)
-    log.Printf("[%s] User profile retrieved: %s", requestID, userID)
+    w.Header().Set("ETag", etag(user.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.DebugContext(r.Context(), "user profile retrieved")
 }
 
 // UpdateProfile godoc
-// @Summary      Update user profile
-// @Description  Update current user profile
+// @Summary      Change my email
+// @Description  Stage a change of the current user's email and email a confirmation link
+// @Description  to the new address. The email changes once the link is opened; until then
+// @Description  the profile keeps the old one. Asking again replaces the pending change.
+// @Description  The request must carry the version of the profile it was made from, either
+// @Description  as an If-Match ETag or as the version field.
 // @Tags         Users
 // @Security     BearerAuth
 // @Accept       json
-// @Param        request  body      model.UpdateUserRequest  true  "Update data"
+// @Param        If-Match  header    string                   false  "ETag from GET /users/me"
+// @Param        request   body      model.UpdateUserRequest  true   "Update data"
 // @Produce      json
-// @Success      200  {object}  model.User
+// @Success      202  {object}  model.PendingEmailChange
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
 // @Failure      409  {object}  ErrorResponse
+// @Failure      412  {object}  ErrorResponse
+// @Failure      428  {object}  ErrorResponse
 // @Router       /users/me [put]
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     userID := GetUserID(r.Context())
 
     if userID == "" {
-        log.Printf("[%s] Unauthorized", requestID)
+        h.logger.WarnContext(r.Context(), "unauthorized")
         WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
         return
     }
 
-    var req model.UpdateUserRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[%s] Invalid request: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid request body")
+    req, ok := Bind[model.UpdateUserRequest](w, r)
+    if !ok {
+        return
+    }
+
+    if req.Email == "" {
+        WriteError(r.Context(), w, http.StatusBadRequest, "No fields to update")
         return
     }
 
-    errs := ValidationErrors{}
-    if req.Email != "" && !isValidEmail(req.Email) {
-        errs["email"] = "invalid email format"
+    version, ok := h.expectedVersion(w, r, req.Version)
+    if !ok {
+        return
     }
 
-    if len(errs) > 0 {
-        WriteValidationErrors(r.Context(), w, errs)
+    pending, err := h.emailChanges.Request(r.Context(), userID, req.Email, version)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "email change request failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to update profile")
         return
     }
 
-    updates := map[string]interface{}{}
-    if req.Email != "" {
-        updates["email"] = req.Email
+    respond.JSON(r.Context(), w, http.StatusAccepted, pending)
+    h.logger.InfoContext(r.Context(), "email change requested")
+}
+
+// expectedVersion returns the user version a write is conditioned on, 0
+// for "If-Match: *". It answers the request itself and returns false when
+// the precondition is malformed or missing.
+func (h *UserHandler) expectedVersion(w http.ResponseWriter, r *http.Request, bodyVersion *int) (int, bool) {
+    version, ok, err := expectedVersion(r, bodyVersion)
+    if err != nil {
+        WriteError(r.Context(), w, http.StatusBadRequest, err.Error())
+        return 0, false
     }
+    if !ok {
+        WriteError(r.Context(), w, http.StatusPreconditionRequired, "If-Match header or version field is required")
+        return 0, false
+    }
+    return version, true
+}
 
-    if len(updates) == 0 {
-        WriteError(r.Context(), w, http.StatusBadRequest, "No fields to update")
+// ConfirmEmail godoc
+// @Summary      Confirm a new email address
+// @Description  Change the user's email to the address the token was sent to, and tell the
+// @Description  old address. The token comes from the link emailed by PUT /users/me and
+// @Description  works once, until it expires.
+// @Tags         Auth
+// @Param        token  query     string  true  "Token from the emailed link"
+// @Produce      json
+// @Success      200  {object}  model.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /auth/confirm-email [get]
+func (h *UserHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+    user, err := h.emailChanges.Confirm(r.Context(), r.URL.Query().Get("token"))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "email confirmation failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to confirm email")
         return
     }
 
-    user, err := h.userSvc.Update(r.Context(), userID, updates)
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.InfoContext(r.Context(), "email change confirmed", "user_id", user.ID)
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Change the current user's password. The current password is required
+// @Description  and the new one must satisfy the password policy.
+// @Tags         Users
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.ChangePasswordRequest  true  "Current and new password"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /users/me/change-password [post]
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+
+    if userID == "" {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.ChangePasswordRequest](w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.userSvc.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+        logServiceError(r.Context(), h.logger, "change password failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to change password")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    h.logger.InfoContext(r.Context(), "password changed")
+}
+
+// GetPreferences godoc
+// @Summary      Get notification preferences
+// @Description  How the current user gets due-date reminders: by email, to a webhook or not at all,
+// @Description  and how many hours before a loan falls due (0 for the library's default).
+// @Tags         Users
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.NotificationPreferences
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/preferences [get]
+func (h *UserHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+
+    if userID == "" {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    prefs, err := h.userSvc.GetPreferences(r.Context(), userID)
     if err != nil {
-        if strings.Contains(err.Error(), "already exists") {
-            log.Printf("[%s] Update failed: %v", requestID, err)
-            WriteError(r.Context(), w, http.StatusConflict, "Email already in use")
-            return
-        }
-        log.Printf("[%s] Update failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to update profile")
+        logServiceError(r.Context(), h.logger, "get preferences failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get preferences")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(user)
-    log.Printf("[%s] User profile updated: %s", requestID, userID)
+    respond.JSON(r.Context(), w, http.StatusOK, prefs)
 }
+
+// UpdatePreferences godoc
+// @Summary      Update notification preferences
+// @Description  Replace the current user's notification preferences. The webhook channel needs an
+// @Description  https webhook_url, which is sent each reminder as a JSON POST.
+// @Tags         Users
+// @Security     BearerAuth
+// @Accept       json
+// @Param        request  body  model.NotificationPreferencesRequest  true  "Preferences"
+// @Produce      json
+// @Success      200  {object}  model.NotificationPreferences
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me/preferences [put]
+func (h *UserHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+
+    if userID == "" {
+        h.logger.WarnContext(r.Context(), "unauthorized")
+        WriteError(r.Context(), w, http.StatusUnauthorized, "Unauthorized")
+        return
+    }
+
+    req, ok := Bind[model.NotificationPreferencesRequest](w, r)
+    if !ok {
+        return
+    }
+
+    prefs, err := h.userSvc.UpdatePreferences(r.Context(), userID, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "update preferences failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to update preferences")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, prefs)
+    h.logger.InfoContext(r.Context(), "notification preferences updated", "channel", prefs.Channel)
+}
+
 // ListUsers godoc
 // @Summary      List all users (admin)
 // @Description  Get all users in the system
@@ -232,39 +339,26 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 // @Security     BearerAuth
 // @Param        limit   query     int     false  "Items per page"  default(20)
 // @Param        offset  query     int     false  "Pagination offset"  default(0)
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor (overrides offset)"
 // @Produce      json
-// @Success      200  {array}   model.User
+// @Produce      xml
+// @Produce      text/csv
+// @Success      200  {object}  model.Page[model.User]
 // @Failure      401  {object}  ErrorResponse
 // @Failure      403  {object}  ErrorResponse
 // @Router       /admin/users [get]
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
-
-    limit := 20
-    offset := 0
-
-    if l := r.URL.Query().Get("limit"); l != "" {
-        if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-            limit = parsed
-        }
-    }
+    page := parsePageRequest(r)
 
-    if o := r.URL.Query().Get("offset"); o != "" {
-        if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-            offset = parsed
-        }
-    }
-
-    users, err := h.userSvc.List(r.Context(), limit, offset)
+    users, err := h.userSvc.List(r.Context(), page)
     if err != nil {
-        log.Printf("[%s] List users failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to list users")
+        logServiceError(r.Context(), h.logger, "list users failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to list users")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(users)
-    log.Printf("[%s] Listed %d users", requestID, len(users))
+    writeList(w, r, h.logger, listMedia(w, r), "users", users, userTable)
+    h.logger.DebugContext(r.Context(), "listed users", "count", len(users.Items), "total", users.Total)
 }
 
 // GetUser godoc
@@ -275,23 +369,138 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 // @Param        id   path  string  true  "User ID"
 // @Produce      json
 // @Success      200  {object}  model.User
+// @Header       200  {string}  ETag  "Current user version"
 // @Failure      401  {object}  ErrorResponse
 // @Failure      403  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
 // @Router       /admin/users/{id} [get]
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     id := chi.URLParam(r, "id")
 
     user, err := h.userSvc.GetByID(r.Context(), id)
     if err != nil {
-        log.Printf("[%s] User not found: %s", requestID, id)
-        WriteError(r.Context(), w, http.StatusNotFound, "User not found")
+        logServiceError(r.Context(), h.logger, "get user failed", err, "target_user_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to get user")
+        return
+    }
+
+    w.Header().Set("ETag", etag(user.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+}
+
+// UpdateUser godoc
+// @Summary      Update user (admin)
+// @Description  Change a user's email, role or status. Omitted fields are left alone.
+// @Description  Suspended users can't log in. The last active admin can't be demoted
+// @Description  or suspended. The request must carry the version being replaced, either
+// @Description  as an If-Match ETag or as the version field.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id        path    string                        true   "User ID"
+// @Param        If-Match  header  string                        false  "ETag from GET /admin/users/{id}"
+// @Param        request   body    model.AdminUpdateUserRequest  true   "Fields to change"
+// @Produce      json
+// @Success      200  {object}  model.User
+// @Header       200  {string}  ETag  "New user version"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      412  {object}  ErrorResponse
+// @Failure      428  {object}  ErrorResponse
+// @Router       /admin/users/{id} [put]
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    req, ok := Bind[model.AdminUpdateUserRequest](w, r)
+    if !ok {
+        return
+    }
+
+    version, ok := h.expectedVersion(w, r, req.Version)
+    if !ok {
+        return
+    }
+    req.Version = nil
+    if version > 0 {
+        req.Version = &version
+    }
+
+    user, err := h.userSvc.AdminUpdate(r.Context(), id, &req)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "admin update user failed", err, "target_user_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to update user")
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(user)
+    w.Header().Set("ETag", etag(user.Version))
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.InfoContext(r.Context(), "user updated by admin", "target_user_id", id)
+}
+
+// SuspendUser godoc
+// @Summary      Suspend user (admin)
+// @Description  Stop a user logging in or borrowing books until the given time, or until
+// @Description  they are unsuspended when until is omitted. Tokens they hold are revoked.
+// @Description  The last active admin can't be suspended.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id       path  string                    true  "User ID"
+// @Param        request  body  model.SuspendUserRequest  true  "End of the suspension"
+// @Produce      json
+// @Success      200  {object}  model.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /admin/users/{id}/suspend [post]
+func (h *UserHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    req, ok := Bind[model.SuspendUserRequest](w, r)
+    if !ok {
+        return
+    }
+
+    user, err := h.userSvc.Suspend(r.Context(), id, req.Until)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "suspend user failed", err, "target_user_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to suspend user")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.InfoContext(r.Context(), "user suspended", "target_user_id", id)
+}
+
+// UnsuspendUser godoc
+// @Summary      Unsuspend user (admin)
+// @Description  Lift a user's suspension. They log in again to get a new token.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Param        id   path  string  true  "User ID"
+// @Produce      json
+// @Success      200  {object}  model.User
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /admin/users/{id}/unsuspend [post]
+func (h *UserHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+
+    user, err := h.userSvc.Unsuspend(r.Context(), id)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "unsuspend user failed", err, "target_user_id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to unsuspend user")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.InfoContext(r.Context(), "user unsuspended", "target_user_id", id)
 }
 
 // DeleteUser godoc
@@ -303,28 +512,17 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 // @Success      204
 // @Failure      401  {object}  ErrorResponse
 // @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
 // @Router       /admin/users/{id} [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-    requestID := GetRequestID(r.Context())
     id := chi.URLParam(r, "id")
 
     if err := h.userSvc.Delete(r.Context(), id); err != nil {
-        log.Printf("[%s] Delete failed: %v", requestID, err)
-        WriteError(r.Context(), w, http.StatusInternalServerError, "Failed to delete user")
+        logServiceError(r.Context(), h.logger, "delete failed", err, "id", id)
+        WriteServiceError(r.Context(), w, err, "Failed to delete user")
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
-    log.Printf("[%s] User deleted: %s", requestID, id)
-}
-
-func isValidEmail(email string) bool {
-    return strings.Contains(email, "@") && strings.Contains(email, ".")
+    h.logger.InfoContext(r.Context(), "user deleted", "target_user_id", id)
 }
-func GetUserID(ctx context.Context) string {
-    userID, ok := ctx.Value(userIDKey).(string)
-    if !ok {
-        return ""
-    }
-    return userID
-}
\ No newline at end of file
@@ -0,0 +1,41 @@
+package handler
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+type UserStatsHandler struct {
+    svc    service.UserStatsService
+    logger *slog.Logger
+}
+
+func NewUserStatsHandler(svc service.UserStatsService, logger *slog.Logger) *UserStatsHandler {
+    return &UserStatsHandler{svc: svc, logger: logger}
+}
+
+// Get godoc
+// @Summary      My borrowing stats
+// @Description  How much the caller has borrowed and how often on time, their unpaid fines, their
+// @Description  most-read authors and categories, and a chart series of the loans started in each
+// @Description  of the last 12 months (UTC). Results are cached for up to USER_STATS_CACHE_TTL.
+// @Tags         Users
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  model.UserStats
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/me/stats [get]
+func (h *UserStatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+    stats, err := h.svc.Get(r.Context(), GetUserID(r.Context()))
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "get user stats failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to get stats")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, stats)
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "strconv"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/respond"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+)
+
+// maxUserImportRows is lower than maxImportRows because every user with a
+// temporary password costs a bcrypt hash, and the import has to finish
+// within its route timeout.
+const maxUserImportRows = 1000
+
+type UserImportHandler struct {
+    svc    service.UserImportService
+    logger *slog.Logger
+}
+
+func NewUserImportHandler(svc service.UserImportService, logger *slog.Logger) *UserImportHandler {
+    return &UserImportHandler{svc: svc, logger: logger}
+}
+
+// Import godoc
+// @Summary      Bulk import users
+// @Description  Onboard an existing patron database from a CSV (header: username,email and optionally role)
+// @Description  or JSON array. Send the file as the raw body with Content-Type text/csv or application/json,
+// @Description  or as multipart/form-data in a field named "file". With mode=password (the default) every
+// @Description  user gets a random temporary password; with mode=invite they get a link to choose their own,
+// @Description  and can't log in until they do. The password or link is returned per row, unless
+// @Description  send_email=true emails it to the user instead. Users are created at the caller's branch.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Accept       text/csv
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        mode        query     string  false  "password or invite"  Enums(password, invite)
+// @Param        send_email  query     bool    false  "Email each user their password or link"
+// @Success      200  {object}  model.UserImportReport
+// @Failure      400  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      415  {object}  ErrorResponse
+// @Router       /admin/users/import [post]
+func (h *UserImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+    opts := model.UserImportOptions{Mode: r.URL.Query().Get("mode")}
+    if v := r.URL.Query().Get("send_email"); v != "" {
+        send, err := strconv.ParseBool(v)
+        if err != nil {
+            WriteError(r.Context(), w, http.StatusBadRequest, "send_email must be true or false")
+            return
+        }
+        opts.SendEmail = send
+    }
+
+    r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+    body, format, err := importSource(r)
+    if err != nil {
+        h.logger.WarnContext(r.Context(), "user import rejected", "error", err)
+        writeImportSourceError(r, w, err)
+        return
+    }
+    defer body.Close()
+
+    var rows []model.ImportUserRow
+    switch format {
+    case "csv":
+        rows, err = parseUserCSV(body)
+    case "json":
+        dec := json.NewDecoder(body)
+        dec.DisallowUnknownFields()
+        err = dec.Decode(&rows)
+    }
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            WriteError(r.Context(), w, http.StatusRequestEntityTooLarge, "Import file too large")
+            return
+        }
+        h.logger.WarnContext(r.Context(), "user import parse failed", "error", err)
+        WriteError(r.Context(), w, http.StatusBadRequest, "Invalid import file: "+err.Error())
+        return
+    }
+
+    if len(rows) == 0 {
+        WriteError(r.Context(), w, http.StatusBadRequest, "Import file contains no rows")
+        return
+    }
+    if len(rows) > maxUserImportRows {
+        WriteError(r.Context(), w, http.StatusBadRequest, fmt.Sprintf("Import is limited to %d rows", maxUserImportRows))
+        return
+    }
+
+    report, err := h.svc.Import(r.Context(), rows, opts)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "user import failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to import users")
+        return
+    }
+
+    cwLogger := logger.GetLogger()
+    if cwLogger != nil {
+        _ = cwLogger.PutMetric(r.Context(), "UsersImported", float64(report.Created), "Count")
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, report)
+    h.logger.InfoContext(r.Context(), "imported users", "created", report.Created, "failed", report.Failed)
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept an invitation
+// @Description  Choose the password of an account an admin imported with mode=invite. The token
+// @Description  comes from the invite link and works once, until it expires.
+// @Tags         Auth
+// @Accept       json
+// @Param        request  body      model.AcceptInvitationRequest  true  "Token and new password"
+// @Produce      json
+// @Success      200  {object}  model.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /auth/accept-invitation [post]
+func (h *UserImportHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+    req, ok := Bind[model.AcceptInvitationRequest](w, r)
+    if !ok {
+        return
+    }
+
+    user, err := h.svc.AcceptInvitation(r.Context(), req.Token, req.Password)
+    if err != nil {
+        logServiceError(r.Context(), h.logger, "accept invitation failed", err)
+        WriteServiceError(r.Context(), w, err, "Failed to accept invitation")
+        return
+    }
+
+    respond.JSON(r.Context(), w, http.StatusOK, user)
+    h.logger.InfoContext(r.Context(), "invitation accepted", "user_id", user.ID)
+}
+
+// parseUserCSV reads rows keyed by a header line. Columns may appear in any
+// order; username and email are required, role optional.
+func parseUserCSV(r io.Reader) ([]model.ImportUserRow, error) {
+    cr := csv.NewReader(r)
+    cr.TrimLeadingSpace = true
+    cr.FieldsPerRecord = -1
+
+    field, err := readCSVHeader(cr, "username", "email")
+    if err == io.EOF {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var rows []model.ImportUserRow
+    for {
+        record, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        rows = append(rows, model.ImportUserRow{
+            Username: field(record, "username"),
+            Email:    field(record, "email"),
+            Role:     model.Role(field(record, "role")),
+        })
+    }
+    return rows, nil
+}
@@ -1,11 +1,21 @@
 package handler
 
-import (
-    "strings"
-)
+import "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
 
 type ValidationErrors map[string]string
 
-func trim(s string) string {
-    return strings.TrimSpace(s)
+// Validate normalizes v (a pointer to a request struct) when it supports it
+// and evaluates its validate tags, so transports other than JSON over HTTP
+// (e.g. gRPC) apply the same rules as Bind.
+func Validate(v interface{}) ValidationErrors {
+    if n, ok := v.(normalizer); ok {
+        n.Normalize()
+    }
+    return validateStruct(v)
+}
+
+// validateStruct evaluates the `validate` tags of v with
+// go-playground/validator. Errors are keyed by the field's JSON name.
+func validateStruct(v interface{}) ValidationErrors {
+    return ValidationErrors(validate.Struct(v))
 }
@@ -0,0 +1,248 @@
+// Package i18n translates the API's user-facing messages. Messages are
+// written in English throughout the code, which is the source language;
+// each locale's catalog maps them to its language. A catalog entry may use
+// {name} placeholders for the parts of a message that vary, e.g.
+// "{field} is required", and its translation places them where its
+// language wants them. Messages a catalog lacks stay in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source is the locale messages are written in.
+const Source = "en"
+
+// builtin holds the catalogs shipped with the API, one <locale>.json file
+// per locale.
+//
+//go:embed locales
+var builtin embed.FS
+
+// Builtin returns the shipped catalogs as a file system for NewCatalog.
+func Builtin() fs.FS {
+	sub, _ := fs.Sub(builtin, "locales")
+	return sub
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// Catalog holds a Translator for each locale it has messages for.
+type Catalog struct {
+	translators map[string]*Translator
+}
+
+// NewCatalog parses the <locale>.json files of fsys, each a JSON object from
+// English message to translation.
+func NewCatalog(fsys fs.FS) (*Catalog, error) {
+	c := &Catalog{translators: map[string]*Translator{Source: {locale: Source}}}
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("parse message catalog %s: %w", file, err)
+		}
+		locale := normalizeLocale(strings.TrimSuffix(path.Base(file), ".json"))
+		t, err := newTranslator(locale, messages)
+		if err != nil {
+			return nil, fmt.Errorf("message catalog %s: %w", file, err)
+		}
+		c.translators[locale] = t
+	}
+	return c, nil
+}
+
+// Locales lists the locales c translates into, the source included.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.translators))
+	for l := range c.translators {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Negotiate returns the Translator for the most preferred locale of an
+// Accept-Language header that c has, trying each locale ("pt-BR") before
+// its language ("pt"). It falls back to the source language.
+func (c *Catalog) Negotiate(acceptLanguage string) *Translator {
+	for _, locale := range preferred(acceptLanguage) {
+		lang, _, _ := strings.Cut(locale, "-")
+		for _, l := range []string{locale, lang} {
+			if t := c.translators[l]; t != nil {
+				return t
+			}
+		}
+	}
+	return c.translators[Source]
+}
+
+// preferred returns the locales of an Accept-Language header, most
+// preferred first, leaving out "*" and those it refuses with q=0.
+func preferred(header string) []string {
+	type choice struct {
+		locale string
+		q      float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		locale, params, _ := strings.Cut(part, ";")
+		locale = normalizeLocale(locale)
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			choices = append(choices, choice{locale, q})
+		}
+	}
+	slices.SortStableFunc(choices, func(a, b choice) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	locales := make([]string, len(choices))
+	for i, c := range choices {
+		locales[i] = c.locale
+	}
+	return locales
+}
+
+// Translator translates messages into one locale. The nil *Translator
+// leaves them in English.
+type Translator struct {
+	locale   string
+	messages map[string]string
+	// patterns are the entries with placeholders, most literal text first
+	// so the most specific wins.
+	patterns []pattern
+}
+
+type pattern struct {
+	re          *regexp.Regexp
+	names       []string
+	translation string
+	literal     int
+}
+
+func newTranslator(locale string, messages map[string]string) (*Translator, error) {
+	t := &Translator{locale: locale, messages: map[string]string{}}
+	for msg, translation := range messages {
+		names := placeholder.FindAllStringSubmatch(msg, -1)
+		if len(names) == 0 {
+			t.messages[msg] = translation
+			continue
+		}
+		p := pattern{translation: translation}
+		var re strings.Builder
+		re.WriteString("^")
+		last := 0
+		for _, loc := range placeholder.FindAllStringSubmatchIndex(msg, -1) {
+			re.WriteString(regexp.QuoteMeta(msg[last:loc[0]]))
+			p.literal += loc[0] - last
+			// A value never spans the "; " joining several messages.
+			re.WriteString(`((?:[^;]|;[^ ])+?)`)
+			p.names = append(p.names, msg[loc[2]:loc[3]])
+			last = loc[1]
+		}
+		re.WriteString(regexp.QuoteMeta(msg[last:]))
+		re.WriteString("$")
+		p.literal += len(msg) - last
+		p.re = regexp.MustCompile(re.String())
+
+		for _, m := range placeholder.FindAllStringSubmatch(translation, -1) {
+			if !slices.Contains(p.names, m[1]) {
+				return nil, fmt.Errorf("translation of %q uses unknown placeholder {%s}", msg, m[1])
+			}
+		}
+		t.patterns = append(t.patterns, p)
+	}
+	slices.SortFunc(t.patterns, func(a, b pattern) int {
+		if a.literal != b.literal {
+			return b.literal - a.literal
+		}
+		return strings.Compare(a.re.String(), b.re.String())
+	})
+	return t, nil
+}
+
+// Locale is the locale t translates into.
+func (t *Translator) Locale() string {
+	if t == nil {
+		return Source
+	}
+	return t.locale
+}
+
+// Translate returns msg in t's language. Several messages joined with
+// "; ", as validation failures are, are translated one by one.
+func (t *Translator) Translate(msg string) string {
+	if t == nil || t.locale == Source || msg == "" {
+		return msg
+	}
+	if translated, ok := t.translate(msg); ok {
+		return translated
+	}
+	parts := strings.Split(msg, "; ")
+	if len(parts) == 1 {
+		return msg
+	}
+	for i, part := range parts {
+		if translated, ok := t.translate(part); ok {
+			parts[i] = translated
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (t *Translator) translate(msg string) (string, bool) {
+	if translated, ok := t.messages[msg]; ok {
+		return translated, true
+	}
+	for _, p := range t.patterns {
+		m := p.re.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		values := make(map[string]string, len(p.names))
+		for i, name := range p.names {
+			values[name] = m[i+1]
+		}
+		return placeholder.ReplaceAllStringFunc(p.translation, func(s string) string {
+			return values[s[1:len(s)-1]]
+		}), true
+	}
+	return "", false
+}
+
+// normalizeLocale lowercases a locale and spells "pt_BR" as "pt-br".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_Negotiate(t *testing.T) {
+	c, err := NewCatalog(Builtin())
+	require.NoError(t, err)
+	require.Equal(t, []string{"en", "es"}, c.Locales())
+
+	for header, want := range map[string]string{
+		"":                        "en",
+		"es":                      "es",
+		"es-MX,es;q=0.9":          "es",
+		"fr, es;q=0.8, en;q=0.5":  "es",
+		"en;q=0.4, es_AR;q=0.7":   "es",
+		"es;q=0, en":              "en",
+		"*":                       "en",
+		"de-DE, fr;q=0.9":         "en",
+		"es;q=nonsense, en;q=0.1": "en",
+	} {
+		require.Equal(t, want, c.Negotiate(header).Locale(), header)
+	}
+}
+
+func TestTranslator_Translate(t *testing.T) {
+	c, err := NewCatalog(Builtin())
+	require.NoError(t, err)
+	es := c.Negotiate("es")
+
+	require.Equal(t, "libro no encontrado", es.Translate("book not found"))
+	require.Equal(t, "title debe tener como máximo 255 caracteres", es.Translate("title must be at most 255 characters"))
+	require.Equal(t, "borrow_days debe ser como máximo 365", es.Translate("borrow_days must be at most 365"))
+	require.Equal(t, "status debe ser uno de: ACTIVE, RETURNED", es.Translate("status must be one of: ACTIVE, RETURNED"))
+	require.Equal(t, "title es obligatorio; author es obligatorio", es.Translate("title is required; author is required"))
+	require.Equal(t, "El inicio de sesión caducó o se inició en otro lugar; inténtelo de nuevo",
+		es.Translate("Login expired or was started elsewhere; try again"))
+	require.Equal(t, "nothing like this is in the catalog", es.Translate("nothing like this is in the catalog"))
+	require.Equal(t, "book not found", c.Negotiate("en").Translate("book not found"))
+	require.Equal(t, "book not found", (*Translator)(nil).Translate("book not found"))
+}
+
+func TestNewCatalog_RejectsUnknownPlaceholders(t *testing.T) {
+	_, err := NewCatalog(fstest.MapFS{"fr.json": {Data: []byte(`{"{field} is required": "{champ} est obligatoire"}`)}})
+	require.ErrorContains(t, err, "{champ}")
+
+	_, err = NewCatalog(fstest.MapFS{"fr.json": {Data: []byte(`["not", "an", "object"]`)}})
+	require.Error(t, err)
+}
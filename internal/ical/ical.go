@@ -0,0 +1,114 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can
+// subscribe to.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prodID identifies the API as the feed's producer.
+const prodID = "-//digicert//library-api//EN"
+
+// Calendar is a feed of events named Name.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Event happens at At, taking no time. A non-zero Reminder raises an alarm
+// that long before it.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	At          time.Time
+	Reminder    time.Duration
+}
+
+// Write writes c as a VCALENDAR stamped with now.
+func Write(w io.Writer, c Calendar, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		fold(bw, name+":"+value)
+	}
+	stamp := now.UTC().Format(timeFormat)
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", stamp)
+		line("DTSTART", e.At.UTC().Format(timeFormat))
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Reminder > 0 {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", escape(e.Summary))
+			line("TRIGGER", "-"+duration(e.Reminder))
+			line("END", "VALARM")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// duration formats d, to the minute, as a DURATION value.
+func duration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes%(24*60) == 0 && minutes > 0 {
+		return "P" + strconv.Itoa(minutes/(24*60)) + "D"
+	}
+	out := "PT"
+	if h := minutes / 60; h > 0 {
+		out += strconv.Itoa(h) + "H"
+	}
+	if m := minutes % 60; m > 0 || minutes == 0 {
+		out += strconv.Itoa(m) + "M"
+	}
+	return out
+}
+
+// timeFormat is a DATE-TIME in UTC.
+const timeFormat = "20060102T150405Z"
+
+// escape escapes TEXT values.
+var escape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace
+
+// fold writes a content line, folding it into lines of at most 75 octets
+// without splitting a UTF-8 sequence, and ends it with CRLF.
+func fold(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !startsRune(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts.
+		limit = 74
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// startsRune reports whether b can begin a UTF-8 sequence.
+func startsRune(b byte) bool {
+	return b&0xC0 != 0x80
+}
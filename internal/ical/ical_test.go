@@ -0,0 +1,63 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	at := time.Date(2026, 3, 14, 17, 0, 0, 0, time.FixedZone("CET", 3600))
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := Write(&buf, Calendar{Name: "Library loans", Events: []Event{{
+		UID:         "b1@library",
+		Summary:     "Return Dune; Messiah, too",
+		Description: "Line one\nline two",
+		At:          at,
+		Reminder:    24 * time.Hour,
+	}}}, now)
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	require.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	for _, want := range []string{
+		"X-WR-CALNAME:Library loans\r\n",
+		"UID:b1@library\r\n",
+		"DTSTAMP:20260301T093000Z\r\n",
+		"DTSTART:20260314T160000Z\r\n",
+		`SUMMARY:Return Dune\; Messiah\, too` + "\r\n",
+		`DESCRIPTION:Line one\nline two` + "\r\n",
+		"TRIGGER:-P1D\r\n",
+	} {
+		require.Contains(t, out, want)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	require.Equal(t, "P2D", duration(48*time.Hour))
+	require.Equal(t, "PT1H30M", duration(90*time.Minute))
+	require.Equal(t, "PT3H", duration(3*time.Hour))
+	require.Equal(t, "PT0M", duration(time.Second))
+}
+
+func TestFold_KeepsLinesShortAndRunesWhole(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Calendar{Events: []Event{{UID: "x", Summary: strings.Repeat("é", 100), At: time.Unix(0, 0)}}}, time.Unix(0, 0))
+	require.NoError(t, err)
+
+	var unfolded strings.Builder
+	for _, l := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(l), 75)
+		if strings.HasPrefix(l, " ") {
+			unfolded.WriteString(l[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + l)
+	}
+	require.Contains(t, unfolded.String(), "SUMMARY:"+strings.Repeat("é", 100))
+}
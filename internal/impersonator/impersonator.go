@@ -0,0 +1,21 @@
+// Package impersonator carries the admin a request is made by when they
+// are signed in as another user for support, so the audit log can record
+// both who acted and who they acted as.
+package impersonator
+
+import "context"
+
+type contextKey struct{}
+
+// With returns a copy of ctx made by the admin adminID impersonating the
+// caller.
+func With(ctx context.Context, adminID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, adminID)
+}
+
+// From returns the ID of the admin impersonating the caller, or "" when
+// the caller is acting as themselves.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
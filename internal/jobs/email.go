@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+)
+
+// KindEmail is the kind of the jobs that deliver rendered emails.
+const KindEmail = "email"
+
+// Mailer is a notify.Provider that queues each message as a KindEmail job
+// rather than sending it, so slow or failing mail servers don't hold up
+// requests and failed sends are retried. Register EmailHandler on a worker
+// to deliver them.
+type Mailer struct {
+	queue *Queue
+}
+
+func NewMailer(q *Queue) *Mailer {
+	return &Mailer{queue: q}
+}
+
+func (m *Mailer) Send(ctx context.Context, msg notify.Message) error {
+	_, err := m.queue.Enqueue(ctx, KindEmail, msg)
+	return err
+}
+
+// EmailHandler delivers queued emails through p. A mail server's permanent
+// (5xx) rejection isn't retried.
+func EmailHandler(p notify.Provider) Handler {
+	return func(ctx context.Context, payload json.RawMessage) error {
+		var msg notify.Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return Permanent(err)
+		}
+		err := p.Send(ctx, msg)
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			return Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,243 @@
+// Package jobs runs slow or retryable work, such as delivering email, in the
+// background. Jobs are stored by a repo.JobRepo, so they survive restarts
+// and are shared by every instance's workers.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// Handler performs one attempt at a job of the kind it is registered for.
+// An error fails the attempt; wrap it with Permanent when retrying can't
+// help.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one a retry can't fix, so the job goes straight to
+// the dead letters.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Queue adds jobs for the workers to run.
+type Queue struct {
+	repo        repo.JobRepo
+	maxAttempts int
+}
+
+// NewQueue returns a queue whose jobs are attempted up to maxAttempts times.
+func NewQueue(r repo.JobRepo, maxAttempts int) *Queue {
+	return &Queue{repo: r, maxAttempts: maxAttempts}
+}
+
+// Enqueue adds a job of kind with payload marshalled to JSON. Inside a
+// transaction, the job is only run if the transaction commits.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any) (*model.Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s job: %w", kind, err)
+	}
+	j := &model.Job{Kind: kind, Payload: b, MaxAttempts: q.maxAttempts}
+	if err := q.repo.Enqueue(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Options tunes a Worker.
+type Options struct {
+	// Batch is how many jobs are claimed at a time.
+	Batch int
+	// Timeout bounds each attempt. A job still running after twice as long
+	// is taken to have lost its worker and is retried.
+	Timeout time.Duration
+	// Backoff is the wait before the first retry; it doubles with each
+	// further attempt, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Concurrency is how many claimed jobs run at once; below 2 they run
+	// in turn. A batch is finished before the next is claimed, so at most
+	// Concurrency goroutines run jobs however deep the queue gets.
+	Concurrency int
+	// Metrics, when set, records the queue's depth after each poll and
+	// each job's duration and outcome.
+	Metrics MetricRecorder
+}
+
+// MetricRecorder buffers metric observations; *logger.CloudWatchLogger is
+// one.
+type MetricRecorder interface {
+	RecordMetric(metricName string, value float64, unit string, dims map[string]string)
+}
+
+// Worker claims due jobs and runs them with the handlers registered for
+// their kinds.
+type Worker struct {
+	repo     repo.JobRepo
+	handlers map[string]Handler
+	opts     Options
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+func NewWorker(r repo.JobRepo, opts Options, logger *slog.Logger) *Worker {
+	return &Worker{repo: r, handlers: map[string]Handler{}, opts: opts, logger: logger, now: time.Now}
+}
+
+// Handle registers h for jobs of kind. It must be called before Run.
+func (w *Worker) Handle(kind string, h Handler) {
+	w.handlers[kind] = h
+}
+
+// Run works through the queue until ctx is cancelled, checking for due jobs
+// every poll interval, or straight away after claiming a full batch.
+func (w *Worker) Run(ctx context.Context, poll time.Duration) {
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			w.logger.ErrorContext(ctx, "job queue poll failed", "error", err)
+		}
+		if n == w.opts.Batch && err == nil {
+			continue
+		}
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce recovers jobs abandoned by dead workers, then claims one batch of
+// due jobs and runs them, Concurrency at a time. It returns how many it
+// claimed.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	now := w.now().UTC()
+	stale, err := w.repo.RecoverStale(ctx, now.Add(-2*w.opts.Timeout))
+	if err != nil {
+		return 0, err
+	}
+	if stale > 0 {
+		w.logger.WarnContext(ctx, "recovered jobs abandoned by a worker", "count", stale)
+	}
+
+	claimed, err := w.repo.Claim(ctx, now, w.opts.Batch)
+	if err != nil {
+		return 0, err
+	}
+	errs := make([]error, len(claimed))
+	slots := make(chan struct{}, max(w.opts.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range claimed {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			errs[i] = w.run(ctx, &claimed[i])
+		}()
+	}
+	wg.Wait()
+	w.recordDepth(ctx)
+	return len(claimed), errors.Join(errs...)
+}
+
+// recordDepth records how many jobs are waiting to run, due or not.
+func (w *Worker) recordDepth(ctx context.Context) {
+	if w.opts.Metrics == nil {
+		return
+	}
+	pending, err := w.repo.List(ctx, model.PageRequest{Limit: 1}, model.JobFilter{Status: model.JobPending})
+	if err != nil {
+		w.logger.WarnContext(ctx, "counting pending jobs failed", "error", err)
+		return
+	}
+	w.opts.Metrics.RecordMetric("JobQueueDepth", float64(pending.Total), "Count", nil)
+}
+
+// run attempts j and records the outcome. Only failures to record it are
+// returned; the job's own failure is stored on the job.
+func (w *Worker) run(ctx context.Context, j *model.Job) error {
+	log := w.logger.With("job_id", j.ID, "kind", j.Kind, "attempt", j.Attempts)
+	h, ok := w.handlers[j.Kind]
+	if !ok {
+		log.ErrorContext(ctx, "no handler for job kind")
+		return w.repo.Bury(ctx, j.ID, "no handler for job kind "+j.Kind)
+	}
+
+	start := time.Now()
+	err := w.attempt(ctx, h, j, log)
+	w.recordAttempt(j.Kind, time.Since(start), err)
+	if err == nil {
+		log.DebugContext(ctx, "job done")
+		return w.repo.Complete(ctx, j.ID)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: hand the job straight back to the queue rather
+		// than leaving it to be recovered as stale.
+		return w.repo.Retry(context.WithoutCancel(ctx), j.ID, "interrupted by shutdown", w.now().UTC())
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || j.Attempts >= j.MaxAttempts {
+		log.ErrorContext(ctx, "job failed for good", "error", err)
+		return w.repo.Bury(ctx, j.ID, err.Error())
+	}
+	retryAt := w.now().UTC().Add(w.backoff(j.Attempts))
+	log.WarnContext(ctx, "job failed, will retry", "error", err, "retry_at", retryAt)
+	return w.repo.Retry(ctx, j.ID, err.Error(), retryAt)
+}
+
+// attempt runs h on j within the attempt timeout. A panic in h fails the
+// attempt, like an error would, rather than the process.
+func (w *Worker) attempt(ctx context.Context, h Handler, j *model.Job, log *slog.Logger) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.ErrorContext(ctx, "job panicked", "panic", r, "stack", string(debug.Stack()))
+			if w.opts.Metrics != nil {
+				w.opts.Metrics.RecordMetric("JobPanics", 1, "Count", map[string]string{"Kind": j.Kind})
+			}
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, j.Payload)
+}
+
+// recordAttempt records how long an attempt at a job of kind took and
+// whether it failed.
+func (w *Worker) recordAttempt(kind string, took time.Duration, err error) {
+	if w.opts.Metrics == nil {
+		return
+	}
+	dims := map[string]string{"Kind": kind}
+	w.opts.Metrics.RecordMetric("JobDuration", float64(took.Milliseconds()), "Milliseconds", dims)
+	if err != nil {
+		w.opts.Metrics.RecordMetric("JobFailures", 1, "Count", dims)
+	}
+}
+
+// backoff is the wait after the given failed attempt.
+func (w *Worker) backoff(attempt int) time.Duration {
+	d := w.opts.Backoff
+	for i := 1; i < attempt && d < w.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, w.opts.MaxBackoff)
+}
@@ -0,0 +1,279 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = Options{Batch: 10, Timeout: time.Second, Backoff: time.Minute, MaxBackoff: 3 * time.Minute}
+
+func newTestQueue(maxAttempts int) (repo.JobRepo, *Queue, *Worker) {
+	r := repo.NewMemoryJobRepo(repo.NewMemoryStore())
+	return r, NewQueue(r, maxAttempts), NewWorker(r, testOptions, logger.Discard())
+}
+
+// advance moves the worker's clock forward by d.
+func advance(w *Worker, d time.Duration) {
+	now := w.now()
+	w.now = func() time.Time { return now.Add(d) }
+}
+
+func TestWorker_RetriesWithBackoffThenBuries(t *testing.T) {
+	ctx := context.Background()
+	r, q, w := newTestQueue(3)
+	calls := 0
+	w.Handle("flaky", func(ctx context.Context, payload json.RawMessage) error {
+		calls++
+		require.JSONEq(t, `{"n":1}`, string(payload))
+		return errors.New("upstream unavailable")
+	})
+	job, err := q.Enqueue(ctx, "flaky", map[string]int{"n": 1})
+	require.NoError(t, err)
+
+	start := time.Now()
+	n, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	got, err := r.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobPending, got.Status)
+	require.Equal(t, 1, got.Attempts)
+	require.Equal(t, "upstream unavailable", got.LastError)
+	require.WithinDuration(t, start.Add(time.Minute), got.RunAt, 5*time.Second)
+
+	n, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n, "the retry waits for its backoff")
+
+	// The second attempt waits twice as long; the third and last buries
+	// the job.
+	advance(w, time.Minute)
+	_, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	got, err = r.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobPending, got.Status)
+	require.Equal(t, 2, got.Attempts)
+	require.WithinDuration(t, start.Add(3*time.Minute), got.RunAt, 5*time.Second)
+
+	advance(w, 2*time.Minute)
+	_, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	got, err = r.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobDead, got.Status)
+	require.Equal(t, 3, calls)
+	require.NotNil(t, got.FinishedAt)
+
+	requeued, err := r.Requeue(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobPending, requeued.Status)
+	require.Zero(t, requeued.Attempts)
+}
+
+func TestWorker_PermanentErrorsAndUnknownKindsAreBuried(t *testing.T) {
+	ctx := context.Background()
+	r, q, w := newTestQueue(5)
+	w.Handle("bad", func(context.Context, json.RawMessage) error {
+		return Permanent(errors.New("malformed payload"))
+	})
+	bad, err := q.Enqueue(ctx, "bad", nil)
+	require.NoError(t, err)
+	unknown, err := q.Enqueue(ctx, "mystery", nil)
+	require.NoError(t, err)
+
+	n, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	for id, msg := range map[string]string{bad.ID: "malformed payload", unknown.ID: "no handler for job kind mystery"} {
+		got, err := r.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, model.JobDead, got.Status)
+		require.Equal(t, 1, got.Attempts)
+		require.Equal(t, msg, got.LastError)
+	}
+}
+
+func TestWorker_CompletesAndRecoversStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	r, q, w := newTestQueue(2)
+	done := 0
+	w.Handle("ok", func(context.Context, json.RawMessage) error {
+		done++
+		return nil
+	})
+	job, err := q.Enqueue(ctx, "ok", nil)
+	require.NoError(t, err)
+
+	// A worker that claimed the job and died holds it until it goes stale.
+	claimed, err := r.Claim(ctx, time.Now(), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	n, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	advance(w, 3*testOptions.Timeout)
+	n, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, 1, done)
+	got, err := r.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobDone, got.Status)
+	require.Equal(t, 2, got.Attempts)
+
+	_, err = r.Requeue(ctx, job.ID)
+	require.ErrorContains(t, err, "only dead jobs")
+}
+
+type fakeProvider struct {
+	err  error
+	sent []notify.Message
+}
+
+func (p *fakeProvider) Send(_ context.Context, m notify.Message) error {
+	p.sent = append(p.sent, m)
+	return p.err
+}
+
+func TestMailer_QueuesEmailsForEmailHandler(t *testing.T) {
+	ctx := context.Background()
+	r, q, w := newTestQueue(3)
+	p := &fakeProvider{}
+	w.Handle(KindEmail, EmailHandler(p))
+
+	msg := notify.Message{From: "library@example.com", To: "ada@example.com", Subject: "Hi", HTML: "<p>hi</p>"}
+	require.NoError(t, NewMailer(q).Send(ctx, msg))
+	require.Empty(t, p.sent, "nothing is sent until a worker runs the job")
+	_, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []notify.Message{msg}, p.sent)
+
+	// A permanent SMTP rejection isn't retried.
+	p.err = &textproto.Error{Code: 550, Msg: "no such user"}
+	require.NoError(t, NewMailer(q).Send(ctx, msg))
+	_, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	page, err := r.List(ctx, model.PageRequest{Limit: 10}, model.JobFilter{Status: model.JobDead, Kind: KindEmail})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	w := NewWorker(nil, testOptions, logger.Discard())
+	require.Equal(t, time.Minute, w.backoff(1))
+	require.Equal(t, 2*time.Minute, w.backoff(2))
+	require.Equal(t, 3*time.Minute, w.backoff(3))
+	require.Equal(t, 3*time.Minute, w.backoff(30))
+}
+
+type recordedMetrics struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (m *recordedMetrics) RecordMetric(name string, value float64, _ string, dims map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name+dims["Kind"]] += value
+}
+
+func TestWorker_RecoversPanicsAndRecordsMetrics(t *testing.T) {
+	ctx := context.Background()
+	r, q, w := newTestQueue(3)
+	metrics := &recordedMetrics{values: map[string]float64{}}
+	w.opts.Metrics = metrics
+	w.Handle("buggy", func(context.Context, json.RawMessage) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	job, err := q.Enqueue(ctx, "buggy", nil)
+	require.NoError(t, err)
+	_, err = q.Enqueue(ctx, "buggy", nil)
+	require.NoError(t, err)
+
+	n, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	got, err := r.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobPending, got.Status, "a panic fails the attempt like an error")
+	require.Contains(t, got.LastError, "panic: assignment to entry in nil map")
+
+	require.Equal(t, 2.0, metrics.values["JobPanicsbuggy"])
+	require.Equal(t, 2.0, metrics.values["JobFailuresbuggy"])
+	require.Equal(t, 2.0, metrics.values["JobQueueDepth"])
+}
+
+func TestWorker_BoundsConcurrency(t *testing.T) {
+	ctx := context.Background()
+	_, q, w := newTestQueue(3)
+	w.opts.Concurrency = 3
+	var running, peak atomic.Int32
+	w.Handle("slow", func(context.Context, json.RawMessage) error {
+		now := running.Add(1)
+		for {
+			was := peak.Load()
+			if now <= was || peak.CompareAndSwap(was, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	for range 10 {
+		_, err := q.Enqueue(ctx, "slow", nil)
+		require.NoError(t, err)
+	}
+
+	n, err := w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+	require.Equal(t, int32(3), peak.Load())
+}
+
+type fakePoster struct {
+	delivered []notify.Delivery
+}
+
+func (p *fakePoster) Deliver(_ context.Context, d notify.Delivery) error {
+	p.delivered = append(p.delivered, d)
+	return nil
+}
+
+func TestWebhookQueue_QueuesWebhooksForWebhookHandler(t *testing.T) {
+	ctx := context.Background()
+	_, q, w := newTestQueue(3)
+	p := &fakePoster{}
+	w.Handle(KindWebhook, WebhookHandler(p))
+
+	reg, err := notify.NewRegistry("en", notify.Builtin())
+	require.NoError(t, err)
+	n := notify.New(reg, &fakeProvider{}, "library@example.com")
+	n.SetPoster(NewWebhookQueue(q))
+	require.NoError(t, n.Post(ctx, "https://hooks.example.com/due", notify.TemplateDueReminder, notify.DueReminder{Title: "Dune"}))
+	require.Empty(t, p.delivered, "nothing is posted until a worker runs the job")
+
+	_, err = w.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Len(t, p.delivered, 1)
+	require.Equal(t, "https://hooks.example.com/due", p.delivered[0].URL)
+	var body notify.Webhook
+	require.NoError(t, json.Unmarshal(p.delivered[0].Body, &body))
+	require.Equal(t, notify.TemplateDueReminder, body.Type)
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+)
+
+// KindWebhook is the kind of the jobs that post webhooks to users.
+const KindWebhook = "webhook"
+
+// WebhookQueue is a notify.Poster that queues each webhook as a KindWebhook
+// job rather than posting it, so a storm of reminders is posted by the
+// workers at their own pace and failed posts are retried. Register
+// WebhookHandler on a worker to post them.
+type WebhookQueue struct {
+	queue *Queue
+}
+
+func NewWebhookQueue(q *Queue) *WebhookQueue {
+	return &WebhookQueue{queue: q}
+}
+
+func (wq *WebhookQueue) Deliver(ctx context.Context, d notify.Delivery) error {
+	_, err := wq.queue.Enqueue(ctx, KindWebhook, d)
+	return err
+}
+
+// WebhookHandler posts queued webhooks through p.
+func WebhookHandler(p notify.Poster) Handler {
+	return func(ctx context.Context, payload json.RawMessage) error {
+		var d notify.Delivery
+		if err := json.Unmarshal(payload, &d); err != nil {
+			return Permanent(err)
+		}
+		return p.Deliver(ctx, d)
+	}
+}
@@ -0,0 +1,112 @@
+package label
+
+import (
+	"bytes"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatterns_AreValidCode128Symbols(t *testing.T) {
+	seen := map[string]bool{}
+	for v, p := range patterns[:stop] {
+		sum, bars := 0, 0
+		for i, w := range p {
+			sum += int(w - '0')
+			if i%2 == 0 {
+				bars += int(w - '0')
+			}
+		}
+		require.Equal(t, 11, sum, "value %d", v)
+		require.Zero(t, bars%2, "value %d has an odd number of bar modules", v)
+		require.False(t, seen[p], "value %d repeats a pattern", v)
+		seen[p] = true
+	}
+}
+
+// decode reads modules back into symbol values.
+func decode(t *testing.T, modules []bool) []int {
+	t.Helper()
+	var values []int
+	for i := 0; i < len(modules); {
+		n := 11
+		if len(modules)-i == 13 {
+			n = 13
+		}
+		var p strings.Builder
+		for j := i; j < i+n; {
+			k := j
+			for k < i+n && modules[k] == modules[j] {
+				k++
+			}
+			p.WriteString(strconv.Itoa(k - j))
+			j = k
+		}
+		v := -1
+		for pv, pattern := range patterns {
+			if pattern == p.String() {
+				v = pv
+			}
+		}
+		require.NotEqual(t, -1, v, "unknown pattern %s", p.String())
+		values = append(values, v)
+		i += n
+	}
+	return values
+}
+
+func TestCode128(t *testing.T) {
+	modules, err := Code128("PJJ123C")
+	require.NoError(t, err)
+	// Start B, the seven characters, the weighted checksum and stop.
+	check := (104 + 48*1 + 42*2 + 42*3 + 17*4 + 18*5 + 19*6 + 35*7) % 103
+	require.Equal(t, []int{104, 48, 42, 42, 17, 18, 19, 35, check, stop}, decode(t, modules))
+	require.True(t, modules[0])
+	require.True(t, modules[len(modules)-1])
+
+	_, err = Code128("")
+	require.Error(t, err)
+	_, err = Code128("café")
+	require.Error(t, err)
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, PNG(&buf, Label{Code: "b7e5/2"}))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	modules, _ := Code128("b7e5/2")
+	require.Equal(t, (len(modules)+2*quietZone)*pngModule, img.Bounds().Dx())
+
+	y := img.Bounds().Dy() / 2
+	for i, bar := range modules {
+		r, _, _, _ := img.At((quietZone+i)*pngModule+1, y).RGBA()
+		require.Equal(t, bar, r == 0, "module %d", i)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, PDF(&buf, Label{Title: "Dune (1965)", Code: "b7e5/2"}))
+	doc := buf.String()
+	require.True(t, strings.HasPrefix(doc, "%PDF-1.4\n"))
+	require.True(t, strings.HasSuffix(doc, "%%EOF\n"))
+	require.Contains(t, doc, `(Dune \(1965\)) Tj`)
+	require.Contains(t, doc, "(b7e5/2) Tj")
+
+	// Every object starts where the cross-reference table says.
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(doc)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(m[1])
+	require.True(t, strings.HasPrefix(doc[xref:], "xref\n"))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(doc[xref:], -1)
+	require.Len(t, entries, 5)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(e[1])
+		require.True(t, strings.HasPrefix(doc[off:], strconv.Itoa(i+1)+" 0 obj\n"), "object %d", i+1)
+	}
+}
@@ -0,0 +1,131 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Label is what one label shows: the barcode of Code with Code written
+// under it, and a Title above.
+type Label struct {
+	Title string
+	Code  string
+}
+
+// PNG sizes: each module is pngModule pixels wide and bars pngHeight high.
+const (
+	pngModule = 3
+	pngHeight = 120
+)
+
+// PNG writes the label's barcode as a black and white image, without the
+// text, which stations print from the API's data when they need it.
+func PNG(w io.Writer, l Label) error {
+	modules, err := Code128(l.Code)
+	if err != nil {
+		return err
+	}
+	width := (len(modules) + 2*quietZone) * pngModule
+	img := image.NewPaletted(image.Rect(0, 0, width, pngHeight+2*quietZone*pngModule),
+		color.Palette{color.White, color.Black})
+	for i, bar := range modules {
+		if !bar {
+			continue
+		}
+		for x := (quietZone + i) * pngModule; x < (quietZone+i+1)*pngModule; x++ {
+			for y := quietZone * pngModule; y < quietZone*pngModule+pngHeight; y++ {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// PDF sizes, in points: modules are pdfModule wide, bars pdfBarHeight
+// high, and the page is pdfHeight high and as wide as the barcode needs.
+const (
+	pdfModule    = 0.75
+	pdfBarHeight = 45
+	pdfHeight    = 90
+	pdfFontSize  = 8
+	pdfMaxTitle  = 60
+)
+
+// PDF writes the label as a one-page PDF sized for a label printer, with
+// the title above the barcode and the code under it in Helvetica. Text
+// outside ASCII is printed as "?".
+func PDF(w io.Writer, l Label) error {
+	modules, err := Code128(l.Code)
+	if err != nil {
+		return err
+	}
+	width := float64(len(modules)+2*quietZone) * pdfModule
+	barY := float64(pdfHeight-pdfBarHeight) / 2
+
+	var content bytes.Buffer
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(modules) && modules[j] {
+			j++
+		}
+		fmt.Fprintf(&content, "%.2f %.2f %.2f %d re\n", float64(quietZone+i)*pdfModule, barY, float64(j-i)*pdfModule, pdfBarHeight)
+		i = j
+	}
+	content.WriteString("f\n")
+	title := l.Title
+	if len(title) > pdfMaxTitle {
+		title = title[:pdfMaxTitle-3] + "..."
+	}
+	fmt.Fprintf(&content, "BT /F1 %d Tf %.2f %.2f Td (%s) Tj ET\n", pdfFontSize, quietZone*pdfModule, barY+pdfBarHeight+6, pdfString(title))
+	fmt.Fprintf(&content, "BT /F1 %d Tf %.2f %.2f Td (%s) Tj ET\n", pdfFontSize, quietZone*pdfModule, barY-6-pdfFontSize, pdfString(l.Code))
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %d] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>", width, pdfHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err = w.Write(doc.Bytes())
+	return err
+}
+
+// pdfString escapes s for a PDF literal string, replacing what the
+// standard fonts can't show.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
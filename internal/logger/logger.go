@@ -6,21 +6,24 @@ import (
     "log"
     "os"
     "sync"
+    "time"
 
-    "github.com/aws/aws-sdk-go-v2/aws"
     "github.com/aws/aws-sdk-go-v2/config"
     "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
-    "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
 type CloudWatchLogger struct {
-    client     *cloudwatch.Client
-    logGroup   string
-    logStream  string
-    stdLogger  *log.Logger
-    mu         sync.Mutex
-    logBuffer  []string
-    isEnabled  bool
+    client    metricsAPI
+    logGroup  string
+    logStream string
+    stdLogger *log.Logger
+    mu        sync.Mutex
+    logLines  int
+    isEnabled bool
+    metrics   *metricBuffer
+    stop      chan struct{}
+    done      chan struct{}
+    closeOnce sync.Once
 }
 
 func NewCloudWatchLogger(logGroup, logStream string, enabled bool) (*CloudWatchLogger, error) {
@@ -36,93 +39,119 @@ func NewCloudWatchLogger(logGroup, logStream string, enabled bool) (*CloudWatchL
         return nil, fmt.Errorf("failed to load AWS config: %w", err)
     }
 
-    client := cloudwatch.NewFromConfig(cfg)
+    cwLogger := newEnabledLogger(cloudwatch.NewFromConfig(cfg), DefaultFlushInterval)
+    cwLogger.logGroup = logGroup
+    cwLogger.logStream = logStream
 
-    cwLogger := &CloudWatchLogger{
+    log.SetOutput(cwLogger)
+
+    return cwLogger, nil
+}
+
+// newEnabledLogger builds a publishing logger and starts its flush loop.
+func newEnabledLogger(client metricsAPI, interval time.Duration) *CloudWatchLogger {
+    l := &CloudWatchLogger{
         client:    client,
-        logGroup:  logGroup,
-        logStream: logStream,
         stdLogger: log.New(os.Stdout, "", log.LstdFlags),
-        logBuffer: make([]string, 0, 100),
         isEnabled: true,
+        metrics:   newMetricBuffer(),
+        stop:      make(chan struct{}),
+        done:      make(chan struct{}),
     }
+    go l.run(interval)
+    return l
+}
 
-    log.SetOutput(cwLogger)
-
-    return cwLogger, nil
+// run flushes buffered metrics on every tick until Close is called.
+func (l *CloudWatchLogger) run(interval time.Duration) {
+    defer close(l.done)
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            ctx, cancel := context.WithTimeout(context.Background(), interval)
+            if err := l.Flush(ctx); err != nil {
+                l.stdLogger.Printf("CloudWatch metric flush failed: %v", err)
+            }
+            cancel()
+        case <-l.stop:
+            return
+        }
+    }
 }
 
 func (l *CloudWatchLogger) Write(p []byte) (n int, err error) {
-    message := string(p)
-
-    // Always write to stdout
-    l.stdLogger.Print(message)
+    // Always write to stdout; lines arrive already formatted (JSON from slog).
+    if _, err := os.Stdout.Write(p); err != nil {
+        return 0, err
+    }
 
     if l.isEnabled {
         l.mu.Lock()
-        l.logBuffer = append(l.logBuffer, message)
+        l.logLines++
         l.mu.Unlock()
     }
 
     return len(p), nil
 }
 
-// Flush sends buffered logs to CloudWatch
+// Flush publishes everything aggregated since the last flush, including the
+// number of log lines written, in as few PutMetricData calls as possible.
 func (l *CloudWatchLogger) Flush(ctx context.Context) error {
     if !l.isEnabled {
         return nil
     }
 
     l.mu.Lock()
-    if len(l.logBuffer) == 0 {
-        l.mu.Unlock()
-        return nil
-    }
-
-    logs := l.logBuffer
-    l.logBuffer = make([]string, 0, 100)
+    lines := l.logLines
+    l.logLines = 0
     l.mu.Unlock()
+    if lines > 0 {
+        l.metrics.add("LogLines", float64(lines), "Count", nil)
+    }
 
-    // Send metric to CloudWatch about log count
-    _, err := l.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
-        Namespace: aws.String("LibraryAPI"),
-        MetricData: []types.MetricDatum{
-            {
-                MetricName: aws.String("LogLines"),
-                Value:      aws.Float64(float64(len(logs))),
-                Unit:       types.StandardUnitCount,
-            },
-        },
-    })
-
-    return err
+    datums := l.metrics.drain(time.Now().UTC())
+    if len(datums) == 0 {
+        return nil
+    }
+    return publish(ctx, l.client, datums)
 }
 
-// PutMetric sends a custom metric to CloudWatch
+// PutMetric records a custom metric. It only buffers the value; the metric
+// reaches CloudWatch on the next flush.
 func (l *CloudWatchLogger) PutMetric(ctx context.Context, metricName string, value float64, unit string) error {
+    l.RecordMetric(metricName, value, unit, nil)
+    return nil
+}
+
+// RecordMetric buffers a metric observation with the given dimensions.
+// Observations sharing a name, unit and dimensions are aggregated into a
+// single statistic set per flush.
+func (l *CloudWatchLogger) RecordMetric(metricName string, value float64, unit string, dims map[string]string) {
     if !l.isEnabled {
-        return nil
+        return
     }
-
-    _, err := l.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
-        Namespace: aws.String("LibraryAPI"),
-        MetricData: []types.MetricDatum{
-            {
-                MetricName: aws.String(metricName),
-                Value:      aws.Float64(value),
-                Unit:       types.StandardUnit(unit),
-            },
-        },
-    })
-    return err
+    l.metrics.add(metricName, value, unit, dims)
 }
 
-// Close closes the CloudWatch client
+// Close stops the flush loop and publishes whatever is still buffered.
 func (l *CloudWatchLogger) Close() error {
     if !l.isEnabled {
         return nil
     }
-    return nil
+
+    var err error
+    l.closeOnce.Do(func() {
+        close(l.stop)
+        <-l.done
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+        err = l.Flush(ctx)
+    })
+    return err
 }
 
 var globalLogger *CloudWatchLogger
@@ -146,4 +175,4 @@ func GetLogger() *CloudWatchLogger {
         }
     }
     return globalLogger
-}
\ No newline at end of file
+}
@@ -0,0 +1,156 @@
+package logger
+
+import (
+    "context"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+    "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+    // metricsNamespace is the CloudWatch namespace all metrics are published under.
+    metricsNamespace = "LibraryAPI"
+
+    // maxDatumsPerRequest is the PutMetricData limit on datums per call.
+    maxDatumsPerRequest = 1000
+
+    // DefaultFlushInterval is how often buffered metrics are published.
+    DefaultFlushInterval = 60 * time.Second
+)
+
+// metricsAPI is the subset of the CloudWatch client used for publishing.
+type metricsAPI interface {
+    PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// seriesKey identifies one aggregated series: a metric name, unit and
+// canonical dimension set.
+type seriesKey struct {
+    name string
+    unit string
+    dims string
+}
+
+// series accumulates the observations of one seriesKey between flushes.
+type series struct {
+    dims  []types.Dimension
+    count float64
+    sum   float64
+    min   float64
+    max   float64
+}
+
+// metricBuffer aggregates observations in memory so a request only pays for
+// a map update; the network round trip happens on flush.
+type metricBuffer struct {
+    mu     sync.Mutex
+    series map[seriesKey]*series
+}
+
+func newMetricBuffer() *metricBuffer {
+    return &metricBuffer{series: make(map[seriesKey]*series)}
+}
+
+func (b *metricBuffer) add(name string, value float64, unit string, dims map[string]string) {
+    key := seriesKey{name: name, unit: unit, dims: canonicalDims(dims)}
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    s, ok := b.series[key]
+    if !ok {
+        s = &series{dims: toDimensions(dims), min: value, max: value}
+        b.series[key] = s
+    }
+    s.count++
+    s.sum += value
+    if value < s.min {
+        s.min = value
+    }
+    if value > s.max {
+        s.max = value
+    }
+}
+
+// drain returns one datum per series and resets the buffer.
+func (b *metricBuffer) drain(ts time.Time) []types.MetricDatum {
+    b.mu.Lock()
+    pending := b.series
+    b.series = make(map[seriesKey]*series)
+    b.mu.Unlock()
+
+    datums := make([]types.MetricDatum, 0, len(pending))
+    for key, s := range pending {
+        datums = append(datums, types.MetricDatum{
+            MetricName: aws.String(key.name),
+            Unit:       types.StandardUnit(key.unit),
+            Dimensions: s.dims,
+            Timestamp:  aws.Time(ts),
+            StatisticValues: &types.StatisticSet{
+                SampleCount: aws.Float64(s.count),
+                Sum:         aws.Float64(s.sum),
+                Minimum:     aws.Float64(s.min),
+                Maximum:     aws.Float64(s.max),
+            },
+        })
+    }
+    return datums
+}
+
+// publish sends datums in PutMetricData batches of at most maxDatumsPerRequest.
+// All batches are attempted; the first error is returned.
+func publish(ctx context.Context, client metricsAPI, datums []types.MetricDatum) error {
+    var firstErr error
+    for start := 0; start < len(datums); start += maxDatumsPerRequest {
+        end := start + maxDatumsPerRequest
+        if end > len(datums) {
+            end = len(datums)
+        }
+        _, err := client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+            Namespace:  aws.String(metricsNamespace),
+            MetricData: datums[start:end],
+        })
+        if err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return firstErr
+}
+
+func canonicalDims(dims map[string]string) string {
+    if len(dims) == 0 {
+        return ""
+    }
+    keys := make([]string, 0, len(dims))
+    for k := range dims {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    var sb strings.Builder
+    for i, k := range keys {
+        if i > 0 {
+            sb.WriteByte(',')
+        }
+        sb.WriteString(k)
+        sb.WriteByte('=')
+        sb.WriteString(dims[k])
+    }
+    return sb.String()
+}
+
+func toDimensions(dims map[string]string) []types.Dimension {
+    if len(dims) == 0 {
+        return nil
+    }
+    out := make([]types.Dimension, 0, len(dims))
+    for k, v := range dims {
+        out = append(out, types.Dimension{Name: aws.String(k), Value: aws.String(v)})
+    }
+    sort.Slice(out, func(i, j int) bool { return *out[i].Name < *out[j].Name })
+    return out
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+    "github.com/stretchr/testify/require"
+)
+
+type fakeMetricsClient struct {
+    mu    sync.Mutex
+    calls []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeMetricsClient) PutMetricData(_ context.Context, in *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.calls = append(f.calls, in)
+    return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestRecordMetric_AggregatesSameSeries(t *testing.T) {
+    client := &fakeMetricsClient{}
+    l := newEnabledLogger(client, time.Hour)
+    defer l.Close()
+
+    dims := map[string]string{"Route": "/books", "StatusClass": "2xx"}
+    l.RecordMetric("Latency", 10, "Milliseconds", dims)
+    l.RecordMetric("Latency", 30, "Milliseconds", dims)
+    l.RecordMetric("Latency", 5, "Milliseconds", map[string]string{"Route": "/books", "StatusClass": "5xx"})
+
+    require.NoError(t, l.Flush(context.Background()))
+    require.Len(t, client.calls, 1)
+    require.Len(t, client.calls[0].MetricData, 2)
+
+    for _, d := range client.calls[0].MetricData {
+        if *d.Dimensions[1].Value == "2xx" {
+            require.Equal(t, 2.0, *d.StatisticValues.SampleCount)
+            require.Equal(t, 40.0, *d.StatisticValues.Sum)
+            require.Equal(t, 10.0, *d.StatisticValues.Minimum)
+            require.Equal(t, 30.0, *d.StatisticValues.Maximum)
+        }
+    }
+
+    // Nothing left to send after a flush.
+    require.NoError(t, l.Flush(context.Background()))
+    require.Len(t, client.calls, 1)
+}
+
+func TestFlush_SplitsIntoBatches(t *testing.T) {
+    client := &fakeMetricsClient{}
+    l := newEnabledLogger(client, time.Hour)
+    defer l.Close()
+
+    for i := 0; i < 1500; i++ {
+        l.RecordMetric("RequestCount", 1, "Count", map[string]string{"Route": fmt.Sprintf("/r/%d", i)})
+    }
+
+    require.NoError(t, l.Flush(context.Background()))
+    require.Len(t, client.calls, 2)
+    require.Len(t, client.calls[0].MetricData, 1000)
+    require.Len(t, client.calls[1].MetricData, 500)
+}
+
+func TestClose_FlushesPending(t *testing.T) {
+    client := &fakeMetricsClient{}
+    l := newEnabledLogger(client, time.Hour)
+
+    l.RecordMetric("BookCreated", 1, "Count", nil)
+    require.NoError(t, l.Close())
+    require.Len(t, client.calls, 1)
+}
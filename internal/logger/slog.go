@@ -0,0 +1,73 @@
+package logger
+
+import (
+    "context"
+    "io"
+    "log/slog"
+    "sync"
+)
+
+// NewJSON returns a slog logger that writes JSON lines to w. Records logged
+// with a context also carry any attributes attached via WithAttrs/AddAttrs,
+// so request_id and user_id show up without every call site passing them.
+func NewJSON(w io.Writer, level slog.Leveler) *slog.Logger {
+    return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+    return slog.New(slog.DiscardHandler)
+}
+
+type attrsKey struct{}
+
+// attrSet is shared by every context derived from the one WithAttrs returned,
+// which lets inner middleware (e.g. auth) enrich the outer access log.
+type attrSet struct {
+    mu    sync.Mutex
+    attrs []any
+}
+
+// WithAttrs returns a context carrying args (slog key/value pairs) on top of
+// any attributes already attached to ctx.
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+    set := &attrSet{}
+    if parent, ok := ctx.Value(attrsKey{}).(*attrSet); ok {
+        parent.mu.Lock()
+        set.attrs = append(set.attrs, parent.attrs...)
+        parent.mu.Unlock()
+    }
+    set.attrs = append(set.attrs, args...)
+    return context.WithValue(ctx, attrsKey{}, set)
+}
+
+// AddAttrs appends args to the attributes already attached to ctx. It is a
+// no-op when ctx was not prepared with WithAttrs.
+func AddAttrs(ctx context.Context, args ...any) {
+    if set, ok := ctx.Value(attrsKey{}).(*attrSet); ok {
+        set.mu.Lock()
+        set.attrs = append(set.attrs, args...)
+        set.mu.Unlock()
+    }
+}
+
+type contextHandler struct {
+    slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+    if set, ok := ctx.Value(attrsKey{}).(*attrSet); ok {
+        set.mu.Lock()
+        r.Add(set.attrs...)
+        set.mu.Unlock()
+    }
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+    return contextHandler{h.Handler.WithGroup(name)}
+}
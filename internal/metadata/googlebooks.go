@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const googleBooksURL = "https://www.googleapis.com/books/v1"
+
+// GoogleBooks looks books up with the Google Books volumes API.
+type GoogleBooks struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewGoogleBooks returns a provider backed by Google Books. apiKey is
+// optional; without one requests share Google's anonymous quota.
+func NewGoogleBooks(client *http.Client, apiKey string) *GoogleBooks {
+	return &GoogleBooks{client: client, baseURL: googleBooksURL, apiKey: apiKey}
+}
+
+type googleVolumes struct {
+	Items []struct {
+		VolumeInfo struct {
+			Title         string   `json:"title"`
+			Authors       []string `json:"authors"`
+			PublishedDate string   `json:"publishedDate"`
+			ImageLinks    struct {
+				Thumbnail string `json:"thumbnail"`
+			} `json:"imageLinks"`
+		} `json:"volumeInfo"`
+	} `json:"items"`
+}
+
+func (g *GoogleBooks) LookupISBN(ctx context.Context, isbn string) (*Book, error) {
+	q := url.Values{"q": {"isbn:" + NormalizeISBN(isbn)}}
+	if g.apiKey != "" {
+		q.Set("key", g.apiKey)
+	}
+
+	var found googleVolumes
+	if err := getJSON(ctx, g.client, "googlebooks", g.baseURL+"/volumes?"+q.Encode(), &found); err != nil {
+		return nil, err
+	}
+	if len(found.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	info := found.Items[0].VolumeInfo
+	return &Book{
+		Title:         info.Title,
+		Author:        strings.Join(info.Authors, ", "),
+		PublishedYear: parseYear(info.PublishedDate),
+		// Google still hands out plain-http image links.
+		CoverURL: strings.Replace(info.ImageLinks.Thumbnail, "http://", "https://", 1),
+	}, nil
+}
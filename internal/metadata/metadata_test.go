@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenLibrary_LookupISBN(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("bibkeys") != "ISBN:9780441013593" {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ISBN:9780441013593": {"title": "Dune", "publish_date": "August 2005",
+			"authors": [{"name": "Frank Herbert"}], "cover": {"medium": "https://covers.example/m.jpg"}}}`))
+	}))
+	defer srv.Close()
+
+	ol := NewOpenLibrary(srv.Client())
+	ol.baseURL = srv.URL
+
+	b, err := ol.LookupISBN(context.Background(), "978-0-441-01359-3")
+	require.NoError(t, err)
+	require.Equal(t, &Book{Title: "Dune", Author: "Frank Herbert", PublishedYear: 2005, CoverURL: "https://covers.example/m.jpg"}, b)
+
+	_, err = ol.LookupISBN(context.Background(), "0000000000")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGoogleBooks_LookupISBN(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "isbn:9780441013593" {
+			_, _ = w.Write([]byte(`{"totalItems": 0}`))
+			return
+		}
+		require.Equal(t, "k", r.URL.Query().Get("key"))
+		_, _ = w.Write([]byte(`{"items": [{"volumeInfo": {"title": "Dune", "authors": ["Frank Herbert"],
+			"publishedDate": "2005-08-02", "imageLinks": {"thumbnail": "http://books.example/t.jpg"}}}]}`))
+	}))
+	defer srv.Close()
+
+	gb := NewGoogleBooks(srv.Client(), "k")
+	gb.baseURL = srv.URL
+
+	b, err := gb.LookupISBN(context.Background(), "9780441013593")
+	require.NoError(t, err)
+	require.Equal(t, &Book{Title: "Dune", Author: "Frank Herbert", PublishedYear: 2005, CoverURL: "https://books.example/t.jpg"}, b)
+
+	_, err = gb.LookupISBN(context.Background(), "0000000000")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+type flakyProvider struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyProvider) LookupISBN(context.Context, string) (*Book, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &Book{Title: "Dune"}, nil
+}
+
+func TestWithRetry(t *testing.T) {
+	flaky := &flakyProvider{errs: []error{&StatusError{Code: 503}, errors.New("connection reset")}}
+	b, err := WithRetry(flaky, 3, time.Millisecond).LookupISBN(context.Background(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "Dune", b.Title)
+	require.Equal(t, 3, flaky.calls)
+
+	// Answers that won't change on retry are returned straight away.
+	for _, permanent := range []error{ErrNotFound, &StatusError{Code: 400}} {
+		p := &flakyProvider{errs: []error{permanent}}
+		_, err := WithRetry(p, 3, time.Millisecond).LookupISBN(context.Background(), "1")
+		require.ErrorIs(t, err, permanent)
+		require.Equal(t, 1, p.calls)
+	}
+
+	p := &flakyProvider{errs: []error{&StatusError{Code: 500}, &StatusError{Code: 500}}}
+	_, err = WithRetry(p, 2, time.Millisecond).LookupISBN(context.Background(), "1")
+	require.Error(t, err)
+	require.Equal(t, 2, p.calls)
+}
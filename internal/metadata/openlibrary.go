@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const openLibraryURL = "https://openlibrary.org"
+
+// OpenLibrary looks books up with the Open Library Books API.
+type OpenLibrary struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewOpenLibrary returns a provider backed by openlibrary.org. The client's
+// Timeout bounds each request.
+func NewOpenLibrary(client *http.Client) *OpenLibrary {
+	return &OpenLibrary{client: client, baseURL: openLibraryURL}
+}
+
+type openLibraryBook struct {
+	Title       string `json:"title"`
+	PublishDate string `json:"publish_date"`
+	Authors     []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Cover struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+func (o *OpenLibrary) LookupISBN(ctx context.Context, isbn string) (*Book, error) {
+	key := "ISBN:" + NormalizeISBN(isbn)
+	q := url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}
+
+	var found map[string]openLibraryBook
+	if err := getJSON(ctx, o.client, "openlibrary", o.baseURL+"/api/books?"+q.Encode(), &found); err != nil {
+		return nil, err
+	}
+	rec, ok := found[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	names := make([]string, 0, len(rec.Authors))
+	for _, a := range rec.Authors {
+		names = append(names, a.Name)
+	}
+	cover := rec.Cover.Large
+	if cover == "" {
+		cover = rec.Cover.Medium
+	}
+	return &Book{
+		Title:         rec.Title,
+		Author:        strings.Join(names, ", "),
+		PublishedYear: parseYear(rec.PublishDate),
+		CoverURL:      cover,
+	}, nil
+}
+
+// getJSON issues a GET and decodes a 200 response into dst. Other statuses
+// are returned as *StatusError, except 404 which is ErrNotFound.
+func getJSON(ctx context.Context, client *http.Client, provider, rawURL string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return &StatusError{Provider: provider, Code: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("decode %s response: %w", provider, err)
+	}
+	return nil
+}
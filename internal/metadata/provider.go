@@ -0,0 +1,101 @@
+// Package metadata looks up bibliographic data for a book by ISBN from an
+// external catalog (Open Library or Google Books).
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound means the provider has no record for the ISBN.
+var ErrNotFound = errors.New("no metadata found for ISBN")
+
+// Book is the metadata a provider returned. Fields it doesn't know are empty.
+type Book struct {
+	Title         string
+	Author        string
+	PublishedYear int
+	CoverURL      string
+}
+
+// Provider is a metadata source. Implementations return ErrNotFound when the
+// ISBN is unknown and a *StatusError for unexpected HTTP responses.
+type Provider interface {
+	LookupISBN(ctx context.Context, isbn string) (*Book, error)
+}
+
+// StatusError is an unexpected HTTP status from a provider.
+type StatusError struct {
+	Provider string
+	Code     int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d", e.Provider, e.Code)
+}
+
+// NormalizeISBN strips the hyphens and spaces people write ISBNs with.
+func NormalizeISBN(isbn string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn))
+}
+
+// WithRetry retries failed lookups up to attempts times in total, doubling
+// backoff between tries. Not-found answers, client errors and a cancelled
+// context are returned immediately.
+func WithRetry(p Provider, attempts int, backoff time.Duration) Provider {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &retryProvider{next: p, attempts: attempts, backoff: backoff}
+}
+
+type retryProvider struct {
+	next     Provider
+	attempts int
+	backoff  time.Duration
+}
+
+func (r *retryProvider) LookupISBN(ctx context.Context, isbn string) (*Book, error) {
+	wait := r.backoff
+	for attempt := 1; ; attempt++ {
+		b, err := r.next.LookupISBN(ctx, isbn)
+		if err == nil || attempt == r.attempts || !retryable(err) {
+			return b, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == 429 || status.Code >= 500
+	}
+	return true
+}
+
+var yearPattern = regexp.MustCompile(`\b(\d{4})\b`)
+
+// parseYear extracts the year from free-form dates such as "1965",
+// "June 1965" or "1965-08-01". It returns 0 when there is none.
+func parseYear(date string) int {
+	m := yearPattern.FindStringSubmatch(date)
+	if m == nil {
+		return 0
+	}
+	year, _ := strconv.Atoi(m[1])
+	return year
+}
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS total_copies INT NOT NULL DEFAULT 1 CHECK (total_copies >= 0);
+
+-- Availability is computed by counting bookings still out per book.
+CREATE INDEX IF NOT EXISTS idx_bookings_book_status ON bookings(book_id, status);
@@ -0,0 +1,8 @@
+-- Failed logins per key ("user:<username>" or "ip:<address>") within the
+-- current counting window, and the lockout they triggered, if any.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    window_start TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
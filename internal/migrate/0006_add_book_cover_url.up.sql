@@ -0,0 +1,2 @@
+-- Filled in from the ISBN metadata provider; empty when unknown.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_url TEXT NOT NULL DEFAULT '';
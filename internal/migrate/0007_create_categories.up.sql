@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS categories (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Names are matched case-insensitively by GET /books?category=.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories(lower(name));
+
+CREATE TABLE IF NOT EXISTS book_categories (
+  book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+  PRIMARY KEY (book_id, category_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_categories_category ON book_categories(category_id);
+
+-- Free-form labels, stored lower-cased.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_books_tags ON books USING GIN (tags);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS loan_policies (
+  role TEXT PRIMARY KEY,
+  max_active_bookings INT NOT NULL CHECK (max_active_bookings >= 0),
+  max_borrow_days INT NOT NULL CHECK (max_borrow_days > 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Roles without a row fall back to the same limits in code.
+INSERT INTO loan_policies (role, max_active_bookings, max_borrow_days) VALUES
+  ('user', 5, 30),
+  ('admin', 5, 30)
+ON CONFLICT (role) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS book_loan_restrictions (
+  book_id UUID PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+  reference_only BOOLEAN NOT NULL DEFAULT false,
+  max_borrow_days INT NOT NULL DEFAULT 0 CHECK (max_borrow_days >= 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,22 @@
+-- Accounts deleted by their owner keep their row, scrubbed of personal data,
+-- while bookings still reference it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Tokens issued to user_id at or before revoked_at are rejected. No foreign
+-- key: the revocation must outlive a hard-deleted user.
+CREATE TABLE IF NOT EXISTS token_revocations (
+  user_id UUID PRIMARY KEY,
+  revoked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  actor_id UUID,
+  action TEXT NOT NULL,
+  target_type TEXT NOT NULL,
+  target_id TEXT NOT NULL,
+  details JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log (target_type, target_id, created_at);
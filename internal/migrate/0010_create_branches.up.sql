@@ -0,0 +1,30 @@
+-- Each library branch owns its books and the bookings made against them.
+-- The code doubles as the branch's subdomain.
+CREATE TABLE IF NOT EXISTS branches (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  code TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  address TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Everything that existed before branches belongs to the main branch, which
+-- is also where books created outside any branch go.
+INSERT INTO branches (id, code, name)
+VALUES ('00000000-0000-0000-0000-000000000001', 'main', 'Main Library')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS branch_id UUID NOT NULL
+  DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES branches(id);
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS branch_id UUID NOT NULL
+  DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES branches(id);
+-- NULL means a global user, who may use every branch.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS branch_id UUID REFERENCES branches(id);
+
+-- Branches catalogue their own copies, so an ISBN is unique per branch.
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS books_branch_isbn_key ON books (branch_id, isbn);
+
+CREATE INDEX IF NOT EXISTS idx_bookings_branch ON bookings (branch_id);
+CREATE INDEX IF NOT EXISTS idx_users_branch ON users (branch_id);
@@ -0,0 +1,5 @@
+-- Admins can suspend an account; a suspended user can't log in.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'suspended'));
+
+CREATE INDEX IF NOT EXISTS idx_users_active_admins ON users (id) WHERE role = 'admin' AND status = 'active';
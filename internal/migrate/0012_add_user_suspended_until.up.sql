@@ -0,0 +1,2 @@
+-- A suspension may end on its own; NULL keeps it until an admin lifts it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ;
@@ -0,0 +1,16 @@
+-- A session is one login. Its id is the jti of the tokens issued for it, so
+-- revoking the session voids them before they expire.
+CREATE TABLE IF NOT EXISTS sessions (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id, created_at);
+-- Instances poll for the revoked sessions that haven't expired yet.
+CREATE INDEX IF NOT EXISTS idx_sessions_revoked ON sessions (expires_at) WHERE revoked_at IS NOT NULL;
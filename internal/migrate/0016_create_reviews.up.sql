@@ -0,0 +1,13 @@
+-- One review per user and book, written once the user has returned it.
+CREATE TABLE IF NOT EXISTS reviews (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+  text TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (book_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_book ON reviews (book_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_reviews_user ON reviews (user_id);
@@ -0,0 +1,16 @@
+-- Users waiting for a copy of a book, served in the order they joined.
+CREATE TABLE IF NOT EXISTS reservations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (book_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_book ON reservations (book_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_reservations_user ON reservations (user_id);
+
+-- A returned copy of a reserved book is offered to the first user in line as
+-- an OFFERED booking, which holds the copy until offer_expires_at.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS offer_expires_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_bookings_offers ON bookings (offer_expires_at) WHERE status = 'OFFERED';
@@ -0,0 +1,10 @@
+-- A duplicate book merged into another is kept, soft-deleted, with a pointer
+-- to the book that replaced it.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES books(id) ON DELETE SET NULL;
+
+-- An ISBN is unique per branch among live books. Books without one store ''
+-- and never clash.
+DROP INDEX IF EXISTS books_branch_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS books_branch_isbn_key ON books (branch_id, isbn)
+  WHERE isbn <> '' AND deleted_at IS NULL;
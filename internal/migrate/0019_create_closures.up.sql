@@ -0,0 +1,12 @@
+-- Days a branch is closed, e.g. holidays. No branch means every branch.
+CREATE TABLE IF NOT EXISTS closures (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  branch_id UUID REFERENCES branches(id) ON DELETE CASCADE,
+  starts_on DATE NOT NULL,
+  ends_on DATE NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_closures_dates ON closures (ends_on, starts_on);
@@ -0,0 +1,3 @@
+-- When the due-date reminder for a loan was sent; NULL until it is.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_bookings_reminders ON bookings (due_date) WHERE status = 'ACTIVE' AND reminder_sent_at IS NULL;
@@ -0,0 +1,16 @@
+-- Transactional outbox. Domain events are written in the transaction of
+-- the change they describe and published by a relay once committed.
+CREATE TABLE IF NOT EXISTS outbox (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  seq BIGINT GENERATED ALWAYS AS IDENTITY,
+  type TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  data JSONB NOT NULL DEFAULT '{}',
+  occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (seq) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered ON outbox (delivered_at) WHERE delivered_at IS NOT NULL;
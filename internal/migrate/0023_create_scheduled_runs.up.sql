@@ -0,0 +1,9 @@
+-- Runs of periodic tasks that must happen once per period across every
+-- instance, such as the weekly overdue report. Claiming a period is
+-- inserting its row.
+CREATE TABLE IF NOT EXISTS scheduled_runs (
+  name TEXT NOT NULL,
+  period TIMESTAMPTZ NOT NULL,
+  ran_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (name, period)
+);
@@ -0,0 +1,7 @@
+-- Emails are now stored lower-cased. Addresses that would collide with
+-- another account once lower-cased are left for an admin to resolve.
+UPDATE users u SET email = lower(u.email)
+WHERE u.email <> lower(u.email)
+  AND NOT EXISTS (
+    SELECT 1 FROM users o WHERE o.id <> u.id AND lower(o.email) = lower(u.email)
+  );
@@ -0,0 +1,10 @@
+-- The API's maintenance mode, shared by every instance. There is at most
+-- one row; without it maintenance mode is off.
+CREATE TABLE IF NOT EXISTS maintenance (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  enabled BOOLEAN NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  retry_after_seconds INT NOT NULL DEFAULT 0,
+  updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,13 @@
+-- Roles are now stored lower-case; the users table used to default to
+-- 'USER' while the API wrote 'user'. Constrain them to the roles the API
+-- knows so the two can't drift apart again.
+UPDATE users SET role = lower(trim(role)) WHERE role IS DISTINCT FROM lower(trim(role));
+UPDATE users SET role = 'user' WHERE role IS NULL OR role = '';
+
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+UPDATE loan_policies SET role = lower(role) WHERE role <> lower(role)
+  AND NOT EXISTS (SELECT 1 FROM loan_policies o WHERE o.role = lower(loan_policies.role));
@@ -0,0 +1,11 @@
+-- How each user wants due-date reminders. Users without a row get them by
+-- email at the library's default lead time.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  channel TEXT NOT NULL DEFAULT 'email' CHECK (channel IN ('email', 'webhook', 'none')),
+  webhook_url TEXT NOT NULL DEFAULT '',
+  -- 0 uses the default lead time.
+  reminder_lead_hours INT NOT NULL DEFAULT 0 CHECK (reminder_lead_hours BETWEEN 0 AND 336),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (channel <> 'webhook' OR webhook_url <> '')
+);
@@ -0,0 +1,3 @@
+-- The address of the client whose request made the audited change; empty
+-- for changes made by background jobs.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS client_ip TEXT NOT NULL DEFAULT '';
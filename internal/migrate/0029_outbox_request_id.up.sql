@@ -0,0 +1,3 @@
+-- The ID of the API request that recorded the event, sent with its
+-- delivery; empty for events recorded by background jobs.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
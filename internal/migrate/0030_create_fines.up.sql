@@ -0,0 +1,39 @@
+-- Fines for loans returned late, and their payments. A payment is taken
+-- by the payment provider; its webhook confirms it, marking the fine paid
+-- and writing a receipt.
+CREATE TABLE IF NOT EXISTS fines (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  booking_id UUID NOT NULL UNIQUE REFERENCES bookings(id) ON DELETE CASCADE,
+  days_late INT NOT NULL CHECK (days_late > 0),
+  amount_cents INT NOT NULL CHECK (amount_cents > 0),
+  status TEXT NOT NULL DEFAULT 'UNPAID' CHECK (status IN ('UNPAID', 'PAID')),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  paid_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_fines_user ON fines (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS payments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  fine_id UUID NOT NULL REFERENCES fines(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  provider_ref TEXT NOT NULL,
+  amount_cents INT NOT NULL,
+  currency TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SUCCEEDED', 'FAILED')),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (provider, provider_ref)
+);
+
+CREATE TABLE IF NOT EXISTS receipts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  fine_id UUID NOT NULL UNIQUE REFERENCES fines(id) ON DELETE CASCADE,
+  payment_id UUID NOT NULL UNIQUE REFERENCES payments(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  amount_cents INT NOT NULL,
+  currency TEXT NOT NULL,
+  paid_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
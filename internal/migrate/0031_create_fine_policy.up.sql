@@ -0,0 +1,14 @@
+-- The fine policy admins set, replacing the configured default. There is
+-- at most one row. Fines record their currency, as they keep the amount
+-- they were assessed at whatever the policy becomes.
+CREATE TABLE IF NOT EXISTS fine_policy (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  grace_days INT NOT NULL CHECK (grace_days >= 0),
+  per_day_cents INT NOT NULL CHECK (per_day_cents >= 0),
+  max_cents INT NOT NULL CHECK (max_cents >= 0),
+  currency TEXT NOT NULL,
+  updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE fines ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'usd';
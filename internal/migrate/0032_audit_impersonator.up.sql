@@ -0,0 +1,3 @@
+-- The admin who made the audited change while signed in as actor_id for
+-- support; NULL when the actor acted as themselves.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS impersonator_id UUID;
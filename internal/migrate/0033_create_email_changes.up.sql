@@ -0,0 +1,10 @@
+-- Email changes waiting for the new address to be confirmed. A user has at
+-- most one; asking again replaces it. Only the hash of the emailed token
+-- is kept.
+CREATE TABLE IF NOT EXISTS email_changes (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  new_email TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,17 @@
+-- Notices admins publish for client apps to show, such as planned
+-- maintenance or policy changes. They show from starts_at (or at once) until
+-- ends_at (or until removed), to everyone or to admins only.
+CREATE TABLE IF NOT EXISTS announcements (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  title TEXT NOT NULL,
+  body TEXT NOT NULL DEFAULT '',
+  audience TEXT NOT NULL DEFAULT 'all' CHECK (audience IN ('all', 'admins')),
+  starts_at TIMESTAMPTZ,
+  ends_at TIMESTAMPTZ,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_announcements_ends_at ON announcements (ends_at);
@@ -0,0 +1,24 @@
+-- Lists of books users keep for themselves, such as "to read". Each user
+-- has at most one favorites list, made the first time they use it. A list
+-- with a share_token can be read by anyone who has the token.
+CREATE TABLE IF NOT EXISTS reading_lists (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  favorites BOOLEAN NOT NULL DEFAULT FALSE,
+  share_token TEXT UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_lists_user_id ON reading_lists (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reading_lists_favorites ON reading_lists (user_id) WHERE favorites;
+
+CREATE TABLE IF NOT EXISTS reading_list_books (
+  list_id UUID NOT NULL REFERENCES reading_lists(id) ON DELETE CASCADE,
+  book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  added_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (list_id, book_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_list_books_book_id ON reading_list_books (book_id);
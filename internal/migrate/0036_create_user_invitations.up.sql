@@ -0,0 +1,9 @@
+-- Invitations to choose a password, sent to users imported without one.
+-- A user has at most one; inviting again replaces it. Only the hash of the
+-- emailed token is kept.
+CREATE TABLE IF NOT EXISTS user_invitations (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
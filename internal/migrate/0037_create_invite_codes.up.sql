@@ -0,0 +1,16 @@
+-- Codes admins hand out so people can register while registration is
+-- closed. A code works max_uses times until expires_at; uses counts the
+-- registrations made with it. Only a SHA-256 hash of the code is stored;
+-- prefix is its first characters, kept so admins can tell codes apart.
+CREATE TABLE IF NOT EXISTS invite_codes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  prefix TEXT NOT NULL,
+  code_hash TEXT NOT NULL UNIQUE,
+  max_uses INT NOT NULL CHECK (max_uses > 0),
+  uses INT NOT NULL DEFAULT 0 CHECK (uses <= max_uses),
+  note TEXT NOT NULL DEFAULT '',
+  created_by UUID,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,3 @@
+-- Every change to a user bumps its version, which clients send back in
+-- If-Match so two admins editing the same user can't overwrite each other.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
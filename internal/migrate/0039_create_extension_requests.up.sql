@@ -0,0 +1,22 @@
+-- Requests to keep a loan past its due date, beyond what the loan policy
+-- allows, each approved or denied by an admin with a comment. A booking
+-- has at most one request waiting for a decision. branch_id is the
+-- booking's, so admins scoped to a branch only see its requests.
+CREATE TABLE IF NOT EXISTS extension_requests (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  branch_id UUID NOT NULL REFERENCES branches(id),
+  current_due_date TIMESTAMPTZ NOT NULL,
+  requested_due_date TIMESTAMPTZ NOT NULL,
+  reason TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'APPROVED', 'DENIED')),
+  decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  decision_comment TEXT NOT NULL DEFAULT '',
+  decided_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_extension_requests_pending
+  ON extension_requests (booking_id) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_extension_requests_user ON extension_requests (user_id, created_at);
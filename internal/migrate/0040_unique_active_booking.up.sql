@@ -0,0 +1,18 @@
+-- Borrow checks for an active loan of the book while holding the book row
+-- locked; this backs that check up, so a user can never hold two ACTIVE
+-- loans of one book even if some other path skips the lock.
+--
+-- Loans that slipped past the check before it locked the book are resolved
+-- first: of a user's ACTIVE loans of one book, the earliest borrowed is
+-- kept and the others are marked returned now, freeing their copies.
+UPDATE bookings SET status = 'RETURNED', returned_at = NOW(), updated_at = NOW()
+WHERE id IN (
+  SELECT id FROM (
+    SELECT id, row_number() OVER (PARTITION BY user_id, book_id ORDER BY borrowed_at, id) AS n
+    FROM bookings WHERE status = 'ACTIVE'
+  ) active
+  WHERE n > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_user_book
+  ON bookings (user_id, book_id) WHERE status = 'ACTIVE';
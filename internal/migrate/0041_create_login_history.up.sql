@@ -0,0 +1,14 @@
+-- Every successful login, kept after its session ends so users can review
+-- where their account was used. new_device marks a login from an IP or
+-- user agent the user hadn't logged in from before, which they were
+-- emailed about.
+CREATE TABLE IF NOT EXISTS login_history (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  new_device BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user ON login_history (user_id, created_at);
@@ -0,0 +1,15 @@
+-- The version each user's due-date calendar feed token is signed with.
+-- Resetting the feed bumps it, which voids URLs handed out before; a user
+-- without a row has no feed, so dropping the row when an account is
+-- anonymized voids them too. Existing accounts start at version 1, whose
+-- tokens are the ones signed before feeds had versions, so URLs already
+-- subscribed to keep working.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  version INT NOT NULL DEFAULT 1,
+  rotated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO calendar_feeds (user_id)
+  SELECT id FROM users WHERE deleted_at IS NULL
+  ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Announcement audiences.
+const (
+	AudienceAll    = "all"
+	AudienceAdmins = "admins"
+)
+
+// Announcement is a notice, such as planned maintenance or a policy
+// change, that client apps show to its audience while it is active.
+type Announcement struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Body     string `json:"body,omitempty"`
+	Audience string `json:"audience"`
+	// StartsAt and EndsAt bound when the announcement shows; nil
+	// StartsAt shows it at once and nil EndsAt until it is removed.
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ActiveAt reports whether the announcement shows at now.
+func (a Announcement) ActiveAt(now time.Time) bool {
+	return (a.StartsAt == nil || !now.Before(*a.StartsAt)) && (a.EndsAt == nil || now.Before(*a.EndsAt))
+}
+
+// AnnouncementRequest creates an announcement, or replaces one on update.
+// Audience defaults to all.
+type AnnouncementRequest struct {
+	Title    string     `json:"title" validate:"required,max=200"`
+	Body     string     `json:"body" validate:"max=2000"`
+	Audience string     `json:"audience" validate:"omitempty,oneof=all admins"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+// Normalize trims the text and lower-cases the audience before validation.
+func (r *AnnouncementRequest) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Body = strings.TrimSpace(r.Body)
+	r.Audience = strings.ToLower(strings.TrimSpace(r.Audience))
+}
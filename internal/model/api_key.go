@@ -0,0 +1,75 @@
+package model
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// API key scopes. A read key may only make safe (GET, HEAD, OPTIONS)
+// requests; a write key may make any request its user could.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKeyScopes are the scopes a key can be given.
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeWrite}
+
+// APIKey lets a machine client call the API as UserID without logging in.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the start of the key, to tell keys apart; the key itself is
+	// only shown when it is issued.
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	UserID    string     `json:"user_id"`
+	Scopes    []string   `json:"scopes"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether k can be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Allows reports whether k's scopes permit a request with method.
+func (k *APIKey) Allows(method string) bool {
+	if slices.Contains(k.Scopes, APIKeyScopeWrite) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(k.Scopes, APIKeyScopeRead)
+	}
+	return false
+}
+
+// CreateAPIKeyRequest issues a key acting as UserID, or as the admin
+// issuing it when UserID is empty.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	UserID    string     `json:"user_id,omitempty"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Normalize trims the name and lower-cases the scopes.
+func (r *CreateAPIKeyRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.UserID = strings.TrimSpace(r.UserID)
+	for i, s := range r.Scopes {
+		r.Scopes[i] = strings.ToLower(strings.TrimSpace(s))
+	}
+}
+
+// CreateAPIKeyResponse is a newly issued key. Key is shown only here.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// Audit actions.
+const (
+	AuditAccountDeleted    = "account.deleted"
+	AuditAccountAnonymized = "account.anonymized"
+	AuditReviewRemoved     = "review.removed"
+	AuditMaintenanceSet    = "maintenance.set"
+	AuditFinePolicySet     = "fine_policy.set"
+	AuditUserImpersonated  = "user.impersonated"
+	AuditBookCreated       = "book.created"
+	AuditBookUpdated       = "book.updated"
+	AuditBookMerged        = "book.merged"
+	AuditBookDeleted       = "book.deleted"
+	AuditBookRestored      = "book.restored"
+	AuditExtensionApproved = "extension.approved"
+	AuditExtensionDenied   = "extension.denied"
+)
+
+// AuditEntry records who did what to which record.
+type AuditEntry struct {
+	ID         string                 `json:"id"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	// ClientIP is the address of the client whose request made the change;
+	// empty for changes made by background jobs.
+	ClientIP string `json:"client_ip,omitempty"`
+	// ImpersonatorID is the admin who made the change while signed in as
+	// ActorID for support; empty when the actor acted as themselves.
+	ImpersonatorID string    `json:"impersonator_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BookChange is one entry in a book's history: what produced Version, who
+// did it and, when fields changed, their values before and after.
+type BookChange struct {
+	Version int    `json:"version"`
+	Action  string `json:"action" example:"book.updated"`
+	ActorID string `json:"actor_id,omitempty"`
+	// ImpersonatorID is the admin who made the change while signed in as
+	// ActorID.
+	ImpersonatorID string                 `json:"impersonator_id,omitempty"`
+	Changes        map[string]FieldChange `json:"changes,omitempty"`
+	// MergedInto is the book a merged duplicate was folded into, and
+	// MergedFrom the duplicate folded into this one.
+	MergedInto string    `json:"merged_into,omitempty"`
+	MergedFrom string    `json:"merged_from,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// FieldChange is a field's value before and after a change; From is null
+// when the book was created.
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
@@ -7,8 +7,27 @@ type LoginResponse struct {
     ExpiresAt time.Time `json:"expires_at"`
 }
 
+// ImpersonationResponse is a token acting as another user, and the banner
+// clients show while it is in use.
+type ImpersonationResponse struct {
+    Token     string    `json:"token"`
+    ExpiresAt time.Time `json:"expires_at"`
+    Banner    string    `json:"banner"`
+}
+
+// Challenge tells a client what to solve before registering. For a
+// CAPTCHA it names the widget to render with SiteKey; for a proof of work
+// it is Nonce, to be solved at Difficulty before ExpiresAt.
+type Challenge struct {
+    Kind       string     `json:"kind"`
+    SiteKey    string     `json:"site_key,omitempty"`
+    Nonce      string     `json:"nonce,omitempty"`
+    Difficulty int        `json:"difficulty,omitempty"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
 type RefreshRequest struct {
-    Token string `json:"token"`
+    Token string `json:"token" validate:"required"`
 }
 
 type Claims struct {
@@ -1,9 +1,14 @@
 package model
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Book struct {
 	ID            string    `json:"id"`
+	BranchID      string    `json:"branch_id"`
 	Title         string    `json:"title"`
 	Author        string    `json:"author"`
 	PublishedYear int       `json:"published_year,omitempty"`
@@ -11,16 +16,120 @@ type Book struct {
 	CreatedAt     time.Time `json:"created_at,omitempty"`
 	UpdatedAt     time.Time `json:"updated_at,omitempty"`
 	Version       int       `json:"version"`
+	CoverURL      string    `json:"cover_url,omitempty"`
+	// TotalCopies is how many copies the library owns; CopiesAvailable
+	// subtracts the ones currently on loan.
+	TotalCopies     int  `json:"total_copies"`
+	CopiesAvailable int  `json:"copies_available"`
+	Available       bool `json:"available"`
+	// Categories is linked by ID on create; reads return the full records.
+	Categories []Category `json:"categories"`
+	Tags       []string   `json:"tags"`
+	// AverageRating is the mean of the book's review ratings, rounded to
+	// two decimals, or 0 while ReviewCount is 0.
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
 }
+
+// CopyCode is what the label of a book's copy-th copy encodes: the book's
+// ID and the copy's number, like "<book id>/2". Copies are numbered from 1
+// to TotalCopies.
+func CopyCode(bookID string, copy int) string {
+	return bookID + "/" + strconv.Itoa(copy)
+}
+
+// ParseCopyCode splits a code made by CopyCode. ok is false when code
+// isn't one.
+func ParseCopyCode(code string) (bookID string, copy int, ok bool) {
+	bookID, n, found := strings.Cut(code, "/")
+	if !found || bookID == "" {
+		return "", 0, false
+	}
+	copy, err := strconv.Atoi(n)
+	if err != nil || copy < 1 {
+		return "", 0, false
+	}
+	return bookID, copy, true
+}
+
+// PopularBook is a book with the number of loans of it started within the
+// popularity window.
+type PopularBook struct {
+	Book
+	BorrowCount int `json:"borrow_count"`
+}
+
+// BookFilter narrows GET /books. Category matches a category ID or name
+// (case-insensitive); Tag matches one tag. Empty fields don't filter.
+type BookFilter struct {
+	Category string
+	Tag      string
+}
+
+// CreateBookRequest may leave out title and author when an ISBN is given;
+// the missing fields are then looked up by ISBN.
 type CreateBookRequest struct {
-	Title         string `json:"title"`
-	Author        string `json:"author"`
-	PublishedYear int    `json:"published_year"`
-	ISBN          string `json:"isbn"`
+	Title         string   `json:"title" validate:"required_without=ISBN,max=500"`
+	Author        string   `json:"author" validate:"required_without=ISBN,max=255"`
+	PublishedYear int      `json:"published_year" validate:"min=0"`
+	ISBN          string   `json:"isbn" validate:"max=20"`
+	TotalCopies   *int     `json:"total_copies,omitempty" validate:"omitempty,min=0"`
+	CategoryIDs   []string `json:"category_ids,omitempty" validate:"max=20"`
+	Tags          []string `json:"tags,omitempty" validate:"max=20"`
+}
+
+// Normalize trims surrounding whitespace from the text fields.
+func (r *CreateBookRequest) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Author = strings.TrimSpace(r.Author)
+	r.ISBN = strings.TrimSpace(r.ISBN)
+	r.Tags = NormalizeTags(r.Tags)
+}
+
+// Copies returns the requested number of copies, defaulting to one.
+func (r *CreateBookRequest) Copies() int {
+	if r.TotalCopies == nil {
+		return 1
+	}
+	return *r.TotalCopies
 }
+
 type UpdateBookRequest struct {
-    Title         string `json:"title"`
-    Author        string `json:"author"`
-    PublishedYear int    `json:"published_year"`
-    ISBN          string `json:"isbn"`
+	Title         string `json:"title" validate:"required,max=500"`
+	Author        string `json:"author" validate:"required,max=255"`
+	PublishedYear int    `json:"published_year" validate:"min=0"`
+	ISBN          string `json:"isbn" validate:"max=20"`
+	TotalCopies   *int   `json:"total_copies,omitempty" validate:"omitempty,min=0"`
+	// CategoryIDs and Tags replace the book's current ones when present;
+	// omit them to leave them unchanged, or send [] to clear them.
+	CategoryIDs []string `json:"category_ids,omitempty" validate:"max=20"`
+	Tags        []string `json:"tags,omitempty" validate:"max=20"`
+	// Version is the version the client last read. It is an alternative to
+	// the If-Match header for clients that cannot set headers.
+	Version *int `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+// Normalize trims surrounding whitespace from the text fields.
+func (r *UpdateBookRequest) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Author = strings.TrimSpace(r.Author)
+	r.ISBN = strings.TrimSpace(r.ISBN)
+	r.Tags = NormalizeTags(r.Tags)
+}
+
+// ImportRowResult reports the outcome of a single row in a bulk import.
+// Row is 1-based and counts data rows only (the CSV header is not a row).
+type ImportRowResult struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"` // created or error
+	BookID string `json:"book_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarises a bulk book import.
+type ImportReport struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []ImportRowResult `json:"results"`
 }
@@ -1,30 +1,119 @@
 package model
 
-import "time"
+import (
+    "strings"
+    "time"
+)
 
 type Booking struct {
     ID         string     `json:"id"`
+    BranchID   string     `json:"branch_id"`
     UserID     string     `json:"user_id"`
     BookID     string     `json:"book_id"`
     Book       *Book      `json:"book,omitempty"`
+    User       *User      `json:"user,omitempty"`
     BorrowedAt time.Time  `json:"borrowed_at"`
     DueDate    time.Time  `json:"due_date"`
     ReturnedAt *time.Time `json:"returned_at,omitempty"`
-    Status     string     `json:"status"` // ACTIVE, RETURNED, OVERDUE
-    CreatedAt  time.Time  `json:"created_at"`
-    UpdatedAt  time.Time  `json:"updated_at"`
+    Status     string     `json:"status"` // ACTIVE, RETURNED, OVERDUE, OFFERED, DECLINED, EXPIRED
+    // OfferExpiresAt is when an OFFERED booking lapses unless accepted.
+    OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+    CreatedAt      time.Time  `json:"created_at"`
+    UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type BorrowBookRequest struct {
-    BookID     string `json:"book_id" validate:"required"`
-    BorrowDays int    `json:"borrow_days" validate:"required,min=1,max=30"`
+    BookID string `json:"book_id" validate:"required"`
+    // BorrowDays is the length of the loan, within the library's
+    // configured bounds; the configured default when left out.
+    BorrowDays int `json:"borrow_days,omitempty" validate:"omitempty,min=1"`
+    // TimeZone is the IANA time zone, such as Europe/London, the receipt
+    // gives the due date in; UTC when empty.
+    TimeZone string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *BorrowBookRequest) Normalize() {
+    r.BookID = strings.TrimSpace(r.BookID)
+    r.TimeZone = strings.TrimSpace(r.TimeZone)
+}
+
+// ScanBorrowRequest borrows the book behind a scanned Code: either its ISBN
+// or a copy barcode as printed on the book's labels.
+type ScanBorrowRequest struct {
+    Code string `json:"code" validate:"required,max=100"`
+    // BorrowDays is as for BorrowBookRequest.
+    BorrowDays int `json:"borrow_days,omitempty" validate:"omitempty,min=1"`
+    // TimeZone is as for BorrowBookRequest.
+    TimeZone string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *ScanBorrowRequest) Normalize() {
+    r.Code = strings.TrimSpace(r.Code)
+    r.TimeZone = strings.TrimSpace(r.TimeZone)
+}
+
+// AcceptOfferRequest turns a waitlist offer into a loan of BorrowDays,
+// which is as for BorrowBookRequest.
+type AcceptOfferRequest struct {
+    BorrowDays int `json:"borrow_days,omitempty" validate:"omitempty,min=1"`
 }
 
 type ReturnBookRequest struct {
     BookingID string `json:"booking_id" validate:"required"`
 }
 
+// BorrowBookResponse is the receipt for a new loan: the booking, with its
+// book, and the terms it was made on.
 type BorrowBookResponse struct {
     Booking *Booking `json:"booking"`
     Message string   `json:"message"`
-}
\ No newline at end of file
+    // DueDateLocal is the booking's due date in TimeZone.
+    DueDateLocal time.Time `json:"due_date_local"`
+    TimeZone     string    `json:"time_zone"`
+    // Policy is the borrower's role policy the loan was checked against,
+    // and MaxBorrowDays the longest the book could be borrowed for under
+    // it and any restriction on the book.
+    Policy        LoanPolicy `json:"policy"`
+    MaxBorrowDays int        `json:"max_borrow_days"`
+    // LoansOutstanding counts the borrower's loans out, this one included.
+    // LoansRemaining is how many more the policy allows them; it is
+    // omitted when the policy sets no limit.
+    LoansOutstanding int  `json:"loans_outstanding"`
+    LoansRemaining   *int `json:"loans_remaining,omitempty"`
+}
+
+// BookingExpand selects the related records embedded in listed bookings
+// (?expand=book,user). They are joined in the same query as the bookings.
+type BookingExpand struct {
+    Book bool
+    User bool
+}
+
+// BookingStatuses are the values Booking.Status can take. OFFERED bookings
+// hold a copy for the first user on the book's waitlist; they become ACTIVE
+// when accepted, or DECLINED or EXPIRED.
+var BookingStatuses = []string{"ACTIVE", "RETURNED", "OVERDUE", "OFFERED", "DECLINED", "EXPIRED"}
+
+// BookingFilter narrows a bookings list. Empty fields match everything;
+// From is inclusive and To is exclusive on borrowed_at.
+type BookingFilter struct {
+    Status string
+    UserID string
+    BookID string
+    From   *time.Time
+    To     *time.Time
+}
+
+// BookingExportFilter narrows a bookings export to a borrowed_at window.
+// Nil bounds are open; From is inclusive and To is exclusive.
+type BookingExportFilter struct {
+    From *time.Time
+    To   *time.Time
+}
+
+// CalendarFeed is where calendar apps subscribe to a user's due dates.
+type CalendarFeed struct {
+    URL string `json:"url"`
+}
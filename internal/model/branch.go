@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultBranchID is the main branch, which owns everything created before
+// branches existed and books created outside any branch.
+const DefaultBranchID = "00000000-0000-0000-0000-000000000001"
+
+// Branch is a library location. Books and bookings belong to one branch;
+// users are either scoped to one or global.
+type Branch struct {
+	ID string `json:"id"`
+	// Code is a short, unique, lower-case name, also used as the branch's
+	// subdomain.
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type BranchRequest struct {
+	Code    string `json:"code" validate:"required,max=63"`
+	Name    string `json:"name" validate:"required,max=200"`
+	Address string `json:"address" validate:"max=500"`
+}
+
+// Normalize trims surrounding whitespace and lower-cases the code.
+func (r *BranchRequest) Normalize() {
+	r.Code = strings.ToLower(strings.TrimSpace(r.Code))
+	r.Name = strings.TrimSpace(r.Name)
+	r.Address = strings.TrimSpace(r.Address)
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Category groups books by genre or subject. A book can be in several.
+type Category struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CategoryRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+// Normalize trims surrounding whitespace from the text fields.
+func (r *CategoryRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+}
+
+// NormalizeTags trims and lower-cases tags, dropping blanks and duplicates.
+// A nil slice stays nil so updates can tell "leave tags alone" from "clear".
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Closure is a run of days, both ends included, on which a branch is closed:
+// nothing can be borrowed or returned, and no loan falls due. Days are UTC
+// calendar days, held as midnight UTC.
+type Closure struct {
+	ID string `json:"id"`
+	// BranchID is empty for closures of every branch.
+	BranchID  string    `json:"branch_id,omitempty"`
+	StartsOn  time.Time `json:"starts_on"`
+	EndsOn    time.Time `json:"ends_on"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Covers reports whether the closure includes the UTC day of t.
+func (c Closure) Covers(t time.Time) bool {
+	day := Day(t)
+	return !day.Before(c.StartsOn) && !day.After(c.EndsOn)
+}
+
+// Day returns midnight UTC of t's UTC calendar day.
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// CreateClosureRequest gives the first and last closed day as YYYY-MM-DD.
+type CreateClosureRequest struct {
+	StartsOn string `json:"starts_on" validate:"required"`
+	EndsOn   string `json:"ends_on" validate:"required"`
+	Reason   string `json:"reason" validate:"max=200"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *CreateClosureRequest) Normalize() {
+	r.StartsOn = strings.TrimSpace(r.StartsOn)
+	r.EndsOn = strings.TrimSpace(r.EndsOn)
+	r.Reason = strings.TrimSpace(r.Reason)
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// EmailChange is a change of a user's email address waiting for the new
+// address to be confirmed through the link emailed to it.
+type EmailChange struct {
+	UserID    string
+	NewEmail  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// PendingEmailChange tells a user where the confirmation link went and
+// until when it works. Their email stays as it was until then.
+type PendingEmailChange struct {
+	PendingEmail string    `json:"pending_email"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
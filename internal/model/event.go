@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain event types.
+const (
+	EventBookingCreated  = "booking.created"
+	EventBookingReturned = "booking.returned"
+	// EventBookingOverdue is recorded when a loan passes its due date.
+	EventBookingOverdue = "booking.overdue"
+	// EventBookingOffered is recorded when a waitlisted user is offered a
+	// copy that has come free.
+	EventBookingOffered = "booking.offered"
+	// EventUserRegistered is recorded for every new account, however it was
+	// created.
+	EventUserRegistered = "user.registered"
+)
+
+// Event is a domain event, published to subscribers after the change it
+// describes commits. Delivery is at least once: subscribers should ignore
+// an ID they have already seen.
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Subject is the ID of the entity the event is about.
+	Subject    string          `json:"subject"`
+	Data       json.RawMessage `json:"data" swaggertype:"object"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	// RequestID is the ID of the API request that made the change, if any.
+	RequestID string `json:"request_id,omitempty"`
+	// Attempts counts failed deliveries so far.
+	Attempts int `json:"-"`
+}
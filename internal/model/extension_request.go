@@ -0,0 +1,64 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Extension request statuses.
+const (
+	ExtensionPending  = "PENDING"
+	ExtensionApproved = "APPROVED"
+	ExtensionDenied   = "DENIED"
+)
+
+// ExtensionRequest asks to keep a loan past its due date for longer than
+// the loan policy allows a borrower to choose. An admin approves it, which
+// moves the booking's due date to RequestedDueDate, or denies it.
+type ExtensionRequest struct {
+	ID        string `json:"id"`
+	BookingID string `json:"booking_id"`
+	UserID    string `json:"user_id"`
+	BranchID  string `json:"branch_id"`
+	// CurrentDueDate is the booking's due date when the request was made.
+	CurrentDueDate   time.Time `json:"current_due_date"`
+	RequestedDueDate time.Time `json:"requested_due_date"`
+	Reason           string    `json:"reason"`
+	Status           string    `json:"status" example:"PENDING"`
+	// DecidedBy is the admin who approved or denied the request, and
+	// Comment what they said about it.
+	DecidedBy string     `json:"decided_by,omitempty"`
+	Comment   string     `json:"comment,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ExtensionRequestFilter narrows an extension request listing. Empty
+// fields don't filter.
+type ExtensionRequestFilter struct {
+	Status string
+	UserID string
+}
+
+// CreateExtensionRequest asks for a booking's due date to move ExtraDays
+// later.
+type CreateExtensionRequest struct {
+	ExtraDays int    `json:"extra_days" validate:"required,min=1,max=365"`
+	Reason    string `json:"reason" validate:"required,max=1000"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *CreateExtensionRequest) Normalize() {
+	r.Reason = strings.TrimSpace(r.Reason)
+}
+
+// DecideExtensionRequest carries the admin's comment on approving or
+// denying an extension request, which the borrower is sent.
+type DecideExtensionRequest struct {
+	Comment string `json:"comment" validate:"required,max=1000"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *DecideExtensionRequest) Normalize() {
+	r.Comment = strings.TrimSpace(r.Comment)
+}
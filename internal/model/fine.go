@@ -0,0 +1,99 @@
+package model
+
+import "time"
+
+// Fine statuses.
+const (
+	FineUnpaid = "UNPAID"
+	FinePaid   = "PAID"
+)
+
+// Fine is what a borrower owes for a loan returned late, priced by the
+// fine policy in force when the book comes back. Later policy changes
+// leave it as it is.
+type Fine struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	BookingID   string     `json:"booking_id"`
+	DaysLate    int        `json:"days_late"`
+	AmountCents int        `json:"amount_cents"`
+	Currency    string     `json:"currency"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+}
+
+// FinePolicy prices late loans: nothing for the first GraceDays days
+// late, then PerDayCents for every further started day, capped at
+// MaxCents per loan unless MaxCents is 0. Amounts are in cents of
+// Currency.
+type FinePolicy struct {
+	GraceDays   int    `json:"grace_days"`
+	PerDayCents int    `json:"per_day_cents"`
+	MaxCents    int    `json:"max_cents"`
+	Currency    string `json:"currency"`
+	// UpdatedBy and UpdatedAt are empty while the configured default is
+	// in force.
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Fine returns the fine for a loan daysLate days late.
+func (p FinePolicy) Fine(daysLate int) int {
+	f := max(daysLate-p.GraceDays, 0) * p.PerDayCents
+	if p.MaxCents > 0 {
+		f = min(f, p.MaxCents)
+	}
+	return f
+}
+
+type FinePolicyRequest struct {
+	GraceDays   int    `json:"grace_days" validate:"min=0,max=365"`
+	PerDayCents int    `json:"per_day_cents" validate:"min=0,max=100000"`
+	MaxCents    int    `json:"max_cents" validate:"min=0,max=10000000"`
+	Currency    string `json:"currency" validate:"required,len=3,lowercase,alpha"`
+}
+
+// Payment statuses.
+const (
+	PaymentPending   = "PENDING"
+	PaymentSucceeded = "SUCCEEDED"
+	PaymentFailed    = "FAILED"
+)
+
+// Payment is one attempt to pay a fine through the payment provider.
+type Payment struct {
+	ID     string `json:"id"`
+	FineID string `json:"fine_id"`
+	UserID string `json:"user_id"`
+	// Provider names the payment provider and ProviderRef is its ID for
+	// the payment, such as a Stripe payment intent ID.
+	Provider    string    `json:"provider"`
+	ProviderRef string    `json:"provider_ref"`
+	AmountCents int       `json:"amount_cents"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PaymentIntent is what a client needs to take the payment of a fine with
+// the provider's SDK, such as Stripe.js.
+type PaymentIntent struct {
+	PaymentID    string `json:"payment_id"`
+	Provider     string `json:"provider"`
+	ClientSecret string `json:"client_secret"`
+	AmountCents  int    `json:"amount_cents"`
+	Currency     string `json:"currency"`
+}
+
+// Receipt records that a fine was paid, and with which payment.
+type Receipt struct {
+	ID          string    `json:"id"`
+	FineID      string    `json:"fine_id"`
+	PaymentID   string    `json:"payment_id"`
+	UserID      string    `json:"user_id"`
+	AmountCents int       `json:"amount_cents"`
+	Currency    string    `json:"currency"`
+	PaidAt      time.Time `json:"paid_at"`
+}
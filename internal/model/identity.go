@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// ExternalIdentity is an account at an external identity provider, as the
+// provider described it after a successful login.
+type ExternalIdentity struct {
+	// Provider names the provider, such as "google" or "github".
+	Provider string
+	// Subject is the provider's stable ID for the account.
+	Subject string
+	Email   string
+	// EmailVerified reports whether the provider vouches that the account
+	// owns Email. Only verified emails are linked to or provisioned.
+	EmailVerified bool
+	// Username is the provider's handle or display name, used to pick the
+	// username of a provisioned user.
+	Username string
+	// Groups are the lower-cased groups the provider puts the account in,
+	// when it was asked for them: for GitHub, its organizations as "org"
+	// and teams as "org/team".
+	Groups []string
+}
+
+// UserIdentity links an external identity to a user.
+type UserIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
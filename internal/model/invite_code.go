@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// InviteCode lets people register while registration is closed. It works
+// MaxUses times until ExpiresAt, unless it is revoked.
+type InviteCode struct {
+	ID string `json:"id"`
+	// Prefix is the start of the code, to tell codes apart; the code
+	// itself is only shown when it is minted.
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	Note      string     `json:"note,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateInviteCodeRequest mints a code. MaxUses defaults to 1, a
+// single-use code, and ExpiresAt to a week from now.
+type CreateInviteCodeRequest struct {
+	MaxUses   int        `json:"max_uses,omitempty" validate:"omitempty,min=1,max=10000"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Note      string     `json:"note,omitempty" validate:"max=200"`
+}
+
+// Normalize trims the note.
+func (r *CreateInviteCodeRequest) Normalize() {
+	r.Note = strings.TrimSpace(r.Note)
+}
+
+// CreateInviteCodeResponse is a newly minted code. Code is shown only
+// here.
+type CreateInviteCodeResponse struct {
+	InviteCode
+	Code string `json:"code"`
+}
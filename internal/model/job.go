@@ -0,0 +1,44 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses. A pending job waits for RunAt and a running one is held by a
+// worker. A failed attempt makes the job pending again, later, until it has
+// used MaxAttempts; then it is dead until an admin requeues it.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// JobStatuses are the values Job.Status can take.
+var JobStatuses = []string{JobPending, JobRunning, JobDone, JobDead}
+
+// Job is a unit of background work, such as sending an email, whose Payload
+// is interpreted by the handler registered for its Kind.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	// LastError is the error of the latest failed attempt.
+	LastError string `json:"last_error,omitempty"`
+	// LockedAt is when a worker claimed the job for its latest attempt.
+	LockedAt   *time.Time `json:"locked_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// JobFilter narrows a job listing; empty fields match everything.
+type JobFilter struct {
+	Status string
+	Kind   string
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoanDuration bounds the borrow_days a borrower may ask for, whatever
+// their role's policy allows, and gives the length of a loan when they
+// don't ask. The zero value means DefaultLoanDuration.
+type LoanDuration struct {
+	MinDays     int
+	MaxDays     int
+	DefaultDays int
+}
+
+// DefaultLoanDuration is the loan duration when none is configured.
+func DefaultLoanDuration() LoanDuration {
+	return LoanDuration{MinDays: 1, MaxDays: 365, DefaultDays: 14}
+}
+
+// Validate reports bounds that no loan could meet, or a default outside
+// them.
+func (d LoanDuration) Validate() error {
+	switch {
+	case d.MinDays < 1:
+		return fmt.Errorf("the shortest loan must be at least 1 day, not %d", d.MinDays)
+	case d.MaxDays < d.MinDays:
+		return fmt.Errorf("the longest loan (%d days) is shorter than the shortest (%d days)", d.MaxDays, d.MinDays)
+	case d.DefaultDays < d.MinDays || d.DefaultDays > d.MaxDays:
+		return fmt.Errorf("the default loan of %d days is outside %d-%d days", d.DefaultDays, d.MinDays, d.MaxDays)
+	}
+	return nil
+}
+
+// LoanPolicy limits borrowing for every user with Role.
+type LoanPolicy struct {
+	Role Role `json:"role"`
+	// MaxActiveBookings caps the bookings a user may have out at once
+	// (active or overdue); 0 means no limit.
+	MaxActiveBookings int       `json:"max_active_bookings"`
+	MaxBorrowDays     int       `json:"max_borrow_days"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// DefaultLoanPolicy applies to a role that has no stored policy.
+func DefaultLoanPolicy(role Role) LoanPolicy {
+	return LoanPolicy{Role: role, MaxActiveBookings: 5, MaxBorrowDays: 30}
+}
+
+// LoanPolicyRequest sets a role's policy. MaxBorrowDays can't exceed the
+// longest loan the library is configured to allow.
+type LoanPolicyRequest struct {
+	MaxActiveBookings int `json:"max_active_bookings" validate:"min=0,max=100"`
+	MaxBorrowDays     int `json:"max_borrow_days" validate:"required,min=1"`
+}
+
+// BookLoanRestriction tightens the role policies for one book.
+type BookLoanRestriction struct {
+	BookID string `json:"book_id"`
+	// ReferenceOnly books can't be borrowed at all.
+	ReferenceOnly bool `json:"reference_only"`
+	// MaxBorrowDays, when non-zero, caps loans of the book below the
+	// borrower's role limit.
+	MaxBorrowDays int       `json:"max_borrow_days,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// BookLoanRestrictionRequest sets a book's restriction; MaxBorrowDays is
+// bounded as for LoanPolicyRequest.
+type BookLoanRestrictionRequest struct {
+	ReferenceOnly bool `json:"reference_only"`
+	MaxBorrowDays int  `json:"max_borrow_days" validate:"min=0"`
+}
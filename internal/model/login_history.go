@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// LoginRecord is one successful login, with the device and address it was
+// made from.
+type LoginRecord struct {
+	ID        string `json:"id"`
+	UserID    string `json:"-"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// NewDevice marks a login from an IP or user agent the user hadn't
+	// logged in from before. Their first login isn't marked.
+	NewDevice bool      `json:"new_device"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Maintenance is the API's maintenance mode. While it is enabled, requests
+// other than admin, health and allowlisted ones are answered with 503.
+type Maintenance struct {
+	Enabled bool `json:"enabled"`
+	// Message tells clients why the API is unavailable.
+	Message string `json:"message,omitempty"`
+	// RetryAfterSeconds is sent as Retry-After; 0 sends the configured
+	// default.
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+	// Forced is set when configuration keeps maintenance mode on whatever
+	// admins set.
+	Forced    bool      `json:"forced,omitempty"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MaintenanceRequest struct {
+	Enabled           *bool  `json:"enabled" validate:"required"`
+	Message           string `json:"message" validate:"max=500"`
+	RetryAfterSeconds int    `json:"retry_after_seconds" validate:"min=0,max=86400"`
+}
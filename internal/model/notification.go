@@ -0,0 +1,49 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// NotifyChannel is how a user wants to hear about their loans.
+type NotifyChannel string
+
+const (
+	NotifyEmail   NotifyChannel = "email"
+	NotifyWebhook NotifyChannel = "webhook"
+	NotifyNone    NotifyChannel = "none"
+)
+
+// NotificationPreferences are a user's choices for due-date reminders.
+// A user who never saved any gets DefaultNotificationPreferences.
+type NotificationPreferences struct {
+	UserID  string        `json:"-"`
+	Channel NotifyChannel `json:"channel"`
+	// WebhookURL receives the reminders when Channel is webhook.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// ReminderLeadHours is how long before a loan falls due its reminder
+	// is sent; 0 uses the library's default.
+	ReminderLeadHours int        `json:"reminder_lead_hours"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+// DefaultNotificationPreferences emails reminders at the library's default
+// lead time.
+func DefaultNotificationPreferences(userID string) NotificationPreferences {
+	return NotificationPreferences{UserID: userID, Channel: NotifyEmail}
+}
+
+// NotificationPreferencesRequest replaces a user's preferences. A reminder
+// lead of up to two weeks may be asked for.
+type NotificationPreferencesRequest struct {
+	Channel           NotifyChannel `json:"channel" validate:"required,oneof=email webhook none"`
+	WebhookURL        string        `json:"webhook_url" validate:"max=2048"`
+	ReminderLeadHours int           `json:"reminder_lead_hours" validate:"min=0,max=336"`
+}
+
+// Normalize trims the webhook URL and lower-cases the channel before
+// validation.
+func (r *NotificationPreferencesRequest) Normalize() {
+	r.Channel = NotifyChannel(strings.ToLower(strings.TrimSpace(string(r.Channel))))
+	r.WebhookURL = strings.TrimSpace(r.WebhookURL)
+}
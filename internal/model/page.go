@@ -0,0 +1,16 @@
+package model
+
+// PageRequest describes which slice of a list the client wants.
+// When Cursor is set it takes precedence over Offset (keyset pagination).
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// Page is the envelope returned by every list endpoint.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
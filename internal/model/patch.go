@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// The patches below are the changes the repositories' Update methods
+// apply. Only the repositories turn them into SQL.
+
+// UpdateBookPatch is a change to a book. Nil fields are left alone, so
+// Tags and CategoryIDs are replaced, or cleared with empty slices, only
+// when not nil. When Version is set the change only applies if the stored
+// version still equals it. Every change bumps the version.
+type UpdateBookPatch struct {
+	Title         *string
+	Author        *string
+	PublishedYear *int
+	ISBN          *string
+	TotalCopies   *int
+	CoverURL      *string
+	Tags          []string
+	CategoryIDs   []string
+	Version       *int
+}
+
+// UpdateUserPatch is a change to a user. Nil fields are left alone.
+// SuspendedUntil goes with Status: setting Status also sets when a
+// suspension ends, with nil meaning when an admin lifts it. When Version is
+// set the change only applies if the stored version still equals it.
+// Every change bumps the version.
+type UpdateUserPatch struct {
+	Username       *string
+	Email          *string
+	PasswordHash   *string
+	Role           *Role
+	Status         *string
+	SuspendedUntil *time.Time
+	Version        *int
+}
+
+// UpdateBookingPatch is a change to a booking. Nil fields are left alone;
+// ClearOfferExpiry drops the expiry of an offer that has been answered.
+// Moving the due date re-arms the booking's due-date reminder.
+type UpdateBookingPatch struct {
+	Status           *string
+	BorrowedAt       *time.Time
+	DueDate          *time.Time
+	ReturnedAt       *time.Time
+	OfferExpiresAt   *time.Time
+	ClearOfferExpiry bool
+}
+
+// Ptr returns a pointer to v, for filling in patches.
+func Ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// FavoritesListName names the favorites list made for a user on first use.
+const FavoritesListName = "Favorites"
+
+// ReadingList is a user's own list of books, such as "to read". Each user
+// has at most one Favorites list.
+type ReadingList struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id,omitempty"`
+	Name      string `json:"name"`
+	Favorites bool   `json:"favorites"`
+	// ShareToken is set while the list is shared: anyone with it can read
+	// the list at ShareURL. Neither is shown to anyone but the owner.
+	ShareToken string            `json:"-"`
+	ShareURL   string            `json:"share_url,omitempty"`
+	Books      []ReadingListBook `json:"books"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// ReadingListBook is a book on a list, with its current availability and
+// when it was added.
+type ReadingListBook struct {
+	Book
+	AddedAt time.Time `json:"added_at"`
+}
+
+// ReadingListRequest creates or renames a list.
+type ReadingListRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *ReadingListRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+}
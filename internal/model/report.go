@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// OverdueReport lists the loans still out past their due date, grouped by
+// borrower, most owed first. Fines are in cents of Currency, as the fine
+// policy in force now would price the loans if returned today.
+type OverdueReport struct {
+	GeneratedAt     time.Time     `json:"generated_at"`
+	FineGraceDays   int           `json:"fine_grace_days"`
+	FinePerDayCents int           `json:"fine_per_day_cents"`
+	Currency        string        `json:"currency"`
+	Borrowers       []OverdueUser `json:"borrowers"`
+	TotalLoans      int           `json:"total_loans"`
+	TotalFineCents  int           `json:"total_fine_cents"`
+}
+
+// OverdueUser is a borrower's overdue loans and the fines they have
+// accrued.
+type OverdueUser struct {
+	UserID    string        `json:"user_id"`
+	Username  string        `json:"username"`
+	Email     string        `json:"email"`
+	Loans     []OverdueLoan `json:"loans"`
+	FineCents int           `json:"fine_cents"`
+}
+
+// OverdueLoan is one overdue loan. DaysLate counts every started day past
+// the due date.
+type OverdueLoan struct {
+	BookingID string    `json:"booking_id"`
+	BranchID  string    `json:"branch_id"`
+	BookID    string    `json:"book_id"`
+	Title     string    `json:"title"`
+	DueDate   time.Time `json:"due_date"`
+	DaysLate  int       `json:"days_late"`
+	FineCents int       `json:"fine_cents"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Reservation is a place on a book's waitlist. When a copy comes back it is
+// offered to the earliest reservation, which is then removed.
+type Reservation struct {
+	ID     string `json:"id"`
+	BookID string `json:"book_id"`
+	UserID string `json:"user_id"`
+	// Position is 1 for the next user to be offered a copy.
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ReserveBookRequest struct {
+	BookID string `json:"book_id" validate:"required"`
+}
+
+// Normalize trims surrounding whitespace before validation.
+func (r *ReserveBookRequest) Normalize() {
+	r.BookID = strings.TrimSpace(r.BookID)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Review is a user's rating of a book they have borrowed and returned.
+type Review struct {
+	ID       string `json:"id"`
+	BookID   string `json:"book_id"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	// Rating is from 1 to 5.
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReviewFilter narrows a review listing. Empty fields don't filter.
+type ReviewFilter struct {
+	BookID string
+	UserID string
+}
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" validate:"min=1,max=5"`
+	Text   string `json:"text" validate:"max=2000"`
+}
+
+// Normalize trims surrounding whitespace from the text.
+func (r *CreateReviewRequest) Normalize() {
+	r.Text = strings.TrimSpace(r.Text)
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Session is one login, with the device and address it was made from.
+// Refreshing a token extends its session rather than starting a new one.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the token the list was requested with.
+	Current bool `json:"current"`
+}
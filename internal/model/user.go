@@ -1,35 +1,148 @@
 package model
 
-import "time"
+import (
+    "slices"
+    "strings"
+    "time"
+)
+
+// User statuses. A suspended user can't log in or borrow books.
+const (
+    UserStatusActive    = "active"
+    UserStatusSuspended = "suspended"
+)
+
+// Role is what a user may do. Roles are stored and compared lower-case.
+type Role string
+
+const (
+    RoleUser  Role = "user"
+    RoleAdmin Role = "admin"
+)
+
+// Roles lists every role, least privileged first.
+var Roles = []Role{RoleUser, RoleAdmin}
+
+// NormalizeRole trims surrounding whitespace and lower-cases s, so "ADMIN"
+// and "admin" are the same role. The result may still not be a valid role.
+func NormalizeRole(s string) Role {
+    return Role(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Valid reports whether r is one of Roles.
+func (r Role) Valid() bool {
+    return slices.Contains(Roles, r)
+}
+
+// RoleNames lists the roles for messages such as "role must be one of: user, admin".
+func RoleNames() string {
+    names := make([]string, len(Roles))
+    for i, r := range Roles {
+        names[i] = string(r)
+    }
+    return strings.Join(names, ", ")
+}
 
 type User struct {
-    ID        string    `json:"id"`
-    Username  string    `json:"username"`
-    Email     string    `json:"email"`
-    Password  string    `json:"-"` // Never expose in JSON
-    Role      string    `json:"role"` // ADMIN or USER
+    ID       string `json:"id"`
+    Username string `json:"username"`
+    Email    string `json:"email"`
+    Password string `json:"-"`      // Never expose in JSON
+    Role     Role   `json:"role"`   // user or admin
+    Status   string `json:"status"` // active or suspended
+    // SuspendedUntil ends a suspension; nil while suspended means until
+    // an admin lifts it.
+    SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+    // BranchID scopes the user to one branch; empty for global users.
+    BranchID  string    `json:"branch_id,omitempty"`
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
+    // Version goes up with every change to the user, and is sent as the
+    // ETag of the user's profile.
+    Version int `json:"version"`
+}
+
+// IsSuspended reports whether u is suspended at now. A suspension with an
+// end lapses on its own once that passes.
+func (u *User) IsSuspended(now time.Time) bool {
+    return u.Status == UserStatusSuspended && (u.SuspendedUntil == nil || now.Before(*u.SuspendedUntil))
+}
+
+// SuspendUserRequest suspends a user until Until, or until an admin lifts
+// the suspension when Until is omitted.
+type SuspendUserRequest struct {
+    Until *time.Time `json:"until,omitempty"`
 }
 
 type RegisterRequest struct {
     Username string `json:"username" validate:"required,min=3,max=50"`
     Email    string `json:"email" validate:"required,email"`
-    Password string `json:"password" validate:"required,min=8"`
+    Password string `json:"password" validate:"required,min=8,max=72"`
+    // InviteCode is required while registration is closed.
+    InviteCode string `json:"invite_code,omitempty" validate:"max=64"`
+}
+
+// Normalize trims surrounding whitespace and lower-cases the email before
+// validation.
+func (r *RegisterRequest) Normalize() {
+    r.Username = strings.TrimSpace(r.Username)
+    r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+    r.Password = strings.TrimSpace(r.Password)
+    r.InviteCode = strings.TrimSpace(r.InviteCode)
 }
 
 type RegisterResponse struct {
     ID       string `json:"id"`
     Username string `json:"username"`
     Email    string `json:"email"`
-    Role     string `json:"role"`
+    Role     Role   `json:"role"`
+    BranchID string `json:"branch_id,omitempty"`
 }
 
 type LoginRequest struct {
-    Username string `json:"username"`
-    Password string `json:"password"`
+    Username string `json:"username" validate:"required,max=50"`
+    Password string `json:"password" validate:"required,max=72"`
+}
+
+// Normalize trims the username; the password is used verbatim.
+func (r *LoginRequest) Normalize() {
+    r.Username = strings.TrimSpace(r.Username)
+}
+
+// ChangePasswordRequest is not normalized: passwords are used verbatim.
+type ChangePasswordRequest struct {
+    CurrentPassword string `json:"current_password" validate:"required"`
+    NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// AdminUpdateUserRequest changes another user's account. Omitted fields
+// are left alone.
+type AdminUpdateUserRequest struct {
+    Email  string `json:"email" validate:"omitempty,email"`
+    Role   Role   `json:"role" validate:"omitempty,max=20"`
+    Status string `json:"status" validate:"omitempty,max=20"`
+    // Version is the version the client last read. It is an alternative to
+    // the If-Match header for clients that cannot set headers.
+    Version *int `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+// Normalize trims surrounding whitespace and lower-cases email, role and
+// status.
+func (r *AdminUpdateUserRequest) Normalize() {
+    r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+    r.Role = NormalizeRole(string(r.Role))
+    r.Status = strings.ToLower(strings.TrimSpace(r.Status))
 }
 
 type UpdateUserRequest struct {
-    Email string `json:"email" validate:"email"`
+    Email string `json:"email" validate:"omitempty,email"`
+    // Version is the version the client last read. It is an alternative to
+    // the If-Match header for clients that cannot set headers.
+    Version *int `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+// Normalize trims surrounding whitespace and lower-cases the email before
+// validation.
+func (r *UpdateUserRequest) Normalize() {
+    r.Email = strings.ToLower(strings.TrimSpace(r.Email))
 }
\ No newline at end of file
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// How imported users get into their accounts.
+const (
+	// UserImportPassword gives each user a random temporary password.
+	UserImportPassword = "password"
+	// UserImportInvite gives each user a link to choose their own.
+	UserImportInvite = "invite"
+)
+
+// ImportUserRow is one user in a bulk import. Role defaults to user.
+type ImportUserRow struct {
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email"`
+	Role     Role   `json:"role" validate:"omitempty,max=20"`
+}
+
+// Normalize trims surrounding whitespace and lower-cases email and role
+// before validation.
+func (r *ImportUserRow) Normalize() {
+	r.Username = strings.TrimSpace(r.Username)
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Role = NormalizeRole(string(r.Role))
+}
+
+// UserImportOptions says how imported users get into their accounts. Mode
+// is UserImportPassword or UserImportInvite; with SendEmail the password
+// or link is emailed to each user instead of returned.
+type UserImportOptions struct {
+	Mode      string
+	SendEmail bool
+}
+
+// UserImportRowResult reports the outcome of one row of a user import. Row
+// is 1-based and counts data rows only. The temporary password or invite
+// link is only returned when it wasn't emailed.
+type UserImportRowResult struct {
+	Row               int    `json:"row"`
+	Status            string `json:"status"` // created or error
+	Username          string `json:"username,omitempty"`
+	UserID            string `json:"user_id,omitempty"`
+	TemporaryPassword string `json:"temporary_password,omitempty"`
+	InviteLink        string `json:"invite_link,omitempty"`
+	Emailed           bool   `json:"emailed,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// UserImportReport summarises a bulk user import.
+type UserImportReport struct {
+	Total   int                   `json:"total"`
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	Results []UserImportRowResult `json:"results"`
+}
+
+// UserInvitation lets an imported user choose their password through the
+// link sent to them.
+type UserInvitation struct {
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// AcceptInvitationRequest sets the password of an invited user. The
+// password is used verbatim.
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
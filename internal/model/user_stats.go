@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// UserStats sums up a user's borrowing: how much they borrow, how often on
+// time, what they owe and what they read most.
+type UserStats struct {
+	// TotalBorrowed counts every loan the user has had, returned or not.
+	TotalBorrowed     int `json:"total_borrowed"`
+	CurrentlyBorrowed int `json:"currently_borrowed"`
+	Overdue           int `json:"overdue"`
+	Returned          int `json:"returned"`
+	ReturnedOnTime    int `json:"returned_on_time"`
+	// OnTimeRate is ReturnedOnTime over Returned, from 0 to 1; null until
+	// a loan has been returned.
+	OnTimeRate *float64 `json:"on_time_rate"`
+	// UnpaidFines totals the user's unpaid fines in each currency.
+	UnpaidFines   []FineTotal `json:"unpaid_fines"`
+	TopAuthors    []StatCount `json:"top_authors"`
+	TopCategories []StatCount `json:"top_categories"`
+	// Monthly counts the loans started in each of the last months, oldest
+	// first, months without any included.
+	Monthly     []MonthCount `json:"monthly"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// FineTotal is the sum of Count fines in one currency.
+type FineTotal struct {
+	Currency    string `json:"currency"`
+	AmountCents int    `json:"amount_cents"`
+	Count       int    `json:"count"`
+}
+
+// StatCount is how many loans were of Name, an author or a category.
+type StatCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// MonthCount is how many loans were started in Month, as YYYY-MM in UTC.
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
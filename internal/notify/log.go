@@ -0,0 +1,22 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Log is a Provider for development that logs messages instead of sending
+// them. Bodies are logged at debug level.
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Send(ctx context.Context, m Message) error {
+	l.logger.InfoContext(ctx, "email not sent: log provider", "to", m.To, "subject", m.Subject)
+	l.logger.DebugContext(ctx, "email body", "to", m.To, "body", m.HTML)
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package notify renders and sends the emails the API sends to its users,
+// such as due-date reminders and waitlist offers, and posts reminders to
+// the webhooks of users who prefer them.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Templates the API sends. Each locale directory of a Registry may provide
+// any of them; the default locale must provide all of them.
+const (
+	TemplateVerifyEmail      = "verify_email"
+	TemplatePasswordReset    = "password_reset"
+	TemplateDueReminder      = "due_reminder"
+	TemplateReservationOffer = "reservation_offer"
+	TemplateOverdueReport    = "overdue_report"
+	TemplateConfirmNewEmail  = "confirm_new_email"
+	TemplateEmailChanged     = "email_changed"
+	TemplateInvitation       = "invitation"
+	TemplateExtensionDecided = "extension_decided"
+	TemplateNewDeviceLogin   = "new_device_login"
+)
+
+// Templates lists every template name above.
+var Templates = []string{TemplateVerifyEmail, TemplatePasswordReset, TemplateDueReminder, TemplateReservationOffer, TemplateOverdueReport, TemplateConfirmNewEmail, TemplateEmailChanged, TemplateInvitation, TemplateExtensionDecided, TemplateNewDeviceLogin}
+
+// VerifyEmail is the data for TemplateVerifyEmail.
+type VerifyEmail struct {
+	Username string
+	Link     string
+}
+
+// PasswordReset is the data for TemplatePasswordReset.
+type PasswordReset struct {
+	Username  string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// ConfirmNewEmail is the data for TemplateConfirmNewEmail, sent to the
+// address a user wants to change to.
+type ConfirmNewEmail struct {
+	Username  string
+	NewEmail  string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// EmailChanged is the data for TemplateEmailChanged, sent to the address a
+// user's email was changed from.
+type EmailChanged struct {
+	Username string
+	NewEmail string
+}
+
+// NewDeviceLogin is the data for TemplateNewDeviceLogin, sent when a user
+// logs in from an IP or user agent they hadn't logged in from before.
+type NewDeviceLogin struct {
+	Username   string
+	IP         string
+	UserAgent  string
+	LoggedInAt time.Time
+}
+
+// Invitation is the data for TemplateInvitation, sent to a user an admin
+// imported. It carries either a Link to choose a password, which works
+// until ExpiresAt, or a temporary Password.
+type Invitation struct {
+	Username  string
+	Link      string
+	Password  string
+	ExpiresAt time.Time
+}
+
+// DueReminder is the data for TemplateDueReminder. It is also posted to
+// the webhooks of users who chose them.
+type DueReminder struct {
+	Username string    `json:"username"`
+	Title    string    `json:"title"`
+	DueDate  time.Time `json:"due_date"`
+}
+
+// ReservationOffer is the data for TemplateReservationOffer.
+type ReservationOffer struct {
+	Username  string
+	Title     string
+	ExpiresAt time.Time
+}
+
+// ExtensionDecided is the data for TemplateExtensionDecided, sent when an
+// admin approves or denies a request to extend a loan. DueDate is the
+// loan's due date after the decision.
+type ExtensionDecided struct {
+	Username string
+	Title    string
+	Approved bool
+	DueDate  time.Time
+	Comment  string
+}
+
+// OverdueReport is the data for TemplateOverdueReport, sent to staff.
+// Fines are formatted amounts.
+type OverdueReport struct {
+	GeneratedAt time.Time
+	Loans       int
+	Borrowers   int
+	TotalFine   string
+	Rows        []OverdueRow
+}
+
+// OverdueRow is one overdue loan in an OverdueReport.
+type OverdueRow struct {
+	Username string
+	Email    string
+	Title    string
+	DueDate  time.Time
+	DaysLate int
+	Fine     string
+}
+
+// Message is a rendered email.
+type Message struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+}
+
+// Provider delivers rendered messages.
+type Provider interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Notifier renders templates and hands the result to a provider, or posts
+// their data to webhooks.
+type Notifier struct {
+	templates *Registry
+	provider  Provider
+	from      string
+	webhooks  *http.Client
+	poster    Poster
+}
+
+// New returns a Notifier sending from the given address through provider
+// and posting webhooks with NewWebhookClient.
+func New(templates *Registry, provider Provider, from string) *Notifier {
+	return &Notifier{templates: templates, provider: provider, from: from, webhooks: NewWebhookClient(webhookTimeout)}
+}
+
+// Send renders the named template for locale, falling back to the
+// registry's default locale, and sends it to the address to.
+func (n *Notifier) Send(ctx context.Context, to, locale, template string, data any) error {
+	subject, body, err := n.templates.Render(locale, template, data)
+	if err != nil {
+		return err
+	}
+	if err := n.provider.Send(ctx, Message{From: n.from, To: to, Subject: subject, HTML: body}); err != nil {
+		return fmt.Errorf("send %s email: %w", template, err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinTemplates_RenderEveryTemplate(t *testing.T) {
+	r, err := NewRegistry("en", Builtin())
+	require.NoError(t, err)
+
+	due := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	data := map[string]any{
+		TemplateVerifyEmail:      VerifyEmail{Username: "ada", Link: "https://library.example.com/verify?t=x"},
+		TemplatePasswordReset:    PasswordReset{Username: "ada", Link: "https://library.example.com/reset?t=x", ExpiresAt: due},
+		TemplateConfirmNewEmail:  ConfirmNewEmail{Username: "ada", NewEmail: "ada@example.org", Link: "https://library.example.com/v1/auth/confirm-email?token=x", ExpiresAt: due},
+		TemplateEmailChanged:     EmailChanged{Username: "ada", NewEmail: "ada@example.org"},
+		TemplateInvitation:       Invitation{Username: "ada", Link: "https://library.example.com/v1/auth/accept-invitation?token=x", ExpiresAt: due},
+		TemplateDueReminder:      DueReminder{Username: "ada", Title: "Dune", DueDate: due},
+		TemplateReservationOffer: ReservationOffer{Username: "ada", Title: "Dune", ExpiresAt: due},
+		TemplateExtensionDecided: ExtensionDecided{Username: "ada", Title: "Dune", Approved: true, DueDate: due, Comment: "Enjoy"},
+		TemplateNewDeviceLogin:   NewDeviceLogin{Username: "ada", IP: "203.0.113.7", UserAgent: "Firefox", LoggedInAt: due},
+		TemplateOverdueReport: OverdueReport{GeneratedAt: due, Loans: 1, Borrowers: 1, TotalFine: "0.75", Rows: []OverdueRow{
+			{Username: "ada", Email: "ada@example.com", Title: "Dune", DueDate: due, DaysLate: 3, Fine: "0.75"},
+		}},
+	}
+	for _, name := range Templates {
+		subject, body, err := r.Render("en", name, data[name])
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, subject, name)
+		assert.Contains(t, body, "ada", name)
+	}
+
+	subject, _, err := r.Render("", TemplateDueReminder, data[TemplateDueReminder])
+	require.NoError(t, err)
+	assert.Equal(t, `"Dune" is due back Monday 2 March`, subject)
+}
+
+func TestRegistry_FallsBackFromRegionToLanguageToDefault(t *testing.T) {
+	overrides := fstest.MapFS{
+		"de/due_reminder.html":    {Data: []byte(`{{define "subject"}}Bitte zurückgeben{{end}}{{define "body"}}de{{end}}`)},
+		"pt-BR/due_reminder.html": {Data: []byte(`{{define "subject"}}Devolva{{end}}{{define "body"}}pt-br{{end}}`)},
+	}
+	r, err := NewRegistry("en", Builtin(), overrides)
+	require.NoError(t, err)
+	data := DueReminder{Username: "ada", Title: "Dune", DueDate: time.Now()}
+
+	cases := map[string]string{"pt_BR": "pt-br", "de-AT": "de", "DE": "de", "fr": "ada"}
+	for locale, want := range cases {
+		_, body, err := r.Render(locale, TemplateDueReminder, data)
+		require.NoError(t, err, locale)
+		assert.Contains(t, body, want, locale)
+	}
+
+	subject, _, err := r.Render("de", TemplateDueReminder, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Bitte zurückgeben", subject)
+}
+
+func TestRegistry_LaterFileSystemsOverrideEarlierOnes(t *testing.T) {
+	overrides := fstest.MapFS{
+		"en/reservation_offer.html": {Data: []byte(`{{define "subject"}}Come get {{.Title}}{{end}}{{define "body"}}<b>{{.Title}}</b>{{end}}`)},
+	}
+	r, err := NewRegistry("en", Builtin(), overrides)
+	require.NoError(t, err)
+
+	subject, body, err := r.Render("en", TemplateReservationOffer, ReservationOffer{Title: "Tom & <Jerry>"})
+	require.NoError(t, err)
+	assert.Equal(t, "Come get Tom & <Jerry>", subject, "subjects are plain text")
+	assert.Equal(t, "<b>Tom &amp; &lt;Jerry&gt;</b>", body, "bodies are escaped")
+}
+
+func TestNewRegistry_RejectsIncompleteTemplates(t *testing.T) {
+	_, err := NewRegistry("fr", Builtin())
+	assert.ErrorContains(t, err, "fr/verify_email.html is missing")
+
+	_, err = NewRegistry("en", Builtin(), fstest.MapFS{
+		"en/due_reminder.html": {Data: []byte(`{{define "body"}}no subject{{end}}`)},
+	})
+	assert.ErrorContains(t, err, "must define subject and body")
+}
+
+func TestRender_UnknownTemplateOrMissingField(t *testing.T) {
+	r, err := NewRegistry("en", Builtin())
+	require.NoError(t, err)
+
+	_, _, err = r.Render("en", "welcome", nil)
+	assert.ErrorContains(t, err, `unknown email template "welcome"`)
+
+	_, _, err = r.Render("en", TemplateDueReminder, map[string]any{"Username": "ada"})
+	assert.Error(t, err)
+}
+
+type recordingProvider struct {
+	sent []Message
+}
+
+func (p *recordingProvider) Send(_ context.Context, m Message) error {
+	p.sent = append(p.sent, m)
+	return nil
+}
+
+func TestNotifier_Send(t *testing.T) {
+	r, err := NewRegistry("en", Builtin())
+	require.NoError(t, err)
+	p := &recordingProvider{}
+	n := New(r, p, "Library <library@example.com>")
+
+	err = n.Send(context.Background(), "ada@example.com", "en-GB", TemplateReservationOffer, ReservationOffer{Username: "ada", Title: "Dune", ExpiresAt: time.Now()})
+	require.NoError(t, err)
+	require.Len(t, p.sent, 1)
+	assert.Equal(t, "Library <library@example.com>", p.sent[0].From)
+	assert.Equal(t, "ada@example.com", p.sent[0].To)
+	assert.Equal(t, `"Dune" is ready for you`, p.sent[0].Subject)
+}
+
+func TestNotifier_Post(t *testing.T) {
+	r, err := NewRegistry("en", Builtin())
+	require.NoError(t, err)
+	var got Webhook
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	due := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	data := DueReminder{Username: "ada", Title: "Dune", DueDate: due}
+
+	n := New(r, &recordingProvider{}, "library@example.com")
+	err = n.Post(context.Background(), srv.URL, TemplateDueReminder, data)
+	require.ErrorIs(t, err, errPrivateAddress, "the default client refuses loopback addresses")
+
+	n.SetWebhookClient(srv.Client())
+	require.NoError(t, n.Post(context.Background(), srv.URL, TemplateDueReminder, data))
+	assert.Equal(t, TemplateDueReminder, got.Type)
+	assert.Equal(t, map[string]any{"username": "ada", "title": "Dune", "due_date": "2026-03-02T17:00:00Z"}, got.Data)
+
+	status = http.StatusGone
+	require.ErrorContains(t, n.Post(context.Background(), srv.URL, TemplateDueReminder, data), "410")
+}
+
+func TestEncodeMessage_EncodesSubjectAndBody(t *testing.T) {
+	msg := string(encodeMessage(Message{
+		From:    "Library <library@example.com>",
+		To:      "ada@example.com",
+		Subject: "Réservation\r\nBcc: evil@example.com",
+		HTML:    "<p>café</p>",
+	}, time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)))
+
+	headers, body, ok := strings.Cut(msg, "\r\n\r\n")
+	require.True(t, ok)
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+	assert.Contains(t, headers, "Date: Mon, 02 Mar 2026 17:00:00 +0000")
+	assert.Equal(t, "<p>caf=C3=A9</p>", body)
+}
+
+// fakeSMTPServer accepts one connection, answers the commands SMTP.Send
+// issues and returns what was sent after DATA.
+func fakeSMTPServer(t *testing.T) (addr string, data <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(line string) {
+			rw.WriteString(line + "\r\n")
+			rw.Flush()
+		}
+		reply("220 fake ESMTP")
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 fake")
+			case "DATA":
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := rw.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				out <- msg.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSMTP_Send(t *testing.T) {
+	addr, data := fakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	p := NewSMTP(host, 0, "", "")
+	p.addr = net.JoinHostPort(host, port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = p.Send(ctx, Message{From: "Library <library@example.com>", To: "ada@example.com", Subject: "Hello", HTML: "<p>hi</p>"})
+	require.NoError(t, err)
+
+	msg := <-data
+	assert.Contains(t, msg, "To: ada@example.com\r\n")
+	assert.Contains(t, msg, "Subject: Hello\r\n")
+	assert.Contains(t, msg, "<p>hi</p>")
+}
+
+func TestNewSES_UsesRegionalSMTPEndpoint(t *testing.T) {
+	p := NewSES("eu-west-1", "user", "pass")
+	assert.Equal(t, "email-smtp.eu-west-1.amazonaws.com:587", p.addr)
+	assert.NotNil(t, p.auth)
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTP sends messages through a mail server, upgrading the connection with
+// STARTTLS whenever the server offers it.
+type SMTP struct {
+	host string
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTP returns an SMTP provider for host:port. Without a username the
+// server is used unauthenticated; credentials are only sent over TLS or to
+// localhost.
+func NewSMTP(host string, port int, username, password string) *SMTP {
+	s := &SMTP{host: host, addr: net.JoinHostPort(host, strconv.Itoa(port))}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// NewSES sends through the SMTP interface of Amazon SES in region. The
+// username and password are SES SMTP credentials, not an access key.
+func NewSES(region, username, password string) *SMTP {
+	return NewSMTP("email-smtp."+region+".amazonaws.com", 587, username, password)
+}
+
+// Send delivers m in one connection, giving up when ctx is done.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(encodeMessage(m, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// encodeMessage formats m as a MIME email. The subject is Q-encoded and the
+// body quoted-printable, so neither can break out of its part. The
+// addresses were already checked for line breaks by the SMTP client.
+func encodeMessage(m Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(m.HTML))
+	qp.Close()
+	return b.Bytes()
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// builtin holds the templates shipped with the API, one directory per
+// locale.
+//
+//go:embed templates
+var builtin embed.FS
+
+// Builtin returns the shipped templates as a file system for NewRegistry.
+func Builtin() fs.FS {
+	sub, _ := fs.Sub(builtin, "templates")
+	return sub
+}
+
+// Registry holds the parsed templates by locale and name. Each template file
+// is <locale>/<name>.html and defines a "subject" and a "body" template.
+type Registry struct {
+	defaultLocale string
+	templates     map[string]map[string]*template.Template
+}
+
+// NewRegistry parses the templates in each of fsys. A template in a later
+// file system replaces the one with the same locale and name in an earlier
+// one, so deployments can override the builtin wording. Every name in
+// Templates must exist for defaultLocale.
+func NewRegistry(defaultLocale string, fsys ...fs.FS) (*Registry, error) {
+	r := &Registry{defaultLocale: normalizeLocale(defaultLocale), templates: map[string]map[string]*template.Template{}}
+	for _, f := range fsys {
+		files, err := fs.Glob(f, "*/*.html")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			b, err := fs.ReadFile(f, file)
+			if err != nil {
+				return nil, err
+			}
+			t, err := template.New(file).Option("missingkey=error").Parse(string(b))
+			if err != nil {
+				return nil, fmt.Errorf("parse email template %s: %w", file, err)
+			}
+			if t.Lookup("subject") == nil || t.Lookup("body") == nil {
+				return nil, fmt.Errorf("email template %s must define subject and body", file)
+			}
+			locale := normalizeLocale(path.Dir(file))
+			if r.templates[locale] == nil {
+				r.templates[locale] = map[string]*template.Template{}
+			}
+			r.templates[locale][strings.TrimSuffix(path.Base(file), ".html")] = t
+		}
+	}
+	for _, name := range Templates {
+		if r.templates[r.defaultLocale][name] == nil {
+			return nil, fmt.Errorf("email template %s/%s.html is missing", r.defaultLocale, name)
+		}
+	}
+	return r, nil
+}
+
+// Render returns the subject and HTML body of the named template. It uses
+// locale ("pt-BR"), then its language ("pt"), then the default locale,
+// whichever has the template first.
+func (r *Registry) Render(locale, name string, data any) (subject, body string, err error) {
+	t := r.lookup(locale, name)
+	if t == nil {
+		return "", "", fmt.Errorf("unknown email template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", fmt.Errorf("render %s subject: %w", name, err)
+	}
+	// The subject is a header, not HTML, so undo the escaping.
+	subject = strings.Join(strings.Fields(html.UnescapeString(buf.String())), " ")
+
+	buf.Reset()
+	if err := t.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", fmt.Errorf("render %s body: %w", name, err)
+	}
+	return subject, buf.String(), nil
+}
+
+func (r *Registry) lookup(locale, name string) *template.Template {
+	locale = normalizeLocale(locale)
+	lang, _, _ := strings.Cut(locale, "-")
+	for _, l := range []string{locale, lang, r.defaultLocale} {
+		if t := r.templates[l][name]; t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// normalizeLocale lowercases a locale and spells "pt_BR" as "pt-br".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
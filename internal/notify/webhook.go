@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// webhookTimeout bounds each webhook delivery, including its response.
+const webhookTimeout = 10 * time.Second
+
+// errPrivateAddress is returned for webhooks on loopback, private and
+// link-local addresses, which users have no business reaching through the
+// API's network.
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// Webhook is the JSON body posted to a user's webhook: the template's data
+// in place of the rendered email.
+type Webhook struct {
+	Type   string    `json:"type"`
+	SentAt time.Time `json:"sent_at"`
+	Data   any       `json:"data"`
+}
+
+// NewWebhookClient returns the client Notifier posts webhooks with by
+// default. It only connects to public addresses, gives up after timeout
+// and sends the request ID of the context it posts for.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicOnly}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &requestid.Transport{
+			Base: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		},
+	}
+}
+
+// publicOnly refuses connections to addresses that aren't public, after
+// DNS resolution so a public name can't point inside the network.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// SetWebhookClient replaces the client webhooks are posted with.
+func (n *Notifier) SetWebhookClient(c *http.Client) {
+	n.webhooks = c
+}
+
+// Delivery is a webhook ready to post: the URL and the JSON body.
+type Delivery struct {
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+}
+
+// Poster delivers webhooks. Notifier is one, posting them straight away.
+type Poster interface {
+	Deliver(ctx context.Context, d Delivery) error
+}
+
+// SetPoster hands the webhooks Post builds to p, such as a job queue, in
+// place of posting them straight away.
+func (n *Notifier) SetPoster(p Poster) {
+	n.poster = p
+}
+
+// Post sends the named template's data as a Webhook to url, through the
+// Poster set with SetPoster if any.
+func (n *Notifier) Post(ctx context.Context, url, template string, data any) error {
+	body, err := json.Marshal(Webhook{Type: template, SentAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return fmt.Errorf("marshal %s webhook: %w", template, err)
+	}
+	d := Delivery{URL: url, Body: body}
+	if n.poster != nil {
+		return n.poster.Deliver(ctx, d)
+	}
+	return n.Deliver(ctx, d)
+}
+
+// Deliver posts d. Any response other than a 2xx fails the delivery.
+func (n *Notifier) Deliver(ctx context.Context, d Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.webhooks.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
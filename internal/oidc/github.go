@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+// GitHub logs users in with their GitHub account. GitHub speaks plain OAuth
+// 2.0 rather than OpenID Connect, so the account and its verified emails
+// are read from the REST API with the access token.
+type GitHub struct {
+	client *http.Client
+	oauth  *oauth2.Config
+	apiURL string
+	groups bool
+}
+
+// NewGitHub returns the provider. With cfg.Groups it also asks for the
+// read:org scope, to read the account's organizations and teams.
+func NewGitHub(client *http.Client, cfg Config) *GitHub {
+	scopes := []string{"read:user", "user:email"}
+	if cfg.Groups {
+		scopes = append(scopes, "read:org")
+	}
+	return &GitHub{
+		client: client,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     github.Endpoint,
+			Scopes:       scopes,
+		},
+		apiURL: githubAPIURL,
+		groups: cfg.Groups,
+	}
+}
+
+// AuthCodeURL ignores nonce; GitHub issues no ID token to carry it.
+func (g *GitHub) AuthCodeURL(state, _ string) string {
+	return g.oauth.AuthCodeURL(state)
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+type githubOrg struct {
+	Login string `json:"login"`
+}
+
+type githubTeam struct {
+	Slug         string    `json:"slug"`
+	Organization githubOrg `json:"organization"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (g *GitHub) Identify(ctx context.Context, code, _ string) (model.ExternalIdentity, error) {
+	tok, err := exchange(ctx, g.oauth, g.client, code)
+	if err != nil {
+		return model.ExternalIdentity{}, err
+	}
+	client := g.oauth.Client(context.WithValue(ctx, oauth2.HTTPClient, g.client), tok)
+
+	var user githubUser
+	if err := g.get(ctx, client, "/user", &user); err != nil {
+		return model.ExternalIdentity{}, err
+	}
+	if user.ID == 0 {
+		return model.ExternalIdentity{}, fmt.Errorf("%w: github user has no id", ErrIdentity)
+	}
+	var emails []githubEmail
+	if err := g.get(ctx, client, "/user/emails", &emails); err != nil {
+		return model.ExternalIdentity{}, err
+	}
+
+	id := model.ExternalIdentity{
+		Provider: "github",
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Username: user.Login,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email, id.EmailVerified = e.Email, e.Verified
+		}
+	}
+	if g.groups {
+		if id.Groups, err = g.userGroups(ctx, client); err != nil {
+			return model.ExternalIdentity{}, err
+		}
+	}
+	return id, nil
+}
+
+// githubPageSize is the most GitHub returns per page. Groups past the
+// first page of organizations or teams are not read.
+const githubPageSize = "100"
+
+// userGroups returns the account's organizations as "org" and its teams as
+// "org/team", lower-cased like GitHub compares them.
+func (g *GitHub) userGroups(ctx context.Context, client *http.Client) ([]string, error) {
+	var orgs []githubOrg
+	if err := g.get(ctx, client, "/user/orgs?per_page="+githubPageSize, &orgs); err != nil {
+		return nil, err
+	}
+	var teams []githubTeam
+	if err := g.get(ctx, client, "/user/teams?per_page="+githubPageSize, &teams); err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(orgs)+len(teams))
+	for _, o := range orgs {
+		groups = append(groups, strings.ToLower(o.Login))
+	}
+	for _, t := range teams {
+		groups = append(groups, strings.ToLower(t.Organization.Login+"/"+t.Slug))
+	}
+	return groups, nil
+}
+
+func (g *GitHub) get(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github %s returned HTTP %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode github %s: %w", path, err)
+	}
+	return nil
+}
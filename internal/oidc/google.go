@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"golang.org/x/oauth2"
+)
+
+const googleIssuer = "https://accounts.google.com"
+
+// Google logs users in with their Google account, verifying the ID token
+// Google returns against its published keys.
+type Google struct {
+	client   *http.Client
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewGoogle discovers Google's endpoints and keys, so it needs to reach
+// accounts.google.com.
+func NewGoogle(ctx context.Context, client *http.Client, cfg Config) (*Google, error) {
+	p, err := gooidc.NewProvider(gooidc.ClientContext(ctx, client), googleIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover google endpoints: %w", err)
+	}
+	verifier := p.Verifier(&gooidc.Config{ClientID: cfg.ClientID})
+	return newGoogle(client, cfg, p.Endpoint(), verifier), nil
+}
+
+func newGoogle(client *http.Client, cfg Config, endpoint oauth2.Endpoint, verifier *gooidc.IDTokenVerifier) *Google {
+	return &Google{
+		client: client,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoint,
+			Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: verifier,
+	}
+}
+
+func (g *Google) AuthCodeURL(state, nonce string) string {
+	return g.oauth.AuthCodeURL(state, gooidc.Nonce(nonce))
+}
+
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func (g *Google) Identify(ctx context.Context, code, nonce string) (model.ExternalIdentity, error) {
+	tok, err := exchange(ctx, g.oauth, g.client, code)
+	if err != nil {
+		return model.ExternalIdentity{}, err
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return model.ExternalIdentity{}, fmt.Errorf("%w: google sent no ID token", ErrIdentity)
+	}
+	idToken, err := g.verifier.Verify(gooidc.ClientContext(ctx, g.client), raw)
+	if err != nil {
+		return model.ExternalIdentity{}, fmt.Errorf("%w: %v", ErrIdentity, err)
+	}
+	if idToken.Nonce != nonce {
+		return model.ExternalIdentity{}, fmt.Errorf("%w: ID token nonce mismatch", ErrIdentity)
+	}
+
+	var claims googleClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return model.ExternalIdentity{}, fmt.Errorf("%w: %v", ErrIdentity, err)
+	}
+	return model.ExternalIdentity{
+		Provider:      "google",
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Username:      localPart(claims.Email),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+var testConfig = Config{ClientID: "client-id", ClientSecret: "client-secret", RedirectURL: "https://library.example.com/v1/auth/oidc/test/callback"}
+
+func TestGoogle_Identify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idToken := func(nonce string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            googleIssuer,
+			"aud":            testConfig.ClientID,
+			"sub":            "1234567890",
+			"email":          "Alice@example.com",
+			"email_verified": true,
+			"nonce":          nonce,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		})
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken(r.Form.Get("code")),
+		})
+	}))
+	defer srv.Close()
+
+	verifier := gooidc.NewVerifier(googleIssuer, &gooidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}},
+		&gooidc.Config{ClientID: testConfig.ClientID})
+	g := newGoogle(srv.Client(), testConfig, oauth2.Endpoint{TokenURL: srv.URL, AuthURL: "https://accounts.example/auth"}, verifier)
+
+	require.Contains(t, g.AuthCodeURL("the-state", "the-nonce"), "nonce=the-nonce")
+
+	// The fake token endpoint puts the code in the ID token's nonce.
+	id, err := g.Identify(context.Background(), "the-nonce", "the-nonce")
+	require.NoError(t, err)
+	require.Equal(t, model.ExternalIdentity{
+		Provider: "google", Subject: "1234567890", Email: "Alice@example.com", EmailVerified: true, Username: "Alice",
+	}, id)
+
+	_, err = g.Identify(context.Background(), "replayed", "the-nonce")
+	require.ErrorIs(t, err, ErrIdentity)
+}
+
+func TestGitHub_Identify(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "gh-token", "token_type": "bearer"}`))
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"id": 42, "login": "octocat"}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true}]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	g := NewGitHub(srv.Client(), testConfig)
+	g.oauth.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/login/oauth/authorize", TokenURL: srv.URL + "/login/oauth/access_token"}
+	g.apiURL = srv.URL
+
+	id, err := g.Identify(context.Background(), "code", "")
+	require.NoError(t, err)
+	require.Equal(t, model.ExternalIdentity{
+		Provider: "github", Subject: "42", Email: "octocat@example.com", EmailVerified: true, Username: "octocat",
+	}, id)
+}
+
+func TestGitHub_IdentifyReadsGroupsWhenAsked(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "gh-token", "token_type": "bearer"}`))
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 42, "login": "octocat"}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"email": "octocat@example.com", "primary": true, "verified": true}]`))
+	})
+	mux.HandleFunc("/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"login": "Acme"}]`))
+	})
+	mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"slug": "librarians", "organization": {"login": "Acme"}}]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cfg := testConfig
+	cfg.Groups = true
+	g := NewGitHub(srv.Client(), cfg)
+	g.oauth.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/login/oauth/authorize", TokenURL: srv.URL + "/login/oauth/access_token"}
+	g.apiURL = srv.URL
+
+	require.Contains(t, g.AuthCodeURL("state", ""), "read%3Aorg")
+	id, err := g.Identify(context.Background(), "code", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme", "acme/librarians"}, id.Groups)
+}
@@ -0,0 +1,56 @@
+// Package oidc logs users in through external identity providers (Google
+// and GitHub) with the OAuth 2.0 authorization code flow, and reports the
+// account that logged in.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"golang.org/x/oauth2"
+)
+
+// Config is the OAuth client registered with a provider.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the API's callback, which the provider sends the user
+	// back to with the authorization code.
+	RedirectURL string
+	// Groups asks the provider for the groups the account is in, which
+	// may need the user to grant more access.
+	Groups bool
+}
+
+// Provider is an identity provider.
+type Provider interface {
+	// AuthCodeURL returns the provider's login page. It redirects back to
+	// the callback with state and an authorization code.
+	AuthCodeURL(state, nonce string) string
+	// Identify exchanges the authorization code for the account that
+	// logged in. nonce is the one given to AuthCodeURL.
+	Identify(ctx context.Context, code, nonce string) (model.ExternalIdentity, error)
+}
+
+// ErrIdentity means the provider's answer couldn't be trusted or used, such
+// as an ID token that fails verification.
+var ErrIdentity = errors.New("identity provider returned an unusable identity")
+
+// exchange trades code for a token, sending requests through client.
+func exchange(ctx context.Context, cfg *oauth2.Config, client *http.Client, code string) (*oauth2.Token, error) {
+	tok, err := cfg.Exchange(context.WithValue(ctx, oauth2.HTTPClient, client), code)
+	if err != nil {
+		return nil, fmt.Errorf("exchange authorization code: %w", err)
+	}
+	return tok, nil
+}
+
+// localPart returns the part of an email address before the @.
+func localPart(email string) string {
+	name, _, _ := strings.Cut(email, "@")
+	return name
+}
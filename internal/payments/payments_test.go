@@ -0,0 +1,81 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripe_CreateIntent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/payment_intents", r.URL.Path)
+		require.Equal(t, "Bearer sk_test_x", r.Header.Get("Authorization"))
+		require.Equal(t, "fine-1", r.Header.Get("Idempotency-Key"))
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("amount") != "250" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "Invalid amount"}}`))
+			return
+		}
+		require.Equal(t, "usd", r.PostForm.Get("currency"))
+		require.Equal(t, "f1", r.PostForm.Get("metadata[fine_id]"))
+		_, _ = w.Write([]byte(`{"id": "pi_1", "client_secret": "pi_1_secret_2"}`))
+	}))
+	defer srv.Close()
+
+	s := NewStripe(srv.Client(), "sk_test_x", "whsec")
+	s.baseURL = srv.URL
+
+	intent, err := s.CreateIntent(context.Background(), IntentRequest{
+		AmountCents: 250, Currency: "usd", Metadata: map[string]string{"fine_id": "f1"}, IdempotencyKey: "fine-1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Intent{ID: "pi_1", ClientSecret: "pi_1_secret_2"}, intent)
+
+	_, err = s.CreateIntent(context.Background(), IntentRequest{AmountCents: 1, Currency: "usd", IdempotencyKey: "fine-1"})
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadRequest, statusErr.Code)
+	require.Equal(t, "Invalid amount", statusErr.Message)
+}
+
+func stripeSignature(secret string, at time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", at.Unix(), payload)
+	return fmt.Sprintf("t=%d,v1=%s", at.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestStripe_ParseEvent(t *testing.T) {
+	now := time.Now()
+	s := NewStripe(http.DefaultClient, "sk_test_x", "whsec")
+	s.now = func() time.Time { return now }
+	payload := []byte(`{"type": "payment_intent.succeeded", "data": {"object": {"id": "pi_1", "amount": 250, "currency": "usd"}}}`)
+
+	header := http.Header{}
+	header.Set(stripeSignatureHeader, stripeSignature("whsec", now, payload))
+	e, err := s.ParseEvent(payload, header)
+	require.NoError(t, err)
+	require.Equal(t, &Event{Kind: EventSucceeded, IntentID: "pi_1", AmountCents: 250, Currency: "usd"}, e)
+
+	header.Set(stripeSignatureHeader, stripeSignature("other", now, payload))
+	_, err = s.ParseEvent(payload, header)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	header.Set(stripeSignatureHeader, stripeSignature("whsec", now.Add(-time.Hour), payload))
+	_, err = s.ParseEvent(payload, header)
+	require.ErrorIs(t, err, ErrInvalidSignature, "old requests can't be replayed")
+
+	other := []byte(`{"type": "charge.refunded", "data": {"object": {"id": "ch_1"}}}`)
+	header.Set(stripeSignatureHeader, stripeSignature("whsec", now, other))
+	e, err = s.ParseEvent(other, header)
+	require.NoError(t, err)
+	require.Empty(t, e.Kind)
+}
@@ -0,0 +1,73 @@
+// Package payments takes payments through an external payment provider
+// (Stripe). A payment is started with an intent, completed by the client
+// with the provider's SDK and confirmed by the provider's webhook.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrInvalidSignature means a webhook request wasn't signed by the
+// provider, or was signed too long ago.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// IntentRequest asks for a payment of AmountCents in Currency.
+type IntentRequest struct {
+	AmountCents int
+	Currency    string
+	Description string
+	// Metadata is kept with the payment by the provider.
+	Metadata map[string]string
+	// IdempotencyKey makes a retried request return the intent the first
+	// one created.
+	IdempotencyKey string
+}
+
+// Intent is a payment the provider is ready to take. The client completes
+// it with ClientSecret.
+type Intent struct {
+	ID           string
+	ClientSecret string
+}
+
+// Kinds of Event.
+const (
+	EventSucceeded = "succeeded"
+	EventFailed    = "failed"
+)
+
+// Event is what a webhook reported about an intent. Kind is empty for
+// events the API doesn't act on.
+type Event struct {
+	Kind        string
+	IntentID    string
+	AmountCents int
+	Currency    string
+}
+
+// Provider is a payment provider.
+type Provider interface {
+	// Name identifies the provider in stored payments.
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// ParseEvent checks a webhook request's signature and reads its event,
+	// returning ErrInvalidSignature when it isn't the provider's.
+	ParseEvent(payload []byte, header http.Header) (*Event, error)
+}
+
+// StatusError is an unexpected HTTP response from a provider.
+type StatusError struct {
+	Provider string
+	Code     int
+	Message  string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s returned HTTP %d", e.Provider, e.Code)
+	}
+	return fmt.Sprintf("%s returned HTTP %d: %s", e.Provider, e.Code, e.Message)
+}
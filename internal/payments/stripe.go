@@ -0,0 +1,154 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeURL = "https://api.stripe.com"
+
+// stripeSignatureHeader signs Stripe's webhook requests, as
+// "t=<unix time>,v1=<hex HMAC-SHA256>".
+const stripeSignatureHeader = "Stripe-Signature"
+
+// stripeTolerance is how old a signed webhook request may be, so a
+// captured one can't be replayed later.
+const stripeTolerance = 5 * time.Minute
+
+// Stripe takes payments with Stripe payment intents.
+type Stripe struct {
+	client        *http.Client
+	baseURL       string
+	secretKey     string
+	webhookSecret []byte
+	now           func() time.Time
+}
+
+// NewStripe returns a provider using the API key secretKey that checks
+// webhooks against the endpoint's signing secret webhookSecret.
+func NewStripe(client *http.Client, secretKey, webhookSecret string) *Stripe {
+	return &Stripe{client: client, baseURL: stripeURL, secretKey: secretKey, webhookSecret: []byte(webhookSecret), now: time.Now}
+}
+
+func (s *Stripe) Name() string { return "stripe" }
+
+func (s *Stripe) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	form := url.Values{
+		"amount":                             {strconv.Itoa(req.AmountCents)},
+		"currency":                           {req.Currency},
+		"automatic_payment_methods[enabled]": {"true"},
+	}
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+	for k, v := range req.Metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/v1/payment_intents", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.secretKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if req.IdempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.IdempotencyKey)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("stripe: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("stripe: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		return nil, &StatusError{Provider: "stripe", Code: resp.StatusCode, Message: failure.Error.Message}
+	}
+	var intent struct {
+		ID           string `json:"id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.Unmarshal(body, &intent); err != nil {
+		return nil, fmt.Errorf("stripe: decode payment intent: %w", err)
+	}
+	return &Intent{ID: intent.ID, ClientSecret: intent.ClientSecret}, nil
+}
+
+func (s *Stripe) ParseEvent(payload []byte, header http.Header) (*Event, error) {
+	if err := s.verify(payload, header.Get(stripeSignatureHeader)); err != nil {
+		return nil, err
+	}
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID       string `json:"id"`
+				Amount   int    `json:"amount"`
+				Currency string `json:"currency"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("stripe: decode event: %w", err)
+	}
+	e := &Event{IntentID: event.Data.Object.ID, AmountCents: event.Data.Object.Amount, Currency: event.Data.Object.Currency}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		e.Kind = EventSucceeded
+	case "payment_intent.payment_failed":
+		e.Kind = EventFailed
+	}
+	return e, nil
+}
+
+// verify checks a Stripe-Signature header: one of its v1 signatures must
+// be the HMAC of "<t>.<payload>", and t recent.
+func (s *Stripe) verify(payload []byte, header string) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := s.now().Sub(time.Unix(t, 0)); age > stripeTolerance || age < -stripeTolerance {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, s.webhookSecret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	want := mac.Sum(nil)
+	for _, sig := range signatures {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
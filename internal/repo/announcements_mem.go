@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memAnnouncementRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryAnnouncementRepo(s *MemoryStore) AnnouncementRepo {
+	return &memAnnouncementRepo{s: s}
+}
+
+func (r *memAnnouncementRepo) Create(ctx context.Context, a *model.Announcement) error {
+	defer r.s.lock(ctx)()
+	a.ID = uuid.New().String()
+	a.CreatedAt = time.Now().UTC()
+	a.UpdatedAt = a.CreatedAt
+	r.s.data.announcements[a.ID] = *a
+	return nil
+}
+
+func (r *memAnnouncementRepo) GetByID(ctx context.Context, id string) (*model.Announcement, error) {
+	defer r.s.lock(ctx)()
+	a, ok := r.s.data.announcements[id]
+	if !ok {
+		return nil, errAnnouncementNotFound
+	}
+	return &a, nil
+}
+
+func (r *memAnnouncementRepo) Update(ctx context.Context, a *model.Announcement) error {
+	defer r.s.lock(ctx)()
+	old, ok := r.s.data.announcements[a.ID]
+	if !ok {
+		return errAnnouncementNotFound
+	}
+	a.CreatedBy, a.CreatedAt = old.CreatedBy, old.CreatedAt
+	a.UpdatedAt = time.Now().UTC()
+	r.s.data.announcements[a.ID] = *a
+	return nil
+}
+
+func (r *memAnnouncementRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.announcements[id]; !ok {
+		return errAnnouncementNotFound
+	}
+	delete(r.s.data.announcements, id)
+	return nil
+}
+
+func (r *memAnnouncementRepo) List(ctx context.Context) ([]model.Announcement, error) {
+	defer r.s.lock(ctx)()
+	out := slices.Collect(maps.Values(r.s.data.announcements))
+	slices.SortFunc(out, func(a, b model.Announcement) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return out, nil
+}
+
+func (r *memAnnouncementRepo) Active(ctx context.Context, now time.Time, audiences []string) ([]model.Announcement, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Announcement{}
+	for _, a := range r.s.data.announcements {
+		if a.ActiveAt(now) && slices.Contains(audiences, a.Audience) {
+			out = append(out, a)
+		}
+	}
+	start := func(a model.Announcement) time.Time {
+		if a.StartsAt != nil {
+			return *a.StartsAt
+		}
+		return a.CreatedAt
+	}
+	slices.SortFunc(out, func(a, b model.Announcement) int {
+		if c := start(b).Compare(start(a)); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// AnnouncementRepo stores the announcements admins publish.
+type AnnouncementRepo interface {
+	Create(ctx context.Context, a *model.Announcement) error
+	GetByID(ctx context.Context, id string) (*model.Announcement, error)
+	// Update replaces the announcement's title, body, audience and
+	// schedule, setting a.UpdatedAt and the fields it keeps.
+	Update(ctx context.Context, a *model.Announcement) error
+	Delete(ctx context.Context, id string) error
+	// List returns every announcement, newest first.
+	List(ctx context.Context) ([]model.Announcement, error)
+	// Active returns the announcements for the audiences that show at now,
+	// latest start first.
+	Active(ctx context.Context, now time.Time, audiences []string) ([]model.Announcement, error)
+}
+
+const announcementColumns = `id, title, body, audience, starts_at, ends_at, COALESCE(created_by::text, ''), created_at, updated_at`
+
+var errAnnouncementNotFound = apperr.NotFound("announcement not found")
+
+type pgAnnouncementRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAnnouncementRepo(db *pgxpool.Pool) AnnouncementRepo {
+	return &pgAnnouncementRepo{db: db}
+}
+
+func scanAnnouncement(row pgx.Row) (model.Announcement, error) {
+	var a model.Announcement
+	err := row.Scan(&a.ID, &a.Title, &a.Body, &a.Audience, &a.StartsAt, &a.EndsAt, &a.CreatedBy, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+func (r *pgAnnouncementRepo) Create(ctx context.Context, a *model.Announcement) error {
+	return conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO announcements (title, body, audience, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		RETURNING id, created_at, updated_at`,
+		a.Title, a.Body, a.Audience, a.StartsAt, a.EndsAt, a.CreatedBy,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func (r *pgAnnouncementRepo) GetByID(ctx context.Context, id string) (*model.Announcement, error) {
+	a, err := scanAnnouncement(conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+announcementColumns+` FROM announcements WHERE id = $1`, id))
+	if isNoRows(err) {
+		return nil, errAnnouncementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *pgAnnouncementRepo) Update(ctx context.Context, a *model.Announcement) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`UPDATE announcements SET title = $2, body = $3, audience = $4, starts_at = $5, ends_at = $6, updated_at = now()
+		WHERE id = $1
+		RETURNING COALESCE(created_by::text, ''), created_at, updated_at`,
+		a.ID, a.Title, a.Body, a.Audience, a.StartsAt, a.EndsAt,
+	).Scan(&a.CreatedBy, &a.CreatedAt, &a.UpdatedAt)
+	if isNoRows(err) {
+		return errAnnouncementNotFound
+	}
+	return err
+}
+
+func (r *pgAnnouncementRepo) Delete(ctx context.Context, id string) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM announcements WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errAnnouncementNotFound
+	}
+	return nil
+}
+
+func (r *pgAnnouncementRepo) List(ctx context.Context) ([]model.Announcement, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+announcementColumns+` FROM announcements ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Announcement, error) {
+		return scanAnnouncement(row)
+	})
+}
+
+func (r *pgAnnouncementRepo) Active(ctx context.Context, now time.Time, audiences []string) ([]model.Announcement, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+announcementColumns+` FROM announcements
+		WHERE audience = ANY($2) AND (starts_at IS NULL OR starts_at <= $1) AND (ends_at IS NULL OR ends_at > $1)
+		ORDER BY COALESCE(starts_at, created_at) DESC, id DESC`,
+		now, audiences)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Announcement, error) {
+		return scanAnnouncement(row)
+	})
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memAPIKeyRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryAPIKeyRepo(s *MemoryStore) APIKeyRepo {
+	return &memAPIKeyRepo{s: s}
+}
+
+func (r *memAPIKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[k.UserID]; !ok {
+		return apperr.Validation("user not found")
+	}
+	for _, other := range r.s.data.apiKeys {
+		if other.Hash == k.Hash {
+			return apperr.Conflict("api key already exists")
+		}
+	}
+	k.ID = uuid.New().String()
+	k.CreatedAt = time.Now().UTC()
+	stored := *k
+	stored.Scopes = slices.Clone(k.Scopes)
+	r.s.data.apiKeys[k.ID] = stored
+	return nil
+}
+
+func (r *memAPIKeyRepo) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	defer r.s.lock(ctx)()
+	for _, k := range r.s.data.apiKeys {
+		if k.Hash == hash {
+			k.Scopes = slices.Clone(k.Scopes)
+			return &k, nil
+		}
+	}
+	return nil, apperr.NotFound("api key not found")
+}
+
+func (r *memAPIKeyRepo) List(ctx context.Context) ([]model.APIKey, error) {
+	defer r.s.lock(ctx)()
+	keys := []model.APIKey{}
+	for _, k := range r.s.data.apiKeys {
+		k.Scopes = slices.Clone(k.Scopes)
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b model.APIKey) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return keys, nil
+}
+
+func (r *memAPIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	defer r.s.lock(ctx)()
+	k, ok := r.s.data.apiKeys[id]
+	if !ok || k.RevokedAt != nil {
+		return apperr.NotFound("api key not found")
+	}
+	k.RevokedAt = &at
+	r.s.data.apiKeys[id] = k
+	return nil
+}
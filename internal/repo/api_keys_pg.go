@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// APIKeyRepo stores API keys by the hash of the key.
+type APIKeyRepo interface {
+	Create(ctx context.Context, k *model.APIKey) error
+	// GetByHash returns the key with hash, revoked and expired ones
+	// included, or a NotFound error.
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	// List returns every key, newest first.
+	List(ctx context.Context) ([]model.APIKey, error)
+	// Revoke revokes the key at at. It returns a NotFound error for an
+	// unknown or already revoked key.
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, user_id, scopes, COALESCE(created_by::text, ''), created_at, expires_at, revoked_at`
+
+func apiKeyDest(k *model.APIKey) []interface{} {
+	return []interface{}{&k.ID, &k.Name, &k.Prefix, &k.Hash, &k.UserID, &k.Scopes, &k.CreatedBy, &k.CreatedAt, &k.ExpiresAt, &k.RevokedAt}
+}
+
+type pgAPIKeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool) APIKeyRepo {
+	return &pgAPIKeyRepo{db: db}
+}
+
+func (r *pgAPIKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO api_keys (name, prefix, key_hash, user_id, scopes, created_by, expires_at)
+		VALUES ($1,$2,$3,$4,$5,NULLIF($6,'')::uuid,$7)
+		RETURNING `+apiKeyColumns,
+		k.Name, k.Prefix, k.Hash, k.UserID, k.Scopes, k.CreatedBy, k.ExpiresAt,
+	).Scan(apiKeyDest(k)...)
+	if foreignKeyViolation(err) {
+		return apperr.Validation("user not found")
+	}
+	return err
+}
+
+func (r *pgAPIKeyRepo) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	k := &model.APIKey{}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash=$1`, hash,
+	).Scan(apiKeyDest(k)...)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("api key not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *pgAPIKeyRepo) List(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.APIKey, error) {
+		var k model.APIKey
+		err := row.Scan(apiKeyDest(&k)...)
+		return k, err
+	})
+}
+
+func (r *pgAPIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE api_keys SET revoked_at=$2 WHERE id=$1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("api key not found")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/impersonator"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memAuditRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryAuditRepo(s *MemoryStore) AuditRepo {
+	return &memAuditRepo{s: s}
+}
+
+func (r *memAuditRepo) Record(ctx context.Context, e *model.AuditEntry) error {
+	defer r.s.lock(ctx)()
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	e.CreatedAt = time.Now().UTC()
+	if e.ClientIP == "" {
+		e.ClientIP = clientip.From(ctx)
+	}
+	if e.ImpersonatorID == "" {
+		e.ImpersonatorID = impersonator.From(ctx)
+	}
+	r.s.data.audit = append(r.s.data.audit, *e)
+	return nil
+}
+
+func (r *memAuditRepo) ListByTarget(ctx context.Context, targetType, targetID string) ([]model.AuditEntry, error) {
+	defer r.s.lock(ctx)()
+	entries := []model.AuditEntry{}
+	for _, e := range r.s.data.audit {
+		if e.TargetType == targetType && e.TargetID == targetID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/impersonator"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// AuditRepo appends to the audit log. Record joins the caller's transaction
+// so the entry is only kept if the audited change is.
+type AuditRepo interface {
+	Record(ctx context.Context, e *model.AuditEntry) error
+	// ListByTarget returns the entries about one record, oldest first.
+	ListByTarget(ctx context.Context, targetType, targetID string) ([]model.AuditEntry, error)
+}
+
+type pgAuditRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepo(db *pgxpool.Pool) AuditRepo {
+	return &pgAuditRepo{db: db}
+}
+
+func (r *pgAuditRepo) Record(ctx context.Context, e *model.AuditEntry) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	e.CreatedAt = time.Now().UTC()
+	if e.ClientIP == "" {
+		e.ClientIP = clientip.From(ctx)
+	}
+	if e.ImpersonatorID == "" {
+		e.ImpersonatorID = impersonator.From(ctx)
+	}
+	details := e.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	var actor, impersonatorID *string
+	if e.ActorID != "" {
+		actor = &e.ActorID
+	}
+	if e.ImpersonatorID != "" {
+		impersonatorID = &e.ImpersonatorID
+	}
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO audit_log (id, actor_id, action, target_type, target_id, details, client_ip, impersonator_id, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		e.ID, actor, e.Action, e.TargetType, e.TargetID, details, e.ClientIP, impersonatorID, e.CreatedAt)
+	return err
+}
+
+func (r *pgAuditRepo) ListByTarget(ctx context.Context, targetType, targetID string) ([]model.AuditEntry, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT id, COALESCE(actor_id::text, ''), action, target_type, target_id, details, client_ip,
+			COALESCE(impersonator_id::text, ''), created_at
+		FROM audit_log WHERE target_type = $1 AND target_id = $2 ORDER BY created_at, id`,
+		targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.AuditEntry, error) {
+		var e model.AuditEntry
+		err := row.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &e.Details, &e.ClientIP, &e.ImpersonatorID, &e.CreatedAt)
+		return e, err
+	})
+}
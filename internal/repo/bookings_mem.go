@@ -0,0 +1,311 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memBookingRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryBookingRepo(s *MemoryStore) BookingRepo {
+	return &memBookingRepo{s: s}
+}
+
+// Create stores b in the branch that owns its book.
+func (r *memBookingRepo) Create(ctx context.Context, b *model.Booking) error {
+	defer r.s.lock(ctx)()
+	book, ok := r.s.data.books[b.BookID]
+	if !ok {
+		return apperr.NotFound("book not found")
+	}
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = now
+	}
+	if b.UpdatedAt.IsZero() {
+		b.UpdatedAt = now
+	}
+	if b.Status == "ACTIVE" && r.hasActive(b.UserID, b.BookID, b.ID) {
+		return errActiveBooking
+	}
+	b.BranchID = book.BranchID
+	stored := *b
+	stored.Book, stored.User = nil, nil
+	r.s.data.bookings[b.ID] = stored
+	return nil
+}
+
+func (r *memBookingRepo) GetByID(ctx context.Context, id string) (*model.Booking, error) {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.bookings[id]
+	if !ok || !inBranch(ctx, b.BranchID) {
+		return nil, apperr.NotFound("booking not found")
+	}
+	return &b, nil
+}
+
+// GetByIDForUpdate is GetByID; the transaction already holds the store.
+func (r *memBookingRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.Booking, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memBookingRepo) GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+	f.UserID = userID
+	return r.List(ctx, p, f, expand)
+}
+
+func (r *memBookingRepo) GetActive(ctx context.Context, userID, bookID string) (*model.Booking, error) {
+	defer r.s.lock(ctx)()
+	for _, b := range r.s.data.bookings {
+		if b.UserID == userID && b.BookID == bookID && b.Status == "ACTIVE" && inBranch(ctx, b.BranchID) {
+			return &b, nil
+		}
+	}
+	return nil, apperr.NotFound("no active booking found")
+}
+
+// CountOutstandingForUpdate counts the user's bookings still out at every
+// branch. The transaction already holds the store, which orders borrows.
+func (r *memBookingRepo) CountOutstandingForUpdate(ctx context.Context, userID string) (int, error) {
+	defer r.s.lock(ctx)()
+	n := 0
+	for _, b := range r.s.data.bookings {
+		if b.UserID == userID && (b.Status == "ACTIVE" || b.Status == "OVERDUE") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memBookingRepo) Update(ctx context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error) {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.bookings[id]
+	if !ok {
+		return nil, apperr.NotFound("booking not found")
+	}
+	if p.Status != nil {
+		b.Status = *p.Status
+	}
+	if b.Status == "ACTIVE" && r.hasActive(b.UserID, b.BookID, id) {
+		return nil, errActiveBooking
+	}
+	if p.BorrowedAt != nil {
+		b.BorrowedAt = *p.BorrowedAt
+	}
+	if p.DueDate != nil {
+		b.DueDate = *p.DueDate
+		delete(r.s.data.reminders, id)
+	}
+	if p.ReturnedAt != nil {
+		b.ReturnedAt = p.ReturnedAt
+	}
+	if p.OfferExpiresAt != nil {
+		b.OfferExpiresAt = p.OfferExpiresAt
+	}
+	if p.ClearOfferExpiry {
+		b.OfferExpiresAt = nil
+	}
+	b.UpdatedAt = time.Now().UTC()
+	r.s.data.bookings[id] = b
+	return &b, nil
+}
+
+// hasActive reports whether the user holds an ACTIVE loan of the book other
+// than booking id, which idx_bookings_active_user_book refuses in Postgres.
+// The caller holds the store.
+func (r *memBookingRepo) hasActive(userID, bookID, id string) bool {
+	for _, b := range r.s.data.bookings {
+		if b.ID != id && b.UserID == userID && b.BookID == bookID && b.Status == "ACTIVE" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memBookingRepo) MarkOverdue(ctx context.Context) ([]model.Booking, error) {
+	defer r.s.lock(ctx)()
+	now := time.Now().UTC()
+	marked := []model.Booking{}
+	for id, b := range r.s.data.bookings {
+		if b.Status == "ACTIVE" && b.DueDate.Before(now) {
+			b.Status = "OVERDUE"
+			b.UpdatedAt = now
+			r.s.data.bookings[id] = b
+			marked = append(marked, b)
+		}
+	}
+	return marked, nil
+}
+
+func (r *memBookingRepo) ExpiredOffers(ctx context.Context, now time.Time) ([]model.Booking, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Booking{}
+	for _, b := range r.s.data.bookings {
+		if b.Status == "OFFERED" && b.OfferExpiresAt != nil && b.OfferExpiresAt.Before(now) {
+			out = append(out, b)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Booking) int {
+		if c := a.OfferExpiresAt.Compare(*b.OfferExpiresAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+func (r *memBookingRepo) ClaimDueReminders(ctx context.Context, now time.Time, lead time.Duration, limit int) ([]model.Booking, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Booking{}
+	for id, b := range r.s.data.bookings {
+		if _, sent := r.s.data.reminders[id]; sent || b.Status != "ACTIVE" || b.DueDate.Before(now) {
+			continue
+		}
+		until := lead
+		if p, ok := r.s.data.notifyPrefs[b.UserID]; ok {
+			if p.Channel == model.NotifyNone {
+				continue
+			}
+			if p.ReminderLeadHours > 0 {
+				until = time.Duration(p.ReminderLeadHours) * time.Hour
+			}
+		}
+		if b.DueDate.Before(now.Add(until)) {
+			out = append(out, b)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Booking) int {
+		if c := a.DueDate.Compare(b.DueDate); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	sentAt := time.Now().UTC()
+	for _, b := range out {
+		r.s.data.reminders[b.ID] = sentAt
+	}
+	return out, nil
+}
+
+func (r *memBookingRepo) ReleaseReminder(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	delete(r.s.data.reminders, id)
+	return nil
+}
+
+func (r *memBookingRepo) List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+	defer r.s.lock(ctx)()
+	bookings := r.matching(ctx, f)
+	page, err := memPage(bookings, p, func(b model.Booking) (time.Time, string) { return b.BorrowedAt, b.ID })
+	if err != nil {
+		return page, err
+	}
+	books := &memBookRepo{s: r.s}
+	for i := range page.Items {
+		b := &page.Items[i]
+		if book, ok := r.s.data.books[b.BookID]; ok && expand.Book {
+			view := books.view(book)
+			b.Book = &view
+		}
+		if u, ok := r.s.data.users[b.UserID]; ok && expand.User {
+			u.Password, u.BranchID = "", ""
+			b.User = &u
+		}
+	}
+	return page, nil
+}
+
+func (r *memBookingRepo) Overdue(ctx context.Context, now time.Time) ([]model.Booking, error) {
+	defer r.s.lock(ctx)()
+	books := &memBookRepo{s: r.s}
+	out := []model.Booking{}
+	for _, b := range r.s.data.bookings {
+		if (b.Status != "ACTIVE" && b.Status != "OVERDUE") || !b.DueDate.Before(now) || !inBranch(ctx, b.BranchID) {
+			continue
+		}
+		if book, ok := r.s.data.books[b.BookID]; ok {
+			view := books.view(book)
+			b.Book = &view
+		}
+		if u, ok := r.s.data.users[b.UserID]; ok {
+			u.Password, u.BranchID = "", ""
+			b.User = &u
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// matching returns the bookings visible to ctx that match f.
+func (r *memBookingRepo) matching(ctx context.Context, f model.BookingFilter) []model.Booking {
+	out := []model.Booking{}
+	for _, b := range r.s.data.bookings {
+		switch {
+		case !inBranch(ctx, b.BranchID),
+			f.Status != "" && b.Status != f.Status,
+			f.UserID != "" && b.UserID != f.UserID,
+			f.BookID != "" && b.BookID != f.BookID,
+			f.From != nil && b.BorrowedAt.Before(*f.From),
+			f.To != nil && !b.BorrowedAt.Before(*f.To):
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// Stream hands fn the bookings after afterID as they were when it was
+// called, in ID order.
+func (r *memBookingRepo) Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error {
+	unlock := r.s.lock(ctx)
+	bookings := []model.Booking{}
+	for _, b := range r.matching(ctx, model.BookingFilter{}) {
+		if b.ID > afterID {
+			bookings = append(bookings, b)
+		}
+	}
+	unlock()
+
+	slices.SortFunc(bookings, func(a, b model.Booking) int { return strings.Compare(a.ID, b.ID) })
+	for i := range bookings {
+		if err := fn(&bookings[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEach hands fn the bookings as they were when it was called, oldest
+// first.
+func (r *memBookingRepo) ForEach(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+	unlock := r.s.lock(ctx)
+	bookings := r.matching(ctx, model.BookingFilter{From: f.From, To: f.To})
+	unlock()
+
+	slices.SortFunc(bookings, func(a, b model.Booking) int {
+		if c := a.BorrowedAt.Compare(b.BorrowedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	for i := range bookings {
+		if err := fn(&bookings[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
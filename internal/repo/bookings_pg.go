@@ -2,33 +2,73 @@ package repo
 
 import (
     "context"
-    "errors"
+    "fmt"
     "time"
 
     "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
     "github.com/jackc/pgx/v5/pgxpool"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
 )
 
 type BookingRepo interface {
     Create(ctx context.Context, b *model.Booking) error
     GetByID(ctx context.Context, id string) (*model.Booking, error)
-    GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error)
+    GetByIDForUpdate(ctx context.Context, id string) (*model.Booking, error)
+    // GetByUser lists the user's bookings; f.UserID is ignored.
+    GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
     GetActive(ctx context.Context, userID, bookID string) (*model.Booking, error)
-    Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Booking, error)
-    MarkOverdue(ctx context.Context) error
-    List(ctx context.Context, limit, offset int) ([]model.Booking, error)
+    CountOutstandingForUpdate(ctx context.Context, userID string) (int, error)
+    Update(ctx context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error)
+    // MarkOverdue marks the ACTIVE loans at every branch that are past due
+    // as OVERDUE and returns them.
+    MarkOverdue(ctx context.Context) ([]model.Booking, error)
+    // ExpiredOffers returns the OFFERED bookings at every branch whose offer
+    // lapsed before now.
+    ExpiredOffers(ctx context.Context, now time.Time) ([]model.Booking, error)
+    // ClaimDueReminders marks up to limit ACTIVE loans at every branch that
+    // haven't been reminded and fall due between now and their borrower's
+    // reminder lead time later, lead for borrowers without one, as
+    // reminded, and returns them. Loans of borrowers who turned reminders
+    // off are never claimed. Concurrent callers claim different loans.
+    ClaimDueReminders(ctx context.Context, now time.Time, lead time.Duration, limit int) ([]model.Booking, error)
+    // ReleaseReminder unmarks a claimed loan whose reminder couldn't be sent.
+    ReleaseReminder(ctx context.Context, id string) error
+    List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
+    // Overdue returns the loans still out past their due date at now, with
+    // their book and user.
+    Overdue(ctx context.Context, now time.Time) ([]model.Booking, error)
+    ForEach(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error
+    // Stream hands fn every booking with an ID after afterID ("" for all),
+    // in ID order, so a broken-off stream can be resumed from its last
+    // booking.
+    Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error
+}
+
+const bookingColumns = `id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at, branch_id, offer_expires_at`
+
+// bookingDest lists scan targets for bookingColumns, in order.
+func bookingDest(b *model.Booking) []interface{} {
+    return []interface{}{&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt, &b.BranchID, &b.OfferExpiresAt}
 }
 
 type pgBookingRepo struct {
-    db *pgxpool.Pool
+    db      *pgxpool.Pool
+    replica *Replica
 }
 
-func NewBookingRepo(db *pgxpool.Pool) BookingRepo {
-    return &pgBookingRepo{db: db}
+// NewBookingRepo returns a BookingRepo on db whose listings, overdue report
+// and exports go to replica while it is healthy. replica may be nil.
+func NewBookingRepo(db *pgxpool.Pool, replica *Replica) BookingRepo {
+    return &pgBookingRepo{db: db, replica: replica}
 }
 
-// Create inserts a new booking
+// errActiveBooking is returned for a second ACTIVE loan of a book to one
+// user, which idx_bookings_active_user_book refuses.
+var errActiveBooking = apperr.Conflict("you already have an active booking for this book")
+
+// Create inserts a new booking in the branch that owns the book
 func (r *pgBookingRepo) Create(ctx context.Context, b *model.Booking) error {
     if b.ID == "" {
         b.ID = uuid.New().String()
@@ -40,13 +80,16 @@ func (r *pgBookingRepo) Create(ctx context.Context, b *model.Booking) error {
         b.UpdatedAt = time.Now().UTC()
     }
 
-    err := r.db.QueryRow(ctx,
-        `INSERT INTO bookings (id, user_id, book_id, borrowed_at, due_date, status, created_at, updated_at)
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-         RETURNING id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at`,
-        b.ID, b.UserID, b.BookID, b.BorrowedAt, b.DueDate, b.Status, b.CreatedAt, b.UpdatedAt,
-    ).Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `INSERT INTO bookings (id, user_id, book_id, borrowed_at, due_date, status, created_at, updated_at, offer_expires_at, branch_id)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, (SELECT branch_id FROM books WHERE id = $3))
+         RETURNING `+bookingColumns,
+        b.ID, b.UserID, b.BookID, b.BorrowedAt, b.DueDate, b.Status, b.CreatedAt, b.UpdatedAt, b.OfferExpiresAt,
+    ).Scan(bookingDest(b)...)
 
+    if _, ok := uniqueViolation(err); ok {
+        return errActiveBooking
+    }
     if err != nil {
         return err
     }
@@ -55,117 +98,361 @@ func (r *pgBookingRepo) Create(ctx context.Context, b *model.Booking) error {
 
 // GetByID retrieves booking by ID
 func (r *pgBookingRepo) GetByID(ctx context.Context, id string) (*model.Booking, error) {
+    return r.getByID(ctx, id, "")
+}
+
+// GetByIDForUpdate retrieves booking by ID and locks the row until the
+// surrounding transaction ends
+func (r *pgBookingRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.Booking, error) {
+    return r.getByID(ctx, id, " FOR UPDATE")
+}
+
+func (r *pgBookingRepo) getByID(ctx context.Context, id, lock string) (*model.Booking, error) {
     b := &model.Booking{}
-    err := r.db.QueryRow(ctx,
-        `SELECT id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at 
-         FROM bookings WHERE id = $1`,
-        id,
-    ).Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+    scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT `+bookingColumns+` FROM bookings`+where(append([]string{"id = $1"}, scope...)...)+lock,
+        args...,
+    ).Scan(bookingDest(b)...)
 
     if err != nil {
-        return nil, errors.New("booking not found")
+        if isNoRows(err) {
+            return nil, apperr.NotFound("booking not found")
+        }
+        return nil, err
     }
     return b, nil
 }
 
 // GetByUser retrieves user's bookings
-func (r *pgBookingRepo) GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-    rows, err := r.db.Query(ctx,
-        `SELECT id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at 
-         FROM bookings WHERE user_id = $1 
-         ORDER BY borrowed_at DESC LIMIT $2 OFFSET $3`,
-        userID, limit, offset,
-    )
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    var bookings []model.Booking
-    for rows.Next() {
-        b := model.Booking{}
-        if err := rows.Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt); err != nil {
-            return nil, err
-        }
-        bookings = append(bookings, b)
-    }
-    return bookings, nil
+func (r *pgBookingRepo) GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    f.UserID = userID
+    return r.List(ctx, p, f, expand)
 }
 
 // GetActive retrieves active booking for user+book
 func (r *pgBookingRepo) GetActive(ctx context.Context, userID, bookID string) (*model.Booking, error) {
     b := &model.Booking{}
-    err := r.db.QueryRow(ctx,
-        `SELECT id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at 
-         FROM bookings WHERE user_id = $1 AND book_id = $2 AND status = 'ACTIVE'`,
-        userID, bookID,
-    ).Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+    scope, args := branchScope(ctx, "branch_id", []interface{}{userID, bookID})
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT `+bookingColumns+` FROM bookings`+where(append([]string{"user_id = $1", "book_id = $2", "status = 'ACTIVE'"}, scope...)...),
+        args...,
+    ).Scan(bookingDest(b)...)
 
     if err != nil {
-        return nil, errors.New("no active booking found")
+        if isNoRows(err) {
+            return nil, apperr.NotFound("no active booking found")
+        }
+        return nil, err
     }
     return b, nil
 }
 
-// Update updates booking
-func (r *pgBookingRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Booking, error) {
-    updates["updated_at"] = time.Now().UTC()
+// CountOutstandingForUpdate counts the user's bookings that are still out
+// (ACTIVE or OVERDUE) at every branch, since loan limits apply to the user
+// rather than the branch. It first takes a per-user lock held until the
+// surrounding transaction ends, so concurrent borrows by the same user are
+// counted one after the other.
+func (r *pgBookingRepo) CountOutstandingForUpdate(ctx context.Context, userID string) (int, error) {
+    if _, err := conn(ctx, r.db).Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('bookings:' || $1))`, userID); err != nil {
+        return 0, err
+    }
+    var n int
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT COUNT(*) FROM bookings WHERE user_id = $1 AND status IN ('ACTIVE', 'OVERDUE')`,
+        userID,
+    ).Scan(&n)
+    return n, err
+}
 
-    // Build dynamic query
-    query := `UPDATE bookings SET `
-    args := []interface{}{}
-    i := 1
+// bookingUpdatable lists the columns Update may set.
+var bookingUpdatable = []string{"borrowed_at", "due_date", "returned_at", "status", "offer_expires_at", "reminder_sent_at", "updated_at"}
 
-    for key, value := range updates {
-        if i > 1 {
-            query += ", "
-        }
-        query += key + "=$" + string(rune(i+48))
-        args = append(args, value)
-        i++
+// bookingPatchColumns are the column values p sets. Moving the due date
+// re-arms its reminder.
+func bookingPatchColumns(p model.UpdateBookingPatch) map[string]interface{} {
+    cols := map[string]interface{}{"updated_at": time.Now().UTC()}
+    if p.Status != nil {
+        cols["status"] = *p.Status
     }
+    if p.BorrowedAt != nil {
+        cols["borrowed_at"] = *p.BorrowedAt
+    }
+    if p.DueDate != nil {
+        cols["due_date"] = *p.DueDate
+        cols["reminder_sent_at"] = nil
+    }
+    if p.ReturnedAt != nil {
+        cols["returned_at"] = *p.ReturnedAt
+    }
+    if p.OfferExpiresAt != nil {
+        cols["offer_expires_at"] = *p.OfferExpiresAt
+    }
+    if p.ClearOfferExpiry {
+        cols["offer_expires_at"] = nil
+    }
+    return cols
+}
 
-    query += ` WHERE id = $` + string(rune(i+48))
-    args = append(args, id)
-    query += ` RETURNING id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at`
+// Update updates booking.
+func (r *pgBookingRepo) Update(ctx context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error) {
+    query, args, err := updateQuery("bookings", bookingUpdatable, bookingPatchColumns(p), id, bookingColumns)
+    if err != nil {
+        return nil, err
+    }
 
     b := &model.Booking{}
-    err := r.db.QueryRow(ctx, query, args...).Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+    err = conn(ctx, r.db).QueryRow(ctx, query, args...).Scan(bookingDest(b)...)
     if err != nil {
+        if isNoRows(err) {
+            return nil, apperr.NotFound("booking not found")
+        }
+        if _, ok := uniqueViolation(err); ok {
+            return nil, errActiveBooking
+        }
         return nil, err
     }
 
     return b, nil
 }
 
-// MarkOverdue marks overdue bookings
-func (r *pgBookingRepo) MarkOverdue(ctx context.Context) error {
-    _, err := r.db.Exec(ctx,
+// MarkOverdue marks overdue bookings at every branch
+func (r *pgBookingRepo) MarkOverdue(ctx context.Context) ([]model.Booking, error) {
+    rows, err := conn(ctx, r.db).Query(ctx,
         `UPDATE bookings SET status = 'OVERDUE', updated_at = NOW() 
-         WHERE status = 'ACTIVE' AND due_date < NOW()`,
+         WHERE status = 'ACTIVE' AND due_date < NOW()
+         RETURNING `+bookingColumns,
     )
-    return err
+    if err != nil {
+        return nil, err
+    }
+    return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Booking, error) {
+        var b model.Booking
+        err := row.Scan(bookingDest(&b)...)
+        return b, err
+    })
 }
 
-// List retrieves all bookings (admin)
-func (r *pgBookingRepo) List(ctx context.Context, limit, offset int) ([]model.Booking, error) {
-    rows, err := r.db.Query(ctx,
-        `SELECT id, user_id, book_id, borrowed_at, due_date, returned_at, status, created_at, updated_at 
-         FROM bookings ORDER BY borrowed_at DESC LIMIT $1 OFFSET $2`,
-        limit, offset,
+func (r *pgBookingRepo) ExpiredOffers(ctx context.Context, now time.Time) ([]model.Booking, error) {
+    rows, err := conn(ctx, r.db).Query(ctx,
+        `SELECT `+bookingColumns+` FROM bookings WHERE status = 'OFFERED' AND offer_expires_at < $1 ORDER BY offer_expires_at, id`,
+        now,
     )
     if err != nil {
         return nil, err
     }
+    return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Booking, error) {
+        var b model.Booking
+        err := row.Scan(bookingDest(&b)...)
+        return b, err
+    })
+}
+
+func (r *pgBookingRepo) ClaimDueReminders(ctx context.Context, now time.Time, lead time.Duration, limit int) ([]model.Booking, error) {
+    rows, err := conn(ctx, r.db).Query(ctx,
+        `UPDATE bookings SET reminder_sent_at = NOW()
+         WHERE id IN (
+             SELECT b.id FROM bookings b
+             LEFT JOIN notification_preferences p ON p.user_id = b.user_id
+             WHERE b.status = 'ACTIVE' AND b.reminder_sent_at IS NULL AND b.due_date >= $1
+               AND b.due_date < $1 + COALESCE(make_interval(hours => NULLIF(p.reminder_lead_hours, 0)), make_interval(secs => $2))
+               AND COALESCE(p.channel, 'email') <> 'none'
+             ORDER BY b.due_date, b.id LIMIT $3
+             FOR UPDATE OF b SKIP LOCKED
+         )
+         RETURNING `+bookingColumns,
+        now, lead.Seconds(), limit,
+    )
+    if err != nil {
+        return nil, err
+    }
+    return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Booking, error) {
+        var b model.Booking
+        err := row.Scan(bookingDest(&b)...)
+        return b, err
+    })
+}
+
+func (r *pgBookingRepo) ReleaseReminder(ctx context.Context, id string) error {
+    _, err := conn(ctx, r.db).Exec(ctx, `UPDATE bookings SET reminder_sent_at = NULL WHERE id = $1`, id)
+    return err
+}
+
+// List returns one page of bookings matching f, newest first, together
+// with the total number of matches and the expanded relations in a single
+// query. The total is counted with COUNT(*) OVER () before the cursor and
+// LIMIT cut the page down.
+func (r *pgBookingRepo) List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    page := model.Page[model.Booking]{Items: []model.Booking{}}
+    conds, filterArgs := bookingFilter(f)
+    scope, filterArgs := branchScope(ctx, "branch_id", filterArgs)
+    conds = append(conds, scope...)
+
+    keyset, tail, args, err := pageQuery(p, "borrowed_at", filterArgs)
+    if err != nil {
+        return page, err
+    }
+    matched := `SELECT ` + bookingColumns + `, COUNT(*) OVER () AS total_count FROM bookings` + where(conds...)
+    query := `SELECT * FROM (` + matched + `) matched` + where(keyset) + tail
+    if expand.Book || expand.User {
+        query = expandBookings(query, expand)
+    }
+    rows, err := readConn(ctx, r.db, r.replica).Query(ctx, query, args...)
+    if err != nil {
+        return page, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        b, err := scanExpandedBooking(rows, expand, &page.Total)
+        if err != nil {
+            return page, err
+        }
+        page.Items = append(page.Items, b)
+    }
+    if err := rows.Err(); err != nil {
+        return page, err
+    }
+    if len(page.Items) == 0 && (p.Offset > 0 || p.Cursor != "") {
+        // Past the last match no row carries the total.
+        err := readConn(ctx, r.db, r.replica).QueryRow(ctx, `SELECT COUNT(*) FROM bookings`+where(conds...), filterArgs...).Scan(&page.Total)
+        if err != nil {
+            return page, err
+        }
+    }
+
+    page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(b model.Booking) string {
+        return encodeCursor(b.BorrowedAt, b.ID)
+    })
+    return page, nil
+}
+
+func (r *pgBookingRepo) Overdue(ctx context.Context, now time.Time) ([]model.Booking, error) {
+    conds := []string{"status IN ('ACTIVE', 'OVERDUE')", "due_date < $1"}
+    scope, args := branchScope(ctx, "branch_id", []interface{}{now})
+    conds = append(conds, scope...)
+    expand := model.BookingExpand{Book: true, User: true}
+    rows, err := readConn(ctx, r.db, r.replica).Query(ctx, expandBookings(`SELECT `+bookingColumns+` FROM bookings`+where(conds...), expand), args...)
+    if err != nil {
+        return nil, err
+    }
+    return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Booking, error) {
+        return scanExpandedBooking(row, expand)
+    })
+}
+
+// ForEach streams bookings matching f, oldest first, to fn without buffering
+// the result set. Iteration stops at the first error returned by fn. The
+// query is exempt from the statement timeouts.
+func (r *pgBookingRepo) ForEach(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+    ctx = unbounded(ctx)
+    conds, args := bookingFilter(model.BookingFilter{From: f.From, To: f.To})
+    scope, args := branchScope(ctx, "branch_id", args)
+    conds = append(conds, scope...)
+    rows, err := readConn(ctx, r.db, r.replica).Query(ctx, `SELECT `+bookingColumns+` FROM bookings`+where(conds...)+` ORDER BY borrowed_at, id`, args...)
+    if err != nil {
+        return err
+    }
     defer rows.Close()
 
-    var bookings []model.Booking
     for rows.Next() {
         b := model.Booking{}
-        if err := rows.Scan(&b.ID, &b.UserID, &b.BookID, &b.BorrowedAt, &b.DueDate, &b.ReturnedAt, &b.Status, &b.CreatedAt, &b.UpdatedAt); err != nil {
-            return nil, err
+        if err := rows.Scan(bookingDest(&b)...); err != nil {
+            return err
+        }
+        if err := fn(&b); err != nil {
+            return err
         }
-        bookings = append(bookings, b)
     }
-    return bookings, nil
-}
\ No newline at end of file
+    return rows.Err()
+}
+
+// Stream queries the bookings in batches of streamBatch, each continuing
+// from the last ID of the one before. The queries are exempt from the
+// statement timeouts.
+func (r *pgBookingRepo) Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error {
+    ctx = unbounded(ctx)
+    next := func(afterID string) (pgx.Rows, error) {
+        conds := []string{}
+        args := []interface{}{}
+        if afterID != "" {
+            args = append(args, afterID)
+            conds = append(conds, "id > $1")
+        }
+        scope, args := branchScope(ctx, "branch_id", args)
+        query := `SELECT ` + bookingColumns + ` FROM bookings` + where(append(conds, scope...)...) + fmt.Sprintf(" ORDER BY id LIMIT %d", streamBatch)
+        return readConn(ctx, r.db, r.replica).Query(ctx, query, args...)
+    }
+    return streamAfter(afterID, next, func(rows pgx.Rows) (*model.Booking, string, error) {
+        b := model.Booking{}
+        err := rows.Scan(bookingDest(&b)...)
+        return &b, b.ID, err
+    }, fn)
+}
+
+// bookingFilter returns the WHERE conditions and arguments for f.
+func bookingFilter(f model.BookingFilter) ([]string, []interface{}) {
+    conds := []string{}
+    args := []interface{}{}
+    add := func(cond string, arg interface{}) {
+        args = append(args, arg)
+        conds = append(conds, fmt.Sprintf(cond, len(args)))
+    }
+    if f.Status != "" {
+        add("status = $%d", f.Status)
+    }
+    if f.UserID != "" {
+        add("user_id = $%d", f.UserID)
+    }
+    if f.BookID != "" {
+        add("book_id = $%d", f.BookID)
+    }
+    if f.From != nil {
+        add("borrowed_at >= $%d", *f.From)
+    }
+    if f.To != nil {
+        add("borrowed_at < $%d", *f.To)
+    }
+    return conds, args
+}
+
+const expandUserColumns = `u.id, u.username, u.email, u.role, u.created_at, u.updated_at`
+
+// expandBookings wraps a page query over bookings and joins the relations
+// selected by e. The page is cut first so the joins only touch returned rows.
+func expandBookings(page string, e model.BookingExpand) string {
+    cols := "bk.*"
+    joins := ""
+    if e.Book {
+        cols += ", bb.*"
+        joins += " JOIN LATERAL (" + bookSelect + " WHERE b.id = bk.book_id) bb ON true"
+    }
+    if e.User {
+        cols += ", " + expandUserColumns
+        joins += " JOIN users u ON u.id = bk.user_id"
+    }
+    return "SELECT " + cols + " FROM (" + page + ") bk" + joins + " ORDER BY bk.borrowed_at DESC, bk.id DESC"
+}
+
+// scanExpandedBooking scans a row of bookingColumns followed by the columns
+// expandBookings adds for e. extra receives any columns the page query
+// selects after bookingColumns.
+func scanExpandedBooking(row pgx.Row, e model.BookingExpand, extra ...any) (model.Booking, error) {
+    b := model.Booking{}
+    dest := append(bookingDest(&b), extra...)
+    if e.Book {
+        b.Book = &model.Book{}
+        dest = append(dest, bookDest(b.Book)...)
+    }
+    if e.User {
+        b.User = &model.User{}
+        dest = append(dest, &b.User.ID, &b.User.Username, &b.User.Email, &b.User.Role, &b.User.CreatedAt, &b.User.UpdatedAt)
+    }
+    if err := row.Scan(dest...); err != nil {
+        return b, err
+    }
+    if b.Book != nil {
+        b.Book.Available = b.Book.CopiesAvailable > 0
+    }
+    return b, nil
+}
@@ -0,0 +1,451 @@
+package repo
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memBookRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryBookRepo(s *MemoryStore) BookRepo {
+	return &memBookRepo{s: s}
+}
+
+func (r *memBookRepo) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+	defer r.s.lock(ctx)()
+	books := []model.Book{}
+	for _, b := range r.s.data.books {
+		if !inBranch(ctx, b.BranchID) {
+			continue
+		}
+		b = r.view(b)
+		if f.Category != "" && !slices.ContainsFunc(b.Categories, func(c model.Category) bool {
+			return c.ID == f.Category || strings.EqualFold(c.Name, f.Category)
+		}) {
+			continue
+		}
+		if f.Tag != "" && !slices.Contains(b.Tags, strings.ToLower(f.Tag)) {
+			continue
+		}
+		books = append(books, b)
+	}
+	return memPage(books, p, func(b model.Book) (time.Time, string) { return b.CreatedAt, b.ID })
+}
+
+// view fills in what bookSelect computes: availability, the full category
+// records and the review average.
+func (r *memBookRepo) view(b model.Book) model.Book {
+	onLoan := 0
+	for _, bk := range r.s.data.bookings {
+		if bk.BookID == b.ID && (bk.Status == "ACTIVE" || bk.Status == "OVERDUE" || bk.Status == "OFFERED") {
+			onLoan++
+		}
+	}
+	b.CopiesAvailable = b.TotalCopies - onLoan
+	b.Available = b.CopiesAvailable > 0
+	b.Tags = tagsOrEmpty(b.Tags)
+	b.Categories = []model.Category{}
+	for _, id := range r.s.data.bookCategories[b.ID] {
+		b.Categories = append(b.Categories, r.s.data.categories[id])
+	}
+	slices.SortFunc(b.Categories, func(a, c model.Category) int { return strings.Compare(a.Name, c.Name) })
+	sum := 0
+	b.AverageRating, b.ReviewCount = 0, 0
+	for _, rv := range r.s.data.reviews {
+		if rv.BookID == b.ID {
+			sum += rv.Rating
+			b.ReviewCount++
+		}
+	}
+	if b.ReviewCount > 0 {
+		b.AverageRating = math.Round(float64(sum)/float64(b.ReviewCount)*100) / 100
+	}
+	return b
+}
+
+func (r *memBookRepo) GetByID(ctx context.Context, id string) (model.Book, error) {
+	defer r.s.lock(ctx)()
+	return r.get(ctx, id)
+}
+
+// GetByIDForUpdate is GetByID; the transaction already holds the store.
+func (r *memBookRepo) GetByIDForUpdate(ctx context.Context, id string) (model.Book, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memBookRepo) FindByISBN(ctx context.Context, isbn string) ([]model.Book, error) {
+	defer r.s.lock(ctx)()
+	isbn = isbnReplacer.Replace(isbn)
+	books := []model.Book{}
+	for _, b := range r.s.data.books {
+		if b.ISBN != "" && isbnReplacer.Replace(b.ISBN) == isbn && inBranch(ctx, b.BranchID) {
+			books = append(books, r.view(b))
+		}
+	}
+	slices.SortFunc(books, func(a, b model.Book) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return books, nil
+}
+
+func (r *memBookRepo) get(ctx context.Context, id string) (model.Book, error) {
+	b, ok := r.s.data.books[id]
+	if !ok || !inBranch(ctx, b.BranchID) {
+		return model.Book{}, apperr.NotFound("book not found")
+	}
+	return r.view(b), nil
+}
+
+func (r *memBookRepo) Create(ctx context.Context, b *model.Book) error {
+	defer r.s.lock(ctx)()
+	ids := make([]string, len(b.Categories))
+	for i, c := range b.Categories {
+		ids[i] = c.ID
+	}
+	if err := r.checkCategories(ids); err != nil {
+		return err
+	}
+	if err := r.insert(ctx, b); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		r.s.data.bookCategories[b.ID] = slices.Compact(slices.Sorted(slices.Values(ids)))
+	}
+	*b = r.view(r.s.data.books[b.ID])
+	return nil
+}
+
+// insert stores b in the caller's branch, or the main branch, setting its
+// ID, timestamps and version.
+func (r *memBookRepo) insert(ctx context.Context, b *model.Book) error {
+	branchID := branchOrDefault(ctx)
+	if r.isbnTaken(branchID, b.ISBN, "") {
+		return &DuplicateISBNError{ISBN: b.ISBN}
+	}
+	now := time.Now().UTC()
+	b.ID = uuid.New().String()
+	b.BranchID = branchID
+	b.CreatedAt, b.UpdatedAt, b.Version = now, now, 1
+	b.Tags = tagsOrEmpty(b.Tags)
+	stored := *b
+	stored.Categories = nil
+	r.s.data.books[b.ID] = stored
+	return nil
+}
+
+// isbnTaken mirrors the partial (branch_id, isbn) unique index.
+func (r *memBookRepo) isbnTaken(branchID, isbn, exceptID string) bool {
+	if isbn == "" {
+		return false
+	}
+	for _, b := range r.s.data.books {
+		if b.ID != exceptID && b.BranchID == branchID && b.ISBN == isbn {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memBookRepo) checkCategories(ids []string) error {
+	for _, id := range ids {
+		if _, ok := r.s.data.categories[id]; !ok {
+			return apperr.Validation("unknown category id")
+		}
+	}
+	return nil
+}
+
+func (r *memBookRepo) CreateMany(ctx context.Context, books []*model.Book) ([]error, error) {
+	defer r.s.lock(ctx)()
+	rowErrs := make([]error, len(books))
+	for i, b := range books {
+		rowErrs[i] = r.insert(ctx, b)
+	}
+	return rowErrs, nil
+}
+
+// Update follows the Postgres repo.
+func (r *memBookRepo) Update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.books[id]
+	if !ok || !inBranch(ctx, b.BranchID) {
+		return nil, apperr.NotFound("book not found")
+	}
+	if p.Version != nil && *p.Version != b.Version {
+		return nil, errVersionMismatch
+	}
+
+	if p.Title != nil {
+		b.Title = *p.Title
+	}
+	if p.Author != nil {
+		b.Author = *p.Author
+	}
+	if p.PublishedYear != nil {
+		b.PublishedYear = *p.PublishedYear
+	}
+	if p.ISBN != nil {
+		b.ISBN = *p.ISBN
+	}
+	if p.TotalCopies != nil {
+		b.TotalCopies = *p.TotalCopies
+	}
+	if p.CoverURL != nil {
+		b.CoverURL = *p.CoverURL
+	}
+	if p.Tags != nil {
+		b.Tags = p.Tags
+	}
+	if p.CategoryIDs != nil {
+		if err := r.checkCategories(p.CategoryIDs); err != nil {
+			return nil, err
+		}
+	}
+	if r.isbnTaken(b.BranchID, b.ISBN, b.ID) {
+		return nil, &DuplicateISBNError{ISBN: b.ISBN}
+	}
+
+	b.Version++
+	b.UpdatedAt = time.Now().UTC()
+	r.s.data.books[id] = b
+	if p.CategoryIDs != nil {
+		r.s.data.bookCategories[id] = slices.Compact(slices.Sorted(slices.Values(p.CategoryIDs)))
+	}
+	book := r.view(b)
+	return &book, nil
+}
+
+// Delete follows the Postgres repo, moving the book to deletedBooks.
+func (r *memBookRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.books[id]
+	if !ok || !inBranch(ctx, b.BranchID) {
+		return apperr.NotFound("book not found")
+	}
+	for _, bk := range r.s.data.bookings {
+		if bk.BookID == id && (bk.Status == "ACTIVE" || bk.Status == "OVERDUE" || bk.Status == "OFFERED") {
+			return apperr.Conflict("book has copies on loan or held for pickup; they must be returned before it is deleted")
+		}
+	}
+	for resID, res := range r.s.data.reservations {
+		if res.BookID == id {
+			delete(r.s.data.reservations, resID)
+		}
+	}
+	for e := range r.s.data.listBooks {
+		if e.bookID == id {
+			delete(r.s.data.listBooks, e)
+		}
+	}
+	b.Version++
+	b.UpdatedAt = time.Now().UTC()
+	r.s.data.deletedBooks[id] = b
+	delete(r.s.data.books, id)
+	return nil
+}
+
+func (r *memBookRepo) Restore(ctx context.Context, id string) (*model.Book, error) {
+	defer r.s.lock(ctx)()
+	if b, ok := r.s.data.books[id]; ok && inBranch(ctx, b.BranchID) {
+		return nil, apperr.Conflict("book is not deleted")
+	}
+	if b, ok := r.s.data.mergedBooks[id]; ok && inBranch(ctx, b.BranchID) {
+		return nil, apperr.Conflict("book was merged into another and can't be restored")
+	}
+	b, ok := r.s.data.deletedBooks[id]
+	if !ok || !inBranch(ctx, b.BranchID) {
+		return nil, apperr.NotFound("book not found")
+	}
+	if r.isbnTaken(b.BranchID, b.ISBN, "") {
+		return nil, &DuplicateISBNError{ISBN: b.ISBN}
+	}
+	b.Version++
+	b.UpdatedAt = time.Now().UTC()
+	r.s.data.books[id] = b
+	delete(r.s.data.deletedBooks, id)
+	book := r.view(b)
+	return &book, nil
+}
+
+// Merge follows the Postgres repo, moving the duplicate to mergedBooks.
+func (r *memBookRepo) Merge(ctx context.Context, sourceID, targetID string) (*model.Book, error) {
+	defer r.s.lock(ctx)()
+	source, ok := r.s.data.books[sourceID]
+	if !ok || !inBranch(ctx, source.BranchID) {
+		return nil, apperr.NotFound("book not found")
+	}
+	target, ok := r.s.data.books[targetID]
+	if !ok || !inBranch(ctx, target.BranchID) {
+		return nil, apperr.NotFound("book not found")
+	}
+	if source.BranchID != target.BranchID {
+		return nil, apperr.Conflict("books in different branches can't be merged")
+	}
+	for _, bk := range r.s.data.bookings {
+		if bk.BookID != sourceID || bk.Status != "ACTIVE" {
+			continue
+		}
+		for _, other := range r.s.data.bookings {
+			if other.BookID == targetID && other.UserID == bk.UserID && other.Status == "ACTIVE" {
+				return nil, apperr.Conflict(r.s.data.users[bk.UserID].Username + " has both books on loan; return one before merging")
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	for id, bk := range r.s.data.bookings {
+		if bk.BookID == sourceID {
+			bk.BookID, bk.UpdatedAt = targetID, now
+			r.s.data.bookings[id] = bk
+		}
+	}
+	reviewed := map[string]bool{}
+	for _, rv := range r.s.data.reviews {
+		if rv.BookID == targetID {
+			reviewed[rv.UserID] = true
+		}
+	}
+	for id, rv := range r.s.data.reviews {
+		if rv.BookID != sourceID {
+			continue
+		}
+		if reviewed[rv.UserID] {
+			delete(r.s.data.reviews, id)
+			continue
+		}
+		rv.BookID = targetID
+		r.s.data.reviews[id] = rv
+	}
+	waiting := map[string]bool{}
+	for _, res := range r.s.data.reservations {
+		if res.BookID == targetID {
+			waiting[res.UserID] = true
+		}
+	}
+	for id, res := range r.s.data.reservations {
+		if res.BookID != sourceID {
+			continue
+		}
+		if waiting[res.UserID] {
+			delete(r.s.data.reservations, id)
+			continue
+		}
+		res.BookID = targetID
+		r.s.data.reservations[id] = res
+	}
+	for e, added := range r.s.data.listBooks {
+		if e.bookID != sourceID {
+			continue
+		}
+		delete(r.s.data.listBooks, e)
+		moved := memListEntry{listID: e.listID, bookID: targetID}
+		if _, ok := r.s.data.listBooks[moved]; !ok {
+			r.s.data.listBooks[moved] = added
+		}
+	}
+	categories := append(slices.Clone(r.s.data.bookCategories[targetID]), r.s.data.bookCategories[sourceID]...)
+	if len(categories) > 0 {
+		r.s.data.bookCategories[targetID] = slices.Compact(slices.Sorted(slices.Values(categories)))
+	}
+
+	target.TotalCopies += source.TotalCopies
+	target.Version++
+	target.UpdatedAt = now
+	r.s.data.books[targetID] = target
+	source.UpdatedAt = now
+	r.s.data.mergedBooks[sourceID] = source
+	delete(r.s.data.books, sourceID)
+
+	book := r.view(target)
+	return &book, nil
+}
+
+func (r *memBookRepo) Popular(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error) {
+	defer r.s.lock(ctx)()
+	borrows := map[string]int{}
+	for _, bk := range r.s.data.bookings {
+		if !bk.BorrowedAt.Before(since) {
+			borrows[bk.BookID]++
+		}
+	}
+	books := []model.PopularBook{}
+	for id, n := range borrows {
+		b, ok := r.s.data.books[id]
+		if ok && inBranch(ctx, b.BranchID) {
+			books = append(books, model.PopularBook{Book: r.view(b), BorrowCount: n})
+		}
+	}
+	slices.SortFunc(books, func(a, b model.PopularBook) int {
+		if a.BorrowCount != b.BorrowCount {
+			return b.BorrowCount - a.BorrowCount
+		}
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return books[:min(limit, len(books))], nil
+}
+
+func (r *memBookRepo) Newest(ctx context.Context, limit int) ([]model.Book, error) {
+	page, err := r.List(ctx, model.PageRequest{Limit: limit}, model.BookFilter{})
+	return page.Items, err
+}
+
+// Stream hands fn the books after afterID as they were when it was called,
+// in ID order.
+func (r *memBookRepo) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+	unlock := r.s.lock(ctx)
+	books := []model.Book{}
+	for _, b := range r.s.data.books {
+		if inBranch(ctx, b.BranchID) && b.ID > afterID {
+			books = append(books, r.view(b))
+		}
+	}
+	unlock()
+
+	slices.SortFunc(books, func(a, b model.Book) int { return strings.Compare(a.ID, b.ID) })
+	for i := range books {
+		if err := fn(&books[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEach hands fn the books as they were when it was called, oldest first.
+func (r *memBookRepo) ForEach(ctx context.Context, fn func(*model.Book) error) error {
+	unlock := r.s.lock(ctx)
+	books := []model.Book{}
+	for _, b := range r.s.data.books {
+		if inBranch(ctx, b.BranchID) {
+			books = append(books, r.view(b))
+		}
+	}
+	unlock()
+
+	slices.SortFunc(books, func(a, b model.Book) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	for i := range books {
+		if err := fn(&books[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,94 +2,342 @@ package repo
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
 	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
 )
 
 type BookRepo interface {
-	List(ctx context.Context, limit, offset int) ([]model.Book, error)
+	List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
 	GetByID(ctx context.Context, id string) (model.Book, error)
+	GetByIDForUpdate(ctx context.Context, id string) (model.Book, error)
+	// FindByISBN returns the books, oldest first, whose ISBN is isbn once
+	// hyphens and spaces are dropped from both. Branches may each hold a
+	// book with the same ISBN, so there can be more than one.
+	FindByISBN(ctx context.Context, isbn string) ([]model.Book, error)
 	Create(ctx context.Context, b *model.Book) error
-    Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) // ← Changed
+	CreateMany(ctx context.Context, books []*model.Book) ([]error, error)
+	// Update applies p with optimistic locking; see UpdateBookPatch.
+	Update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error)
+	// Delete soft-deletes a book, keeping its row, loan history and reviews
+	// for Restore. It fails with a Conflict while copies are on loan or held
+	// for pickup; the book's reservations and reading list entries go.
 	Delete(ctx context.Context, id string) error
+	// Restore brings back a deleted book with its version bumped. It fails
+	// with a DuplicateISBNError when another book has taken its ISBN since,
+	// and with a Conflict when the book isn't deleted or was merged.
+	Restore(ctx context.Context, id string) (*model.Book, error)
+	ForEach(ctx context.Context, fn func(*model.Book) error) error
+	// Stream hands fn every book with an ID after afterID ("" for all), in
+	// ID order, so a broken-off stream can be resumed from its last book.
+	Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error
+	// Popular returns up to limit books ranked by the bookings made since
+	// since, most borrowed first. Books not borrowed since then are left out.
+	Popular(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error)
+	// Newest returns up to limit books, most recently added first.
+	Newest(ctx context.Context, limit int) ([]model.Book, error)
+	// Merge folds the duplicate book sourceID into targetID and returns the
+	// updated target. See pgBookRepo.Merge.
+	Merge(ctx context.Context, sourceID, targetID string) (*model.Book, error)
 }
 
+// bookSelect reads books together with their live availability: total copies
+// minus the bookings that are still out (ACTIVE or OVERDUE) or held for the
+// waitlist (OFFERED). Categories come
+// back as one JSON array per book, followed by the review average and count.
+// Callers add liveBook and the branch scope to WHERE.
+const bookSelect = `SELECT b.id, b.title, b.author, b.published_year, b.isbn, b.created_at, b.updated_at, b.version,
+	b.total_copies, b.total_copies - COALESCE(a.on_loan, 0), b.cover_url, b.tags, b.branch_id,
+	COALESCE((
+		SELECT json_agg(json_build_object('id', c.id, 'name', c.name, 'description', c.description,
+			'created_at', c.created_at, 'updated_at', c.updated_at) ORDER BY c.name)
+		FROM book_categories bc JOIN categories c ON c.id = bc.category_id
+		WHERE bc.book_id = b.id
+	), '[]') AS categories,
+	COALESCE(rv.average, 0) AS average_rating, COALESCE(rv.n, 0) AS review_count
+	FROM books b
+	LEFT JOIN (
+		SELECT book_id, COUNT(*) AS on_loan FROM bookings
+		WHERE status IN ('ACTIVE', 'OVERDUE', 'OFFERED') GROUP BY book_id
+	) a ON a.book_id = b.id
+	LEFT JOIN (
+		SELECT book_id, ROUND(AVG(rating), 2)::float8 AS average, COUNT(*) AS n FROM reviews GROUP BY book_id
+	) rv ON rv.book_id = b.id`
+
+// liveBook leaves out books that were deleted or merged into another.
+const liveBook = "b.deleted_at IS NULL"
+
+var errVersionMismatch = apperr.PreconditionFailed("book was modified by another request. Please refetch and retry.")
+
 type pgBookRepo struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	replica *Replica
 }
 
-func NewBookRepo(db *pgxpool.Pool) BookRepo {
-	return &pgBookRepo{db: db}
+// NewBookRepo returns a BookRepo on db whose searches, listings, exports
+// and unlocked lookups go to replica while it is healthy. replica may be
+// nil.
+func NewBookRepo(db *pgxpool.Pool, replica *Replica) BookRepo {
+	return &pgBookRepo{db: db, replica: replica}
 }
 
-func (r *pgBookRepo) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
-	rows, err := r.db.Query(ctx, `SELECT id,title,author,published_year,isbn,created_at,updated_at,version FROM books ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+func (r *pgBookRepo) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+	page := model.Page[model.Book]{Items: []model.Book{}}
+	conds, args := bookFilter(f)
+	scope, args := branchScope(ctx, "b.branch_id", args)
+	conds = append(append([]string{liveBook}, conds...), scope...)
+	if err := readConn(ctx, r.db, r.replica).QueryRow(ctx, `SELECT COUNT(*) FROM books b`+where(conds...), args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", args)
 	if err != nil {
-		return nil, err
+		return page, err
+	}
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx, bookSelect+where(append(conds, keyset)...)+tail, args...)
+	if err != nil {
+		return page, err
 	}
 	defer rows.Close()
-	var out []model.Book
 	for rows.Next() {
 		var b model.Book
-		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.PublishedYear, &b.ISBN, &b.CreatedAt, &b.UpdatedAt, &b.Version); err != nil {
-			return nil, err
+		if err := scanBook(rows, &b); err != nil {
+			return page, err
 		}
-		out = append(out, b)
+		page.Items = append(page.Items, b)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(b model.Book) string {
+		return encodeCursor(b.CreatedAt, b.ID)
+	})
+	return page, nil
+}
+
+// bookFilter returns the WHERE conditions for f over books aliased as b.
+func bookFilter(f model.BookFilter) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.Category != "" {
+		args = append(args, f.Category)
+		conds = append(conds, fmt.Sprintf(`EXISTS (SELECT 1 FROM book_categories bc JOIN categories c ON c.id = bc.category_id
+			WHERE bc.book_id = b.id AND (c.id::text = $%[1]d OR lower(c.name) = lower($%[1]d)))`, len(args)))
+	}
+	if f.Tag != "" {
+		args = append(args, strings.ToLower(f.Tag))
+		conds = append(conds, fmt.Sprintf("$%d = ANY(b.tags)", len(args)))
 	}
-	return out, nil
+	return conds, args
 }
 
 func (r *pgBookRepo) GetByID(ctx context.Context, id string) (model.Book, error) {
+	return r.getByID(ctx, id, "")
+}
+
+// GetByIDForUpdate is GetByID plus a row lock on the book that is held until
+// the surrounding transaction ends, serialising concurrent borrows of it.
+func (r *pgBookRepo) GetByIDForUpdate(ctx context.Context, id string) (model.Book, error) {
+	return r.getByID(ctx, id, " FOR UPDATE OF b")
+}
+
+func (r *pgBookRepo) FindByISBN(ctx context.Context, isbn string) ([]model.Book, error) {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{isbnReplacer.Replace(isbn)})
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx,
+		bookSelect+where(append([]string{`b.isbn <> ''`, `translate(b.isbn, '- ', '')=$1`, liveBook}, scope...)...)+` ORDER BY b.created_at, b.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Book, error) {
+		var b model.Book
+		err := scanBook(row, &b)
+		return b, err
+	})
+}
+
+// isbnReplacer drops the separators people and publishers put in ISBNs.
+var isbnReplacer = strings.NewReplacer("-", "", " ", "")
+
+func (r *pgBookRepo) getByID(ctx context.Context, id, lock string) (model.Book, error) {
 	var b model.Book
-	err := r.db.QueryRow(ctx, `SELECT id,title,author,published_year,isbn,created_at,updated_at,version FROM books WHERE id=$1`, id).Scan(
-		&b.ID, &b.Title, &b.Author, &b.PublishedYear, &b.ISBN, &b.CreatedAt, &b.UpdatedAt, &b.Version)
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{id})
+	c := conn(ctx, r.db)
+	if lock == "" {
+		c = readConn(ctx, r.db, r.replica)
+	}
+	err := scanBook(c.QueryRow(ctx, bookSelect+where(append([]string{"b.id=$1", liveBook}, scope...)...)+lock, args...), &b)
 	if err != nil {
+		if isNoRows(err) {
+			return b, apperr.NotFound("book not found")
+		}
 		return b, err
 	}
 	return b, nil
 }
 
+// Create inserts b into the branch ctx is scoped to, or the main branch, and
+// links it to b.Categories by ID. On success b is replaced by the stored
+// book, with full category records.
 func (r *pgBookRepo) Create(ctx context.Context, b *model.Book) error {
-	now := time.Now().UTC()
-	err := r.db.QueryRow(ctx,
-		`INSERT INTO books (title,author,published_year,isbn,created_at,updated_at,version) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id,created_at,updated_at,version`,
-		b.Title, b.Author, b.PublishedYear, b.ISBN, now, now, 1).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt, &b.Version)
+	return r.withinTx(ctx, func(ctx context.Context) error {
+		now := time.Now().UTC()
+		err := conn(ctx, r.db).QueryRow(ctx,
+			`INSERT INTO books (title,author,published_year,isbn,total_copies,cover_url,tags,created_at,updated_at,version,branch_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id`,
+			b.Title, b.Author, b.PublishedYear, b.ISBN, b.TotalCopies, b.CoverURL, tagsOrEmpty(b.Tags), now, now, 1, branchOrDefault(ctx)).Scan(&b.ID)
+		if _, ok := uniqueViolation(err); ok {
+			return &DuplicateISBNError{ISBN: b.ISBN}
+		}
+		if err != nil {
+			return err
+		}
+		if len(b.Categories) > 0 {
+			ids := make([]string, len(b.Categories))
+			for i, c := range b.Categories {
+				ids[i] = c.ID
+			}
+			if err := r.setCategories(ctx, b.ID, ids); err != nil {
+				return err
+			}
+		}
+		stored, err := r.GetByID(ctx, b.ID)
+		if err != nil {
+			return err
+		}
+		*b = stored
+		return nil
+	})
+}
+
+// setCategories replaces the categories linked to a book.
+func (r *pgBookRepo) setCategories(ctx context.Context, bookID string, categoryIDs []string) error {
+	if _, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM book_categories WHERE book_id=$1`, bookID); err != nil {
+		return err
+	}
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO book_categories (book_id, category_id) SELECT $1, unnest($2::text[])::uuid ON CONFLICT DO NOTHING`,
+		bookID, categoryIDs)
+	if foreignKeyViolation(err) {
+		return apperr.Validation("unknown category id")
+	}
 	return err
 }
 
-func (r *pgBookRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
-    // Step 1: Get current book (including version)
-    var currentBook model.Book
-    err := r.db.QueryRow(ctx,
-        `SELECT id, version FROM books WHERE id = $1`,
-        id,
-    ).Scan(&currentBook.ID, &currentBook.Version)
-    if err != nil {
-        return nil, errors.New("book not found")
-    }
+func (r *pgBookRepo) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return (&pgTxManager{db: r.db}).WithinTx(ctx, fn)
+}
+
+// tagsOrEmpty keeps the NOT NULL tags column happy when a book has none.
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// CreateMany inserts books in a single transaction, into the same branch as
+// Create. Each insert runs under its
+// own savepoint so one bad row (e.g. a duplicate ISBN) doesn't abort the rest;
+// the returned slice holds the per-book error, nil for rows that were created.
+func (r *pgBookRepo) CreateMany(ctx context.Context, books []*model.Book) ([]error, error) {
+	rowErrs := make([]error, len(books))
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	now := time.Now().UTC()
+	branchID := branchOrDefault(ctx)
+	for i, b := range books {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = sp.QueryRow(ctx,
+			`INSERT INTO books (title,author,published_year,isbn,total_copies,cover_url,tags,created_at,updated_at,version,branch_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id,created_at,updated_at,version,branch_id`,
+			b.Title, b.Author, b.PublishedYear, b.ISBN, b.TotalCopies, b.CoverURL, tagsOrEmpty(b.Tags), now, now, 1, branchID).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt, &b.Version, &b.BranchID)
+		if err != nil {
+			if rbErr := sp.Rollback(ctx); rbErr != nil {
+				return nil, rbErr
+			}
+			if _, ok := uniqueViolation(err); ok {
+				err = &DuplicateISBNError{ISBN: b.ISBN}
+			}
+			rowErrs[i] = err
+			continue
+		}
+		if err := sp.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rowErrs, nil
+}
+
+// Update applies p with optimistic locking. When p.Version is set, the
+// write only succeeds if the stored version still equals it; otherwise it
+// applies to whatever version is stored. Either way the version is bumped.
+func (r *pgBookRepo) Update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    var book *model.Book
+    err := r.withinTx(ctx, func(ctx context.Context) error {
+        var err error
+        book, err = r.update(ctx, id, p)
+        return err
+    })
+    return book, err
+}
 
-    // Step 2: Increment version
-    newVersion := currentBook.Version + 1
-
-    // Step 3: Update with optimistic locking
-    cmdTag, err := r.db.Exec(ctx,
-        `UPDATE books 
-         SET title=$1, author=$2, published_year=$3, isbn=$4, 
-             updated_at=$5, version=$6
-         WHERE id=$7 AND version=$8`,
-        updates["title"], updates["author"], updates["published_year"], updates["isbn"],
-        time.Now().UTC(), newVersion, id, currentBook.Version,
-    )
-    
+func (r *pgBookRepo) update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    // The version check and the write are one statement, so no other
+    // update can slip in between them. A NULL $10 skips the check, and a
+    // NULL column value leaves the column alone.
+    scope, args := branchScope(ctx, "branch_id", []interface{}{
+        p.Title, p.Author, p.PublishedYear, p.ISBN, p.TotalCopies,
+        p.CoverURL, p.Tags, time.Now().UTC(), id, p.Version,
+    })
+    var version int
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `UPDATE books
+         SET title=COALESCE($1, title), author=COALESCE($2, author),
+             published_year=COALESCE($3, published_year), isbn=COALESCE($4, isbn),
+             total_copies=COALESCE($5, total_copies),
+             cover_url=COALESCE($6, cover_url),
+             tags=COALESCE($7, tags),
+             updated_at=$8, version=version+1`+
+            where(append([]string{"id=$9", "deleted_at IS NULL", "($10::int IS NULL OR version=$10)"}, scope...)...)+
+            ` RETURNING version`,
+        args...,
+    ).Scan(&version)
     if err != nil {
+        if isNoRows(err) {
+            return nil, r.updateMissed(ctx, id)
+        }
+        if _, ok := uniqueViolation(err); ok {
+            var isbn string
+            if p.ISBN != nil {
+                isbn = *p.ISBN
+            }
+            return nil, &DuplicateISBNError{ISBN: isbn}
+        }
         return nil, err
     }
 
-    if cmdTag.RowsAffected() == 0 {
-        return nil, errors.New("conflict: book was modified by another request")
+    if p.CategoryIDs != nil {
+        if err := r.setCategories(ctx, id, p.CategoryIDs); err != nil {
+            return nil, err
+        }
     }
 
     // Return updated book
@@ -100,7 +348,258 @@ func (r *pgBookRepo) Update(ctx context.Context, id string, updates map[string]i
     return &book, nil
 }
 
+// updateMissed explains an update of book id that matched no row: the
+// book is gone or out of the caller's branch, or its version moved on.
+func (r *pgBookRepo) updateMissed(ctx context.Context, id string) error {
+    scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+    var exists bool
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT EXISTS (SELECT 1 FROM books`+where(append([]string{"id = $1", "deleted_at IS NULL"}, scope...)...)+`)`,
+        args...,
+    ).Scan(&exists)
+    switch {
+    case err != nil:
+        return err
+    case !exists:
+        return apperr.NotFound("book not found")
+    }
+    return errVersionMismatch
+}
+
 func (r *pgBookRepo) Delete(ctx context.Context, id string) error {
-	_, err := r.db.Exec(ctx, `DELETE FROM books WHERE id=$1`, id)
-	return err
+	return r.withinTx(ctx, func(ctx context.Context) error {
+		scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+		var onLoan bool
+		err := conn(ctx, r.db).QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM bookings WHERE book_id = books.id AND status IN ('ACTIVE', 'OVERDUE', 'OFFERED'))
+			FROM books`+where(append([]string{"id=$1", "deleted_at IS NULL"}, scope...)...)+` FOR UPDATE`, args...).Scan(&onLoan)
+		if isNoRows(err) {
+			return apperr.NotFound("book not found")
+		}
+		if err != nil {
+			return err
+		}
+		if onLoan {
+			return apperr.Conflict("book has copies on loan or held for pickup; they must be returned before it is deleted")
+		}
+
+		for _, stmt := range []string{
+			`DELETE FROM reservations WHERE book_id = $1`,
+			`DELETE FROM reading_list_books WHERE book_id = $1`,
+			`UPDATE books SET deleted_at = NOW(), version = version + 1, updated_at = NOW() WHERE id = $1`,
+		} {
+			if _, err := conn(ctx, r.db).Exec(ctx, stmt, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *pgBookRepo) Restore(ctx context.Context, id string) (*model.Book, error) {
+	var book *model.Book
+	err := r.withinTx(ctx, func(ctx context.Context) error {
+		scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+		var deleted, merged bool
+		var isbn string
+		err := conn(ctx, r.db).QueryRow(ctx,
+			`SELECT deleted_at IS NOT NULL, merged_into IS NOT NULL, isbn FROM books`+where(append([]string{"id=$1"}, scope...)...)+` FOR UPDATE`,
+			args...).Scan(&deleted, &merged, &isbn)
+		switch {
+		case isNoRows(err):
+			return apperr.NotFound("book not found")
+		case err != nil:
+			return err
+		case !deleted:
+			return apperr.Conflict("book is not deleted")
+		case merged:
+			return apperr.Conflict("book was merged into another and can't be restored")
+		}
+
+		_, err = conn(ctx, r.db).Exec(ctx,
+			`UPDATE books SET deleted_at = NULL, version = version + 1, updated_at = NOW() WHERE id = $1`, id)
+		if _, ok := uniqueViolation(err); ok {
+			return &DuplicateISBNError{ISBN: isbn}
+		}
+		if err != nil {
+			return err
+		}
+		b, err := r.GetByID(ctx, id)
+		book = &b
+		return err
+	})
+	return book, err
+}
+
+func (r *pgBookRepo) Popular(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error) {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{since, limit})
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx,
+		`SELECT bb.*, p.borrows FROM (`+bookSelect+where(append([]string{liveBook}, scope...)...)+`) bb
+		JOIN (
+			SELECT book_id, COUNT(*) AS borrows FROM bookings WHERE borrowed_at >= $1 GROUP BY book_id
+		) p ON p.book_id = bb.id
+		ORDER BY p.borrows DESC, bb.created_at DESC, bb.id DESC LIMIT $2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.PopularBook, error) {
+		var pb model.PopularBook
+		err := row.Scan(append(bookDest(&pb.Book), &pb.BorrowCount)...)
+		pb.Available = pb.CopiesAvailable > 0
+		return pb, err
+	})
+}
+
+func (r *pgBookRepo) Newest(ctx context.Context, limit int) ([]model.Book, error) {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{limit})
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx,
+		bookSelect+where(append([]string{liveBook}, scope...)...)+` ORDER BY b.created_at DESC, b.id DESC LIMIT $1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Book, error) {
+		var b model.Book
+		err := scanBook(row, &b)
+		return b, err
+	})
+}
+
+// ForEach streams every book, oldest first, to fn without buffering the
+// result set. Iteration stops at the first error returned by fn. The query
+// is exempt from the statement timeouts.
+func (r *pgBookRepo) ForEach(ctx context.Context, fn func(*model.Book) error) error {
+	ctx = unbounded(ctx)
+	scope, args := branchScope(ctx, "b.branch_id", nil)
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx, bookSelect+where(append([]string{liveBook}, scope...)...)+` ORDER BY b.created_at, b.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b model.Book
+		if err := scanBook(rows, &b); err != nil {
+			return err
+		}
+		if err := fn(&b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Stream queries the books in batches of streamBatch, each continuing from
+// the last ID of the one before. The queries are exempt from the statement
+// timeouts.
+func (r *pgBookRepo) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+	ctx = unbounded(ctx)
+	next := func(afterID string) (pgx.Rows, error) {
+		conds := []string{liveBook}
+		args := []interface{}{}
+		if afterID != "" {
+			args = append(args, afterID)
+			conds = append(conds, "b.id > $1")
+		}
+		scope, args := branchScope(ctx, "b.branch_id", args)
+		query := bookSelect + where(append(conds, scope...)...) + fmt.Sprintf(" ORDER BY b.id LIMIT %d", streamBatch)
+		return readConn(ctx, r.db, r.replica).Query(ctx, query, args...)
+	}
+	return streamAfter(afterID, next, func(rows pgx.Rows) (*model.Book, string, error) {
+		var b model.Book
+		err := scanBook(rows, &b)
+		return &b, b.ID, err
+	}, fn)
+}
+
+// Merge folds the duplicate sourceID into targetID in one transaction. The
+// duplicate's bookings, reservations and reviews move to the target, except
+// the reservations and reviews of users who already have one there, which
+// are dropped. On reading lists the target takes the duplicate's place.
+// Its categories and copies are added to the target's, whose
+// version is bumped. The duplicate is then soft-deleted, keeping its row and
+// ISBN with merged_into pointing at the target. Both books must be in the
+// same branch, and no user may have both on loan.
+func (r *pgBookRepo) Merge(ctx context.Context, sourceID, targetID string) (*model.Book, error) {
+	var merged *model.Book
+	err := r.withinTx(ctx, func(ctx context.Context) error {
+		// Lock in ID order so merges of the same two books can't deadlock.
+		ids := []string{sourceID, targetID}
+		slices.Sort(ids)
+		books := map[string]model.Book{}
+		for _, id := range ids {
+			b, err := r.GetByIDForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			books[id] = b
+		}
+		source, target := books[sourceID], books[targetID]
+		if source.BranchID != target.BranchID {
+			return apperr.Conflict("books in different branches can't be merged")
+		}
+		// The loans can't all move while a user has both books out:
+		// idx_bookings_active_user_book allows one ACTIVE loan of a book.
+		var username string
+		err := conn(ctx, r.db).QueryRow(ctx,
+			`SELECT u.username FROM bookings s JOIN users u ON u.id = s.user_id
+			 WHERE s.book_id = $1 AND s.status = 'ACTIVE'
+			   AND EXISTS (SELECT 1 FROM bookings t WHERE t.book_id = $2 AND t.user_id = s.user_id AND t.status = 'ACTIVE')
+			 ORDER BY u.username LIMIT 1`,
+			sourceID, targetID).Scan(&username)
+		if err == nil {
+			return apperr.Conflict(username + " has both books on loan; return one before merging")
+		}
+		if !isNoRows(err) {
+			return err
+		}
+
+		for _, q := range []string{
+			`UPDATE bookings SET book_id = $2, updated_at = NOW() WHERE book_id = $1`,
+			`DELETE FROM reviews s WHERE s.book_id = $1
+				AND EXISTS (SELECT 1 FROM reviews t WHERE t.book_id = $2 AND t.user_id = s.user_id)`,
+			`UPDATE reviews SET book_id = $2 WHERE book_id = $1`,
+			`DELETE FROM reservations s WHERE s.book_id = $1
+				AND EXISTS (SELECT 1 FROM reservations t WHERE t.book_id = $2 AND t.user_id = s.user_id)`,
+			`UPDATE reservations SET book_id = $2 WHERE book_id = $1`,
+			`INSERT INTO reading_list_books (list_id, book_id, added_at)
+				SELECT list_id, $2, added_at FROM reading_list_books WHERE book_id = $1 ON CONFLICT DO NOTHING`,
+			`DELETE FROM reading_list_books WHERE book_id = $1`,
+			`INSERT INTO book_categories (book_id, category_id)
+				SELECT $2, category_id FROM book_categories WHERE book_id = $1 ON CONFLICT DO NOTHING`,
+			`UPDATE books SET total_copies = total_copies + (SELECT total_copies FROM books WHERE id = $1),
+				version = version + 1, updated_at = NOW() WHERE id = $2`,
+			`UPDATE books SET deleted_at = NOW(), merged_into = $2, updated_at = NOW() WHERE id = $1`,
+		} {
+			if _, err := conn(ctx, r.db).Exec(ctx, q, sourceID, targetID); err != nil {
+				return err
+			}
+		}
+
+		stored, err := r.GetByID(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		merged = &stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// scanBook scans a row produced by bookSelect.
+func scanBook(row pgx.Row, b *model.Book) error {
+	if err := row.Scan(bookDest(b)...); err != nil {
+		return err
+	}
+	b.Available = b.CopiesAvailable > 0
+	return nil
+}
+
+// bookDest lists scan targets for the columns of bookSelect, in order, so
+// queries that embed those columns can scan them alongside their own.
+// Callers must set Available once the scan succeeds.
+func bookDest(b *model.Book) []interface{} {
+	return []interface{}{&b.ID, &b.Title, &b.Author, &b.PublishedYear, &b.ISBN, &b.CreatedAt, &b.UpdatedAt, &b.Version,
+		&b.TotalCopies, &b.CopiesAvailable, &b.CoverURL, &b.Tags, &b.BranchID, &b.Categories, &b.AverageRating, &b.ReviewCount}
 }
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memBranchRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryBranchRepo(s *MemoryStore) BranchRepo {
+	return &memBranchRepo{s: s}
+}
+
+func (r *memBranchRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.Branch], error) {
+	defer r.s.lock(ctx)()
+	branches := make([]model.Branch, 0, len(r.s.data.branches))
+	for _, b := range r.s.data.branches {
+		branches = append(branches, b)
+	}
+	return memPage(branches, p, func(b model.Branch) (time.Time, string) { return b.CreatedAt, b.ID })
+}
+
+func (r *memBranchRepo) GetByID(ctx context.Context, id string) (model.Branch, error) {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.branches[id]
+	if !ok {
+		return b, errBranchNotFound
+	}
+	return b, nil
+}
+
+func (r *memBranchRepo) Resolve(ctx context.Context, ref string) (model.Branch, error) {
+	defer r.s.lock(ctx)()
+	for _, b := range r.s.data.branches {
+		if b.ID == ref || b.Code == strings.ToLower(ref) {
+			return b, nil
+		}
+	}
+	return model.Branch{}, errBranchNotFound
+}
+
+func (r *memBranchRepo) codeTaken(code, exceptID string) bool {
+	for _, b := range r.s.data.branches {
+		if b.ID != exceptID && b.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memBranchRepo) Create(ctx context.Context, b *model.Branch) error {
+	defer r.s.lock(ctx)()
+	if r.codeTaken(b.Code, "") {
+		return errBranchCodeTaken
+	}
+	now := time.Now().UTC()
+	b.ID = uuid.New().String()
+	b.CreatedAt, b.UpdatedAt = now, now
+	r.s.data.branches[b.ID] = *b
+	return nil
+}
+
+func (r *memBranchRepo) Update(ctx context.Context, b *model.Branch) error {
+	defer r.s.lock(ctx)()
+	stored, ok := r.s.data.branches[b.ID]
+	if !ok {
+		return errBranchNotFound
+	}
+	if r.codeTaken(b.Code, b.ID) {
+		return errBranchCodeTaken
+	}
+	b.CreatedAt, b.UpdatedAt = stored.CreatedAt, time.Now().UTC()
+	r.s.data.branches[b.ID] = *b
+	return nil
+}
+
+func (r *memBranchRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.branches[id]; !ok {
+		return errBranchNotFound
+	}
+	for _, b := range r.s.data.books {
+		if b.BranchID == id {
+			return errBranchInUse
+		}
+	}
+	for _, b := range r.s.data.bookings {
+		if b.BranchID == id {
+			return errBranchInUse
+		}
+	}
+	for _, u := range r.s.data.users {
+		if u.BranchID == id {
+			return errBranchInUse
+		}
+	}
+	delete(r.s.data.branches, id)
+	for cID, c := range r.s.data.closures {
+		if c.BranchID == id {
+			delete(r.s.data.closures, cID)
+		}
+	}
+	return nil
+}
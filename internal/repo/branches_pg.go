@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type BranchRepo interface {
+	List(ctx context.Context, p model.PageRequest) (model.Page[model.Branch], error)
+	GetByID(ctx context.Context, id string) (model.Branch, error)
+	// Resolve finds a branch by ID or by code.
+	Resolve(ctx context.Context, ref string) (model.Branch, error)
+	Create(ctx context.Context, b *model.Branch) error
+	Update(ctx context.Context, b *model.Branch) error
+	// Delete refuses branches that still own books, bookings or users.
+	Delete(ctx context.Context, id string) error
+}
+
+const branchSelect = `SELECT id, code, name, address, created_at, updated_at FROM branches`
+
+var (
+	errBranchNotFound  = apperr.NotFound("branch not found")
+	errBranchCodeTaken = apperr.Conflict("branch with this code already exists")
+	errBranchInUse     = apperr.Conflict("branch still has books, bookings or users")
+)
+
+type pgBranchRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewBranchRepo(db *pgxpool.Pool) BranchRepo {
+	return &pgBranchRepo{db: db}
+}
+
+func (r *pgBranchRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.Branch], error) {
+	page := model.Page[model.Branch]{Items: []model.Branch{}}
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM branches`).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", nil)
+	if err != nil {
+		return page, err
+	}
+	rows, err := conn(ctx, r.db).Query(ctx, branchSelect+where(keyset)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b model.Branch
+		if err := scanBranch(rows, &b); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, b)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(b model.Branch) string {
+		return encodeCursor(b.CreatedAt, b.ID)
+	})
+	return page, nil
+}
+
+func (r *pgBranchRepo) GetByID(ctx context.Context, id string) (model.Branch, error) {
+	var b model.Branch
+	err := scanBranch(conn(ctx, r.db).QueryRow(ctx, branchSelect+` WHERE id::text=$1`, id), &b)
+	if isNoRows(err) {
+		return b, errBranchNotFound
+	}
+	return b, err
+}
+
+func (r *pgBranchRepo) Resolve(ctx context.Context, ref string) (model.Branch, error) {
+	var b model.Branch
+	err := scanBranch(conn(ctx, r.db).QueryRow(ctx, branchSelect+` WHERE id::text=$1 OR code=lower($1)`, ref), &b)
+	if isNoRows(err) {
+		return b, errBranchNotFound
+	}
+	return b, err
+}
+
+func (r *pgBranchRepo) Create(ctx context.Context, b *model.Branch) error {
+	now := time.Now().UTC()
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO branches (code, name, address, created_at, updated_at) VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at, updated_at`,
+		b.Code, b.Name, b.Address, now, now).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return errBranchCodeTaken
+	}
+	return err
+}
+
+func (r *pgBranchRepo) Update(ctx context.Context, b *model.Branch) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`UPDATE branches SET code=$1, name=$2, address=$3, updated_at=$4 WHERE id::text=$5 RETURNING created_at, updated_at`,
+		b.Code, b.Name, b.Address, time.Now().UTC(), b.ID).Scan(&b.CreatedAt, &b.UpdatedAt)
+	if isNoRows(err) {
+		return errBranchNotFound
+	}
+	if _, ok := uniqueViolation(err); ok {
+		return errBranchCodeTaken
+	}
+	return err
+}
+
+func (r *pgBranchRepo) Delete(ctx context.Context, id string) error {
+	cmdTag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM branches WHERE id::text=$1`, id)
+	if foreignKeyViolation(err) {
+		return errBranchInUse
+	}
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return errBranchNotFound
+	}
+	return nil
+}
+
+func scanBranch(row pgx.Row, b *model.Branch) error {
+	return row.Scan(&b.ID, &b.Code, &b.Name, &b.Address, &b.CreatedAt, &b.UpdatedAt)
+}
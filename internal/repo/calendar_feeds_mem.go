@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+)
+
+type memCalendarFeedRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryCalendarFeedRepo(s *MemoryStore) CalendarFeedRepo {
+	return &memCalendarFeedRepo{s: s}
+}
+
+func (r *memCalendarFeedRepo) Version(ctx context.Context, userID string) (int, error) {
+	defer r.s.lock(ctx)()
+	version, ok := r.s.data.calendarFeeds[userID]
+	if !ok {
+		return 0, apperr.NotFound("calendar feed not found")
+	}
+	return version, nil
+}
+
+func (r *memCalendarFeedRepo) Issue(ctx context.Context, userID string) (int, error) {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[userID]; !ok {
+		return 0, apperr.NotFound("user not found")
+	}
+	version, ok := r.s.data.calendarFeeds[userID]
+	if !ok {
+		version = 1
+		r.s.data.calendarFeeds[userID] = version
+	}
+	return version, nil
+}
+
+// Rotate starts a missing feed at 2, as the Postgres repo does.
+func (r *memCalendarFeedRepo) Rotate(ctx context.Context, userID string) (int, error) {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[userID]; !ok {
+		return 0, apperr.NotFound("user not found")
+	}
+	version := r.s.data.calendarFeeds[userID] + 1
+	if version < 2 {
+		version = 2
+	}
+	r.s.data.calendarFeeds[userID] = version
+	return version, nil
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+)
+
+// CalendarFeedRepo stores the version each user's calendar feed token is
+// signed with.
+type CalendarFeedRepo interface {
+	// Version returns the version of the user's feed, or a NotFound error
+	// if they have none.
+	Version(ctx context.Context, userID string) (int, error)
+	// Issue returns the version of the user's feed, starting one at 1 if
+	// they have none.
+	Issue(ctx context.Context, userID string) (int, error)
+	// Rotate moves the user's feed to a new version and returns it.
+	Rotate(ctx context.Context, userID string) (int, error)
+}
+
+type pgCalendarFeedRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewCalendarFeedRepo(db *pgxpool.Pool) CalendarFeedRepo {
+	return &pgCalendarFeedRepo{db: db}
+}
+
+func (r *pgCalendarFeedRepo) Version(ctx context.Context, userID string) (int, error) {
+	var version int
+	err := conn(ctx, r.db).QueryRow(ctx, `SELECT version FROM calendar_feeds WHERE user_id = $1`, userID).Scan(&version)
+	if isNoRows(err) {
+		return 0, apperr.NotFound("calendar feed not found")
+	}
+	return version, err
+}
+
+// Issue updates nothing on conflict, but DO UPDATE is what makes RETURNING
+// give back the existing row.
+func (r *pgCalendarFeedRepo) Issue(ctx context.Context, userID string) (int, error) {
+	return r.upsert(ctx, `INSERT INTO calendar_feeds (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE SET version = calendar_feeds.version
+		RETURNING version`, userID)
+}
+
+// Rotate starts a missing feed at 2, past the version 1 tokens signed
+// before feeds had versions.
+func (r *pgCalendarFeedRepo) Rotate(ctx context.Context, userID string) (int, error) {
+	return r.upsert(ctx, `INSERT INTO calendar_feeds (user_id, version) VALUES ($1, 2)
+		ON CONFLICT (user_id) DO UPDATE SET version = calendar_feeds.version + 1, rotated_at = now()
+		RETURNING version`, userID)
+}
+
+func (r *pgCalendarFeedRepo) upsert(ctx context.Context, query, userID string) (int, error) {
+	var version int
+	err := conn(ctx, r.db).QueryRow(ctx, query, userID).Scan(&version)
+	if foreignKeyViolation(err) {
+		return 0, apperr.NotFound("user not found")
+	}
+	return version, err
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memCategoryRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryCategoryRepo(s *MemoryStore) CategoryRepo {
+	return &memCategoryRepo{s: s}
+}
+
+func (r *memCategoryRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error) {
+	defer r.s.lock(ctx)()
+	categories := make([]model.Category, 0, len(r.s.data.categories))
+	for _, c := range r.s.data.categories {
+		categories = append(categories, c)
+	}
+	return memPage(categories, p, func(c model.Category) (time.Time, string) { return c.CreatedAt, c.ID })
+}
+
+func (r *memCategoryRepo) GetByID(ctx context.Context, id string) (model.Category, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.categories[id]
+	if !ok {
+		return c, apperr.NotFound("category not found")
+	}
+	return c, nil
+}
+
+// nameTaken mirrors the case-insensitive unique index on the name.
+func (r *memCategoryRepo) nameTaken(name, exceptID string) bool {
+	for _, c := range r.s.data.categories {
+		if c.ID != exceptID && strings.EqualFold(c.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memCategoryRepo) Create(ctx context.Context, c *model.Category) error {
+	defer r.s.lock(ctx)()
+	if r.nameTaken(c.Name, "") {
+		return errCategoryNameTaken
+	}
+	now := time.Now().UTC()
+	c.ID = uuid.New().String()
+	c.CreatedAt, c.UpdatedAt = now, now
+	r.s.data.categories[c.ID] = *c
+	return nil
+}
+
+func (r *memCategoryRepo) Update(ctx context.Context, c *model.Category) error {
+	defer r.s.lock(ctx)()
+	stored, ok := r.s.data.categories[c.ID]
+	if !ok {
+		return apperr.NotFound("category not found")
+	}
+	if r.nameTaken(c.Name, c.ID) {
+		return errCategoryNameTaken
+	}
+	c.CreatedAt, c.UpdatedAt = stored.CreatedAt, time.Now().UTC()
+	r.s.data.categories[c.ID] = *c
+	return nil
+}
+
+func (r *memCategoryRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.categories[id]; !ok {
+		return apperr.NotFound("category not found")
+	}
+	delete(r.s.data.categories, id)
+	for bookID, ids := range r.s.data.bookCategories {
+		kept := []string{}
+		for _, c := range ids {
+			if c != id {
+				kept = append(kept, c)
+			}
+		}
+		r.s.data.bookCategories[bookID] = kept
+	}
+	return nil
+}
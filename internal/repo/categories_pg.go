@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type CategoryRepo interface {
+	List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error)
+	GetByID(ctx context.Context, id string) (model.Category, error)
+	Create(ctx context.Context, c *model.Category) error
+	Update(ctx context.Context, c *model.Category) error
+	// Delete removes the category and unlinks it from its books.
+	Delete(ctx context.Context, id string) error
+}
+
+const categorySelect = `SELECT id, name, description, created_at, updated_at FROM categories`
+
+var errCategoryNameTaken = apperr.Conflict("category with this name already exists")
+
+type pgCategoryRepo struct {
+	db      *pgxpool.Pool
+	replica *Replica
+}
+
+// NewCategoryRepo returns a CategoryRepo on db whose list and lookup
+// queries go to replica while it is healthy. replica may be nil.
+func NewCategoryRepo(db *pgxpool.Pool, replica *Replica) CategoryRepo {
+	return &pgCategoryRepo{db: db, replica: replica}
+}
+
+func (r *pgCategoryRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error) {
+	page := model.Page[model.Category]{Items: []model.Category{}}
+	if err := readConn(ctx, r.db, r.replica).QueryRow(ctx, `SELECT COUNT(*) FROM categories`).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", nil)
+	if err != nil {
+		return page, err
+	}
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx, categorySelect+where(keyset)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c model.Category
+		if err := scanCategory(rows, &c); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(c model.Category) string {
+		return encodeCursor(c.CreatedAt, c.ID)
+	})
+	return page, nil
+}
+
+func (r *pgCategoryRepo) GetByID(ctx context.Context, id string) (model.Category, error) {
+	var c model.Category
+	err := scanCategory(readConn(ctx, r.db, r.replica).QueryRow(ctx, categorySelect+` WHERE id=$1`, id), &c)
+	if isNoRows(err) {
+		return c, apperr.NotFound("category not found")
+	}
+	return c, err
+}
+
+func (r *pgCategoryRepo) Create(ctx context.Context, c *model.Category) error {
+	now := time.Now().UTC()
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO categories (name, description, created_at, updated_at) VALUES ($1,$2,$3,$4) RETURNING id, created_at, updated_at`,
+		c.Name, c.Description, now, now).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return errCategoryNameTaken
+	}
+	return err
+}
+
+func (r *pgCategoryRepo) Update(ctx context.Context, c *model.Category) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`UPDATE categories SET name=$1, description=$2, updated_at=$3 WHERE id=$4 RETURNING created_at, updated_at`,
+		c.Name, c.Description, time.Now().UTC(), c.ID).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if isNoRows(err) {
+		return apperr.NotFound("category not found")
+	}
+	if _, ok := uniqueViolation(err); ok {
+		return errCategoryNameTaken
+	}
+	return err
+}
+
+func (r *pgCategoryRepo) Delete(ctx context.Context, id string) error {
+	cmdTag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM categories WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return apperr.NotFound("category not found")
+	}
+	return nil
+}
+
+func scanCategory(row pgx.Row, c *model.Category) error {
+	return row.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt)
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+type memClosureRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryClosureRepo(s *MemoryStore) ClosureRepo {
+	return &memClosureRepo{s: s}
+}
+
+func (r *memClosureRepo) Create(ctx context.Context, c *model.Closure) error {
+	defer r.s.lock(ctx)()
+	c.ID = uuid.New().String()
+	c.BranchID = tenant.BranchID(ctx)
+	c.StartsOn, c.EndsOn = model.Day(c.StartsOn), model.Day(c.EndsOn)
+	c.CreatedAt = time.Now().UTC()
+	r.s.data.closures[c.ID] = *c
+	return nil
+}
+
+func (r *memClosureRepo) List(ctx context.Context, from, to time.Time) ([]model.Closure, error) {
+	return r.ForBranch(ctx, tenant.BranchID(ctx), from, to)
+}
+
+func (r *memClosureRepo) ForBranch(ctx context.Context, branchID string, from, to time.Time) ([]model.Closure, error) {
+	defer r.s.lock(ctx)()
+	from, to = model.Day(from), model.Day(to)
+	out := []model.Closure{}
+	for _, c := range r.s.data.closures {
+		if c.EndsOn.Before(from) || c.StartsOn.After(to) {
+			continue
+		}
+		if branchID != "" && c.BranchID != "" && c.BranchID != branchID {
+			continue
+		}
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b model.Closure) int {
+		if c := a.StartsOn.Compare(b.StartsOn); c != 0 {
+			return c
+		}
+		if c := a.EndsOn.Compare(b.EndsOn); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+func (r *memClosureRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.closures[id]
+	if !ok {
+		return errClosureNotFound
+	}
+	if scope := tenant.BranchID(ctx); scope != "" && c.BranchID != scope {
+		return errClosureNotFound
+	}
+	delete(r.s.data.closures, id)
+	return nil
+}
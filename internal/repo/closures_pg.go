@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+// ClosureRepo stores the library calendar. A closure created in a branch
+// scope belongs to that branch; one created unscoped closes every branch.
+type ClosureRepo interface {
+	Create(ctx context.Context, c *model.Closure) error
+	// List returns the closures overlapping the days from to to, by start.
+	// In a branch scope that is the branch's closures and those of every
+	// branch.
+	List(ctx context.Context, from, to time.Time) ([]model.Closure, error)
+	// ForBranch is List for branchID, whatever the scope of ctx.
+	ForBranch(ctx context.Context, branchID string, from, to time.Time) ([]model.Closure, error)
+	// Delete removes a closure. In a branch scope only the branch's own
+	// closures can be removed.
+	Delete(ctx context.Context, id string) error
+}
+
+const closureColumns = `id, COALESCE(branch_id::text, ''), starts_on, ends_on, reason, created_at`
+
+var errClosureNotFound = apperr.NotFound("closure not found")
+
+type pgClosureRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewClosureRepo(db *pgxpool.Pool) ClosureRepo {
+	return &pgClosureRepo{db: db}
+}
+
+func (r *pgClosureRepo) Create(ctx context.Context, c *model.Closure) error {
+	c.BranchID = tenant.BranchID(ctx)
+	return conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO closures (branch_id, starts_on, ends_on, reason) VALUES (NULLIF($1, '')::uuid, $2, $3, $4)
+		RETURNING id, created_at`,
+		c.BranchID, c.StartsOn, c.EndsOn, c.Reason,
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+func (r *pgClosureRepo) List(ctx context.Context, from, to time.Time) ([]model.Closure, error) {
+	return r.list(ctx, tenant.BranchID(ctx), from, to)
+}
+
+func (r *pgClosureRepo) ForBranch(ctx context.Context, branchID string, from, to time.Time) ([]model.Closure, error) {
+	return r.list(ctx, branchID, from, to)
+}
+
+func (r *pgClosureRepo) list(ctx context.Context, branchID string, from, to time.Time) ([]model.Closure, error) {
+	conds := []string{"ends_on >= $1", "starts_on <= $2"}
+	args := []interface{}{from, to}
+	if branchID != "" {
+		args = append(args, branchID)
+		conds = append(conds, "(branch_id IS NULL OR branch_id = $3)")
+	}
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+closureColumns+` FROM closures`+where(conds...)+` ORDER BY starts_on, ends_on, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Closure, error) {
+		var c model.Closure
+		err := row.Scan(&c.ID, &c.BranchID, &c.StartsOn, &c.EndsOn, &c.Reason, &c.CreatedAt)
+		return c, err
+	})
+}
+
+func (r *pgClosureRepo) Delete(ctx context.Context, id string) error {
+	scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM closures`+where(append([]string{"id = $1"}, scope...)...), args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errClosureNotFound
+	}
+	return nil
+}
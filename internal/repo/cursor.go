@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// Cursors are opaque to clients: base64("<RFC3339Nano sort key>|<id>").
+// Every list query orders by (sort key DESC, id DESC) so the pair is unique.
+
+func encodeCursor(ts time.Time, id string) string {
+	raw := ts.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", apperr.Validation("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", apperr.Validation("invalid cursor")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", apperr.Validation("invalid cursor")
+	}
+	return ts, parts[1], nil
+}
+
+// trimPage drops the look-ahead row fetched past the limit and returns the
+// cursor for the next page, or "" when this is the last page.
+func trimPage[T any](items []T, limit int, cursorOf func(T) string) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, cursorOf(items[len(items)-1])
+}
+
+// pageQuery appends the pagination arguments to args and returns the keyset
+// condition (empty without a cursor) and the ORDER BY/LIMIT/OFFSET tail.
+// One extra row is requested so callers can tell whether another page exists.
+func pageQuery(p model.PageRequest, sortCol string, args []interface{}) (string, string, []interface{}, error) {
+	cond := ""
+	offset := p.Offset
+	if p.Cursor != "" {
+		ts, id, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return "", "", nil, err
+		}
+		args = append(args, ts, id)
+		cond = fmt.Sprintf("(%s, id) < ($%d, $%d)", sortCol, len(args)-1, len(args))
+		offset = 0
+	}
+	args = append(args, p.Limit+1, offset)
+	tail := fmt.Sprintf(" ORDER BY %s DESC, id DESC LIMIT $%d OFFSET $%d", sortCol, len(args)-1, len(args))
+	return cond, tail, args, nil
+}
+
+// where joins the non-empty conditions into a WHERE clause.
+func where(conds ...string) string {
+	out := []string{}
+	for _, c := range conds {
+		if c != "" {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(out, " AND ")
+}
+
+// streamBatch is how many rows each query of a keyset stream fetches. A
+// stream is a series of short queries rather than one long one, so even a
+// huge export never holds a snapshot, or a replica's replay, for its whole
+// run.
+const streamBatch = 1000
+
+// streamAfter hands fn the rows after afterID, in id order. next queries the
+// streamBatch rows following an id ("" for the first) and scan reads one of
+// them along with its id; a short batch ends the stream. Iteration stops at
+// the first error returned by fn.
+func streamAfter[T any](afterID string, next func(afterID string) (pgx.Rows, error), scan func(pgx.Rows) (T, string, error), fn func(T) error) error {
+	batch := func() (int, error) {
+		rows, err := next(afterID)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			v, id, err := scan(rows)
+			if err != nil {
+				return n, err
+			}
+			n++
+			afterID = id
+			if err := fn(v); err != nil {
+				return n, err
+			}
+		}
+		return n, rows.Err()
+	}
+	for {
+		n, err := batch()
+		if err != nil || n < streamBatch {
+			return err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	cursor := encodeCursor(ts, "book-1")
+
+	gotTS, gotID, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	require.True(t, ts.Equal(gotTS))
+	require.Equal(t, "book-1", gotID)
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	_, _, err := decodeCursor("not-a-cursor!")
+	require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func TestPageQuery_WithCursor(t *testing.T) {
+	cursor := encodeCursor(time.Now(), "id-1")
+	cond, tail, args, err := pageQuery(model.PageRequest{Limit: 10, Offset: 5, Cursor: cursor}, "borrowed_at", []interface{}{"user-1"})
+	require.NoError(t, err)
+	require.Equal(t, "(borrowed_at, id) < ($2, $3)", cond)
+	require.Equal(t, " ORDER BY borrowed_at DESC, id DESC LIMIT $4 OFFSET $5", tail)
+	require.Len(t, args, 5)
+	require.Equal(t, 11, args[3])
+	require.Equal(t, 0, args[4])
+}
+
+func TestTrimPage(t *testing.T) {
+	items, next := trimPage([]string{"a", "b", "c"}, 2, func(s string) string { return s })
+	require.Equal(t, []string{"a", "b"}, items)
+	require.Equal(t, "b", next)
+
+	items, next = trimPage([]string{"a"}, 2, func(s string) string { return s })
+	require.Equal(t, []string{"a"}, items)
+	require.Empty(t, next)
+}
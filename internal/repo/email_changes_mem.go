@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memEmailChangeRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryEmailChangeRepo(s *MemoryStore) EmailChangeRepo {
+	return &memEmailChangeRepo{s: s}
+}
+
+func (r *memEmailChangeRepo) Save(ctx context.Context, c *model.EmailChange) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[c.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	c.CreatedAt = time.Now().UTC()
+	r.s.data.emailChanges[c.UserID] = *c
+	return nil
+}
+
+func (r *memEmailChangeRepo) Take(ctx context.Context, tokenHash string, now time.Time) (*model.EmailChange, error) {
+	defer r.s.lock(ctx)()
+	for id, c := range r.s.data.emailChanges {
+		if c.TokenHash != tokenHash {
+			continue
+		}
+		delete(r.s.data.emailChanges, id)
+		if _, ok := r.s.data.users[id]; !ok || !c.ExpiresAt.After(now) {
+			break
+		}
+		return &c, nil
+	}
+	return nil, apperr.NotFound("email change not found")
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// EmailChangeRepo stores the email changes waiting to be confirmed, at most
+// one per user.
+type EmailChangeRepo interface {
+	// Save stores c, replacing the user's earlier change if any, and sets
+	// c.CreatedAt.
+	Save(ctx context.Context, c *model.EmailChange) error
+	// Take removes and returns the change with the token hash, returning a
+	// NotFound error when there is none or it expired before now.
+	Take(ctx context.Context, tokenHash string, now time.Time) (*model.EmailChange, error)
+}
+
+type pgEmailChangeRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewEmailChangeRepo(db *pgxpool.Pool) EmailChangeRepo {
+	return &pgEmailChangeRepo{db: db}
+}
+
+func (r *pgEmailChangeRepo) Save(ctx context.Context, c *model.EmailChange) error {
+	return conn(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET new_email = EXCLUDED.new_email, token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at, created_at = now()
+		RETURNING created_at`,
+		c.UserID, c.NewEmail, c.TokenHash, c.ExpiresAt).Scan(&c.CreatedAt)
+}
+
+func (r *pgEmailChangeRepo) Take(ctx context.Context, tokenHash string, now time.Time) (*model.EmailChange, error) {
+	var c model.EmailChange
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		DELETE FROM email_changes WHERE token_hash = $1
+		RETURNING user_id::text, new_email, token_hash, expires_at, created_at`,
+		tokenHash).Scan(&c.UserID, &c.NewEmail, &c.TokenHash, &c.ExpiresAt, &c.CreatedAt)
+	if isNoRows(err) || (err == nil && !c.ExpiresAt.After(now)) {
+		return nil, apperr.NotFound("email change not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
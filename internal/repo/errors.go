@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+)
+
+// SQLSTATEs Postgres reports for unique and foreign key constraint failures.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+// uniqueViolation reports whether err is a unique constraint failure and,
+// if so, the name of the violated constraint.
+func uniqueViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
+
+// foreignKeyViolation reports whether err is a foreign key constraint failure.
+func foreignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation
+}
+
+// DuplicateISBNError reports that another book in the same branch already
+// has the ISBN. It is a Conflict; callers that want the ISBN use errors.As.
+type DuplicateISBNError struct {
+	ISBN string
+}
+
+func (e *DuplicateISBNError) Error() string {
+	return fmt.Sprintf("a book with ISBN %s already exists", e.ISBN)
+}
+
+func (e *DuplicateISBNError) Unwrap() error {
+	return apperr.ErrConflict
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memExtensionRequestRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryExtensionRequestRepo(s *MemoryStore) ExtensionRequestRepo {
+	return &memExtensionRequestRepo{s: s}
+}
+
+func (r *memExtensionRequestRepo) Create(ctx context.Context, er *model.ExtensionRequest) error {
+	defer r.s.lock(ctx)()
+	b, ok := r.s.data.bookings[er.BookingID]
+	if !ok {
+		return apperr.NotFound("booking not found")
+	}
+	for _, other := range r.s.data.extensions {
+		if other.BookingID == er.BookingID && other.Status == model.ExtensionPending {
+			return errExtensionPending
+		}
+	}
+	er.ID = uuid.New().String()
+	er.BranchID = b.BranchID
+	er.Status = model.ExtensionPending
+	er.CreatedAt = time.Now().UTC()
+	r.s.data.extensions[er.ID] = *er
+	return nil
+}
+
+func (r *memExtensionRequestRepo) GetByID(ctx context.Context, id string) (*model.ExtensionRequest, error) {
+	defer r.s.lock(ctx)()
+	er, ok := r.s.data.extensions[id]
+	if !ok || !inBranch(ctx, er.BranchID) {
+		return nil, apperr.NotFound("extension request not found")
+	}
+	return &er, nil
+}
+
+// GetByIDForUpdate is GetByID; the transaction already holds the store.
+func (r *memExtensionRequestRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.ExtensionRequest, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memExtensionRequestRepo) List(ctx context.Context, p model.PageRequest, f model.ExtensionRequestFilter) (model.Page[model.ExtensionRequest], error) {
+	defer r.s.lock(ctx)()
+	requests := []model.ExtensionRequest{}
+	for _, er := range r.s.data.extensions {
+		if !inBranch(ctx, er.BranchID) || (f.Status != "" && er.Status != f.Status) || (f.UserID != "" && er.UserID != f.UserID) {
+			continue
+		}
+		requests = append(requests, er)
+	}
+	return memPage(requests, p, func(er model.ExtensionRequest) (time.Time, string) { return er.CreatedAt, er.ID })
+}
+
+func (r *memExtensionRequestRepo) Decide(ctx context.Context, id, status, decidedBy, comment string, decidedAt time.Time) error {
+	defer r.s.lock(ctx)()
+	er, ok := r.s.data.extensions[id]
+	if !ok {
+		return apperr.NotFound("extension request not found")
+	}
+	er.Status = status
+	er.DecidedBy = decidedBy
+	er.Comment = comment
+	er.DecidedAt = &decidedAt
+	r.s.data.extensions[id] = er
+	return nil
+}
@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// ExtensionRequestRepo stores requests to extend loans. Requests are
+// scoped to the branch of their booking.
+type ExtensionRequestRepo interface {
+	// Create stores er in its booking's branch. It returns a Conflict
+	// error if the booking has a request waiting for a decision.
+	Create(ctx context.Context, er *model.ExtensionRequest) error
+	GetByID(ctx context.Context, id string) (*model.ExtensionRequest, error)
+	// GetByIDForUpdate is GetByID holding a lock on the request until the
+	// transaction ends.
+	GetByIDForUpdate(ctx context.Context, id string) (*model.ExtensionRequest, error)
+	// List returns the requests matching f, newest first.
+	List(ctx context.Context, p model.PageRequest, f model.ExtensionRequestFilter) (model.Page[model.ExtensionRequest], error)
+	// Decide records the admin decidedBy's decision on the request.
+	Decide(ctx context.Context, id, status, decidedBy, comment string, decidedAt time.Time) error
+}
+
+const extensionRequestColumns = `id, booking_id, user_id, branch_id, current_due_date, requested_due_date, reason, status,
+	COALESCE(decided_by::text, ''), decision_comment, decided_at, created_at`
+
+var errExtensionPending = apperr.Conflict("this booking already has an extension request waiting for a decision")
+
+func extensionRequestDest(er *model.ExtensionRequest) []interface{} {
+	return []interface{}{&er.ID, &er.BookingID, &er.UserID, &er.BranchID, &er.CurrentDueDate, &er.RequestedDueDate,
+		&er.Reason, &er.Status, &er.DecidedBy, &er.Comment, &er.DecidedAt, &er.CreatedAt}
+}
+
+type pgExtensionRequestRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewExtensionRequestRepo(db *pgxpool.Pool) ExtensionRequestRepo {
+	return &pgExtensionRequestRepo{db: db}
+}
+
+func (r *pgExtensionRequestRepo) Create(ctx context.Context, er *model.ExtensionRequest) error {
+	er.Status = model.ExtensionPending
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO extension_requests (booking_id, user_id, branch_id, current_due_date, requested_due_date, reason)
+		VALUES ($1, $2, (SELECT branch_id FROM bookings WHERE id = $1), $3, $4, $5)
+		RETURNING id, branch_id, created_at`,
+		er.BookingID, er.UserID, er.CurrentDueDate, er.RequestedDueDate, er.Reason,
+	).Scan(&er.ID, &er.BranchID, &er.CreatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return errExtensionPending
+	}
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("booking not found")
+	}
+	return err
+}
+
+func (r *pgExtensionRequestRepo) get(ctx context.Context, id, suffix string) (*model.ExtensionRequest, error) {
+	er := &model.ExtensionRequest{}
+	scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+extensionRequestColumns+` FROM extension_requests`+where(append([]string{"id = $1"}, scope...)...)+suffix, args...,
+	).Scan(extensionRequestDest(er)...)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("extension request not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return er, nil
+}
+
+func (r *pgExtensionRequestRepo) GetByID(ctx context.Context, id string) (*model.ExtensionRequest, error) {
+	return r.get(ctx, id, "")
+}
+
+func (r *pgExtensionRequestRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.ExtensionRequest, error) {
+	return r.get(ctx, id, " FOR UPDATE")
+}
+
+func (r *pgExtensionRequestRepo) List(ctx context.Context, p model.PageRequest, f model.ExtensionRequestFilter) (model.Page[model.ExtensionRequest], error) {
+	page := model.Page[model.ExtensionRequest]{Items: []model.ExtensionRequest{}}
+	conds, args := extensionRequestFilter(f)
+	scope, args := branchScope(ctx, "branch_id", args)
+	conds = append(conds, scope...)
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM extension_requests`+where(conds...), args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", args)
+	if err != nil {
+		return page, err
+	}
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT `+extensionRequestColumns+` FROM extension_requests`+where(append(conds, keyset)...)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	page.Items, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.ExtensionRequest, error) {
+		var er model.ExtensionRequest
+		err := row.Scan(extensionRequestDest(&er)...)
+		return er, err
+	})
+	if err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(er model.ExtensionRequest) string {
+		return encodeCursor(er.CreatedAt, er.ID)
+	})
+	return page, nil
+}
+
+// extensionRequestFilter returns the WHERE conditions and arguments for f.
+func extensionRequestFilter(f model.ExtensionRequestFilter) ([]string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.UserID != "" {
+		add("user_id = $%d", f.UserID)
+	}
+	return conds, args
+}
+
+func (r *pgExtensionRequestRepo) Decide(ctx context.Context, id, status, decidedBy, comment string, decidedAt time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE extension_requests SET status = $2, decided_by = $3, decision_comment = $4, decided_at = $5 WHERE id = $1`,
+		id, status, decidedBy, comment, decidedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("extension request not found")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memFinePolicyRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryFinePolicyRepo(s *MemoryStore) FinePolicyRepo {
+	return &memFinePolicyRepo{s: s}
+}
+
+func (r *memFinePolicyRepo) Get(ctx context.Context) (*model.FinePolicy, error) {
+	defer r.s.lock(ctx)()
+	if r.s.data.finePolicy == nil {
+		return nil, nil
+	}
+	p := *r.s.data.finePolicy
+	return &p, nil
+}
+
+func (r *memFinePolicyRepo) Save(ctx context.Context, p *model.FinePolicy) error {
+	defer r.s.lock(ctx)()
+	now := time.Now().UTC()
+	p.UpdatedAt = &now
+	saved := *p
+	r.s.data.finePolicy = &saved
+	return nil
+}
@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// FinePolicyRepo stores the fine policy admins set.
+type FinePolicyRepo interface {
+	// Get returns the stored policy, or nil when none was ever saved.
+	Get(ctx context.Context) (*model.FinePolicy, error)
+	// Save replaces the stored policy, setting p.UpdatedAt.
+	Save(ctx context.Context, p *model.FinePolicy) error
+}
+
+type pgFinePolicyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewFinePolicyRepo(db *pgxpool.Pool) FinePolicyRepo {
+	return &pgFinePolicyRepo{db: db}
+}
+
+func (r *pgFinePolicyRepo) Get(ctx context.Context) (*model.FinePolicy, error) {
+	var p model.FinePolicy
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT grace_days, per_day_cents, max_cents, currency, COALESCE(updated_by::text, ''), updated_at FROM fine_policy`).
+		Scan(&p.GraceDays, &p.PerDayCents, &p.MaxCents, &p.Currency, &p.UpdatedBy, &p.UpdatedAt)
+	if isNoRows(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *pgFinePolicyRepo) Save(ctx context.Context, p *model.FinePolicy) error {
+	return conn(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO fine_policy (grace_days, per_day_cents, max_cents, currency, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, now())
+		ON CONFLICT (id) DO UPDATE SET grace_days = EXCLUDED.grace_days, per_day_cents = EXCLUDED.per_day_cents,
+			max_cents = EXCLUDED.max_cents, currency = EXCLUDED.currency,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		p.GraceDays, p.PerDayCents, p.MaxCents, p.Currency, p.UpdatedBy).Scan(&p.UpdatedAt)
+}
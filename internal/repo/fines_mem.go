@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memFineRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryFineRepo(s *MemoryStore) FineRepo {
+	return &memFineRepo{s: s}
+}
+
+func (r *memFineRepo) Create(ctx context.Context, f *model.Fine) error {
+	defer r.s.lock(ctx)()
+	for _, other := range r.s.data.fines {
+		if other.BookingID == f.BookingID {
+			return apperr.Conflict("this booking has been fined already")
+		}
+	}
+	f.ID = uuid.New().String()
+	f.Status = model.FineUnpaid
+	f.CreatedAt = time.Now().UTC()
+	r.s.data.fines[f.ID] = *f
+	return nil
+}
+
+func (r *memFineRepo) GetByID(ctx context.Context, id string) (*model.Fine, error) {
+	defer r.s.lock(ctx)()
+	f, ok := r.s.data.fines[id]
+	if !ok {
+		return nil, apperr.NotFound("fine not found")
+	}
+	return &f, nil
+}
+
+func (r *memFineRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.Fine, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memFineRepo) ListByUser(ctx context.Context, userID string) ([]model.Fine, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Fine{}
+	for _, f := range r.s.data.fines {
+		if f.UserID == userID {
+			out = append(out, f)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Fine) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+func (r *memFineRepo) MarkPaid(ctx context.Context, id string, paidAt time.Time) error {
+	defer r.s.lock(ctx)()
+	f, ok := r.s.data.fines[id]
+	if !ok {
+		return apperr.NotFound("fine not found")
+	}
+	f.Status = model.FinePaid
+	f.PaidAt = &paidAt
+	r.s.data.fines[id] = f
+	return nil
+}
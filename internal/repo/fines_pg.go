@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// FineRepo stores the fines for late returns.
+type FineRepo interface {
+	// Create returns a Conflict error if the booking was fined already.
+	Create(ctx context.Context, f *model.Fine) error
+	GetByID(ctx context.Context, id string) (*model.Fine, error)
+	// GetByIDForUpdate is GetByID holding a lock on the fine until the
+	// transaction ends.
+	GetByIDForUpdate(ctx context.Context, id string) (*model.Fine, error)
+	// ListByUser returns the user's fines, newest first.
+	ListByUser(ctx context.Context, userID string) ([]model.Fine, error)
+	MarkPaid(ctx context.Context, id string, paidAt time.Time) error
+}
+
+const fineColumns = `id, user_id, booking_id, days_late, amount_cents, currency, status, created_at, paid_at`
+
+func scanFine(row pgx.Row) (*model.Fine, error) {
+	var f model.Fine
+	err := row.Scan(&f.ID, &f.UserID, &f.BookingID, &f.DaysLate, &f.AmountCents, &f.Currency, &f.Status, &f.CreatedAt, &f.PaidAt)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("fine not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+type pgFineRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewFineRepo(db *pgxpool.Pool) FineRepo {
+	return &pgFineRepo{db: db}
+}
+
+func (r *pgFineRepo) Create(ctx context.Context, f *model.Fine) error {
+	f.Status = model.FineUnpaid
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO fines (user_id, booking_id, days_late, amount_cents, currency) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		f.UserID, f.BookingID, f.DaysLate, f.AmountCents, f.Currency,
+	).Scan(&f.ID, &f.CreatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("this booking has been fined already")
+	}
+	return err
+}
+
+func (r *pgFineRepo) GetByID(ctx context.Context, id string) (*model.Fine, error) {
+	return scanFine(conn(ctx, r.db).QueryRow(ctx, `SELECT `+fineColumns+` FROM fines WHERE id = $1`, id))
+}
+
+func (r *pgFineRepo) GetByIDForUpdate(ctx context.Context, id string) (*model.Fine, error) {
+	return scanFine(conn(ctx, r.db).QueryRow(ctx, `SELECT `+fineColumns+` FROM fines WHERE id = $1 FOR UPDATE`, id))
+}
+
+func (r *pgFineRepo) ListByUser(ctx context.Context, userID string) ([]model.Fine, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+fineColumns+` FROM fines WHERE user_id = $1 ORDER BY created_at DESC, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Fine, error) {
+		f, err := scanFine(row)
+		if err != nil {
+			return model.Fine{}, err
+		}
+		return *f, nil
+	})
+}
+
+func (r *pgFineRepo) MarkPaid(ctx context.Context, id string, paidAt time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE fines SET status = 'PAID', paid_at = $2 WHERE id = $1`, id, paidAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("fine not found")
+	}
+	return nil
+}
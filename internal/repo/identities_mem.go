@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memIdentityRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryIdentityRepo(s *MemoryStore) IdentityRepo {
+	return &memIdentityRepo{s: s}
+}
+
+func identityKey(provider, subject string) string {
+	return provider + "\x00" + subject
+}
+
+func (r *memIdentityRepo) UserID(ctx context.Context, provider, subject string) (string, error) {
+	defer r.s.lock(ctx)()
+	id, ok := r.s.data.identities[identityKey(provider, subject)]
+	if !ok {
+		return "", apperr.NotFound("identity not linked")
+	}
+	return id.UserID, nil
+}
+
+func (r *memIdentityRepo) Link(ctx context.Context, id *model.UserIdentity) error {
+	defer r.s.lock(ctx)()
+	key := identityKey(id.Provider, id.Subject)
+	if _, ok := r.s.data.identities[key]; ok {
+		return apperr.Conflict("identity already linked")
+	}
+	if _, ok := r.s.data.users[id.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	if id.CreatedAt.IsZero() {
+		id.CreatedAt = time.Now().UTC()
+	}
+	r.s.data.identities[key] = *id
+	return nil
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// IdentityRepo links external identities to users.
+type IdentityRepo interface {
+	// UserID returns the user the identity is linked to, or a NotFound
+	// error if it isn't linked.
+	UserID(ctx context.Context, provider, subject string) (string, error)
+	// Link links the identity to its user. It returns a Conflict error if
+	// the identity is already linked.
+	Link(ctx context.Context, id *model.UserIdentity) error
+}
+
+type pgIdentityRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepo(db *pgxpool.Pool) IdentityRepo {
+	return &pgIdentityRepo{db: db}
+}
+
+func (r *pgIdentityRepo) UserID(ctx context.Context, provider, subject string) (string, error) {
+	var userID string
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT user_id FROM user_identities WHERE provider=$1 AND subject=$2`,
+		provider, subject).Scan(&userID)
+	if isNoRows(err) {
+		return "", apperr.NotFound("identity not linked")
+	}
+	return userID, err
+}
+
+func (r *pgIdentityRepo) Link(ctx context.Context, id *model.UserIdentity) error {
+	if id.CreatedAt.IsZero() {
+		id.CreatedAt = time.Now().UTC()
+	}
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+		VALUES ($1,$2,$3,$4,$5)`,
+		id.Provider, id.Subject, id.UserID, id.Email, id.CreatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("identity already linked")
+	}
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("user not found")
+	}
+	return err
+}
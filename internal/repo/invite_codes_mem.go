@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memInviteCodeRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryInviteCodeRepo(s *MemoryStore) InviteCodeRepo {
+	return &memInviteCodeRepo{s: s}
+}
+
+func (r *memInviteCodeRepo) Create(ctx context.Context, c *model.InviteCode) error {
+	defer r.s.lock(ctx)()
+	for _, other := range r.s.data.inviteCodes {
+		if other.Hash == c.Hash {
+			return apperr.Conflict("invite code already exists")
+		}
+	}
+	c.ID = uuid.New().String()
+	c.Uses = 0
+	c.CreatedAt = time.Now().UTC()
+	r.s.data.inviteCodes[c.ID] = *c
+	return nil
+}
+
+func (r *memInviteCodeRepo) List(ctx context.Context) ([]model.InviteCode, error) {
+	defer r.s.lock(ctx)()
+	codes := []model.InviteCode{}
+	for _, c := range r.s.data.inviteCodes {
+		codes = append(codes, c)
+	}
+	slices.SortFunc(codes, func(a, b model.InviteCode) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return codes, nil
+}
+
+func (r *memInviteCodeRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.inviteCodes[id]
+	if !ok || c.RevokedAt != nil {
+		return apperr.NotFound("invite code not found")
+	}
+	c.RevokedAt = &at
+	r.s.data.inviteCodes[id] = c
+	return nil
+}
+
+func (r *memInviteCodeRepo) Redeem(ctx context.Context, hash string, now time.Time) (*model.InviteCode, error) {
+	defer r.s.lock(ctx)()
+	for id, c := range r.s.data.inviteCodes {
+		if c.Hash != hash {
+			continue
+		}
+		if c.RevokedAt != nil || c.Uses >= c.MaxUses || !c.ExpiresAt.After(now) {
+			break
+		}
+		c.Uses++
+		r.s.data.inviteCodes[id] = c
+		return &c, nil
+	}
+	return nil, apperr.NotFound("invite code not found")
+}
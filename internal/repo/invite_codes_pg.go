@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// InviteCodeRepo stores registration invite codes by the hash of the code.
+type InviteCodeRepo interface {
+	Create(ctx context.Context, c *model.InviteCode) error
+	// List returns every code, newest first.
+	List(ctx context.Context) ([]model.InviteCode, error)
+	// Revoke revokes the code at at. It returns a NotFound error for an
+	// unknown or already revoked code.
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// Redeem counts a use of the code with hash and returns it, or returns
+	// a NotFound error when there is no such code or it is revoked, used
+	// up or expired at now.
+	Redeem(ctx context.Context, hash string, now time.Time) (*model.InviteCode, error)
+}
+
+const inviteCodeColumns = `id, prefix, code_hash, max_uses, uses, note, COALESCE(created_by::text, ''), created_at, expires_at, revoked_at`
+
+func inviteCodeDest(c *model.InviteCode) []interface{} {
+	return []interface{}{&c.ID, &c.Prefix, &c.Hash, &c.MaxUses, &c.Uses, &c.Note, &c.CreatedBy, &c.CreatedAt, &c.ExpiresAt, &c.RevokedAt}
+}
+
+type pgInviteCodeRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewInviteCodeRepo(db *pgxpool.Pool) InviteCodeRepo {
+	return &pgInviteCodeRepo{db: db}
+}
+
+func (r *pgInviteCodeRepo) Create(ctx context.Context, c *model.InviteCode) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO invite_codes (prefix, code_hash, max_uses, note, created_by, expires_at)
+		VALUES ($1,$2,$3,$4,NULLIF($5,'')::uuid,$6)
+		RETURNING `+inviteCodeColumns,
+		c.Prefix, c.Hash, c.MaxUses, c.Note, c.CreatedBy, c.ExpiresAt,
+	).Scan(inviteCodeDest(c)...)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("invite code already exists")
+	}
+	return err
+}
+
+func (r *pgInviteCodeRepo) List(ctx context.Context) ([]model.InviteCode, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+inviteCodeColumns+` FROM invite_codes ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.InviteCode, error) {
+		var c model.InviteCode
+		err := row.Scan(inviteCodeDest(&c)...)
+		return c, err
+	})
+}
+
+func (r *pgInviteCodeRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE invite_codes SET revoked_at=$2 WHERE id=$1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("invite code not found")
+	}
+	return nil
+}
+
+// Redeem counts the use in the same statement that checks the code, so two
+// registrations racing for a code's last use can't both have it.
+func (r *pgInviteCodeRepo) Redeem(ctx context.Context, hash string, now time.Time) (*model.InviteCode, error) {
+	c := &model.InviteCode{}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`UPDATE invite_codes SET uses = uses + 1
+		WHERE code_hash=$1 AND revoked_at IS NULL AND uses < max_uses AND expires_at > $2
+		RETURNING `+inviteCodeColumns, hash, now,
+	).Scan(inviteCodeDest(c)...)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("invite code not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
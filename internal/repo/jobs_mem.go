@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memJobRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryJobRepo(s *MemoryStore) JobRepo {
+	return &memJobRepo{s: s}
+}
+
+func (r *memJobRepo) Enqueue(ctx context.Context, j *model.Job) error {
+	defer r.s.lock(ctx)()
+	now := time.Now().UTC()
+	j.ID = uuid.New().String()
+	j.Status = model.JobPending
+	j.Attempts = 0
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	if j.Payload == nil {
+		j.Payload = []byte("{}")
+	}
+	j.LastError, j.LockedAt, j.FinishedAt = "", nil, nil
+	j.CreatedAt, j.UpdatedAt = now, now
+	r.s.data.jobs[j.ID] = *j
+	return nil
+}
+
+func (r *memJobRepo) Claim(ctx context.Context, now time.Time, limit int) ([]model.Job, error) {
+	defer r.s.lock(ctx)()
+	due := []model.Job{}
+	for _, j := range r.s.data.jobs {
+		if j.Status == model.JobPending && !j.RunAt.After(now) {
+			due = append(due, j)
+		}
+	}
+	slices.SortFunc(due, func(a, b model.Job) int {
+		if c := a.RunAt.Compare(b.RunAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		j := &due[i]
+		j.Status = model.JobRunning
+		j.Attempts++
+		j.LockedAt = &now
+		j.UpdatedAt = now
+		r.s.data.jobs[j.ID] = *j
+	}
+	return due, nil
+}
+
+func (r *memJobRepo) Complete(ctx context.Context, id string) error {
+	return r.finish(ctx, id, func(j *model.Job, now time.Time) {
+		j.Status = model.JobDone
+		j.FinishedAt = &now
+	})
+}
+
+func (r *memJobRepo) Retry(ctx context.Context, id, lastErr string, runAt time.Time) error {
+	return r.finish(ctx, id, func(j *model.Job, now time.Time) {
+		j.Status = model.JobPending
+		j.LastError = lastErr
+		j.RunAt = runAt
+	})
+}
+
+func (r *memJobRepo) Bury(ctx context.Context, id, lastErr string) error {
+	return r.finish(ctx, id, func(j *model.Job, now time.Time) {
+		j.Status = model.JobDead
+		j.LastError = lastErr
+		j.FinishedAt = &now
+	})
+}
+
+func (r *memJobRepo) finish(ctx context.Context, id string, update func(j *model.Job, now time.Time)) error {
+	defer r.s.lock(ctx)()
+	j, ok := r.s.data.jobs[id]
+	if !ok || j.Status != model.JobRunning {
+		return apperr.NotFound("running job not found")
+	}
+	now := time.Now().UTC()
+	update(&j, now)
+	j.LockedAt = nil
+	j.UpdatedAt = now
+	r.s.data.jobs[id] = j
+	return nil
+}
+
+func (r *memJobRepo) Requeue(ctx context.Context, id string) (*model.Job, error) {
+	defer r.s.lock(ctx)()
+	j, ok := r.s.data.jobs[id]
+	if !ok {
+		return nil, apperr.NotFound("job not found")
+	}
+	if j.Status != model.JobDead {
+		return nil, apperr.Conflict("only dead jobs can be requeued")
+	}
+	now := time.Now().UTC()
+	j.Status = model.JobPending
+	j.Attempts = 0
+	j.RunAt = now
+	j.FinishedAt = nil
+	j.UpdatedAt = now
+	r.s.data.jobs[id] = j
+	return &j, nil
+}
+
+func (r *memJobRepo) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	defer r.s.lock(ctx)()
+	now := time.Now().UTC()
+	n := 0
+	for id, j := range r.s.data.jobs {
+		if j.Status != model.JobRunning || j.LockedAt == nil || !j.LockedAt.Before(cutoff) {
+			continue
+		}
+		j.Status = model.JobPending
+		if j.Attempts >= j.MaxAttempts {
+			j.Status = model.JobDead
+			j.FinishedAt = &now
+		}
+		j.LastError = "worker stopped before the job finished"
+		j.RunAt, j.LockedAt, j.UpdatedAt = now, nil, now
+		r.s.data.jobs[id] = j
+		n++
+	}
+	return n, nil
+}
+
+func (r *memJobRepo) GetByID(ctx context.Context, id string) (*model.Job, error) {
+	defer r.s.lock(ctx)()
+	j, ok := r.s.data.jobs[id]
+	if !ok {
+		return nil, apperr.NotFound("job not found")
+	}
+	return &j, nil
+}
+
+func (r *memJobRepo) List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error) {
+	defer r.s.lock(ctx)()
+	jobs := []model.Job{}
+	for _, j := range r.s.data.jobs {
+		if (f.Status == "" || j.Status == f.Status) && (f.Kind == "" || j.Kind == f.Kind) {
+			jobs = append(jobs, j)
+		}
+	}
+	return memPage(jobs, p, func(j model.Job) (time.Time, string) { return j.CreatedAt, j.ID })
+}
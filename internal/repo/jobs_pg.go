@@ -0,0 +1,212 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// JobRepo stores the background job queue. Jobs belong to no branch.
+type JobRepo interface {
+	// Enqueue stores a new pending job. It joins the surrounding
+	// transaction, so a job enqueued with a change is only run if the
+	// change commits.
+	Enqueue(ctx context.Context, j *model.Job) error
+	// Claim marks up to limit pending jobs due by now as running, counting
+	// an attempt, and returns them, longest due first. Concurrent callers
+	// claim different jobs.
+	Claim(ctx context.Context, now time.Time, limit int) ([]model.Job, error)
+	// Complete marks a running job done.
+	Complete(ctx context.Context, id string) error
+	// Retry makes a running job pending again from runAt, recording the
+	// error of the failed attempt.
+	Retry(ctx context.Context, id, lastErr string, runAt time.Time) error
+	// Bury marks a running job dead, recording the error that killed it.
+	Bury(ctx context.Context, id, lastErr string) error
+	// Requeue makes a dead job pending again with its attempts reset. It
+	// returns a Conflict error for a job that isn't dead.
+	Requeue(ctx context.Context, id string) (*model.Job, error)
+	// RecoverStale gives up on running jobs claimed before cutoff, whose
+	// worker must have died: they are retried, or buried when out of
+	// attempts. It returns how many there were.
+	RecoverStale(ctx context.Context, cutoff time.Time) (int, error)
+	GetByID(ctx context.Context, id string) (*model.Job, error)
+	// List returns one page of jobs matching f, newest first.
+	List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error)
+}
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, run_at, last_error, locked_at, finished_at, created_at, updated_at`
+
+func jobDest(j *model.Job) []interface{} {
+	return []interface{}{&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &j.LockedAt, &j.FinishedAt, &j.CreatedAt, &j.UpdatedAt}
+}
+
+func collectJobs(rows pgx.Rows) ([]model.Job, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Job, error) {
+		var j model.Job
+		err := row.Scan(jobDest(&j)...)
+		return j, err
+	})
+}
+
+type pgJobRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewJobRepo(db *pgxpool.Pool) JobRepo {
+	return &pgJobRepo{db: db}
+}
+
+func (r *pgJobRepo) Enqueue(ctx context.Context, j *model.Job) error {
+	runAt := j.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now().UTC()
+	}
+	return conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO jobs (kind, payload, max_attempts, run_at) VALUES ($1, $2, $3, $4)
+		RETURNING `+jobColumns,
+		j.Kind, j.Payload, j.MaxAttempts, runAt,
+	).Scan(jobDest(j)...)
+}
+
+func (r *pgJobRepo) Claim(ctx context.Context, now time.Time, limit int) ([]model.Job, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = $1, updated_at = $1
+		WHERE id IN (
+			SELECT id FROM jobs WHERE status = 'pending' AND run_at <= $1
+			ORDER BY run_at, id LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns,
+		now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectJobs(rows)
+}
+
+func (r *pgJobRepo) Complete(ctx context.Context, id string) error {
+	return r.finish(ctx,
+		`UPDATE jobs SET status = 'done', locked_at = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`, id)
+}
+
+func (r *pgJobRepo) Retry(ctx context.Context, id, lastErr string, runAt time.Time) error {
+	return r.finish(ctx,
+		`UPDATE jobs SET status = 'pending', last_error = $2, run_at = $3, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`, id, lastErr, runAt)
+}
+
+func (r *pgJobRepo) Bury(ctx context.Context, id, lastErr string) error {
+	return r.finish(ctx,
+		`UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`, id, lastErr)
+}
+
+// finish runs an update of one running job, which fails with NotFound if
+// the job has stopped running meanwhile, e.g. by being recovered as stale.
+func (r *pgJobRepo) finish(ctx context.Context, query string, args ...interface{}) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("running job not found")
+	}
+	return nil
+}
+
+func (r *pgJobRepo) Requeue(ctx context.Context, id string) (*model.Job, error) {
+	j := &model.Job{}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING `+jobColumns, id,
+	).Scan(jobDest(j)...)
+	if isNoRows(err) {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, apperr.Conflict("only dead jobs can be requeued")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (r *pgJobRepo) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE jobs SET
+			status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+			last_error = 'worker stopped before the job finished',
+			run_at = NOW(), locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < $1`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (r *pgJobRepo) GetByID(ctx context.Context, id string) (*model.Job, error) {
+	j := &model.Job{}
+	err := conn(ctx, r.db).QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id).Scan(jobDest(j)...)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("job not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (r *pgJobRepo) List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error) {
+	page := model.Page[model.Job]{Items: []model.Job{}}
+	conds, args := jobFilter(f)
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM jobs`+where(conds...), args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", args)
+	if err != nil {
+		return page, err
+	}
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT `+jobColumns+` FROM jobs`+where(append(conds, keyset)...)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	page.Items, err = collectJobs(rows)
+	if err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(j model.Job) string {
+		return encodeCursor(j.CreatedAt, j.ID)
+	})
+	return page, nil
+}
+
+// jobFilter returns the WHERE conditions and arguments for f.
+func jobFilter(f model.JobFilter) ([]string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.Kind != "" {
+		add("kind = $%d", f.Kind)
+	}
+	return conds, args
+}
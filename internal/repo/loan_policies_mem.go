@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memLoanPolicyRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryLoanPolicyRepo(s *MemoryStore) LoanPolicyRepo {
+	return &memLoanPolicyRepo{s: s}
+}
+
+func (r *memLoanPolicyRepo) ListPolicies(ctx context.Context) ([]model.LoanPolicy, error) {
+	defer r.s.lock(ctx)()
+	policies := []model.LoanPolicy{}
+	for _, p := range r.s.data.policies {
+		policies = append(policies, p)
+	}
+	slices.SortFunc(policies, func(a, b model.LoanPolicy) int { return cmp.Compare(a.Role, b.Role) })
+	return policies, nil
+}
+
+func (r *memLoanPolicyRepo) GetPolicy(ctx context.Context, role model.Role) (model.LoanPolicy, error) {
+	defer r.s.lock(ctx)()
+	p, ok := r.s.data.policies[role]
+	if !ok {
+		return model.LoanPolicy{Role: role}, apperr.NotFound("no loan policy for role " + string(role))
+	}
+	return p, nil
+}
+
+func (r *memLoanPolicyRepo) SavePolicy(ctx context.Context, p *model.LoanPolicy) error {
+	defer r.s.lock(ctx)()
+	p.UpdatedAt = time.Now().UTC()
+	r.s.data.policies[p.Role] = *p
+	return nil
+}
+
+func (r *memLoanPolicyRepo) GetBookRestriction(ctx context.Context, bookID string) (model.BookLoanRestriction, error) {
+	defer r.s.lock(ctx)()
+	lr, ok := r.s.data.restrictions[bookID]
+	if !ok {
+		return model.BookLoanRestriction{BookID: bookID}, apperr.NotFound("book has no loan restriction")
+	}
+	return lr, nil
+}
+
+func (r *memLoanPolicyRepo) SaveBookRestriction(ctx context.Context, lr *model.BookLoanRestriction) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.books[lr.BookID]; !ok {
+		return apperr.NotFound("book not found")
+	}
+	lr.UpdatedAt = time.Now().UTC()
+	r.s.data.restrictions[lr.BookID] = *lr
+	return nil
+}
+
+func (r *memLoanPolicyRepo) DeleteBookRestriction(ctx context.Context, bookID string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.restrictions[bookID]; !ok {
+		return apperr.NotFound("book has no loan restriction")
+	}
+	delete(r.s.data.restrictions, bookID)
+	return nil
+}
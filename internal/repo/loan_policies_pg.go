@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// LoanPolicyRepo stores the per-role loan policies and per-book loan
+// restrictions enforced when a book is borrowed.
+type LoanPolicyRepo interface {
+	ListPolicies(ctx context.Context) ([]model.LoanPolicy, error)
+	// GetPolicy returns a NotFound error when role has no stored policy.
+	GetPolicy(ctx context.Context, role model.Role) (model.LoanPolicy, error)
+	// SavePolicy creates or replaces the policy for p.Role.
+	SavePolicy(ctx context.Context, p *model.LoanPolicy) error
+	// GetBookRestriction returns a NotFound error when the book has none.
+	GetBookRestriction(ctx context.Context, bookID string) (model.BookLoanRestriction, error)
+	// SaveBookRestriction creates or replaces the restriction for r.BookID.
+	SaveBookRestriction(ctx context.Context, r *model.BookLoanRestriction) error
+	DeleteBookRestriction(ctx context.Context, bookID string) error
+}
+
+type pgLoanPolicyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewLoanPolicyRepo(db *pgxpool.Pool) LoanPolicyRepo {
+	return &pgLoanPolicyRepo{db: db}
+}
+
+func (r *pgLoanPolicyRepo) ListPolicies(ctx context.Context) ([]model.LoanPolicy, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT role, max_active_bookings, max_borrow_days, updated_at FROM loan_policies ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	policies := []model.LoanPolicy{}
+	for rows.Next() {
+		var p model.LoanPolicy
+		if err := rows.Scan(&p.Role, &p.MaxActiveBookings, &p.MaxBorrowDays, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (r *pgLoanPolicyRepo) GetPolicy(ctx context.Context, role model.Role) (model.LoanPolicy, error) {
+	p := model.LoanPolicy{Role: role}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT max_active_bookings, max_borrow_days, updated_at FROM loan_policies WHERE role=$1`,
+		role).Scan(&p.MaxActiveBookings, &p.MaxBorrowDays, &p.UpdatedAt)
+	if isNoRows(err) {
+		return p, apperr.NotFound("no loan policy for role " + string(role))
+	}
+	return p, err
+}
+
+func (r *pgLoanPolicyRepo) SavePolicy(ctx context.Context, p *model.LoanPolicy) error {
+	p.UpdatedAt = time.Now().UTC()
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO loan_policies (role, max_active_bookings, max_borrow_days, updated_at) VALUES ($1,$2,$3,$4)
+		ON CONFLICT (role) DO UPDATE SET max_active_bookings=EXCLUDED.max_active_bookings,
+			max_borrow_days=EXCLUDED.max_borrow_days, updated_at=EXCLUDED.updated_at`,
+		p.Role, p.MaxActiveBookings, p.MaxBorrowDays, p.UpdatedAt)
+	return err
+}
+
+func (r *pgLoanPolicyRepo) GetBookRestriction(ctx context.Context, bookID string) (model.BookLoanRestriction, error) {
+	lr := model.BookLoanRestriction{BookID: bookID}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT reference_only, max_borrow_days, updated_at FROM book_loan_restrictions WHERE book_id=$1`,
+		bookID).Scan(&lr.ReferenceOnly, &lr.MaxBorrowDays, &lr.UpdatedAt)
+	if isNoRows(err) {
+		return lr, apperr.NotFound("book has no loan restriction")
+	}
+	return lr, err
+}
+
+func (r *pgLoanPolicyRepo) SaveBookRestriction(ctx context.Context, lr *model.BookLoanRestriction) error {
+	lr.UpdatedAt = time.Now().UTC()
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO book_loan_restrictions (book_id, reference_only, max_borrow_days, updated_at) VALUES ($1,$2,$3,$4)
+		ON CONFLICT (book_id) DO UPDATE SET reference_only=EXCLUDED.reference_only,
+			max_borrow_days=EXCLUDED.max_borrow_days, updated_at=EXCLUDED.updated_at`,
+		lr.BookID, lr.ReferenceOnly, lr.MaxBorrowDays, lr.UpdatedAt)
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("book not found")
+	}
+	return err
+}
+
+func (r *pgLoanPolicyRepo) DeleteBookRestriction(ctx context.Context, bookID string) error {
+	cmdTag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM book_loan_restrictions WHERE book_id=$1`, bookID)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return apperr.NotFound("book has no loan restriction")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+type memLoginAttempt struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+type memLoginAttemptRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryLoginAttemptRepo(s *MemoryStore) LoginAttemptRepo {
+	return &memLoginAttemptRepo{s: s}
+}
+
+func (r *memLoginAttemptRepo) LockedUntil(ctx context.Context, keys []string, now time.Time) (time.Time, error) {
+	defer r.s.lock(ctx)()
+	var until time.Time
+	for _, key := range keys {
+		if a := r.s.data.attempts[key]; a.lockedUntil.After(now) && a.lockedUntil.After(until) {
+			until = a.lockedUntil
+		}
+	}
+	return until, nil
+}
+
+func (r *memLoginAttemptRepo) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	defer r.s.lock(ctx)()
+	a, ok := r.s.data.attempts[key]
+	if !ok || a.windowStart.Before(windowStart) {
+		a.failures, a.windowStart = 0, now
+	}
+	a.failures++
+	r.s.data.attempts[key] = a
+	return a.failures, nil
+}
+
+func (r *memLoginAttemptRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	defer r.s.lock(ctx)()
+	if a, ok := r.s.data.attempts[key]; ok {
+		a.lockedUntil, a.failures = until, 0
+		r.s.data.attempts[key] = a
+	}
+	return nil
+}
+
+func (r *memLoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	defer r.s.lock(ctx)()
+	delete(r.s.data.attempts, key)
+	return nil
+}
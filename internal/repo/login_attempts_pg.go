@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoginAttemptRepo counts failed logins per key so repeated failures can
+// lock the key out. Keys are opaque to the repo.
+type LoginAttemptRepo interface {
+	// LockedUntil returns the latest lockout expiry among keys that is still
+	// after now, or the zero time when none of them is locked.
+	LockedUntil(ctx context.Context, keys []string, now time.Time) (time.Time, error)
+	// RecordFailure counts a failure for key and returns the failures seen
+	// since windowStart; older failures are forgotten.
+	RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error)
+	// Lock locks key until the given time and resets its failure count.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets key entirely.
+	Reset(ctx context.Context, key string) error
+}
+
+type pgLoginAttemptRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginAttemptRepo(db *pgxpool.Pool) LoginAttemptRepo {
+	return &pgLoginAttemptRepo{db: db}
+}
+
+func (r *pgLoginAttemptRepo) LockedUntil(ctx context.Context, keys []string, now time.Time) (time.Time, error) {
+	var until *time.Time
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT MAX(locked_until) FROM login_attempts WHERE key = ANY($1) AND locked_until > $2`,
+		keys, now).Scan(&until)
+	if err != nil || until == nil {
+		return time.Time{}, err
+	}
+	return *until, nil
+}
+
+func (r *pgLoginAttemptRepo) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	var failures int
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO login_attempts AS la (key, failures, window_start) VALUES ($1, 1, $2)
+		 ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN la.window_start < $3 THEN 1 ELSE la.failures + 1 END,
+			window_start = CASE WHEN la.window_start < $3 THEN $2 ELSE la.window_start END
+		 RETURNING failures`,
+		key, now, windowStart).Scan(&failures)
+	return failures, err
+}
+
+func (r *pgLoginAttemptRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE login_attempts SET locked_until = $2, failures = 0 WHERE key = $1`, key, until)
+	return err
+}
+
+func (r *pgLoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
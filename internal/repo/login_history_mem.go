@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memLoginHistoryRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryLoginHistoryRepo(s *MemoryStore) LoginHistoryRepo {
+	return &memLoginHistoryRepo{s: s}
+}
+
+func (r *memLoginHistoryRepo) Record(ctx context.Context, l *model.LoginRecord) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[l.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	var before, sameIP, sameAgent bool
+	for _, other := range r.s.data.logins {
+		if other.UserID != l.UserID {
+			continue
+		}
+		before = true
+		sameIP = sameIP || other.IP == l.IP
+		sameAgent = sameAgent || other.UserAgent == l.UserAgent
+	}
+	l.ID = uuid.New().String()
+	l.NewDevice = before && !(sameIP && sameAgent)
+	l.CreatedAt = time.Now().UTC()
+	r.s.data.logins[l.ID] = *l
+	return nil
+}
+
+func (r *memLoginHistoryRepo) List(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.LoginRecord], error) {
+	defer r.s.lock(ctx)()
+	logins := []model.LoginRecord{}
+	for _, l := range r.s.data.logins {
+		if l.UserID == userID {
+			logins = append(logins, l)
+		}
+	}
+	return memPage(logins, p, func(l model.LoginRecord) (time.Time, string) { return l.CreatedAt, l.ID })
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// LoginHistoryRepo stores users' successful logins.
+type LoginHistoryRepo interface {
+	// Record stores l, setting its ID, CreatedAt and NewDevice: whether
+	// the user had logged in before, but not from l.IP or not with
+	// l.UserAgent. It returns a NotFound error for an unknown user.
+	Record(ctx context.Context, l *model.LoginRecord) error
+	// List returns the user's logins, newest first.
+	List(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.LoginRecord], error)
+}
+
+const loginRecordColumns = `id, user_id, ip, user_agent, new_device, created_at`
+
+type pgLoginHistoryRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginHistoryRepo(db *pgxpool.Pool) LoginHistoryRepo {
+	return &pgLoginHistoryRepo{db: db}
+}
+
+func (r *pgLoginHistoryRepo) Record(ctx context.Context, l *model.LoginRecord) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO login_history (user_id, ip, user_agent, new_device)
+		SELECT $1, $2, $3,
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1)
+			AND (NOT EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND ip = $2)
+				OR NOT EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND user_agent = $3))
+		RETURNING id, new_device, created_at`,
+		l.UserID, l.IP, l.UserAgent,
+	).Scan(&l.ID, &l.NewDevice, &l.CreatedAt)
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("user not found")
+	}
+	return err
+}
+
+func (r *pgLoginHistoryRepo) List(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.LoginRecord], error) {
+	page := model.Page[model.LoginRecord]{Items: []model.LoginRecord{}}
+	conds, args := []string{"user_id = $1"}, []interface{}{userID}
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM login_history`+where(conds...), args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", args)
+	if err != nil {
+		return page, err
+	}
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT `+loginRecordColumns+` FROM login_history`+where(append(conds, keyset)...)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	page.Items, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.LoginRecord, error) {
+		var l model.LoginRecord
+		err := row.Scan(&l.ID, &l.UserID, &l.IP, &l.UserAgent, &l.NewDevice, &l.CreatedAt)
+		return l, err
+	})
+	if err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(l model.LoginRecord) string {
+		return encodeCursor(l.CreatedAt, l.ID)
+	})
+	return page, nil
+}
@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memMaintenanceRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryMaintenanceRepo(s *MemoryStore) MaintenanceRepo {
+	return &memMaintenanceRepo{s: s}
+}
+
+func (r *memMaintenanceRepo) Get(ctx context.Context) (model.Maintenance, error) {
+	defer r.s.lock(ctx)()
+	return r.s.data.maintenance, nil
+}
+
+func (r *memMaintenanceRepo) Save(ctx context.Context, m *model.Maintenance) error {
+	defer r.s.lock(ctx)()
+	m.UpdatedAt = time.Now().UTC()
+	r.s.data.maintenance = *m
+	return nil
+}
@@ -0,0 +1,45 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// MaintenanceRepo stores the maintenance mode every instance follows.
+type MaintenanceRepo interface {
+	// Get returns the stored mode, which is off when none was ever saved.
+	Get(ctx context.Context) (model.Maintenance, error)
+	// Save replaces the stored mode, setting m.UpdatedAt.
+	Save(ctx context.Context, m *model.Maintenance) error
+}
+
+type pgMaintenanceRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewMaintenanceRepo(db *pgxpool.Pool) MaintenanceRepo {
+	return &pgMaintenanceRepo{db: db}
+}
+
+func (r *pgMaintenanceRepo) Get(ctx context.Context) (model.Maintenance, error) {
+	var m model.Maintenance
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT enabled, message, retry_after_seconds, COALESCE(updated_by::text, ''), updated_at FROM maintenance`).
+		Scan(&m.Enabled, &m.Message, &m.RetryAfterSeconds, &m.UpdatedBy, &m.UpdatedAt)
+	if isNoRows(err) {
+		return model.Maintenance{}, nil
+	}
+	return m, err
+}
+
+func (r *pgMaintenanceRepo) Save(ctx context.Context, m *model.Maintenance) error {
+	return conn(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO maintenance (enabled, message, retry_after_seconds, updated_by, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, now())
+		ON CONFLICT (id) DO UPDATE SET enabled = EXCLUDED.enabled, message = EXCLUDED.message,
+			retry_after_seconds = EXCLUDED.retry_after_seconds, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		m.Enabled, m.Message, m.RetryAfterSeconds, m.UpdatedBy).Scan(&m.UpdatedAt)
+}
@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+// MemoryStore holds the data of the in-memory repositories, which stand in
+// for Postgres in local development and tests. Nothing is persisted.
+//
+// Every call takes a single store-wide lock, and a transaction holds it
+// until it ends, so transactions are serializable and concurrent borrows
+// are ordered as they are by Postgres' row and advisory locks. A failed
+// transaction restores the data as it was when it began.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data memData
+}
+
+type memData struct {
+	books          map[string]model.Book
+	mergedBooks    map[string]model.Book // soft-deleted duplicates, see memBookRepo.Merge
+	deletedBooks   map[string]model.Book // soft-deleted titles, see memBookRepo.Delete
+	bookCategories map[string][]string
+	categories     map[string]model.Category
+	branches       map[string]model.Branch
+	users          map[string]model.User
+	notifyPrefs    map[string]model.NotificationPreferences
+	bookings       map[string]model.Booking
+	reminders      map[string]time.Time // booking ID to when its due-date reminder was sent
+	policies       map[model.Role]model.LoanPolicy
+	restrictions   map[string]model.BookLoanRestriction
+	attempts       map[string]memLoginAttempt
+	revocations    map[string]time.Time
+	sessions       map[string]memSession
+	logins         map[string]model.LoginRecord
+	calendarFeeds  map[string]int // user ID to feed version
+	identities     map[string]model.UserIdentity
+	apiKeys        map[string]model.APIKey
+	emailChanges   map[string]model.EmailChange    // by user ID
+	invitations    map[string]model.UserInvitation // by user ID
+	inviteCodes    map[string]model.InviteCode
+	reviews        map[string]model.Review
+	reservations   map[string]model.Reservation
+	closures       map[string]model.Closure
+	extensions     map[string]model.ExtensionRequest
+	announcements  map[string]model.Announcement
+	readingLists   map[string]model.ReadingList // without their books
+	listBooks      map[memListEntry]time.Time   // to when the book was added
+	jobs           map[string]model.Job
+	outbox         []memOutboxEvent
+	scheduledRuns  map[string]time.Time // "name|period" to when it ran
+	fines          map[string]model.Fine
+	payments       map[string]model.Payment
+	receipts       map[string]model.Receipt // by fine ID
+	finePolicy     *model.FinePolicy
+	maintenance    model.Maintenance
+	audit          []model.AuditEntry
+}
+
+// NewMemoryStore returns an empty store holding only the main branch, as
+// the migrations leave a new database.
+func NewMemoryStore() *MemoryStore {
+	now := time.Now().UTC()
+	return &MemoryStore{data: memData{
+		books:          map[string]model.Book{},
+		mergedBooks:    map[string]model.Book{},
+		deletedBooks:   map[string]model.Book{},
+		bookCategories: map[string][]string{},
+		categories:     map[string]model.Category{},
+		branches: map[string]model.Branch{
+			model.DefaultBranchID: {ID: model.DefaultBranchID, Code: "main", Name: "Main Library", CreatedAt: now, UpdatedAt: now},
+		},
+		users:         map[string]model.User{},
+		notifyPrefs:   map[string]model.NotificationPreferences{},
+		bookings:      map[string]model.Booking{},
+		reminders:     map[string]time.Time{},
+		policies:      map[model.Role]model.LoanPolicy{},
+		restrictions:  map[string]model.BookLoanRestriction{},
+		attempts:      map[string]memLoginAttempt{},
+		revocations:   map[string]time.Time{},
+		sessions:      map[string]memSession{},
+		logins:        map[string]model.LoginRecord{},
+		calendarFeeds: map[string]int{},
+		identities:    map[string]model.UserIdentity{},
+		apiKeys:       map[string]model.APIKey{},
+		emailChanges:  map[string]model.EmailChange{},
+		invitations:   map[string]model.UserInvitation{},
+		inviteCodes:   map[string]model.InviteCode{},
+		reviews:       map[string]model.Review{},
+		reservations:  map[string]model.Reservation{},
+		closures:      map[string]model.Closure{},
+		extensions:    map[string]model.ExtensionRequest{},
+		announcements: map[string]model.Announcement{},
+		readingLists:  map[string]model.ReadingList{},
+		listBooks:     map[memListEntry]time.Time{},
+		jobs:          map[string]model.Job{},
+		scheduledRuns: map[string]time.Time{},
+		fines:         map[string]model.Fine{},
+		payments:      map[string]model.Payment{},
+		receipts:      map[string]model.Receipt{},
+	}}
+}
+
+// clone copies the maps so a transaction can be rolled back. Stored values
+// are replaced rather than modified in place, so a shallow copy suffices.
+func (d memData) clone() memData {
+	return memData{
+		books:          maps.Clone(d.books),
+		mergedBooks:    maps.Clone(d.mergedBooks),
+		deletedBooks:   maps.Clone(d.deletedBooks),
+		bookCategories: maps.Clone(d.bookCategories),
+		categories:     maps.Clone(d.categories),
+		branches:       maps.Clone(d.branches),
+		users:          maps.Clone(d.users),
+		notifyPrefs:    maps.Clone(d.notifyPrefs),
+		bookings:       maps.Clone(d.bookings),
+		reminders:      maps.Clone(d.reminders),
+		policies:       maps.Clone(d.policies),
+		restrictions:   maps.Clone(d.restrictions),
+		attempts:       maps.Clone(d.attempts),
+		revocations:    maps.Clone(d.revocations),
+		sessions:       maps.Clone(d.sessions),
+		logins:         maps.Clone(d.logins),
+		calendarFeeds:  maps.Clone(d.calendarFeeds),
+		identities:     maps.Clone(d.identities),
+		apiKeys:        maps.Clone(d.apiKeys),
+		emailChanges:   maps.Clone(d.emailChanges),
+		invitations:    maps.Clone(d.invitations),
+		inviteCodes:    maps.Clone(d.inviteCodes),
+		reviews:        maps.Clone(d.reviews),
+		reservations:   maps.Clone(d.reservations),
+		closures:       maps.Clone(d.closures),
+		extensions:     maps.Clone(d.extensions),
+		announcements:  maps.Clone(d.announcements),
+		readingLists:   maps.Clone(d.readingLists),
+		listBooks:      maps.Clone(d.listBooks),
+		jobs:           maps.Clone(d.jobs),
+		outbox:         slices.Clone(d.outbox),
+		scheduledRuns:  maps.Clone(d.scheduledRuns),
+		fines:          maps.Clone(d.fines),
+		payments:       maps.Clone(d.payments),
+		receipts:       maps.Clone(d.receipts),
+		finePolicy:     d.finePolicy,
+		maintenance:    d.maintenance,
+		audit:          slices.Clone(d.audit),
+	}
+}
+
+type memTxKey struct{}
+
+// lock takes the store lock and returns its release, unless ctx belongs to
+// one of the store's transactions, which holds the lock already.
+func (s *MemoryStore) lock(ctx context.Context) func() {
+	if ctx.Value(memTxKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type memTxManager struct {
+	s *MemoryStore
+}
+
+func NewMemoryTxManager(s *MemoryStore) TxManager {
+	return &memTxManager{s: s}
+}
+
+// WithinTx keeps fn's changes when it returns nil and discards them
+// otherwise. A nested call joins the outer transaction.
+func (m *memTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memTxKey{}) == m.s {
+		return fn(ctx)
+	}
+
+	m.s.mu.Lock()
+	snapshot := m.s.data.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			m.s.data = snapshot
+		}
+		m.s.mu.Unlock()
+	}()
+
+	if err := fn(context.WithValue(ctx, memTxKey{}, m.s)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// inBranch reports whether a record of branchID is visible to ctx, which
+// sees every branch unless it is scoped to one.
+func inBranch(ctx context.Context, branchID string) bool {
+	id := tenant.BranchID(ctx)
+	return id == "" || id == branchID
+}
+
+// memPage orders items newest first by key, like the SQL list queries, and
+// cuts out the page p asks for.
+func memPage[T any](items []T, p model.PageRequest, key func(T) (time.Time, string)) (model.Page[T], error) {
+	page := model.Page[T]{Items: []T{}, Total: len(items)}
+	sort.Slice(items, func(i, j int) bool {
+		ti, idi := key(items[i])
+		tj, idj := key(items[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return idi > idj
+	})
+
+	start := p.Offset
+	if p.Cursor != "" {
+		ts, id, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return page, err
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			t, itemID := key(items[i])
+			return t.Before(ts) || (t.Equal(ts) && itemID < id)
+		})
+	}
+	start = min(start, len(items))
+	end := min(start+p.Limit+1, len(items))
+	page.Items = append(page.Items, items[start:end]...)
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(v T) string {
+		return encodeCursor(key(v))
+	})
+	return page, nil
+}
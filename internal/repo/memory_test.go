@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/clientip"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/impersonator"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTx_RollsBackOnError(t *testing.T) {
+	s := NewMemoryStore()
+	repos := NewMemoryRepos(s)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	err := repos.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, repos.Books.Create(ctx, &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "1"}))
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	page, err := repos.Books.List(ctx, model.PageRequest{Limit: 10}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Zero(t, page.Total)
+}
+
+func TestMemoryBooks_CursorPagination(t *testing.T) {
+	books := NewMemoryBookRepo(NewMemoryStore())
+	ctx := context.Background()
+	for _, isbn := range []string{"1", "2", "3"} {
+		require.NoError(t, books.Create(ctx, &model.Book{Title: "Book " + isbn, Author: "A", ISBN: isbn}))
+	}
+
+	first, err := books.List(ctx, model.PageRequest{Limit: 2}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 3, first.Total)
+	require.Len(t, first.Items, 2)
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := books.List(ctx, model.PageRequest{Limit: 2, Cursor: first.NextCursor}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	require.Empty(t, second.NextCursor)
+	require.NotContains(t, []string{first.Items[0].ID, first.Items[1].ID}, second.Items[0].ID)
+}
+
+func TestMemoryBooks_StreamResumesAfterID(t *testing.T) {
+	books := NewMemoryBookRepo(NewMemoryStore())
+	ctx := context.Background()
+	for _, isbn := range []string{"1", "2", "3"} {
+		require.NoError(t, books.Create(ctx, &model.Book{Title: "Book " + isbn, Author: "A", ISBN: isbn}))
+	}
+
+	stream := func(afterID string) []string {
+		ids := []string{}
+		require.NoError(t, books.Stream(ctx, afterID, func(b *model.Book) error {
+			ids = append(ids, b.ID)
+			return nil
+		}))
+		return ids
+	}
+	all := stream("")
+	require.Len(t, all, 3)
+	require.Equal(t, all[1:], stream(all[0]))
+	require.Empty(t, stream(all[2]))
+}
+
+func TestMemoryBooks_ScopedToBranch(t *testing.T) {
+	repos := NewMemoryRepos(NewMemoryStore())
+	north := &model.Branch{Code: "north", Name: "North"}
+	require.NoError(t, repos.Branches.Create(context.Background(), north))
+	northCtx := tenant.WithBranch(context.Background(), north.ID)
+
+	dune := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "1"}
+	require.NoError(t, repos.Books.Create(northCtx, dune))
+	require.Equal(t, north.ID, dune.BranchID)
+	require.NoError(t, repos.Books.Create(context.Background(), &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "1"}),
+		"an ISBN is unique per branch")
+
+	_, err := repos.Books.GetByID(tenant.WithBranch(context.Background(), model.DefaultBranchID), dune.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+
+	page, err := repos.Books.List(northCtx, model.PageRequest{Limit: 10}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+
+	require.ErrorIs(t, repos.Branches.Delete(context.Background(), north.ID), apperr.ErrConflict)
+}
+
+func TestMemoryBookings_CountTowardsAvailability(t *testing.T) {
+	repos := NewMemoryRepos(NewMemoryStore())
+	ctx := context.Background()
+	book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+	require.NoError(t, repos.Books.Create(ctx, book))
+	require.True(t, book.Available)
+
+	booking := &model.Booking{UserID: "u1", BookID: book.ID, Status: "ACTIVE"}
+	require.NoError(t, repos.Bookings.Create(ctx, booking))
+	got, err := repos.Books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.False(t, got.Available)
+
+	n, err := repos.Bookings.CountOutstandingForUpdate(ctx, "u1")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestMemoryBookings_OneActiveLoanPerUserAndBook(t *testing.T) {
+	repos := NewMemoryRepos(NewMemoryStore())
+	ctx := context.Background()
+	book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 3}
+	require.NoError(t, repos.Books.Create(ctx, book))
+
+	require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: "u1", BookID: book.ID, Status: "ACTIVE"}))
+	require.ErrorIs(t, repos.Bookings.Create(ctx, &model.Booking{UserID: "u1", BookID: book.ID, Status: "ACTIVE"}), apperr.ErrConflict)
+	require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: "u2", BookID: book.ID, Status: "ACTIVE"}))
+
+	offer := &model.Booking{UserID: "u1", BookID: book.ID, Status: "OFFERED"}
+	require.NoError(t, repos.Bookings.Create(ctx, offer))
+	_, err := repos.Bookings.Update(ctx, offer.ID, model.UpdateBookingPatch{Status: model.Ptr("ACTIVE")})
+	require.ErrorIs(t, err, apperr.ErrConflict, "accepting an offer can't make a second ACTIVE loan")
+	got, err := repos.Bookings.GetByID(ctx, offer.ID)
+	require.NoError(t, err)
+	require.Equal(t, "OFFERED", got.Status)
+}
+
+func TestMemoryBooks_MergeRefusesDoubleLoans(t *testing.T) {
+	repos := NewMemoryRepos(NewMemoryStore())
+	ctx := context.Background()
+	ada := &model.User{Username: "ada", Email: "ada@example.com", Role: "user"}
+	require.NoError(t, repos.Users.Create(ctx, ada))
+	dune := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "1"}
+	dup := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "2"}
+	require.NoError(t, repos.Books.Create(ctx, dune))
+	require.NoError(t, repos.Books.Create(ctx, dup))
+	for _, b := range []*model.Book{dune, dup} {
+		require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: ada.ID, BookID: b.ID, Status: "ACTIVE"}))
+	}
+
+	_, err := repos.Books.Merge(ctx, dup.ID, dune.ID)
+	require.ErrorIs(t, err, apperr.ErrConflict)
+	require.ErrorContains(t, err, "ada")
+	_, err = repos.Books.GetByID(ctx, dup.ID)
+	require.NoError(t, err, "the duplicate is left alone")
+}
+
+func TestMemoryBooks_PopularCountsRecentBorrows(t *testing.T) {
+	repos := NewMemoryRepos(NewMemoryStore())
+	ctx := context.Background()
+	dune := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "1", TotalCopies: 5}
+	emma := &model.Book{Title: "Emma", Author: "Jane Austen", ISBN: "2", TotalCopies: 5}
+	require.NoError(t, repos.Books.Create(ctx, dune))
+	require.NoError(t, repos.Books.Create(ctx, emma))
+	require.NoError(t, repos.Books.Create(ctx, &model.Book{Title: "Ulysses", Author: "James Joyce", ISBN: "3"}))
+
+	now := time.Now().UTC()
+	for _, b := range []struct {
+		book *model.Book
+		at   time.Time
+	}{{dune, now}, {dune, now}, {emma, now}, {emma, now.AddDate(0, -2, 0)}, {emma, now.AddDate(0, -2, 0)}} {
+		require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: "u1", BookID: b.book.ID, BorrowedAt: b.at, Status: "RETURNED"}))
+	}
+
+	popular, err := repos.Books.Popular(ctx, now.AddDate(0, -1, 0), 10)
+	require.NoError(t, err)
+	require.Len(t, popular, 2, "books not borrowed in the window are left out")
+	require.Equal(t, dune.ID, popular[0].ID)
+	require.Equal(t, 2, popular[0].BorrowCount)
+	require.Equal(t, 1, popular[1].BorrowCount)
+
+	newest, err := repos.Books.Newest(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, newest, 2)
+	require.Equal(t, "Ulysses", newest[0].Title)
+}
+
+func TestMemoryAudit_RecordsClientIP(t *testing.T) {
+	s := NewMemoryStore()
+	audit := NewMemoryAuditRepo(s)
+
+	ctx := clientip.With(context.Background(), "198.51.100.1")
+	require.NoError(t, audit.Record(ctx, &model.AuditEntry{Action: model.AuditReviewRemoved, TargetType: "review", TargetID: "r1"}))
+	require.NoError(t, audit.Record(context.Background(), &model.AuditEntry{Action: model.AuditMaintenanceSet, TargetType: "maintenance", TargetID: "global"}))
+
+	require.Equal(t, "198.51.100.1", s.data.audit[0].ClientIP)
+	require.Empty(t, s.data.audit[1].ClientIP, "background changes have no client")
+}
+
+func TestMemoryAudit_RecordsImpersonator(t *testing.T) {
+	s := NewMemoryStore()
+	audit := NewMemoryAuditRepo(s)
+
+	ctx := impersonator.With(context.Background(), "admin-1")
+	require.NoError(t, audit.Record(ctx, &model.AuditEntry{ActorID: "user-1", Action: model.AuditReviewRemoved, TargetType: "review", TargetID: "r1"}))
+
+	require.Equal(t, "user-1", s.data.audit[0].ActorID)
+	require.Equal(t, "admin-1", s.data.audit[0].ImpersonatorID)
+}
@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// memOutboxEvent is an event with its delivery state, kept in the order
+// the events were added.
+type memOutboxEvent struct {
+	event       model.Event
+	lastError   string
+	deliveredAt *time.Time
+}
+
+type memOutboxRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryOutboxRepo(s *MemoryStore) OutboxRepo {
+	return &memOutboxRepo{s: s}
+}
+
+func (r *memOutboxRepo) Add(ctx context.Context, e *model.Event) error {
+	defer r.s.lock(ctx)()
+	e.ID = uuid.New().String()
+	e.OccurredAt = time.Now().UTC()
+	e.Attempts = 0
+	if e.Data == nil {
+		e.Data = []byte("{}")
+	}
+	if e.RequestID == "" {
+		e.RequestID = requestid.From(ctx)
+	}
+	r.s.data.outbox = append(r.s.data.outbox, memOutboxEvent{event: *e})
+	return nil
+}
+
+func (r *memOutboxRepo) Pending(ctx context.Context, limit int) ([]model.Event, error) {
+	defer r.s.lock(ctx)()
+	events := []model.Event{}
+	for _, o := range r.s.data.outbox {
+		if len(events) == limit {
+			break
+		}
+		if o.deliveredAt == nil {
+			events = append(events, o.event)
+		}
+	}
+	return events, nil
+}
+
+func (r *memOutboxRepo) MarkDelivered(ctx context.Context, id string) error {
+	r.update(ctx, id, func(o *memOutboxEvent) {
+		now := time.Now().UTC()
+		o.deliveredAt = &now
+		o.lastError = ""
+	})
+	return nil
+}
+
+func (r *memOutboxRepo) MarkFailed(ctx context.Context, id, lastErr string) error {
+	r.update(ctx, id, func(o *memOutboxEvent) {
+		o.event.Attempts++
+		o.lastError = lastErr
+	})
+	return nil
+}
+
+func (r *memOutboxRepo) update(ctx context.Context, id string, fn func(o *memOutboxEvent)) {
+	defer r.s.lock(ctx)()
+	if i := slices.IndexFunc(r.s.data.outbox, func(o memOutboxEvent) bool { return o.event.ID == id }); i >= 0 {
+		fn(&r.s.data.outbox[i])
+	}
+}
+
+func (r *memOutboxRepo) PurgeDelivered(ctx context.Context, cutoff time.Time) (int, error) {
+	defer r.s.lock(ctx)()
+	before := len(r.s.data.outbox)
+	r.s.data.outbox = slices.DeleteFunc(r.s.data.outbox, func(o memOutboxEvent) bool {
+		return o.deliveredAt != nil && o.deliveredAt.Before(cutoff)
+	})
+	return before - len(r.s.data.outbox), nil
+}
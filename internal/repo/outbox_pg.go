@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// OutboxRepo stores domain events until they are published.
+type OutboxRepo interface {
+	// Add stores an undelivered event. It joins the surrounding
+	// transaction, so the event is only published if the change it
+	// describes commits.
+	Add(ctx context.Context, e *model.Event) error
+	// Pending returns up to limit undelivered events, oldest first. Within
+	// a transaction they stay locked until it ends, and concurrent callers
+	// skip them.
+	Pending(ctx context.Context, limit int) ([]model.Event, error)
+	MarkDelivered(ctx context.Context, id string) error
+	// MarkFailed counts a failed delivery, recording its error.
+	MarkFailed(ctx context.Context, id, lastErr string) error
+	// PurgeDelivered deletes events delivered before cutoff and returns how
+	// many there were.
+	PurgeDelivered(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type pgOutboxRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepo(db *pgxpool.Pool) OutboxRepo {
+	return &pgOutboxRepo{db: db}
+}
+
+func (r *pgOutboxRepo) Add(ctx context.Context, e *model.Event) error {
+	if e.Data == nil {
+		e.Data = []byte("{}")
+	}
+	if e.RequestID == "" {
+		e.RequestID = requestid.From(ctx)
+	}
+	return conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO outbox (type, subject, data, request_id) VALUES ($1, $2, $3, $4)
+		RETURNING id, occurred_at`,
+		e.Type, e.Subject, e.Data, e.RequestID,
+	).Scan(&e.ID, &e.OccurredAt)
+}
+
+func (r *pgOutboxRepo) Pending(ctx context.Context, limit int) ([]model.Event, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT id, type, subject, data, occurred_at, request_id, attempts FROM outbox
+		WHERE delivered_at IS NULL
+		ORDER BY seq LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Event, error) {
+		var e model.Event
+		err := row.Scan(&e.ID, &e.Type, &e.Subject, &e.Data, &e.OccurredAt, &e.RequestID, &e.Attempts)
+		return e, err
+	})
+}
+
+func (r *pgOutboxRepo) MarkDelivered(ctx context.Context, id string) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE outbox SET delivered_at = NOW(), last_error = '' WHERE id = $1`, id)
+	return err
+}
+
+func (r *pgOutboxRepo) MarkFailed(ctx context.Context, id, lastErr string) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, lastErr)
+	return err
+}
+
+func (r *pgOutboxRepo) PurgeDelivered(ctx context.Context, cutoff time.Time) (int, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM outbox WHERE delivered_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
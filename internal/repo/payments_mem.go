@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memPaymentRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryPaymentRepo(s *MemoryStore) PaymentRepo {
+	return &memPaymentRepo{s: s}
+}
+
+func (r *memPaymentRepo) Create(ctx context.Context, p *model.Payment) error {
+	defer r.s.lock(ctx)()
+	for _, other := range r.s.data.payments {
+		if other.Provider == p.Provider && other.ProviderRef == p.ProviderRef {
+			return apperr.Conflict("payment already recorded")
+		}
+	}
+	p.ID = uuid.New().String()
+	p.Status = model.PaymentPending
+	p.CreatedAt = time.Now().UTC()
+	p.UpdatedAt = p.CreatedAt
+	r.s.data.payments[p.ID] = *p
+	return nil
+}
+
+func (r *memPaymentRepo) GetByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error) {
+	defer r.s.lock(ctx)()
+	for _, p := range r.s.data.payments {
+		if p.Provider == provider && p.ProviderRef == ref {
+			return &p, nil
+		}
+	}
+	return nil, apperr.NotFound("payment not found")
+}
+
+func (r *memPaymentRepo) SetStatus(ctx context.Context, id, status string) error {
+	defer r.s.lock(ctx)()
+	p, ok := r.s.data.payments[id]
+	if !ok {
+		return apperr.NotFound("payment not found")
+	}
+	p.Status = status
+	p.UpdatedAt = time.Now().UTC()
+	r.s.data.payments[id] = p
+	return nil
+}
+
+func (r *memPaymentRepo) CreateReceipt(ctx context.Context, rc *model.Receipt) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.receipts[rc.FineID]; ok {
+		return apperr.Conflict("this fine has a receipt already")
+	}
+	rc.ID = uuid.New().String()
+	r.s.data.receipts[rc.FineID] = *rc
+	return nil
+}
+
+func (r *memPaymentRepo) GetReceiptByFine(ctx context.Context, fineID string) (*model.Receipt, error) {
+	defer r.s.lock(ctx)()
+	rc, ok := r.s.data.receipts[fineID]
+	if !ok {
+		return nil, apperr.NotFound("receipt not found")
+	}
+	return &rc, nil
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// PaymentRepo stores payments of fines and the receipts of those paid.
+type PaymentRepo interface {
+	Create(ctx context.Context, p *model.Payment) error
+	// GetByProviderRef returns the payment the provider knows as ref.
+	GetByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error)
+	SetStatus(ctx context.Context, id, status string) error
+	// CreateReceipt returns a Conflict error if the fine has a receipt
+	// already.
+	CreateReceipt(ctx context.Context, rc *model.Receipt) error
+	GetReceiptByFine(ctx context.Context, fineID string) (*model.Receipt, error)
+}
+
+type pgPaymentRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPaymentRepo(db *pgxpool.Pool) PaymentRepo {
+	return &pgPaymentRepo{db: db}
+}
+
+func (r *pgPaymentRepo) Create(ctx context.Context, p *model.Payment) error {
+	p.Status = model.PaymentPending
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO payments (fine_id, user_id, provider, provider_ref, amount_cents, currency)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`,
+		p.FineID, p.UserID, p.Provider, p.ProviderRef, p.AmountCents, p.Currency,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("payment already recorded")
+	}
+	return err
+}
+
+func (r *pgPaymentRepo) GetByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error) {
+	var p model.Payment
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT id, fine_id, user_id, provider, provider_ref, amount_cents, currency, status, created_at, updated_at
+		FROM payments WHERE provider = $1 AND provider_ref = $2`, provider, ref,
+	).Scan(&p.ID, &p.FineID, &p.UserID, &p.Provider, &p.ProviderRef, &p.AmountCents, &p.Currency, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("payment not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *pgPaymentRepo) SetStatus(ctx context.Context, id, status string) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE payments SET status = $2, updated_at = NOW() WHERE id = $1`, id, status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("payment not found")
+	}
+	return nil
+}
+
+func (r *pgPaymentRepo) CreateReceipt(ctx context.Context, rc *model.Receipt) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO receipts (fine_id, payment_id, user_id, amount_cents, currency, paid_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		rc.FineID, rc.PaymentID, rc.UserID, rc.AmountCents, rc.Currency, rc.PaidAt,
+	).Scan(&rc.ID)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("this fine has a receipt already")
+	}
+	return err
+}
+
+func (r *pgPaymentRepo) GetReceiptByFine(ctx context.Context, fineID string) (*model.Receipt, error) {
+	var rc model.Receipt
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT id, fine_id, payment_id, user_id, amount_cents, currency, paid_at FROM receipts WHERE fine_id = $1`, fineID,
+	).Scan(&rc.ID, &rc.FineID, &rc.PaymentID, &rc.UserID, &rc.AmountCents, &rc.Currency, &rc.PaidAt)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("receipt not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
@@ -0,0 +1,1168 @@
+//go:build integration
+
+package repo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// These tests run the Postgres repositories against a real database in a
+// container. Run them with
+//
+//	go test -tags integration ./internal/repo/
+//
+// They are skipped when no Docker daemon is reachable.
+
+var (
+	pgOnce      sync.Once
+	pgPool      *pgxpool.Pool
+	pgContainer *postgres.PostgresContainer
+	pgErr       error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if pgPool != nil {
+		pgPool.Close()
+	}
+	if pgContainer != nil {
+		_ = testcontainers.TerminateContainer(pgContainer)
+	}
+	os.Exit(code)
+}
+
+// testDB returns a pool on a migrated database with every table emptied
+// except branches, which keeps only the main branch.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	pgOnce.Do(func() { pgPool, pgErr = startPostgres(context.Background()) })
+	require.NoError(t, pgErr)
+
+	_, err := pgPool.Exec(context.Background(), `
+		TRUNCATE books, users, bookings, categories, login_attempts, loan_policies, sessions, user_identities, api_keys, reviews, reservations, closures, jobs, outbox, scheduled_runs,
+			audit_log, token_revocations, maintenance, fine_policy, email_changes, user_invitations, invite_codes, announcements,
+			reading_lists, reading_list_books CASCADE;
+		DELETE FROM branches WHERE id <> '`+model.DefaultBranchID+`'`)
+	require.NoError(t, err)
+	return pgPool
+}
+
+func startPostgres(ctx context.Context) (*pgxpool.Pool, error) {
+	var err error
+	pgContainer, err = postgres.Run(ctx, "postgres:15-alpine",
+		postgres.WithDatabase("digicert"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return pool, migrate(ctx, pool)
+}
+
+// migrate applies the up migrations in order, as the migrate container
+// does in docker-compose.
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	files, err := filepath.Glob(filepath.Join("..", "migrate", "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		sql, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			return errors.Join(errors.New(filepath.Base(f)), err)
+		}
+	}
+	return nil
+}
+
+func createBook(t *testing.T, books BookRepo, ctx context.Context, isbn string) *model.Book {
+	t.Helper()
+	b := &model.Book{Title: "Book " + isbn, Author: "Author", ISBN: isbn, PublishedYear: 2000, TotalCopies: 1}
+	require.NoError(t, books.Create(ctx, b))
+	return b
+}
+
+func createUser(t *testing.T, users UserRepo, ctx context.Context, name string) *model.User {
+	t.Helper()
+	u := &model.User{Username: name, Email: name + "@example.com", Password: "hash", Role: model.RoleUser}
+	require.NoError(t, users.Create(ctx, u))
+	return u
+}
+
+func bookPatch(b *model.Book, version int) model.UpdateBookPatch {
+	return model.UpdateBookPatch{
+		Title:         &b.Title,
+		Author:        &b.Author,
+		PublishedYear: &b.PublishedYear,
+		ISBN:          &b.ISBN,
+		Version:       &version,
+	}
+}
+
+func TestPgBookRepo_UpdateOptimisticLocking(t *testing.T) {
+	books := NewBookRepo(testDB(t), nil)
+	ctx := context.Background()
+	b := createBook(t, books, ctx, "1")
+	require.Equal(t, 1, b.Version)
+
+	patch := bookPatch(b, 1)
+	patch.Title = model.Ptr("Renamed")
+	got, err := books.Update(ctx, b.ID, patch)
+	require.NoError(t, err)
+	require.Equal(t, "Renamed", got.Title)
+	require.Equal(t, 2, got.Version)
+	require.Equal(t, 1, got.TotalCopies, "nil total_copies keeps the stored value")
+
+	_, err = books.Update(ctx, b.ID, bookPatch(b, 1))
+	require.ErrorIs(t, err, apperr.ErrPreconditionFailed)
+
+	unchecked := bookPatch(b, 0)
+	unchecked.Version = nil
+	got, err = books.Update(ctx, b.ID, unchecked)
+	require.NoError(t, err, "without a version the update applies to the stored one")
+	require.Equal(t, 3, got.Version)
+
+	_, err = books.Update(ctx, "00000000-0000-0000-0000-00000000ffff", bookPatch(b, 3))
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = books.Update(cancelled, b.ID, bookPatch(b, 3))
+	require.Error(t, err, "a failed write is reported, not a panic")
+}
+
+func TestPgBookRepo_ConcurrentUpdatesOneWins(t *testing.T) {
+	books := NewBookRepo(testDB(t), nil)
+	ctx := context.Background()
+	b := createBook(t, books, ctx, "1")
+
+	const writers = 5
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := books.Update(ctx, b.ID, bookPatch(b, b.Version))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, apperr.ErrPreconditionFailed)
+	}
+	require.Equal(t, 1, succeeded)
+
+	got, err := books.GetByID(ctx, b.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Version)
+}
+
+func TestPgBookRepo_ISBNUniquePerBranch(t *testing.T) {
+	db := testDB(t)
+	books, branches := NewBookRepo(db, nil), NewBranchRepo(db)
+	ctx := context.Background()
+	createBook(t, books, ctx, "1")
+	other := createBook(t, books, ctx, "2")
+
+	err := books.Create(ctx, &model.Book{Title: "Dup", Author: "A", ISBN: "1", TotalCopies: 1})
+	var dupErr *DuplicateISBNError
+	require.ErrorAs(t, err, &dupErr)
+	require.Equal(t, "1", dupErr.ISBN)
+	require.ErrorIs(t, err, apperr.ErrConflict)
+	require.NoError(t, books.Create(ctx, &model.Book{Title: "No ISBN", Author: "A", TotalCopies: 1}))
+	require.NoError(t, books.Create(ctx, &model.Book{Title: "No ISBN either", Author: "A", TotalCopies: 1}))
+
+	patch := bookPatch(other, other.Version)
+	patch.ISBN = model.Ptr("1")
+	_, err = books.Update(ctx, other.ID, patch)
+	require.ErrorIs(t, err, apperr.ErrConflict)
+
+	north := &model.Branch{Code: "north", Name: "North"}
+	require.NoError(t, branches.Create(ctx, north))
+	northCtx := tenant.WithBranch(ctx, north.ID)
+	createBook(t, books, northCtx, "1")
+
+	_, err = books.GetByID(tenant.WithBranch(ctx, model.DefaultBranchID), other.ID)
+	require.NoError(t, err)
+	_, err = books.GetByID(northCtx, other.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestPgBookRepo_Merge(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings, reviews, categories := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil), NewReviewRepo(db, nil), NewCategoryRepo(db, nil)
+	ctx := context.Background()
+	target, dup := createBook(t, books, ctx, "1"), createBook(t, books, ctx, "2")
+	alice, bob := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "bob")
+	cat := &model.Category{Name: "Fiction"}
+	require.NoError(t, categories.Create(ctx, cat))
+	patch := bookPatch(dup, dup.Version)
+	patch.CategoryIDs = []string{cat.ID}
+	_, err := books.Update(ctx, dup.ID, patch)
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	loan := &model.Booking{UserID: alice.ID, BookID: dup.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}
+	require.NoError(t, bookings.Create(ctx, loan))
+	require.NoError(t, reviews.Create(ctx, &model.Review{BookID: target.ID, UserID: alice.ID, Rating: 5}))
+	require.NoError(t, reviews.Create(ctx, &model.Review{BookID: dup.ID, UserID: alice.ID, Rating: 1}))
+	require.NoError(t, reviews.Create(ctx, &model.Review{BookID: dup.ID, UserID: bob.ID, Rating: 3}))
+
+	merged, err := books.Merge(ctx, dup.ID, target.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, merged.TotalCopies)
+	require.Equal(t, 1, merged.CopiesAvailable)
+	require.Equal(t, target.Version+1, merged.Version)
+	require.Equal(t, 2, merged.ReviewCount)
+	require.Len(t, merged.Categories, 1)
+
+	moved, err := bookings.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Equal(t, target.ID, moved.BookID)
+	_, err = books.GetByID(ctx, dup.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+	page, err := books.List(ctx, model.PageRequest{Limit: 10}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	var mergedInto string
+	require.NoError(t, db.QueryRow(ctx, `SELECT merged_into FROM books WHERE id = $1 AND deleted_at IS NOT NULL`, dup.ID).Scan(&mergedInto))
+	require.Equal(t, target.ID, mergedInto)
+
+	// The soft-deleted duplicate no longer holds its ISBN.
+	createBook(t, books, ctx, "2")
+
+	_, err = books.Restore(ctx, dup.ID)
+	require.ErrorIs(t, err, apperr.ErrConflict, "merged books stay merged")
+}
+
+func TestPgBookRepo_MergeRefusesDoubleLoans(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	target, dup := createBook(t, books, ctx, "1"), createBook(t, books, ctx, "2")
+	alice := createUser(t, users, ctx, "alice")
+
+	now := time.Now().UTC()
+	for _, b := range []*model.Book{target, dup} {
+		require.NoError(t, bookings.Create(ctx, &model.Booking{UserID: alice.ID, BookID: b.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}))
+	}
+
+	_, err := books.Merge(ctx, dup.ID, target.ID)
+	require.ErrorIs(t, err, apperr.ErrConflict)
+	require.ErrorContains(t, err, "alice")
+	_, err = books.GetByID(ctx, dup.ID)
+	require.NoError(t, err, "the duplicate is left alone")
+}
+
+func TestPgBookRepo_DeleteAndRestore(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings, reviews, audit := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil), NewReviewRepo(db, nil), NewAuditRepo(db)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice := createUser(t, users, ctx, "alice")
+
+	now := time.Now().UTC()
+	loan := &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}
+	require.NoError(t, bookings.Create(ctx, loan))
+	require.ErrorIs(t, books.Delete(ctx, book.ID), apperr.ErrConflict, "copies are on loan")
+	_, err := bookings.Update(ctx, loan.ID, model.UpdateBookingPatch{Status: model.Ptr("RETURNED"), ReturnedAt: &now})
+	require.NoError(t, err)
+	require.NoError(t, reviews.Create(ctx, &model.Review{BookID: book.ID, UserID: alice.ID, Rating: 4}))
+
+	require.NoError(t, books.Delete(ctx, book.ID))
+	require.ErrorIs(t, books.Delete(ctx, book.ID), apperr.ErrNotFound)
+	_, err = books.GetByID(ctx, book.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+	_, err = bookings.GetByID(ctx, loan.ID)
+	require.NoError(t, err, "loan history is kept")
+
+	restored, err := books.Restore(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, book.Version+2, restored.Version)
+	require.Equal(t, 1, restored.ReviewCount)
+	_, err = books.Restore(ctx, book.ID)
+	require.ErrorIs(t, err, apperr.ErrConflict, "not deleted")
+
+	// Another book takes the ISBN while this one is deleted.
+	require.NoError(t, books.Delete(ctx, book.ID))
+	createBook(t, books, ctx, "1")
+	_, err = books.Restore(ctx, book.ID)
+	var dupErr *DuplicateISBNError
+	require.ErrorAs(t, err, &dupErr)
+	require.Equal(t, "1", dupErr.ISBN)
+
+	require.NoError(t, audit.Record(ctx, &model.AuditEntry{ActorID: alice.ID, Action: model.AuditBookDeleted, TargetType: "book", TargetID: book.ID,
+		Details: map[string]interface{}{"version": 3}}))
+	entries, err := audit.ListByTarget(ctx, "book", book.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, alice.ID, entries[0].ActorID)
+	require.Equal(t, float64(3), entries[0].Details["version"])
+}
+
+func TestPgBookRepo_CursorPagination(t *testing.T) {
+	books := NewBookRepo(testDB(t), nil)
+	ctx := context.Background()
+	for _, isbn := range []string{"1", "2", "3"} {
+		createBook(t, books, ctx, isbn)
+	}
+
+	first, err := books.List(ctx, model.PageRequest{Limit: 2}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Len(t, first.Items, 2)
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := books.List(ctx, model.PageRequest{Limit: 2, Cursor: first.NextCursor}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	require.Empty(t, second.NextCursor)
+}
+
+func TestPgBookRepo_StreamCrossesBatchesAndResumes(t *testing.T) {
+	books := NewBookRepo(testDB(t), nil)
+	ctx := context.Background()
+	for i := 0; i <= streamBatch; i++ {
+		createBook(t, books, ctx, strconv.Itoa(i))
+	}
+
+	ids := []string{}
+	require.NoError(t, books.Stream(ctx, "", func(b *model.Book) error {
+		ids = append(ids, b.ID)
+		return nil
+	}))
+	require.Len(t, ids, streamBatch+1)
+	require.True(t, sort.StringsAreSorted(ids))
+
+	resumed := []string{}
+	require.NoError(t, books.Stream(ctx, ids[streamBatch-1], func(b *model.Book) error {
+		resumed = append(resumed, b.ID)
+		return nil
+	}))
+	require.Equal(t, ids[streamBatch:], resumed)
+}
+
+func TestPgBookRepo_PopularAndNewest(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	first, second := createBook(t, books, ctx, "1"), createBook(t, books, ctx, "2")
+	latest := createBook(t, books, ctx, "3")
+	user := createUser(t, users, ctx, "alice")
+
+	now := time.Now().UTC()
+	for _, b := range []struct {
+		book *model.Book
+		at   time.Time
+	}{{second, now}, {second, now}, {first, now}, {first, now.AddDate(0, -2, 0)}} {
+		require.NoError(t, bookings.Create(ctx, &model.Booking{UserID: user.ID, BookID: b.book.ID, BorrowedAt: b.at, DueDate: b.at, Status: "RETURNED"}))
+	}
+
+	popular, err := books.Popular(ctx, now.AddDate(0, -1, 0), 10)
+	require.NoError(t, err)
+	require.Len(t, popular, 2)
+	require.Equal(t, second.ID, popular[0].ID)
+	require.Equal(t, 2, popular[0].BorrowCount)
+	require.True(t, popular[0].Available)
+	require.Equal(t, 1, popular[1].BorrowCount)
+
+	newest, err := books.Newest(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, newest, 2)
+	require.Equal(t, latest.ID, newest[0].ID)
+}
+
+func TestPgBookingRepo_ListCountsAndExpandsInOneQuery(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice, bob := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "bob")
+
+	now := time.Now().UTC()
+	for i, u := range []*model.User{alice, alice, alice, bob} {
+		at := now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, bookings.Create(ctx, &model.Booking{UserID: u.ID, BookID: book.ID, BorrowedAt: at, DueDate: at, Status: "RETURNED"}))
+	}
+
+	expand := model.BookingExpand{Book: true, User: true}
+	first, err := bookings.List(ctx, model.PageRequest{Limit: 2}, model.BookingFilter{UserID: alice.ID}, expand)
+	require.NoError(t, err)
+	require.Equal(t, 3, first.Total)
+	require.Len(t, first.Items, 2)
+	require.Equal(t, "alice", first.Items[0].User.Username)
+	require.Equal(t, book.ID, first.Items[0].Book.ID)
+
+	// The total counts every match, not just those after the cursor.
+	second, err := bookings.List(ctx, model.PageRequest{Limit: 2, Cursor: first.NextCursor}, model.BookingFilter{UserID: alice.ID}, model.BookingExpand{})
+	require.NoError(t, err)
+	require.Equal(t, 3, second.Total)
+	require.Len(t, second.Items, 1)
+	require.Empty(t, second.NextCursor)
+
+	past, err := bookings.List(ctx, model.PageRequest{Limit: 2, Offset: 10}, model.BookingFilter{}, expand)
+	require.NoError(t, err)
+	require.Empty(t, past.Items)
+	require.Equal(t, 4, past.Total)
+}
+
+func TestPgUserRepo_Update(t *testing.T) {
+	users := NewUserRepo(testDB(t), nil)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+	createUser(t, users, ctx, "bob")
+
+	got, err := users.Update(ctx, alice.ID, model.UpdateUserPatch{Email: model.Ptr("alice@example.org")})
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.org", got.Email)
+	require.Equal(t, "alice", got.Username)
+	require.Equal(t, model.UserStatusActive, got.Status)
+
+	_, err = users.Update(ctx, alice.ID, model.UpdateUserPatch{Username: model.Ptr("bob")})
+	require.ErrorIs(t, err, apperr.ErrConflict)
+
+	_, err = users.Update(ctx, "00000000-0000-0000-0000-00000000ffff", model.UpdateUserPatch{Email: model.Ptr("x@example.com")})
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestPgUserRepo_UpdateChecksVersion(t *testing.T) {
+	users := NewUserRepo(testDB(t), nil)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+	require.Equal(t, 1, alice.Version)
+
+	got, err := users.Update(ctx, alice.ID, model.UpdateUserPatch{Email: model.Ptr("alice@example.org"), Version: model.Ptr(1)})
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Version)
+
+	_, err = users.Update(ctx, alice.ID, model.UpdateUserPatch{Email: model.Ptr("alice@example.net"), Version: model.Ptr(1)})
+	require.ErrorIs(t, err, apperr.ErrPreconditionFailed)
+
+	got, err = users.Update(ctx, alice.ID, model.UpdateUserPatch{Status: model.Ptr(model.UserStatusSuspended)})
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Version, "unconditional updates bump the version too")
+}
+
+func TestPgUserRepo_ReplacePasswordHash(t *testing.T) {
+	users := NewUserRepo(testDB(t), nil)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+
+	require.NoError(t, users.ReplacePasswordHash(ctx, alice.ID, "hash", "rehashed"))
+	got, err := users.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "rehashed", got.Password)
+	require.Equal(t, 1, got.Version, "a rehash isn't a profile change")
+
+	// A hash changed since it was read is left alone.
+	require.NoError(t, users.ReplacePasswordHash(ctx, alice.ID, "hash", "stale"))
+	got, err = users.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "rehashed", got.Password)
+}
+
+func TestPgUserRepo_NormalizesRoles(t *testing.T) {
+	users := NewUserRepo(testDB(t), nil)
+	ctx := context.Background()
+
+	u := &model.User{Username: "alice", Email: "alice@example.com", Password: "hash", Role: "ADMIN"}
+	require.NoError(t, users.Create(ctx, u))
+	require.Equal(t, model.RoleAdmin, u.Role)
+
+	got, err := users.Update(ctx, u.ID, model.UpdateUserPatch{Role: model.Ptr(model.Role(" User "))})
+	require.NoError(t, err)
+	require.Equal(t, model.RoleUser, got.Role)
+
+	_, err = users.Update(ctx, u.ID, model.UpdateUserPatch{Role: model.Ptr(model.Role("librarian"))})
+	require.Error(t, err, "the database refuses unknown roles")
+}
+
+func TestPgBookingRepo_LifecycleAndAvailability(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	user := createUser(t, users, ctx, "alice")
+
+	now := time.Now().UTC()
+	booking := &model.Booking{UserID: user.ID, BookID: book.ID, BorrowedAt: now.Add(-time.Hour), DueDate: now.Add(-time.Minute), Status: "ACTIVE"}
+	require.NoError(t, bookings.Create(ctx, booking))
+	require.Equal(t, model.DefaultBranchID, booking.BranchID)
+
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.False(t, got.Available)
+
+	marked, err := bookings.MarkOverdue(ctx)
+	require.NoError(t, err)
+	require.Len(t, marked, 1)
+	require.Equal(t, booking.ID, marked[0].ID)
+	overdue, err := bookings.GetByID(ctx, booking.ID)
+	require.NoError(t, err)
+	require.Equal(t, "OVERDUE", overdue.Status)
+
+	n, err := bookings.CountOutstandingForUpdate(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	returned, err := bookings.Update(ctx, booking.ID, model.UpdateBookingPatch{Status: model.Ptr("RETURNED"), ReturnedAt: model.Ptr(time.Now().UTC())})
+	require.NoError(t, err)
+	require.Equal(t, "RETURNED", returned.Status)
+	require.NotNil(t, returned.ReturnedAt)
+
+	got, err = books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.True(t, got.Available)
+}
+
+func TestPgBookingRepo_ClaimDueReminders(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	user := createUser(t, users, ctx, "alice")
+	now := time.Now().UTC()
+	loan := func(isbn string, due time.Time) *model.Booking {
+		b := &model.Booking{UserID: user.ID, BookID: createBook(t, books, ctx, isbn).ID, BorrowedAt: now, DueDate: due, Status: "ACTIVE"}
+		require.NoError(t, bookings.Create(ctx, b))
+		return b
+	}
+	first := loan("1", now.Add(2*time.Hour))
+	second := loan("2", now.Add(3*time.Hour))
+	loan("3", now.Add(48*time.Hour))
+
+	claimed, err := bookings.ClaimDueReminders(ctx, now, 24*time.Hour, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, first.ID, claimed[0].ID)
+	claimed, err = bookings.ClaimDueReminders(ctx, now, 24*time.Hour, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, second.ID, claimed[0].ID)
+
+	require.NoError(t, bookings.ReleaseReminder(ctx, first.ID))
+	_, err = bookings.Update(ctx, second.ID, model.UpdateBookingPatch{DueDate: model.Ptr(now.Add(4 * time.Hour))})
+	require.NoError(t, err)
+	claimed, err = bookings.ClaimDueReminders(ctx, now, 24*time.Hour, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "released and rescheduled loans are claimed again")
+}
+
+func TestPgBookingRepo_ClaimDueReminders_Preferences(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	loan := func(user *model.User, isbn string, due time.Time) *model.Booking {
+		b := &model.Booking{UserID: user.ID, BookID: createBook(t, books, ctx, isbn).ID, BorrowedAt: now, DueDate: due, Status: "ACTIVE"}
+		require.NoError(t, bookings.Create(ctx, b))
+		return b
+	}
+	early, quiet, late := createUser(t, users, ctx, "early"), createUser(t, users, ctx, "quiet"), createUser(t, users, ctx, "late")
+	require.NoError(t, users.SaveNotificationPreferences(ctx, &model.NotificationPreferences{UserID: early.ID, Channel: model.NotifyWebhook, WebhookURL: "https://hooks.example.com/x", ReminderLeadHours: 72}))
+	require.NoError(t, users.SaveNotificationPreferences(ctx, &model.NotificationPreferences{UserID: quiet.ID, Channel: model.NotifyNone}))
+	require.NoError(t, users.SaveNotificationPreferences(ctx, &model.NotificationPreferences{UserID: late.ID, Channel: model.NotifyEmail, ReminderLeadHours: 1}))
+	got, err := users.GetNotificationPreferences(ctx, early.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.NotifyWebhook, got.Channel)
+	require.Equal(t, 72, got.ReminderLeadHours)
+
+	want := loan(early, "1", now.Add(48*time.Hour))
+	loan(quiet, "2", now.Add(2*time.Hour))
+	loan(late, "3", now.Add(2*time.Hour))
+
+	claimed, err := bookings.ClaimDueReminders(ctx, now, 24*time.Hour, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, want.ID, claimed[0].ID)
+
+	require.NoError(t, users.Anonymize(ctx, early.ID))
+	got, err = users.GetNotificationPreferences(ctx, early.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DefaultNotificationPreferences(early.ID), got, "anonymizing drops the preferences")
+}
+
+func TestPgBookingRepo_Overdue(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	user := createUser(t, users, ctx, "alice")
+	now := time.Now().UTC()
+	loan := func(isbn string, due time.Time, status string) *model.Booking {
+		b := &model.Booking{UserID: user.ID, BookID: createBook(t, books, ctx, isbn).ID, BorrowedAt: due.AddDate(0, 0, -14), DueDate: due, Status: status}
+		require.NoError(t, bookings.Create(ctx, b))
+		return b
+	}
+	late := loan("1", now.Add(-time.Hour), "ACTIVE")
+	later := loan("2", now.AddDate(0, 0, -5), "OVERDUE")
+	loan("3", now.AddDate(0, 0, -5), "RETURNED")
+	loan("4", now.Add(time.Hour), "ACTIVE")
+
+	got, err := bookings.Overdue(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, got, 2, "returned and not yet due loans are left out")
+	require.ElementsMatch(t, []string{late.ID, later.ID}, []string{got[0].ID, got[1].ID})
+	require.Equal(t, "alice", got[0].User.Username)
+	require.NotEmpty(t, got[0].Book.Title)
+}
+
+func TestPgScheduledRunRepo_ClaimOncePerPeriod(t *testing.T) {
+	db := testDB(t)
+	runs, tx := NewScheduledRunRepo(db), NewTxManager(db)
+	ctx := context.Background()
+	week := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	// A rolled back claim leaves the period to be claimed again.
+	boom := errors.New("boom")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		claimed, err := runs.Claim(ctx, "report", week)
+		require.NoError(t, err)
+		require.True(t, claimed)
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	claimed, err := runs.Claim(ctx, "report", week)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = runs.Claim(ctx, "report", week)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	claimed, err = runs.Claim(ctx, "report", week.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.True(t, claimed)
+}
+
+func TestPgMaintenanceRepo_SaveReplaces(t *testing.T) {
+	db := testDB(t)
+	maintenance := NewMaintenanceRepo(db)
+	ctx := context.Background()
+	admin := createUser(t, NewUserRepo(db, nil), ctx, "admin")
+
+	m, err := maintenance.Get(ctx)
+	require.NoError(t, err)
+	require.False(t, m.Enabled)
+
+	require.NoError(t, maintenance.Save(ctx, &model.Maintenance{Enabled: true, Message: "Upgrading", RetryAfterSeconds: 60, UpdatedBy: admin.ID}))
+	require.NoError(t, maintenance.Save(ctx, &model.Maintenance{Enabled: true, Message: "Still upgrading"}))
+	m, err = maintenance.Get(ctx)
+	require.NoError(t, err)
+	require.True(t, m.Enabled)
+	require.Equal(t, "Still upgrading", m.Message)
+	require.Zero(t, m.RetryAfterSeconds)
+	require.Empty(t, m.UpdatedBy)
+}
+
+func TestPgSessionRepo_RevokeAndList(t *testing.T) {
+	db := testDB(t)
+	users, sessions := NewUserRepo(db, nil), NewSessionRepo(db)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+	bob := createUser(t, users, ctx, "bob")
+
+	now := time.Now().UTC()
+	newSession := func(userID string, expiresAt time.Time) *model.Session {
+		s := &model.Session{ID: uuid.New().String(), UserID: userID, UserAgent: "test", IP: "203.0.113.7",
+			CreatedAt: now, LastUsedAt: now, ExpiresAt: expiresAt}
+		require.NoError(t, sessions.Create(ctx, s))
+		return s
+	}
+	current := newSession(alice.ID, now.Add(time.Hour))
+	other := newSession(alice.ID, now.Add(time.Hour))
+	newSession(alice.ID, now.Add(-time.Minute))
+
+	active, err := sessions.ListActive(ctx, alice.ID, now)
+	require.NoError(t, err)
+	require.Len(t, active, 2, "expired sessions aren't listed")
+
+	require.ErrorIs(t, sessions.Revoke(ctx, bob.ID, other.ID, now), apperr.ErrNotFound)
+	require.NoError(t, sessions.Revoke(ctx, alice.ID, other.ID, now))
+	require.ErrorIs(t, sessions.Revoke(ctx, alice.ID, other.ID, now), apperr.ErrNotFound)
+
+	revoked, err := sessions.RevokedIDs(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, []string{other.ID}, revoked)
+
+	require.NoError(t, sessions.Extend(ctx, current.ID, now, now.Add(2*time.Hour)))
+	require.ErrorIs(t, sessions.Extend(ctx, other.ID, now, now.Add(2*time.Hour)), apperr.ErrNotFound)
+}
+
+func TestPgLoginHistoryRepo_NewDevices(t *testing.T) {
+	db := testDB(t)
+	users, logins := NewUserRepo(db, nil), NewLoginHistoryRepo(db)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+	bob := createUser(t, users, ctx, "bob")
+
+	record := func(userID, userAgent, ip string) *model.LoginRecord {
+		l := &model.LoginRecord{UserID: userID, UserAgent: userAgent, IP: ip}
+		require.NoError(t, logins.Record(ctx, l))
+		return l
+	}
+	require.False(t, record(alice.ID, "Firefox", "203.0.113.7").NewDevice, "the first login was new")
+	require.False(t, record(alice.ID, "Firefox", "203.0.113.7").NewDevice)
+	require.True(t, record(alice.ID, "Firefox", "198.51.100.2").NewDevice)
+	require.True(t, record(alice.ID, "curl/8.0", "203.0.113.7").NewDevice)
+	require.False(t, record(bob.ID, "curl/8.0", "198.51.100.2").NewDevice, "another user's logins counted")
+
+	require.ErrorIs(t, logins.Record(ctx, &model.LoginRecord{UserID: uuid.New().String()}), apperr.ErrNotFound)
+
+	page, err := logins.List(ctx, alice.ID, model.PageRequest{Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, 4, page.Total)
+	require.Len(t, page.Items, 3)
+	require.Equal(t, "curl/8.0", page.Items[0].UserAgent, "logins aren't newest first")
+	require.NotEmpty(t, page.NextCursor)
+}
+
+func TestPgCalendarFeedRepo_IssueRotateAndAnonymize(t *testing.T) {
+	db := testDB(t)
+	users, feeds := NewUserRepo(db, nil), NewCalendarFeedRepo(db)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+
+	_, err := feeds.Version(ctx, alice.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+	version, err := feeds.Issue(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+	version, err = feeds.Issue(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, version, "issuing again rotated the feed")
+
+	version, err = feeds.Rotate(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+	version, err = feeds.Version(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+
+	_, err = feeds.Issue(ctx, uuid.New().String())
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+
+	require.NoError(t, users.Anonymize(ctx, alice.ID))
+	_, err = feeds.Version(ctx, alice.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound, "an anonymized user kept their feed")
+}
+
+func TestPgIdentityRepo_Link(t *testing.T) {
+	db := testDB(t)
+	users, identities := NewUserRepo(db, nil), NewIdentityRepo(db)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+
+	_, err := identities.UserID(ctx, "github", "42")
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+
+	require.NoError(t, identities.Link(ctx, &model.UserIdentity{Provider: "github", Subject: "42", UserID: alice.ID}))
+	userID, err := identities.UserID(ctx, "github", "42")
+	require.NoError(t, err)
+	require.Equal(t, alice.ID, userID)
+
+	err = identities.Link(ctx, &model.UserIdentity{Provider: "github", Subject: "42", UserID: alice.ID})
+	require.ErrorIs(t, err, apperr.ErrConflict)
+	err = identities.Link(ctx, &model.UserIdentity{Provider: "google", Subject: "42", UserID: uuid.New().String()})
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestPgAPIKeyRepo_CreateAndRevoke(t *testing.T) {
+	db := testDB(t)
+	users, keys := NewUserRepo(db, nil), NewAPIKeyRepo(db)
+	ctx := context.Background()
+	alice := createUser(t, users, ctx, "alice")
+
+	k := &model.APIKey{Name: "sync", Prefix: "lk_abcdefgh", Hash: "hash-1", UserID: alice.ID, Scopes: []string{model.APIKeyScopeRead}}
+	require.NoError(t, keys.Create(ctx, k))
+	require.NotEmpty(t, k.ID)
+
+	got, err := keys.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	require.Equal(t, alice.ID, got.UserID)
+	require.Equal(t, []string{model.APIKeyScopeRead}, got.Scopes)
+	require.Nil(t, got.RevokedAt)
+
+	err = keys.Create(ctx, &model.APIKey{Name: "orphan", Prefix: "lk_x", Hash: "hash-2", UserID: uuid.New().String(), Scopes: []string{"read"}})
+	require.ErrorIs(t, err, apperr.ErrValidation)
+
+	require.NoError(t, keys.Revoke(ctx, k.ID, time.Now()))
+	require.ErrorIs(t, keys.Revoke(ctx, k.ID, time.Now()), apperr.ErrNotFound)
+	got, err = keys.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	require.NotNil(t, got.RevokedAt)
+}
+
+func TestPgReviewRepo_AverageAndModeration(t *testing.T) {
+	db := testDB(t)
+	books, users, reviews := NewBookRepo(db, nil), NewUserRepo(db, nil), NewReviewRepo(db, nil)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice, bob := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "bob")
+
+	first := &model.Review{BookID: book.ID, UserID: alice.ID, Rating: 5, Text: "great"}
+	require.NoError(t, reviews.Create(ctx, first))
+	require.Equal(t, "alice", first.Username)
+	require.NoError(t, reviews.Create(ctx, &model.Review{BookID: book.ID, UserID: bob.ID, Rating: 2}))
+	err := reviews.Create(ctx, &model.Review{BookID: book.ID, UserID: alice.ID, Rating: 1})
+	require.ErrorIs(t, err, apperr.ErrConflict)
+
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3.5, got.AverageRating)
+	require.Equal(t, 2, got.ReviewCount)
+
+	page, err := reviews.List(ctx, model.PageRequest{Limit: 1}, model.ReviewFilter{BookID: book.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, page.Total)
+	require.Len(t, page.Items, 1)
+	require.NotEmpty(t, page.NextCursor)
+	page, err = reviews.List(ctx, model.PageRequest{Limit: 10}, model.ReviewFilter{UserID: alice.ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	require.Equal(t, first.ID, page.Items[0].ID)
+
+	require.NoError(t, reviews.Delete(ctx, first.ID))
+	require.ErrorIs(t, reviews.Delete(ctx, first.ID), apperr.ErrNotFound)
+	got, err = books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, got.AverageRating)
+	require.Equal(t, 1, got.ReviewCount)
+}
+
+func TestPgReservationRepo_QueueAndOffers(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings, reservations := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil), NewReservationRepo(db)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice, bob := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "bob")
+
+	first := &model.Reservation{BookID: book.ID, UserID: alice.ID}
+	require.NoError(t, reservations.Create(ctx, first))
+	require.Equal(t, 1, first.Position)
+	second := &model.Reservation{BookID: book.ID, UserID: bob.ID}
+	require.NoError(t, reservations.Create(ctx, second))
+	require.Equal(t, 2, second.Position)
+	require.ErrorIs(t, reservations.Create(ctx, &model.Reservation{BookID: book.ID, UserID: bob.ID}), apperr.ErrConflict)
+
+	mine, err := reservations.ListByUser(ctx, bob.ID)
+	require.NoError(t, err)
+	require.Len(t, mine, 1)
+	require.Equal(t, 2, mine[0].Position)
+	waiting, err := reservations.CountWaiting(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, waiting)
+	ids, err := reservations.WaitlistedBookIDs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{book.ID}, ids)
+
+	next, err := reservations.PopNext(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, alice.ID, next.UserID)
+	mine, err = reservations.ListByUser(ctx, bob.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, mine[0].Position)
+
+	// An offer holds a copy until it lapses.
+	now := time.Now().UTC()
+	expires := now.Add(-time.Minute)
+	offer := &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: expires, Status: "OFFERED", OfferExpiresAt: &expires}
+	require.NoError(t, bookings.Create(ctx, offer))
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.False(t, got.Available)
+	expired, err := bookings.ExpiredOffers(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	require.Equal(t, offer.ID, expired[0].ID)
+	updated, err := bookings.Update(ctx, offer.ID, model.UpdateBookingPatch{Status: model.Ptr("EXPIRED"), ClearOfferExpiry: true})
+	require.NoError(t, err)
+	require.Nil(t, updated.OfferExpiresAt)
+
+	require.ErrorIs(t, reservations.Delete(ctx, alice.ID, second.ID), apperr.ErrNotFound)
+	require.NoError(t, reservations.Delete(ctx, bob.ID, second.ID))
+	_, err = reservations.PopNext(ctx, book.ID)
+	require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestPgExtensionRequestRepo_OnePendingPerBooking(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings, extensions := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil), NewExtensionRequestRepo(db)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice, admin := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "admin")
+
+	now := time.Now().UTC()
+	booking := &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 14), Status: "ACTIVE"}
+	require.NoError(t, bookings.Create(ctx, booking))
+
+	er := &model.ExtensionRequest{BookingID: booking.ID, UserID: alice.ID, CurrentDueDate: booking.DueDate, RequestedDueDate: booking.DueDate.AddDate(0, 0, 30), Reason: "Course"}
+	require.NoError(t, extensions.Create(ctx, er))
+	require.Equal(t, model.DefaultBranchID, er.BranchID)
+	require.Equal(t, model.ExtensionPending, er.Status)
+	again := &model.ExtensionRequest{BookingID: booking.ID, UserID: alice.ID, CurrentDueDate: booking.DueDate, RequestedDueDate: booking.DueDate.AddDate(0, 0, 7), Reason: "Again"}
+	require.ErrorIs(t, extensions.Create(ctx, again), apperr.ErrConflict)
+
+	require.NoError(t, extensions.Decide(ctx, er.ID, model.ExtensionDenied, admin.ID, "No", now))
+	got, err := extensions.GetByID(ctx, er.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.ExtensionDenied, got.Status)
+	require.Equal(t, admin.ID, got.DecidedBy)
+	require.Equal(t, "No", got.Comment)
+	require.NotNil(t, got.DecidedAt)
+
+	// Once decided, the booking can be asked about again.
+	require.NoError(t, extensions.Create(ctx, again))
+	pending, err := extensions.List(ctx, model.PageRequest{Limit: 10}, model.ExtensionRequestFilter{Status: model.ExtensionPending})
+	require.NoError(t, err)
+	require.Equal(t, 1, pending.Total)
+	require.Equal(t, again.ID, pending.Items[0].ID)
+	mine, err := extensions.List(ctx, model.PageRequest{Limit: 10}, model.ExtensionRequestFilter{UserID: alice.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, mine.Total)
+}
+
+func TestPgBookingRepo_OneActiveLoanPerUserAndBook(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice := createUser(t, users, ctx, "alice")
+
+	now := time.Now().UTC()
+	loan := func(status string) *model.Booking {
+		return &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 14), Status: status}
+	}
+	first := loan("ACTIVE")
+	require.NoError(t, bookings.Create(ctx, first))
+	require.ErrorIs(t, bookings.Create(ctx, loan("ACTIVE")), apperr.ErrConflict)
+
+	// Loans no longer ACTIVE don't count.
+	overdue := loan("OVERDUE")
+	require.NoError(t, bookings.Create(ctx, overdue))
+	_, err := bookings.Update(ctx, overdue.ID, model.UpdateBookingPatch{Status: model.Ptr("ACTIVE")})
+	require.ErrorIs(t, err, apperr.ErrConflict)
+	_, err = bookings.Update(ctx, first.ID, model.UpdateBookingPatch{Status: model.Ptr("RETURNED"), ReturnedAt: &now})
+	require.NoError(t, err)
+	require.NoError(t, bookings.Create(ctx, loan("ACTIVE")))
+}
+
+func TestPgMigration_ResolvesDuplicateActiveLoans(t *testing.T) {
+	db := testDB(t)
+	books, users, bookings := NewBookRepo(db, nil), NewUserRepo(db, nil), NewBookingRepo(db, nil)
+	ctx := context.Background()
+	book := createBook(t, books, ctx, "1")
+	alice, bob := createUser(t, users, ctx, "alice"), createUser(t, users, ctx, "bob")
+
+	// Recreate the state the migration meets: duplicates from before the index.
+	_, err := db.Exec(ctx, `DROP INDEX idx_bookings_active_user_book`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = db.Exec(ctx, `TRUNCATE bookings; CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_user_book
+			ON bookings (user_id, book_id) WHERE status = 'ACTIVE'`)
+	})
+	now := time.Now().UTC().Truncate(time.Second)
+	loan := func(u *model.User, borrowed time.Time) *model.Booking {
+		b := &model.Booking{UserID: u.ID, BookID: book.ID, BorrowedAt: borrowed, DueDate: borrowed.AddDate(0, 0, 14), Status: "ACTIVE"}
+		require.NoError(t, bookings.Create(ctx, b))
+		return b
+	}
+	kept := loan(alice, now.Add(-2*time.Hour))
+	later := loan(alice, now.Add(-time.Hour))
+	other := loan(bob, now)
+
+	sql, err := os.ReadFile(filepath.Join("..", "migrate", "0040_unique_active_booking.up.sql"))
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, string(sql))
+	require.NoError(t, err)
+
+	for _, b := range []*model.Booking{kept, other} {
+		got, err := bookings.GetByID(ctx, b.ID)
+		require.NoError(t, err)
+		require.Equal(t, "ACTIVE", got.Status)
+	}
+	got, err := bookings.GetByID(ctx, later.ID)
+	require.NoError(t, err)
+	require.Equal(t, "RETURNED", got.Status, "the later duplicate is returned")
+	require.NotNil(t, got.ReturnedAt)
+	require.ErrorIs(t, bookings.Create(ctx, &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now, Status: "ACTIVE"}), apperr.ErrConflict,
+		"the index is back")
+}
+
+func TestPgClosureRepo_ScopedByBranch(t *testing.T) {
+	db := testDB(t)
+	closures, branches := NewClosureRepo(db), NewBranchRepo(db)
+	ctx := context.Background()
+	north := &model.Branch{Code: "north", Name: "North"}
+	require.NoError(t, branches.Create(ctx, north))
+	northCtx := tenant.WithBranch(ctx, north.ID)
+
+	day := time.Date(2030, 12, 24, 0, 0, 0, 0, time.UTC)
+	everywhere := &model.Closure{StartsOn: day, EndsOn: day.AddDate(0, 0, 2), Reason: "Holidays"}
+	require.NoError(t, closures.Create(ctx, everywhere))
+	require.Empty(t, everywhere.BranchID)
+	local := &model.Closure{StartsOn: day.AddDate(0, 0, 10), EndsOn: day.AddDate(0, 0, 10)}
+	require.NoError(t, closures.Create(northCtx, local))
+	require.Equal(t, north.ID, local.BranchID)
+
+	got, err := closures.List(northCtx, day.AddDate(0, 0, 2), day.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, everywhere.ID, got[0].ID)
+	require.Equal(t, day, got[0].StartsOn)
+	require.Equal(t, north.ID, got[1].BranchID)
+	got, err = closures.ForBranch(ctx, model.DefaultBranchID, day, day.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	got, err = closures.List(ctx, day.AddDate(0, 0, 3), day.AddDate(0, 0, 9))
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	require.ErrorIs(t, closures.Delete(northCtx, everywhere.ID), apperr.ErrNotFound)
+	require.NoError(t, closures.Delete(ctx, everywhere.ID))
+}
+
+func TestPgJobRepo_ClaimRetryAndRequeue(t *testing.T) {
+	db := testDB(t)
+	jobs := NewJobRepo(db)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	first := &model.Job{Kind: "email", Payload: []byte(`{"to":"a@example.com"}`), MaxAttempts: 2}
+	require.NoError(t, jobs.Enqueue(ctx, first))
+	later := &model.Job{Kind: "email", Payload: []byte(`{}`), MaxAttempts: 2, RunAt: now.Add(time.Hour)}
+	require.NoError(t, jobs.Enqueue(ctx, later))
+
+	claimed, err := jobs.Claim(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "jobs run no earlier than run_at")
+	require.Equal(t, first.ID, claimed[0].ID)
+	require.Equal(t, model.JobRunning, claimed[0].Status)
+	require.Equal(t, 1, claimed[0].Attempts)
+	claimed, err = jobs.Claim(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Empty(t, claimed, "a running job isn't claimed twice")
+
+	require.NoError(t, jobs.Retry(ctx, first.ID, "timeout", now))
+	require.ErrorIs(t, jobs.Complete(ctx, first.ID), apperr.ErrNotFound, "only running jobs finish")
+	_, err = jobs.Requeue(ctx, first.ID)
+	require.ErrorIs(t, err, apperr.ErrConflict)
+
+	claimed, err = jobs.Claim(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, 2, claimed[0].Attempts)
+	require.NoError(t, jobs.Bury(ctx, first.ID, "mailbox full"))
+
+	page, err := jobs.List(ctx, model.PageRequest{Limit: 10}, model.JobFilter{Status: model.JobDead})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	require.Equal(t, "mailbox full", page.Items[0].LastError)
+	require.JSONEq(t, `{"to":"a@example.com"}`, string(page.Items[0].Payload))
+
+	requeued, err := jobs.Requeue(ctx, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobPending, requeued.Status)
+	require.Zero(t, requeued.Attempts)
+
+	// A worker that died mid-job leaves it running until it goes stale.
+	_, err = jobs.Claim(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	n, err := jobs.RecoverStale(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = jobs.RecoverStale(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestPgOutboxRepo_PendingSkipsLockedEvents(t *testing.T) {
+	db := testDB(t)
+	outbox, tx := NewOutboxRepo(db), NewTxManager(db)
+	ctx := context.Background()
+	for _, subject := range []string{"a", "b", "c"} {
+		require.NoError(t, outbox.Add(ctx, &model.Event{Type: model.EventBookingCreated, Subject: subject, Data: []byte(`{"n":1}`)}))
+	}
+
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		held, err := outbox.Pending(ctx, 2)
+		require.NoError(t, err)
+		require.Equal(t, "a", held[0].Subject)
+		require.Equal(t, "b", held[1].Subject)
+
+		// Another relay skips the events this one holds.
+		others, err := outbox.Pending(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, others, 1)
+		require.Equal(t, "c", others[0].Subject)
+
+		require.NoError(t, outbox.MarkDelivered(ctx, held[0].ID))
+		return outbox.MarkFailed(ctx, held[1].ID, "timeout")
+	})
+	require.NoError(t, err)
+
+	pending, err := outbox.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, "b", pending[0].Subject)
+	require.Equal(t, 1, pending[0].Attempts)
+	require.JSONEq(t, `{"n":1}`, string(pending[0].Data))
+
+	n, err := outbox.PurgeDelivered(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestPgTxManager_RollsBack(t *testing.T) {
+	db := testDB(t)
+	books, tx := NewBookRepo(db, nil), NewTxManager(db)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		createBook(t, books, ctx, "1")
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	page, err := books.List(ctx, model.PageRequest{Limit: 10}, model.BookFilter{})
+	require.NoError(t, err)
+	require.Zero(t, page.Total)
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+// branchScope returns the condition limiting col to the branch ctx is
+// scoped to, with its argument appended to args. Outside a branch there is
+// no condition and args is returned as is.
+func branchScope(ctx context.Context, col string, args []interface{}) ([]string, []interface{}) {
+	id := tenant.BranchID(ctx)
+	if id == "" {
+		return nil, args
+	}
+	args = append(args, id)
+	return []string{fmt.Sprintf("%s = $%d", col, len(args))}, args
+}
+
+// branchOrDefault is the branch ctx is scoped to, or the main branch.
+func branchOrDefault(ctx context.Context) string {
+	if id := tenant.BranchID(ctx); id != "" {
+		return id
+	}
+	return model.DefaultBranchID
+}
+
+// updateQuery renders "UPDATE table SET ... WHERE id = $n" from a map of
+// column values. Columns must appear in allowed, so map keys coming from
+// callers never reach the SQL unchecked, and are written in sorted order so
+// equal maps always produce the same statement. returning, when not empty,
+// is appended as the RETURNING list.
+func updateQuery(table string, allowed []string, values map[string]interface{}, id string, returning string) (string, []interface{}, error) {
+	sets, args, err := setClause(table, allowed, values)
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, id)
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", table, sets, len(args))
+	if returning != "" {
+		query += " RETURNING " + returning
+	}
+	return query, args, nil
+}
+
+// versionedUpdateQuery is updateQuery for a table with a version column,
+// which the statement bumps. When expected is not nil the row is only
+// updated if its version still equals *expected.
+func versionedUpdateQuery(table string, allowed []string, values map[string]interface{}, id string, expected *int, returning string) (string, []interface{}, error) {
+	sets, args, err := setClause(table, allowed, values)
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, id, expected)
+
+	query := fmt.Sprintf("UPDATE %s SET %s, version = version + 1 WHERE id = $%d AND ($%d::int IS NULL OR version = $%d)",
+		table, sets, len(args)-1, len(args), len(args))
+	if returning != "" {
+		query += " RETURNING " + returning
+	}
+	return query, args, nil
+}
+
+// setClause renders the SET list of updateQuery and its arguments, which
+// are numbered from $1.
+func setClause(table string, allowed []string, values map[string]interface{}) (string, []interface{}, error) {
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("update %s: no columns to set", table)
+	}
+
+	cols := make([]string, 0, len(values))
+	for col := range values {
+		if !slices.Contains(allowed, col) {
+			return "", nil, fmt.Errorf("update %s: column %q is not updatable", table, col)
+		}
+		cols = append(cols, col)
+	}
+	slices.Sort(cols)
+
+	sets := make([]string, len(cols))
+	args := make([]interface{}, 0, len(cols)+2)
+	for i, col := range cols {
+		sets[i] = fmt.Sprintf("%s = $%d", col, i+1)
+		args = append(args, values[col])
+	}
+	return strings.Join(sets, ", "), args, nil
+}
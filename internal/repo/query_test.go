@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateQuery_SortedAndDeterministic(t *testing.T) {
+	values := map[string]interface{}{"status": "RETURNED", "returned_at": "now", "updated_at": "later"}
+	want := "UPDATE bookings SET returned_at = $1, status = $2, updated_at = $3 WHERE id = $4 RETURNING id, status"
+
+	for i := 0; i < 20; i++ {
+		query, args, err := updateQuery("bookings", bookingUpdatable, values, "bk1", "id, status")
+		require.NoError(t, err)
+		require.Equal(t, want, query)
+		require.Equal(t, []interface{}{"now", "RETURNED", "later", "bk1"}, args)
+	}
+}
+
+func TestUpdateQuery_TenOrMoreColumns(t *testing.T) {
+	allowed := []string{}
+	values := map[string]interface{}{}
+	for i := 0; i < 12; i++ {
+		col := fmt.Sprintf("c%02d", i)
+		allowed = append(allowed, col)
+		values[col] = i
+	}
+
+	query, args, err := updateQuery("t", allowed, values, "x", "")
+	require.NoError(t, err)
+	require.Contains(t, query, "c11 = $12 WHERE id = $13")
+	require.Len(t, args, 13)
+	require.Equal(t, "x", args[12])
+}
+
+func TestUpdateQuery_RejectsUnknownAndEmpty(t *testing.T) {
+	_, _, err := updateQuery("users", userUpdatable, map[string]interface{}{"email = 'x'; --": "y"}, "u1", "")
+	require.Error(t, err)
+
+	_, _, err = updateQuery("users", userUpdatable, map[string]interface{}{}, "u1", "")
+	require.Error(t, err)
+}
+
+func TestBranchScope(t *testing.T) {
+	conds, args := branchScope(context.Background(), "b.branch_id", []interface{}{"x"})
+	require.Empty(t, conds)
+	require.Equal(t, []interface{}{"x"}, args)
+
+	ctx := tenant.WithBranch(context.Background(), "b1")
+	conds, args = branchScope(ctx, "b.branch_id", []interface{}{"x"})
+	require.Equal(t, []string{"b.branch_id = $2"}, conds)
+	require.Equal(t, []interface{}{"x", "b1"}, args)
+}
+
+func TestVersionedUpdateQuery(t *testing.T) {
+	expected := 3
+	query, args, err := versionedUpdateQuery("users", userUpdatable, map[string]interface{}{"email": "a@b.c"}, "u1", &expected, "id")
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE users SET email = $1, version = version + 1 WHERE id = $2 AND ($3::int IS NULL OR version = $3) RETURNING id", query)
+	require.Equal(t, []interface{}{"a@b.c", "u1", &expected}, args)
+}
@@ -0,0 +1,198 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// memListEntry keys the books on reading lists.
+type memListEntry struct {
+	listID, bookID string
+}
+
+type memReadingListRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryReadingListRepo(s *MemoryStore) ReadingListRepo {
+	return &memReadingListRepo{s: s}
+}
+
+func (r *memReadingListRepo) Create(ctx context.Context, l *model.ReadingList) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[l.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	if l.Favorites {
+		for _, other := range r.s.data.readingLists {
+			if other.UserID == l.UserID && other.Favorites {
+				return apperr.Conflict("you already have a favorites list")
+			}
+		}
+	}
+	l.ID = uuid.New().String()
+	l.CreatedAt = time.Now().UTC()
+	l.UpdatedAt = l.CreatedAt
+	l.ShareToken = ""
+	l.Books = []model.ReadingListBook{}
+	stored := *l
+	stored.Books = nil
+	r.s.data.readingLists[l.ID] = stored
+	return nil
+}
+
+func (r *memReadingListRepo) GetByID(ctx context.Context, id string) (*model.ReadingList, error) {
+	return r.getOne(ctx, func(l model.ReadingList) bool { return l.ID == id })
+}
+
+func (r *memReadingListRepo) GetByShareToken(ctx context.Context, token string) (*model.ReadingList, error) {
+	return r.getOne(ctx, func(l model.ReadingList) bool { return token != "" && l.ShareToken == token })
+}
+
+func (r *memReadingListRepo) Favorites(ctx context.Context, userID string) (*model.ReadingList, error) {
+	return r.getOne(ctx, func(l model.ReadingList) bool { return l.UserID == userID && l.Favorites })
+}
+
+func (r *memReadingListRepo) getOne(ctx context.Context, match func(model.ReadingList) bool) (*model.ReadingList, error) {
+	defer r.s.lock(ctx)()
+	for _, l := range r.s.data.readingLists {
+		if match(l) {
+			l.Books = r.books(l.ID)
+			return &l, nil
+		}
+	}
+	return nil, errReadingListNotFound
+}
+
+func (r *memReadingListRepo) ListByUser(ctx context.Context, userID string) ([]model.ReadingList, error) {
+	defer r.s.lock(ctx)()
+	out := []model.ReadingList{}
+	for _, l := range r.s.data.readingLists {
+		if l.UserID == userID {
+			l.Books = r.books(l.ID)
+			out = append(out, l)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.ReadingList) int {
+		if a.Favorites != b.Favorites {
+			if a.Favorites {
+				return -1
+			}
+			return 1
+		}
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+// books returns the books on the list, oldest addition first. The caller
+// holds the store.
+func (r *memReadingListRepo) books(listID string) []model.ReadingListBook {
+	books := &memBookRepo{s: r.s}
+	out := []model.ReadingListBook{}
+	for e, added := range r.s.data.listBooks {
+		if b, ok := r.s.data.books[e.bookID]; ok && e.listID == listID {
+			out = append(out, model.ReadingListBook{Book: books.view(b), AddedAt: added})
+		}
+	}
+	slices.SortFunc(out, func(a, b model.ReadingListBook) int {
+		if c := a.AddedAt.Compare(b.AddedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+func (r *memReadingListRepo) Rename(ctx context.Context, id, name string) error {
+	return r.update(ctx, id, func(l *model.ReadingList) { l.Name = name })
+}
+
+func (r *memReadingListRepo) SetShareToken(ctx context.Context, id, token string) error {
+	return r.update(ctx, id, func(l *model.ReadingList) { l.ShareToken = token })
+}
+
+func (r *memReadingListRepo) update(ctx context.Context, id string, fn func(*model.ReadingList)) error {
+	defer r.s.lock(ctx)()
+	l, ok := r.s.data.readingLists[id]
+	if !ok {
+		return errReadingListNotFound
+	}
+	fn(&l)
+	l.UpdatedAt = time.Now().UTC()
+	r.s.data.readingLists[id] = l
+	return nil
+}
+
+func (r *memReadingListRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.readingLists[id]; !ok {
+		return errReadingListNotFound
+	}
+	delete(r.s.data.readingLists, id)
+	for e := range r.s.data.listBooks {
+		if e.listID == id {
+			delete(r.s.data.listBooks, e)
+		}
+	}
+	return nil
+}
+
+// deleteReadingLists drops the user's lists and the books on them.
+func deleteReadingLists(d *memData, userID string) {
+	for id, l := range d.readingLists {
+		if l.UserID == userID {
+			delete(d.readingLists, id)
+		}
+	}
+	for e := range d.listBooks {
+		if _, ok := d.readingLists[e.listID]; !ok {
+			delete(d.listBooks, e)
+		}
+	}
+}
+
+func (r *memReadingListRepo) AddBook(ctx context.Context, id, bookID string) error {
+	defer r.s.lock(ctx)()
+	l, ok := r.s.data.readingLists[id]
+	if !ok {
+		return errReadingListNotFound
+	}
+	if _, ok := r.s.data.books[bookID]; !ok {
+		return apperr.NotFound("book not found")
+	}
+	e := memListEntry{listID: id, bookID: bookID}
+	if _, ok := r.s.data.listBooks[e]; ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	r.s.data.listBooks[e] = now
+	l.UpdatedAt = now
+	r.s.data.readingLists[id] = l
+	return nil
+}
+
+func (r *memReadingListRepo) RemoveBook(ctx context.Context, id, bookID string) error {
+	defer r.s.lock(ctx)()
+	l, ok := r.s.data.readingLists[id]
+	if !ok {
+		return errReadingListNotFound
+	}
+	e := memListEntry{listID: id, bookID: bookID}
+	if _, ok := r.s.data.listBooks[e]; !ok {
+		return apperr.NotFound("book is not on the list")
+	}
+	delete(r.s.data.listBooks, e)
+	l.UpdatedAt = time.Now().UTC()
+	r.s.data.readingLists[id] = l
+	return nil
+}
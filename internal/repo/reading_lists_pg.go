@@ -0,0 +1,198 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// ReadingListRepo stores users' reading lists and the books on them. Lists
+// come back with their books, oldest addition first.
+type ReadingListRepo interface {
+	// Create stores l. It is a Conflict if l is a favorites list and the
+	// user already has one.
+	Create(ctx context.Context, l *model.ReadingList) error
+	GetByID(ctx context.Context, id string) (*model.ReadingList, error)
+	GetByShareToken(ctx context.Context, token string) (*model.ReadingList, error)
+	// Favorites returns the user's favorites list, NotFound until made.
+	Favorites(ctx context.Context, userID string) (*model.ReadingList, error)
+	// ListByUser returns the user's lists, favorites first, then oldest
+	// first.
+	ListByUser(ctx context.Context, userID string) ([]model.ReadingList, error)
+	Rename(ctx context.Context, id, name string) error
+	// SetShareToken shares the list under token, or stops sharing it when
+	// token is "".
+	SetShareToken(ctx context.Context, id, token string) error
+	Delete(ctx context.Context, id string) error
+	// AddBook puts the book on the list; adding it again changes nothing.
+	AddBook(ctx context.Context, id, bookID string) error
+	// RemoveBook takes the book off the list, NotFound if it isn't on it.
+	RemoveBook(ctx context.Context, id, bookID string) error
+}
+
+const readingListColumns = `id, user_id, name, favorites, COALESCE(share_token, ''), created_at, updated_at`
+
+var errReadingListNotFound = apperr.NotFound("reading list not found")
+
+type pgReadingListRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewReadingListRepo(db *pgxpool.Pool) ReadingListRepo {
+	return &pgReadingListRepo{db: db}
+}
+
+func scanReadingList(row pgx.Row) (model.ReadingList, error) {
+	var l model.ReadingList
+	err := row.Scan(&l.ID, &l.UserID, &l.Name, &l.Favorites, &l.ShareToken, &l.CreatedAt, &l.UpdatedAt)
+	return l, err
+}
+
+func (r *pgReadingListRepo) Create(ctx context.Context, l *model.ReadingList) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO reading_lists (user_id, name, favorites) VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`,
+		l.UserID, l.Name, l.Favorites,
+	).Scan(&l.ID, &l.CreatedAt, &l.UpdatedAt)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("you already have a favorites list")
+	}
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("user not found")
+	}
+	if err != nil {
+		return err
+	}
+	l.Books = []model.ReadingListBook{}
+	return nil
+}
+
+func (r *pgReadingListRepo) GetByID(ctx context.Context, id string) (*model.ReadingList, error) {
+	return r.getOne(ctx, `id = $1`, id)
+}
+
+func (r *pgReadingListRepo) GetByShareToken(ctx context.Context, token string) (*model.ReadingList, error) {
+	return r.getOne(ctx, `share_token = $1`, token)
+}
+
+func (r *pgReadingListRepo) Favorites(ctx context.Context, userID string) (*model.ReadingList, error) {
+	return r.getOne(ctx, `user_id = $1 AND favorites`, userID)
+}
+
+func (r *pgReadingListRepo) getOne(ctx context.Context, cond string, arg any) (*model.ReadingList, error) {
+	l, err := scanReadingList(conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+readingListColumns+` FROM reading_lists WHERE `+cond, arg))
+	if isNoRows(err) {
+		return nil, errReadingListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	lists := []model.ReadingList{l}
+	if err := r.withBooks(ctx, lists); err != nil {
+		return nil, err
+	}
+	return &lists[0], nil
+}
+
+func (r *pgReadingListRepo) ListByUser(ctx context.Context, userID string) ([]model.ReadingList, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+readingListColumns+` FROM reading_lists WHERE user_id = $1
+		ORDER BY favorites DESC, created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.ReadingList, error) {
+		return scanReadingList(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.withBooks(ctx, lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// withBooks fills in the books on each of lists in one query.
+func (r *pgReadingListRepo) withBooks(ctx context.Context, lists []model.ReadingList) error {
+	ids := make([]string, len(lists))
+	index := make(map[string]int, len(lists))
+	for i := range lists {
+		ids[i] = lists[i].ID
+		index[lists[i].ID] = i
+		lists[i].Books = []model.ReadingListBook{}
+	}
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT e.list_id, e.added_at, bb.* FROM reading_list_books e
+		JOIN (`+bookSelect+where(liveBook)+`) bb ON bb.id = e.book_id
+		WHERE e.list_id = ANY($1)
+		ORDER BY e.added_at, bb.id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var listID string
+		var b model.ReadingListBook
+		if err := rows.Scan(append([]interface{}{&listID, &b.AddedAt}, bookDest(&b.Book)...)...); err != nil {
+			return err
+		}
+		b.Available = b.CopiesAvailable > 0
+		l := &lists[index[listID]]
+		l.Books = append(l.Books, b)
+	}
+	return rows.Err()
+}
+
+func (r *pgReadingListRepo) Rename(ctx context.Context, id, name string) error {
+	return r.exec(ctx, `UPDATE reading_lists SET name = $2, updated_at = now() WHERE id = $1`, id, name)
+}
+
+func (r *pgReadingListRepo) SetShareToken(ctx context.Context, id, token string) error {
+	return r.exec(ctx, `UPDATE reading_lists SET share_token = NULLIF($2, ''), updated_at = now() WHERE id = $1`, id, token)
+}
+
+func (r *pgReadingListRepo) Delete(ctx context.Context, id string) error {
+	return r.exec(ctx, `DELETE FROM reading_lists WHERE id = $1`, id)
+}
+
+// exec runs a statement on the list with ID args[0], NotFound if there
+// isn't one.
+func (r *pgReadingListRepo) exec(ctx context.Context, q string, args ...any) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errReadingListNotFound
+	}
+	return nil
+}
+
+func (r *pgReadingListRepo) AddBook(ctx context.Context, id, bookID string) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`WITH l AS (UPDATE reading_lists SET updated_at = now() WHERE id = $1 RETURNING id)
+		INSERT INTO reading_list_books (list_id, book_id) SELECT id, $2 FROM l
+		ON CONFLICT DO NOTHING`, id, bookID)
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("book not found")
+	}
+	return err
+}
+
+func (r *pgReadingListRepo) RemoveBook(ctx context.Context, id, bookID string) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM reading_list_books WHERE list_id = $1 AND book_id = $2`, id, bookID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("book is not on the list")
+	}
+	_, err = conn(ctx, r.db).Exec(ctx, `UPDATE reading_lists SET updated_at = now() WHERE id = $1`, id)
+	return err
+}
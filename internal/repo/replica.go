@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Replica is a read-replica pool. Repositories send read-only queries that
+// tolerate replication lag, such as lists, searches and catalog lookups, to
+// it while it is healthy, and everything else to the primary. A nil
+// *Replica is never healthy, so all queries go to the primary.
+type Replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// NewReplica wraps pool, which starts out healthy.
+func NewReplica(pool *pgxpool.Pool) *Replica {
+	r := &Replica{pool: pool}
+	r.healthy.Store(true)
+	return r
+}
+
+// Healthy reports whether reads are currently sent to the replica.
+func (r *Replica) Healthy() bool {
+	return r != nil && r.healthy.Load()
+}
+
+// replicaLagSQL is how far the replica's replay trails the primary. A
+// replica that has replayed everything it received is not lagging, however
+// long ago the last write on the primary was.
+const replicaLagSQL = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// Monitor checks the replica straight away and then every interval until
+// ctx is cancelled. Reads go to the primary while the replica fails to
+// answer within interval or, when maxLag is positive, trails the primary by
+// more than maxLag.
+func (r *Replica) Monitor(ctx context.Context, interval, maxLag time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := r.check(checkCtx, maxLag)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.InfoContext(ctx, "read replica healthy again, sending reads to it")
+			} else {
+				logger.WarnContext(ctx, "read replica unhealthy, sending reads to the primary", "error", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replica) check(ctx context.Context, maxLag time.Duration) error {
+	if maxLag <= 0 {
+		return r.pool.Ping(ctx)
+	}
+	var seconds float64
+	if err := r.pool.QueryRow(ctx, replicaLagSQL).Scan(&seconds); err != nil {
+		return err
+	}
+	if lag := time.Duration(seconds * float64(time.Second)); lag > maxLag {
+		return &lagError{lag: lag, max: maxLag}
+	}
+	return nil
+}
+
+type lagError struct {
+	lag, max time.Duration
+}
+
+func (e *lagError) Error() string {
+	return "replica lags by " + e.lag.Round(time.Millisecond).String() + ", more than " + e.max.String()
+}
+
+// readConn is conn for read-only queries that tolerate replication lag:
+// outside a transaction they go to replica while it is healthy.
+func readConn(ctx context.Context, db *pgxpool.Pool, replica *Replica) dbtx {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); !ok && replica.Healthy() {
+		return timed(replica.pool)
+	}
+	return conn(ctx, db)
+}
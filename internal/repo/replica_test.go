@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTx struct{ pgx.Tx }
+
+func TestReadConn_RoutesToHealthyReplicaOutsideTx(t *testing.T) {
+	ctx := context.Background()
+	primary := &pgxpool.Pool{}
+	replica := NewReplica(&pgxpool.Pool{})
+
+	require.Same(t, primary, readConn(ctx, primary, nil))
+	require.Same(t, replica.pool, readConn(ctx, primary, replica))
+
+	tx := &fakeTx{}
+	require.Same(t, tx, readConn(context.WithValue(ctx, txKey{}, pgx.Tx(tx)), primary, replica), "transactions read their own writes")
+
+	replica.healthy.Store(false)
+	require.False(t, replica.Healthy())
+	require.Same(t, primary, readConn(ctx, primary, replica))
+}
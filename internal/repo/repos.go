@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repos is the full set of repositories, all backed by the same store.
+type Repos struct {
+	Books         BookRepo
+	Users         UserRepo
+	Bookings      BookingRepo
+	LoginAttempts LoginAttemptRepo
+	Categories    CategoryRepo
+	Branches      BranchRepo
+	LoanPolicies  LoanPolicyRepo
+	Audit         AuditRepo
+	Revocations   TokenRevocationRepo
+	Sessions      SessionRepo
+	Logins        LoginHistoryRepo
+	CalendarFeeds CalendarFeedRepo
+	Identities    IdentityRepo
+	APIKeys       APIKeyRepo
+	Reviews       ReviewRepo
+	Reservations  ReservationRepo
+	Closures      ClosureRepo
+	Extensions    ExtensionRequestRepo
+	Jobs          JobRepo
+	Outbox        OutboxRepo
+	ScheduledRuns ScheduledRunRepo
+	Maintenance   MaintenanceRepo
+	Fines         FineRepo
+	Payments      PaymentRepo
+	FinePolicy    FinePolicyRepo
+	EmailChanges  EmailChangeRepo
+	Invitations   UserInvitationRepo
+	InviteCodes   InviteCodeRepo
+	Announcements AnnouncementRepo
+	ReadingLists  ReadingListRepo
+	UserStats     UserStatsRepo
+	Tx            TxManager
+	// Ping reports whether the store can serve requests.
+	Ping func(ctx context.Context) error
+}
+
+// NewPostgresRepos returns the repositories backed by db. Lists, searches
+// and catalog lookups go to replica while it is healthy; replica may be
+// nil.
+func NewPostgresRepos(db *pgxpool.Pool, replica *Replica) Repos {
+	return Repos{
+		Books:         NewBookRepo(db, replica),
+		Users:         NewUserRepo(db, replica),
+		Bookings:      NewBookingRepo(db, replica),
+		LoginAttempts: NewLoginAttemptRepo(db),
+		Categories:    NewCategoryRepo(db, replica),
+		Branches:      NewBranchRepo(db),
+		LoanPolicies:  NewLoanPolicyRepo(db),
+		Audit:         NewAuditRepo(db),
+		Revocations:   NewTokenRevocationRepo(db),
+		Sessions:      NewSessionRepo(db),
+		Logins:        NewLoginHistoryRepo(db),
+		CalendarFeeds: NewCalendarFeedRepo(db),
+		Identities:    NewIdentityRepo(db),
+		APIKeys:       NewAPIKeyRepo(db),
+		Reviews:       NewReviewRepo(db, replica),
+		Reservations:  NewReservationRepo(db),
+		Closures:      NewClosureRepo(db),
+		Extensions:    NewExtensionRequestRepo(db),
+		Jobs:          NewJobRepo(db),
+		Outbox:        NewOutboxRepo(db),
+		ScheduledRuns: NewScheduledRunRepo(db),
+		Maintenance:   NewMaintenanceRepo(db),
+		Fines:         NewFineRepo(db),
+		Payments:      NewPaymentRepo(db),
+		FinePolicy:    NewFinePolicyRepo(db),
+		EmailChanges:  NewEmailChangeRepo(db),
+		Invitations:   NewUserInvitationRepo(db),
+		InviteCodes:   NewInviteCodeRepo(db),
+		Announcements: NewAnnouncementRepo(db),
+		ReadingLists:  NewReadingListRepo(db),
+		UserStats:     NewUserStatsRepo(db, replica),
+		Tx:            NewTxManager(db),
+		Ping:          db.Ping,
+	}
+}
+
+// NewMemoryRepos returns the repositories backed by s.
+func NewMemoryRepos(s *MemoryStore) Repos {
+	return Repos{
+		Books:         NewMemoryBookRepo(s),
+		Users:         NewMemoryUserRepo(s),
+		Bookings:      NewMemoryBookingRepo(s),
+		LoginAttempts: NewMemoryLoginAttemptRepo(s),
+		Categories:    NewMemoryCategoryRepo(s),
+		Branches:      NewMemoryBranchRepo(s),
+		LoanPolicies:  NewMemoryLoanPolicyRepo(s),
+		Audit:         NewMemoryAuditRepo(s),
+		Revocations:   NewMemoryTokenRevocationRepo(s),
+		Sessions:      NewMemorySessionRepo(s),
+		Logins:        NewMemoryLoginHistoryRepo(s),
+		CalendarFeeds: NewMemoryCalendarFeedRepo(s),
+		Identities:    NewMemoryIdentityRepo(s),
+		APIKeys:       NewMemoryAPIKeyRepo(s),
+		Reviews:       NewMemoryReviewRepo(s),
+		Reservations:  NewMemoryReservationRepo(s),
+		Closures:      NewMemoryClosureRepo(s),
+		Extensions:    NewMemoryExtensionRequestRepo(s),
+		Jobs:          NewMemoryJobRepo(s),
+		Outbox:        NewMemoryOutboxRepo(s),
+		ScheduledRuns: NewMemoryScheduledRunRepo(s),
+		Maintenance:   NewMemoryMaintenanceRepo(s),
+		Fines:         NewMemoryFineRepo(s),
+		Payments:      NewMemoryPaymentRepo(s),
+		FinePolicy:    NewMemoryFinePolicyRepo(s),
+		EmailChanges:  NewMemoryEmailChangeRepo(s),
+		Invitations:   NewMemoryUserInvitationRepo(s),
+		InviteCodes:   NewMemoryInviteCodeRepo(s),
+		Announcements: NewMemoryAnnouncementRepo(s),
+		ReadingLists:  NewMemoryReadingListRepo(s),
+		UserStats:     NewMemoryUserStatsRepo(s),
+		Tx:            NewMemoryTxManager(s),
+		Ping:          func(context.Context) error { return nil },
+	}
+}
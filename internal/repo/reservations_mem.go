@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memReservationRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryReservationRepo(s *MemoryStore) ReservationRepo {
+	return &memReservationRepo{s: s}
+}
+
+// queue returns the book's reservations in line order.
+func (r *memReservationRepo) queue(bookID string) []model.Reservation {
+	out := []model.Reservation{}
+	for _, res := range r.s.data.reservations {
+		if res.BookID == bookID {
+			out = append(out, res)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Reservation) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	for i := range out {
+		out[i].Position = i + 1
+	}
+	return out
+}
+
+func (r *memReservationRepo) Create(ctx context.Context, res *model.Reservation) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.books[res.BookID]; !ok {
+		return apperr.NotFound("book not found")
+	}
+	if _, ok := r.s.data.users[res.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	queue := r.queue(res.BookID)
+	for _, other := range queue {
+		if other.UserID == res.UserID {
+			return apperr.Conflict("you are already on the waitlist for this book")
+		}
+	}
+	res.ID = uuid.New().String()
+	res.CreatedAt = time.Now().UTC()
+	res.Position = len(queue) + 1
+	r.s.data.reservations[res.ID] = *res
+	return nil
+}
+
+func (r *memReservationRepo) ListByUser(ctx context.Context, userID string) ([]model.Reservation, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Reservation{}
+	for _, res := range r.s.data.reservations {
+		b, ok := r.s.data.books[res.BookID]
+		if res.UserID != userID || !ok || !inBranch(ctx, b.BranchID) {
+			continue
+		}
+		for _, queued := range r.queue(res.BookID) {
+			if queued.ID == res.ID {
+				out = append(out, queued)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Reservation) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+func (r *memReservationRepo) Delete(ctx context.Context, userID, id string) error {
+	defer r.s.lock(ctx)()
+	res, ok := r.s.data.reservations[id]
+	if !ok || res.UserID != userID || !inBranch(ctx, r.s.data.books[res.BookID].BranchID) {
+		return apperr.NotFound("reservation not found")
+	}
+	delete(r.s.data.reservations, id)
+	return nil
+}
+
+func (r *memReservationRepo) CountWaiting(ctx context.Context, bookID string) (int, error) {
+	defer r.s.lock(ctx)()
+	return len(r.queue(bookID)), nil
+}
+
+func (r *memReservationRepo) PopNext(ctx context.Context, bookID string) (*model.Reservation, error) {
+	defer r.s.lock(ctx)()
+	queue := r.queue(bookID)
+	if len(queue) == 0 {
+		return nil, apperr.NotFound("nobody is waiting for this book")
+	}
+	delete(r.s.data.reservations, queue[0].ID)
+	return &queue[0], nil
+}
+
+func (r *memReservationRepo) WaitlistedBookIDs(ctx context.Context) ([]string, error) {
+	defer r.s.lock(ctx)()
+	ids := []string{}
+	for _, res := range r.s.data.reservations {
+		if !slices.Contains(ids, res.BookID) {
+			ids = append(ids, res.BookID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
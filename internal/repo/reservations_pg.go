@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// ReservationRepo stores book waitlists. Reservations are scoped to a branch
+// through their book.
+type ReservationRepo interface {
+	// Create returns a Conflict error if the user is already waiting for
+	// the book.
+	Create(ctx context.Context, res *model.Reservation) error
+	// ListByUser returns the user's reservations with their place in line,
+	// oldest first.
+	ListByUser(ctx context.Context, userID string) ([]model.Reservation, error)
+	// Delete removes the user's reservation id, or returns a NotFound error.
+	Delete(ctx context.Context, userID, id string) error
+	// CountWaiting returns how many users are waiting for the book.
+	CountWaiting(ctx context.Context, bookID string) (int, error)
+	// PopNext removes and returns the book's earliest reservation, or
+	// returns a NotFound error when nobody is waiting.
+	PopNext(ctx context.Context, bookID string) (*model.Reservation, error)
+	// WaitlistedBookIDs returns the books at every branch with a waitlist.
+	WaitlistedBookIDs(ctx context.Context) ([]string, error)
+}
+
+type pgReservationRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewReservationRepo(db *pgxpool.Pool) ReservationRepo {
+	return &pgReservationRepo{db: db}
+}
+
+func (r *pgReservationRepo) Create(ctx context.Context, res *model.Reservation) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO reservations (book_id, user_id) VALUES ($1, $2) RETURNING id, created_at,
+		(SELECT COUNT(*) + 1 FROM reservations WHERE book_id = $1)`,
+		res.BookID, res.UserID,
+	).Scan(&res.ID, &res.CreatedAt, &res.Position)
+	if _, ok := uniqueViolation(err); ok {
+		return apperr.Conflict("you are already on the waitlist for this book")
+	}
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("book not found")
+	}
+	return err
+}
+
+func (r *pgReservationRepo) ListByUser(ctx context.Context, userID string) ([]model.Reservation, error) {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{userID})
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT res.id, res.book_id, res.user_id, res.created_at,
+			(SELECT COUNT(*) FROM reservations ahead
+			 WHERE ahead.book_id = res.book_id AND (ahead.created_at, ahead.id) <= (res.created_at, res.id))
+		FROM reservations res JOIN books b ON b.id = res.book_id`+
+			where(append([]string{"res.user_id = $1"}, scope...)...)+` ORDER BY res.created_at, res.id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Reservation, error) {
+		var res model.Reservation
+		err := row.Scan(&res.ID, &res.BookID, &res.UserID, &res.CreatedAt, &res.Position)
+		return res, err
+	})
+}
+
+func (r *pgReservationRepo) Delete(ctx context.Context, userID, id string) error {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{id, userID})
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM reservations res USING books b`+
+			where(append([]string{"res.id = $1", "res.user_id = $2", "b.id = res.book_id"}, scope...)...),
+		args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("reservation not found")
+	}
+	return nil
+}
+
+func (r *pgReservationRepo) CountWaiting(ctx context.Context, bookID string) (int, error) {
+	var n int
+	err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM reservations WHERE book_id = $1`, bookID).Scan(&n)
+	return n, err
+}
+
+func (r *pgReservationRepo) PopNext(ctx context.Context, bookID string) (*model.Reservation, error) {
+	res := &model.Reservation{Position: 1}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`DELETE FROM reservations WHERE id = (
+			SELECT id FROM reservations WHERE book_id = $1 ORDER BY created_at, id LIMIT 1 FOR UPDATE
+		) RETURNING id, book_id, user_id, created_at`, bookID,
+	).Scan(&res.ID, &res.BookID, &res.UserID, &res.CreatedAt)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("nobody is waiting for this book")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *pgReservationRepo) WaitlistedBookIDs(ctx context.Context) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT DISTINCT book_id::text FROM reservations`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
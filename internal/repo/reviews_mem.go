@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memReviewRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryReviewRepo(s *MemoryStore) ReviewRepo {
+	return &memReviewRepo{s: s}
+}
+
+func (r *memReviewRepo) Create(ctx context.Context, rv *model.Review) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.books[rv.BookID]; !ok {
+		return apperr.NotFound("book not found")
+	}
+	u, ok := r.s.data.users[rv.UserID]
+	if !ok {
+		return apperr.NotFound("user not found")
+	}
+	for _, other := range r.s.data.reviews {
+		if other.BookID == rv.BookID && other.UserID == rv.UserID {
+			return errAlreadyReviewed
+		}
+	}
+	rv.ID = uuid.New().String()
+	rv.CreatedAt = time.Now().UTC()
+	rv.Username = u.Username
+	r.s.data.reviews[rv.ID] = *rv
+	return nil
+}
+
+// visible reports whether rv's book is in the branch ctx is scoped to.
+func (r *memReviewRepo) visible(ctx context.Context, rv model.Review) bool {
+	b, ok := r.s.data.books[rv.BookID]
+	return ok && inBranch(ctx, b.BranchID)
+}
+
+// view fills in the reviewer's current username, as the join does.
+func (r *memReviewRepo) view(rv model.Review) model.Review {
+	rv.Username = r.s.data.users[rv.UserID].Username
+	return rv
+}
+
+func (r *memReviewRepo) GetByID(ctx context.Context, id string) (*model.Review, error) {
+	defer r.s.lock(ctx)()
+	rv, ok := r.s.data.reviews[id]
+	if !ok || !r.visible(ctx, rv) {
+		return nil, apperr.NotFound("review not found")
+	}
+	rv = r.view(rv)
+	return &rv, nil
+}
+
+func (r *memReviewRepo) List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error) {
+	defer r.s.lock(ctx)()
+	reviews := []model.Review{}
+	for _, rv := range r.s.data.reviews {
+		if !r.visible(ctx, rv) || (f.BookID != "" && rv.BookID != f.BookID) || (f.UserID != "" && rv.UserID != f.UserID) {
+			continue
+		}
+		reviews = append(reviews, r.view(rv))
+	}
+	return memPage(reviews, p, func(rv model.Review) (time.Time, string) { return rv.CreatedAt, rv.ID })
+}
+
+func (r *memReviewRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	rv, ok := r.s.data.reviews[id]
+	if !ok || !r.visible(ctx, rv) {
+		return apperr.NotFound("review not found")
+	}
+	delete(r.s.data.reviews, id)
+	return nil
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// ReviewRepo stores book reviews. Reviews are scoped to a branch through
+// their book.
+type ReviewRepo interface {
+	// Create returns a Conflict error if the user has reviewed the book.
+	Create(ctx context.Context, rv *model.Review) error
+	GetByID(ctx context.Context, id string) (*model.Review, error)
+	// List returns the reviews matching f, newest first.
+	List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error)
+	Delete(ctx context.Context, id string) error
+}
+
+// reviewSelect wraps the join so the page and filter columns need no alias.
+const reviewSelect = `SELECT id, book_id, user_id, username, rating, text, created_at FROM (
+	SELECT rv.id, rv.book_id, rv.user_id, u.username, rv.rating, rv.text, rv.created_at, b.branch_id
+	FROM reviews rv JOIN users u ON u.id = rv.user_id JOIN books b ON b.id = rv.book_id
+) reviews`
+
+var errAlreadyReviewed = apperr.Conflict("you have already reviewed this book")
+
+func reviewDest(rv *model.Review) []interface{} {
+	return []interface{}{&rv.ID, &rv.BookID, &rv.UserID, &rv.Username, &rv.Rating, &rv.Text, &rv.CreatedAt}
+}
+
+type pgReviewRepo struct {
+	db      *pgxpool.Pool
+	replica *Replica
+}
+
+// NewReviewRepo returns a ReviewRepo on db whose listings go to replica
+// while it is healthy. replica may be nil.
+func NewReviewRepo(db *pgxpool.Pool, replica *Replica) ReviewRepo {
+	return &pgReviewRepo{db: db, replica: replica}
+}
+
+func (r *pgReviewRepo) Create(ctx context.Context, rv *model.Review) error {
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`INSERT INTO reviews (book_id, user_id, rating, text) VALUES ($1,$2,$3,$4)
+		RETURNING id, created_at, (SELECT username FROM users WHERE id=$2)`,
+		rv.BookID, rv.UserID, rv.Rating, rv.Text,
+	).Scan(&rv.ID, &rv.CreatedAt, &rv.Username)
+	if _, ok := uniqueViolation(err); ok {
+		return errAlreadyReviewed
+	}
+	if foreignKeyViolation(err) {
+		return apperr.NotFound("book not found")
+	}
+	return err
+}
+
+func (r *pgReviewRepo) GetByID(ctx context.Context, id string) (*model.Review, error) {
+	rv := &model.Review{}
+	scope, args := branchScope(ctx, "branch_id", []interface{}{id})
+	err := conn(ctx, r.db).QueryRow(ctx, reviewSelect+where(append([]string{"id=$1"}, scope...)...), args...).Scan(reviewDest(rv)...)
+	if isNoRows(err) {
+		return nil, apperr.NotFound("review not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (r *pgReviewRepo) List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error) {
+	page := model.Page[model.Review]{Items: []model.Review{}}
+	conds, args := reviewFilter(f)
+	scope, args := branchScope(ctx, "branch_id", args)
+	conds = append(conds, scope...)
+	if err := readConn(ctx, r.db, r.replica).QueryRow(ctx, `SELECT COUNT(*) FROM (`+reviewSelect+where(conds...)+`) matched`, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	keyset, tail, args, err := pageQuery(p, "created_at", args)
+	if err != nil {
+		return page, err
+	}
+	rows, err := readConn(ctx, r.db, r.replica).Query(ctx, reviewSelect+where(append(conds, keyset)...)+tail, args...)
+	if err != nil {
+		return page, err
+	}
+	page.Items, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Review, error) {
+		var rv model.Review
+		err := row.Scan(reviewDest(&rv)...)
+		return rv, err
+	})
+	if err != nil {
+		return page, err
+	}
+	page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(rv model.Review) string {
+		return encodeCursor(rv.CreatedAt, rv.ID)
+	})
+	return page, nil
+}
+
+// reviewFilter returns the WHERE conditions and arguments for f.
+func reviewFilter(f model.ReviewFilter) ([]string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.BookID != "" {
+		add("book_id = $%d", f.BookID)
+	}
+	if f.UserID != "" {
+		add("user_id = $%d", f.UserID)
+	}
+	return conds, args
+}
+
+func (r *pgReviewRepo) Delete(ctx context.Context, id string) error {
+	scope, args := branchScope(ctx, "b.branch_id", []interface{}{id})
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM reviews rv USING books b`+where(append([]string{"rv.id=$1", "b.id = rv.book_id"}, scope...)...), args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("review not found")
+	}
+	return nil
+}
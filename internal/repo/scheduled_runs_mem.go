@@ -0,0 +1,24 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+type memScheduledRunRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryScheduledRunRepo(s *MemoryStore) ScheduledRunRepo {
+	return &memScheduledRunRepo{s: s}
+}
+
+func (r *memScheduledRunRepo) Claim(ctx context.Context, name string, period time.Time) (bool, error) {
+	defer r.s.lock(ctx)()
+	key := name + "|" + period.UTC().Format(time.RFC3339Nano)
+	if _, ok := r.s.data.scheduledRuns[key]; ok {
+		return false, nil
+	}
+	r.s.data.scheduledRuns[key] = time.Now().UTC()
+	return true, nil
+}
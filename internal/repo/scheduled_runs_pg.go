@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ScheduledRunRepo records which periods of a periodic task have run, so
+// that of all the instances scheduling it, one runs it per period.
+type ScheduledRunRepo interface {
+	// Claim records the run of name for the period starting at period and
+	// reports whether this caller claimed it; false means it was already
+	// claimed. Within a transaction the claim is undone by a rollback.
+	Claim(ctx context.Context, name string, period time.Time) (bool, error)
+}
+
+type pgScheduledRunRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewScheduledRunRepo(db *pgxpool.Pool) ScheduledRunRepo {
+	return &pgScheduledRunRepo{db: db}
+}
+
+func (r *pgScheduledRunRepo) Claim(ctx context.Context, name string, period time.Time) (bool, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO scheduled_runs (name, period) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		name, period,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memSession struct {
+	model.Session
+	revokedAt time.Time
+}
+
+// activeAt mirrors the "revoked_at IS NULL AND expires_at > now" filter.
+func (s memSession) activeAt(now time.Time) bool {
+	return s.revokedAt.IsZero() && s.ExpiresAt.After(now)
+}
+
+type memSessionRepo struct {
+	s *MemoryStore
+}
+
+func NewMemorySessionRepo(s *MemoryStore) SessionRepo {
+	return &memSessionRepo{s: s}
+}
+
+func (r *memSessionRepo) Create(ctx context.Context, s *model.Session) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[s.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	stored := *s
+	stored.Current = false
+	r.s.data.sessions[s.ID] = memSession{Session: stored}
+	return nil
+}
+
+func (r *memSessionRepo) Extend(ctx context.Context, id string, now, expiresAt time.Time) error {
+	defer r.s.lock(ctx)()
+	s, ok := r.s.data.sessions[id]
+	if !ok || !s.activeAt(now) {
+		return apperr.NotFound("session not found")
+	}
+	s.LastUsedAt, s.ExpiresAt = now, expiresAt
+	r.s.data.sessions[id] = s
+	return nil
+}
+
+func (r *memSessionRepo) ListActive(ctx context.Context, userID string, now time.Time) ([]model.Session, error) {
+	defer r.s.lock(ctx)()
+	out := []model.Session{}
+	for _, s := range r.s.data.sessions {
+		if s.UserID == userID && s.activeAt(now) {
+			out = append(out, s.Session)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Session) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return out, nil
+}
+
+func (r *memSessionRepo) Revoke(ctx context.Context, userID, id string, now time.Time) error {
+	defer r.s.lock(ctx)()
+	s, ok := r.s.data.sessions[id]
+	if !ok || s.UserID != userID || !s.activeAt(now) {
+		return apperr.NotFound("session not found")
+	}
+	s.revokedAt = now
+	r.s.data.sessions[id] = s
+	return nil
+}
+
+func (r *memSessionRepo) RevokedIDs(ctx context.Context, now time.Time) ([]string, error) {
+	defer r.s.lock(ctx)()
+	ids := []string{}
+	for id, s := range r.s.data.sessions {
+		if !s.revokedAt.IsZero() && s.ExpiresAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// SessionRepo stores users' sessions. A session is active until it expires
+// or is revoked.
+type SessionRepo interface {
+	Create(ctx context.Context, s *model.Session) error
+	// Extend moves an active session's expiry to expiresAt, marking it used
+	// at now. It returns a NotFound error for a session that isn't active.
+	Extend(ctx context.Context, id string, now, expiresAt time.Time) error
+	// ListActive returns the user's active sessions, newest first.
+	ListActive(ctx context.Context, userID string, now time.Time) ([]model.Session, error)
+	// Revoke ends one of the user's active sessions, returning a NotFound
+	// error if they have no such session.
+	Revoke(ctx context.Context, userID, id string, now time.Time) error
+	// RevokedIDs returns the sessions revoked before they expired whose
+	// tokens are still unexpired.
+	RevokedIDs(ctx context.Context, now time.Time) ([]string, error)
+}
+
+type pgSessionRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepo(db *pgxpool.Pool) SessionRepo {
+	return &pgSessionRepo{db: db}
+}
+
+func (r *pgSessionRepo) Create(ctx context.Context, s *model.Session) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+		s.ID, s.UserID, s.UserAgent, s.IP, s.CreatedAt, s.LastUsedAt, s.ExpiresAt)
+	return err
+}
+
+func (r *pgSessionRepo) Extend(ctx context.Context, id string, now, expiresAt time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE sessions SET last_used_at=$2, expires_at=$3
+		WHERE id=$1 AND revoked_at IS NULL AND expires_at > $2`,
+		id, now, expiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("session not found")
+	}
+	return nil
+}
+
+func (r *pgSessionRepo) ListActive(ctx context.Context, userID string, now time.Time) ([]model.Session, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id=$1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC, id DESC`,
+		userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Session, error) {
+		var s model.Session
+		err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt)
+		return s, err
+	})
+}
+
+func (r *pgSessionRepo) Revoke(ctx context.Context, userID, id string, now time.Time) error {
+	tag, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE sessions SET revoked_at=$3
+		WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL AND expires_at > $3`,
+		id, userID, now)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("session not found")
+	}
+	return nil
+}
+
+func (r *pgSessionRepo) RevokedIDs(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT id::text FROM sessions WHERE revoked_at IS NOT NULL AND expires_at > $1`, now)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Timeouts bound each statement the Postgres repositories send, so a
+// runaway query gives up its connection long before the request that sent
+// it would. Point applies to statements read through QueryRow, which
+// return at most one row, and List to Query and Exec: lists, searches and
+// writes, some of which touch many rows. 0 leaves a statement unbounded.
+type Timeouts struct {
+	Point time.Duration
+	List  time.Duration
+}
+
+var timeouts atomic.Pointer[Timeouts]
+
+// SetTimeouts sets the statement timeouts of every Postgres repository.
+// Until it is called statements are unbounded.
+func SetTimeouts(t Timeouts) {
+	timeouts.Store(&t)
+}
+
+type unboundedKey struct{}
+
+// unbounded marks ctx's statements as exempt from Timeouts. Exports stream
+// rows to the client as they are read, so their queries last as long as
+// the client takes to receive them.
+func unbounded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unboundedKey{}, true)
+}
+
+// timed applies the statement timeouts to db.
+func timed(db dbtx) dbtx {
+	t := timeouts.Load()
+	if t == nil || *t == (Timeouts{}) {
+		return db
+	}
+	return timedConn{dbtx: db, timeouts: *t}
+}
+
+type timedConn struct {
+	dbtx
+	timeouts Timeouts
+}
+
+// withTimeout bounds ctx by d, unless d is 0 or ctx is unbounded.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 || ctx.Value(unboundedKey{}) != nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func (c timedConn) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.List)
+	defer cancel()
+	return c.dbtx.Exec(ctx, sql, args...)
+}
+
+// Query's timeout runs until the rows are closed.
+func (c timedConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.List)
+	rows, err := c.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return rows, err
+	}
+	return &timedRows{Rows: rows, cancel: cancel}, nil
+}
+
+// QueryRow's timeout runs until the row is scanned.
+func (c timedConn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Point)
+	return &timedRow{row: c.dbtx.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+type timedRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timedRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+type timedRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *timedRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
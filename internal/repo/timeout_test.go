@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+// deadlineConn records the context each statement is sent with.
+type deadlineConn struct {
+	dbtx
+	ctx context.Context
+}
+
+func (c *deadlineConn) Exec(ctx context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+	c.ctx = ctx
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *deadlineConn) Query(ctx context.Context, _ string, _ ...any) (pgx.Rows, error) {
+	c.ctx = ctx
+	return fakeRows{}, nil
+}
+
+func (c *deadlineConn) QueryRow(ctx context.Context, _ string, _ ...any) pgx.Row {
+	c.ctx = ctx
+	return fakeRow{}
+}
+
+type fakeRows struct{ pgx.Rows }
+
+func (fakeRows) Close() {}
+
+type fakeRow struct{}
+
+func (fakeRow) Scan(...any) error { return nil }
+
+// remaining is how long ctx has until its deadline, or 0 without one.
+func remaining(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return time.Until(deadline)
+}
+
+func TestTimed_BoundsStatementsByKind(t *testing.T) {
+	t.Cleanup(func() { timeouts.Store(nil) })
+	ctx := context.Background()
+	c := &deadlineConn{}
+
+	require.Same(t, c, timed(c), "unbounded until SetTimeouts")
+
+	SetTimeouts(Timeouts{Point: time.Second, List: time.Minute})
+	db := timed(c)
+
+	require.NoError(t, db.QueryRow(ctx, "SELECT").Scan())
+	require.InDelta(t, time.Second, remaining(c.ctx), float64(100*time.Millisecond))
+	require.Error(t, c.ctx.Err(), "the timeout is released once the row is scanned")
+
+	_, err := db.Exec(ctx, "UPDATE")
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute, remaining(c.ctx), float64(100*time.Millisecond))
+
+	rows, err := db.Query(ctx, "SELECT")
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute, remaining(c.ctx), float64(100*time.Millisecond))
+	require.NoError(t, c.ctx.Err(), "rows are still being read")
+	rows.Close()
+	require.Error(t, c.ctx.Err())
+
+	rows, err = db.Query(unbounded(ctx), "SELECT")
+	require.NoError(t, err)
+	rows.Close()
+	require.Zero(t, remaining(c.ctx), "exports are exempt")
+}
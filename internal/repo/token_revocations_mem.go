@@ -0,0 +1,27 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+type memTokenRevocationRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryTokenRevocationRepo(s *MemoryStore) TokenRevocationRepo {
+	return &memTokenRevocationRepo{s: s}
+}
+
+func (r *memTokenRevocationRepo) Revoke(ctx context.Context, userID string, at time.Time) error {
+	defer r.s.lock(ctx)()
+	if at.After(r.s.data.revocations[userID]) {
+		r.s.data.revocations[userID] = at
+	}
+	return nil
+}
+
+func (r *memTokenRevocationRepo) RevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	defer r.s.lock(ctx)()
+	return r.s.data.revocations[userID], nil
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TokenRevocationRepo records, per user, the time before which every token
+// issued to them is void.
+type TokenRevocationRepo interface {
+	// Revoke voids the user's tokens issued up to at; a later call moves
+	// the cut-off forward.
+	Revoke(ctx context.Context, userID string, at time.Time) error
+	// RevokedAt returns the user's cut-off, or the zero time if none.
+	RevokedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+type pgTokenRevocationRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewTokenRevocationRepo(db *pgxpool.Pool) TokenRevocationRepo {
+	return &pgTokenRevocationRepo{db: db}
+}
+
+func (r *pgTokenRevocationRepo) Revoke(ctx context.Context, userID string, at time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO token_revocations (user_id, revoked_at) VALUES ($1,$2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_at = GREATEST(token_revocations.revoked_at, EXCLUDED.revoked_at)`,
+		userID, at)
+	return err
+}
+
+func (r *pgTokenRevocationRepo) RevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	var at time.Time
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT revoked_at FROM token_revocations WHERE user_id=$1`, userID).Scan(&at)
+	if isNoRows(err) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
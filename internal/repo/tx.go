@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TxManager runs a unit of work in a single database transaction. Repository
+// calls made with the context passed to fn join that transaction; calls made
+// with any other context use the pool as usual.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// dbtx is the query surface shared by *pgxpool.Pool and pgx.Tx.
+type dbtx interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+// conn returns the transaction bound to ctx, or db when there is none,
+// with the statement timeouts applied.
+func conn(ctx context.Context, db *pgxpool.Pool) dbtx {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return timed(tx)
+	}
+	return timed(db)
+}
+
+type pgTxManager struct {
+	db *pgxpool.Pool
+}
+
+func NewTxManager(db *pgxpool.Pool) TxManager {
+	return &pgTxManager{db: db}
+}
+
+// WithinTx commits when fn returns nil and rolls back otherwise. A nested
+// call joins the outer transaction rather than opening a new one.
+func (m *pgTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
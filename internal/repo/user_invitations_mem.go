@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memUserInvitationRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryUserInvitationRepo(s *MemoryStore) UserInvitationRepo {
+	return &memUserInvitationRepo{s: s}
+}
+
+func (r *memUserInvitationRepo) Save(ctx context.Context, inv *model.UserInvitation) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[inv.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	inv.CreatedAt = time.Now().UTC()
+	r.s.data.invitations[inv.UserID] = *inv
+	return nil
+}
+
+func (r *memUserInvitationRepo) Take(ctx context.Context, tokenHash string, now time.Time) (*model.UserInvitation, error) {
+	defer r.s.lock(ctx)()
+	for id, inv := range r.s.data.invitations {
+		if inv.TokenHash != tokenHash {
+			continue
+		}
+		delete(r.s.data.invitations, id)
+		if _, ok := r.s.data.users[id]; !ok || !inv.ExpiresAt.After(now) {
+			break
+		}
+		return &inv, nil
+	}
+	return nil, apperr.NotFound("invitation not found")
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// UserInvitationRepo stores the invitations to choose a password, at most
+// one per user.
+type UserInvitationRepo interface {
+	// Save stores inv, replacing the user's earlier invitation if any, and
+	// sets inv.CreatedAt.
+	Save(ctx context.Context, inv *model.UserInvitation) error
+	// Take removes and returns the invitation with the token hash,
+	// returning a NotFound error when there is none or it expired before
+	// now.
+	Take(ctx context.Context, tokenHash string, now time.Time) (*model.UserInvitation, error)
+}
+
+type pgUserInvitationRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewUserInvitationRepo(db *pgxpool.Pool) UserInvitationRepo {
+	return &pgUserInvitationRepo{db: db}
+}
+
+func (r *pgUserInvitationRepo) Save(ctx context.Context, inv *model.UserInvitation) error {
+	return conn(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO user_invitations (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at, created_at = now()
+		RETURNING created_at`,
+		inv.UserID, inv.TokenHash, inv.ExpiresAt).Scan(&inv.CreatedAt)
+}
+
+func (r *pgUserInvitationRepo) Take(ctx context.Context, tokenHash string, now time.Time) (*model.UserInvitation, error) {
+	var inv model.UserInvitation
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		DELETE FROM user_invitations WHERE token_hash = $1
+		RETURNING user_id::text, token_hash, expires_at, created_at`,
+		tokenHash).Scan(&inv.UserID, &inv.TokenHash, &inv.ExpiresAt, &inv.CreatedAt)
+	if isNoRows(err) || (err == nil && !inv.ExpiresAt.After(now)) {
+		return nil, apperr.NotFound("invitation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
@@ -0,0 +1,250 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+type memUserRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryUserRepo(s *MemoryStore) UserRepo {
+	return &memUserRepo{s: s}
+}
+
+func (r *memUserRepo) Create(ctx context.Context, u *model.User) error {
+	defer r.s.lock(ctx)()
+	if err := r.checkUnique(u.Username, u.Email, ""); err != nil {
+		return err
+	}
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	u.Role = storedRole(u.Role)
+	now := time.Now().UTC()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now
+	}
+	if u.UpdatedAt.IsZero() {
+		u.UpdatedAt = now
+	}
+	u.Status = model.UserStatusActive
+	u.Version = 1
+	r.s.data.users[u.ID] = *u
+	return nil
+}
+
+// checkUnique mirrors the unique constraints on username and email.
+func (r *memUserRepo) checkUnique(username, email, exceptID string) error {
+	for _, u := range r.s.data.users {
+		if u.ID == exceptID {
+			continue
+		}
+		if u.Username == username {
+			return apperr.Conflict("username already exists")
+		}
+		if u.Email == email {
+			return apperr.Conflict("email already exists")
+		}
+	}
+	return nil
+}
+
+func (r *memUserRepo) GetByID(ctx context.Context, id string) (*model.User, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return nil, apperr.NotFound("user not found")
+	}
+	u.Password = ""
+	return &u, nil
+}
+
+func (r *memUserRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.find(ctx, func(u model.User) bool { return u.Username == username })
+}
+
+func (r *memUserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.find(ctx, func(u model.User) bool { return u.Email == email })
+}
+
+func (r *memUserRepo) find(ctx context.Context, match func(model.User) bool) (*model.User, error) {
+	defer r.s.lock(ctx)()
+	for _, u := range r.s.data.users {
+		if match(u) {
+			return &u, nil
+		}
+	}
+	return nil, apperr.NotFound("user not found")
+}
+
+func (r *memUserRepo) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return nil, apperr.NotFound("user not found")
+	}
+	if p.Version != nil && *p.Version != u.Version {
+		return nil, errUserVersionMismatch
+	}
+	if p.Username != nil {
+		u.Username = *p.Username
+	}
+	if p.Email != nil {
+		u.Email = *p.Email
+	}
+	if p.PasswordHash != nil {
+		u.Password = *p.PasswordHash
+	}
+	if p.Role != nil {
+		u.Role = storedRole(*p.Role)
+	}
+	if p.Status != nil {
+		u.Status = *p.Status
+		u.SuspendedUntil = p.SuspendedUntil
+	}
+	if err := r.checkUnique(u.Username, u.Email, id); err != nil {
+		return nil, err
+	}
+	u.UpdatedAt = time.Now().UTC()
+	u.Version++
+	r.s.data.users[id] = u
+	u.Password = ""
+	return &u, nil
+}
+
+func (r *memUserRepo) ReplacePasswordHash(ctx context.Context, id, old, new string) error {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return apperr.NotFound("user not found")
+	}
+	if u.Password == old {
+		u.Password = new
+		r.s.data.users[id] = u
+	}
+	return nil
+}
+
+// Delete also removes the user's bookings, as the foreign key cascades in
+// Postgres.
+func (r *memUserRepo) Delete(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[id]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	delete(r.s.data.users, id)
+	delete(r.s.data.notifyPrefs, id)
+	for bkID, bk := range r.s.data.bookings {
+		if bk.UserID == id {
+			delete(r.s.data.bookings, bkID)
+		}
+	}
+	for sID, s := range r.s.data.sessions {
+		if s.UserID == id {
+			delete(r.s.data.sessions, sID)
+		}
+	}
+	delete(r.s.data.calendarFeeds, id)
+	for lID, l := range r.s.data.logins {
+		if l.UserID == id {
+			delete(r.s.data.logins, lID)
+		}
+	}
+	for key, ident := range r.s.data.identities {
+		if ident.UserID == id {
+			delete(r.s.data.identities, key)
+		}
+	}
+	for keyID, k := range r.s.data.apiKeys {
+		if k.UserID == id {
+			delete(r.s.data.apiKeys, keyID)
+		}
+	}
+	for rvID, rv := range r.s.data.reviews {
+		if rv.UserID == id {
+			delete(r.s.data.reviews, rvID)
+		}
+	}
+	for resID, res := range r.s.data.reservations {
+		if res.UserID == id {
+			delete(r.s.data.reservations, resID)
+		}
+	}
+	deleteReadingLists(&r.s.data, id)
+	return nil
+}
+
+func (r *memUserRepo) Anonymize(ctx context.Context, id string) error {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return apperr.NotFound("user not found")
+	}
+	u.Username = "deleted-" + id
+	u.Email = "deleted-" + id + "@invalid"
+	u.Password = ""
+	u.UpdatedAt = time.Now().UTC()
+	u.Version++
+	r.s.data.users[id] = u
+	delete(r.s.data.notifyPrefs, id)
+	delete(r.s.data.invitations, id)
+	delete(r.s.data.calendarFeeds, id)
+	deleteReadingLists(&r.s.data, id)
+	return nil
+}
+
+// List returns the users of the caller's branch plus the global ones, or
+// everyone outside a branch.
+func (r *memUserRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+	defer r.s.lock(ctx)()
+	branchID := tenant.BranchID(ctx)
+	users := []model.User{}
+	for _, u := range r.s.data.users {
+		if branchID != "" && u.BranchID != "" && u.BranchID != branchID {
+			continue
+		}
+		u.Password = ""
+		users = append(users, u)
+	}
+	return memPage(users, p, func(u model.User) (time.Time, string) { return u.CreatedAt, u.ID })
+}
+
+// CountActiveAdminsForUpdate counts the active admins at every branch. The
+// transaction already holds the store.
+func (r *memUserRepo) CountActiveAdminsForUpdate(ctx context.Context) (int, error) {
+	defer r.s.lock(ctx)()
+	n := 0
+	for _, u := range r.s.data.users {
+		if u.Role == model.RoleAdmin && u.Status == model.UserStatusActive {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memUserRepo) GetNotificationPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+	defer r.s.lock(ctx)()
+	p, ok := r.s.data.notifyPrefs[userID]
+	if !ok {
+		return model.DefaultNotificationPreferences(userID), nil
+	}
+	return p, nil
+}
+
+func (r *memUserRepo) SaveNotificationPreferences(ctx context.Context, p *model.NotificationPreferences) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.users[p.UserID]; !ok {
+		return apperr.NotFound("user not found")
+	}
+	now := time.Now().UTC()
+	p.UpdatedAt = &now
+	r.s.data.notifyPrefs[p.UserID] = *p
+	return nil
+}
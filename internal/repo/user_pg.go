@@ -2,12 +2,11 @@ package repo
 
 import (
     "context"
-    "errors"
     "time"
-	"fmt"
 
     "github.com/google/uuid"
     "github.com/jackc/pgx/v5/pgxpool"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
 )
 
@@ -16,21 +15,67 @@ type UserRepo interface {
     GetByID(ctx context.Context, id string) (*model.User, error)
     GetByUsername(ctx context.Context, username string) (*model.User, error)
     GetByEmail(ctx context.Context, email string) (*model.User, error)
-    Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
+    // Update applies p and bumps the user's version. When p.Version is
+    // set, the write only succeeds if the stored version still equals it.
+    Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
+    // ReplacePasswordHash swaps the user's password hash from old to new
+    // without bumping their version, as a rehash leaves the password as it
+    // was. It does nothing when the hash is no longer old, so a password
+    // changed meanwhile isn't overwritten.
+    ReplacePasswordHash(ctx context.Context, id, old, new string) error
     Delete(ctx context.Context, id string) error
-    List(ctx context.Context, limit, offset int) ([]model.User, error)
+    // Anonymize scrubs a user's personal data and login but keeps the row
+    // for the records that reference it.
+    Anonymize(ctx context.Context, id string) error
+    List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
+    // CountActiveAdminsForUpdate counts the active admins, locking them
+    // until the transaction ends so two requests can't each remove one of
+    // the last two.
+    CountActiveAdminsForUpdate(ctx context.Context) (int, error)
+    // GetNotificationPreferences returns the user's preferences, or the
+    // defaults when they never saved any.
+    GetNotificationPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error)
+    // SaveNotificationPreferences creates or replaces the preferences of
+    // p.UserID.
+    SaveNotificationPreferences(ctx context.Context, p *model.NotificationPreferences) error
+}
+
+// userBranch selects branch_id as text, empty for global users.
+const userBranch = `COALESCE(branch_id::text, '')`
+
+// userColumns are the columns userDest scans, in order. The password hash
+// is only read where it is needed.
+const userColumns = `id, username, email, role, status, suspended_until, created_at, updated_at, version, ` + userBranch
+
+func userDest(u *model.User) []interface{} {
+    return []interface{}{&u.ID, &u.Username, &u.Email, &u.Role, &u.Status, &u.SuspendedUntil, &u.CreatedAt, &u.UpdatedAt, &u.Version, &u.BranchID}
 }
 
 type pgUserRepo struct {
-    db *pgxpool.Pool
+    db      *pgxpool.Pool
+    replica *Replica
 }
 
-func NewUserRepo(db *pgxpool.Pool) UserRepo {
-    return &pgUserRepo{db: db}
+// NewUserRepo returns a UserRepo on db whose listings go to replica while
+// it is healthy; logins always read the primary. replica may be nil.
+func NewUserRepo(db *pgxpool.Pool, replica *Replica) UserRepo {
+    return &pgUserRepo{db: db, replica: replica}
+}
+
+// storedRole is the role written to the database for a role given to
+// Create or Update: normalized, and the user role when empty. Unknown
+// roles are left to the database's check constraint to refuse.
+func storedRole(role model.Role) model.Role {
+    role = model.NormalizeRole(string(role))
+    if role == "" {
+        return model.RoleUser
+    }
+    return role
 }
 
 // Create inserts a new user
 func (r *pgUserRepo) Create(ctx context.Context, u *model.User) error {
+    u.Role = storedRole(u.Role)
     if u.ID == "" {
         u.ID = uuid.New().String()
     }
@@ -41,21 +86,15 @@ func (r *pgUserRepo) Create(ctx context.Context, u *model.User) error {
         u.UpdatedAt = time.Now().UTC()
     }
 
-    err := r.db.QueryRow(ctx,
-        `INSERT INTO users (id, username, email, password_hash, role, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-        RETURNING id, username, email, role, created_at, updated_at`,
-        u.ID, u.Username, u.Email, u.Password, u.Role, u.CreatedAt, u.UpdatedAt,
-    ).Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `INSERT INTO users (id, username, email, password_hash, role, created_at, updated_at, branch_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid)
+        RETURNING `+userColumns,
+        u.ID, u.Username, u.Email, u.Password, u.Role, u.CreatedAt, u.UpdatedAt, u.BranchID,
+    ).Scan(userDest(u)...)
 
     if err != nil {
-        if err.Error() == "duplicate key value violates unique constraint \"users_username_key\"" {
-            return errors.New("username already exists")
-        }
-        if err.Error() == "duplicate key value violates unique constraint \"users_email_key\"" {
-            return errors.New("email already exists")
-        }
-        return err
+        return userWriteError(err)
     }
 
     return nil
@@ -64,13 +103,16 @@ func (r *pgUserRepo) Create(ctx context.Context, u *model.User) error {
 // In GetByID method
 func (r *pgUserRepo) GetByID(ctx context.Context, id string) (*model.User, error) {
     u := &model.User{}
-    err := r.db.QueryRow(ctx,
-        `SELECT id, username, email, role, created_at, updated_at FROM users WHERE id = $1`,
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT `+userColumns+` FROM users WHERE id = $1`,
         id,
-    ).Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+    ).Scan(userDest(u)...)
 
     if err != nil {
-        return nil, errors.New("user not found")
+        if isNoRows(err) {
+            return nil, apperr.NotFound("user not found")
+        }
+        return nil, err
     }
     return u, nil
 }
@@ -78,13 +120,16 @@ func (r *pgUserRepo) GetByID(ctx context.Context, id string) (*model.User, error
 // In GetByUsername method (for login)
 func (r *pgUserRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
     u := &model.User{}
-    err := r.db.QueryRow(ctx,
-        `SELECT id, username, email, password_hash, role, created_at, updated_at FROM users WHERE username = $1`,
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT password_hash, `+userColumns+` FROM users WHERE username = $1`,
         username,
-    ).Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+    ).Scan(append([]interface{}{&u.Password}, userDest(u)...)...)
 
     if err != nil {
-        return nil, errors.New("user not found")
+        if isNoRows(err) {
+            return nil, apperr.NotFound("user not found")
+        }
+        return nil, err
     }
     return u, nil
 }
@@ -92,81 +137,217 @@ func (r *pgUserRepo) GetByUsername(ctx context.Context, username string) (*model
 // GetByEmail retrieves user by email
 func (r *pgUserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
     u := &model.User{}
-    err := r.db.QueryRow(ctx,
-        `SELECT id, username, email, password_hash, role, created_at, updated_at FROM users WHERE email = $1`,
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT password_hash, `+userColumns+` FROM users WHERE email = $1`,
         email,
-    ).Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+    ).Scan(append([]interface{}{&u.Password}, userDest(u)...)...)
 
     if err != nil {
-        return nil, errors.New("user not found")
+        if isNoRows(err) {
+            return nil, apperr.NotFound("user not found")
+        }
+        return nil, err
     }
     return u, nil
 }
 
-// Update updates user information
-func (r *pgUserRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    u := &model.User{}
-    updates["updated_at"] = time.Now().UTC()
+var errUserVersionMismatch = apperr.PreconditionFailed("user was modified by another request. Please refetch and retry.")
 
-    // Build dynamic query
-    query := `UPDATE users SET `
-    args := []interface{}{}
-    i := 1
+// userUpdatable lists the columns Update may set.
+var userUpdatable = []string{"email", "password_hash", "role", "status", "suspended_until", "updated_at", "username"}
 
-    for key, value := range updates {
-        if i > 1 {
-            query += ", "
-        }
-        query += key + " = $" + fmt.Sprintf("%d", i)
-        args = append(args, value)
-        i++
+// userPatchColumns are the column values p sets.
+func userPatchColumns(p model.UpdateUserPatch) map[string]interface{} {
+    cols := map[string]interface{}{"updated_at": time.Now().UTC()}
+    if p.Username != nil {
+        cols["username"] = *p.Username
     }
+    if p.Email != nil {
+        cols["email"] = *p.Email
+    }
+    if p.PasswordHash != nil {
+        cols["password_hash"] = *p.PasswordHash
+    }
+    if p.Role != nil {
+        cols["role"] = storedRole(*p.Role)
+    }
+    if p.Status != nil {
+        cols["status"] = *p.Status
+        cols["suspended_until"] = p.SuspendedUntil
+    }
+    return cols
+}
 
-    query += ` WHERE id = $` + fmt.Sprintf("%d", i)
-    args = append(args, id)
-
-    query += ` RETURNING id, username, email, created_at, updated_at`
-
-    err := r.db.QueryRow(ctx, query, args...).Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+// Update updates user information. The version check and the write are
+// one statement, so no other update can slip in between them.
+func (r *pgUserRepo) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    u := &model.User{}
+    query, args, err := versionedUpdateQuery("users", userUpdatable, userPatchColumns(p), id, p.Version, userColumns)
     if err != nil {
         return nil, err
     }
 
+    err = conn(ctx, r.db).QueryRow(ctx, query, args...).Scan(userDest(u)...)
+    if err != nil {
+        if isNoRows(err) {
+            if p.Version != nil {
+                if _, err := r.GetByID(ctx, id); err != nil {
+                    return nil, err
+                }
+                return nil, errUserVersionMismatch
+            }
+            return nil, apperr.NotFound("user not found")
+        }
+        return nil, userWriteError(err)
+    }
+
     return u, nil
 }
 
+// ReplacePasswordHash updates the hash in place
+func (r *pgUserRepo) ReplacePasswordHash(ctx context.Context, id, old, new string) error {
+    _, err := conn(ctx, r.db).Exec(ctx,
+        `UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2`, id, old, new)
+    return err
+}
+
 // Delete removes a user
 func (r *pgUserRepo) Delete(ctx context.Context, id string) error {
-    cmdTag, err := r.db.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+    cmdTag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
     if err != nil {
         return err
     }
     if cmdTag.RowsAffected() == 0 {
-        return errors.New("user not found")
+        return apperr.NotFound("user not found")
     }
     return nil
 }
 
-// List retrieves all users (paginated)
-func (r *pgUserRepo) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    rows, err := r.db.Query(ctx,
-        `SELECT id, username, email,role, created_at, updated_at FROM users 
-         ORDER BY created_at DESC LIMIT $1 OFFSET $2`,
-        limit, offset,
+// Anonymize replaces the username and email with placeholders derived from
+// the ID and clears the password hash, so the account can't sign in again.
+// The notification preferences, which may hold a personal webhook URL, are
+// dropped, and so is a pending invitation, which would set a password, and
+// the calendar feed, which would keep publishing the loans.
+func (r *pgUserRepo) Anonymize(ctx context.Context, id string) error {
+    cmdTag, err := conn(ctx, r.db).Exec(ctx,
+        `WITH prefs AS (DELETE FROM notification_preferences WHERE user_id = $1),
+            lists AS (DELETE FROM reading_lists WHERE user_id = $1),
+            invitation AS (DELETE FROM user_invitations WHERE user_id = $1),
+            feed AS (DELETE FROM calendar_feeds WHERE user_id = $1)
+        UPDATE users SET username = 'deleted-' || id, email = 'deleted-' || id || '@invalid',
+            password_hash = '', deleted_at = NOW(), updated_at = NOW(), version = version + 1
+        WHERE id = $1`, id)
+    if err != nil {
+        return err
+    }
+    if cmdTag.RowsAffected() == 0 {
+        return apperr.NotFound("user not found")
+    }
+    return nil
+}
+
+// List retrieves all users (paginated). Within a branch that is the
+// branch's own users plus the global ones.
+func (r *pgUserRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    page := model.Page[model.User]{Items: []model.User{}}
+    scope, args := branchScope(ctx, "branch_id", nil)
+    if len(scope) > 0 {
+        scope[0] = "(" + scope[0] + " OR branch_id IS NULL)"
+    }
+    if err := readConn(ctx, r.db, r.replica).QueryRow(ctx, `SELECT COUNT(*) FROM users`+where(scope...), args...).Scan(&page.Total); err != nil {
+        return page, err
+    }
+
+    cond, tail, args, err := pageQuery(p, "created_at", args)
+    if err != nil {
+        return page, err
+    }
+    rows, err := readConn(ctx, r.db, r.replica).Query(ctx,
+        `SELECT `+userColumns+` FROM users`+where(append(scope, cond)...)+tail,
+        args...,
     )
     if err != nil {
-        return nil, err
+        return page, err
     }
     defer rows.Close()
 
-    var users []model.User
     for rows.Next() {
         u := model.User{}
-        if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
-            return nil, err
+        if err := rows.Scan(userDest(&u)...); err != nil {
+            return page, err
         }
-        users = append(users, u)
+        page.Items = append(page.Items, u)
+    }
+    if err := rows.Err(); err != nil {
+        return page, err
+    }
+
+    page.Items, page.NextCursor = trimPage(page.Items, p.Limit, func(u model.User) string {
+        return encodeCursor(u.CreatedAt, u.ID)
+    })
+    return page, nil
+}
+
+// CountActiveAdminsForUpdate counts the active admins at every branch.
+// FOR UPDATE can't lock an aggregate, so the rows are fetched and counted.
+func (r *pgUserRepo) CountActiveAdminsForUpdate(ctx context.Context) (int, error) {
+    rows, err := conn(ctx, r.db).Query(ctx,
+        `SELECT id FROM users WHERE role = 'admin' AND status = 'active' FOR UPDATE`)
+    if err != nil {
+        return 0, err
     }
+    defer rows.Close()
+
+    n := 0
+    for rows.Next() {
+        n++
+    }
+    return n, rows.Err()
+}
 
-    return users, nil
-}
\ No newline at end of file
+func (r *pgUserRepo) GetNotificationPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    p := model.NotificationPreferences{UserID: userID}
+    var updatedAt time.Time
+    err := conn(ctx, r.db).QueryRow(ctx,
+        `SELECT channel, webhook_url, reminder_lead_hours, updated_at FROM notification_preferences WHERE user_id = $1`,
+        userID,
+    ).Scan(&p.Channel, &p.WebhookURL, &p.ReminderLeadHours, &updatedAt)
+    if isNoRows(err) {
+        return model.DefaultNotificationPreferences(userID), nil
+    }
+    if err != nil {
+        return p, err
+    }
+    p.UpdatedAt = &updatedAt
+    return p, nil
+}
+
+func (r *pgUserRepo) SaveNotificationPreferences(ctx context.Context, p *model.NotificationPreferences) error {
+    now := time.Now().UTC()
+    p.UpdatedAt = &now
+    _, err := conn(ctx, r.db).Exec(ctx,
+        `INSERT INTO notification_preferences (user_id, channel, webhook_url, reminder_lead_hours, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE SET channel = EXCLUDED.channel, webhook_url = EXCLUDED.webhook_url,
+            reminder_lead_hours = EXCLUDED.reminder_lead_hours, updated_at = EXCLUDED.updated_at`,
+        p.UserID, p.Channel, p.WebhookURL, p.ReminderLeadHours, now)
+    if foreignKeyViolation(err) {
+        return apperr.NotFound("user not found")
+    }
+    return err
+}
+
+// userWriteError maps unique constraint failures on users to conflict errors.
+func userWriteError(err error) error {
+    constraint, ok := uniqueViolation(err)
+    if !ok {
+        return err
+    }
+    switch constraint {
+    case "users_username_key":
+        return apperr.Conflict("username already exists")
+    case "users_email_key":
+        return apperr.Conflict("email already exists")
+    }
+    return apperr.Conflict("user already exists")
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+type memUserStatsRepo struct {
+	s *MemoryStore
+}
+
+func NewMemoryUserStatsRepo(s *MemoryStore) UserStatsRepo {
+	return &memUserStatsRepo{s: s}
+}
+
+func (r *memUserStatsRepo) UserStats(ctx context.Context, userID string, now, since time.Time, top int) (*model.UserStats, error) {
+	defer r.s.lock(ctx)()
+	s := &model.UserStats{}
+	authors, categories, months := map[string]int{}, map[string]int{}, map[string]int{}
+	for _, bk := range r.s.data.bookings {
+		if bk.UserID != userID {
+			continue
+		}
+		switch bk.Status {
+		case "ACTIVE", "OVERDUE":
+			s.CurrentlyBorrowed++
+			if bk.Status == "OVERDUE" || bk.DueDate.Before(now) {
+				s.Overdue++
+			}
+		case "RETURNED":
+			s.Returned++
+			if bk.ReturnedAt != nil && !bk.ReturnedAt.After(bk.DueDate) {
+				s.ReturnedOnTime++
+			}
+		default:
+			continue
+		}
+		s.TotalBorrowed++
+		if !bk.BorrowedAt.Before(since) {
+			months[bk.BorrowedAt.UTC().Format("2006-01")]++
+		}
+		b, ok := r.s.data.books[bk.BookID]
+		if !ok {
+			b, ok = r.s.data.mergedBooks[bk.BookID]
+		}
+		if !ok {
+			b, ok = r.s.data.deletedBooks[bk.BookID]
+		}
+		if !ok {
+			continue
+		}
+		authors[b.Author]++
+		for _, id := range r.s.data.bookCategories[b.ID] {
+			if c, ok := r.s.data.categories[id]; ok {
+				categories[c.Name]++
+			}
+		}
+	}
+
+	fines := map[string]model.FineTotal{}
+	for _, f := range r.s.data.fines {
+		if f.UserID == userID && f.Status == model.FineUnpaid {
+			t := fines[f.Currency]
+			t.Currency = f.Currency
+			t.AmountCents += f.AmountCents
+			t.Count++
+			fines[f.Currency] = t
+		}
+	}
+	s.UnpaidFines = []model.FineTotal{}
+	for _, t := range fines {
+		s.UnpaidFines = append(s.UnpaidFines, t)
+	}
+	slices.SortFunc(s.UnpaidFines, func(a, b model.FineTotal) int { return strings.Compare(a.Currency, b.Currency) })
+
+	s.TopAuthors = topCounts(authors, top)
+	s.TopCategories = topCounts(categories, top)
+	s.Monthly = []model.MonthCount{}
+	for m, n := range months {
+		s.Monthly = append(s.Monthly, model.MonthCount{Month: m, Count: n})
+	}
+	slices.SortFunc(s.Monthly, func(a, b model.MonthCount) int { return strings.Compare(a.Month, b.Month) })
+	return s, nil
+}
+
+// topCounts returns up to top of counts, most first and then by name.
+func topCounts(counts map[string]int, top int) []model.StatCount {
+	out := []model.StatCount{}
+	for name, n := range counts {
+		out = append(out, model.StatCount{Name: name, Count: n})
+	}
+	slices.SortFunc(out, func(a, b model.StatCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out[:min(len(out), top)]
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// UserStatsRepo aggregates a user's bookings and fines.
+type UserStatsRepo interface {
+	// UserStats sums up the user's loans and unpaid fines as of now, with
+	// their top authors and categories, up to top of each, and the loans
+	// started in each month from since on. Months without loans are left
+	// out of Monthly, and OnTimeRate and GeneratedAt are left for the
+	// caller.
+	UserStats(ctx context.Context, userID string, now, since time.Time, top int) (*model.UserStats, error)
+}
+
+// loanStatuses are the booking statuses of actual loans, as opposed to
+// waitlist offers.
+const loanStatuses = `('ACTIVE', 'OVERDUE', 'RETURNED')`
+
+type pgUserStatsRepo struct {
+	db      *pgxpool.Pool
+	replica *Replica
+}
+
+func NewUserStatsRepo(db *pgxpool.Pool, replica *Replica) UserStatsRepo {
+	return &pgUserStatsRepo{db: db, replica: replica}
+}
+
+func (r *pgUserStatsRepo) UserStats(ctx context.Context, userID string, now, since time.Time, top int) (*model.UserStats, error) {
+	c := readConn(ctx, r.db, r.replica)
+	s := &model.UserStats{}
+	err := c.QueryRow(ctx,
+		`SELECT COUNT(*) FILTER (WHERE status IN `+loanStatuses+`),
+			COUNT(*) FILTER (WHERE status IN ('ACTIVE', 'OVERDUE')),
+			COUNT(*) FILTER (WHERE status = 'OVERDUE' OR (status = 'ACTIVE' AND due_date < $2)),
+			COUNT(*) FILTER (WHERE status = 'RETURNED'),
+			COUNT(*) FILTER (WHERE status = 'RETURNED' AND returned_at <= due_date)
+		FROM bookings WHERE user_id = $1`, userID, now,
+	).Scan(&s.TotalBorrowed, &s.CurrentlyBorrowed, &s.Overdue, &s.Returned, &s.ReturnedOnTime)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.Query(ctx,
+		`SELECT currency, SUM(amount_cents), COUNT(*) FROM fines
+		WHERE user_id = $1 AND status = 'UNPAID' GROUP BY currency ORDER BY currency`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.UnpaidFines, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.FineTotal, error) {
+		var f model.FineTotal
+		err := row.Scan(&f.Currency, &f.AmountCents, &f.Count)
+		return f, err
+	}); err != nil {
+		return nil, err
+	}
+
+	if s.TopAuthors, err = statCounts(ctx, c,
+		`SELECT b.author, COUNT(*) FROM bookings bk JOIN books b ON b.id = bk.book_id
+		WHERE bk.user_id = $1 AND bk.status IN `+loanStatuses+`
+		GROUP BY b.author ORDER BY COUNT(*) DESC, b.author LIMIT $2`, userID, top); err != nil {
+		return nil, err
+	}
+	if s.TopCategories, err = statCounts(ctx, c,
+		`SELECT ct.name, COUNT(*) FROM bookings bk
+		JOIN book_categories bc ON bc.book_id = bk.book_id JOIN categories ct ON ct.id = bc.category_id
+		WHERE bk.user_id = $1 AND bk.status IN `+loanStatuses+`
+		GROUP BY ct.name ORDER BY COUNT(*) DESC, ct.name LIMIT $2`, userID, top); err != nil {
+		return nil, err
+	}
+
+	rows, err = c.Query(ctx,
+		`SELECT to_char(date_trunc('month', borrowed_at AT TIME ZONE 'UTC'), 'YYYY-MM') AS month, COUNT(*)
+		FROM bookings WHERE user_id = $1 AND status IN `+loanStatuses+` AND borrowed_at >= $2
+		GROUP BY month ORDER BY month`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	if s.Monthly, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.MonthCount, error) {
+		var m model.MonthCount
+		err := row.Scan(&m.Month, &m.Count)
+		return m, err
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// statCounts collects rows of a name and a count.
+func statCounts(ctx context.Context, c dbtx, q string, args ...any) ([]model.StatCount, error) {
+	rows, err := c.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.StatCount, error) {
+		var sc model.StatCount
+		err := row.Scan(&sc.Name, &sc.Count)
+		return sc, err
+	})
+}
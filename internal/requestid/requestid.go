@@ -0,0 +1,52 @@
+// Package requestid carries the ID of the request being served, so
+// everything done on its behalf can be correlated with it: log lines,
+// error responses, calls to other services and database sessions.
+package requestid
+
+import (
+	"context"
+	"net/http"
+)
+
+// Header is the HTTP header a request ID travels in, both ways.
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying the request ID id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From returns the request ID carried by ctx, or "" when there is none.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Transport sends the request ID of each outgoing request's context in
+// Header, unless the request already has one, so the services the API
+// calls can log it too.
+type Transport struct {
+	// Base makes the requests; http.DefaultTransport when nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := From(req.Context()); id != "" && req.Header.Get(Header) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(Header, id)
+	}
+	return base.RoundTrip(req)
+}
+
+// Client returns c with its transport wrapped in a Transport.
+func Client(c *http.Client) *http.Client {
+	wrapped := *c
+	wrapped.Transport = &Transport{Base: c.Transport}
+	return &wrapped
+}
@@ -0,0 +1,35 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SendsRequestID(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(Header))
+	}))
+	defer srv.Close()
+	client := Client(srv.Client())
+
+	send := func(ctx context.Context, header string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	send(With(context.Background(), "req-1"), "")
+	send(context.Background(), "")
+	send(With(context.Background(), "req-1"), "caller-set")
+
+	require.Equal(t, []string{"req-1", "", "caller-set"}, got)
+}
@@ -0,0 +1,150 @@
+// Package respond writes the API's JSON response bodies. A response is
+// either the bare value, as the API has always sent, or, in envelope mode,
+// wrapped as {"data": ..., "meta": {"request_id", "pagination"}, "error":
+// ...} so every body has the same shape. Envelope mode is on for a request
+// when the server enables it by default or the client asks for it with an
+// Accept profile (see Middleware).
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/requestid"
+)
+
+// Accept profiles that switch envelope mode on or off for one request, as
+// in Accept: application/json; profile="envelope".
+const (
+	ProfileEnvelope = "envelope"
+	ProfilePlain    = "plain"
+)
+
+// Envelope is the body of every response in envelope mode. Exactly one of
+// Data and Error is set.
+type Envelope struct {
+	Data  any  `json:"data"`
+	Meta  Meta `json:"meta"`
+	Error any  `json:"error"`
+}
+
+// Meta describes the response rather than the resource.
+type Meta struct {
+	RequestID  string      `json:"request_id"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination is what a page of a list says about the rest of it.
+type Pagination struct {
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type envelopeKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return requestid.With(ctx, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	return requestid.From(ctx)
+}
+
+// WithEnvelope returns a copy of ctx whose responses are enveloped or not.
+func WithEnvelope(ctx context.Context, on bool) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, on)
+}
+
+// Enveloped reports whether responses for ctx are enveloped.
+func Enveloped(ctx context.Context) bool {
+	on, _ := ctx.Value(envelopeKey{}).(bool)
+	return on
+}
+
+// Middleware puts each request in envelope mode when byDefault is set,
+// unless its Accept header asks for the plain profile, or when it asks for
+// the envelope profile.
+func Middleware(byDefault bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			on := byDefault
+			switch acceptProfile(r.Header.Get("Accept")) {
+			case ProfileEnvelope:
+				on = true
+			case ProfilePlain:
+				on = false
+			}
+			next.ServeHTTP(w, r.WithContext(WithEnvelope(r.Context(), on)))
+		})
+	}
+}
+
+// acceptProfile returns the profile of the first JSON media type in an
+// Accept header that names one.
+func acceptProfile(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if profile := params["profile"]; profile != "" {
+			return profile
+		}
+	}
+	return ""
+}
+
+// JSON writes v with status, enveloped as data in envelope mode.
+func JSON(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	if !Enveloped(ctx) {
+		write(ctx, w, status, v)
+		return
+	}
+	write(ctx, w, status, Envelope{Data: v, Meta: Meta{RequestID: RequestID(ctx)}})
+}
+
+// Page writes a page of a list with status 200. In envelope mode the
+// items are the data and the total and next cursor go in the meta.
+func Page[T any](ctx context.Context, w http.ResponseWriter, page model.Page[T]) {
+	if !Enveloped(ctx) {
+		write(ctx, w, http.StatusOK, page)
+		return
+	}
+	items := page.Items
+	if items == nil {
+		items = []T{}
+	}
+	write(ctx, w, http.StatusOK, Envelope{
+		Data: items,
+		Meta: Meta{RequestID: RequestID(ctx), Pagination: &Pagination{Total: page.Total, NextCursor: page.NextCursor}},
+	})
+}
+
+// Error writes the error body v with status, enveloped as error in
+// envelope mode.
+func Error(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	if !Enveloped(ctx) {
+		write(ctx, w, status, v)
+		return
+	}
+	write(ctx, w, status, Envelope{Error: v, Meta: Meta{RequestID: RequestID(ctx)}})
+}
+
+func write(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	contentType := "application/json"
+	if Enveloped(ctx) {
+		contentType += `; profile="` + ProfileEnvelope + `"`
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.ErrorContext(ctx, "failed to encode response", "error", err)
+	}
+}
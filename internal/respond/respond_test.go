@@ -0,0 +1,104 @@
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+func TestMiddleware_AcceptProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		byDefault bool
+		accept    string
+		want      bool
+	}{
+		{"off by default", false, "application/json", false},
+		{"on by default", true, "", true},
+		{"opt in", false, `application/json; profile="envelope"`, true},
+		{"opt out", true, `application/json;profile=plain`, false},
+		{"other types ignored", false, `text/html; profile="envelope", */*`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			h := Middleware(tt.byDefault)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = Enveloped(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("Enveloped = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSON_Plain(t *testing.T) {
+	w := httptest.NewRecorder()
+	JSON(context.Background(), w, http.StatusCreated, map[string]string{"id": "b1"})
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if body := w.Body.String(); body != "{\"id\":\"b1\"}\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	ctx := WithEnvelope(WithRequestID(context.Background(), "req-1"), true)
+
+	t.Run("data", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		JSON(ctx, w, http.StatusOK, map[string]string{"id": "b1"})
+
+		if ct := w.Header().Get("Content-Type"); ct != `application/json; profile="envelope"` {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		want := `{"data":{"id":"b1"},"meta":{"request_id":"req-1"},"error":null}`
+		if body := w.Body.String(); body != want+"\n" {
+			t.Errorf("body = %s, want %s", body, want)
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Page(ctx, w, model.Page[string]{Total: 3, NextCursor: "c2"})
+
+		var got struct {
+			Data []string `json:"data"`
+			Meta Meta     `json:"meta"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Data == nil || len(got.Data) != 0 {
+			t.Errorf("data = %v, want []", got.Data)
+		}
+		if p := got.Meta.Pagination; p == nil || p.Total != 3 || p.NextCursor != "c2" {
+			t.Errorf("pagination = %+v", p)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Error(ctx, w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+		want := `{"data":null,"meta":{"request_id":"req-1"},"error":{"error":"Not Found"}}`
+		if body := w.Body.String(); body != want+"\n" {
+			t.Errorf("body = %s, want %s", body, want)
+		}
+	})
+}
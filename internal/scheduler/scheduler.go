@@ -0,0 +1,46 @@
+// Package scheduler runs the API's periodic background jobs.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of periodic work. Run should be safe to repeat and to run on
+// several instances at once, since every instance schedules its own jobs.
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Run runs each job once per interval, in order, until ctx is cancelled. A
+// failing job is logged and retried on the next tick; it doesn't stop the
+// others. Each run gets at most one interval to finish.
+func Run(ctx context.Context, interval time.Duration, logger *slog.Logger, jobs ...Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, job := range jobs {
+				runOnce(ctx, interval, logger, job)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func runOnce(ctx context.Context, timeout time.Duration, logger *slog.Logger, job Job) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		logger.ErrorContext(ctx, "scheduled job failed", "job", job.Name, "error", err)
+		return
+	}
+	logger.DebugContext(ctx, "scheduled job finished", "job", job.Name, "duration", time.Since(start))
+}
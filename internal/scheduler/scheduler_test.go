@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_RepeatsJobsUntilCancelled(t *testing.T) {
+	var ok, failing atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, 5*time.Millisecond, logger.Discard(),
+			Job{Name: "failing", Run: func(context.Context) error {
+				failing.Add(1)
+				return errors.New("boom")
+			}},
+			Job{Name: "ok", Run: func(context.Context) error {
+				ok.Add(1)
+				return nil
+			}},
+		)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return ok.Load() >= 3 }, time.Second, time.Millisecond,
+		"a failing job must not stop the ones after it")
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	require.GreaterOrEqual(t, failing.Load(), int32(3))
+}
@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// FetchJWTKeys loads a JWT key set from AWS Secrets Manager. See ParseJWTKeySet
+// for the accepted secret formats.
+func FetchJWTKeys(ctx context.Context, region, secretID string) (string, map[string]string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if out.SecretString == nil {
+		return "", nil, errors.New("secret has no string value")
+	}
+	return ParseJWTKeySet(*out.SecretString)
+}
+
+// ParseJWTKeySet accepts either a bare secret (one key with ID "default") or
+// JSON of the form {"active": "kid", "keys": {"kid": "secret", ...}}. It
+// returns the active key ID and all keys.
+func ParseJWTKeySet(value string) (string, map[string]string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "{") {
+		if value == "" {
+			return "", nil, errors.New("secret is empty")
+		}
+		return "default", map[string]string{"default": value}, nil
+	}
+
+	var set struct {
+		Active string            `json:"active"`
+		Keys   map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(value), &set); err != nil {
+		return "", nil, fmt.Errorf("parse key set: %w", err)
+	}
+	if _, ok := set.Keys[set.Active]; !ok {
+		return "", nil, fmt.Errorf("active key %q is not in keys", set.Active)
+	}
+	return set.Active, set.Keys, nil
+}
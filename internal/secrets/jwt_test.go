@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJWTKeySet(t *testing.T) {
+	active, keys, err := ParseJWTKeySet("plain-secret")
+	require.NoError(t, err)
+	require.Equal(t, "default", active)
+	require.Equal(t, "plain-secret", keys["default"])
+
+	active, keys, err = ParseJWTKeySet(`{"active":"b","keys":{"a":"secret-a","b":"secret-b"}}`)
+	require.NoError(t, err)
+	require.Equal(t, "b", active)
+	require.Len(t, keys, 2)
+
+	_, _, err = ParseJWTKeySet(`{"active":"c","keys":{"a":"secret-a"}}`)
+	require.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Package seed loads sample data for demos and local development. Fixtures
+// go through the services, so passwords are hashed and every record is
+// validated exactly as it would be when created through the API.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures is the content of a fixture file. JSON files work too, as JSON
+// is valid YAML. Users and books are referred to by username and ISBN,
+// categories by name.
+type Fixtures struct {
+	Categories []model.CategoryRequest `yaml:"categories"`
+	Users      []User                  `yaml:"users"`
+	Books      []Book                  `yaml:"books"`
+	Bookings   []Booking               `yaml:"bookings"`
+}
+
+type User struct {
+	Username string `yaml:"username"`
+	Email    string `yaml:"email"`
+	Password string `yaml:"password"`
+	Admin    bool   `yaml:"admin"`
+}
+
+type Book struct {
+	Title         string   `yaml:"title"`
+	Author        string   `yaml:"author"`
+	PublishedYear int      `yaml:"published_year"`
+	ISBN          string   `yaml:"isbn"`
+	TotalCopies   int      `yaml:"total_copies"`
+	Tags          []string `yaml:"tags"`
+	Categories    []string `yaml:"categories"`
+}
+
+// Booking borrows the book with ISBN Book for User. Returned bookings are
+// returned straight away, leaving them in the user's history.
+type Booking struct {
+	User       string `yaml:"user"`
+	Book       string `yaml:"book"`
+	BorrowDays int    `yaml:"borrow_days"`
+	Returned   bool   `yaml:"returned"`
+}
+
+// Load reads fixtures from a YAML or JSON file. Unknown keys are an error,
+// so a misspelt field isn't silently dropped.
+func Load(path string) (*Fixtures, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixtures: %w", err)
+	}
+	f := &Fixtures{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil {
+		return nil, fmt.Errorf("parse fixtures %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Seeder creates fixtures through the services.
+type Seeder struct {
+	Categories service.CategoryService
+	Users      service.UserService
+	Books      service.BookService
+	Bookings   service.BookingService
+	Logger     *slog.Logger
+}
+
+// Report counts the records a run created.
+type Report struct {
+	Categories int
+	Users      int
+	Books      int
+	Bookings   int
+}
+
+// Run creates the fixtures in order: categories, users, books, then
+// bookings. It stops at the first record that fails, naming it in the
+// error; records created before it are kept. Fixtures are meant for an
+// empty database, so running the same file twice fails on the first
+// duplicate.
+func (s *Seeder) Run(ctx context.Context, f *Fixtures) (Report, error) {
+	var report Report
+
+	categoryIDs := map[string]string{}
+	for _, c := range f.Categories {
+		c.Normalize()
+		created, err := s.Categories.Create(ctx, c)
+		if err != nil {
+			return report, fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		categoryIDs[created.Name] = created.ID
+		report.Categories++
+	}
+
+	userIDs := map[string]string{}
+	for _, u := range f.Users {
+		req := &model.RegisterRequest{Username: u.Username, Email: u.Email, Password: u.Password}
+		req.Normalize()
+		register := s.Users.Register
+		if u.Admin {
+			register = s.Users.RegisterAdmin
+		}
+		created, err := register(ctx, req)
+		if err != nil {
+			return report, fmt.Errorf("user %q: %w", u.Username, err)
+		}
+		userIDs[created.Username] = created.ID
+		report.Users++
+	}
+
+	bookIDs := map[string]string{}
+	for _, b := range f.Books {
+		book := &model.Book{
+			Title:         b.Title,
+			Author:        b.Author,
+			PublishedYear: b.PublishedYear,
+			ISBN:          b.ISBN,
+			TotalCopies:   b.TotalCopies,
+			Tags:          model.NormalizeTags(b.Tags),
+		}
+		if book.TotalCopies == 0 {
+			book.TotalCopies = 1
+		}
+		for _, name := range b.Categories {
+			id, ok := categoryIDs[name]
+			if !ok {
+				return report, fmt.Errorf("book %q: category %q is not in the fixtures", b.ISBN, name)
+			}
+			book.Categories = append(book.Categories, model.Category{ID: id})
+		}
+		if err := s.Books.Create(ctx, "", book); err != nil {
+			return report, fmt.Errorf("book %q: %w", b.ISBN, err)
+		}
+		bookIDs[b.ISBN] = book.ID
+		report.Books++
+	}
+
+	for i, bk := range f.Bookings {
+		userID, ok := userIDs[bk.User]
+		if !ok {
+			return report, fmt.Errorf("booking %d: user %q is not in the fixtures", i+1, bk.User)
+		}
+		bookID, ok := bookIDs[bk.Book]
+		if !ok {
+			return report, fmt.Errorf("booking %d: book %q is not in the fixtures", i+1, bk.Book)
+		}
+		days := bk.BorrowDays
+		if days == 0 {
+			days = 14
+		}
+		receipt, err := s.Bookings.Borrow(ctx, userID, &model.BorrowBookRequest{BookID: bookID, BorrowDays: days})
+		if err != nil {
+			return report, fmt.Errorf("booking %d: %w", i+1, err)
+		}
+		if bk.Returned {
+			if _, err := s.Bookings.Return(ctx, userID, receipt.Booking.ID, false); err != nil {
+				return report, fmt.Errorf("booking %d: return: %w", i+1, err)
+			}
+		}
+		report.Bookings++
+	}
+
+	s.Logger.InfoContext(ctx, "fixtures loaded",
+		"categories", report.Categories, "users", report.Users,
+		"books", report.Books, "bookings", report.Bookings)
+	return report, nil
+}
@@ -0,0 +1,76 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+	"github.com/praveen-anandh-jeyaraman/digicert/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func newSeeder(repos repo.Repos) *Seeder {
+	log := logger.Discard()
+	return &Seeder{
+		Categories: service.NewCategoryService(repos.Categories, log),
+		Users:      service.NewUserService(repos.Users, nil, repos.Revocations, repos.Outbox, service.LockoutPolicy{}, service.DefaultPasswordPolicy(), service.EmailPolicy{}, repos.Tx, log),
+		Books:      service.NewBookService(repos.Books, repos.Audit, repos.Tx, nil, log),
+		Bookings:   service.NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, repos.Reservations, repos.Closures, nil, nil, nil, nil, 48*time.Hour, repos.Tx, log),
+		Logger:     log,
+	}
+}
+
+func TestRun_DemoFixtures(t *testing.T) {
+	fixtures, err := Load(filepath.Join("..", "..", "fixtures", "demo.yaml"))
+	require.NoError(t, err)
+
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	report, err := newSeeder(repos).Run(context.Background(), fixtures)
+	require.NoError(t, err)
+	require.Equal(t, Report{Categories: 3, Users: 3, Books: 4, Bookings: 3}, report)
+
+	ctx := context.Background()
+	admin, err := repos.Users.GetByUsername(ctx, "admin")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleAdmin, admin.Role)
+	require.NotEqual(t, "Library-Admin-2026", admin.Password, "passwords are hashed")
+
+	active, err := repos.Bookings.List(ctx, model.PageRequest{Limit: 10}, model.BookingFilter{Status: "ACTIVE"}, model.BookingExpand{})
+	require.NoError(t, err)
+	require.Equal(t, 2, active.Total)
+
+	books, err := repos.Books.List(ctx, model.PageRequest{Limit: 10}, model.BookFilter{Category: "Classics"})
+	require.NoError(t, err)
+	require.Equal(t, 2, books.Total)
+}
+
+func TestRun_StopsAtInvalidRecord(t *testing.T) {
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	_, err := newSeeder(repos).Run(context.Background(), &Fixtures{
+		Users: []User{{Username: "alice", Email: "alice@example.com", Password: "short"}},
+	})
+	require.ErrorIs(t, err, apperr.ErrValidation)
+	require.ErrorContains(t, err, `user "alice"`)
+}
+
+func TestRun_UnknownReference(t *testing.T) {
+	repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+	_, err := newSeeder(repos).Run(context.Background(), &Fixtures{
+		Books: []Book{{Title: "Dune", Author: "Frank Herbert", ISBN: "1", Categories: []string{"Nope"}}},
+	})
+	require.ErrorContains(t, err, `category "Nope" is not in the fixtures`)
+}
+
+func TestLoad_RejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"users": [{"username": "alice", "pasword": "x"}]}`), 0o600))
+
+	_, err := Load(path)
+	require.ErrorContains(t, err, "pasword")
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// AccountService handles requests users make about their own account data.
+type AccountService interface {
+    // DeleteAccount deletes the user's account at their request. It is
+    // refused while they have books out. A user with booking history is
+    // anonymized rather than removed, so the history stays consistent.
+    // Either way their tokens are revoked and the deletion is audited.
+    DeleteAccount(ctx context.Context, userID string) error
+    // Impersonate issues the admin a short-lived token acting as the user,
+    // for support, and audits it. Admins can't be impersonated, and admins
+    // scoped to a branch can only impersonate users of their branch.
+    Impersonate(ctx context.Context, adminID, userID string) (*model.ImpersonationResponse, error)
+}
+
+type accountService struct {
+    users    repo.UserRepo
+    bookings repo.BookingRepo
+    audit    repo.AuditRepo
+    auth     AuthService
+    tx       repo.TxManager
+    logger   *slog.Logger
+
+    impersonationTTL time.Duration
+}
+
+// NewAccountService returns the service. Impersonation tokens last
+// impersonationTTL.
+func NewAccountService(users repo.UserRepo, bookings repo.BookingRepo, audit repo.AuditRepo, auth AuthService, impersonationTTL time.Duration, tx repo.TxManager, logger *slog.Logger) AccountService {
+    return &accountService{users: users, bookings: bookings, audit: audit, auth: auth, impersonationTTL: impersonationTTL, tx: tx, logger: logger}
+}
+
+func (s *accountService) DeleteAccount(ctx context.Context, userID string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        // Takes the same per-user lock as Borrow, so no loan can start
+        // while the account is going away.
+        outstanding, err := s.bookings.CountOutstandingForUpdate(ctx, userID)
+        if err != nil {
+            return err
+        }
+        if outstanding > 0 {
+            return apperr.Conflict(fmt.Sprintf("return your %d borrowed book(s) before deleting your account", outstanding))
+        }
+
+        history, err := s.bookings.List(ctx, model.PageRequest{Limit: 1}, model.BookingFilter{UserID: userID}, model.BookingExpand{})
+        if err != nil {
+            return err
+        }
+
+        action := model.AuditAccountDeleted
+        if history.Total > 0 {
+            action = model.AuditAccountAnonymized
+            err = s.users.Anonymize(ctx, userID)
+        } else {
+            err = s.users.Delete(ctx, userID)
+        }
+        if err != nil {
+            return err
+        }
+
+        if err := s.auth.RevokeTokens(ctx, userID); err != nil {
+            return err
+        }
+
+        return s.audit.Record(ctx, &model.AuditEntry{
+            ActorID:    userID,
+            Action:     action,
+            TargetType: "user",
+            TargetID:   userID,
+            Details:    map[string]interface{}{"bookings": history.Total},
+        })
+    })
+}
+
+func (s *accountService) Impersonate(ctx context.Context, adminID, userID string) (*model.ImpersonationResponse, error) {
+    if userID == adminID {
+        return nil, apperr.Validation("you can't impersonate yourself")
+    }
+    admin, err := s.users.GetByID(ctx, adminID)
+    if err != nil {
+        return nil, err
+    }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if u.Role == model.RoleAdmin {
+        return nil, apperr.Forbidden("admins can't be impersonated")
+    }
+    if admin.BranchID != "" && u.BranchID != admin.BranchID {
+        return nil, apperr.Forbidden("you can only impersonate users of your branch")
+    }
+
+    token, expiresAt, err := s.auth.Impersonate(u, admin, s.impersonationTTL)
+    if err != nil {
+        return nil, err
+    }
+    err = s.audit.Record(ctx, &model.AuditEntry{
+        ActorID:    adminID,
+        Action:     model.AuditUserImpersonated,
+        TargetType: "user",
+        TargetID:   userID,
+        Details:    map[string]interface{}{"expires_at": expiresAt},
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    s.logger.WarnContext(ctx, "user impersonated", "user_id", userID, "by", adminID, "expires_at", expiresAt)
+    return &model.ImpersonationResponse{
+        Token:     token,
+        ExpiresAt: expiresAt,
+        Banner:    impersonationBanner(u, admin),
+    }, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+type recordingAudit struct {
+    entries []*model.AuditEntry
+}
+
+func (a *recordingAudit) Record(_ context.Context, e *model.AuditEntry) error {
+    a.entries = append(a.entries, e)
+    return nil
+}
+
+func (a *recordingAudit) ListByTarget(context.Context, string, string) ([]model.AuditEntry, error) {
+    return nil, nil
+}
+
+type accountFixture struct {
+    outstanding int
+    history     int
+    calls       []string
+    audit       *recordingAudit
+    svc         AccountService
+}
+
+func newAccountFixture(t *testing.T, outstanding, history int) *accountFixture {
+    f := &accountFixture{outstanding: outstanding, history: history, audit: &recordingAudit{}}
+    users := &mockUserRepo{
+        deleteFn: func(ctx context.Context, id string) error {
+            require.True(t, inTx(ctx))
+            f.calls = append(f.calls, "delete")
+            return nil
+        },
+        anonymizeFn: func(ctx context.Context, id string) error {
+            require.True(t, inTx(ctx))
+            f.calls = append(f.calls, "anonymize")
+            return nil
+        },
+    }
+    bookings := &mockBookingRepoForTest{
+        countOutstandingFn: func(ctx context.Context, userID string) (int, error) {
+            return f.outstanding, nil
+        },
+        listFn: func(ctx context.Context, p model.PageRequest, flt model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            require.Equal(t, "u1", flt.UserID)
+            return model.Page[model.Booking]{Total: f.history}, nil
+        },
+    }
+    auth := &fakeAuthRevoker{revoke: func(ctx context.Context, userID string) error {
+        require.True(t, inTx(ctx))
+        f.calls = append(f.calls, "revoke")
+        return nil
+    }}
+    f.svc = NewAccountService(users, bookings, f.audit, auth, 0, &mockTxManager{}, logger.Discard())
+    return f
+}
+
+// fakeAuthRevoker is an AuthService that only supports revocation.
+type fakeAuthRevoker struct {
+    AuthService
+    revoke func(ctx context.Context, userID string) error
+}
+
+func (f *fakeAuthRevoker) RevokeTokens(ctx context.Context, userID string) error {
+    return f.revoke(ctx, userID)
+}
+
+func TestAccountService_DeleteAccount_RefusedWithBooksOut(t *testing.T) {
+    f := newAccountFixture(t, 2, 5)
+
+    err := f.svc.DeleteAccount(context.Background(), "u1")
+    require.True(t, errors.Is(err, apperr.ErrConflict))
+    require.Empty(t, f.calls)
+    require.Empty(t, f.audit.entries)
+}
+
+func TestAccountService_DeleteAccount_AnonymizesWithHistory(t *testing.T) {
+    f := newAccountFixture(t, 0, 3)
+
+    require.NoError(t, f.svc.DeleteAccount(context.Background(), "u1"))
+    require.Equal(t, []string{"anonymize", "revoke"}, f.calls)
+    require.Len(t, f.audit.entries, 1)
+    require.Equal(t, model.AuditAccountAnonymized, f.audit.entries[0].Action)
+    require.Equal(t, "u1", f.audit.entries[0].TargetID)
+}
+
+func TestAccountService_DeleteAccount_RemovesWithoutHistory(t *testing.T) {
+    f := newAccountFixture(t, 0, 0)
+
+    require.NoError(t, f.svc.DeleteAccount(context.Background(), "u1"))
+    require.Equal(t, []string{"delete", "revoke"}, f.calls)
+    require.Equal(t, model.AuditAccountDeleted, f.audit.entries[0].Action)
+}
+
+func TestAccountService_Impersonate(t *testing.T) {
+    users := map[string]*model.User{
+        "admin":        {ID: "admin", Username: "root", Role: model.RoleAdmin},
+        "branch-admin": {ID: "branch-admin", Username: "desk", Role: model.RoleAdmin, BranchID: "b1"},
+        "other-admin":  {ID: "other-admin", Username: "boss", Role: model.RoleAdmin},
+        "ada":          {ID: "ada", Username: "ada", Role: model.RoleUser},
+    }
+    repo := &mockUserRepo{getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+        u, ok := users[id]
+        if !ok {
+            return nil, apperr.NotFound("user not found")
+        }
+        return u, nil
+    }}
+    auth := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    audit := &recordingAudit{}
+    svc := NewAccountService(repo, nil, audit, auth, 15*time.Minute, &mockTxManager{}, logger.Discard())
+    ctx := context.Background()
+
+    resp, err := svc.Impersonate(ctx, "admin", "ada")
+    require.NoError(t, err)
+    require.Equal(t, "root is signed in as ada for support", resp.Banner)
+    claims, err := auth.ValidateToken(ctx, resp.Token)
+    require.NoError(t, err)
+    require.Equal(t, "ada", claims["user_id"])
+    require.Equal(t, "admin", claims["impersonator_id"])
+    require.Len(t, audit.entries, 1)
+    require.Equal(t, model.AuditUserImpersonated, audit.entries[0].Action)
+    require.Equal(t, "admin", audit.entries[0].ActorID)
+    require.Equal(t, "ada", audit.entries[0].TargetID)
+
+    _, err = svc.Impersonate(ctx, "admin", "other-admin")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    _, err = svc.Impersonate(ctx, "branch-admin", "ada")
+    require.ErrorIs(t, err, apperr.ErrForbidden, "global users are out of a branch admin's reach")
+    _, err = svc.Impersonate(ctx, "admin", "admin")
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.Impersonate(ctx, "admin", "nobody")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    require.Len(t, audit.entries, 1)
+}
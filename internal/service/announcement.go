@@ -0,0 +1,123 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// AnnouncementService manages the notices client apps show, such as
+// planned maintenance or policy changes.
+type AnnouncementService interface {
+    // Active returns the announcements showing now: those for everyone,
+    // and for admins also those for admins only.
+    Active(ctx context.Context, admin bool) ([]model.Announcement, error)
+    List(ctx context.Context) ([]model.Announcement, error)
+    Get(ctx context.Context, id string) (*model.Announcement, error)
+    Create(ctx context.Context, actorID string, req *model.AnnouncementRequest) (*model.Announcement, error)
+    // Update replaces the announcement's text, audience and schedule.
+    Update(ctx context.Context, id string, req *model.AnnouncementRequest) (*model.Announcement, error)
+    Delete(ctx context.Context, id string) error
+}
+
+type announcementService struct {
+    repo   repo.AnnouncementRepo
+    logger *slog.Logger
+}
+
+func NewAnnouncementService(r repo.AnnouncementRepo, logger *slog.Logger) AnnouncementService {
+    return &announcementService{repo: r, logger: logger}
+}
+
+func (s *announcementService) Active(ctx context.Context, admin bool) ([]model.Announcement, error) {
+    audiences := []string{model.AudienceAll}
+    if admin {
+        audiences = append(audiences, model.AudienceAdmins)
+    }
+    return s.repo.Active(ctx, time.Now().UTC(), audiences)
+}
+
+func (s *announcementService) List(ctx context.Context) ([]model.Announcement, error) {
+    return s.repo.List(ctx)
+}
+
+func (s *announcementService) Get(ctx context.Context, id string) (*model.Announcement, error) {
+    if uuid.Validate(id) != nil {
+        return nil, apperr.NotFound("announcement not found")
+    }
+    return s.repo.GetByID(ctx, id)
+}
+
+func (s *announcementService) Create(ctx context.Context, actorID string, req *model.AnnouncementRequest) (*model.Announcement, error) {
+    a, err := announcementFrom(req)
+    if err != nil {
+        return nil, err
+    }
+    a.CreatedBy = actorID
+    if err := s.repo.Create(ctx, a); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "announcement created", "announcement_id", a.ID, "audience", a.Audience,
+        "starts_at", a.StartsAt, "ends_at", a.EndsAt)
+    return a, nil
+}
+
+func (s *announcementService) Update(ctx context.Context, id string, req *model.AnnouncementRequest) (*model.Announcement, error) {
+    if uuid.Validate(id) != nil {
+        return nil, apperr.NotFound("announcement not found")
+    }
+    a, err := announcementFrom(req)
+    if err != nil {
+        return nil, err
+    }
+    a.ID = id
+    if err := s.repo.Update(ctx, a); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "announcement updated", "announcement_id", a.ID, "audience", a.Audience,
+        "starts_at", a.StartsAt, "ends_at", a.EndsAt)
+    return a, nil
+}
+
+func (s *announcementService) Delete(ctx context.Context, id string) error {
+    if uuid.Validate(id) != nil {
+        return apperr.NotFound("announcement not found")
+    }
+    if err := s.repo.Delete(ctx, id); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "announcement deleted", "announcement_id", id)
+    return nil
+}
+
+// announcementFrom checks req's schedule and returns the announcement it
+// describes.
+func announcementFrom(req *model.AnnouncementRequest) (*model.Announcement, error) {
+    if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+        return nil, apperr.Validation("ends_at must be after starts_at")
+    }
+    audience := req.Audience
+    if audience == "" {
+        audience = model.AudienceAll
+    }
+    return &model.Announcement{
+        Title:    req.Title,
+        Body:     req.Body,
+        Audience: audience,
+        StartsAt: utcTime(req.StartsAt),
+        EndsAt:   utcTime(req.EndsAt),
+    }, nil
+}
+
+func utcTime(t *time.Time) *time.Time {
+    if t == nil {
+        return nil
+    }
+    u := t.UTC()
+    return &u
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestAnnouncementService_ActiveFollowsScheduleAndAudience(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewAnnouncementService(repos.Announcements, logger.Discard())
+
+    now := time.Now()
+    hourAgo, inHour := now.Add(-time.Hour), now.Add(time.Hour)
+    create := func(title, audience string, starts, ends *time.Time) *model.Announcement {
+        a, err := svc.Create(ctx, "admin-1", &model.AnnouncementRequest{Title: title, Audience: audience, StartsAt: starts, EndsAt: ends})
+        require.NoError(t, err)
+        return a
+    }
+    open := create("Open", "", nil, nil)
+    require.Equal(t, model.AudienceAll, open.Audience)
+    staff := create("Staff meeting", model.AudienceAdmins, &hourAgo, &inHour)
+    create("Scheduled", model.AudienceAll, &inHour, nil)
+    create("Over", model.AudienceAll, nil, &hourAgo)
+
+    titles := func(admin bool) []string {
+        active, err := svc.Active(ctx, admin)
+        require.NoError(t, err)
+        var out []string
+        for _, a := range active {
+            out = append(out, a.Title)
+        }
+        return out
+    }
+    require.Equal(t, []string{"Open"}, titles(false))
+    require.Equal(t, []string{"Open", "Staff meeting"}, titles(true))
+
+    _, err := svc.Update(ctx, staff.ID, &model.AnnouncementRequest{Title: "Staff meeting", StartsAt: &hourAgo, EndsAt: &inHour})
+    require.NoError(t, err)
+    require.Equal(t, []string{"Open", "Staff meeting"}, titles(false), "an update to audience all didn't show it to everyone")
+
+    _, err = svc.Create(ctx, "admin-1", &model.AnnouncementRequest{Title: "Backwards", StartsAt: &inHour, EndsAt: &hourAgo})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    require.NoError(t, svc.Delete(ctx, open.ID))
+    require.ErrorIs(t, svc.Delete(ctx, open.ID), apperr.ErrNotFound)
+    require.Equal(t, []string{"Staff meeting"}, titles(false))
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "log/slog"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// ErrInvalidAPIKey is returned by Authenticate for a key that is unknown,
+// revoked or expired, or whose user can't currently log in.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+type APIKeyService interface {
+    // Create issues a key on behalf of createdBy, returning it with the
+    // key itself, which is not stored and can't be shown again.
+    Create(ctx context.Context, createdBy string, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error)
+    List(ctx context.Context) ([]model.APIKey, error)
+    Revoke(ctx context.Context, id string) error
+    // Authenticate returns the key and the user it acts as.
+    Authenticate(ctx context.Context, key string) (*model.APIKey, *model.User, error)
+}
+
+type apiKeyService struct {
+    repo   repo.APIKeyRepo
+    users  repo.UserRepo
+    logger *slog.Logger
+}
+
+func NewAPIKeyService(r repo.APIKeyRepo, users repo.UserRepo, logger *slog.Logger) APIKeyService {
+    return &apiKeyService{repo: r, users: users, logger: logger}
+}
+
+// apiKeyPrefix starts every key, so leaked keys are easy to spot.
+const apiKeyPrefix = "lk_"
+
+// apiKeyShownLen is how much of a key is kept to identify it.
+const apiKeyShownLen = len(apiKeyPrefix) + 8
+
+func (s *apiKeyService) Create(ctx context.Context, createdBy string, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error) {
+    if req.Name == "" {
+        return nil, "", apperr.Validation("name is required")
+    }
+    scopes := slices.Compact(slices.Sorted(slices.Values(req.Scopes)))
+    if len(scopes) == 0 {
+        return nil, "", apperr.Validation("at least one scope is required")
+    }
+    for _, sc := range scopes {
+        if !slices.Contains(model.APIKeyScopes, sc) {
+            return nil, "", apperr.Validation("scopes must be " + strings.Join(model.APIKeyScopes, " or "))
+        }
+    }
+    if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+        return nil, "", apperr.Validation("expires_at must be in the future")
+    }
+    userID := req.UserID
+    if userID == "" {
+        userID = createdBy
+    }
+    if uuid.Validate(userID) != nil {
+        return nil, "", apperr.Validation("user_id must be a UUID")
+    }
+
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        return nil, "", err
+    }
+    key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+    k := &model.APIKey{
+        Name:      req.Name,
+        Prefix:    key[:apiKeyShownLen],
+        Hash:      hashToken(key),
+        UserID:    userID,
+        Scopes:    scopes,
+        CreatedBy: createdBy,
+        ExpiresAt: req.ExpiresAt,
+    }
+    if err := s.repo.Create(ctx, k); err != nil {
+        return nil, "", err
+    }
+    s.logger.InfoContext(ctx, "api key issued", "api_key_id", k.ID, "user_id", k.UserID, "scopes", k.Scopes)
+    return k, key, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]model.APIKey, error) {
+    return s.repo.List(ctx)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id string) error {
+    if uuid.Validate(id) != nil {
+        return apperr.NotFound("api key not found")
+    }
+    return s.repo.Revoke(ctx, id, time.Now().UTC())
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*model.APIKey, *model.User, error) {
+    if !strings.HasPrefix(key, apiKeyPrefix) {
+        return nil, nil, ErrInvalidAPIKey
+    }
+    k, err := s.repo.GetByHash(ctx, hashToken(key))
+    if errors.Is(err, apperr.ErrNotFound) {
+        return nil, nil, ErrInvalidAPIKey
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+    now := time.Now()
+    if !k.Active(now) {
+        return nil, nil, ErrInvalidAPIKey
+    }
+
+    u, err := s.users.GetByID(ctx, k.UserID)
+    if errors.Is(err, apperr.ErrNotFound) {
+        return nil, nil, ErrInvalidAPIKey
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+    if u.IsSuspended(now) {
+        s.logger.WarnContext(ctx, "api key refused: account suspended", "api_key_id", k.ID, "user_id", u.ID)
+        return nil, nil, ErrInvalidAPIKey
+    }
+    return k, u, nil
+}
+
+// hashToken returns the SHA-256 of a random token, such as an API key.
+// Tokens carry 256 random bits, so a fast hash is enough and lets a token
+// be looked up by its hash.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
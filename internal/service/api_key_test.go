@@ -0,0 +1,67 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService_IssueAuthenticateRevoke(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewAPIKeyService(repos.APIKeys, repos.Users, logger.Discard())
+    ctx := context.Background()
+    admin := &model.User{Username: "admin", Email: "admin@example.com", Role: "admin"}
+    require.NoError(t, repos.Users.Create(ctx, admin))
+
+    k, key, err := svc.Create(ctx, admin.ID, &model.CreateAPIKeyRequest{Name: "catalog sync", Scopes: []string{"read", "read"}})
+    require.NoError(t, err)
+    require.Equal(t, admin.ID, k.UserID, "a key acts as its issuer by default")
+    require.Equal(t, []string{"read"}, k.Scopes)
+    require.True(t, len(key) > apiKeyShownLen && key[:apiKeyShownLen] == k.Prefix)
+
+    got, u, err := svc.Authenticate(ctx, key)
+    require.NoError(t, err)
+    require.Equal(t, k.ID, got.ID)
+    require.Equal(t, model.RoleAdmin, u.Role)
+
+    _, _, err = svc.Authenticate(ctx, key+"x")
+    require.ErrorIs(t, err, ErrInvalidAPIKey)
+
+    require.NoError(t, svc.Revoke(ctx, k.ID))
+    _, _, err = svc.Authenticate(ctx, key)
+    require.ErrorIs(t, err, ErrInvalidAPIKey)
+    require.ErrorIs(t, svc.Revoke(ctx, k.ID), apperr.ErrNotFound)
+}
+
+func TestAPIKeyService_CreateValidates(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewAPIKeyService(repos.APIKeys, repos.Users, logger.Discard())
+    ctx := context.Background()
+    admin := &model.User{Username: "admin", Email: "admin@example.com", Role: "admin"}
+    require.NoError(t, repos.Users.Create(ctx, admin))
+    past := time.Now().Add(-time.Hour)
+
+    for name, req := range map[string]*model.CreateAPIKeyRequest{
+        "unknown scope": {Name: "k", Scopes: []string{"delete"}},
+        "no scope":      {Name: "k"},
+        "expired":       {Name: "k", Scopes: []string{"write"}, ExpiresAt: &past},
+        "unknown user":  {Name: "k", Scopes: []string{"write"}, UserID: "00000000-0000-0000-0000-00000000ffff"},
+    } {
+        _, _, err := svc.Create(ctx, admin.ID, req)
+        require.ErrorIs(t, err, apperr.ErrValidation, name)
+    }
+}
+
+func TestAPIKey_Allows(t *testing.T) {
+    read := &model.APIKey{Scopes: []string{model.APIKeyScopeRead}}
+    write := &model.APIKey{Scopes: []string{model.APIKeyScopeWrite}}
+    require.True(t, read.Allows("GET"))
+    require.False(t, read.Allows("POST"))
+    require.True(t, write.Allows("DELETE"))
+}
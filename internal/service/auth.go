@@ -1,70 +1,481 @@
 package service
 
 import (
+    "context"
     "errors"
+    "fmt"
     "time"
 
     "github.com/golang-jwt/jwt/v5"
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// Reasons ValidateToken refuses a token. Other errors mean the token
+// couldn't be checked.
+var (
+    ErrTokenInvalid   = errors.New("invalid token")
+    ErrTokenExpired   = errors.New("token expired")
+    ErrTokenRevoked   = errors.New("token revoked")
+    ErrSessionRevoked = errors.New("session revoked")
 )
 
 type AuthService interface {
-    GenerateToken(userID, username, role string) (string, time.Time, error)
-    ValidateToken(token string) (map[string]interface{}, error)
+    // GenerateToken issues a token for the user. branchID scopes it to the
+    // user's branch; it is empty for global users.
+    GenerateToken(userID, username string, role model.Role, branchID string) (string, time.Time, error)
+    ValidateToken(ctx context.Context, token string) (map[string]interface{}, error)
+    // RevokeTokens voids every token issued to the user so far.
+    RevokeTokens(ctx context.Context, userID string) error
+    // Impersonate issues admin a token acting as u for ttl. It carries
+    // who the admin is and a banner for clients to show, can't be
+    // refreshed, and is voided along with the admin's own tokens.
+    Impersonate(u, admin *model.User, ttl time.Duration) (string, time.Time, error)
+
+    // StartSession records a login from userAgent at ip and issues a token
+    // bound to it.
+    StartSession(ctx context.Context, u *model.User, userAgent, ip string) (string, time.Time, error)
+    // RefreshSession issues a new token for the session of the validated
+    // claims, extending it. A token from before sessions starts a new one.
+    RefreshSession(ctx context.Context, claims map[string]interface{}, userAgent, ip string) (string, time.Time, error)
+    // ListSessions returns the user's active sessions, marking currentID.
+    ListSessions(ctx context.Context, userID, currentID string) ([]model.Session, error)
+    // RevokeSession ends one of the user's sessions, voiding its tokens.
+    RevokeSession(ctx context.Context, userID, sessionID string) error
+
+    // StartRefreshableSession is StartSession for clients that keep a
+    // refresh token: the access token lasts the policy's AccessTTL, and the
+    // refresh token, which ValidateToken refuses, as long as the session.
+    StartRefreshableSession(ctx context.Context, u *model.User, userAgent, ip string) (*TokenPair, error)
+    // RefreshWithToken extends the session of refreshToken and issues a new
+    // pair; the refresh token should be replaced by the new one. It fails
+    // like ValidateToken for a refresh token that is no longer good.
+    RefreshWithToken(ctx context.Context, refreshToken, userAgent, ip string) (*TokenPair, error)
+    // EndSession revokes the session of refreshToken. A token that is no
+    // longer good has nothing left to end and is ignored.
+    EndSession(ctx context.Context, refreshToken string) error
+}
+
+// TokenPair is an access token with the refresh token that replaces it.
+type TokenPair struct {
+    AccessToken      string
+    AccessExpiresAt  time.Time
+    RefreshToken     string
+    RefreshExpiresAt time.Time
+}
+
+// tokenUseRefresh marks refresh tokens, which only RefreshWithToken and
+// EndSession accept.
+const tokenUseRefresh = "refresh"
+
+// SigningKey is an HMAC key identified by the kid header of the tokens it signs.
+type SigningKey struct {
+    ID     string
+    Secret []byte
+}
+
+// TokenPolicy says whom tokens are issued by and for, and how far the
+// clocks of the instances issuing and checking them may drift apart.
+type TokenPolicy struct {
+    // Issuer and Audience are put in the iss and aud claims, and a token
+    // must carry them to be accepted. Empty leaves the claim out and
+    // unchecked.
+    Issuer   string
+    Audience string
+    // Leeway is how long past its expiry, or how early before it was
+    // issued, a token is still accepted.
+    Leeway time.Duration
+    // AccessTTL is how long access tokens issued alongside a refresh token
+    // last; zero makes them last as long as the session.
+    AccessTTL time.Duration
 }
 
 type authService struct {
-    secretKey string
-    expiry    time.Duration
+    active      SigningKey
+    keys        map[string][]byte
+    expiry      time.Duration
+    policy      TokenPolicy
+    revocations repo.TokenRevocationRepo
+    sessions    repo.SessionRepo
+    revoked     *revokedSessions
 }
 
-func NewAuthService(secretKey string, expiry time.Duration) AuthService {
-    return &authService{
-        secretKey: secretKey,
-        expiry:    expiry,
+// NewAuthService signs new tokens with keys[0] and accepts tokens signed by
+// any key in keys. Rotating means prepending a new key and dropping the
+// oldest one once every token it signed has expired. Tokens must be signed
+// with HS256 and satisfy policy. revocations may be nil,
+// in which case tokens can't be revoked before they expire.
+//
+// sessions may be nil too, in which case tokens aren't bound to sessions.
+// Otherwise the revoked sessions are cached and reloaded every
+// sessionCacheTTL, so one revoked through another instance is honored
+// within that long.
+func NewAuthService(keys []SigningKey, expiry time.Duration, policy TokenPolicy, revocations repo.TokenRevocationRepo, sessions repo.SessionRepo, sessionCacheTTL time.Duration) AuthService {
+    s := &authService{
+        keys:        make(map[string][]byte, len(keys)),
+        expiry:      expiry,
+        policy:      policy,
+        revocations: revocations,
+        sessions:    sessions,
+    }
+    if sessions != nil {
+        s.revoked = newRevokedSessions(sessions, sessionCacheTTL)
     }
+    if len(keys) > 0 {
+        s.active = keys[0]
+    }
+    for _, k := range keys {
+        s.keys[k.ID] = k.Secret
+    }
+    return s
 }
 
 type Claims struct {
     UserID   string `json:"user_id"`
     Username string `json:"username"`
     Role     string `json:"role"`
+    BranchID string `json:"branch_id,omitempty"`
+    // ImpersonatorID and Impersonator name the admin a token from
+    // Impersonate was issued to, and Banner is the notice clients show
+    // while it is in use.
+    ImpersonatorID string `json:"impersonator_id,omitempty"`
+    Impersonator   string `json:"impersonator,omitempty"`
+    Banner         string `json:"banner,omitempty"`
+    // Use is "refresh" for refresh tokens and empty for access tokens.
+    Use string `json:"use,omitempty"`
     jwt.RegisteredClaims
 }
 
-func (s *authService) GenerateToken(userID, username, role string) (string, time.Time, error) {
-    expiresAt := time.Now().Add(s.expiry)
-    claims := Claims{
+func (s *authService) GenerateToken(userID, username string, role model.Role, branchID string) (string, time.Time, error) {
+    return s.sign(userID, username, role, branchID, "", time.Now().Add(s.expiry))
+}
+
+// sign issues a token expiring at expiresAt. sessionID becomes its jti.
+func (s *authService) sign(userID, username string, role model.Role, branchID, sessionID string, expiresAt time.Time) (string, time.Time, error) {
+    return s.signClaims(Claims{
         UserID:   userID,
         Username: username,
-        Role:     role,
+        Role:     string(role),
+        BranchID: branchID,
         RegisteredClaims: jwt.RegisteredClaims{
+            ID:        sessionID,
             ExpiresAt: jwt.NewNumericDate(expiresAt),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
         },
+    })
+}
+
+func (s *authService) Impersonate(u, admin *model.User, ttl time.Duration) (string, time.Time, error) {
+    return s.signClaims(Claims{
+        UserID:         u.ID,
+        Username:       u.Username,
+        Role:           string(u.Role),
+        BranchID:       u.BranchID,
+        ImpersonatorID: admin.ID,
+        Impersonator:   admin.Username,
+        Banner:         impersonationBanner(u, admin),
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+        },
+    })
+}
+
+// impersonationBanner is the notice clients show while admin is signed in
+// as u.
+func impersonationBanner(u, admin *model.User) string {
+    return fmt.Sprintf("%s is signed in as %s for support", admin.Username, u.Username)
+}
+
+// signClaims signs claims, issued now by and for whom the policy says.
+func (s *authService) signClaims(claims Claims) (string, time.Time, error) {
+    if len(s.active.Secret) == 0 {
+        return "", time.Time{}, errors.New("no signing key configured")
+    }
+    claims.IssuedAt = jwt.NewNumericDate(time.Now())
+    claims.Issuer = s.policy.Issuer
+    if s.policy.Audience != "" {
+        claims.Audience = jwt.ClaimStrings{s.policy.Audience}
     }
 
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    tokenString, err := token.SignedString([]byte(s.secretKey))
+    token.Header["kid"] = s.active.ID
+    tokenString, err := token.SignedString(s.active.Secret)
     if err != nil {
         return "", time.Time{}, err
     }
 
-    return tokenString, expiresAt, nil
+    return tokenString, claims.ExpiresAt.Time, nil
 }
 
-func (s *authService) ValidateToken(tokenString string) (map[string]interface{}, error) {
+func (s *authService) ValidateToken(ctx context.Context, tokenString string) (map[string]interface{}, error) {
+    claims, err := s.parse(ctx, tokenString)
+    if err != nil {
+        return nil, err
+    }
+    if claims.Use != "" {
+        return nil, ErrTokenInvalid
+    }
+
+    var expiresAt time.Time
+    if claims.ExpiresAt != nil {
+        expiresAt = claims.ExpiresAt.Time
+    }
+    return map[string]interface{}{
+        "user_id":         claims.UserID,
+        "username":        claims.Username,
+        // Tokens issued before roles were normalized may carry "ADMIN".
+        "role":            string(model.NormalizeRole(claims.Role)),
+        "branch_id":       claims.BranchID,
+        "session_id":      claims.ID,
+        "expires_at":      expiresAt,
+        "impersonator_id": claims.ImpersonatorID,
+        "impersonator":    claims.Impersonator,
+        "banner":          claims.Banner,
+    }, nil
+}
+
+// parse checks tokenString's signature and claims, and that neither it nor
+// its session has been revoked.
+func (s *authService) parse(ctx context.Context, tokenString string) (*Claims, error) {
     claims := &Claims{}
-    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-        return []byte(s.secretKey), nil
-    })
+    token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFor, s.parserOptions()...)
 
     if err != nil || !token.Valid {
-        return nil, errors.New("invalid token")
+        // The signature is checked before the expiry, so only genuine
+        // tokens are reported as expired.
+        if errors.Is(err, jwt.ErrTokenExpired) {
+            return nil, ErrTokenExpired
+        }
+        return nil, ErrTokenInvalid
     }
 
-    return map[string]interface{}{
-        "user_id":  claims.UserID,
-        "username": claims.Username,
-        "role":     claims.Role,
-    }, nil
-}
\ No newline at end of file
+    if s.revocations != nil {
+        // Revoking the admin's tokens voids their impersonations too.
+        for _, userID := range []string{claims.UserID, claims.ImpersonatorID} {
+            if userID == "" {
+                continue
+            }
+            revokedAt, err := s.revocations.RevokedAt(ctx, userID)
+            if err != nil {
+                return nil, fmt.Errorf("check token revocation: %w", err)
+            }
+            // iat has one-second resolution, so a token from the second of
+            // the revocation is treated as revoked too.
+            if !revokedAt.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(revokedAt.Truncate(time.Second))) {
+                return nil, ErrTokenRevoked
+            }
+        }
+    }
+
+    if s.revoked != nil && claims.ID != "" {
+        revoked, err := s.revoked.contains(ctx, claims.ID)
+        if err != nil {
+            return nil, fmt.Errorf("check session revocation: %w", err)
+        }
+        if revoked {
+            return nil, ErrSessionRevoked
+        }
+    }
+    return claims, nil
+}
+
+func (s *authService) RevokeTokens(ctx context.Context, userID string) error {
+    if s.revocations == nil {
+        return errors.New("token revocation is not configured")
+    }
+    return s.revocations.Revoke(ctx, userID, time.Now().UTC())
+}
+
+func (s *authService) StartSession(ctx context.Context, u *model.User, userAgent, ip string) (string, time.Time, error) {
+    if s.sessions == nil {
+        return s.GenerateToken(u.ID, u.Username, u.Role, u.BranchID)
+    }
+
+    session, err := s.createSession(ctx, u, userAgent, ip, time.Now().UTC())
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return s.sign(u.ID, u.Username, u.Role, u.BranchID, session.ID, session.ExpiresAt)
+}
+
+// createSession records a login by u from userAgent at ip, lasting the
+// token expiry from now.
+func (s *authService) createSession(ctx context.Context, u *model.User, userAgent, ip string, now time.Time) (*model.Session, error) {
+    session := &model.Session{
+        ID:         uuid.New().String(),
+        UserID:     u.ID,
+        UserAgent:  userAgent,
+        IP:         ip,
+        CreatedAt:  now,
+        LastUsedAt: now,
+        ExpiresAt:  now.Add(s.expiry),
+    }
+    if err := s.sessions.Create(ctx, session); err != nil {
+        return nil, fmt.Errorf("create session: %w", err)
+    }
+    return session, nil
+}
+
+func (s *authService) RefreshSession(ctx context.Context, claims map[string]interface{}, userAgent, ip string) (string, time.Time, error) {
+    u := &model.User{}
+    u.ID, _ = claims["user_id"].(string)
+    u.Username, _ = claims["username"].(string)
+    role, _ := claims["role"].(string)
+    u.Role = model.Role(role)
+    u.BranchID, _ = claims["branch_id"].(string)
+    sessionID, _ := claims["session_id"].(string)
+    if impersonatorID, _ := claims["impersonator_id"].(string); impersonatorID != "" {
+        return "", time.Time{}, apperr.Forbidden("impersonation tokens can't be refreshed")
+    }
+
+    if s.sessions == nil {
+        return s.GenerateToken(u.ID, u.Username, u.Role, u.BranchID)
+    }
+    if sessionID == "" {
+        return s.StartSession(ctx, u, userAgent, ip)
+    }
+
+    now := time.Now().UTC()
+    expiresAt := now.Add(s.expiry)
+    if err := s.sessions.Extend(ctx, sessionID, now, expiresAt); err != nil {
+        return "", time.Time{}, fmt.Errorf("extend session: %w", err)
+    }
+    return s.sign(u.ID, u.Username, u.Role, u.BranchID, sessionID, expiresAt)
+}
+
+func (s *authService) StartRefreshableSession(ctx context.Context, u *model.User, userAgent, ip string) (*TokenPair, error) {
+    now := time.Now().UTC()
+    if s.sessions == nil {
+        return s.signPair(u, "", now, now.Add(s.expiry))
+    }
+    session, err := s.createSession(ctx, u, userAgent, ip, now)
+    if err != nil {
+        return nil, err
+    }
+    return s.signPair(u, session.ID, now, session.ExpiresAt)
+}
+
+func (s *authService) RefreshWithToken(ctx context.Context, refreshToken, userAgent, ip string) (*TokenPair, error) {
+    claims, err := s.parse(ctx, refreshToken)
+    if err != nil {
+        return nil, err
+    }
+    if claims.Use != tokenUseRefresh {
+        return nil, ErrTokenInvalid
+    }
+
+    now := time.Now().UTC()
+    expiresAt := now.Add(s.expiry)
+    if s.sessions != nil && claims.ID != "" {
+        if err := s.sessions.Extend(ctx, claims.ID, now, expiresAt); err != nil {
+            return nil, fmt.Errorf("extend session: %w", err)
+        }
+    }
+    u := &model.User{ID: claims.UserID, Username: claims.Username, Role: model.NormalizeRole(claims.Role), BranchID: claims.BranchID}
+    return s.signPair(u, claims.ID, now, expiresAt)
+}
+
+func (s *authService) EndSession(ctx context.Context, refreshToken string) error {
+    claims, err := s.parse(ctx, refreshToken)
+    if errors.Is(err, ErrTokenInvalid) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrSessionRevoked) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if claims.Use != tokenUseRefresh || claims.ID == "" || s.sessions == nil {
+        return nil
+    }
+    return s.RevokeSession(ctx, claims.UserID, claims.ID)
+}
+
+// signPair issues u an access token and a refresh token for the session
+// sessionID, which lasts until expiresAt.
+func (s *authService) signPair(u *model.User, sessionID string, now, expiresAt time.Time) (*TokenPair, error) {
+    accessExpiresAt := expiresAt
+    if s.policy.AccessTTL > 0 && now.Add(s.policy.AccessTTL).Before(expiresAt) {
+        accessExpiresAt = now.Add(s.policy.AccessTTL)
+    }
+    access, _, err := s.sign(u.ID, u.Username, u.Role, u.BranchID, sessionID, accessExpiresAt)
+    if err != nil {
+        return nil, err
+    }
+    refresh, _, err := s.signClaims(Claims{
+        UserID:   u.ID,
+        Username: u.Username,
+        Role:     string(u.Role),
+        BranchID: u.BranchID,
+        Use:      tokenUseRefresh,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ID:        sessionID,
+            ExpiresAt: jwt.NewNumericDate(expiresAt),
+        },
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &TokenPair{AccessToken: access, AccessExpiresAt: accessExpiresAt, RefreshToken: refresh, RefreshExpiresAt: expiresAt}, nil
+}
+
+func (s *authService) ListSessions(ctx context.Context, userID, currentID string) ([]model.Session, error) {
+    if s.sessions == nil {
+        return nil, errors.New("sessions are not configured")
+    }
+    sessions, err := s.sessions.ListActive(ctx, userID, time.Now().UTC())
+    if err != nil {
+        return nil, err
+    }
+    for i := range sessions {
+        sessions[i].Current = sessions[i].ID == currentID
+    }
+    return sessions, nil
+}
+
+func (s *authService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+    if s.sessions == nil {
+        return errors.New("sessions are not configured")
+    }
+    if uuid.Validate(sessionID) != nil {
+        return apperr.NotFound("session not found")
+    }
+    if err := s.sessions.Revoke(ctx, userID, sessionID, time.Now().UTC()); err != nil {
+        return err
+    }
+    s.revoked.add(sessionID)
+    return nil
+}
+
+// parserOptions are the checks ValidateToken makes beyond the signature.
+// Only HS256 is accepted, so neither "none" nor a public-key algorithm can
+// pass a token off as genuine.
+func (s *authService) parserOptions() []jwt.ParserOption {
+    opts := []jwt.ParserOption{
+        jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+        jwt.WithExpirationRequired(),
+        jwt.WithIssuedAt(),
+        jwt.WithLeeway(s.policy.Leeway),
+    }
+    if s.policy.Issuer != "" {
+        opts = append(opts, jwt.WithIssuer(s.policy.Issuer))
+    }
+    if s.policy.Audience != "" {
+        opts = append(opts, jwt.WithAudience(s.policy.Audience))
+    }
+    return opts
+}
+
+// keyFor picks the verification key named by the token's kid header. Tokens
+// issued before kid was introduced carry none and are checked against the
+// active key.
+func (s *authService) keyFor(token *jwt.Token) (interface{}, error) {
+    kid, _ := token.Header["kid"].(string)
+    if kid == "" {
+        return s.active.Secret, nil
+    }
+    secret, ok := s.keys[kid]
+    if !ok {
+        return nil, errors.New("unknown signing key")
+    }
+    return secret, nil
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/stretchr/testify/require"
+)
+
+var (
+    oldKey = SigningKey{ID: "2024-01", Secret: []byte("old-secret-old-secret-old-secret!")}
+    newKey = SigningKey{ID: "2024-06", Secret: []byte("new-secret-new-secret-new-secret!")}
+)
+
+func TestAuthService_TokenCarriesActiveKid(t *testing.T) {
+    svc := NewAuthService([]SigningKey{newKey, oldKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+
+    token, _, err := svc.GenerateToken("user-1", "john", "user", "")
+    require.NoError(t, err)
+
+    parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+    require.NoError(t, err)
+    require.Equal(t, "2024-06", parsed.Header["kid"])
+}
+
+func TestAuthService_AcceptsTokensFromRotatedKey(t *testing.T) {
+    before := NewAuthService([]SigningKey{oldKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    token, _, err := before.GenerateToken("user-1", "john", "user", "")
+    require.NoError(t, err)
+
+    after := NewAuthService([]SigningKey{newKey, oldKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    claims, err := after.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+    require.Equal(t, "user-1", claims["user_id"])
+
+    retired := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    _, err = retired.ValidateToken(context.Background(), token)
+    require.Error(t, err)
+}
+
+func TestAuthService_LegacyTokenWithoutKid(t *testing.T) {
+    legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+        UserID: "user-1",
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+        },
+    })
+    token, err := legacy.SignedString(newKey.Secret)
+    require.NoError(t, err)
+
+    svc := NewAuthService([]SigningKey{newKey, oldKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    _, err = svc.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+}
+
+func TestAuthService_ExpiredToken(t *testing.T) {
+    expired := func(key SigningKey) string {
+        token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+            UserID: "user-1",
+            RegisteredClaims: jwt.RegisteredClaims{
+                ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+            },
+        }).SignedString(key.Secret)
+        require.NoError(t, err)
+        return token
+    }
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+
+    _, err := svc.ValidateToken(context.Background(), expired(newKey))
+    require.ErrorIs(t, err, ErrTokenExpired)
+
+    _, err = svc.ValidateToken(context.Background(), expired(SigningKey{Secret: []byte("forged-secret-forged-secret-forged")}))
+    require.ErrorIs(t, err, ErrTokenInvalid, "only genuine tokens are reported as expired")
+}
+
+func TestAuthService_ChecksIssuerAndAudience(t *testing.T) {
+    policy := TokenPolicy{Issuer: "library-api", Audience: "library-clients"}
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, policy, nil, nil, 0)
+    ctx := context.Background()
+
+    token, _, err := svc.GenerateToken("user-1", "john", "user", "")
+    require.NoError(t, err)
+    parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+    require.NoError(t, err)
+    iss, _ := parsed.Claims.GetIssuer()
+    aud, _ := parsed.Claims.GetAudience()
+    require.Equal(t, "library-api", iss)
+    require.Equal(t, jwt.ClaimStrings{"library-clients"}, aud)
+    _, err = svc.ValidateToken(ctx, token)
+    require.NoError(t, err)
+
+    for name, other := range map[string]TokenPolicy{
+        "no claims":      {},
+        "other issuer":   {Issuer: "someone-else", Audience: "library-clients"},
+        "other audience": {Issuer: "library-api", Audience: "someone-else"},
+    } {
+        token, _, err := NewAuthService([]SigningKey{newKey}, time.Hour, other, nil, nil, 0).GenerateToken("user-1", "john", "user", "")
+        require.NoError(t, err)
+        _, err = svc.ValidateToken(ctx, token)
+        require.ErrorIs(t, err, ErrTokenInvalid, name)
+    }
+}
+
+func TestAuthService_Leeway(t *testing.T) {
+    token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+        UserID: "user-1",
+        RegisteredClaims: jwt.RegisteredClaims{
+            IssuedAt:  jwt.NewNumericDate(time.Now().Add(10 * time.Second)),
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(-10 * time.Second)),
+        },
+    }).SignedString(newKey.Secret)
+    require.NoError(t, err)
+
+    strict := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    _, err = strict.ValidateToken(context.Background(), token)
+    require.Error(t, err)
+
+    lenient := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{Leeway: time.Minute}, nil, nil, 0)
+    _, err = lenient.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+}
+
+func TestAuthService_RefusesOtherAlgorithms(t *testing.T) {
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    claims := Claims{
+        UserID: "user-1",
+        Role:   "admin",
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+        },
+    }
+
+    unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+    require.NoError(t, err)
+    _, err = svc.ValidateToken(context.Background(), unsigned)
+    require.ErrorIs(t, err, ErrTokenInvalid)
+
+    hs512, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(newKey.Secret)
+    require.NoError(t, err)
+    _, err = svc.ValidateToken(context.Background(), hs512)
+    require.ErrorIs(t, err, ErrTokenInvalid)
+
+    noExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "user-1"}).SignedString(newKey.Secret)
+    require.NoError(t, err)
+    _, err = svc.ValidateToken(context.Background(), noExpiry)
+    require.ErrorIs(t, err, ErrTokenInvalid)
+}
+
+func TestAuthService_NormalizesLegacyRole(t *testing.T) {
+    legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+        UserID: "user-1",
+        Role:   "ADMIN",
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+        },
+    })
+    legacy.Header["kid"] = newKey.ID
+    token, err := legacy.SignedString(newKey.Secret)
+    require.NoError(t, err)
+
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+    claims, err := svc.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+    require.Equal(t, string(model.RoleAdmin), claims["role"])
+}
+
+func TestAuthService_TokenCarriesBranch(t *testing.T) {
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, nil, 0)
+
+    token, _, err := svc.GenerateToken("user-1", "john", "user", "branch-1")
+    require.NoError(t, err)
+    claims, err := svc.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+    require.Equal(t, "branch-1", claims["branch_id"])
+
+    token, _, err = svc.GenerateToken("user-2", "jane", "admin", "")
+    require.NoError(t, err)
+    claims, err = svc.ValidateToken(context.Background(), token)
+    require.NoError(t, err)
+    require.Equal(t, "", claims["branch_id"])
+}
+
+type fakeRevocations map[string]time.Time
+
+func (f fakeRevocations) Revoke(_ context.Context, userID string, at time.Time) error {
+    f[userID] = at
+    return nil
+}
+
+func (f fakeRevocations) RevokedAt(_ context.Context, userID string) (time.Time, error) {
+    return f[userID], nil
+}
+
+func TestAuthService_RevokeTokens(t *testing.T) {
+    ctx := context.Background()
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, fakeRevocations{}, nil, 0)
+
+    token, _, err := svc.GenerateToken("user-1", "john", "user", "")
+    require.NoError(t, err)
+    other, _, err := svc.GenerateToken("user-2", "jane", "user", "")
+    require.NoError(t, err)
+
+    require.NoError(t, svc.RevokeTokens(ctx, "user-1"))
+
+    _, err = svc.ValidateToken(ctx, token)
+    require.EqualError(t, err, "token revoked")
+    _, err = svc.ValidateToken(ctx, other)
+    require.NoError(t, err, "other users' tokens stay valid")
+}
+
+func TestAuthService_Impersonate(t *testing.T) {
+    ctx := context.Background()
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, fakeRevocations{}, newFakeSessions(), time.Hour)
+    ada := &model.User{ID: "user-1", Username: "ada", Role: model.RoleUser, BranchID: "branch-1"}
+    root := &model.User{ID: "admin-1", Username: "root", Role: model.RoleAdmin}
+
+    token, expiresAt, err := svc.Impersonate(ada, root, 15*time.Minute)
+    require.NoError(t, err)
+    require.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, time.Minute)
+    claims, err := svc.ValidateToken(ctx, token)
+    require.NoError(t, err)
+    require.Equal(t, "user-1", claims["user_id"])
+    require.Equal(t, "branch-1", claims["branch_id"])
+    require.Equal(t, "admin-1", claims["impersonator_id"])
+    require.Equal(t, "root", claims["impersonator"])
+    require.Equal(t, "root is signed in as ada for support", claims["banner"])
+
+    _, _, err = svc.RefreshSession(ctx, claims, "", "")
+    require.ErrorIs(t, err, apperr.ErrForbidden, "impersonation can't be extended")
+
+    require.NoError(t, svc.RevokeTokens(ctx, "admin-1"))
+    _, err = svc.ValidateToken(ctx, token)
+    require.ErrorIs(t, err, ErrTokenRevoked, "revoking the admin ends their impersonations")
+}
+
+// fakeSessions is a SessionRepo shared by the services of a test, as the
+// sessions table is by instances.
+type fakeSessions struct {
+    sessions map[string]model.Session
+    revoked  map[string]bool
+}
+
+func newFakeSessions() *fakeSessions {
+    return &fakeSessions{sessions: map[string]model.Session{}, revoked: map[string]bool{}}
+}
+
+func (f *fakeSessions) Create(_ context.Context, s *model.Session) error {
+    f.sessions[s.ID] = *s
+    return nil
+}
+
+func (f *fakeSessions) Extend(_ context.Context, id string, now, expiresAt time.Time) error {
+    s, ok := f.sessions[id]
+    if !ok || f.revoked[id] {
+        return apperr.NotFound("session not found")
+    }
+    s.LastUsedAt, s.ExpiresAt = now, expiresAt
+    f.sessions[id] = s
+    return nil
+}
+
+func (f *fakeSessions) ListActive(_ context.Context, userID string, now time.Time) ([]model.Session, error) {
+    out := []model.Session{}
+    for id, s := range f.sessions {
+        if s.UserID == userID && !f.revoked[id] && s.ExpiresAt.After(now) {
+            out = append(out, s)
+        }
+    }
+    return out, nil
+}
+
+func (f *fakeSessions) Revoke(_ context.Context, userID, id string, _ time.Time) error {
+    if s, ok := f.sessions[id]; !ok || s.UserID != userID || f.revoked[id] {
+        return apperr.NotFound("session not found")
+    }
+    f.revoked[id] = true
+    return nil
+}
+
+func (f *fakeSessions) RevokedIDs(context.Context, time.Time) ([]string, error) {
+    ids := []string{}
+    for id := range f.revoked {
+        ids = append(ids, id)
+    }
+    return ids, nil
+}
+
+func TestAuthService_Sessions(t *testing.T) {
+    ctx := context.Background()
+    sessions := newFakeSessions()
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, sessions, time.Hour)
+    user := &model.User{ID: uuid.New().String(), Username: "john", Role: "user"}
+
+    token, _, err := svc.StartSession(ctx, user, "test-browser", "203.0.113.7")
+    require.NoError(t, err)
+    claims, err := svc.ValidateToken(ctx, token)
+    require.NoError(t, err)
+    sessionID := claims["session_id"].(string)
+    require.NotEmpty(t, sessionID)
+
+    other, _, err := svc.StartSession(ctx, user, "test-phone", "198.51.100.2")
+    require.NoError(t, err)
+
+    list, err := svc.ListSessions(ctx, user.ID, sessionID)
+    require.NoError(t, err)
+    require.Len(t, list, 2)
+    for _, s := range list {
+        require.Equal(t, s.ID == sessionID, s.Current)
+    }
+
+    refreshed, _, err := svc.RefreshSession(ctx, claims, "test-browser", "203.0.113.7")
+    require.NoError(t, err)
+    refreshedClaims, err := svc.ValidateToken(ctx, refreshed)
+    require.NoError(t, err)
+    require.Equal(t, sessionID, refreshedClaims["session_id"], "refreshing keeps the session")
+
+    require.ErrorIs(t, svc.RevokeSession(ctx, "someone-else", sessionID), apperr.ErrNotFound)
+    require.NoError(t, svc.RevokeSession(ctx, user.ID, sessionID))
+
+    _, err = svc.ValidateToken(ctx, token)
+    require.EqualError(t, err, "session revoked")
+    _, err = svc.ValidateToken(ctx, refreshed)
+    require.EqualError(t, err, "session revoked")
+    _, err = svc.ValidateToken(ctx, other)
+    require.NoError(t, err, "the user's other sessions stay valid")
+
+    _, _, err = svc.RefreshSession(ctx, claims, "test-browser", "203.0.113.7")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestAuthService_RevokedSessionsCache(t *testing.T) {
+    ctx := context.Background()
+    sessions := newFakeSessions()
+    user := &model.User{ID: uuid.New().String(), Username: "john", Role: "user"}
+
+    // Two instances sharing the sessions table; only one reloads on every
+    // check.
+    revoking := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, sessions, time.Hour)
+    cached := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, sessions, time.Hour)
+    uncached := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, nil, sessions, 0)
+
+    token, _, err := revoking.StartSession(ctx, user, "", "")
+    require.NoError(t, err)
+    claims, err := cached.ValidateToken(ctx, token)
+    require.NoError(t, err)
+
+    require.NoError(t, revoking.RevokeSession(ctx, user.ID, claims["session_id"].(string)))
+
+    _, err = cached.ValidateToken(ctx, token)
+    require.NoError(t, err, "an instance trusts its cache until it expires")
+    _, err = uncached.ValidateToken(ctx, token)
+    require.EqualError(t, err, "session revoked")
+}
+
+func TestAuthService_RefreshTokens(t *testing.T) {
+    ctx := context.Background()
+    svc := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{AccessTTL: time.Minute}, nil, newFakeSessions(), time.Hour)
+    user := &model.User{ID: uuid.New().String(), Username: "john", Role: "user"}
+
+    pair, err := svc.StartRefreshableSession(ctx, user, "test-browser", "203.0.113.7")
+    require.NoError(t, err)
+    require.WithinDuration(t, time.Now().Add(time.Minute), pair.AccessExpiresAt, 5*time.Second)
+    require.WithinDuration(t, time.Now().Add(time.Hour), pair.RefreshExpiresAt, 5*time.Second)
+    claims, err := svc.ValidateToken(ctx, pair.AccessToken)
+    require.NoError(t, err)
+    require.NotEmpty(t, claims["session_id"])
+    _, err = svc.ValidateToken(ctx, pair.RefreshToken)
+    require.ErrorIs(t, err, ErrTokenInvalid, "a refresh token was accepted as an access token")
+
+    _, err = svc.RefreshWithToken(ctx, pair.AccessToken, "", "")
+    require.ErrorIs(t, err, ErrTokenInvalid, "an access token was accepted as a refresh token")
+    next, err := svc.RefreshWithToken(ctx, pair.RefreshToken, "", "")
+    require.NoError(t, err)
+    claims, err = svc.ValidateToken(ctx, next.AccessToken)
+    require.NoError(t, err)
+    require.Equal(t, "john", claims["username"])
+
+    require.NoError(t, svc.EndSession(ctx, next.RefreshToken))
+    _, err = svc.RefreshWithToken(ctx, next.RefreshToken, "", "")
+    require.ErrorIs(t, err, ErrSessionRevoked)
+    _, err = svc.ValidateToken(ctx, next.AccessToken)
+    require.ErrorIs(t, err, ErrSessionRevoked)
+    require.NoError(t, svc.EndSession(ctx, next.RefreshToken), "ending an ended session")
+}
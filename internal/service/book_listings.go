@@ -0,0 +1,56 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+)
+
+// BookListingService serves the landing-page listings. Results are cached
+// per branch and limit, so they may be up to the cache TTL old.
+type BookListingService interface {
+    // Popular ranks books by the loans started within the popularity window.
+    Popular(ctx context.Context, limit int) ([]model.PopularBook, error)
+    // Newest lists the most recently added books.
+    Newest(ctx context.Context, limit int) ([]model.Book, error)
+}
+
+type bookListingService struct {
+    repo    repo.BookRepo
+    window  time.Duration
+    popular *listingCache[[]model.PopularBook]
+    newest  *listingCache[[]model.Book]
+    logger  *slog.Logger
+}
+
+func NewBookListingService(r repo.BookRepo, window, cacheTTL time.Duration, logger *slog.Logger) BookListingService {
+    return &bookListingService{
+        repo:    r,
+        window:  window,
+        popular: newListingCache[[]model.PopularBook](cacheTTL),
+        newest:  newListingCache[[]model.Book](cacheTTL),
+        logger:  logger,
+    }
+}
+
+// listingKey tells apart the listings of each branch and page size.
+func listingKey(ctx context.Context, limit int) string {
+    return fmt.Sprintf("%s|%d", tenant.BranchID(ctx), limit)
+}
+
+func (s *bookListingService) Popular(ctx context.Context, limit int) ([]model.PopularBook, error) {
+    return s.popular.get(ctx, listingKey(ctx, limit), func(ctx context.Context) ([]model.PopularBook, error) {
+        return s.repo.Popular(ctx, time.Now().UTC().Add(-s.window), limit)
+    })
+}
+
+func (s *bookListingService) Newest(ctx context.Context, limit int) ([]model.Book, error) {
+    return s.newest.get(ctx, listingKey(ctx, limit), func(ctx context.Context) ([]model.Book, error) {
+        return s.repo.Newest(ctx, limit)
+    })
+}
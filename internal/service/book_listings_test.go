@@ -0,0 +1,73 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/stretchr/testify/require"
+)
+
+func TestBookListingService_CachesPerBranchAndLimit(t *testing.T) {
+    calls := 0
+    var gotSince time.Time
+    mock := &mockBookRepo{
+        popularFn: func(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error) {
+            calls++
+            gotSince = since
+            return []model.PopularBook{{Book: model.Book{ID: tenant.BranchID(ctx)}, BorrowCount: limit}}, nil
+        },
+    }
+    svc := NewBookListingService(mock, 7*24*time.Hour, time.Hour, logger.Discard())
+    ctx := context.Background()
+
+    books, err := svc.Popular(ctx, 10)
+    require.NoError(t, err)
+    require.Equal(t, 10, books[0].BorrowCount)
+    require.WithinDuration(t, time.Now().Add(-7*24*time.Hour), gotSince, time.Minute)
+
+    _, err = svc.Popular(ctx, 10)
+    require.NoError(t, err)
+    require.Equal(t, 1, calls, "the second call is served from the cache")
+
+    _, err = svc.Popular(ctx, 5)
+    require.NoError(t, err)
+    north, err := svc.Popular(tenant.WithBranch(ctx, "b-north"), 10)
+    require.NoError(t, err)
+    require.Equal(t, "b-north", north[0].ID)
+    require.Equal(t, 3, calls)
+}
+
+func TestBookListingService_ReloadsAfterTTL(t *testing.T) {
+    calls := 0
+    mock := &mockBookRepo{
+        newestFn: func(ctx context.Context, limit int) ([]model.Book, error) {
+            calls++
+            return []model.Book{}, nil
+        },
+    }
+    svc := NewBookListingService(mock, time.Hour, time.Nanosecond, logger.Discard())
+
+    for range 2 {
+        _, err := svc.Newest(context.Background(), 10)
+        require.NoError(t, err)
+    }
+    require.Equal(t, 2, calls)
+}
+
+func TestListingCache_DropsExpiredEntries(t *testing.T) {
+    c := newListingCache[int](time.Millisecond)
+    load := func(context.Context) (int, error) { return 1, nil }
+    for _, key := range []string{"a", "b", "c"} {
+        _, err := c.get(context.Background(), key, load)
+        require.NoError(t, err)
+    }
+    time.Sleep(2 * time.Millisecond)
+
+    _, err := c.get(context.Background(), "d", load)
+    require.NoError(t, err)
+    require.Len(t, c.entries, 1, "only the new key is kept")
+}
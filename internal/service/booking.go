@@ -3,92 +3,442 @@ package service
 import (
     "context"
     "errors"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
     "time"
 
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
 )
 
 type BookingService interface {
-    Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.Booking, error)
-    Return(ctx context.Context, bookingID string) (*model.Booking, error)
-    GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error)
+    // Borrow lends the user a copy of req.BookID, subject to the loan
+    // policy, and returns the receipt for the loan.
+    Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.BorrowBookResponse, error)
+    // BorrowScanned is Borrow for the book identified by a scanned ISBN or
+    // copy barcode, as at a self-checkout kiosk.
+    BorrowScanned(ctx context.Context, userID string, req *model.ScanBorrowRequest) (*model.BorrowBookResponse, error)
+    // Return ends the loan. Only the borrower, userID, may return it
+    // unless asAdmin is set, as for returns taken at the desk.
+    Return(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error)
+    GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
     GetByID(ctx context.Context, id string) (*model.Booking, error)
-    List(ctx context.Context, limit, offset int) ([]model.Booking, error)
+    List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
     UpdateOverdue(ctx context.Context) error
+    // AcceptOffer turns the user's OFFERED booking into a loan of
+    // req.BorrowDays, subject to the loan policy.
+    AcceptOffer(ctx context.Context, userID, bookingID string, req *model.AcceptOfferRequest) (*model.Booking, error)
+    // DeclineOffer gives up the user's OFFERED booking, passing the copy to
+    // the next user on the waitlist.
+    DeclineOffer(ctx context.Context, userID, bookingID string) (*model.Booking, error)
+    // ExpireOffers lapses the offers that weren't accepted in time and
+    // offers every free copy of a waitlisted book to the next user in line.
+    ExpireOffers(ctx context.Context) error
+    // SendDueReminders reminds each borrower whose loan falls due within
+    // their preferred lead time, or lead when they have none, once per loan
+    // and over their preferred channel.
+    SendDueReminders(ctx context.Context, lead time.Duration) error
+    Export(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error
+    // Stream hands fn every booking after afterID ("" for all) in ID order.
+    Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error
 }
 
 type bookingService struct {
-    bookingRepo repo.BookingRepo
-    bookRepo    repo.BookRepo
-    userRepo    repo.UserRepo
+    bookingRepo  repo.BookingRepo
+    bookRepo     repo.BookRepo
+    userRepo     repo.UserRepo
+    policies     repo.LoanPolicyRepo
+    duration     model.LoanDuration
+    reservations repo.ReservationRepo
+    closures     repo.ClosureRepo
+    outbox       repo.OutboxRepo
+    fines        repo.FineRepo
+    finePolicy   FinePolicyService
+    notifier     *notify.Notifier
+    offerHold    time.Duration
+    tx           repo.TxManager
+    logger       *slog.Logger
 }
 
-func NewBookingService(br repo.BookingRepo, bk repo.BookRepo, u repo.UserRepo) BookingService {
+// NewBookingService returns the loan service. Returned copies of a waitlisted
+// book are offered to the next user in line for offerHold. reservations may
+// be nil, in which case there are no waitlists, and so may closures, in which
+// case the library never closes, outbox, in which case no events are
+// published, fines, in which case late returns aren't fined, and notifier,
+// in which case no emails are sent. Late returns are priced by the policy
+// finePolicy holds when they are made. Loans are bounded by duration, or by
+// model.DefaultLoanDuration when it is the zero value.
+func NewBookingService(br repo.BookingRepo, bk repo.BookRepo, u repo.UserRepo, policies repo.LoanPolicyRepo, duration model.LoanDuration, reservations repo.ReservationRepo, closures repo.ClosureRepo, outbox repo.OutboxRepo, fines repo.FineRepo, finePolicy FinePolicyService, notifier *notify.Notifier, offerHold time.Duration, tx repo.TxManager, logger *slog.Logger) BookingService {
+    if duration == (model.LoanDuration{}) {
+        duration = model.DefaultLoanDuration()
+    }
     return &bookingService{
-        bookingRepo: br,
-        bookRepo:    bk,
-        userRepo:    u,
+        bookingRepo:  br,
+        bookRepo:     bk,
+        userRepo:     u,
+        policies:     policies,
+        duration:     duration,
+        reservations: reservations,
+        closures:     closures,
+        outbox:       outbox,
+        fines:        fines,
+        finePolicy:   finePolicy,
+        notifier:     notifier,
+        offerHold:    offerHold,
+        tx:           tx,
+        logger:       logger,
     }
 }
 
-func (s *bookingService) Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.Booking, error) {
-    _, err := s.userRepo.GetByID(ctx, userID)
-    if err != nil {
-        return nil, errors.New("user not found")
+// Borrow runs in one transaction holding a lock on the book row, so two
+// concurrent borrows of the same book cannot both pass the active-booking and
+// availability checks. Free copies of a book with a waitlist are kept for the
+// users on it. Nothing can be borrowed while the book's branch is closed, and
+// a due date that falls on a closed day moves to the next open one. The loan
+// policy for the user's role and any restriction on the book are enforced
+// last.
+func (s *bookingService) Borrow(ctx context.Context, userID string, req *model.BorrowBookRequest) (*model.BorrowBookResponse, error) {
+    loc, err := time.LoadLocation(req.TimeZone)
+    if err != nil || strings.EqualFold(req.TimeZone, "local") {
+        return nil, apperr.Validation("time_zone must be an IANA time zone such as Europe/London")
     }
 
-    _, err = s.bookRepo.GetByID(ctx, req.BookID)
+    var receipt *model.BorrowBookResponse
+    err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        user, err := s.userRepo.GetByID(ctx, userID)
+        if err != nil {
+            return err
+        }
+        if user.IsSuspended(time.Now()) {
+            return apperr.Forbidden("your account is suspended")
+        }
+
+        book, err := s.bookRepo.GetByIDForUpdate(ctx, req.BookID)
+        if err != nil {
+            return err
+        }
+        now := time.Now().UTC()
+        if err := s.ensureOpen(ctx, book.BranchID, now); err != nil {
+            return err
+        }
+
+        _, err = s.bookingRepo.GetActive(ctx, userID, req.BookID)
+        if err == nil {
+            return apperr.Conflict("you already have an active booking for this book")
+        }
+        if !errors.Is(err, apperr.ErrNotFound) {
+            return err
+        }
+
+        if !book.Available {
+            return apperr.Conflict("no copies of this book are currently available")
+        }
+        if s.reservations != nil {
+            waiting, err := s.reservations.CountWaiting(ctx, book.ID)
+            if err != nil {
+                return err
+            }
+            if waiting > 0 {
+                return apperr.Conflict("the available copies of this book are held for its waitlist")
+            }
+        }
+
+        terms, err := s.checkLoanPolicy(ctx, user, book.ID, req.BorrowDays)
+        if err != nil {
+            return err
+        }
+        due, err := s.dueDate(ctx, book.BranchID, now, terms.days)
+        if err != nil {
+            return err
+        }
+
+        booking := &model.Booking{
+            UserID:     userID,
+            BookID:     req.BookID,
+            BorrowedAt: now,
+            DueDate:    due,
+            Status:     "ACTIVE",
+        }
+        if err := s.bookingRepo.Create(ctx, booking); err != nil {
+            return err
+        }
+        if err := recordEvent(ctx, s.outbox, model.EventBookingCreated, booking.ID, booking); err != nil {
+            return err
+        }
+
+        // Read the book again so the receipt counts this loan.
+        lent, err := s.bookRepo.GetByID(ctx, book.ID)
+        if err != nil {
+            return err
+        }
+        booking.Book = &lent
+        receipt = terms.receipt(booking, loc)
+        return nil
+    })
     if err != nil {
-        return nil, errors.New("book not found")
+        return nil, err
     }
 
-    active, _ := s.bookingRepo.GetActive(ctx, userID, req.BookID)
-    if active != nil {
-        return nil, errors.New("you already have an active booking for this book")
+    return receipt, nil
+}
+
+// loanTerms are what checkLoanPolicy checked a loan against.
+type loanTerms struct {
+    policy      model.LoanPolicy
+    days        int // the length of the loan
+    maxDays     int
+    outstanding int // the borrower's loans out before this one
+}
+
+// receipt describes booking, a loan made on t, with its due date in loc.
+func (t loanTerms) receipt(booking *model.Booking, loc *time.Location) *model.BorrowBookResponse {
+    due := booking.DueDate.In(loc)
+    r := &model.BorrowBookResponse{
+        Booking:          booking,
+        Message:          "Book borrowed; due back by " + due.Format("Mon, 02 Jan 2006 15:04 MST"),
+        DueDateLocal:     due,
+        TimeZone:         loc.String(),
+        Policy:           t.policy,
+        MaxBorrowDays:    t.maxDays,
+        LoansOutstanding: t.outstanding + 1,
     }
+    if t.policy.MaxActiveBookings > 0 {
+        remaining := max(t.policy.MaxActiveBookings-r.LoansOutstanding, 0)
+        r.LoansRemaining = &remaining
+    }
+    return r
+}
 
-    if req.BorrowDays < 1 || req.BorrowDays > 30 {
-        return nil, errors.New("borrow days must be between 1 and 30")
+// ensureOpen returns a PolicyViolation when the branch is closed on the day
+// of now.
+func (s *bookingService) ensureOpen(ctx context.Context, branchID string, now time.Time) error {
+    if s.closures == nil {
+        return nil
+    }
+    closures, err := s.closures.ForBranch(ctx, branchID, now, now)
+    if err != nil {
+        return err
+    }
+    if len(closures) == 0 {
+        return nil
+    }
+    msg := "the library is closed on " + now.Format("2006-01-02")
+    if closures[0].Reason != "" {
+        msg += " (" + closures[0].Reason + ")"
     }
+    return apperr.PolicyViolation(msg)
+}
+
+// dueDate returns the time borrowDays after now, moved past any closure of
+// the branch it falls in.
+func (s *bookingService) dueDate(ctx context.Context, branchID string, now time.Time, borrowDays int) (time.Time, error) {
+    return afterClosures(ctx, s.closures, branchID, now.AddDate(0, 0, borrowDays))
+}
 
-    booking := &model.Booking{
-        UserID:     userID,
-        BookID:     req.BookID,
-        BorrowedAt: time.Now().UTC(),
-        DueDate:    time.Now().UTC().AddDate(0, 0, req.BorrowDays),
-        Status:     "ACTIVE",
+// afterClosures moves due to the same time on the day after any closure of
+// the branch it falls in. closures may be nil.
+func afterClosures(ctx context.Context, closures repo.ClosureRepo, branchID string, due time.Time) (time.Time, error) {
+    if closures == nil {
+        return due, nil
+    }
+    found, err := closures.ForBranch(ctx, branchID, due, due.AddDate(1, 0, 0))
+    if err != nil {
+        return time.Time{}, err
     }
+    // Closures come by start, so one pass also steps over back-to-back ones.
+    for _, c := range found {
+        if c.Covers(due) {
+            due = c.EndsOn.AddDate(0, 0, 1).Add(due.Sub(model.Day(due)))
+        }
+    }
+    return due, nil
+}
 
-    if err := s.bookingRepo.Create(ctx, booking); err != nil {
-        return nil, err
+// checkLoanPolicy returns a PolicyViolation naming the first limit that a
+// loan of borrowDays would break, or the terms the loan meets. Roles
+// without a stored policy get model.DefaultLoanPolicy. borrowDays must be
+// within the configured loan duration; 0 asks for its default, shortened
+// to the longest loan the policy and book allow.
+func (s *bookingService) checkLoanPolicy(ctx context.Context, user *model.User, bookID string, borrowDays int) (loanTerms, error) {
+    if borrowDays != 0 && (borrowDays < s.duration.MinDays || borrowDays > s.duration.MaxDays) {
+        return loanTerms{}, apperr.Validation(fmt.Sprintf("borrow days must be between %d and %d", s.duration.MinDays, s.duration.MaxDays))
+    }
+    policy, err := s.policies.GetPolicy(ctx, user.Role)
+    if errors.Is(err, apperr.ErrNotFound) {
+        policy = model.DefaultLoanPolicy(user.Role)
+    } else if err != nil {
+        return loanTerms{}, err
     }
+    terms := loanTerms{policy: policy, maxDays: min(policy.MaxBorrowDays, s.duration.MaxDays)}
 
-    return booking, nil
+    restriction, err := s.policies.GetBookRestriction(ctx, bookID)
+    if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+        return loanTerms{}, err
+    }
+    if restriction.ReferenceOnly {
+        return loanTerms{}, apperr.PolicyViolation("this book is reference-only and cannot be borrowed")
+    }
+    if restriction.MaxBorrowDays > 0 && restriction.MaxBorrowDays < terms.maxDays {
+        terms.maxDays = restriction.MaxBorrowDays
+    }
+    terms.days = borrowDays
+    if borrowDays == 0 {
+        terms.days = min(s.duration.DefaultDays, terms.maxDays)
+    }
+    if restriction.MaxBorrowDays > 0 && restriction.MaxBorrowDays < policy.MaxBorrowDays && terms.days > restriction.MaxBorrowDays {
+        return loanTerms{}, apperr.PolicyViolation(fmt.Sprintf("this book can be borrowed for at most %d days", restriction.MaxBorrowDays))
+    }
+    if terms.days > policy.MaxBorrowDays {
+        return loanTerms{}, apperr.PolicyViolation(fmt.Sprintf("borrow days exceed the %d-day limit for the %s role", policy.MaxBorrowDays, user.Role))
+    }
+
+    terms.outstanding, err = s.bookingRepo.CountOutstandingForUpdate(ctx, user.ID)
+    if err != nil {
+        return loanTerms{}, err
+    }
+    if policy.MaxActiveBookings > 0 && terms.outstanding >= policy.MaxActiveBookings {
+        return loanTerms{}, apperr.PolicyViolation(fmt.Sprintf("you already have %d books on loan, the limit for the %s role", terms.outstanding, user.Role))
+    }
+    return terms, nil
 }
 
-func (s *bookingService) Return(ctx context.Context, bookingID string) (*model.Booking, error) {
-    booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+// BorrowScanned borrows the book a scanned label or ISBN stands for, as
+// Borrow does.
+func (s *bookingService) BorrowScanned(ctx context.Context, userID string, req *model.ScanBorrowRequest) (*model.BorrowBookResponse, error) {
+    bookID, err := s.resolveScan(ctx, req.Code)
     if err != nil {
-        return nil, errors.New("booking not found")
+        return nil, err
     }
+    return s.Borrow(ctx, userID, &model.BorrowBookRequest{BookID: bookID, BorrowDays: req.BorrowDays, TimeZone: req.TimeZone})
+}
 
-    if booking.Status == "RETURNED" {
-        return nil, errors.New("book already returned")
+// resolveScan returns the ID of the book a scanned code stands for: a book
+// label, a copy label, or else an ISBN. Of several books with the ISBN it
+// picks the first with a copy free, leaving Borrow to refuse when none has.
+func (s *bookingService) resolveScan(ctx context.Context, code string) (string, error) {
+    if bookID, n, ok := model.ParseCopyCode(code); ok && uuid.Validate(bookID) == nil {
+        book, err := s.bookRepo.GetByID(ctx, bookID)
+        if err != nil {
+            return "", err
+        }
+        if n > book.TotalCopies {
+            return "", apperr.NotFound("copy not found")
+        }
+        return book.ID, nil
+    }
+    if uuid.Validate(code) == nil {
+        return code, nil
     }
 
-    now := time.Now().UTC()
-    updates := map[string]interface{}{
-        "returned_at": now,
-        "status":      "RETURNED",
+    books, err := s.bookRepo.FindByISBN(ctx, code)
+    if err != nil {
+        return "", err
+    }
+    if len(books) == 0 {
+        return "", apperr.NotFound("no book has that isbn or barcode")
     }
+    for _, b := range books {
+        if b.Available {
+            return b.ID, nil
+        }
+    }
+    return books[0].ID, nil
+}
 
-    return s.bookingRepo.Update(ctx, bookingID, updates)
+// Return locks the book before the booking, the same order Borrow takes, so
+// a return racing a borrow of the same book cannot deadlock, and a booking
+// can only be returned once, and not while the branch is closed. The
+// returned copy is offered to the next user on the book's waitlist in the
+// same transaction, and they are told once it commits.
+func (s *bookingService) Return(ctx context.Context, userID, bookingID string, asAdmin bool) (*model.Booking, error) {
+    var updated *model.Booking
+    var offers []model.Booking
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+        if err != nil {
+            return err
+        }
+        if !asAdmin && booking.UserID != userID {
+            return apperr.Forbidden("this booking belongs to another user")
+        }
+        if _, err := s.bookRepo.GetByIDForUpdate(ctx, booking.BookID); err != nil {
+            return err
+        }
+        booking, err = s.bookingRepo.GetByIDForUpdate(ctx, bookingID)
+        if err != nil {
+            return err
+        }
+
+        if booking.Status == "RETURNED" {
+            return apperr.Conflict("book already returned")
+        }
+        if booking.Status != "ACTIVE" && booking.Status != "OVERDUE" {
+            return apperr.Conflict("only books on loan can be returned")
+        }
+
+        now := time.Now().UTC()
+        if err := s.ensureOpen(ctx, booking.BranchID, now); err != nil {
+            return err
+        }
+        updated, err = s.bookingRepo.Update(ctx, bookingID, model.UpdateBookingPatch{
+            Status:     model.Ptr("RETURNED"),
+            ReturnedAt: &now,
+        })
+        if err != nil {
+            return err
+        }
+        if err := recordEvent(ctx, s.outbox, model.EventBookingReturned, updated.ID, updated); err != nil {
+            return err
+        }
+        if err := s.fineLateReturn(ctx, updated); err != nil {
+            return err
+        }
+        offers, err = s.offerFreeCopies(ctx, booking.BookID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.notifyOffers(ctx, offers)
+
+    return updated, nil
 }
 
-// GetByUser retrieves user's bookings
-func (s *bookingService) GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-    return s.bookingRepo.GetByUser(ctx, userID, limit, offset)
+// fineLateReturn records the fine for b when it came back after its due
+// date and the fine policy charges for it.
+func (s *bookingService) fineLateReturn(ctx context.Context, b *model.Booking) error {
+    if s.fines == nil || b.ReturnedAt == nil || !b.ReturnedAt.After(b.DueDate) {
+        return nil
+    }
+    policy, err := s.finePolicy.Get(ctx)
+    if err != nil {
+        return err
+    }
+    days := daysLate(b.DueDate, *b.ReturnedAt)
+    amount := policy.Fine(days)
+    if amount <= 0 {
+        return nil
+    }
+    fine := &model.Fine{UserID: b.UserID, BookingID: b.ID, DaysLate: days, AmountCents: amount, Currency: policy.Currency}
+    if err := s.fines.Create(ctx, fine); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "late return fined", "booking_id", b.ID, "fine_id", fine.ID, "amount_cents", amount)
+    return nil
+}
+
+// GetByUser retrieves user's bookings matching f
+func (s *bookingService) GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    f.UserID = ""
+    if err := validateBookingFilter(f); err != nil {
+        return model.Page[model.Booking]{}, err
+    }
+    return s.bookingRepo.GetByUser(ctx, userID, p, f, expand)
 }
 
 // GetByID retrieves booking by ID
@@ -96,12 +446,358 @@ func (s *bookingService) GetByID(ctx context.Context, id string) (*model.Booking
     return s.bookingRepo.GetByID(ctx, id)
 }
 
-// List retrieves all bookings
-func (s *bookingService) List(ctx context.Context, limit, offset int) ([]model.Booking, error) {
-    return s.bookingRepo.List(ctx, limit, offset)
+// List retrieves all bookings matching f
+func (s *bookingService) List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    if err := validateBookingFilter(f); err != nil {
+        return model.Page[model.Booking]{}, err
+    }
+    return s.bookingRepo.List(ctx, p, f, expand)
+}
+
+// validateBookingFilter rejects filter values that could never match, so
+// typos surface as 400s instead of empty pages.
+func validateBookingFilter(f model.BookingFilter) error {
+    if f.Status != "" && !slices.Contains(model.BookingStatuses, f.Status) {
+        return apperr.Validation("status must be one of: " + strings.Join(model.BookingStatuses, ", "))
+    }
+    if f.UserID != "" && uuid.Validate(f.UserID) != nil {
+        return apperr.Validation("user_id must be a valid ID")
+    }
+    if f.BookID != "" && uuid.Validate(f.BookID) != nil {
+        return apperr.Validation("book_id must be a valid ID")
+    }
+    if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+        return apperr.Validation("from must be before to")
+    }
+    return nil
 }
 
-// UpdateOverdue marks overdue bookings
+// UpdateOverdue marks overdue bookings, recording an event for each.
 func (s *bookingService) UpdateOverdue(ctx context.Context) error {
-    return s.bookingRepo.MarkOverdue(ctx)
-}
\ No newline at end of file
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        marked, err := s.bookingRepo.MarkOverdue(ctx)
+        if err != nil {
+            return err
+        }
+        for _, b := range marked {
+            if err := recordEvent(ctx, s.outbox, model.EventBookingOverdue, b.ID, b); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        s.logger.ErrorContext(ctx, "marking overdue bookings failed", "error", err)
+        return err
+    }
+    return nil
+}
+
+// offerFreeCopies offers each free copy of the book to the next user on its
+// waitlist, as an OFFERED booking held for offerHold, and returns the offers
+// made. The caller must hold the lock on the book, and should pass the
+// offers to notifyOffers once its transaction commits.
+func (s *bookingService) offerFreeCopies(ctx context.Context, bookID string) ([]model.Booking, error) {
+    if s.reservations == nil {
+        return nil, nil
+    }
+    var offers []model.Booking
+    for {
+        book, err := s.bookRepo.GetByID(ctx, bookID)
+        if err != nil {
+            return nil, err
+        }
+        if book.CopiesAvailable <= 0 {
+            return offers, nil
+        }
+        next, err := s.reservations.PopNext(ctx, bookID)
+        if errors.Is(err, apperr.ErrNotFound) {
+            return offers, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        now := time.Now().UTC()
+        expires := now.Add(s.offerHold)
+        offer := &model.Booking{
+            UserID:         next.UserID,
+            BookID:         bookID,
+            BorrowedAt:     now,
+            DueDate:        expires,
+            Status:         "OFFERED",
+            OfferExpiresAt: &expires,
+        }
+        if err := s.bookingRepo.Create(ctx, offer); err != nil {
+            return nil, err
+        }
+        if err := recordEvent(ctx, s.outbox, model.EventBookingOffered, offer.ID, offer); err != nil {
+            return nil, err
+        }
+        s.logger.InfoContext(ctx, "waitlist offer made", "booking_id", offer.ID, "book_id", bookID, "user_id", next.UserID, "expires_at", expires)
+        offers = append(offers, *offer)
+    }
+}
+
+// notifyOffers emails each user their waitlist offer. Failures are logged
+// and otherwise ignored: the offer stands and shows up in the user's
+// bookings either way.
+func (s *bookingService) notifyOffers(ctx context.Context, offers []model.Booking) {
+    if s.notifier == nil {
+        return
+    }
+    for _, offer := range offers {
+        err := s.notify(ctx, &offer, notify.TemplateReservationOffer, func(user *model.User, book *model.Book) any {
+            return notify.ReservationOffer{Username: user.Username, Title: book.Title, ExpiresAt: *offer.OfferExpiresAt}
+        })
+        if err != nil {
+            s.logger.ErrorContext(ctx, "sending waitlist offer email failed", "booking_id", offer.ID, "user_id", offer.UserID, "error", err)
+        }
+    }
+}
+
+// notify sends the booking's user the named template, with data built from
+// the user and the booked book.
+func (s *bookingService) notify(ctx context.Context, b *model.Booking, template string, data func(*model.User, *model.Book) any) error {
+    user, book, err := s.loanParties(ctx, b)
+    if err != nil {
+        return err
+    }
+    return s.notifier.Send(ctx, user.Email, "", template, data(user, book))
+}
+
+// loanParties looks up the user and the book of b.
+func (s *bookingService) loanParties(ctx context.Context, b *model.Booking) (*model.User, *model.Book, error) {
+    user, err := s.userRepo.GetByID(ctx, b.UserID)
+    if err != nil {
+        return nil, nil, err
+    }
+    book, err := s.bookRepo.GetByID(ctx, b.BookID)
+    if err != nil {
+        return nil, nil, err
+    }
+    return user, &book, nil
+}
+
+// openOffer locks the book and then the booking, in Borrow's order, and
+// checks that the booking is an offer made to userID.
+func (s *bookingService) openOffer(ctx context.Context, userID, bookingID string) (*model.Booking, error) {
+    booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+    if err != nil {
+        return nil, err
+    }
+    if booking.UserID != userID {
+        return nil, apperr.Forbidden("this booking belongs to another user")
+    }
+    if _, err := s.bookRepo.GetByIDForUpdate(ctx, booking.BookID); err != nil {
+        return nil, err
+    }
+    booking, err = s.bookingRepo.GetByIDForUpdate(ctx, bookingID)
+    if err != nil {
+        return nil, err
+    }
+    if booking.Status != "OFFERED" {
+        return nil, apperr.Conflict("this booking is not an open offer")
+    }
+    return booking, nil
+}
+
+func (s *bookingService) AcceptOffer(ctx context.Context, userID, bookingID string, req *model.AcceptOfferRequest) (*model.Booking, error) {
+    var accepted *model.Booking
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        booking, err := s.openOffer(ctx, userID, bookingID)
+        if err != nil {
+            return err
+        }
+        now := time.Now().UTC()
+        if booking.OfferExpiresAt != nil && !now.Before(*booking.OfferExpiresAt) {
+            return apperr.Conflict("this offer has expired")
+        }
+
+        user, err := s.userRepo.GetByID(ctx, userID)
+        if err != nil {
+            return err
+        }
+        if user.IsSuspended(now) {
+            return apperr.Forbidden("your account is suspended")
+        }
+        if err := s.ensureOpen(ctx, booking.BranchID, now); err != nil {
+            return err
+        }
+        terms, err := s.checkLoanPolicy(ctx, user, booking.BookID, req.BorrowDays)
+        if err != nil {
+            return err
+        }
+        due, err := s.dueDate(ctx, booking.BranchID, now, terms.days)
+        if err != nil {
+            return err
+        }
+
+        accepted, err = s.bookingRepo.Update(ctx, bookingID, model.UpdateBookingPatch{
+            Status:           model.Ptr("ACTIVE"),
+            BorrowedAt:       &now,
+            DueDate:          &due,
+            ClearOfferExpiry: true,
+        })
+        if err != nil {
+            return err
+        }
+        return recordEvent(ctx, s.outbox, model.EventBookingCreated, accepted.ID, accepted)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return accepted, nil
+}
+
+func (s *bookingService) DeclineOffer(ctx context.Context, userID, bookingID string) (*model.Booking, error) {
+    var declined *model.Booking
+    var offers []model.Booking
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        booking, err := s.openOffer(ctx, userID, bookingID)
+        if err != nil {
+            return err
+        }
+        declined, err = s.bookingRepo.Update(ctx, bookingID, model.UpdateBookingPatch{Status: model.Ptr("DECLINED")})
+        if err != nil {
+            return err
+        }
+        offers, err = s.offerFreeCopies(ctx, booking.BookID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.notifyOffers(ctx, offers)
+    return declined, nil
+}
+
+// ExpireOffers handles each book in its own transaction, so one failure
+// doesn't hold up the rest; failures are logged and returned together.
+func (s *bookingService) ExpireOffers(ctx context.Context) error {
+    if s.reservations == nil {
+        return nil
+    }
+    expired, err := s.bookingRepo.ExpiredOffers(ctx, time.Now().UTC())
+    if err != nil {
+        return err
+    }
+    var errs []error
+    for _, offer := range expired {
+        var offers []model.Booking
+        err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+            if _, err := s.bookRepo.GetByIDForUpdate(ctx, offer.BookID); err != nil {
+                return err
+            }
+            booking, err := s.bookingRepo.GetByIDForUpdate(ctx, offer.ID)
+            if err != nil {
+                return err
+            }
+            if booking.Status != "OFFERED" {
+                return nil
+            }
+            if _, err := s.bookingRepo.Update(ctx, offer.ID, model.UpdateBookingPatch{Status: model.Ptr("EXPIRED")}); err != nil {
+                return err
+            }
+            s.logger.InfoContext(ctx, "waitlist offer expired", "booking_id", offer.ID, "book_id", offer.BookID, "user_id", offer.UserID)
+            offers, err = s.offerFreeCopies(ctx, offer.BookID)
+            return err
+        })
+        if err != nil {
+            s.logger.ErrorContext(ctx, "expiring waitlist offer failed", "booking_id", offer.ID, "error", err)
+            errs = append(errs, err)
+            continue
+        }
+        s.notifyOffers(ctx, offers)
+    }
+
+    // Copies can also be free because an admin added some.
+    bookIDs, err := s.reservations.WaitlistedBookIDs(ctx)
+    if err != nil {
+        return errors.Join(append(errs, err)...)
+    }
+    for _, bookID := range bookIDs {
+        var offers []model.Booking
+        err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+            if _, err := s.bookRepo.GetByIDForUpdate(ctx, bookID); err != nil {
+                return err
+            }
+            offers, err = s.offerFreeCopies(ctx, bookID)
+            return err
+        })
+        if err != nil {
+            s.logger.ErrorContext(ctx, "offering free copies failed", "book_id", bookID, "error", err)
+            errs = append(errs, err)
+            continue
+        }
+        s.notifyOffers(ctx, offers)
+    }
+    return errors.Join(errs...)
+}
+
+// reminderBatch caps the reminders one SendDueReminders run claims, so a
+// backlog is worked off over several runs rather than outliving one.
+const reminderBatch = 100
+
+// SendDueReminders claims each loan before reminding its borrower, so
+// instances running the job at the same time don't remind anyone twice. A
+// reminder that can't be sent is released and retried on the next run.
+func (s *bookingService) SendDueReminders(ctx context.Context, lead time.Duration) error {
+    if s.notifier == nil {
+        return nil
+    }
+    due, err := s.bookingRepo.ClaimDueReminders(ctx, time.Now().UTC(), lead, reminderBatch)
+    if err != nil {
+        return err
+    }
+    var errs []error
+    for _, b := range due {
+        err := s.remind(ctx, &b)
+        if err == nil {
+            continue
+        }
+        s.logger.ErrorContext(ctx, "sending due-date reminder failed", "booking_id", b.ID, "user_id", b.UserID, "error", err)
+        errs = append(errs, err)
+        if err := s.bookingRepo.ReleaseReminder(ctx, b.ID); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// remind sends the due-date reminder for b over its borrower's preferred
+// channel. Borrowers who turned reminders off after b was claimed get none.
+func (s *bookingService) remind(ctx context.Context, b *model.Booking) error {
+    prefs, err := s.userRepo.GetNotificationPreferences(ctx, b.UserID)
+    if err != nil {
+        return err
+    }
+    if prefs.Channel == model.NotifyNone {
+        return nil
+    }
+    user, book, err := s.loanParties(ctx, b)
+    if err != nil {
+        return err
+    }
+    data := notify.DueReminder{Username: user.Username, Title: book.Title, DueDate: b.DueDate}
+    if prefs.Channel == model.NotifyWebhook {
+        return s.notifier.Post(ctx, prefs.WebhookURL, notify.TemplateDueReminder, data)
+    }
+    return s.notifier.Send(ctx, user.Email, "", notify.TemplateDueReminder, data)
+}
+
+// Export streams bookings matching f to fn.
+func (s *bookingService) Export(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+    if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+        return apperr.Validation("from must be before to")
+    }
+    return s.bookingRepo.ForEach(ctx, f, fn)
+}
+
+// Stream streams every booking to fn, resuming after afterID.
+func (s *bookingService) Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error {
+    if err := validateAfterID(afterID); err != nil {
+        return err
+    }
+    return s.bookingRepo.Stream(ctx, afterID, fn)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "log/slog"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/ical"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// calendarReminder is how long before a due date calendar apps remind the
+// borrower.
+const calendarReminder = 24 * time.Hour
+
+// BookingCalendarService publishes each user's due dates as an iCalendar
+// feed. Calendar apps can't send a bearer token, so the feed is read with a
+// token signed for the user that is part of its URL.
+type BookingCalendarService interface {
+    // FeedURL returns the user's feed URL. Anyone who has it can read the
+    // feed, until the user resets it or the signing secret changes.
+    FeedURL(ctx context.Context, userID string) (*model.CalendarFeed, error)
+    // ResetFeedURL voids the user's feed URL and returns a new one.
+    ResetFeedURL(ctx context.Context, userID string) (*model.CalendarFeed, error)
+    // Feed returns the due dates of the loans, active and overdue, of the
+    // user token was signed for. Suspended and deleted users have no feed.
+    Feed(ctx context.Context, token string) (*ical.Calendar, error)
+}
+
+type bookingCalendarService struct {
+    bookings repo.BookingRepo
+    users    repo.UserRepo
+    feeds    repo.CalendarFeedRepo
+    secret   []byte
+    feedURL  string
+    logger   *slog.Logger
+}
+
+// NewBookingCalendarService returns a BookingCalendarService signing feed
+// tokens with secret, or with feeds turned off when secret is empty. Feed
+// URLs are feedURL with the token as its token parameter.
+func NewBookingCalendarService(bookings repo.BookingRepo, users repo.UserRepo, feeds repo.CalendarFeedRepo, secret, feedURL string, logger *slog.Logger) BookingCalendarService {
+    return &bookingCalendarService{bookings: bookings, users: users, feeds: feeds, secret: []byte(secret), feedURL: feedURL, logger: logger}
+}
+
+var errFeedNotFound = apperr.NotFound("calendar feed not found")
+
+var errFeedsDisabled = apperr.PolicyViolation("calendar feeds are not enabled")
+
+func (s *bookingCalendarService) FeedURL(ctx context.Context, userID string) (*model.CalendarFeed, error) {
+    if len(s.secret) == 0 {
+        return nil, errFeedsDisabled
+    }
+    version, err := s.feeds.Issue(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    return s.feed(userID, version), nil
+}
+
+func (s *bookingCalendarService) ResetFeedURL(ctx context.Context, userID string) (*model.CalendarFeed, error) {
+    if len(s.secret) == 0 {
+        return nil, errFeedsDisabled
+    }
+    version, err := s.feeds.Rotate(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "calendar feed reset", "user_id", userID, "version", version)
+    return s.feed(userID, version), nil
+}
+
+func (s *bookingCalendarService) feed(userID string, version int) *model.CalendarFeed {
+    token := userID + "." + s.sign(userID, version)
+    return &model.CalendarFeed{URL: s.feedURL + "?" + url.Values{"token": {token}}.Encode()}
+}
+
+// sign signs the user's feed at version. Version 1 signs what tokens did
+// before feeds had versions, so URLs handed out then still work.
+func (s *bookingCalendarService) sign(userID string, version int) string {
+    msg := "calendar-feed:" + userID
+    if version > 1 {
+        msg += ":" + strconv.Itoa(version)
+    }
+    mac := hmac.New(sha256.New, s.secret)
+    mac.Write([]byte(msg))
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *bookingCalendarService) Feed(ctx context.Context, token string) (*ical.Calendar, error) {
+    if len(s.secret) == 0 {
+        return nil, errFeedNotFound
+    }
+    userID, sig, ok := strings.Cut(token, ".")
+    if !ok || uuid.Validate(userID) != nil {
+        return nil, errFeedNotFound
+    }
+    version, err := s.feeds.Version(ctx, userID)
+    if errors.Is(err, apperr.ErrNotFound) {
+        return nil, errFeedNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !hmac.Equal([]byte(sig), []byte(s.sign(userID, version))) {
+        return nil, errFeedNotFound
+    }
+    user, err := s.users.GetByID(ctx, userID)
+    if errors.Is(err, apperr.ErrNotFound) {
+        return nil, errFeedNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    if user.IsSuspended(time.Now()) {
+        s.logger.WarnContext(ctx, "calendar feed refused: account suspended", "user_id", userID)
+        return nil, errFeedNotFound
+    }
+
+    cal := &ical.Calendar{Name: "Library loans"}
+    for _, status := range []string{"ACTIVE", "OVERDUE"} {
+        p := model.PageRequest{Limit: 100}
+        for {
+            page, err := s.bookings.GetByUser(ctx, userID, p, model.BookingFilter{Status: status}, model.BookingExpand{Book: true})
+            if err != nil {
+                return nil, err
+            }
+            for _, b := range page.Items {
+                cal.Events = append(cal.Events, dueEvent(b))
+            }
+            if page.NextCursor == "" {
+                break
+            }
+            p.Cursor = page.NextCursor
+        }
+    }
+    return cal, nil
+}
+
+// dueEvent is the calendar event for a loan's due date.
+func dueEvent(b model.Booking) ical.Event {
+    title := "a library book"
+    if b.Book != nil {
+        title = "“" + b.Book.Title + "”"
+    }
+    e := ical.Event{
+        UID:         b.ID + "@library-api",
+        Summary:     "Return " + title,
+        Description: "Due back at the library. Booking " + b.ID + ".",
+        At:          b.DueDate,
+        Reminder:    calendarReminder,
+    }
+    if b.Status == "OVERDUE" {
+        e.Summary = "Overdue: return " + title
+    }
+    return e
+}
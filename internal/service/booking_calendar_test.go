@@ -0,0 +1,103 @@
+package service
+
+import (
+    "context"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestBookingCalendarService_Feed(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingCalendarService(repos.Bookings, repos.Users, repos.CalendarFeeds, strings.Repeat("k", 32), "https://library.example/v1/bookings/calendar.ics", logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 3}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    now := time.Now().UTC()
+    for _, status := range []string{"ACTIVE", "OVERDUE", "RETURNED"} {
+        require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: ada.ID, BookID: dune.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: status}))
+    }
+
+    feed, err := svc.FeedURL(ctx, ada.ID)
+    require.NoError(t, err)
+    u, err := url.Parse(feed.URL)
+    require.NoError(t, err)
+    require.Equal(t, "/v1/bookings/calendar.ics", u.Path)
+    token := u.Query().Get("token")
+
+    cal, err := svc.Feed(ctx, token)
+    require.NoError(t, err)
+    require.Len(t, cal.Events, 2, "returned loans aren't due")
+    require.Equal(t, "Return “Dune”", cal.Events[0].Summary)
+    require.Equal(t, "Overdue: return “Dune”", cal.Events[1].Summary)
+
+    tampered := token[:len(token)-1] + "A"
+    if tampered == token {
+        tampered = token[:len(token)-1] + "B"
+    }
+    _, err = svc.Feed(ctx, tampered)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    _, err = svc.Feed(ctx, "")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestBookingCalendarService_DisabledWithoutSecret(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingCalendarService(repos.Bookings, repos.Users, repos.CalendarFeeds, "", "https://library.example/v1/bookings/calendar.ics", logger.Discard())
+
+    _, err := svc.FeedURL(context.Background(), "00000000-0000-0000-0000-000000000001")
+    require.ErrorIs(t, err, apperr.ErrPolicyViolation)
+    _, err = svc.Feed(context.Background(), "00000000-0000-0000-0000-000000000001.")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestBookingCalendarService_ResetAndRefusals(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingCalendarService(repos.Bookings, repos.Users, repos.CalendarFeeds, strings.Repeat("k", 32), "https://library.example/v1/bookings/calendar.ics", logger.Discard())
+    token := func(feed *model.CalendarFeed) string {
+        u, err := url.Parse(feed.URL)
+        require.NoError(t, err)
+        return u.Query().Get("token")
+    }
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Status: model.UserStatusActive}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    feed, err := svc.FeedURL(ctx, ada.ID)
+    require.NoError(t, err)
+    old := token(feed)
+    again, err := svc.FeedURL(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, old, token(again), "the feed URL changed without a reset")
+
+    reset, err := svc.ResetFeedURL(ctx, ada.ID)
+    require.NoError(t, err)
+    require.NotEqual(t, old, token(reset))
+    _, err = svc.Feed(ctx, old)
+    require.ErrorIs(t, err, apperr.ErrNotFound, "a reset feed URL still worked")
+    _, err = svc.Feed(ctx, token(reset))
+    require.NoError(t, err)
+
+    _, err = repos.Users.Update(ctx, ada.ID, model.UpdateUserPatch{Status: model.Ptr(model.UserStatusSuspended)})
+    require.NoError(t, err)
+    _, err = svc.Feed(ctx, token(reset))
+    require.ErrorIs(t, err, apperr.ErrNotFound, "a suspended user's feed worked")
+
+    grace := &model.User{Username: "grace", Email: "grace@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, grace))
+    feed, err = svc.FeedURL(ctx, grace.ID)
+    require.NoError(t, err)
+    require.NoError(t, repos.Users.Anonymize(ctx, grace.ID))
+    _, err = svc.Feed(ctx, token(feed))
+    require.ErrorIs(t, err, apperr.ErrNotFound, "a deleted user's feed worked")
+}
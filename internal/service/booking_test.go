@@ -2,24 +2,34 @@ package service
 
 import (
     "context"
+    "encoding/json"
     "errors"
+    "net/http"
+    "net/http/httptest"
     "testing"
     "time"
 
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
     "github.com/stretchr/testify/require"
 )
 
 // Mock repos
 type mockBookingRepoForTest struct {
-    createFn    func(ctx context.Context, b *model.Booking) error
-    getByIDFn   func(ctx context.Context, id string) (*model.Booking, error)
-    getByUserFn func(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error)
-    getActiveFn func(ctx context.Context, userID, bookID string) (*model.Booking, error)
-    updateFn    func(ctx context.Context, id string, updates map[string]interface{}) (*model.Booking, error)
-    listFn      func(ctx context.Context, limit, offset int) ([]model.Booking, error)
-    markOverdueFn func(ctx context.Context) error
+    createFn           func(ctx context.Context, b *model.Booking) error
+    getByIDFn          func(ctx context.Context, id string) (*model.Booking, error)
+    getByIDForUpdateFn func(ctx context.Context, id string) (*model.Booking, error)
+    getByUserFn        func(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
+    getActiveFn        func(ctx context.Context, userID, bookID string) (*model.Booking, error)
+    countOutstandingFn func(ctx context.Context, userID string) (int, error)
+    updateFn           func(ctx context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error)
+    listFn             func(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error)
+    markOverdueFn      func(ctx context.Context) ([]model.Booking, error)
+    forEachFn          func(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error
 }
 
 func (m *mockBookingRepoForTest) Create(ctx context.Context, b *model.Booking) error {
@@ -28,48 +38,93 @@ func (m *mockBookingRepoForTest) Create(ctx context.Context, b *model.Booking) e
 func (m *mockBookingRepoForTest) GetByID(ctx context.Context, id string) (*model.Booking, error) {
     return m.getByIDFn(ctx, id)
 }
-func (m *mockBookingRepoForTest) GetByUser(ctx context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-    return m.getByUserFn(ctx, userID, limit, offset)
+func (m *mockBookingRepoForTest) GetByIDForUpdate(ctx context.Context, id string) (*model.Booking, error) {
+    return m.getByIDForUpdateFn(ctx, id)
+}
+func (m *mockBookingRepoForTest) GetByUser(ctx context.Context, userID string, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    return m.getByUserFn(ctx, userID, p, f, expand)
 }
 func (m *mockBookingRepoForTest) GetActive(ctx context.Context, userID, bookID string) (*model.Booking, error) {
     return m.getActiveFn(ctx, userID, bookID)
 }
-func (m *mockBookingRepoForTest) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Booking, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockBookingRepoForTest) CountOutstandingForUpdate(ctx context.Context, userID string) (int, error) {
+    if m.countOutstandingFn == nil {
+        return 0, nil
+    }
+    return m.countOutstandingFn(ctx, userID)
+}
+func (m *mockBookingRepoForTest) Update(ctx context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error) {
+    return m.updateFn(ctx, id, p)
 }
-func (m *mockBookingRepoForTest) List(ctx context.Context, limit, offset int) ([]model.Booking, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockBookingRepoForTest) List(ctx context.Context, p model.PageRequest, f model.BookingFilter, expand model.BookingExpand) (model.Page[model.Booking], error) {
+    return m.listFn(ctx, p, f, expand)
 }
-func (m *mockBookingRepoForTest) MarkOverdue(ctx context.Context) error {
+func (m *mockBookingRepoForTest) MarkOverdue(ctx context.Context) ([]model.Booking, error) {
     return m.markOverdueFn(ctx)
 }
+func (m *mockBookingRepoForTest) ForEach(ctx context.Context, f model.BookingExportFilter, fn func(*model.Booking) error) error {
+    return m.forEachFn(ctx, f, fn)
+}
+func (m *mockBookingRepoForTest) Stream(ctx context.Context, afterID string, fn func(*model.Booking) error) error {
+    return nil
+}
+func (m *mockBookingRepoForTest) ExpiredOffers(ctx context.Context, now time.Time) ([]model.Booking, error) {
+    return nil, nil
+}
+
+func (m *mockBookingRepoForTest) ClaimDueReminders(ctx context.Context, now time.Time, lead time.Duration, limit int) ([]model.Booking, error) {
+    return nil, nil
+}
+
+func (m *mockBookingRepoForTest) ReleaseReminder(ctx context.Context, id string) error {
+    return nil
+}
+func (m *mockBookingRepoForTest) Overdue(ctx context.Context, now time.Time) ([]model.Booking, error) {
+    return nil, nil
+}
 
 var _ repo.BookingRepo = (*mockBookingRepoForTest)(nil)
 
 type mockBookRepoForTest struct {
-    getByIDFn func(ctx context.Context, id string) (model.Book, error)
-    createFn  func(ctx context.Context, b *model.Book) error
-    listFn    func(ctx context.Context, limit, offset int) ([]model.Book, error)
-    updateFn  func(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error)
-    deleteFn  func(ctx context.Context, id string) error
+    getByIDFn          func(ctx context.Context, id string) (model.Book, error)
+    getByIDForUpdateFn func(ctx context.Context, id string) (model.Book, error)
+    createFn           func(ctx context.Context, b *model.Book) error
+    listFn             func(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
+    updateFn           func(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error)
+    deleteFn           func(ctx context.Context, id string) error
+    createManyFn       func(ctx context.Context, books []*model.Book) ([]error, error)
+    forEachFn          func(ctx context.Context, fn func(*model.Book) error) error
 }
 
 func (m *mockBookRepoForTest) GetByID(ctx context.Context, id string) (model.Book, error) {
+    if m.getByIDFn == nil {
+        return m.getByIDForUpdateFn(ctx, id)
+    }
     return m.getByIDFn(ctx, id)
 }
+func (m *mockBookRepoForTest) GetByIDForUpdate(ctx context.Context, id string) (model.Book, error) {
+    return m.getByIDForUpdateFn(ctx, id)
+}
+func (m *mockBookRepoForTest) FindByISBN(ctx context.Context, isbn string) ([]model.Book, error) {
+    return nil, nil
+}
 func (m *mockBookRepoForTest) Create(ctx context.Context, b *model.Book) error {
     return m.createFn(ctx, b)
 }
-func (m *mockBookRepoForTest) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockBookRepoForTest) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+    return m.listFn(ctx, p, f)
 }
-func (m *mockBookRepoForTest) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockBookRepoForTest) Update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    return m.updateFn(ctx, id, p)
 }
 func (m *mockBookRepoForTest) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockBookRepoForTest) CreateMany(ctx context.Context, books []*model.Book) ([]error, error) {
+    return m.createManyFn(ctx, books)
+}
+
 var _ repo.BookRepo = (*mockBookRepoForTest)(nil)
 
 type mockUserRepoForTest struct {
@@ -77,9 +132,12 @@ type mockUserRepoForTest struct {
     getByUsernameFn func(ctx context.Context, username string) (*model.User, error)
     getByEmailFn    func(ctx context.Context, email string) (*model.User, error)
     createFn        func(ctx context.Context, u *model.User) error
-    updateFn        func(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
-    listFn          func(ctx context.Context, limit, offset int) ([]model.User, error)
+    updateFn        func(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
+    listFn          func(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
     deleteFn        func(ctx context.Context, id string) error
+    anonymizeFn     func(ctx context.Context, id string) error
+    countAdminsFn   func(ctx context.Context) (int, error)
+    savePrefsFn     func(ctx context.Context, p *model.NotificationPreferences) error
 }
 
 func (m *mockUserRepoForTest) GetByID(ctx context.Context, id string) (*model.User, error) {
@@ -94,25 +152,108 @@ func (m *mockUserRepoForTest) GetByEmail(ctx context.Context, email string) (*mo
 func (m *mockUserRepoForTest) Create(ctx context.Context, u *model.User) error {
     return m.createFn(ctx, u)
 }
-func (m *mockUserRepoForTest) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockUserRepoForTest) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    return m.updateFn(ctx, id, p)
+}
+func (m *mockUserRepoForTest) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    return m.listFn(ctx, p)
 }
-func (m *mockUserRepoForTest) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockUserRepoForTest) ReplacePasswordHash(ctx context.Context, id, old, new string) error {
+    return nil
 }
 func (m *mockUserRepoForTest) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
+func (m *mockUserRepoForTest) Anonymize(ctx context.Context, id string) error {
+    return m.anonymizeFn(ctx, id)
+}
+
+func (m *mockUserRepoForTest) CountActiveAdminsForUpdate(ctx context.Context) (int, error) {
+    return m.countAdminsFn(ctx)
+}
+
+func (m *mockUserRepoForTest) GetNotificationPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    return model.DefaultNotificationPreferences(userID), nil
+}
+
+func (m *mockUserRepoForTest) SaveNotificationPreferences(ctx context.Context, p *model.NotificationPreferences) error {
+    return m.savePrefsFn(ctx, p)
+}
 
 var _ repo.UserRepo = (*mockUserRepoForTest)(nil)
 
+// fakeLoanPolicies serves the stored policies and restrictions from maps;
+// anything missing is reported as not found, like the pg repo.
+type fakeLoanPolicies struct {
+    policies     map[model.Role]model.LoanPolicy
+    restrictions map[string]model.BookLoanRestriction
+}
+
+func (f *fakeLoanPolicies) ListPolicies(context.Context) ([]model.LoanPolicy, error) {
+    var out []model.LoanPolicy
+    for _, p := range f.policies {
+        out = append(out, p)
+    }
+    return out, nil
+}
+func (f *fakeLoanPolicies) GetPolicy(_ context.Context, role model.Role) (model.LoanPolicy, error) {
+    if p, ok := f.policies[role]; ok {
+        return p, nil
+    }
+    return model.LoanPolicy{Role: role}, apperr.NotFound("no loan policy for role " + string(role))
+}
+func (f *fakeLoanPolicies) SavePolicy(_ context.Context, p *model.LoanPolicy) error {
+    if f.policies == nil {
+        f.policies = map[model.Role]model.LoanPolicy{}
+    }
+    f.policies[p.Role] = *p
+    return nil
+}
+func (f *fakeLoanPolicies) GetBookRestriction(_ context.Context, bookID string) (model.BookLoanRestriction, error) {
+    if r, ok := f.restrictions[bookID]; ok {
+        return r, nil
+    }
+    return model.BookLoanRestriction{BookID: bookID}, apperr.NotFound("book has no loan restriction")
+}
+func (f *fakeLoanPolicies) SaveBookRestriction(_ context.Context, r *model.BookLoanRestriction) error {
+    if f.restrictions == nil {
+        f.restrictions = map[string]model.BookLoanRestriction{}
+    }
+    f.restrictions[r.BookID] = *r
+    return nil
+}
+func (f *fakeLoanPolicies) DeleteBookRestriction(_ context.Context, bookID string) error {
+    delete(f.restrictions, bookID)
+    return nil
+}
+
+var _ repo.LoanPolicyRepo = (*fakeLoanPolicies)(nil)
+
+type txCtxKey struct{}
+
+// mockTxManager runs fn directly, marking the context so tests can check
+// which repo calls happened inside the transaction.
+type mockTxManager struct {
+    calls int
+}
+
+func (m *mockTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+    m.calls++
+    return fn(context.WithValue(ctx, txCtxKey{}, true))
+}
+
+func inTx(ctx context.Context) bool {
+    v, _ := ctx.Value(txCtxKey{}).(bool)
+    return v
+}
+
 func TestBookingService_Borrow_Success(t *testing.T) {
     ctx := context.Background()
     now := time.Now().UTC()
 
     bookingRepo := &mockBookingRepoForTest{
         getActiveFn: func(_ context.Context, userID, bookID string) (*model.Booking, error) {
-            return nil, errors.New("no active booking")
+            return nil, apperr.NotFound("no active booking found")
         },
         createFn: func(_ context.Context, b *model.Booking) error {
             b.ID = "booking-1"
@@ -130,18 +271,201 @@ func TestBookingService_Borrow_Success(t *testing.T) {
     }
 
     bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "Go Programming", TotalCopies: 1, CopiesAvailable: 1, Available: true}, nil
+        },
         getByIDFn: func(_ context.Context, id string) (model.Book, error) {
-            return model.Book{ID: id, Title: "Go Programming"}, nil
+            return model.Book{ID: id, Title: "Go Programming", TotalCopies: 1}, nil
         },
     }
 
-    svc := NewBookingService(bookingRepo, bookRepo, userRepo)
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
     req := &model.BorrowBookRequest{BookID: "book-1", BorrowDays: 14}
-    booking, err := svc.Borrow(ctx, "user-1", req)
+    receipt, err := svc.Borrow(ctx, "user-1", req)
+
+    require.NoError(t, err)
+    require.Equal(t, "ACTIVE", receipt.Booking.Status)
+    require.NotEmpty(t, receipt.Booking.ID)
+    require.Equal(t, "Go Programming", receipt.Booking.Book.Title)
+    require.Equal(t, "UTC", receipt.TimeZone)
+}
+
+func TestBookingService_Borrow_Receipt(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    policies := &fakeLoanPolicies{
+        policies:     map[model.Role]model.LoanPolicy{model.RoleUser: {Role: model.RoleUser, MaxActiveBookings: 3, MaxBorrowDays: 21}},
+        restrictions: map[string]model.BookLoanRestriction{},
+    }
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, policies, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    user := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, user))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    require.NoError(t, repos.Books.Create(ctx, emma))
+    policies.restrictions[emma.ID] = model.BookLoanRestriction{BookID: emma.ID, MaxBorrowDays: 7}
+
+    _, err := svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: dune.ID, BorrowDays: 14})
+    require.NoError(t, err)
+    receipt, err := svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: emma.ID, BorrowDays: 7, TimeZone: "Asia/Tokyo"})
+    require.NoError(t, err)
+
+    require.Equal(t, "Emma", receipt.Booking.Book.Title)
+    require.Zero(t, receipt.Booking.Book.CopiesAvailable, "the book counts this loan")
+    require.Equal(t, "Asia/Tokyo", receipt.TimeZone)
+    require.True(t, receipt.DueDateLocal.Equal(receipt.Booking.DueDate))
+    _, offset := receipt.DueDateLocal.Zone()
+    require.Equal(t, 9*3600, offset)
+    require.Equal(t, 21, receipt.Policy.MaxBorrowDays)
+    require.Equal(t, 7, receipt.MaxBorrowDays, "the book's restriction is tighter than the policy")
+    require.Equal(t, 2, receipt.LoansOutstanding)
+    require.NotNil(t, receipt.LoansRemaining)
+    require.Equal(t, 1, *receipt.LoansRemaining)
+
+    _, err = svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: dune.ID, BorrowDays: 7, TimeZone: "Local"})
+    require.ErrorIs(t, err, apperr.ErrValidation, "the server's own zone is no use to the client")
+}
+
+func TestBookingService_Borrow_LoanDuration(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    policies := &fakeLoanPolicies{restrictions: map[string]model.BookLoanRestriction{}}
+    duration := model.LoanDuration{MinDays: 3, MaxDays: 28, DefaultDays: 10}
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, policies, duration, nil, nil, nil, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    user := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, user))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    require.NoError(t, repos.Books.Create(ctx, emma))
+    policies.restrictions[emma.ID] = model.BookLoanRestriction{BookID: emma.ID, MaxBorrowDays: 7}
+
+    for _, days := range []int{2, 29} {
+        _, err := svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: dune.ID, BorrowDays: days})
+        require.ErrorIs(t, err, apperr.ErrValidation, "borrowed for %d days", days)
+    }
+
+    receipt, err := svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: dune.ID})
+    require.NoError(t, err)
+    require.Equal(t, 10, loanDays(receipt.Booking))
+    require.Equal(t, 28, receipt.MaxBorrowDays, "the default policy's 30 days outlast the longest loan")
+
+    receipt, err = svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: emma.ID})
+    require.NoError(t, err)
+    require.Equal(t, 7, loanDays(receipt.Booking), "the default is cut to the book's restriction")
+}
+
+// loanDays returns the length of b's loan in whole days.
+func loanDays(b *model.Booking) int {
+    return int(b.DueDate.Sub(b.BorrowedAt).Round(time.Hour).Hours()) / 24
+}
+
+func TestBookingService_BorrowScanned(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    northBranch := &model.Branch{Code: "north", Name: "North"}
+    southBranch := &model.Branch{Code: "south", Name: "South"}
+    require.NoError(t, repos.Branches.Create(ctx, northBranch))
+    require.NoError(t, repos.Branches.Create(ctx, southBranch))
+    north := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "978-0-441-17271-9", TotalCopies: 1}
+    south := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "978-0-441-17271-9", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(tenant.WithBranch(ctx, northBranch.ID), north))
+    require.NoError(t, repos.Books.Create(tenant.WithBranch(ctx, southBranch.ID), south))
+    require.NotEqual(t, north.BranchID, south.BranchID)
 
+    receipt, err := svc.BorrowScanned(ctx, ada.ID, &model.ScanBorrowRequest{Code: "9780441172719", BorrowDays: 7})
     require.NoError(t, err)
-    require.Equal(t, "ACTIVE", booking.Status)
-    require.NotEmpty(t, booking.ID)
+    require.Equal(t, north.ID, receipt.Booking.BookID, "the hyphens in the stored isbn don't matter")
+
+    receipt, err = svc.BorrowScanned(ctx, bob.ID, &model.ScanBorrowRequest{Code: "978 0441 172719", BorrowDays: 7})
+    require.NoError(t, err)
+    require.Equal(t, south.ID, receipt.Booking.BookID, "the branch with a copy free is picked")
+
+    _, err = svc.BorrowScanned(ctx, bob.ID, &model.ScanBorrowRequest{Code: model.CopyCode(north.ID, 1), BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrConflict, "the only copy is out")
+
+    _, err = svc.BorrowScanned(ctx, bob.ID, &model.ScanBorrowRequest{Code: model.CopyCode(north.ID, 2), BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    _, err = svc.BorrowScanned(ctx, bob.ID, &model.ScanBorrowRequest{Code: "9780000000000", BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestBookingService_Borrow_RefusesSuspendedUser(t *testing.T) {
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Status: model.UserStatusSuspended}, nil
+        },
+    }
+    svc := NewBookingService(&mockBookingRepoForTest{}, &mockBookRepoForTest{}, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+
+    _, err := svc.Borrow(context.Background(), "user-1", &model.BorrowBookRequest{BookID: "book-1", BorrowDays: 14})
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+}
+
+func TestBookingService_Borrow_NoCopiesAvailable(t *testing.T) {
+    ctx := context.Background()
+
+    bookingRepo := &mockBookingRepoForTest{
+        getActiveFn: func(_ context.Context, userID, bookID string) (*model.Booking, error) {
+            return nil, apperr.NotFound("no active booking found")
+        },
+    }
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Username: "john"}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "Go Programming", TotalCopies: 2, CopiesAvailable: 0}, nil
+        },
+    }
+
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+    _, err := svc.Borrow(ctx, "user-1", &model.BorrowBookRequest{BookID: "book-1", BorrowDays: 14})
+
+    require.ErrorIs(t, err, apperr.ErrConflict)
+}
+
+func TestBookingService_Borrow_ActiveLookupFails(t *testing.T) {
+    ctx := context.Background()
+    dbErr := errors.New("connection reset")
+
+    created := false
+    bookingRepo := &mockBookingRepoForTest{
+        getActiveFn: func(context.Context, string, string) (*model.Booking, error) {
+            return nil, dbErr
+        },
+        createFn: func(context.Context, *model.Booking) error {
+            created = true
+            return nil
+        },
+    }
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Username: "john"}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "Go Programming", TotalCopies: 2, CopiesAvailable: 2, Available: true}, nil
+        },
+    }
+
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+    _, err := svc.Borrow(ctx, "user-1", &model.BorrowBookRequest{BookID: "book-1", BorrowDays: 14})
+
+    require.ErrorIs(t, err, dbErr)
+    require.False(t, created, "the borrow went ahead without knowing of active loans")
 }
 
 func TestBookingService_Return_Success(t *testing.T) {
@@ -150,12 +474,12 @@ func TestBookingService_Return_Success(t *testing.T) {
 
     bookingRepo := &mockBookingRepoForTest{
         getByIDFn: func(_ context.Context, id string) (*model.Booking, error) {
-            return &model.Booking{
-                ID:     id,
-                Status: "ACTIVE",
-            }, nil
+            return &model.Booking{ID: id, UserID: "user-1", BookID: "book-1", Status: "ACTIVE"}, nil
+        },
+        getByIDForUpdateFn: func(_ context.Context, id string) (*model.Booking, error) {
+            return &model.Booking{ID: id, UserID: "user-1", BookID: "book-1", Status: "ACTIVE"}, nil
         },
-        updateFn: func(_ context.Context, id string, updates map[string]interface{}) (*model.Booking, error) {
+        updateFn: func(_ context.Context, id string, p model.UpdateBookingPatch) (*model.Booking, error) {
             return &model.Booking{
                 ID:         id,
                 Status:     "RETURNED",
@@ -163,29 +487,467 @@ func TestBookingService_Return_Success(t *testing.T) {
             }, nil
         },
     }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id}, nil
+        },
+    }
 
-    svc := NewBookingService(bookingRepo, nil, nil)
-    booking, err := svc.Return(ctx, "booking-1")
+    svc := NewBookingService(bookingRepo, bookRepo, nil, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+    booking, err := svc.Return(ctx, "user-1", "booking-1", false)
 
     require.NoError(t, err)
     require.Equal(t, "RETURNED", booking.Status)
     require.NotNil(t, booking.ReturnedAt)
 }
 
+func TestBookingService_Return_AlreadyReturnedUnderLock(t *testing.T) {
+    ctx := context.Background()
+
+    // The unlocked read still sees ACTIVE; a concurrent return committed
+    // before this one acquired the lock.
+    bookingRepo := &mockBookingRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.Booking, error) {
+            return &model.Booking{ID: id, UserID: "user-1", BookID: "book-1", Status: "ACTIVE"}, nil
+        },
+        getByIDForUpdateFn: func(_ context.Context, id string) (*model.Booking, error) {
+            return &model.Booking{ID: id, UserID: "user-1", BookID: "book-1", Status: "RETURNED"}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id}, nil
+        },
+    }
+
+    svc := NewBookingService(bookingRepo, bookRepo, nil, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+    _, err := svc.Return(ctx, "user-1", "booking-1", false)
+
+    require.ErrorIs(t, err, apperr.ErrConflict)
+}
+
+func TestBookingService_Return_OnlyBorrowerOrAdmin(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com"}
+    mallory := &model.User{Username: "mallory", Email: "mallory@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    require.NoError(t, repos.Users.Create(ctx, mallory))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    require.NoError(t, repos.Books.Create(ctx, emma))
+
+    first, err := svc.Borrow(ctx, alice.ID, &model.BorrowBookRequest{BookID: dune.ID, BorrowDays: 7})
+    require.NoError(t, err)
+    second, err := svc.Borrow(ctx, alice.ID, &model.BorrowBookRequest{BookID: emma.ID, BorrowDays: 7})
+    require.NoError(t, err)
+
+    _, err = svc.Return(ctx, mallory.ID, first.Booking.ID, false)
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    still, err := repos.Bookings.GetByID(ctx, first.Booking.ID)
+    require.NoError(t, err)
+    require.Equal(t, "ACTIVE", still.Status)
+
+    returned, err := svc.Return(ctx, alice.ID, first.Booking.ID, false)
+    require.NoError(t, err)
+    require.Equal(t, "RETURNED", returned.Status)
+
+    returned, err = svc.Return(ctx, "admin-1", second.Booking.ID, true)
+    require.NoError(t, err, "admins may return anyone's loan")
+    require.Equal(t, "RETURNED", returned.Status)
+}
+
+func TestBookingService_Borrow_RunsInTransaction(t *testing.T) {
+    ctx := context.Background()
+    tx := &mockTxManager{}
+
+    bookingRepo := &mockBookingRepoForTest{
+        getActiveFn: func(ctx context.Context, userID, bookID string) (*model.Booking, error) {
+            require.True(t, inTx(ctx))
+            return nil, apperr.NotFound("no active booking found")
+        },
+        createFn: func(ctx context.Context, b *model.Booking) error {
+            require.True(t, inTx(ctx))
+            b.ID = "booking-1"
+            return nil
+        },
+    }
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(ctx context.Context, id string) (model.Book, error) {
+            require.True(t, inTx(ctx))
+            return model.Book{ID: id, TotalCopies: 1, CopiesAvailable: 1, Available: true}, nil
+        },
+    }
+
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, tx, logger.Discard())
+    _, err := svc.Borrow(ctx, "user-1", &model.BorrowBookRequest{BookID: "book-1", BorrowDays: 7})
+
+    require.NoError(t, err)
+    require.Equal(t, 1, tx.calls)
+}
+
 func TestBookingService_GetByUser_Success(t *testing.T) {
     ctx := context.Background()
 
     bookingRepo := &mockBookingRepoForTest{
-        getByUserFn: func(_ context.Context, userID string, limit, offset int) ([]model.Booking, error) {
-            return []model.Booking{
+        getByUserFn: func(_ context.Context, userID string, p model.PageRequest, _ model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            return model.Page[model.Booking]{Items: []model.Booking{
                 {ID: "1", UserID: userID, Status: "ACTIVE"},
-            }, nil
+            }, Total: 1}, nil
         },
     }
 
-    svc := NewBookingService(bookingRepo, nil, nil)
-    bookings, err := svc.GetByUser(ctx, "user-1", 10, 0)
+    svc := NewBookingService(bookingRepo, nil, nil, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+    bookings, err := svc.GetByUser(ctx, "user-1", model.PageRequest{Limit: 10}, model.BookingFilter{}, model.BookingExpand{})
 
     require.NoError(t, err)
-    require.Len(t, bookings, 1)
-}
\ No newline at end of file
+    require.Len(t, bookings.Items, 1)
+}
+func (m *mockBookRepoForTest) ForEach(ctx context.Context, fn func(*model.Book) error) error {
+    return m.forEachFn(ctx, fn)
+}
+func (m *mockBookRepoForTest) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+    return nil
+}
+func (m *mockBookRepoForTest) Popular(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error) {
+    return nil, nil
+}
+func (m *mockBookRepoForTest) Newest(ctx context.Context, limit int) ([]model.Book, error) {
+    return nil, nil
+}
+func (m *mockBookRepoForTest) Merge(ctx context.Context, sourceID, targetID string) (*model.Book, error) {
+    return nil, nil
+}
+func (m *mockBookRepoForTest) Restore(ctx context.Context, id string) (*model.Book, error) {
+    return nil, nil
+}
+
+func TestBookingService_Borrow_EnforcesLoanPolicy(t *testing.T) {
+    ctx := context.Background()
+
+    policies := &fakeLoanPolicies{
+        policies: map[model.Role]model.LoanPolicy{"user": {Role: "user", MaxActiveBookings: 2, MaxBorrowDays: 14}},
+        restrictions: map[string]model.BookLoanRestriction{
+            "reference": {BookID: "reference", ReferenceOnly: true},
+            "short":     {BookID: "short", MaxBorrowDays: 3},
+        },
+    }
+    outstanding := 0
+    created := 0
+    bookingRepo := &mockBookingRepoForTest{
+        getActiveFn: func(context.Context, string, string) (*model.Booking, error) {
+            return nil, apperr.NotFound("no active booking found")
+        },
+        countOutstandingFn: func(ctx context.Context, userID string) (int, error) {
+            require.True(t, inTx(ctx))
+            return outstanding, nil
+        },
+        createFn: func(_ context.Context, b *model.Booking) error {
+            created++
+            return nil
+        },
+    }
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Role: "user"}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, TotalCopies: 1, CopiesAvailable: 1, Available: true}, nil
+        },
+    }
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, policies, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+
+    cases := []struct {
+        bookID      string
+        days        int
+        outstanding int
+        msg         string
+    }{
+        {"reference", 7, 0, "reference-only"},
+        {"short", 7, 0, "at most 3 days"},
+        {"any", 15, 0, "14-day limit for the user role"},
+        {"any", 7, 2, "already have 2 books on loan"},
+    }
+    for _, tc := range cases {
+        outstanding = tc.outstanding
+        _, err := svc.Borrow(ctx, "user-1", &model.BorrowBookRequest{BookID: tc.bookID, BorrowDays: tc.days})
+        require.ErrorIs(t, err, apperr.ErrPolicyViolation)
+        require.Contains(t, err.Error(), tc.msg)
+    }
+    require.Zero(t, created)
+
+    outstanding = 1
+    _, err := svc.Borrow(ctx, "user-1", &model.BorrowBookRequest{BookID: "short", BorrowDays: 3})
+    require.NoError(t, err)
+    require.Equal(t, 1, created)
+}
+
+func TestBookingService_Borrow_DefaultPolicyWithoutStoredOne(t *testing.T) {
+    bookingRepo := &mockBookingRepoForTest{
+        getActiveFn: func(context.Context, string, string) (*model.Booking, error) {
+            return nil, apperr.NotFound("no active booking found")
+        },
+    }
+    userRepo := &mockUserRepoForTest{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Role: "admin"}, nil
+        },
+    }
+    bookRepo := &mockBookRepoForTest{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, TotalCopies: 1, CopiesAvailable: 1, Available: true}, nil
+        },
+    }
+    svc := NewBookingService(bookingRepo, bookRepo, userRepo, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+
+    _, err := svc.Borrow(context.Background(), "admin-1", &model.BorrowBookRequest{BookID: "b1", BorrowDays: 31})
+    require.ErrorIs(t, err, apperr.ErrPolicyViolation)
+}
+
+func TestBookingService_List_ValidatesFilter(t *testing.T) {
+    ctx := context.Background()
+    var got model.BookingFilter
+    bookingRepo := &mockBookingRepoForTest{
+        listFn: func(_ context.Context, _ model.PageRequest, f model.BookingFilter, _ model.BookingExpand) (model.Page[model.Booking], error) {
+            got = f
+            return model.Page[model.Booking]{}, nil
+        },
+    }
+    svc := NewBookingService(bookingRepo, nil, nil, &fakeLoanPolicies{}, model.LoanDuration{}, nil, nil, nil, nil, nil, nil, 0, &mockTxManager{}, logger.Discard())
+
+    from := time.Now()
+    to := from.Add(-time.Hour)
+    for _, bad := range []model.BookingFilter{
+        {Status: "LOST"},
+        {UserID: "u1"},
+        {BookID: "b1"},
+        {From: &from, To: &to},
+    } {
+        _, err := svc.List(ctx, model.PageRequest{Limit: 10}, bad, model.BookingExpand{})
+        require.ErrorIs(t, err, apperr.ErrValidation)
+    }
+
+    want := model.BookingFilter{Status: "OVERDUE", UserID: "6f1c1f9e-2b8e-4a4e-9d55-0c4a3b0e6f11"}
+    _, err := svc.List(ctx, model.PageRequest{Limit: 10}, want, model.BookingExpand{})
+    require.NoError(t, err)
+    require.Equal(t, want, got)
+}
+
+func TestLoanPolicyService_SetPolicy(t *testing.T) {
+    ctx := context.Background()
+    store := &fakeLoanPolicies{}
+    svc := NewLoanPolicyService(store, model.LoanDuration{MinDays: 1, MaxDays: 60, DefaultDays: 14}, logger.Discard())
+
+    _, err := svc.SetPolicy(ctx, "guest", model.LoanPolicyRequest{MaxBorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.SetPolicy(ctx, "user", model.LoanPolicyRequest{MaxBorrowDays: 90})
+    require.ErrorIs(t, err, apperr.ErrValidation, "a policy outlasted the longest loan")
+
+    _, err = svc.SetPolicy(ctx, "user", model.LoanPolicyRequest{MaxActiveBookings: 3, MaxBorrowDays: 21})
+    require.NoError(t, err)
+
+    policies, err := svc.ListPolicies(ctx)
+    require.NoError(t, err)
+    require.Len(t, policies, 2)
+    require.Equal(t, 21, policies[0].MaxBorrowDays)
+    require.Equal(t, model.DefaultLoanPolicy("admin"), policies[1])
+}
+
+// fakeMailer records sent emails and fails while err is set.
+type fakeMailer struct {
+    sent []notify.Message
+    err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg notify.Message) error {
+    if m.err != nil {
+        return m.err
+    }
+    m.sent = append(m.sent, msg)
+    return nil
+}
+
+func newTestNotifier(t *testing.T, mailer *fakeMailer) *notify.Notifier {
+    t.Helper()
+    templates, err := notify.NewRegistry("en", notify.Builtin())
+    require.NoError(t, err)
+    return notify.New(templates, mailer, "Library <library@example.com>")
+}
+
+func TestBookingService_SendDueReminders(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, nil, nil, nil, newTestNotifier(t, mailer), 0, repos.Tx, logger.Discard())
+
+    user := &model.User{Username: "ada", Email: "ada@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, user))
+    now := time.Now().UTC()
+    // Each loan is of its own book: a user holds one ACTIVE loan of a book at most.
+    loan := func(due time.Time, status string) *model.Booking {
+        book := &model.Book{Title: "Dune", Author: "Frank Herbert"}
+        require.NoError(t, repos.Books.Create(ctx, book))
+        b := &model.Booking{UserID: user.ID, BookID: book.ID, BorrowedAt: now, DueDate: due, Status: status}
+        require.NoError(t, repos.Bookings.Create(ctx, b))
+        return b
+    }
+    soon := loan(now.Add(6*time.Hour), "ACTIVE")
+    loan(now.Add(72*time.Hour), "ACTIVE")
+    loan(now.Add(-time.Hour), "ACTIVE")
+    loan(now.Add(6*time.Hour), "RETURNED")
+
+    // A failed send is released and retried on the next run.
+    mailer.err = errors.New("mail server down")
+    require.Error(t, svc.SendDueReminders(ctx, 24*time.Hour))
+    mailer.err = nil
+    require.NoError(t, svc.SendDueReminders(ctx, 24*time.Hour))
+    require.Len(t, mailer.sent, 1)
+    require.Equal(t, "ada@example.com", mailer.sent[0].To)
+    require.Contains(t, mailer.sent[0].Subject, "Dune")
+
+    require.NoError(t, svc.SendDueReminders(ctx, 24*time.Hour))
+    require.Len(t, mailer.sent, 1, "each loan is reminded once")
+
+    // Moving the due date re-arms the reminder.
+    _, err := repos.Bookings.Update(ctx, soon.ID, model.UpdateBookingPatch{DueDate: model.Ptr(now.Add(12 * time.Hour))})
+    require.NoError(t, err)
+    require.NoError(t, svc.SendDueReminders(ctx, 24*time.Hour))
+    require.Len(t, mailer.sent, 2)
+}
+
+func TestBookingService_SendDueReminders_FollowsPreferences(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    notifier := newTestNotifier(t, mailer)
+    var posted []notify.Webhook
+    hook := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var body notify.Webhook
+        _ = json.NewDecoder(r.Body).Decode(&body)
+        posted = append(posted, body)
+    }))
+    defer hook.Close()
+    notifier.SetWebhookClient(hook.Client())
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, nil, nil, nil, notifier, 0, repos.Tx, logger.Discard())
+
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 5}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    now := time.Now().UTC()
+    borrower := func(name string, prefs *model.NotificationPreferences, due time.Duration) {
+        user := &model.User{Username: name, Email: name + "@example.com", Role: model.RoleUser}
+        require.NoError(t, repos.Users.Create(ctx, user))
+        if prefs != nil {
+            prefs.UserID = user.ID
+            require.NoError(t, repos.Users.SaveNotificationPreferences(ctx, prefs))
+        }
+        b := &model.Booking{UserID: user.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.Add(due), Status: "ACTIVE"}
+        require.NoError(t, repos.Bookings.Create(ctx, b))
+    }
+    borrower("ada", nil, 6*time.Hour)
+    borrower("grace", &model.NotificationPreferences{Channel: model.NotifyWebhook, WebhookURL: hook.URL, ReminderLeadHours: 72}, 48*time.Hour)
+    borrower("alan", &model.NotificationPreferences{Channel: model.NotifyNone}, 6*time.Hour)
+    borrower("edsger", &model.NotificationPreferences{Channel: model.NotifyEmail, ReminderLeadHours: 2}, 6*time.Hour)
+
+    require.NoError(t, svc.SendDueReminders(ctx, 24*time.Hour))
+    require.Len(t, mailer.sent, 1, "alan turned reminders off and edsger's lead hasn't been reached")
+    require.Equal(t, "ada@example.com", mailer.sent[0].To)
+    require.Len(t, posted, 1, "grace asked to be reminded three days ahead, by webhook")
+    require.Equal(t, notify.TemplateDueReminder, posted[0].Type)
+    require.Equal(t, "grace", posted[0].Data.(map[string]any)["username"])
+}
+
+func TestBookingService_ReturnEmailsWaitlistOffer(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, repos.Reservations, nil, repos.Outbox, nil, nil, newTestNotifier(t, mailer), time.Hour, repos.Tx, logger.Discard())
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+
+    loan, err := svc.Borrow(ctx, alice.ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.NoError(t, err)
+    require.NoError(t, repos.Reservations.Create(ctx, &model.Reservation{BookID: book.ID, UserID: bob.ID}))
+
+    _, err = svc.Return(ctx, alice.ID, loan.Booking.ID, false)
+    require.NoError(t, err)
+    require.Len(t, mailer.sent, 1)
+    require.Equal(t, "bob@example.com", mailer.sent[0].To)
+    require.Equal(t, `"Dune" is ready for you`, mailer.sent[0].Subject)
+
+    events, err := repos.Outbox.Pending(ctx, 10)
+    require.NoError(t, err)
+    require.Equal(t, model.EventBookingOffered, events[len(events)-1].Type)
+    var offer model.Booking
+    require.NoError(t, json.Unmarshal(events[len(events)-1].Data, &offer))
+    require.Equal(t, bob.ID, offer.UserID)
+    require.Equal(t, "OFFERED", offer.Status)
+}
+
+func TestBookingService_RecordsLoanEvents(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, repos.Outbox, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    user := &model.User{Username: "ada", Email: "ada@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, user))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+
+    borrowed, err := svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.NoError(t, err)
+    _, err = svc.Borrow(ctx, user.ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.Error(t, err)
+    _, err = svc.Return(ctx, user.ID, borrowed.Booking.ID, false)
+    require.NoError(t, err)
+
+    events, err := repos.Outbox.Pending(ctx, 10)
+    require.NoError(t, err)
+    require.Len(t, events, 2, "a failed borrow records nothing")
+    require.Equal(t, model.EventBookingCreated, events[0].Type)
+    require.Equal(t, borrowed.Booking.ID, events[0].Subject)
+    require.Equal(t, model.EventBookingReturned, events[1].Type)
+    var returned model.Booking
+    require.NoError(t, json.Unmarshal(events[1].Data, &returned))
+    require.Equal(t, "RETURNED", returned.Status)
+}
+
+func TestBookingService_UpdateOverdue_RecordsEvents(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, repos.Outbox, nil, nil, nil, 0, repos.Tx, logger.Discard())
+
+    user := &model.User{Username: "ada", Email: "ada@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, user))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert"}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen"}
+    require.NoError(t, repos.Books.Create(ctx, emma))
+    now := time.Now().UTC()
+    late := &model.Booking{UserID: user.ID, BookID: dune.ID, BorrowedAt: now, DueDate: now.Add(-time.Hour), Status: "ACTIVE"}
+    require.NoError(t, repos.Bookings.Create(ctx, late))
+    require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: user.ID, BookID: emma.ID, BorrowedAt: now, DueDate: now.Add(time.Hour), Status: "ACTIVE"}))
+
+    require.NoError(t, svc.UpdateOverdue(ctx))
+    require.NoError(t, svc.UpdateOverdue(ctx))
+
+    events, err := repos.Outbox.Pending(ctx, 10)
+    require.NoError(t, err)
+    require.Len(t, events, 1, "a loan turns overdue once")
+    require.Equal(t, model.EventBookingOverdue, events[0].Type)
+    require.Equal(t, late.ID, events[0].Subject)
+}
@@ -2,43 +2,469 @@ package service
 
 import (
     "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "fmt"
+    "slices"
+    "time"
 
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
 )
 
+// BookService manages the catalog. Every change to a book is recorded in
+// the audit log, by actorID, with the version it produced; History reads
+// it back.
 type BookService interface {
-    List(ctx context.Context, limit, offset int) ([]model.Book, error)
+    List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
     GetByID(ctx context.Context, id string) (model.Book, error)
-    Create(ctx context.Context, b *model.Book) error
-    Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) // ← Changed
-    Delete(ctx context.Context, id string) error
+    Create(ctx context.Context, actorID string, b *model.Book) error
+    Update(ctx context.Context, actorID, id string, p model.UpdateBookPatch) (*model.Book, error)
+    Delete(ctx context.Context, actorID, id string) error
+    // Restore brings back a deleted book, unless its ISBN was reused.
+    Restore(ctx context.Context, actorID, id string) (*model.Book, error)
+    // History returns a book's changes, oldest first. Deleted books keep
+    // theirs.
+    History(ctx context.Context, id string) ([]model.BookChange, error)
+    Import(ctx context.Context, actorID string, rows []model.CreateBookRequest) (*model.ImportReport, error)
+    Export(ctx context.Context, fn func(*model.Book) error) error
+    // Stream hands fn every book after afterID ("" for all) in ID order.
+    Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error
+    Enrich(ctx context.Context, actorID, id string) (*model.Book, error)
+    // Merge folds the duplicate book id into targetID, moving its loans,
+    // reservations and reviews, and returns the updated target.
+    Merge(ctx context.Context, actorID, id, targetID string) (*model.Book, error)
 }
 
 type bookServiceImpl struct {
-    repo repo.BookRepo
+    repo   repo.BookRepo
+    audit  repo.AuditRepo
+    tx     repo.TxManager
+    enrich EnrichmentService
+    logger *slog.Logger
 }
 
-func NewBookService(r repo.BookRepo) BookService {
-    return &bookServiceImpl{repo: r}
+// NewBookService returns the catalog service. enrich may be nil, in which
+// case books can't be created from an ISBN alone.
+func NewBookService(r repo.BookRepo, audit repo.AuditRepo, tx repo.TxManager, enrich EnrichmentService, logger *slog.Logger) BookService {
+    return &bookServiceImpl{repo: r, audit: audit, tx: tx, enrich: enrich, logger: logger}
 }
 
-func (s *bookServiceImpl) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
-    return s.repo.List(ctx, limit, offset)
+func (s *bookServiceImpl) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+    return s.repo.List(ctx, p, f)
 }
 
 func (s *bookServiceImpl) GetByID(ctx context.Context, id string) (model.Book, error) {
     return s.repo.GetByID(ctx, id)
 }
 
-func (s *bookServiceImpl) Create(ctx context.Context, b *model.Book) error {
-    return s.repo.Create(ctx, b)
+// Create looks up a missing title or author by ISBN before inserting.
+func (s *bookServiceImpl) Create(ctx context.Context, actorID string, b *model.Book) error {
+    if (b.Title == "" || b.Author == "") && b.ISBN != "" && s.enrich != nil {
+        err := s.enrich.Fill(ctx, b)
+        if errors.Is(err, apperr.ErrNotFound) {
+            return apperr.Validation(err.Error() + "; title and author are required")
+        }
+        if err != nil {
+            return err
+        }
+    }
+    if err := validateBook(b); err != nil {
+        return err
+    }
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Create(ctx, b); err != nil {
+            return err
+        }
+        return s.recordCreated(ctx, actorID, b)
+    })
 }
 
-func (s *bookServiceImpl) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
-    return s.repo.Update(ctx, id, updates)
+// Update replaces the book's tags and categories only when p carries
+// them.
+func (s *bookServiceImpl) Update(ctx context.Context, actorID, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    if err := validateTags(p.Tags); err != nil {
+        return nil, err
+    }
+    if err := validateCategoryIDs(p.CategoryIDs); err != nil {
+        return nil, err
+    }
+    var book *model.Book
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        before, err := s.repo.GetByIDForUpdate(ctx, id)
+        if err != nil {
+            return err
+        }
+        book, err = s.update(ctx, actorID, before, p)
+        return err
+    })
+    return book, err
 }
 
-func (s *bookServiceImpl) Delete(ctx context.Context, id string) error {
-    return s.repo.Delete(ctx, id)
-}
\ No newline at end of file
+// update applies p to before, the book as stored, and records the change.
+// It runs in the caller's transaction.
+func (s *bookServiceImpl) update(ctx context.Context, actorID string, before model.Book, p model.UpdateBookPatch) (*model.Book, error) {
+    after, err := s.repo.Update(ctx, before.ID, p)
+    if err != nil {
+        return nil, err
+    }
+    err = s.record(ctx, actorID, model.AuditBookUpdated, after.ID, bookAuditDetails{
+        Version: after.Version,
+        Changes: bookChanges(&before, after),
+    })
+    return after, err
+}
+
+func (s *bookServiceImpl) Delete(ctx context.Context, actorID, id string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        book, err := s.repo.GetByIDForUpdate(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := s.repo.Delete(ctx, id); err != nil {
+            return err
+        }
+        return s.record(ctx, actorID, model.AuditBookDeleted, id, bookAuditDetails{Version: book.Version + 1})
+    })
+}
+
+func (s *bookServiceImpl) Restore(ctx context.Context, actorID, id string) (*model.Book, error) {
+    var book *model.Book
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        book, err = s.repo.Restore(ctx, id)
+        if err != nil {
+            return err
+        }
+        return s.record(ctx, actorID, model.AuditBookRestored, id, bookAuditDetails{Version: book.Version})
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "book restored", "book_id", id)
+    return book, nil
+}
+
+func (s *bookServiceImpl) History(ctx context.Context, id string) ([]model.BookChange, error) {
+    entries, err := s.audit.ListByTarget(ctx, bookTarget, id)
+    if err != nil {
+        return nil, err
+    }
+    if len(entries) == 0 {
+        // Books from before changes were recorded have no history yet.
+        if _, err := s.repo.GetByID(ctx, id); err != nil {
+            return nil, err
+        }
+    }
+
+    history := make([]model.BookChange, 0, len(entries))
+    for _, e := range entries {
+        // Details are JSON in the database; the round trip reads them the
+        // same way from any AuditRepo.
+        raw, err := json.Marshal(e.Details)
+        if err != nil {
+            return nil, err
+        }
+        var d bookAuditDetails
+        if err := json.Unmarshal(raw, &d); err != nil {
+            return nil, fmt.Errorf("audit entry %s: %w", e.ID, err)
+        }
+        history = append(history, model.BookChange{
+            Version:        d.Version,
+            Action:         e.Action,
+            ActorID:        e.ActorID,
+            ImpersonatorID: e.ImpersonatorID,
+            Changes:        d.Changes,
+            MergedInto:     d.MergedInto,
+            MergedFrom:     d.MergedFrom,
+            ChangedAt:      e.CreatedAt,
+        })
+    }
+    return history, nil
+}
+
+// Merge records the merge against both books: the duplicate's entry points
+// at the target and the target's lists what it gained.
+func (s *bookServiceImpl) Merge(ctx context.Context, actorID, id, targetID string) (*model.Book, error) {
+    if id == targetID {
+        return nil, apperr.Validation("a book can't be merged into itself")
+    }
+    var merged *model.Book
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        // Plain reads: Merge takes the locks, in its own order.
+        source, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        target, err := s.repo.GetByID(ctx, targetID)
+        if err != nil {
+            return err
+        }
+        merged, err = s.repo.Merge(ctx, id, targetID)
+        if err != nil {
+            return err
+        }
+        if err := s.record(ctx, actorID, model.AuditBookMerged, id, bookAuditDetails{Version: source.Version, MergedInto: targetID}); err != nil {
+            return err
+        }
+        return s.record(ctx, actorID, model.AuditBookMerged, targetID, bookAuditDetails{
+            Version:    merged.Version,
+            Changes:    bookChanges(&target, merged),
+            MergedFrom: id,
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "book merged", "book_id", id, "target_id", targetID)
+    return merged, nil
+}
+// Enrich re-syncs a book's title, author, published year and cover with the
+// metadata provider. The write is conditioned on the version read here.
+func (s *bookServiceImpl) Enrich(ctx context.Context, actorID, id string) (*model.Book, error) {
+    if s.enrich == nil {
+        return nil, apperr.Validation("book metadata enrichment is not configured")
+    }
+    before, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if before.ISBN == "" {
+        return nil, apperr.Validation("book has no ISBN to look up")
+    }
+    book := before
+    if err := s.enrich.Refresh(ctx, &book); err != nil {
+        return nil, err
+    }
+    var enriched *model.Book
+    err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        enriched, err = s.update(ctx, actorID, before, model.UpdateBookPatch{
+            Title:         &book.Title,
+            Author:        &book.Author,
+            PublishedYear: &book.PublishedYear,
+            ISBN:          &book.ISBN,
+            CoverURL:      &book.CoverURL,
+            Version:       &book.Version,
+        })
+        return err
+    })
+    return enriched, err
+}
+
+// Export streams the whole catalog to fn.
+func (s *bookServiceImpl) Export(ctx context.Context, fn func(*model.Book) error) error {
+    return s.repo.ForEach(ctx, fn)
+}
+
+// Stream streams the catalog to fn, resuming after afterID.
+func (s *bookServiceImpl) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+    if err := validateAfterID(afterID); err != nil {
+        return err
+    }
+    return s.repo.Stream(ctx, afterID, fn)
+}
+
+// validateAfterID checks a stream's resume point, which must be an ID.
+func validateAfterID(afterID string) error {
+    if afterID != "" && uuid.Validate(afterID) != nil {
+        return apperr.Validation("after_id must be an ID from a previous stream")
+    }
+    return nil
+}
+
+// Import validates every row and inserts the valid ones in one batch.
+// Invalid rows are reported individually and never reach the database.
+func (s *bookServiceImpl) Import(ctx context.Context, actorID string, rows []model.CreateBookRequest) (*model.ImportReport, error) {
+    report := &model.ImportReport{
+        Total:   len(rows),
+        Results: make([]model.ImportRowResult, len(rows)),
+    }
+
+    var books []*model.Book
+    var positions []int
+    for i, row := range rows {
+        report.Results[i].Row = i + 1
+        row.Normalize()
+        book := &model.Book{
+            Title:         row.Title,
+            Author:        row.Author,
+            PublishedYear: row.PublishedYear,
+            ISBN:          row.ISBN,
+            TotalCopies:   row.Copies(),
+            Tags:          row.Tags,
+        }
+        err := validateBook(book)
+        if errs := validate.Struct(&row); err == nil && len(errs) > 0 {
+            // The row's validate tags, as enforced on a single create.
+            err = apperr.Validation(errs.String())
+        }
+        if err != nil {
+            report.Results[i].Status = "error"
+            report.Results[i].Error = err.Error()
+            continue
+        }
+        books = append(books, book)
+        positions = append(positions, i)
+    }
+
+    if len(books) > 0 {
+        var rowErrs []error
+        err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+            var err error
+            rowErrs, err = s.repo.CreateMany(ctx, books)
+            if err != nil {
+                return err
+            }
+            for j, b := range books {
+                if rowErrs[j] != nil {
+                    continue
+                }
+                if err := s.recordCreated(ctx, actorID, b); err != nil {
+                    return err
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+        for j, i := range positions {
+            if rowErrs[j] != nil {
+                s.logger.WarnContext(ctx, "import row rejected by database", "row", i+1, "error", rowErrs[j])
+                report.Results[i].Status = "error"
+                report.Results[i].Error = rowErrs[j].Error()
+                continue
+            }
+            report.Results[i].Status = "created"
+            report.Results[i].BookID = books[j].ID
+        }
+    }
+
+    for _, res := range report.Results {
+        if res.Status == "created" {
+            report.Created++
+        } else {
+            report.Failed++
+        }
+    }
+    return report, nil
+}
+
+// bookTarget is the audit log's target type for books.
+const bookTarget = "book"
+
+// bookAuditDetails are the details of a book's audit entries.
+type bookAuditDetails struct {
+    Version    int                          `json:"version"`
+    Changes    map[string]model.FieldChange `json:"changes,omitempty"`
+    MergedInto string                       `json:"merged_into,omitempty"`
+    MergedFrom string                       `json:"merged_from,omitempty"`
+}
+
+// record adds an entry about the book bookID to the audit log, in the
+// caller's transaction.
+func (s *bookServiceImpl) record(ctx context.Context, actorID, action, bookID string, d bookAuditDetails) error {
+    details := map[string]interface{}{"version": d.Version}
+    if len(d.Changes) > 0 {
+        details["changes"] = d.Changes
+    }
+    if d.MergedInto != "" {
+        details["merged_into"] = d.MergedInto
+    }
+    if d.MergedFrom != "" {
+        details["merged_from"] = d.MergedFrom
+    }
+    return s.audit.Record(ctx, &model.AuditEntry{
+        ActorID:    actorID,
+        Action:     action,
+        TargetType: bookTarget,
+        TargetID:   bookID,
+        Details:    details,
+    })
+}
+
+func (s *bookServiceImpl) recordCreated(ctx context.Context, actorID string, b *model.Book) error {
+    return s.record(ctx, actorID, model.AuditBookCreated, b.ID, bookAuditDetails{Version: b.Version, Changes: bookChanges(nil, b)})
+}
+
+// bookChanges returns the fields that differ between before and after.
+// With a nil before, the book was just created and the changes are the
+// fields it was created with.
+func bookChanges(before, after *model.Book) map[string]model.FieldChange {
+    var was model.Book
+    if before != nil {
+        was = *before
+    }
+    changes := map[string]model.FieldChange{}
+    add := func(field string, from, to interface{}, same bool) {
+        if same {
+            return
+        }
+        if before == nil {
+            from = nil
+        }
+        changes[field] = model.FieldChange{From: from, To: to}
+    }
+    add("title", was.Title, after.Title, was.Title == after.Title)
+    add("author", was.Author, after.Author, was.Author == after.Author)
+    add("published_year", was.PublishedYear, after.PublishedYear, was.PublishedYear == after.PublishedYear)
+    add("isbn", was.ISBN, after.ISBN, was.ISBN == after.ISBN)
+    add("total_copies", was.TotalCopies, after.TotalCopies, was.TotalCopies == after.TotalCopies)
+    add("cover_url", was.CoverURL, after.CoverURL, was.CoverURL == after.CoverURL)
+    add("tags", was.Tags, after.Tags, slices.Equal(was.Tags, after.Tags))
+    wasIDs, ids := categoryIDs(was.Categories), categoryIDs(after.Categories)
+    add("category_ids", wasIDs, ids, slices.Equal(wasIDs, ids))
+    return changes
+}
+
+func categoryIDs(categories []model.Category) []string {
+    ids := make([]string, len(categories))
+    for i, c := range categories {
+        ids[i] = c.ID
+    }
+    return ids
+}
+
+// validateBook checks the fields required for a catalog entry.
+func validateBook(b *model.Book) error {
+    if b.Title == "" {
+        return apperr.Validation("title is required")
+    }
+    if b.Author == "" {
+        return apperr.Validation("author is required")
+    }
+    if b.PublishedYear < 0 || b.PublishedYear > time.Now().Year()+1 {
+        return apperr.Validation(fmt.Sprintf("published_year must be between 0 and %d", time.Now().Year()+1))
+    }
+    if b.TotalCopies < 0 {
+        return apperr.Validation("total_copies must not be negative")
+    }
+    if err := validateTags(b.Tags); err != nil {
+        return err
+    }
+    return validateCategoryIDs(categoryIDs(b.Categories))
+}
+
+const maxTagLength = 50
+
+func validateTags(tags []string) error {
+    for _, t := range tags {
+        if len(t) > maxTagLength {
+            return apperr.Validation(fmt.Sprintf("tags must be at most %d characters", maxTagLength))
+        }
+    }
+    return nil
+}
+
+func validateCategoryIDs(ids []string) error {
+    for _, id := range ids {
+        if _, err := uuid.Parse(id); err != nil {
+            return apperr.Validation(fmt.Sprintf("invalid category id %q", id))
+        }
+    }
+    return nil
+}
@@ -3,8 +3,13 @@ package service
 import (
     "context"
     "errors"
+    "strings"
     "testing"
+    "time"
 
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/metadata"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
     "github.com/stretchr/testify/require"
@@ -12,11 +17,26 @@ import (
 
 // Mock for repo.BookRepo
 type mockBookRepo struct {
-    createFn   func(ctx context.Context, b *model.Book) error
-    getByIDFn  func(ctx context.Context, id string) (model.Book, error)
-    listFn     func(ctx context.Context, limit, offset int) ([]model.Book, error)
-    updateFn   func(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error)
-    deleteFn   func(ctx context.Context, id string) error
+    createFn           func(ctx context.Context, b *model.Book) error
+    getByIDFn          func(ctx context.Context, id string) (model.Book, error)
+    getByIDForUpdateFn func(ctx context.Context, id string) (model.Book, error)
+    listFn             func(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error)
+    updateFn           func(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error)
+    deleteFn           func(ctx context.Context, id string) error
+    createManyFn       func(ctx context.Context, books []*model.Book) ([]error, error)
+    forEachFn          func(ctx context.Context, fn func(*model.Book) error) error
+    streamFn           func(ctx context.Context, afterID string, fn func(*model.Book) error) error
+    popularFn          func(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error)
+    newestFn           func(ctx context.Context, limit int) ([]model.Book, error)
+    mergeFn            func(ctx context.Context, sourceID, targetID string) (*model.Book, error)
+    restoreFn          func(ctx context.Context, id string) (*model.Book, error)
+}
+
+// newBookService returns the book service on r, recording its audit
+// entries in a store of its own.
+func newBookService(r repo.BookRepo, enrich EnrichmentService) BookService {
+    s := repo.NewMemoryStore()
+    return NewBookService(r, repo.NewMemoryAuditRepo(s), repo.NewMemoryTxManager(s), enrich, logger.Discard())
 }
 
 func (m *mockBookRepo) Create(ctx context.Context, b *model.Book) error {
@@ -27,18 +47,30 @@ func (m *mockBookRepo) GetByID(ctx context.Context, id string) (model.Book, erro
     return m.getByIDFn(ctx, id)
 }
 
-func (m *mockBookRepo) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockBookRepo) GetByIDForUpdate(ctx context.Context, id string) (model.Book, error) {
+    return m.getByIDForUpdateFn(ctx, id)
+}
+
+func (m *mockBookRepo) FindByISBN(ctx context.Context, isbn string) ([]model.Book, error) {
+    return nil, nil
 }
 
-func (m *mockBookRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockBookRepo) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+    return m.listFn(ctx, p, f)
+}
+
+func (m *mockBookRepo) Update(ctx context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+    return m.updateFn(ctx, id, p)
 }
 
 func (m *mockBookRepo) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockBookRepo) CreateMany(ctx context.Context, books []*model.Book) ([]error, error) {
+    return m.createManyFn(ctx, books)
+}
+
 var _ repo.BookRepo = (*mockBookRepo)(nil)
 
 // Book Service Tests
@@ -53,9 +85,9 @@ func TestBookService_Create_Success(t *testing.T) {
         },
     }
 
-    svc := NewBookService(mock)
+    svc := newBookService(mock, nil)
     book := &model.Book{Title: "Go Programming", Author: "Donovan"}
-    err := svc.Create(ctx, book)
+    err := svc.Create(ctx, "admin-1", book)
 
     require.NoError(t, err)
     require.NotEmpty(t, book.ID)
@@ -77,7 +109,7 @@ func TestBookService_GetByID_Success(t *testing.T) {
         },
     }
 
-    svc := NewBookService(mock)
+    svc := newBookService(mock, nil)
     book, err := svc.GetByID(ctx, "book-1")
 
     require.NoError(t, err)
@@ -94,7 +126,7 @@ func TestBookService_GetByID_NotFound(t *testing.T) {
         },
     }
 
-    svc := NewBookService(mock)
+    svc := newBookService(mock, nil)
     book, err := svc.GetByID(ctx, "nonexistent")
 
     require.Error(t, err)
@@ -105,7 +137,10 @@ func TestBookService_Update_Success(t *testing.T) {
     ctx := context.Background()
 
     mock := &mockBookRepo{
-        updateFn: func(_ context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "Go Programming", Author: "Donovan", Version: 1}, nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
             return &model.Book{
                 ID:      id,
                 Title:   "Go Programming - Updated",
@@ -115,9 +150,8 @@ func TestBookService_Update_Success(t *testing.T) {
         },
     }
 
-    svc := NewBookService(mock)
-    updates := map[string]interface{}{"title": "Go Programming - Updated"}
-    book, err := svc.Update(ctx, "book-1", updates)
+    svc := newBookService(mock, nil)
+    book, err := svc.Update(ctx, "admin-1", "book-1", model.UpdateBookPatch{Title: model.Ptr("Go Programming - Updated")})
 
     require.NoError(t, err)
     require.Equal(t, "Go Programming - Updated", book.Title)
@@ -128,32 +162,304 @@ func TestBookService_List_Success(t *testing.T) {
     ctx := context.Background()
 
     mock := &mockBookRepo{
-        listFn: func(_ context.Context, limit, offset int) ([]model.Book, error) {
-            return []model.Book{
+        listFn: func(_ context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
+            return model.Page[model.Book]{Items: []model.Book{
                 {ID: "1", Title: "Book 1", Version: 1},
                 {ID: "2", Title: "Book 2", Version: 1},
-            }, nil
+            }, Total: 2}, nil
         },
     }
 
-    svc := NewBookService(mock)
-    books, err := svc.List(ctx, 10, 0)
+    svc := newBookService(mock, nil)
+    books, err := svc.List(ctx, model.PageRequest{Limit: 10}, model.BookFilter{})
 
     require.NoError(t, err)
-    require.Len(t, books, 2)
+    require.Len(t, books.Items, 2)
 }
 
 func TestBookService_Delete_Success(t *testing.T) {
     ctx := context.Background()
 
     mock := &mockBookRepo{
+        getByIDForUpdateFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Version: 1}, nil
+        },
         deleteFn: func(_ context.Context, id string) error {
             return nil
         },
     }
 
-    svc := NewBookService(mock)
-    err := svc.Delete(ctx, "book-1")
+    svc := newBookService(mock, nil)
+    err := svc.Delete(ctx, "admin-1", "book-1")
+
+    require.NoError(t, err)
+}
+func TestBookService_Stream_ValidatesAfterID(t *testing.T) {
+    ctx := context.Background()
+
+    var got string
+    mock := &mockBookRepo{
+        streamFn: func(_ context.Context, afterID string, fn func(*model.Book) error) error {
+            got = afterID
+            return nil
+        },
+    }
+    svc := newBookService(mock, nil)
+
+    afterID := "4b5e2f1c-8d3a-4f6e-9a7b-1c2d3e4f5a6b"
+    require.NoError(t, svc.Stream(ctx, afterID, func(*model.Book) error { return nil }))
+    require.Equal(t, afterID, got)
+
+    err := svc.Stream(ctx, "not-an-id", func(*model.Book) error { return nil })
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func TestBookService_Import_ReportsPerRow(t *testing.T) {
+    ctx := context.Background()
+
+    mock := &mockBookRepo{
+        createManyFn: func(_ context.Context, books []*model.Book) ([]error, error) {
+            // Only valid rows reach the repo; fail the second one as a duplicate.
+            require.Len(t, books, 2)
+            books[0].ID = "book-1"
+            return []error{nil, errors.New("book with this ISBN already exists")}, nil
+        },
+    }
+
+    svc := newBookService(mock, nil)
+    report, err := svc.Import(ctx, "admin-1", []model.CreateBookRequest{
+        {Title: "Go Programming", Author: "Donovan", ISBN: "1"},
+        {Title: "", Author: "Nobody"},
+        {Title: "Dup", Author: "Someone", ISBN: "1"},
+        {Title: strings.Repeat("x", 501), Author: "Verbose"},
+    })
+
+    require.NoError(t, err)
+    require.Equal(t, 4, report.Total)
+    require.Equal(t, 1, report.Created)
+    require.Equal(t, 3, report.Failed)
+    require.Equal(t, "created", report.Results[0].Status)
+    require.Equal(t, "book-1", report.Results[0].BookID)
+    require.Equal(t, "title is required", report.Results[1].Error)
+    require.Equal(t, "error", report.Results[2].Status)
+    require.Equal(t, "title must be at most 500 characters", report.Results[3].Error)
+}
+
+func (m *mockBookRepo) ForEach(ctx context.Context, fn func(*model.Book) error) error {
+    return m.forEachFn(ctx, fn)
+}
+
+func (m *mockBookRepo) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+    return m.streamFn(ctx, afterID, fn)
+}
+
+type fakeMetadataProvider struct {
+    books map[string]*metadata.Book
+    err   error
+}
+
+func (f *fakeMetadataProvider) LookupISBN(_ context.Context, isbn string) (*metadata.Book, error) {
+    if f.err != nil {
+        return nil, f.err
+    }
+    if b, ok := f.books[isbn]; ok {
+        return b, nil
+    }
+    return nil, metadata.ErrNotFound
+}
+
+var dune = &metadata.Book{Title: "Dune", Author: "Frank Herbert", PublishedYear: 1965, CoverURL: "https://covers.example/dune.jpg"}
+
+func TestBookService_Create_FillsMissingFieldsFromISBN(t *testing.T) {
+    var created *model.Book
+    mock := &mockBookRepo{
+        createFn: func(_ context.Context, b *model.Book) error {
+            created = b
+            return nil
+        },
+    }
+    enrich := NewEnrichmentService(&fakeMetadataProvider{books: map[string]*metadata.Book{"9780441013593": dune}}, logger.Discard())
+    svc := newBookService(mock, enrich)
+
+    err := svc.Create(context.Background(), "admin-1", &model.Book{Author: "F. Herbert", ISBN: "9780441013593", TotalCopies: 1})
+    require.NoError(t, err)
+    require.Equal(t, "Dune", created.Title)
+    require.Equal(t, "F. Herbert", created.Author, "fields the admin gave are kept")
+    require.Equal(t, 1965, created.PublishedYear)
+    require.Equal(t, dune.CoverURL, created.CoverURL)
+
+    err = svc.Create(context.Background(), "admin-1", &model.Book{ISBN: "0000000000"})
+    require.ErrorIs(t, err, apperr.ErrValidation)
 
+    failing := newBookService(mock, NewEnrichmentService(&fakeMetadataProvider{err: errors.New("timeout")}, logger.Discard()))
+    err = failing.Create(context.Background(), "admin-1", &model.Book{ISBN: "9780441013593"})
+    require.ErrorIs(t, err, apperr.ErrUpstream)
+}
+
+func TestBookService_Enrich_OverwritesAtReadVersion(t *testing.T) {
+    mock := &mockBookRepo{
+        getByIDFn: func(_ context.Context, id string) (model.Book, error) {
+            return model.Book{ID: id, Title: "dune", Author: "herbert", ISBN: "9780441013593", Version: 4}, nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateBookPatch) (*model.Book, error) {
+            require.Equal(t, "Dune", *p.Title)
+            require.Equal(t, "Frank Herbert", *p.Author)
+            require.Equal(t, dune.CoverURL, *p.CoverURL)
+            require.Nil(t, p.TotalCopies)
+            require.Equal(t, 4, *p.Version)
+            return &model.Book{ID: id, Title: "Dune", Version: 5}, nil
+        },
+    }
+    enrich := NewEnrichmentService(&fakeMetadataProvider{books: map[string]*metadata.Book{"9780441013593": dune}}, logger.Discard())
+    svc := newBookService(mock, enrich)
+
+    book, err := svc.Enrich(context.Background(), "admin-1", "book-1")
     require.NoError(t, err)
-}
\ No newline at end of file
+    require.Equal(t, 5, book.Version)
+
+    mock.getByIDFn = func(_ context.Context, id string) (model.Book, error) {
+        return model.Book{ID: id, Title: "No ISBN", Author: "Anon"}, nil
+    }
+    _, err = svc.Enrich(context.Background(), "admin-1", "book-2")
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func TestBookService_RejectsBadTagsAndCategoryIDs(t *testing.T) {
+    ctx := context.Background()
+    svc := newBookService(&mockBookRepo{}, nil)
+
+    err := svc.Create(ctx, "admin-1", &model.Book{Title: "Dune", Author: "Herbert", Categories: []model.Category{{ID: "sci-fi"}}})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    _, err = svc.Update(ctx, "admin-1", "1", model.UpdateBookPatch{Tags: []string{strings.Repeat("x", maxTagLength+1)}})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    _, err = svc.Update(ctx, "admin-1", "1", model.UpdateBookPatch{CategoryIDs: []string{"not-a-uuid"}})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func (m *mockBookRepo) Popular(ctx context.Context, since time.Time, limit int) ([]model.PopularBook, error) {
+    return m.popularFn(ctx, since, limit)
+}
+
+func (m *mockBookRepo) Newest(ctx context.Context, limit int) ([]model.Book, error) {
+    return m.newestFn(ctx, limit)
+}
+
+func (m *mockBookRepo) Merge(ctx context.Context, sourceID, targetID string) (*model.Book, error) {
+    return m.mergeFn(ctx, sourceID, targetID)
+}
+
+func (m *mockBookRepo) Restore(ctx context.Context, id string) (*model.Book, error) {
+    return m.restoreFn(ctx, id)
+}
+
+func TestBookService_Merge(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookService(repos.Books, repos.Audit, repos.Tx, nil, logger.Discard())
+    ctx := context.Background()
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    target := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, target))
+    dup := &model.Book{Title: "Dune (dup)", Author: "Frank Herbert", ISBN: "0441172717", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, dup))
+
+    _, err := repos.Books.Update(ctx, dup.ID, model.UpdateBookPatch{ISBN: &target.ISBN})
+    var dupErr *repo.DuplicateISBNError
+    require.ErrorAs(t, err, &dupErr)
+    require.Equal(t, target.ISBN, dupErr.ISBN)
+    require.ErrorIs(t, err, apperr.ErrConflict)
+    require.NoError(t, repos.Books.Create(ctx, &model.Book{Title: "Untitled", Author: "Anon", TotalCopies: 1}))
+    require.NoError(t, repos.Books.Create(ctx, &model.Book{Title: "Untitled 2", Author: "Anon", TotalCopies: 1}),
+        "books without an ISBN don't clash")
+
+    now := time.Now().UTC()
+    loan := &model.Booking{UserID: alice.ID, BookID: dup.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}
+    require.NoError(t, repos.Bookings.Create(ctx, loan))
+    require.NoError(t, repos.Reviews.Create(ctx, &model.Review{BookID: target.ID, UserID: alice.ID, Rating: 5}))
+    require.NoError(t, repos.Reviews.Create(ctx, &model.Review{BookID: dup.ID, UserID: alice.ID, Rating: 1}))
+    require.NoError(t, repos.Reviews.Create(ctx, &model.Review{BookID: dup.ID, UserID: bob.ID, Rating: 3}))
+
+    _, err = svc.Merge(ctx, "admin-1", dup.ID, dup.ID)
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    merged, err := svc.Merge(ctx, "admin-1", dup.ID, target.ID)
+    require.NoError(t, err)
+    require.Equal(t, 3, merged.TotalCopies)
+    require.Equal(t, 2, merged.CopiesAvailable, "alice's loan now counts against the target")
+    require.Equal(t, target.Version+1, merged.Version)
+    require.Equal(t, 2, merged.ReviewCount, "alice's review of the duplicate is dropped")
+    require.Equal(t, 4.0, merged.AverageRating)
+
+    moved, err := repos.Bookings.GetByID(ctx, loan.ID)
+    require.NoError(t, err)
+    require.Equal(t, target.ID, moved.BookID)
+    _, err = svc.GetByID(ctx, dup.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    _, err = svc.Merge(ctx, "admin-1", dup.ID, target.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    // The duplicate's ISBN is free again.
+    require.NoError(t, repos.Books.Create(ctx, &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: dup.ISBN, TotalCopies: 1}))
+}
+
+func TestBookService_HistoryDeleteAndRestore(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewBookService(repos.Books, repos.Audit, repos.Tx, nil, logger.Discard())
+    ctx := context.Background()
+
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", TotalCopies: 1}
+    require.NoError(t, svc.Create(ctx, "admin-1", book))
+    _, err := svc.Update(ctx, "admin-2", book.ID, model.UpdateBookPatch{TotalCopies: model.Ptr(3), Version: &book.Version})
+    require.NoError(t, err)
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    now := time.Now().UTC()
+    loan := &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}
+    require.NoError(t, repos.Bookings.Create(ctx, loan))
+    require.ErrorIs(t, svc.Delete(ctx, "admin-1", book.ID), apperr.ErrConflict, "a copy is on loan")
+    _, err = repos.Bookings.Update(ctx, loan.ID, model.UpdateBookingPatch{Status: model.Ptr("RETURNED"), ReturnedAt: &now})
+    require.NoError(t, err)
+
+    require.NoError(t, svc.Delete(ctx, "admin-1", book.ID))
+    _, err = svc.GetByID(ctx, book.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    // Another book takes the ISBN while this one is deleted.
+    other := &model.Book{Title: "Dune (2021)", Author: "Frank Herbert", ISBN: book.ISBN, TotalCopies: 1}
+    require.NoError(t, svc.Create(ctx, "admin-1", other))
+    _, err = svc.Restore(ctx, "admin-1", book.ID)
+    var dupErr *repo.DuplicateISBNError
+    require.ErrorAs(t, err, &dupErr)
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    require.NoError(t, svc.Delete(ctx, "admin-1", other.ID))
+    restored, err := svc.Restore(ctx, "admin-1", book.ID)
+    require.NoError(t, err)
+    require.Equal(t, 4, restored.Version)
+    _, err = svc.Restore(ctx, "admin-1", book.ID)
+    require.ErrorIs(t, err, apperr.ErrConflict, "the book isn't deleted")
+
+    history, err := svc.History(ctx, book.ID)
+    require.NoError(t, err)
+    require.Len(t, history, 4)
+    require.Equal(t, model.AuditBookCreated, history[0].Action)
+    require.Equal(t, 1, history[0].Version)
+    require.Equal(t, model.FieldChange{To: "Dune"}, history[0].Changes["title"])
+    require.Equal(t, model.AuditBookUpdated, history[1].Action)
+    require.Equal(t, "admin-2", history[1].ActorID)
+    require.Equal(t, map[string]model.FieldChange{"total_copies": {From: float64(1), To: float64(3)}}, history[1].Changes,
+        "only what changed, as read back from JSON")
+    require.Equal(t, model.AuditBookDeleted, history[2].Action)
+    require.Equal(t, 3, history[2].Version)
+    require.Equal(t, model.AuditBookRestored, history[3].Action)
+    require.Equal(t, 4, history[3].Version)
+
+    _, err = svc.History(ctx, "00000000-0000-0000-0000-000000000000")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "regexp"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// branchCode is a DNS label, so every code can be served as a subdomain.
+var branchCode = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// BranchService manages the library's branches and resolves the branch a
+// request is made for.
+type BranchService interface {
+    List(ctx context.Context, p model.PageRequest) (model.Page[model.Branch], error)
+    GetByID(ctx context.Context, id string) (model.Branch, error)
+    // Resolve finds a branch by ID or code.
+    Resolve(ctx context.Context, ref string) (model.Branch, error)
+    Create(ctx context.Context, req model.BranchRequest) (*model.Branch, error)
+    Update(ctx context.Context, id string, req model.BranchRequest) (*model.Branch, error)
+    Delete(ctx context.Context, id string) error
+}
+
+type branchService struct {
+    repo   repo.BranchRepo
+    logger *slog.Logger
+}
+
+func NewBranchService(r repo.BranchRepo, logger *slog.Logger) BranchService {
+    return &branchService{repo: r, logger: logger}
+}
+
+func (s *branchService) List(ctx context.Context, p model.PageRequest) (model.Page[model.Branch], error) {
+    return s.repo.List(ctx, p)
+}
+
+func (s *branchService) GetByID(ctx context.Context, id string) (model.Branch, error) {
+    return s.repo.GetByID(ctx, id)
+}
+
+func (s *branchService) Resolve(ctx context.Context, ref string) (model.Branch, error) {
+    return s.repo.Resolve(ctx, ref)
+}
+
+func (s *branchService) Create(ctx context.Context, req model.BranchRequest) (*model.Branch, error) {
+    if !branchCode.MatchString(req.Code) {
+        return nil, errInvalidBranchCode
+    }
+    b := &model.Branch{Code: req.Code, Name: req.Name, Address: req.Address}
+    if err := s.repo.Create(ctx, b); err != nil {
+        return nil, err
+    }
+    return b, nil
+}
+
+func (s *branchService) Update(ctx context.Context, id string, req model.BranchRequest) (*model.Branch, error) {
+    if !branchCode.MatchString(req.Code) {
+        return nil, errInvalidBranchCode
+    }
+    b := &model.Branch{ID: id, Code: req.Code, Name: req.Name, Address: req.Address}
+    if err := s.repo.Update(ctx, b); err != nil {
+        return nil, err
+    }
+    return b, nil
+}
+
+// Delete refuses branches that still own books, bookings or users, and the
+// main branch.
+func (s *branchService) Delete(ctx context.Context, id string) error {
+    if id == model.DefaultBranchID {
+        return apperr.Conflict("the main branch can't be deleted")
+    }
+    return s.repo.Delete(ctx, id)
+}
+
+var errInvalidBranchCode = apperr.Validation("code may only contain lower-case letters, digits and inner hyphens")
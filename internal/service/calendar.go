@@ -0,0 +1,78 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// maxCalendarRange bounds how many days one calendar listing may span.
+const maxCalendarRange = 2 * 366
+
+// CalendarService manages the days the library is closed. BookingService
+// reads the same closures to refuse loans and returns on closed days.
+type CalendarService interface {
+    // List returns the closures overlapping the days from to to, inclusive.
+    List(ctx context.Context, from, to time.Time) ([]model.Closure, error)
+    Create(ctx context.Context, req *model.CreateClosureRequest) (*model.Closure, error)
+    Delete(ctx context.Context, id string) error
+}
+
+type calendarService struct {
+    closures repo.ClosureRepo
+    logger   *slog.Logger
+}
+
+func NewCalendarService(closures repo.ClosureRepo, logger *slog.Logger) CalendarService {
+    return &calendarService{closures: closures, logger: logger}
+}
+
+func (s *calendarService) List(ctx context.Context, from, to time.Time) ([]model.Closure, error) {
+    from, to = model.Day(from), model.Day(to)
+    if to.Before(from) {
+        return nil, apperr.Validation("to must not be before from")
+    }
+    if to.Sub(from) > maxCalendarRange*24*time.Hour {
+        return nil, apperr.Validation("the calendar can be listed at most two years at a time")
+    }
+    return s.closures.List(ctx, from, to)
+}
+
+// Create adds a closure to the caller's branch, or to every branch for
+// requests with no branch scope. Closures can't end in the past.
+func (s *calendarService) Create(ctx context.Context, req *model.CreateClosureRequest) (*model.Closure, error) {
+    starts, err := time.Parse("2006-01-02", req.StartsOn)
+    if err != nil {
+        return nil, apperr.Validation("starts_on must be a date (YYYY-MM-DD)")
+    }
+    ends, err := time.Parse("2006-01-02", req.EndsOn)
+    if err != nil {
+        return nil, apperr.Validation("ends_on must be a date (YYYY-MM-DD)")
+    }
+    if ends.Before(starts) {
+        return nil, apperr.Validation("ends_on must not be before starts_on")
+    }
+    if ends.Before(model.Day(time.Now())) {
+        return nil, apperr.Validation("a closure can't end in the past")
+    }
+
+    c := &model.Closure{StartsOn: starts, EndsOn: ends, Reason: req.Reason}
+    if err := s.closures.Create(ctx, c); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "closure created", "closure_id", c.ID, "branch_id", c.BranchID,
+        "starts_on", req.StartsOn, "ends_on", req.EndsOn)
+    return c, nil
+}
+
+func (s *calendarService) Delete(ctx context.Context, id string) error {
+    if err := s.closures.Delete(ctx, id); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "closure deleted", "closure_id", id)
+    return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/stretchr/testify/require"
+)
+
+func TestCalendarService_CreateAndList(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewCalendarService(repos.Closures, logger.Discard())
+    ctx := context.Background()
+    north := &model.Branch{Code: "north", Name: "North"}
+    require.NoError(t, repos.Branches.Create(ctx, north))
+    northCtx := tenant.WithBranch(ctx, north.ID)
+
+    today := model.Day(time.Now())
+    day := func(offset int) string { return today.AddDate(0, 0, offset).Format("2006-01-02") }
+
+    _, err := svc.Create(ctx, &model.CreateClosureRequest{StartsOn: day(5), EndsOn: day(4)})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.Create(ctx, &model.CreateClosureRequest{StartsOn: day(-3), EndsOn: day(-1)})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.Create(ctx, &model.CreateClosureRequest{StartsOn: "25/12", EndsOn: day(1)})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    everywhere, err := svc.Create(ctx, &model.CreateClosureRequest{StartsOn: day(10), EndsOn: day(11), Reason: "Holiday"})
+    require.NoError(t, err)
+    require.Empty(t, everywhere.BranchID)
+    local, err := svc.Create(northCtx, &model.CreateClosureRequest{StartsOn: day(2), EndsOn: day(2), Reason: "Stocktake"})
+    require.NoError(t, err)
+    require.Equal(t, north.ID, local.BranchID)
+
+    got, err := svc.List(northCtx, today, today.AddDate(0, 1, 0))
+    require.NoError(t, err)
+    require.Len(t, got, 2)
+    require.Equal(t, local.ID, got[0].ID)
+    got, err = svc.List(tenant.WithBranch(ctx, model.DefaultBranchID), today, today.AddDate(0, 1, 0))
+    require.NoError(t, err)
+    require.Len(t, got, 1, "the main branch doesn't see north's stocktake")
+    got, err = svc.List(northCtx, today, today.AddDate(0, 0, 5))
+    require.NoError(t, err)
+    require.Len(t, got, 1)
+
+    _, err = svc.List(ctx, today, today.AddDate(-1, 0, 0))
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    require.ErrorIs(t, svc.Delete(northCtx, everywhere.ID), apperr.ErrNotFound, "a branch can't remove a library-wide closure")
+    require.NoError(t, svc.Delete(ctx, everywhere.ID))
+}
+
+func TestBookingService_Closures(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    bookings := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, repos.Closures, nil, nil, nil, nil, 0, repos.Tx, logger.Discard())
+    ctx := context.Background()
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    other := &model.Book{Title: "Emma", Author: "Jane Austen", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, other))
+
+    // Back-to-back closures over days 7-8 and 9: a 7-day loan falls due on day 10.
+    today := model.Day(time.Now())
+    for _, c := range []model.Closure{
+        {StartsOn: today.AddDate(0, 0, 9), EndsOn: today.AddDate(0, 0, 9)},
+        {StartsOn: today.AddDate(0, 0, 7), EndsOn: today.AddDate(0, 0, 8)},
+    } {
+        require.NoError(t, repos.Closures.Create(ctx, &c))
+    }
+    receipt, err := bookings.Borrow(ctx, alice.ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.NoError(t, err)
+    loan := receipt.Booking
+    require.Equal(t, today.AddDate(0, 0, 10), model.Day(loan.DueDate))
+    require.Equal(t, loan.BorrowedAt.Sub(model.Day(loan.BorrowedAt)), loan.DueDate.Sub(model.Day(loan.DueDate)), "the due time of day is kept")
+
+    // While closed, nothing goes out or comes back.
+    require.NoError(t, repos.Closures.Create(ctx, &model.Closure{StartsOn: today, EndsOn: today, Reason: "Flood"}))
+    _, err = bookings.Borrow(ctx, alice.ID, &model.BorrowBookRequest{BookID: other.ID, BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrPolicyViolation)
+    require.Contains(t, err.Error(), "Flood")
+    _, err = bookings.Return(ctx, alice.ID, loan.ID, false)
+    require.ErrorIs(t, err, apperr.ErrPolicyViolation)
+}
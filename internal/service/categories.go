@@ -0,0 +1,57 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// CategoryService manages the genres books can be filed under. Linking books
+// to categories goes through BookService.
+type CategoryService interface {
+    List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error)
+    GetByID(ctx context.Context, id string) (model.Category, error)
+    Create(ctx context.Context, req model.CategoryRequest) (*model.Category, error)
+    Update(ctx context.Context, id string, req model.CategoryRequest) (*model.Category, error)
+    Delete(ctx context.Context, id string) error
+}
+
+type categoryService struct {
+    repo   repo.CategoryRepo
+    logger *slog.Logger
+}
+
+func NewCategoryService(r repo.CategoryRepo, logger *slog.Logger) CategoryService {
+    return &categoryService{repo: r, logger: logger}
+}
+
+func (s *categoryService) List(ctx context.Context, p model.PageRequest) (model.Page[model.Category], error) {
+    return s.repo.List(ctx, p)
+}
+
+func (s *categoryService) GetByID(ctx context.Context, id string) (model.Category, error) {
+    return s.repo.GetByID(ctx, id)
+}
+
+func (s *categoryService) Create(ctx context.Context, req model.CategoryRequest) (*model.Category, error) {
+    c := &model.Category{Name: req.Name, Description: req.Description}
+    if err := s.repo.Create(ctx, c); err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+func (s *categoryService) Update(ctx context.Context, id string, req model.CategoryRequest) (*model.Category, error) {
+    c := &model.Category{ID: id, Name: req.Name, Description: req.Description}
+    if err := s.repo.Update(ctx, c); err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+// Delete also removes the category from every book filed under it.
+func (s *categoryService) Delete(ctx context.Context, id string) error {
+    return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "log/slog"
+    "net/url"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// EmailChangeService changes users' email addresses once the new address
+// is confirmed, so an account can't be moved to an address its owner
+// doesn't read.
+type EmailChangeService interface {
+    // Request stages a change of the user's email and emails a
+    // confirmation link to the new address. Asking again replaces the
+    // earlier change, whose link stops working. A version other than 0
+    // must be the user's current version, or ErrPreconditionFailed is
+    // returned.
+    Request(ctx context.Context, userID, email string, version int) (*model.PendingEmailChange, error)
+    // Confirm makes the change whose link carried token and tells the old
+    // address about it. Unknown, used and expired tokens are refused.
+    Confirm(ctx context.Context, token string) (*model.User, error)
+}
+
+type emailChangeService struct {
+    repo       repo.EmailChangeRepo
+    users      repo.UserRepo
+    emails     EmailPolicy
+    notifier   *notify.Notifier
+    confirmURL string
+    ttl        time.Duration
+    tx         repo.TxManager
+    logger     *slog.Logger
+}
+
+// NewEmailChangeService returns the service. Confirmation links are
+// confirmURL with the token added as ?token=, and work for ttl.
+func NewEmailChangeService(r repo.EmailChangeRepo, users repo.UserRepo, emails EmailPolicy, notifier *notify.Notifier, confirmURL string, ttl time.Duration, tx repo.TxManager, logger *slog.Logger) EmailChangeService {
+    return &emailChangeService{repo: r, users: users, emails: emails, notifier: notifier, confirmURL: confirmURL, ttl: ttl, tx: tx, logger: logger}
+}
+
+func (s *emailChangeService) Request(ctx context.Context, userID, email string, version int) (*model.PendingEmailChange, error) {
+    email, err := checkEmail(ctx, s.emails, s.logger, email)
+    if err != nil {
+        return nil, err
+    }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if version != 0 && u.Version != version {
+        return nil, apperr.PreconditionFailed("profile was modified by another request. Please refetch and retry.")
+    }
+    if u.Email == email {
+        return nil, apperr.Validation("that is already your email address")
+    }
+    switch _, err := s.users.GetByEmail(ctx, email); {
+    case err == nil:
+        return nil, apperr.Conflict("email already in use")
+    case !errors.Is(err, apperr.ErrNotFound):
+        return nil, err
+    }
+
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        return nil, err
+    }
+    token := base64.RawURLEncoding.EncodeToString(raw)
+    c := &model.EmailChange{
+        UserID:    userID,
+        NewEmail:  email,
+        TokenHash: hashToken(token),
+        ExpiresAt: time.Now().UTC().Add(s.ttl),
+    }
+    link := s.confirmURL + "?" + url.Values{"token": {token}}.Encode()
+    err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Save(ctx, c); err != nil {
+            return err
+        }
+        return s.notifier.Send(ctx, email, "", notify.TemplateConfirmNewEmail, notify.ConfirmNewEmail{
+            Username: u.Username, NewEmail: email, Link: link, ExpiresAt: c.ExpiresAt,
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "email change requested", "user_id", userID, "expires_at", c.ExpiresAt)
+    return &model.PendingEmailChange{PendingEmail: email, ExpiresAt: c.ExpiresAt}, nil
+}
+
+func (s *emailChangeService) Confirm(ctx context.Context, token string) (*model.User, error) {
+    if token == "" {
+        return nil, apperr.Validation("token is required")
+    }
+    var updated *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        c, err := s.repo.Take(ctx, hashToken(token), time.Now().UTC())
+        if errors.Is(err, apperr.ErrNotFound) {
+            return apperr.NotFound("this confirmation link is invalid or has expired")
+        }
+        if err != nil {
+            return err
+        }
+        u, err := s.users.GetByID(ctx, c.UserID)
+        if err != nil {
+            return err
+        }
+        updated, err = s.users.Update(ctx, c.UserID, model.UpdateUserPatch{Email: &c.NewEmail})
+        if err != nil {
+            return err
+        }
+        return s.notifier.Send(ctx, u.Email, "", notify.TemplateEmailChanged, notify.EmailChanged{
+            Username: u.Username, NewEmail: c.NewEmail,
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "email changed", "user_id", updated.ID)
+    return updated, nil
+}
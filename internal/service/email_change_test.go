@@ -0,0 +1,94 @@
+package service
+
+import (
+    "context"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+var confirmTokenRE = regexp.MustCompile(`confirm-email\?token=([\w-]+)`)
+
+// confirmToken returns the token of the confirmation link in the last
+// email sent.
+func confirmToken(t *testing.T, mailer *fakeMailer) string {
+    t.Helper()
+    m := confirmTokenRE.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].HTML)
+    require.NotNil(t, m, "no confirmation link sent")
+    return m[1]
+}
+
+func TestEmailChangeService_ChangesOnceTheNewAddressConfirms(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewEmailChangeService(repos.EmailChanges, repos.Users, EmailPolicy{}, newTestNotifier(t, mailer),
+        "https://library.example.com/v1/auth/confirm-email", time.Hour, repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    grace := &model.User{Username: "grace", Email: "grace@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, grace))
+
+    _, err := svc.Request(ctx, ada.ID, "grace@example.com", 0)
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    pending, err := svc.Request(ctx, ada.ID, " Ada@Example.org ", 0)
+    require.NoError(t, err)
+    require.Equal(t, "ada@example.org", pending.PendingEmail)
+    require.Len(t, mailer.sent, 1)
+    require.Equal(t, "ada@example.org", mailer.sent[0].To)
+    first := confirmToken(t, mailer)
+
+    u, err := repos.Users.GetByID(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, "ada@example.com", u.Email, "the email changed before it was confirmed")
+
+    // Asking again replaces the first change and its link.
+    _, err = svc.Request(ctx, ada.ID, "ada@example.net", 0)
+    require.NoError(t, err)
+    second := confirmToken(t, mailer)
+    _, err = svc.Confirm(ctx, first)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    u, err = svc.Confirm(ctx, second)
+    require.NoError(t, err)
+    require.Equal(t, "ada@example.net", u.Email)
+    notice := mailer.sent[len(mailer.sent)-1]
+    require.Equal(t, "ada@example.com", notice.To)
+    require.Contains(t, notice.HTML, "ada@example.net")
+
+    // The change bumped the version, so a request made from the old
+    // profile is refused.
+    require.Equal(t, 2, u.Version)
+    _, err = svc.Request(ctx, ada.ID, "ada@example.org", 1)
+    require.ErrorIs(t, err, apperr.ErrPreconditionFailed)
+
+    _, err = svc.Confirm(ctx, second)
+    require.ErrorIs(t, err, apperr.ErrNotFound, "a link worked twice")
+}
+
+func TestEmailChangeService_ExpiredLinkIsRefused(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewEmailChangeService(repos.EmailChanges, repos.Users, EmailPolicy{}, newTestNotifier(t, mailer),
+        "https://library.example.com/v1/auth/confirm-email", -time.Minute, repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    _, err := svc.Request(ctx, ada.ID, "ada@example.org", 0)
+    require.NoError(t, err)
+
+    _, err = svc.Confirm(ctx, confirmToken(t, mailer))
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    u, err := repos.Users.GetByID(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, "ada@example.com", u.Email)
+}
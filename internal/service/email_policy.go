@@ -0,0 +1,43 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "net"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
+)
+
+// EmailPolicy is enforced whenever an email address is set: on
+// registration, profile update and admin update.
+type EmailPolicy struct {
+    // CheckMX also requires the address's domain to accept mail, looked up
+    // in DNS with Resolver, or net.DefaultResolver when that is nil.
+    CheckMX  bool
+    Resolver validate.Resolver
+}
+
+// Check returns email normalized, or a validation error when it isn't an
+// address mail can be sent to. A lookup that fails for another reason,
+// such as a DNS timeout, is returned unwrapped with the normalized address
+// so the caller can decide whether to accept it.
+func (p EmailPolicy) Check(ctx context.Context, email string) (string, error) {
+    email = validate.NormalizeEmail(email)
+    if !validate.IsEmail(email) {
+        return "", apperr.Validation("invalid email format")
+    }
+    if !p.CheckMX {
+        return email, nil
+    }
+
+    var r validate.Resolver = net.DefaultResolver
+    if p.Resolver != nil {
+        r = p.Resolver
+    }
+    err := validate.CheckMX(ctx, r, email)
+    if errors.Is(err, validate.ErrNoMailServer) {
+        return "", apperr.Validation("email domain does not accept email")
+    }
+    return email, err
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/metadata"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+)
+
+// EnrichmentService copies bibliographic metadata for a book's ISBN from an
+// external provider onto the book.
+type EnrichmentService interface {
+    // Fill sets title, author, published year and cover only where the book
+    // leaves them empty, e.g. when an admin creates a book from an ISBN.
+    Fill(ctx context.Context, b *model.Book) error
+    // Refresh overwrites those fields with whatever the provider knows.
+    Refresh(ctx context.Context, b *model.Book) error
+}
+
+type enrichmentService struct {
+    provider metadata.Provider
+    logger   *slog.Logger
+}
+
+// NewEnrichmentService wraps provider; give it a metadata.WithRetry provider
+// to retry transient failures.
+func NewEnrichmentService(provider metadata.Provider, logger *slog.Logger) EnrichmentService {
+    return &enrichmentService{provider: provider, logger: logger}
+}
+
+func (s *enrichmentService) Fill(ctx context.Context, b *model.Book) error {
+    found, err := s.lookup(ctx, b.ISBN)
+    if err != nil {
+        return err
+    }
+    if b.Title == "" {
+        b.Title = found.Title
+    }
+    if b.Author == "" {
+        b.Author = found.Author
+    }
+    if b.PublishedYear == 0 {
+        b.PublishedYear = found.PublishedYear
+    }
+    if b.CoverURL == "" {
+        b.CoverURL = found.CoverURL
+    }
+    return nil
+}
+
+func (s *enrichmentService) Refresh(ctx context.Context, b *model.Book) error {
+    found, err := s.lookup(ctx, b.ISBN)
+    if err != nil {
+        return err
+    }
+    if found.Title != "" {
+        b.Title = found.Title
+    }
+    if found.Author != "" {
+        b.Author = found.Author
+    }
+    if found.PublishedYear != 0 {
+        b.PublishedYear = found.PublishedYear
+    }
+    if found.CoverURL != "" {
+        b.CoverURL = found.CoverURL
+    }
+    return nil
+}
+
+// lookup maps provider failures to apperr kinds: an unknown ISBN is a
+// NotFound, anything else an Upstream error with the cause logged here.
+func (s *enrichmentService) lookup(ctx context.Context, isbn string) (*metadata.Book, error) {
+    if isbn == "" {
+        return nil, apperr.Validation("an isbn is required to look up book metadata")
+    }
+    found, err := s.provider.LookupISBN(ctx, isbn)
+    if errors.Is(err, metadata.ErrNotFound) {
+        return nil, apperr.NotFound("no metadata found for ISBN " + isbn)
+    }
+    if err != nil {
+        s.logger.ErrorContext(ctx, "metadata lookup failed", "isbn", isbn, "error", err)
+        return nil, apperr.Upstream("book metadata provider is unavailable")
+    }
+    return found, nil
+}
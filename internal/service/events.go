@@ -0,0 +1,24 @@
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// recordEvent adds a domain event about subject to the outbox. Call it in
+// the transaction making the change, so the event is published if and only
+// if the change commits. A nil outbox records nothing.
+func recordEvent(ctx context.Context, outbox repo.OutboxRepo, eventType, subject string, data any) error {
+    if outbox == nil {
+        return nil
+    }
+    b, err := json.Marshal(data)
+    if err != nil {
+        return fmt.Errorf("marshal %s event: %w", eventType, err)
+    }
+    return outbox.Add(ctx, &model.Event{Type: eventType, Subject: subject, Data: b})
+}
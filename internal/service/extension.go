@@ -0,0 +1,222 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// ExtensionService handles requests to keep loans longer than the loan
+// policy lets borrowers choose. Borrowers ask with a reason; an admin
+// approves, which moves the booking's due date, or denies, and the
+// borrower is emailed the decision either way.
+type ExtensionService interface {
+    // Request asks for the user's loan to be due req.ExtraDays later than
+    // it is now.
+    Request(ctx context.Context, userID, bookingID string, req *model.CreateExtensionRequest) (*model.ExtensionRequest, error)
+    ListMine(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.ExtensionRequest], error)
+    List(ctx context.Context, p model.PageRequest, f model.ExtensionRequestFilter) (model.Page[model.ExtensionRequest], error)
+    // Approve moves the booking's due date to the requested one on behalf
+    // of the admin actorID, in the same transaction that records the
+    // decision.
+    Approve(ctx context.Context, actorID, id string, req *model.DecideExtensionRequest) (*model.ExtensionRequest, error)
+    Deny(ctx context.Context, actorID, id string, req *model.DecideExtensionRequest) (*model.ExtensionRequest, error)
+}
+
+type extensionService struct {
+    extensions repo.ExtensionRequestRepo
+    bookings   repo.BookingRepo
+    books      repo.BookRepo
+    users      repo.UserRepo
+    closures   repo.ClosureRepo
+    audit      repo.AuditRepo
+    notifier   *notify.Notifier
+    tx         repo.TxManager
+    logger     *slog.Logger
+}
+
+// NewExtensionService returns the extension service. closures may be nil,
+// in which case requested due dates aren't moved past closures, and so may
+// notifier, in which case borrowers aren't emailed decisions.
+func NewExtensionService(extensions repo.ExtensionRequestRepo, bookings repo.BookingRepo, books repo.BookRepo, users repo.UserRepo, closures repo.ClosureRepo, audit repo.AuditRepo, notifier *notify.Notifier, tx repo.TxManager, logger *slog.Logger) ExtensionService {
+    return &extensionService{
+        extensions: extensions,
+        bookings:   bookings,
+        books:      books,
+        users:      users,
+        closures:   closures,
+        audit:      audit,
+        notifier:   notifier,
+        tx:         tx,
+        logger:     logger,
+    }
+}
+
+// extendable reports whether b is a loan still out, which is all that can
+// be extended.
+func extendable(b *model.Booking) bool {
+    return b.Status == "ACTIVE" || b.Status == "OVERDUE"
+}
+
+// Request extends from the current due date, moved past closures as a new
+// loan's is, so an overdue loan needs enough extra days to be due in the
+// future again.
+func (s *extensionService) Request(ctx context.Context, userID, bookingID string, req *model.CreateExtensionRequest) (*model.ExtensionRequest, error) {
+    user, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now().UTC()
+    if user.IsSuspended(now) {
+        return nil, apperr.Forbidden("your account is suspended")
+    }
+
+    booking, err := s.bookings.GetByID(ctx, bookingID)
+    if err != nil {
+        return nil, err
+    }
+    if booking.UserID != userID {
+        return nil, apperr.Forbidden("this booking belongs to another user")
+    }
+    if !extendable(booking) {
+        return nil, apperr.Conflict("only loans that are still out can be extended")
+    }
+    due, err := afterClosures(ctx, s.closures, booking.BranchID, booking.DueDate.AddDate(0, 0, req.ExtraDays))
+    if err != nil {
+        return nil, err
+    }
+    if !due.After(now) {
+        return nil, apperr.Validation("the extended due date would still be in the past")
+    }
+
+    er := &model.ExtensionRequest{
+        BookingID:        booking.ID,
+        UserID:           userID,
+        CurrentDueDate:   booking.DueDate,
+        RequestedDueDate: due,
+        Reason:           req.Reason,
+    }
+    if err := s.extensions.Create(ctx, er); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "extension requested", "extension_id", er.ID, "booking_id", booking.ID, "requested_due_date", due)
+    return er, nil
+}
+
+func (s *extensionService) ListMine(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.ExtensionRequest], error) {
+    return s.extensions.List(ctx, p, model.ExtensionRequestFilter{UserID: userID})
+}
+
+func (s *extensionService) List(ctx context.Context, p model.PageRequest, f model.ExtensionRequestFilter) (model.Page[model.ExtensionRequest], error) {
+    switch f.Status {
+    case "", model.ExtensionPending, model.ExtensionApproved, model.ExtensionDenied:
+    default:
+        return model.Page[model.ExtensionRequest]{}, apperr.Validation("status must be PENDING, APPROVED or DENIED")
+    }
+    return s.extensions.List(ctx, p, f)
+}
+
+// Approve refuses a request whose loan has since been returned; deny it
+// instead.
+func (s *extensionService) Approve(ctx context.Context, actorID, id string, req *model.DecideExtensionRequest) (*model.ExtensionRequest, error) {
+    return s.decide(ctx, actorID, id, model.ExtensionApproved, req.Comment)
+}
+
+func (s *extensionService) Deny(ctx context.Context, actorID, id string, req *model.DecideExtensionRequest) (*model.ExtensionRequest, error) {
+    return s.decide(ctx, actorID, id, model.ExtensionDenied, req.Comment)
+}
+
+// decide records the decision, and for an approval moves the due date,
+// bringing an overdue loan back to ACTIVE, in one transaction. The
+// borrower is emailed once it commits.
+func (s *extensionService) decide(ctx context.Context, actorID, id, status, comment string) (*model.ExtensionRequest, error) {
+    var decided *model.ExtensionRequest
+    var booking *model.Booking
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        er, err := s.extensions.GetByIDForUpdate(ctx, id)
+        if err != nil {
+            return err
+        }
+        if er.Status != model.ExtensionPending {
+            return apperr.Conflict("this extension request has been decided already")
+        }
+        booking, err = s.bookings.GetByIDForUpdate(ctx, er.BookingID)
+        if err != nil {
+            return err
+        }
+
+        now := time.Now().UTC()
+        if status == model.ExtensionApproved {
+            if !extendable(booking) {
+                return apperr.Conflict("the loan has ended, so it can't be extended")
+            }
+            patch := model.UpdateBookingPatch{DueDate: &er.RequestedDueDate}
+            if booking.Status == "OVERDUE" && er.RequestedDueDate.After(now) {
+                patch.Status = model.Ptr("ACTIVE")
+            }
+            if booking, err = s.bookings.Update(ctx, booking.ID, patch); err != nil {
+                return err
+            }
+        }
+        if err := s.extensions.Decide(ctx, id, status, actorID, comment, now); err != nil {
+            return err
+        }
+        er.Status, er.DecidedBy, er.Comment, er.DecidedAt = status, actorID, comment, &now
+        decided = er
+
+        action := model.AuditExtensionDenied
+        if status == model.ExtensionApproved {
+            action = model.AuditExtensionApproved
+        }
+        return s.audit.Record(ctx, &model.AuditEntry{
+            ActorID:    actorID,
+            Action:     action,
+            TargetType: "extension_request",
+            TargetID:   id,
+            Details: map[string]interface{}{
+                "booking_id":         er.BookingID,
+                "user_id":            er.UserID,
+                "current_due_date":   er.CurrentDueDate,
+                "requested_due_date": er.RequestedDueDate,
+                "comment":            comment,
+            },
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "extension request decided", "extension_id", id, "booking_id", decided.BookingID, "status", status)
+
+    if err := s.notifyDecision(ctx, decided, booking); err != nil {
+        s.logger.ErrorContext(ctx, "sending extension decision email failed", "extension_id", id, "user_id", decided.UserID, "error", err)
+    }
+    return decided, nil
+}
+
+// notifyDecision emails the borrower the decision on er and the loan's
+// due date after it.
+func (s *extensionService) notifyDecision(ctx context.Context, er *model.ExtensionRequest, booking *model.Booking) error {
+    if s.notifier == nil {
+        return nil
+    }
+    user, err := s.users.GetByID(ctx, er.UserID)
+    if err != nil {
+        return err
+    }
+    book, err := s.books.GetByID(ctx, booking.BookID)
+    if err != nil {
+        return err
+    }
+    return s.notifier.Send(ctx, user.Email, "", notify.TemplateExtensionDecided, notify.ExtensionDecided{
+        Username: user.Username,
+        Title:    book.Title,
+        Approved: er.Status == model.ExtensionApproved,
+        DueDate:  booking.DueDate,
+        Comment:  er.Comment,
+    })
+}
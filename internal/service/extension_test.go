@@ -0,0 +1,104 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestExtensionService_ApprovalMovesTheDueDate(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewExtensionService(repos.Extensions, repos.Bookings, repos.Books, repos.Users, repos.Closures, repos.Audit,
+        newTestNotifier(t, mailer), repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    grace := &model.User{Username: "grace", Email: "grace@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, grace))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    now := time.Now().UTC()
+    due := now.AddDate(0, 0, -2)
+    loan := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: now.AddDate(0, 0, -30), DueDate: due, Status: "OVERDUE"}
+    require.NoError(t, repos.Bookings.Create(ctx, loan))
+
+    _, err := svc.Request(ctx, grace.ID, loan.ID, &model.CreateExtensionRequest{ExtraDays: 14, Reason: "Mine now"})
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    _, err = svc.Request(ctx, ada.ID, loan.ID, &model.CreateExtensionRequest{ExtraDays: 1, Reason: "Nearly done"})
+    require.ErrorIs(t, err, apperr.ErrValidation, "an extension left the loan overdue")
+
+    er, err := svc.Request(ctx, ada.ID, loan.ID, &model.CreateExtensionRequest{ExtraDays: 14, Reason: "Reading it for a course"})
+    require.NoError(t, err)
+    require.Equal(t, model.ExtensionPending, er.Status)
+    require.True(t, er.RequestedDueDate.Equal(due.AddDate(0, 0, 14)))
+    _, err = svc.Request(ctx, ada.ID, loan.ID, &model.CreateExtensionRequest{ExtraDays: 20, Reason: "Again"})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    decided, err := svc.Approve(ctx, grace.ID, er.ID, &model.DecideExtensionRequest{Comment: "Good luck"})
+    require.NoError(t, err)
+    require.Equal(t, model.ExtensionApproved, decided.Status)
+    require.Equal(t, "Good luck", decided.Comment)
+
+    b, err := repos.Bookings.GetByID(ctx, loan.ID)
+    require.NoError(t, err)
+    require.True(t, b.DueDate.Equal(er.RequestedDueDate))
+    require.Equal(t, "ACTIVE", b.Status, "the extended loan stayed overdue")
+
+    require.Len(t, mailer.sent, 1)
+    require.Equal(t, "ada@example.com", mailer.sent[0].To)
+    require.Contains(t, mailer.sent[0].Subject, "approved")
+    require.Contains(t, mailer.sent[0].HTML, "Good luck")
+
+    _, err = svc.Deny(ctx, grace.ID, er.ID, &model.DecideExtensionRequest{Comment: "Changed my mind"})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    pending, err := svc.List(ctx, model.PageRequest{Limit: 10}, model.ExtensionRequestFilter{Status: model.ExtensionPending})
+    require.NoError(t, err)
+    require.Zero(t, pending.Total)
+}
+
+func TestExtensionService_DenialLeavesTheLoanAlone(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewExtensionService(repos.Extensions, repos.Bookings, repos.Books, repos.Users, nil, repos.Audit,
+        newTestNotifier(t, mailer), repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    now := time.Now().UTC()
+    loan := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 14), Status: "ACTIVE"}
+    require.NoError(t, repos.Bookings.Create(ctx, loan))
+
+    er, err := svc.Request(ctx, ada.ID, loan.ID, &model.CreateExtensionRequest{ExtraDays: 60, Reason: "Long book"})
+    require.NoError(t, err)
+    _, err = repos.Bookings.Update(ctx, loan.ID, model.UpdateBookingPatch{Status: model.Ptr("RETURNED"), ReturnedAt: &now})
+    require.NoError(t, err)
+
+    _, err = svc.Approve(ctx, ada.ID, er.ID, &model.DecideExtensionRequest{Comment: "Sure"})
+    require.ErrorIs(t, err, apperr.ErrConflict, "a returned loan was extended")
+    decided, err := svc.Deny(ctx, ada.ID, er.ID, &model.DecideExtensionRequest{Comment: "Already returned"})
+    require.NoError(t, err)
+    require.Equal(t, model.ExtensionDenied, decided.Status)
+
+    b, err := repos.Bookings.GetByID(ctx, loan.ID)
+    require.NoError(t, err)
+    require.True(t, b.DueDate.Equal(loan.DueDate))
+    require.Len(t, mailer.sent, 1)
+    require.Contains(t, mailer.sent[0].Subject, "declined")
+
+    audit, err := repos.Audit.ListByTarget(ctx, "extension_request", er.ID)
+    require.NoError(t, err)
+    require.Len(t, audit, 1)
+    require.Equal(t, model.AuditExtensionDenied, audit[0].Action)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/payments"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// FineService lets borrowers see and pay the fines BookingService records
+// for late returns. A payment is started with Pay, completed by the client
+// with the provider's SDK and confirmed by the provider's webhook, which
+// marks the fine paid and writes its receipt.
+type FineService interface {
+    ListMine(ctx context.Context, userID string) ([]model.Fine, error)
+    // Pay starts a payment of the user's unpaid fine.
+    Pay(ctx context.Context, userID, fineID string) (*model.PaymentIntent, error)
+    // Receipt returns the receipt of the user's paid fine.
+    Receipt(ctx context.Context, userID, fineID string) (*model.Receipt, error)
+    // HandleWebhook acts on a payment provider's webhook request.
+    HandleWebhook(ctx context.Context, payload []byte, header http.Header) error
+}
+
+type fineService struct {
+    fines    repo.FineRepo
+    payments repo.PaymentRepo
+    provider payments.Provider
+    tx       repo.TxManager
+    logger   *slog.Logger
+}
+
+// NewFineService returns the fine service, taking payments in each fine's
+// currency through provider. provider may be nil, in which case fines
+// can't be paid online.
+func NewFineService(fines repo.FineRepo, paymentRepo repo.PaymentRepo, provider payments.Provider, tx repo.TxManager, logger *slog.Logger) FineService {
+    return &fineService{fines: fines, payments: paymentRepo, provider: provider, tx: tx, logger: logger}
+}
+
+func (s *fineService) ListMine(ctx context.Context, userID string) ([]model.Fine, error) {
+    return s.fines.ListByUser(ctx, userID)
+}
+
+// mine returns the user's fine id. Another user's fine is reported as not
+// found, so fine IDs can't be probed.
+func (s *fineService) mine(ctx context.Context, userID, fineID string) (*model.Fine, error) {
+    fine, err := s.fines.GetByID(ctx, fineID)
+    if err != nil {
+        return nil, err
+    }
+    if fine.UserID != userID {
+        return nil, apperr.NotFound("fine not found")
+    }
+    return fine, nil
+}
+
+func (s *fineService) Pay(ctx context.Context, userID, fineID string) (*model.PaymentIntent, error) {
+    if s.provider == nil {
+        return nil, apperr.PolicyViolation("fines can't be paid online")
+    }
+    fine, err := s.mine(ctx, userID, fineID)
+    if err != nil {
+        return nil, err
+    }
+    if fine.Status == model.FinePaid {
+        return nil, apperr.Conflict("this fine has been paid already")
+    }
+
+    intent, err := s.provider.CreateIntent(ctx, payments.IntentRequest{
+        AmountCents: fine.AmountCents,
+        Currency:    fine.Currency,
+        Description: fmt.Sprintf("Library fine for %d days late", fine.DaysLate),
+        Metadata:    map[string]string{"fine_id": fine.ID, "user_id": userID},
+        // Each call is a new attempt; the key only covers retries of it.
+        IdempotencyKey: uuid.New().String(),
+    })
+    if err != nil {
+        s.logger.ErrorContext(ctx, "payment provider refused intent", "fine_id", fine.ID, "provider", s.provider.Name(), "error", err)
+        return nil, apperr.Upstream("the payment provider is unavailable, try again later")
+    }
+    payment := &model.Payment{
+        FineID:      fine.ID,
+        UserID:      userID,
+        Provider:    s.provider.Name(),
+        ProviderRef: intent.ID,
+        AmountCents: fine.AmountCents,
+        Currency:    fine.Currency,
+    }
+    if err := s.payments.Create(ctx, payment); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "fine payment started", "fine_id", fine.ID, "payment_id", payment.ID, "amount_cents", fine.AmountCents)
+    return &model.PaymentIntent{
+        PaymentID:    payment.ID,
+        Provider:     payment.Provider,
+        ClientSecret: intent.ClientSecret,
+        AmountCents:  payment.AmountCents,
+        Currency:     payment.Currency,
+    }, nil
+}
+
+func (s *fineService) Receipt(ctx context.Context, userID, fineID string) (*model.Receipt, error) {
+    if _, err := s.mine(ctx, userID, fineID); err != nil {
+        return nil, err
+    }
+    return s.payments.GetReceiptByFine(ctx, fineID)
+}
+
+// HandleWebhook records the outcome of a payment. A successful one marks
+// its fine paid and writes the receipt, once: the provider may deliver an
+// event more than once, and a fine paid twice keeps its first receipt.
+// Events about payments the API didn't start are ignored.
+func (s *fineService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) error {
+    if s.provider == nil {
+        return apperr.NotFound("payments are not enabled")
+    }
+    event, err := s.provider.ParseEvent(payload, header)
+    if errors.Is(err, payments.ErrInvalidSignature) {
+        return apperr.Forbidden("invalid webhook signature")
+    }
+    if err != nil {
+        return apperr.Validation(err.Error())
+    }
+    if event.Kind == "" {
+        return nil
+    }
+
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        payment, err := s.payments.GetByProviderRef(ctx, s.provider.Name(), event.IntentID)
+        if errors.Is(err, apperr.ErrNotFound) {
+            s.logger.WarnContext(ctx, "webhook for unknown payment ignored", "provider", s.provider.Name(), "intent_id", event.IntentID)
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if payment.Status != model.PaymentPending {
+            return nil
+        }
+        if event.Kind == payments.EventFailed {
+            s.logger.InfoContext(ctx, "fine payment failed", "fine_id", payment.FineID, "payment_id", payment.ID)
+            return s.payments.SetStatus(ctx, payment.ID, model.PaymentFailed)
+        }
+
+        if event.AmountCents != payment.AmountCents || event.Currency != payment.Currency {
+            s.logger.ErrorContext(ctx, "payment amount doesn't match the fine", "payment_id", payment.ID,
+                "expected_cents", payment.AmountCents, "paid_cents", event.AmountCents, "currency", event.Currency)
+            return apperr.Validation("payment amount doesn't match")
+        }
+        if err := s.payments.SetStatus(ctx, payment.ID, model.PaymentSucceeded); err != nil {
+            return err
+        }
+        fine, err := s.fines.GetByIDForUpdate(ctx, payment.FineID)
+        if err != nil {
+            return err
+        }
+        if fine.Status == model.FinePaid {
+            s.logger.WarnContext(ctx, "fine paid twice", "fine_id", fine.ID, "payment_id", payment.ID)
+            return nil
+        }
+        now := time.Now().UTC()
+        if err := s.fines.MarkPaid(ctx, fine.ID, now); err != nil {
+            return err
+        }
+        receipt := &model.Receipt{
+            FineID:      fine.ID,
+            PaymentID:   payment.ID,
+            UserID:      fine.UserID,
+            AmountCents: payment.AmountCents,
+            Currency:    payment.Currency,
+            PaidAt:      now,
+        }
+        if err := s.payments.CreateReceipt(ctx, receipt); err != nil {
+            return err
+        }
+        s.logger.InfoContext(ctx, "fine paid", "fine_id", fine.ID, "payment_id", payment.ID, "receipt_id", receipt.ID)
+        return nil
+    })
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// FinePolicyService holds the fine policy late returns are priced by.
+// Fines are priced once, when the book comes back, so a new policy only
+// affects returns made after it is set.
+type FinePolicyService interface {
+    // Get returns the policy in force: the one admins set, or the
+    // configured default when they never set one.
+    Get(ctx context.Context) (model.FinePolicy, error)
+    // Set stores the policy and records who changed it in the audit log.
+    Set(ctx context.Context, actorID string, req model.FinePolicyRequest) (*model.FinePolicy, error)
+}
+
+type finePolicyService struct {
+    repo     repo.FinePolicyRepo
+    audit    repo.AuditRepo
+    tx       repo.TxManager
+    defaults model.FinePolicy
+    logger   *slog.Logger
+}
+
+// NewFinePolicyService returns the service. defaults, as configured by
+// FINE_GRACE_DAYS, FINE_PER_DAY_CENTS, FINE_MAX_CENTS and FINE_CURRENCY,
+// is in force until an admin sets a policy.
+func NewFinePolicyService(r repo.FinePolicyRepo, audit repo.AuditRepo, tx repo.TxManager, defaults model.FinePolicy, logger *slog.Logger) FinePolicyService {
+    return &finePolicyService{repo: r, audit: audit, tx: tx, defaults: defaults, logger: logger}
+}
+
+func (s *finePolicyService) Get(ctx context.Context) (model.FinePolicy, error) {
+    p, err := s.repo.Get(ctx)
+    if err != nil {
+        return model.FinePolicy{}, err
+    }
+    if p == nil {
+        return s.defaults, nil
+    }
+    return *p, nil
+}
+
+func (s *finePolicyService) Set(ctx context.Context, actorID string, req model.FinePolicyRequest) (*model.FinePolicy, error) {
+    p := &model.FinePolicy{
+        GraceDays:   req.GraceDays,
+        PerDayCents: req.PerDayCents,
+        MaxCents:    req.MaxCents,
+        Currency:    req.Currency,
+        UpdatedBy:   actorID,
+    }
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Save(ctx, p); err != nil {
+            return err
+        }
+        return s.audit.Record(ctx, &model.AuditEntry{
+            ActorID:    actorID,
+            Action:     model.AuditFinePolicySet,
+            TargetType: "fine_policy",
+            TargetID:   "fines",
+            Details: map[string]interface{}{
+                "grace_days":    p.GraceDays,
+                "per_day_cents": p.PerDayCents,
+                "max_cents":     p.MaxCents,
+                "currency":      p.Currency,
+            },
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    s.logger.InfoContext(ctx, "fine policy set", "grace_days", p.GraceDays, "per_day_cents", p.PerDayCents,
+        "max_cents", p.MaxCents, "currency", p.Currency, "by", actorID)
+    return p, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/payments"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+// fakePayments is a provider whose webhook events are handed to it
+// directly, as the "event" header.
+type fakePayments struct {
+    intents []payments.IntentRequest
+    events  map[string]*payments.Event
+}
+
+func (p *fakePayments) Name() string { return "fake" }
+
+func (p *fakePayments) CreateIntent(_ context.Context, req payments.IntentRequest) (*payments.Intent, error) {
+    p.intents = append(p.intents, req)
+    id := fmt.Sprintf("pi_%d", len(p.intents))
+    return &payments.Intent{ID: id, ClientSecret: id + "_secret"}, nil
+}
+
+func (p *fakePayments) ParseEvent(_ []byte, header http.Header) (*payments.Event, error) {
+    e, ok := p.events[header.Get("event")]
+    if !ok {
+        return nil, payments.ErrInvalidSignature
+    }
+    return e, nil
+}
+
+func TestFineService_LateReturnIsFinedAndPaid(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    bookings := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, nil, repos.Fines, NewFinePolicyService(repos.FinePolicy, repos.Audit, repos.Tx, model.FinePolicy{PerDayCents: 25, MaxCents: 500, Currency: "usd"}, logger.Discard()), nil, 0, repos.Tx, logger.Discard())
+    provider := &fakePayments{events: map[string]*payments.Event{}}
+    fines := NewFineService(repos.Fines, repos.Payments, provider, repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    now := time.Now().UTC()
+    late := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: now.AddDate(0, 0, -10), DueDate: now.Add(-50 * time.Hour), Status: "OVERDUE"}
+    onTime := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}
+    require.NoError(t, repos.Bookings.Create(ctx, late))
+    require.NoError(t, repos.Bookings.Create(ctx, onTime))
+
+    _, err := bookings.Return(ctx, ada.ID, late.ID, false)
+    require.NoError(t, err)
+    _, err = bookings.Return(ctx, ada.ID, onTime.ID, false)
+    require.NoError(t, err)
+
+    mine, err := fines.ListMine(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Len(t, mine, 1, "only the late return is fined")
+    fine := mine[0]
+    require.Equal(t, late.ID, fine.BookingID)
+    require.Equal(t, 3, fine.DaysLate)
+    require.Equal(t, 75, fine.AmountCents)
+    require.Equal(t, model.FineUnpaid, fine.Status)
+
+    _, err = fines.Pay(ctx, bob.ID, fine.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound, "other users' fines can't be paid or seen")
+    intent, err := fines.Pay(ctx, ada.ID, fine.ID)
+    require.NoError(t, err)
+    require.Equal(t, "pi_1_secret", intent.ClientSecret)
+    require.Equal(t, 75, intent.AmountCents)
+    require.Equal(t, fine.ID, provider.intents[0].Metadata["fine_id"])
+
+    _, err = fines.Receipt(ctx, ada.ID, fine.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    err = fines.HandleWebhook(ctx, []byte("{}"), http.Header{"Event": {"forged"}})
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+
+    provider.events["paid"] = &payments.Event{Kind: payments.EventSucceeded, IntentID: "pi_1", AmountCents: 75, Currency: "usd"}
+    for range 2 {
+        require.NoError(t, fines.HandleWebhook(ctx, []byte("{}"), http.Header{"Event": {"paid"}}), "redelivered events are ignored")
+    }
+    mine, err = fines.ListMine(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, model.FinePaid, mine[0].Status)
+    require.NotNil(t, mine[0].PaidAt)
+    receipt, err := fines.Receipt(ctx, ada.ID, fine.ID)
+    require.NoError(t, err)
+    require.Equal(t, 75, receipt.AmountCents)
+    require.Equal(t, intent.PaymentID, receipt.PaymentID)
+
+    _, err = fines.Pay(ctx, ada.ID, fine.ID)
+    require.ErrorIs(t, err, apperr.ErrConflict)
+}
+
+func TestFineService_FailedPaymentLeavesFineUnpaid(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    provider := &fakePayments{events: map[string]*payments.Event{
+        "failed": {Kind: payments.EventFailed, IntentID: "pi_1"},
+    }}
+    fines := NewFineService(repos.Fines, repos.Payments, provider, repos.Tx, logger.Discard())
+    fine := &model.Fine{UserID: "u1", BookingID: "b1", DaysLate: 1, AmountCents: 25, Currency: "usd"}
+    require.NoError(t, repos.Fines.Create(ctx, fine))
+
+    _, err := fines.Pay(ctx, "u1", fine.ID)
+    require.NoError(t, err)
+    require.NoError(t, fines.HandleWebhook(ctx, nil, http.Header{"Event": {"failed"}}))
+
+    got, err := repos.Fines.GetByID(ctx, fine.ID)
+    require.NoError(t, err)
+    require.Equal(t, model.FineUnpaid, got.Status)
+    payment, err := repos.Payments.GetByProviderRef(ctx, "fake", "pi_1")
+    require.NoError(t, err)
+    require.Equal(t, model.PaymentFailed, payment.Status)
+
+    fines = NewFineService(repos.Fines, repos.Payments, nil, repos.Tx, logger.Discard())
+    _, err = fines.Pay(ctx, "u1", fine.ID)
+    require.ErrorIs(t, err, apperr.ErrPolicyViolation, "without a provider fines can't be paid online")
+}
+
+func TestFinePolicyService_OnlyLaterReturnsFollowANewPolicy(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    audit := &recordingAudit{}
+    policies := NewFinePolicyService(repos.FinePolicy, audit, repos.Tx, model.FinePolicy{PerDayCents: 25, Currency: "usd"}, logger.Discard())
+    bookings := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, nil, nil, nil, repos.Fines, policies, nil, 0, repos.Tx, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    returnLate := func() {
+        now := time.Now().UTC()
+        b := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: now.AddDate(0, 0, -10), DueDate: now.Add(-50 * time.Hour), Status: "OVERDUE"}
+        require.NoError(t, repos.Bookings.Create(ctx, b))
+        _, err := bookings.Return(ctx, ada.ID, b.ID, false)
+        require.NoError(t, err)
+    }
+
+    returnLate()
+    set, err := policies.Set(ctx, ada.ID, model.FinePolicyRequest{GraceDays: 2, PerDayCents: 100, MaxCents: 1000, Currency: "eur"})
+    require.NoError(t, err)
+    require.NotNil(t, set.UpdatedAt)
+    returnLate()
+
+    mine, err := repos.Fines.ListByUser(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Len(t, mine, 2)
+    amounts := map[string]int{}
+    for _, f := range mine {
+        amounts[f.Currency] = f.AmountCents
+    }
+    require.Equal(t, map[string]int{"usd": 75, "eur": 100}, amounts, "the earlier fine keeps its amount; the grace days are free")
+
+    require.Len(t, audit.entries, 1)
+    require.Equal(t, model.AuditFinePolicySet, audit.entries[0].Action)
+}
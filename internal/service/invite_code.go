@@ -0,0 +1,172 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "errors"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// InviteCodeService mints the codes people register with while
+// registration is closed.
+type InviteCodeService interface {
+    // Create mints a code on behalf of createdBy, returning it with the
+    // code itself, which is not stored and can't be shown again.
+    Create(ctx context.Context, createdBy string, req *model.CreateInviteCodeRequest) (*model.InviteCode, string, error)
+    List(ctx context.Context) ([]model.InviteCode, error)
+    Revoke(ctx context.Context, id string) error
+}
+
+type inviteCodeService struct {
+    repo   repo.InviteCodeRepo
+    logger *slog.Logger
+}
+
+func NewInviteCodeService(r repo.InviteCodeRepo, logger *slog.Logger) InviteCodeService {
+    return &inviteCodeService{repo: r, logger: logger}
+}
+
+// defaultInviteCodeTTL is how long a code works when no expiry is given.
+const defaultInviteCodeTTL = 7 * 24 * time.Hour
+
+// inviteCodeAlphabet is Crockford's base32, which leaves out I, L, O and U
+// so a code read out or typed by hand is hard to get wrong.
+const inviteCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// inviteCodeGroups and inviteCodeGroupLen shape codes like
+// 7KQ2-M9XD-4RTV-H3NB: 80 random bits in groups that are easy to read.
+const (
+    inviteCodeGroups   = 4
+    inviteCodeGroupLen = 4
+)
+
+func (s *inviteCodeService) Create(ctx context.Context, createdBy string, req *model.CreateInviteCodeRequest) (*model.InviteCode, string, error) {
+    maxUses := req.MaxUses
+    if maxUses == 0 {
+        maxUses = 1
+    }
+    if maxUses < 0 {
+        return nil, "", apperr.Validation("max_uses must be positive")
+    }
+    expiresAt := time.Now().UTC().Add(defaultInviteCodeTTL)
+    if req.ExpiresAt != nil {
+        if !req.ExpiresAt.After(time.Now()) {
+            return nil, "", apperr.Validation("expires_at must be in the future")
+        }
+        expiresAt = req.ExpiresAt.UTC()
+    }
+
+    code, err := newInviteCode()
+    if err != nil {
+        return nil, "", err
+    }
+    c := &model.InviteCode{
+        Prefix:    code[:inviteCodeGroupLen],
+        Hash:      hashToken(normalizeInviteCode(code)),
+        MaxUses:   maxUses,
+        Note:      req.Note,
+        CreatedBy: createdBy,
+        ExpiresAt: expiresAt,
+    }
+    if err := s.repo.Create(ctx, c); err != nil {
+        return nil, "", err
+    }
+    s.logger.InfoContext(ctx, "invite code minted", "invite_code_id", c.ID, "max_uses", c.MaxUses, "expires_at", c.ExpiresAt)
+    return c, code, nil
+}
+
+func (s *inviteCodeService) List(ctx context.Context) ([]model.InviteCode, error) {
+    return s.repo.List(ctx)
+}
+
+func (s *inviteCodeService) Revoke(ctx context.Context, id string) error {
+    if uuid.Validate(id) != nil {
+        return apperr.NotFound("invite code not found")
+    }
+    return s.repo.Revoke(ctx, id, time.Now().UTC())
+}
+
+func newInviteCode() (string, error) {
+    raw := make([]byte, inviteCodeGroups*inviteCodeGroupLen)
+    if _, err := rand.Read(raw); err != nil {
+        return "", err
+    }
+    var b strings.Builder
+    for i, r := range raw {
+        if i > 0 && i%inviteCodeGroupLen == 0 {
+            b.WriteByte('-')
+        }
+        // 256 is a multiple of the alphabet's 32 letters, so every letter
+        // is equally likely.
+        b.WriteByte(inviteCodeAlphabet[int(r)%len(inviteCodeAlphabet)])
+    }
+    return b.String(), nil
+}
+
+// normalizeInviteCode upper-cases code and drops dashes and spaces, so a
+// code is accepted however it was copied.
+func normalizeInviteCode(code string) string {
+    return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// inviteOnlyUsers closes registration to everyone without an invite code.
+type inviteOnlyUsers struct {
+    UserService
+    codes  repo.InviteCodeRepo
+    tx     repo.TxManager
+    logger *slog.Logger
+}
+
+// RequireInviteCodes returns users with registration closed: Register
+// then needs an invite code, and uses it up in the same transaction that
+// creates the user, so a registration that fails doesn't spend a use.
+// RegisterAdmin is refused outright; admins promote invited users instead.
+func RequireInviteCodes(users UserService, codes repo.InviteCodeRepo, tx repo.TxManager, logger *slog.Logger) UserService {
+    return &inviteOnlyUsers{UserService: users, codes: codes, tx: tx, logger: logger}
+}
+
+func (s *inviteOnlyUsers) Register(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
+    var u *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        c, err := redeemInviteCode(ctx, s.codes, req.InviteCode, s.logger)
+        if err != nil {
+            return err
+        }
+        if u, err = s.UserService.Register(ctx, req); err != nil {
+            return err
+        }
+        s.logger.InfoContext(ctx, "invite code redeemed", "invite_code_id", c.ID, "user_id", u.ID, "uses", c.Uses)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return u, nil
+}
+
+func (s *inviteOnlyUsers) RegisterAdmin(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
+    s.logger.WarnContext(ctx, "admin registration refused: registration is invite-only", "username", req.Username)
+    return nil, apperr.Forbidden("admin accounts can't be registered while registration is invite-only")
+}
+
+// redeemInviteCode counts a use of code, returning a forbidden error when
+// there is none or it can't be used. Call it in the transaction that
+// creates the user, so the use is only counted if that succeeds.
+func redeemInviteCode(ctx context.Context, codes repo.InviteCodeRepo, code string, logger *slog.Logger) (*model.InviteCode, error) {
+    if code == "" {
+        return nil, apperr.Forbidden("registration requires an invite code")
+    }
+    c, err := codes.Redeem(ctx, hashToken(normalizeInviteCode(code)), time.Now().UTC())
+    if errors.Is(err, apperr.ErrNotFound) {
+        logger.WarnContext(ctx, "registration refused: invite code invalid, used up or expired")
+        return nil, apperr.Forbidden("invite code is invalid, used up or expired")
+    }
+    return c, err
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+    "context"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestRequireInviteCodes_RegistersOnlyWithAUsableCode(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    codes := NewInviteCodeService(repos.InviteCodes, logger.Discard())
+    users := RequireInviteCodes(
+        NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, repos.Tx, logger.Discard()),
+        repos.InviteCodes, repos.Tx, logger.Discard())
+    register := func(username, code string) error {
+        _, err := users.Register(ctx, &model.RegisterRequest{
+            Username: username, Email: username + "@example.com", Password: "Correct-Horse-42", InviteCode: code,
+        })
+        return err
+    }
+
+    require.ErrorIs(t, register("ada", ""), apperr.ErrForbidden)
+    require.ErrorIs(t, register("ada", "NOPE-NOPE-NOPE-NOPE"), apperr.ErrForbidden)
+
+    c, code, err := codes.Create(ctx, "", &model.CreateInviteCodeRequest{MaxUses: 2})
+    require.NoError(t, err)
+    require.Regexp(t, `^[0-9A-Z]{4}(-[0-9A-Z]{4}){3}$`, code)
+    require.Equal(t, code[:4], c.Prefix)
+
+    // Typed in lower case without dashes, the code still works.
+    require.NoError(t, register("ada", strings.ToLower(strings.ReplaceAll(code, "-", ""))))
+    // A registration that fails doesn't spend a use.
+    require.ErrorIs(t, register("ada", code), apperr.ErrConflict)
+    require.NoError(t, register("grace", code))
+    require.ErrorIs(t, register("linus", code), apperr.ErrForbidden, "a code worked more than max_uses times")
+
+    listed, err := codes.List(ctx)
+    require.NoError(t, err)
+    require.Len(t, listed, 1)
+    require.Equal(t, 2, listed[0].Uses)
+
+    single, code, err := codes.Create(ctx, "", &model.CreateInviteCodeRequest{})
+    require.NoError(t, err)
+    require.Equal(t, 1, single.MaxUses)
+    require.NoError(t, codes.Revoke(ctx, single.ID))
+    require.ErrorIs(t, register("linus", code), apperr.ErrForbidden, "a revoked code worked")
+
+    past := time.Now().Add(-time.Minute)
+    _, _, err = codes.Create(ctx, "", &model.CreateInviteCodeRequest{ExpiresAt: &past})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func TestRequireInviteCodes_RefusesAdminRegistration(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    users := RequireInviteCodes(
+        NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, repos.Tx, logger.Discard()),
+        repos.InviteCodes, repos.Tx, logger.Discard())
+    _, code, err := NewInviteCodeService(repos.InviteCodes, logger.Discard()).Create(ctx, "", &model.CreateInviteCodeRequest{})
+    require.NoError(t, err)
+
+    _, err = users.RegisterAdmin(ctx, &model.RegisterRequest{
+        Username: "mallory", Email: "mallory@example.com", Password: "Correct-Horse-42", InviteCode: code,
+    })
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    _, err = repos.Users.GetByUsername(ctx, "mallory")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestOIDCService_InviteOnlyProvisioning(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewOIDCService(repos.Users, repos.Identities, repos.Revocations, repos.Outbox, repos.InviteCodes, nil, repos.Tx, logger.Discard())
+    existing := &model.User{Username: "alice", Email: "alice@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, existing))
+
+    newcomer := model.ExternalIdentity{Provider: "github", Subject: "42", Email: "octocat@example.com", EmailVerified: true, Username: "octocat"}
+    _, err := svc.Login(ctx, newcomer, "")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    _, err = svc.Login(ctx, newcomer, "NOPE-NOPE-NOPE-NOPE")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    _, err = repos.Users.GetByEmail(ctx, "octocat@example.com")
+    require.ErrorIs(t, err, apperr.ErrNotFound, "a user was provisioned without an invite")
+
+    // Users the library already has log in without one.
+    u, err := svc.Login(ctx, model.ExternalIdentity{Provider: "google", Subject: "g-1", Email: "alice@example.com", EmailVerified: true}, "")
+    require.NoError(t, err)
+    require.Equal(t, existing.ID, u.ID)
+
+    _, code, err := NewInviteCodeService(repos.InviteCodes, logger.Discard()).Create(ctx, "", &model.CreateInviteCodeRequest{})
+    require.NoError(t, err)
+    u, err = svc.Login(ctx, newcomer, code)
+    require.NoError(t, err)
+    require.Equal(t, "octocat", u.Username)
+    // The identity is linked now, so it logs in without the code.
+    again, err := svc.Login(ctx, newcomer, "")
+    require.NoError(t, err)
+    require.Equal(t, u.ID, again.ID)
+
+    _, err = svc.Login(ctx, model.ExternalIdentity{Provider: "github", Subject: "43", Email: "other@example.com", EmailVerified: true}, code)
+    require.ErrorIs(t, err, apperr.ErrForbidden, "a single-use code provisioned twice")
+}
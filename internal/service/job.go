@@ -0,0 +1,57 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "slices"
+    "strings"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// JobService lets admins inspect the background job queue and requeue jobs
+// that ran out of attempts.
+type JobService interface {
+    List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error)
+    Get(ctx context.Context, id string) (*model.Job, error)
+    // Requeue gives a dead job a fresh set of attempts, starting now.
+    Requeue(ctx context.Context, id string) (*model.Job, error)
+}
+
+type jobService struct {
+    jobs   repo.JobRepo
+    logger *slog.Logger
+}
+
+func NewJobService(jobs repo.JobRepo, logger *slog.Logger) JobService {
+    return &jobService{jobs: jobs, logger: logger}
+}
+
+func (s *jobService) List(ctx context.Context, p model.PageRequest, f model.JobFilter) (model.Page[model.Job], error) {
+    if f.Status != "" && !slices.Contains(model.JobStatuses, f.Status) {
+        return model.Page[model.Job]{}, apperr.Validation("status must be one of: " + strings.Join(model.JobStatuses, ", "))
+    }
+    return s.jobs.List(ctx, p, f)
+}
+
+func (s *jobService) Get(ctx context.Context, id string) (*model.Job, error) {
+    if uuid.Validate(id) != nil {
+        return nil, apperr.NotFound("job not found")
+    }
+    return s.jobs.GetByID(ctx, id)
+}
+
+func (s *jobService) Requeue(ctx context.Context, id string) (*model.Job, error) {
+    if uuid.Validate(id) != nil {
+        return nil, apperr.NotFound("job not found")
+    }
+    j, err := s.jobs.Requeue(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "job requeued", "job_id", id, "kind", j.Kind)
+    return j, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestJobService_ListAndRequeue(t *testing.T) {
+    ctx := context.Background()
+    jobs := repo.NewMemoryJobRepo(repo.NewMemoryStore())
+    svc := NewJobService(jobs, logger.Discard())
+
+    job := &model.Job{Kind: "email", Payload: []byte(`{}`), MaxAttempts: 1}
+    require.NoError(t, jobs.Enqueue(ctx, job))
+
+    _, err := svc.List(ctx, model.PageRequest{Limit: 10}, model.JobFilter{Status: "failed"})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.Get(ctx, "not-a-uuid")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    _, err = svc.Requeue(ctx, job.ID)
+    require.ErrorIs(t, err, apperr.ErrConflict, "a pending job can't be requeued")
+
+    _, err = jobs.Claim(ctx, time.Now(), 1)
+    require.NoError(t, err)
+    require.NoError(t, jobs.Bury(ctx, job.ID, "smtp: 550 no such user"))
+    page, err := svc.List(ctx, model.PageRequest{Limit: 10}, model.JobFilter{Status: model.JobDead})
+    require.NoError(t, err)
+    require.Len(t, page.Items, 1)
+    require.Equal(t, "smtp: 550 no such user", page.Items[0].LastError)
+
+    requeued, err := svc.Requeue(ctx, job.ID)
+    require.NoError(t, err)
+    require.Equal(t, model.JobPending, requeued.Status)
+    require.Zero(t, requeued.Attempts)
+    require.Nil(t, requeued.FinishedAt)
+}
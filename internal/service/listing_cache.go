@@ -0,0 +1,72 @@
+package service
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// listingCache keeps the results of expensive listings for ttl, by key. An
+// expired entry is reloaded by the first caller to need it; concurrent
+// callers of the same key wait for that load rather than repeating it.
+// Expired entries are dropped at most once per ttl, so caches with a key
+// per user don't grow without bound.
+type listingCache[T any] struct {
+    ttl time.Duration
+
+    mu      sync.Mutex
+    entries map[string]*listingEntry[T]
+    sweptAt time.Time
+}
+
+type listingEntry[T any] struct {
+    mu       sync.Mutex
+    value    T
+    loadedAt time.Time
+}
+
+func newListingCache[T any](ttl time.Duration) *listingCache[T] {
+    return &listingCache[T]{ttl: ttl, entries: map[string]*listingEntry[T]{}}
+}
+
+func (c *listingCache[T]) get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+    c.mu.Lock()
+    e, ok := c.entries[key]
+    if !ok {
+        c.sweep()
+        e = &listingEntry[T]{}
+        c.entries[key] = e
+    }
+    c.mu.Unlock()
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    now := time.Now()
+    if !e.loadedAt.IsZero() && now.Sub(e.loadedAt) < c.ttl {
+        return e.value, nil
+    }
+    v, err := load(ctx)
+    if err != nil {
+        return v, err
+    }
+    e.value, e.loadedAt = v, now
+    return v, nil
+}
+
+// sweep drops the expired entries not being loaded. The caller holds c.mu.
+func (c *listingCache[T]) sweep() {
+    now := time.Now()
+    if now.Sub(c.sweptAt) < c.ttl {
+        return
+    }
+    c.sweptAt = now
+    for key, e := range c.entries {
+        if !e.mu.TryLock() {
+            continue
+        }
+        if now.Sub(e.loadedAt) >= c.ttl {
+            delete(c.entries, key)
+        }
+        e.mu.Unlock()
+    }
+}
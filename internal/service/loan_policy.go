@@ -0,0 +1,109 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// LoanPolicyService lets admins configure the loan limits that
+// BookingService.Borrow enforces.
+type LoanPolicyService interface {
+    // ListPolicies returns the effective policy for every role, stored or default.
+    ListPolicies(ctx context.Context) ([]model.LoanPolicy, error)
+    SetPolicy(ctx context.Context, role model.Role, req model.LoanPolicyRequest) (*model.LoanPolicy, error)
+    GetBookRestriction(ctx context.Context, bookID string) (model.BookLoanRestriction, error)
+    SetBookRestriction(ctx context.Context, bookID string, req model.BookLoanRestrictionRequest) (*model.BookLoanRestriction, error)
+    DeleteBookRestriction(ctx context.Context, bookID string) error
+}
+
+type loanPolicyService struct {
+    repo     repo.LoanPolicyRepo
+    duration model.LoanDuration
+    logger   *slog.Logger
+}
+
+// NewLoanPolicyService returns the loan policy service. Policies and
+// restrictions can't allow loans longer than duration does, or than
+// model.DefaultLoanDuration does when it is the zero value.
+func NewLoanPolicyService(r repo.LoanPolicyRepo, duration model.LoanDuration, logger *slog.Logger) LoanPolicyService {
+    if duration == (model.LoanDuration{}) {
+        duration = model.DefaultLoanDuration()
+    }
+    return &loanPolicyService{repo: r, duration: duration, logger: logger}
+}
+
+// checkMaxDays refuses a limit of days longer than the longest loan.
+func (s *loanPolicyService) checkMaxDays(days int) error {
+    if days > s.duration.MaxDays {
+        return apperr.Validation(fmt.Sprintf("max borrow days can't exceed the %d-day longest loan", s.duration.MaxDays))
+    }
+    return nil
+}
+
+func (s *loanPolicyService) ListPolicies(ctx context.Context) ([]model.LoanPolicy, error) {
+    stored, err := s.repo.ListPolicies(ctx)
+    if err != nil {
+        return nil, err
+    }
+    policies := make([]model.LoanPolicy, 0, len(model.Roles))
+    for _, role := range model.Roles {
+        policy := model.DefaultLoanPolicy(role)
+        for _, p := range stored {
+            if p.Role == role {
+                policy = p
+            }
+        }
+        policies = append(policies, policy)
+    }
+    return policies, nil
+}
+
+func (s *loanPolicyService) SetPolicy(ctx context.Context, role model.Role, req model.LoanPolicyRequest) (*model.LoanPolicy, error) {
+    if !role.Valid() {
+        return nil, apperr.Validation("role must be one of: " + model.RoleNames())
+    }
+    if err := s.checkMaxDays(req.MaxBorrowDays); err != nil {
+        return nil, err
+    }
+    policy := &model.LoanPolicy{
+        Role:              role,
+        MaxActiveBookings: req.MaxActiveBookings,
+        MaxBorrowDays:     req.MaxBorrowDays,
+    }
+    if err := s.repo.SavePolicy(ctx, policy); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "loan policy updated", "role", role,
+        "max_active_bookings", policy.MaxActiveBookings, "max_borrow_days", policy.MaxBorrowDays)
+    return policy, nil
+}
+
+func (s *loanPolicyService) GetBookRestriction(ctx context.Context, bookID string) (model.BookLoanRestriction, error) {
+    return s.repo.GetBookRestriction(ctx, bookID)
+}
+
+func (s *loanPolicyService) SetBookRestriction(ctx context.Context, bookID string, req model.BookLoanRestrictionRequest) (*model.BookLoanRestriction, error) {
+    if err := s.checkMaxDays(req.MaxBorrowDays); err != nil {
+        return nil, err
+    }
+    restriction := &model.BookLoanRestriction{
+        BookID:        bookID,
+        ReferenceOnly: req.ReferenceOnly,
+        MaxBorrowDays: req.MaxBorrowDays,
+    }
+    if err := s.repo.SaveBookRestriction(ctx, restriction); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "book loan restriction updated", "book_id", bookID,
+        "reference_only", restriction.ReferenceOnly, "max_borrow_days", restriction.MaxBorrowDays)
+    return restriction, nil
+}
+
+func (s *loanPolicyService) DeleteBookRestriction(ctx context.Context, bookID string) error {
+    return s.repo.DeleteBookRestriction(ctx, bookID)
+}
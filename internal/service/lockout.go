@@ -0,0 +1,85 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+)
+
+// LockoutPolicy configures brute-force protection on login. Failures are
+// counted per username and per client IP; reaching the limit for either
+// locks it for Duration. A zero limit disables that check.
+type LockoutPolicy struct {
+    MaxFailures      int
+    MaxFailuresPerIP int
+    Window           time.Duration
+    Duration         time.Duration
+}
+
+func (p LockoutPolicy) enabled() bool {
+    return p.MaxFailures > 0 || p.MaxFailuresPerIP > 0
+}
+
+// LockedError is returned by Login while the account or client is locked
+// out. It classifies as apperr.ErrLocked.
+type LockedError struct {
+    Until time.Time
+}
+
+func (e *LockedError) Error() string {
+    return fmt.Sprintf("too many failed login attempts; try again after %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+    return apperr.ErrLocked
+}
+
+// RetryAfter is how long the caller has to wait, rounded up to whole seconds.
+func (e *LockedError) RetryAfter(now time.Time) time.Duration {
+    d := e.Until.Sub(now)
+    if d <= 0 {
+        return 0
+    }
+    return d.Truncate(time.Second) + time.Second
+}
+
+type lockoutKey struct {
+    key   string
+    limit int
+}
+
+func (s *userService) lockoutKeys(username, clientIP string) []lockoutKey {
+    var keys []lockoutKey
+    if s.lockout.MaxFailures > 0 {
+        keys = append(keys, lockoutKey{"user:" + strings.ToLower(username), s.lockout.MaxFailures})
+    }
+    if s.lockout.MaxFailuresPerIP > 0 && clientIP != "" {
+        keys = append(keys, lockoutKey{"ip:" + clientIP, s.lockout.MaxFailuresPerIP})
+    }
+    return keys
+}
+
+// recordFailure counts a failed login against every key and locks those that
+// reached their limit. It returns the lockout expiry, or the zero time.
+func (s *userService) recordFailure(ctx context.Context, keys []lockoutKey) (time.Time, error) {
+    now := time.Now().UTC()
+    var until time.Time
+    for _, k := range keys {
+        failures, err := s.attempts.RecordFailure(ctx, k.key, now, now.Add(-s.lockout.Window))
+        if err != nil {
+            return time.Time{}, err
+        }
+        if failures < k.limit {
+            continue
+        }
+        until = now.Add(s.lockout.Duration)
+        if err := s.attempts.Lock(ctx, k.key, until); err != nil {
+            return time.Time{}, err
+        }
+        s.logger.WarnContext(ctx, "login locked out", "key", k.key, "failures", failures, "until", until)
+    }
+    return until, nil
+}
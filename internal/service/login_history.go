@@ -0,0 +1,55 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// LoginHistoryService keeps a record of users' successful logins and emails
+// them when one comes from a device or address they hadn't used before.
+type LoginHistoryService interface {
+    // Record stores a login by u from userAgent at ip, emailing u if it
+    // is from a new device.
+    Record(ctx context.Context, u *model.User, userAgent, ip string) (*model.LoginRecord, error)
+    ListMine(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.LoginRecord], error)
+}
+
+type loginHistoryService struct {
+    logins   repo.LoginHistoryRepo
+    notifier *notify.Notifier
+    logger   *slog.Logger
+}
+
+// NewLoginHistoryService returns the login history service. notifier may
+// be nil, in which case logins from new devices are recorded but nobody is
+// emailed.
+func NewLoginHistoryService(logins repo.LoginHistoryRepo, notifier *notify.Notifier, logger *slog.Logger) LoginHistoryService {
+    return &loginHistoryService{logins: logins, notifier: notifier, logger: logger}
+}
+
+// Record stores the login before emailing, so a failed email doesn't lose
+// it; the email error is returned with the stored record.
+func (s *loginHistoryService) Record(ctx context.Context, u *model.User, userAgent, ip string) (*model.LoginRecord, error) {
+    l := &model.LoginRecord{UserID: u.ID, IP: ip, UserAgent: userAgent}
+    if err := s.logins.Record(ctx, l); err != nil {
+        return nil, err
+    }
+    if !l.NewDevice || s.notifier == nil || u.Email == "" {
+        return l, nil
+    }
+    s.logger.InfoContext(ctx, "login from new device", "user_id", u.ID, "ip", ip)
+    return l, s.notifier.Send(ctx, u.Email, "", notify.TemplateNewDeviceLogin, notify.NewDeviceLogin{
+        Username:   u.Username,
+        IP:         ip,
+        UserAgent:  userAgent,
+        LoggedInAt: l.CreatedAt,
+    })
+}
+
+func (s *loginHistoryService) ListMine(ctx context.Context, userID string, p model.PageRequest) (model.Page[model.LoginRecord], error) {
+    return s.logins.List(ctx, userID, p)
+}
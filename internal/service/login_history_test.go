@@ -0,0 +1,62 @@
+package service
+
+import (
+    "context"
+    "testing"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestLoginHistoryService_AlertsOnNewDevices(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := NewLoginHistoryService(repos.Logins, newTestNotifier(t, mailer), logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+
+    first, err := svc.Record(ctx, ada, "Firefox", "203.0.113.7")
+    require.NoError(t, err)
+    require.False(t, first.NewDevice, "the first login was new")
+    again, err := svc.Record(ctx, ada, "Firefox", "203.0.113.7")
+    require.NoError(t, err)
+    require.False(t, again.NewDevice)
+    require.Empty(t, mailer.sent)
+
+    otherIP, err := svc.Record(ctx, ada, "Firefox", "198.51.100.2")
+    require.NoError(t, err)
+    require.True(t, otherIP.NewDevice)
+    otherAgent, err := svc.Record(ctx, ada, "curl/8.0", "203.0.113.7")
+    require.NoError(t, err)
+    require.True(t, otherAgent.NewDevice)
+
+    require.Len(t, mailer.sent, 2)
+    require.Equal(t, "ada@example.com", mailer.sent[0].To)
+    require.Contains(t, mailer.sent[0].HTML, "198.51.100.2")
+    require.Contains(t, mailer.sent[1].HTML, "curl/8.0")
+
+    logins, err := svc.ListMine(ctx, ada.ID, model.PageRequest{Limit: 10})
+    require.NoError(t, err)
+    require.Equal(t, 4, logins.Total)
+
+    _, err = svc.Record(ctx, &model.User{ID: "missing"}, "Firefox", "203.0.113.7")
+    require.Error(t, err)
+}
+
+func TestLoginHistoryService_RecordsWithoutNotifier(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewLoginHistoryService(repos.Logins, nil, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    _, err := svc.Record(ctx, ada, "Firefox", "203.0.113.7")
+    require.NoError(t, err)
+    l, err := svc.Record(ctx, ada, "Safari", "203.0.113.7")
+    require.NoError(t, err)
+    require.True(t, l.NewDevice)
+}
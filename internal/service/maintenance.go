@@ -0,0 +1,108 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "sync"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// MaintenanceService switches the API's maintenance mode. The mode is
+// stored, so every instance follows it within the cache TTL.
+type MaintenanceService interface {
+    Get(ctx context.Context) (model.Maintenance, error)
+    // Set stores the mode and records who changed it in the audit log.
+    Set(ctx context.Context, actorID string, req model.MaintenanceRequest) (*model.Maintenance, error)
+    // Current is the mode requests are checked against. It is reloaded
+    // once older than the TTL; when that fails the last known mode stays.
+    Current(ctx context.Context) model.Maintenance
+}
+
+type maintenanceService struct {
+    repo   repo.MaintenanceRepo
+    audit  repo.AuditRepo
+    tx     repo.TxManager
+    forced bool
+    ttl    time.Duration
+    logger *slog.Logger
+
+    mu       sync.Mutex
+    current  model.Maintenance
+    loadedAt time.Time
+}
+
+// NewMaintenanceService returns the service. When forced is set, as by
+// MAINTENANCE_MODE, maintenance mode stays on whatever admins set.
+func NewMaintenanceService(r repo.MaintenanceRepo, audit repo.AuditRepo, tx repo.TxManager, forced bool, ttl time.Duration, logger *slog.Logger) MaintenanceService {
+    return &maintenanceService{repo: r, audit: audit, tx: tx, forced: forced, ttl: ttl, logger: logger}
+}
+
+func (s *maintenanceService) Get(ctx context.Context) (model.Maintenance, error) {
+    m, err := s.repo.Get(ctx)
+    if err != nil {
+        return model.Maintenance{}, err
+    }
+    return s.apply(m), nil
+}
+
+func (s *maintenanceService) Set(ctx context.Context, actorID string, req model.MaintenanceRequest) (*model.Maintenance, error) {
+    m := &model.Maintenance{
+        Enabled:           *req.Enabled,
+        Message:           req.Message,
+        RetryAfterSeconds: req.RetryAfterSeconds,
+        UpdatedBy:         actorID,
+    }
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Save(ctx, m); err != nil {
+            return err
+        }
+        return s.audit.Record(ctx, &model.AuditEntry{
+            ActorID:    actorID,
+            Action:     model.AuditMaintenanceSet,
+            TargetType: "maintenance",
+            TargetID:   "api",
+            Details: map[string]interface{}{
+                "enabled": m.Enabled,
+                "message": m.Message,
+            },
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    s.mu.Lock()
+    s.current, s.loadedAt = *m, time.Now()
+    s.mu.Unlock()
+    s.logger.WarnContext(ctx, "maintenance mode set", "enabled", m.Enabled, "by", actorID)
+
+    applied := s.apply(*m)
+    return &applied, nil
+}
+
+func (s *maintenanceService) Current(ctx context.Context) model.Maintenance {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := time.Now()
+    if s.loadedAt.IsZero() || now.Sub(s.loadedAt) >= s.ttl {
+        m, err := s.repo.Get(ctx)
+        if err != nil {
+            s.logger.WarnContext(ctx, "load maintenance mode failed, keeping the last known mode", "error", err)
+        } else {
+            s.current = m
+        }
+        s.loadedAt = now
+    }
+    return s.apply(s.current)
+}
+
+func (s *maintenanceService) apply(m model.Maintenance) model.Maintenance {
+    if s.forced {
+        m.Enabled, m.Forced = true, true
+    }
+    return m
+}
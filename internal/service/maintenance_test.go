@@ -0,0 +1,70 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+// flakyMaintenanceRepo fails Get while down is set.
+type flakyMaintenanceRepo struct {
+    repo.MaintenanceRepo
+    down bool
+}
+
+func (r *flakyMaintenanceRepo) Get(ctx context.Context) (model.Maintenance, error) {
+    if r.down {
+        return model.Maintenance{}, errors.New("connection refused")
+    }
+    return r.MaintenanceRepo.Get(ctx)
+}
+
+func TestMaintenanceService_SetIsSharedAndAudited(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    audit := &recordingAudit{}
+    ctx := context.Background()
+    this := NewMaintenanceService(repos.Maintenance, audit, repos.Tx, false, time.Hour, logger.Discard())
+    other := NewMaintenanceService(repos.Maintenance, audit, repos.Tx, false, 0, logger.Discard())
+
+    require.False(t, this.Current(ctx).Enabled)
+
+    on := true
+    m, err := this.Set(ctx, "admin-1", model.MaintenanceRequest{Enabled: &on, Message: "Upgrading", RetryAfterSeconds: 120})
+    require.NoError(t, err)
+    require.True(t, m.Enabled)
+    require.Equal(t, "admin-1", m.UpdatedBy)
+
+    require.True(t, this.Current(ctx).Enabled, "the instance that set it sees it at once")
+    require.Equal(t, "Upgrading", other.Current(ctx).Message, "other instances reload it")
+
+    require.Len(t, audit.entries, 1)
+    require.Equal(t, model.AuditMaintenanceSet, audit.entries[0].Action)
+    require.Equal(t, "admin-1", audit.entries[0].ActorID)
+}
+
+func TestMaintenanceService_KeepsLastKnownModeAndForced(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    ctx := context.Background()
+    on := true
+    require.NoError(t, repos.Maintenance.Save(ctx, &model.Maintenance{Enabled: on}))
+
+    flaky := &flakyMaintenanceRepo{MaintenanceRepo: repos.Maintenance}
+    svc := NewMaintenanceService(flaky, &recordingAudit{}, repos.Tx, false, 0, logger.Discard())
+    require.True(t, svc.Current(ctx).Enabled)
+    flaky.down = true
+    require.True(t, svc.Current(ctx).Enabled, "a failed reload keeps the last known mode")
+
+    forced := NewMaintenanceService(repos.Maintenance, &recordingAudit{}, repos.Tx, true, 0, logger.Discard())
+    off := false
+    m, err := forced.Set(ctx, "admin-1", model.MaintenanceRequest{Enabled: &off})
+    require.NoError(t, err)
+    require.True(t, m.Enabled)
+    require.True(t, m.Forced)
+    require.True(t, forced.Current(ctx).Enabled)
+}
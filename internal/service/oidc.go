@@ -0,0 +1,237 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
+)
+
+// OIDCService logs users in with accounts at external identity providers.
+type OIDCService interface {
+    // Login returns the user an external identity logs in as. An identity
+    // seen before logs in as the user it was linked to. A new one is linked
+    // to the user with its verified email, or, failing that, to a user
+    // provisioned for it. When the provider's groups are mapped to roles,
+    // the user's role is brought in line with id.Groups. Suspended users
+    // are refused with a forbidden error. inviteCode is only used, and
+    // while registration is invite-only required, to provision a user.
+    Login(ctx context.Context, id model.ExternalIdentity, inviteCode string) (*model.User, error)
+}
+
+type oidcService struct {
+    users       repo.UserRepo
+    identities  repo.IdentityRepo
+    revocations repo.TokenRevocationRepo
+    outbox      repo.OutboxRepo
+    invites     repo.InviteCodeRepo
+    roles       map[string]map[string]model.Role
+    tx          repo.TxManager
+    logger      *slog.Logger
+}
+
+// NewOIDCService builds the single sign-on service. roles maps, per
+// provider, the groups of its accounts to the role their members get;
+// providers left out don't change roles. revocations may be nil, in which
+// case a user whose role changes keeps the tokens they hold until these
+// expire, and outbox too, in which case provisioned users are not
+// announced. With invites set, registration is invite-only: provisioning a
+// user uses up an invite code, as RequireInviteCodes does for Register.
+func NewOIDCService(users repo.UserRepo, identities repo.IdentityRepo, revocations repo.TokenRevocationRepo, outbox repo.OutboxRepo, invites repo.InviteCodeRepo, roles map[string]map[string]model.Role, tx repo.TxManager, logger *slog.Logger) OIDCService {
+    normalized := make(map[string]map[string]model.Role, len(roles))
+    for provider, groups := range roles {
+        normalized[provider] = make(map[string]model.Role, len(groups))
+        for group, role := range groups {
+            normalized[provider][strings.ToLower(group)] = model.NormalizeRole(string(role))
+        }
+    }
+    return &oidcService{users: users, identities: identities, revocations: revocations, outbox: outbox, invites: invites, roles: normalized, tx: tx, logger: logger}
+}
+
+// maxUsernameAttempts bounds the suffixes tried when a provisioned user's
+// preferred username is taken.
+const maxUsernameAttempts = 20
+
+func (s *oidcService) Login(ctx context.Context, id model.ExternalIdentity, inviteCode string) (*model.User, error) {
+    if id.Provider == "" || id.Subject == "" {
+        return nil, apperr.Validation("identity has no provider or subject")
+    }
+
+    var u *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        if u, err = s.resolve(ctx, id, inviteCode); err != nil {
+            return err
+        }
+        u, err = s.syncRole(ctx, u, id)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if u.IsSuspended(time.Now()) {
+        s.logger.WarnContext(ctx, "login refused: account suspended", "user_id", u.ID, "provider", id.Provider)
+        return nil, apperr.Forbidden("account is suspended")
+    }
+    u.Password = ""
+    return u, nil
+}
+
+// resolve finds, links or provisions the user for id.
+func (s *oidcService) resolve(ctx context.Context, id model.ExternalIdentity, inviteCode string) (*model.User, error) {
+    userID, err := s.identities.UserID(ctx, id.Provider, id.Subject)
+    if err == nil {
+        return s.users.GetByID(ctx, userID)
+    }
+    if !errors.Is(err, apperr.ErrNotFound) {
+        return nil, err
+    }
+
+    // An unverified email may belong to someone else, so it neither links
+    // to their account nor claims the address for a new one.
+    if id.Email == "" || !id.EmailVerified {
+        return nil, apperr.Forbidden("the identity provider has not verified an email address for this account")
+    }
+    id.Email = validate.NormalizeEmail(id.Email)
+
+    u, err := s.users.GetByEmail(ctx, id.Email)
+    switch {
+    case err == nil:
+        s.logger.InfoContext(ctx, "external identity linked by email", "user_id", u.ID, "provider", id.Provider)
+    case errors.Is(err, apperr.ErrNotFound):
+        if u, err = s.provision(ctx, id, inviteCode); err != nil {
+            return nil, err
+        }
+        s.logger.InfoContext(ctx, "user provisioned from external identity", "user_id", u.ID, "provider", id.Provider)
+    default:
+        return nil, err
+    }
+
+    link := &model.UserIdentity{Provider: id.Provider, Subject: id.Subject, UserID: u.ID, Email: id.Email}
+    if err := s.identities.Link(ctx, link); err != nil {
+        return nil, err
+    }
+    return u, nil
+}
+
+// syncRole gives u the most privileged role any of id's groups maps to, or
+// the user role when none does, if the provider's groups are mapped at all.
+// The last active admin keeps their role, so a mapping that leaves out the
+// admins' group can't lock every admin out.
+func (s *oidcService) syncRole(ctx context.Context, u *model.User, id model.ExternalIdentity) (*model.User, error) {
+    groups, ok := s.roles[id.Provider]
+    if !ok {
+        return u, nil
+    }
+    role := model.RoleUser
+    for _, g := range id.Groups {
+        if mapped, ok := groups[strings.ToLower(g)]; ok && slices.Index(model.Roles, mapped) > slices.Index(model.Roles, role) {
+            role = mapped
+        }
+    }
+    if role == u.Role {
+        return u, nil
+    }
+
+    if u.Role == model.RoleAdmin && u.Status == model.UserStatusActive {
+        admins, err := s.users.CountActiveAdminsForUpdate(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if admins <= 1 {
+            s.logger.WarnContext(ctx, "role sync skipped: last active admin", "user_id", u.ID, "provider", id.Provider, "role", role)
+            return u, nil
+        }
+    }
+    updated, err := s.users.Update(ctx, u.ID, model.UpdateUserPatch{Role: &role})
+    if err != nil {
+        return nil, err
+    }
+    // Tokens carry the role they were issued with, so the old ones go. The
+    // cut-off is a second back because revocations are kept to the second,
+    // and the token this login is about to get must survive it.
+    if s.revocations != nil {
+        if err := s.revocations.Revoke(ctx, u.ID, time.Now().UTC().Add(-time.Second)); err != nil {
+            return nil, err
+        }
+    }
+    s.logger.InfoContext(ctx, "role synced from identity provider", "user_id", u.ID, "provider", id.Provider, "from", u.Role, "to", role)
+    return updated, nil
+}
+
+// provision creates a user for id without a password; they log in through
+// the provider only. The username is the provider's, suffixed with a number
+// if it is taken. While registration is invite-only, it uses up inviteCode.
+func (s *oidcService) provision(ctx context.Context, id model.ExternalIdentity, inviteCode string) (*model.User, error) {
+    if s.invites != nil {
+        c, err := redeemInviteCode(ctx, s.invites, inviteCode, s.logger)
+        if err != nil {
+            return nil, err
+        }
+        s.logger.InfoContext(ctx, "invite code redeemed", "invite_code_id", c.ID, "provider", id.Provider, "uses", c.Uses)
+    }
+    base := usernameFrom(id)
+    for i := 1; i <= maxUsernameAttempts; i++ {
+        username := base
+        if i > 1 {
+            username = fmt.Sprintf("%s%d", base, i)
+        }
+        _, err := s.users.GetByUsername(ctx, username)
+        if err == nil {
+            continue
+        }
+        if !errors.Is(err, apperr.ErrNotFound) {
+            return nil, err
+        }
+
+        u := &model.User{
+            Username: username,
+            Email:    id.Email,
+            Role:     model.RoleUser,
+            BranchID: tenant.BranchID(ctx),
+        }
+        if err := s.users.Create(ctx, u); err != nil {
+            return nil, err
+        }
+        if err := recordEvent(ctx, s.outbox, model.EventUserRegistered, u.ID, u); err != nil {
+            return nil, err
+        }
+        return u, nil
+    }
+    return nil, apperr.Conflict("no free username for this account; register one instead")
+}
+
+// usernameFrom makes a valid username from the provider's handle, or the
+// email's local part when there is none.
+func usernameFrom(id model.ExternalIdentity) string {
+    name := id.Username
+    if name == "" {
+        name, _, _ = strings.Cut(id.Email, "@")
+    }
+    name = strings.Map(func(r rune) rune {
+        switch {
+        case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+            return r
+        case r >= 'A' && r <= 'Z':
+            return r + ('a' - 'A')
+        }
+        return -1
+    }, name)
+    if len(name) > 40 {
+        name = name[:40]
+    }
+    if len(name) < 3 {
+        name = id.Provider + "-" + name
+    }
+    return name
+}
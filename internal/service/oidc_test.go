@@ -0,0 +1,107 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func newTestOIDCService() (OIDCService, repo.Repos) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    return NewOIDCService(repos.Users, repos.Identities, repos.Revocations, repos.Outbox, nil, nil, repos.Tx, logger.Discard()), repos
+}
+
+func TestOIDCService_ProvisionsThenReuses(t *testing.T) {
+    svc, repos := newTestOIDCService()
+    ctx := context.Background()
+    require.NoError(t, repos.Users.Create(ctx, &model.User{Username: "octocat", Email: "someone@example.com", Role: "user"}))
+
+    id := model.ExternalIdentity{Provider: "github", Subject: "42", Email: "octocat@example.com", EmailVerified: true, Username: "OctoCat"}
+    u, err := svc.Login(ctx, id, "")
+    require.NoError(t, err)
+    require.Equal(t, "octocat2", u.Username, "a taken username gets a suffix")
+    require.Equal(t, "octocat@example.com", u.Email)
+    require.Equal(t, model.RoleUser, u.Role)
+
+    // The same account logs in as the same user, even with a new email.
+    id.Email = "new@example.com"
+    again, err := svc.Login(ctx, id, "")
+    require.NoError(t, err)
+    require.Equal(t, u.ID, again.ID)
+}
+
+func TestOIDCService_LinksByVerifiedEmail(t *testing.T) {
+    svc, repos := newTestOIDCService()
+    ctx := context.Background()
+    existing := &model.User{Username: "alice", Email: "alice@example.com", Password: "hash", Role: "admin"}
+    require.NoError(t, repos.Users.Create(ctx, existing))
+
+    _, err := svc.Login(ctx, model.ExternalIdentity{Provider: "google", Subject: "g-1", Email: "alice@example.com"}, "")
+    require.ErrorIs(t, err, apperr.ErrForbidden, "an unverified email links nothing")
+
+    u, err := svc.Login(ctx, model.ExternalIdentity{Provider: "google", Subject: "g-1", Email: "alice@example.com", EmailVerified: true}, "")
+    require.NoError(t, err)
+    require.Equal(t, existing.ID, u.ID)
+    require.Empty(t, u.Password)
+
+    linked, err := repos.Identities.UserID(ctx, "google", "g-1")
+    require.NoError(t, err)
+    require.Equal(t, existing.ID, linked)
+}
+
+func TestOIDCService_RefusesSuspendedUser(t *testing.T) {
+    svc, repos := newTestOIDCService()
+    ctx := context.Background()
+    u := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, u))
+    until := time.Now().Add(time.Hour)
+    _, err := repos.Users.Update(ctx, u.ID, model.UpdateUserPatch{Status: model.Ptr(model.UserStatusSuspended), SuspendedUntil: &until})
+    require.NoError(t, err)
+
+    _, err = svc.Login(ctx, model.ExternalIdentity{Provider: "google", Subject: "g-1", Email: "alice@example.com", EmailVerified: true}, "")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+}
+
+func TestOIDCService_SyncsRoleFromGroups(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewOIDCService(repos.Users, repos.Identities, repos.Revocations, repos.Outbox, nil, map[string]map[string]model.Role{
+        "github": {"acme/Admins": model.RoleAdmin, "acme/staff": model.RoleUser},
+    }, repos.Tx, logger.Discard())
+    ctx := context.Background()
+
+    id := model.ExternalIdentity{Provider: "github", Subject: "42", Email: "octocat@example.com", EmailVerified: true, Username: "octocat",
+        Groups: []string{"acme", "acme/staff", "acme/admins"}}
+    u, err := svc.Login(ctx, id, "")
+    require.NoError(t, err)
+    require.Equal(t, model.RoleAdmin, u.Role, "the most privileged mapped group wins")
+
+    // The last admin keeps their role when they leave the admins' group.
+    id.Groups = []string{"acme/staff"}
+    u, err = svc.Login(ctx, id, "")
+    require.NoError(t, err)
+    require.Equal(t, model.RoleAdmin, u.Role)
+
+    require.NoError(t, repos.Users.Create(ctx, &model.User{Username: "root", Email: "root@example.com", Role: model.RoleAdmin}))
+    u, err = svc.Login(ctx, id, "")
+    require.NoError(t, err)
+    require.Equal(t, model.RoleUser, u.Role)
+    revoked, err := repos.Revocations.RevokedAt(ctx, u.ID)
+    require.NoError(t, err)
+    require.False(t, revoked.IsZero(), "tokens issued with the old role still work")
+
+    // Providers without a mapping leave roles alone.
+    google, err := svc.Login(ctx, model.ExternalIdentity{Provider: "google", Subject: "g-1", Email: "root@example.com", EmailVerified: true}, "")
+    require.NoError(t, err)
+    require.Equal(t, model.RoleAdmin, google.Role)
+}
+
+func TestUsernameFrom(t *testing.T) {
+    require.Equal(t, "jane.doe", usernameFrom(model.ExternalIdentity{Provider: "google", Email: "Jane.Doe@example.com"}))
+    require.Equal(t, "github-a", usernameFrom(model.ExternalIdentity{Provider: "github", Username: "a!"}))
+}
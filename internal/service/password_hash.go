@@ -0,0 +1,130 @@
+package service
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/argon2"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing schemes. A hash records its scheme and parameters, so
+// hashes made under an earlier setting still verify after it changes.
+const (
+    HashBcrypt   = "bcrypt"
+    HashArgon2id = "argon2id"
+)
+
+// Argon2Params are the Argon2id costs: Memory in KiB, Time passes over it,
+// and Threads lanes hashed in parallel.
+type Argon2Params struct {
+    Memory  uint32
+    Time    uint32
+    Threads uint8
+}
+
+// PasswordHasher hashes new passwords with Scheme and verifies hashes of
+// either scheme. The zero value hashes with bcrypt at its default cost.
+type PasswordHasher struct {
+    Scheme     string
+    BcryptCost int
+    Argon2     Argon2Params
+}
+
+// DefaultPasswordHasher hashes with bcrypt at its default cost, and with the
+// Argon2id parameters RFC 9106 recommends where memory is constrained when
+// Scheme is switched to HashArgon2id.
+func DefaultPasswordHasher() PasswordHasher {
+    return PasswordHasher{
+        Scheme:     HashBcrypt,
+        BcryptCost: bcrypt.DefaultCost,
+        Argon2:     Argon2Params{Memory: 64 * 1024, Time: 3, Threads: 4},
+    }
+}
+
+const (
+    argon2SaltBytes = 16
+    argon2KeyBytes  = 32
+)
+
+// Hash returns the hash of password under the configured scheme: a bcrypt
+// hash, or an Argon2id one in the PHC string format,
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func (h PasswordHasher) Hash(password string) (string, error) {
+    if h.Scheme != HashArgon2id {
+        hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost())
+        return string(hashed), err
+    }
+    salt := make([]byte, argon2SaltBytes)
+    if _, err := rand.Read(salt); err != nil {
+        return "", err
+    }
+    p := h.Argon2
+    key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, argon2KeyBytes)
+    return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+        base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Matches reports whether password hashes to hash, whichever scheme made
+// it. Malformed hashes match nothing.
+func (h PasswordHasher) Matches(hash, password string) bool {
+    if !strings.HasPrefix(hash, "$argon2id$") {
+        return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+    }
+    p, salt, key, err := parseArgon2id(hash)
+    if err != nil {
+        return false
+    }
+    got := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+    return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+// NeedsRehash reports whether hash was made under another scheme or other
+// parameters than h hashes with now.
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+    if h.Scheme == HashArgon2id {
+        p, _, _, err := parseArgon2id(hash)
+        return err != nil || p != h.Argon2
+    }
+    cost, err := bcrypt.Cost([]byte(hash))
+    return err != nil || cost != h.bcryptCost()
+}
+
+func (h PasswordHasher) bcryptCost() int {
+    if h.BcryptCost == 0 {
+        return bcrypt.DefaultCost
+    }
+    return h.BcryptCost
+}
+
+var errMalformedArgon2id = errors.New("malformed argon2id hash")
+
+// parseArgon2id splits a hash made by Hash into its parameters, salt and key.
+func parseArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+    // "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+    parts := strings.Split(hash, "$")
+    if len(parts) != 6 || parts[1] != HashArgon2id {
+        return Argon2Params{}, nil, nil, errMalformedArgon2id
+    }
+    var version int
+    if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+        return Argon2Params{}, nil, nil, errMalformedArgon2id
+    }
+    var p Argon2Params
+    if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+        return Argon2Params{}, nil, nil, errMalformedArgon2id
+    }
+    salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+    if err != nil {
+        return Argon2Params{}, nil, nil, errMalformedArgon2id
+    }
+    key, err := base64.RawStdEncoding.DecodeString(parts[5])
+    if err != nil || len(key) == 0 {
+        return Argon2Params{}, nil, nil, errMalformedArgon2id
+    }
+    return p, salt, key, nil
+}
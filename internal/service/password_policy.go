@@ -0,0 +1,131 @@
+package service
+
+import (
+    "bufio"
+    _ "embed"
+    "fmt"
+    "os"
+    "strings"
+    "unicode"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords would be
+// silently truncated or rejected when hashing.
+const maxPasswordBytes = 72
+
+// PasswordPolicy is enforced whenever a password is set, on registration and
+// on change. Existing passwords are not re-checked at login, though their
+// hashes are brought up to date.
+type PasswordPolicy struct {
+    MinLength     int
+    RequireUpper  bool
+    RequireLower  bool
+    RequireDigit  bool
+    RequireSymbol bool
+    // Denylist holds lower-cased passwords that are rejected outright.
+    Denylist map[string]struct{}
+    // Hasher hashes the passwords set, and rehashes older hashes at login.
+    Hasher PasswordHasher
+}
+
+// DefaultPasswordPolicy requires 8 characters mixing upper case, lower case
+// and digits, and rejects a built-in list of commonly breached passwords.
+func DefaultPasswordPolicy() PasswordPolicy {
+    p := PasswordPolicy{
+        MinLength:    8,
+        RequireUpper: true,
+        RequireLower: true,
+        RequireDigit: true,
+        Hasher:       DefaultPasswordHasher(),
+    }
+    p.Deny(parseDenylist(commonPasswords))
+    return p
+}
+
+// Deny adds passwords to the denylist.
+func (p *PasswordPolicy) Deny(passwords []string) {
+    if p.Denylist == nil {
+        p.Denylist = make(map[string]struct{}, len(passwords))
+    }
+    for _, pw := range passwords {
+        p.Denylist[strings.ToLower(pw)] = struct{}{}
+    }
+}
+
+// ReadDenylistFile reads one password per line, skipping blank lines and
+// lines starting with #.
+func ReadDenylistFile(path string) ([]string, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("read password denylist: %w", err)
+    }
+    return parseDenylist(string(b)), nil
+}
+
+func parseDenylist(s string) []string {
+    var out []string
+    sc := bufio.NewScanner(strings.NewReader(s))
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        out = append(out, line)
+    }
+    return out
+}
+
+// Check reports every rule password breaks in a single validation error, so
+// the user can fix them all at once. username is rejected as a password.
+func (p PasswordPolicy) Check(password, username string) error {
+    var problems []string
+    if n := len([]rune(password)); n < p.MinLength {
+        problems = append(problems, fmt.Sprintf("be at least %d characters", p.MinLength))
+    }
+    if len(password) > maxPasswordBytes {
+        problems = append(problems, fmt.Sprintf("be at most %d bytes", maxPasswordBytes))
+    }
+
+    var upper, lower, digit, symbol bool
+    for _, r := range password {
+        switch {
+        case unicode.IsUpper(r):
+            upper = true
+        case unicode.IsLower(r):
+            lower = true
+        case unicode.IsDigit(r):
+            digit = true
+        case unicode.IsPunct(r) || unicode.IsSymbol(r):
+            symbol = true
+        }
+    }
+    if p.RequireUpper && !upper {
+        problems = append(problems, "contain an upper-case letter")
+    }
+    if p.RequireLower && !lower {
+        problems = append(problems, "contain a lower-case letter")
+    }
+    if p.RequireDigit && !digit {
+        problems = append(problems, "contain a digit")
+    }
+    if p.RequireSymbol && !symbol {
+        problems = append(problems, "contain a symbol")
+    }
+
+    lowered := strings.ToLower(password)
+    if _, denied := p.Denylist[lowered]; denied {
+        problems = append(problems, "not be a commonly used password")
+    } else if username != "" && lowered == strings.ToLower(username) {
+        problems = append(problems, "not be the same as the username")
+    }
+
+    if len(problems) == 0 {
+        return nil
+    }
+    return apperr.Validation("password must " + strings.Join(problems, ", "))
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "log/slog"
+
+    "github.com/google/uuid"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// ReadingListService manages users' reading lists. Every method but Shared
+// acts on the lists of userID only; other users' lists are NotFound.
+type ReadingListService interface {
+    List(ctx context.Context, userID string) ([]model.ReadingList, error)
+    Get(ctx context.Context, userID, id string) (*model.ReadingList, error)
+    Create(ctx context.Context, userID string, req *model.ReadingListRequest) (*model.ReadingList, error)
+    Rename(ctx context.Context, userID, id string, req *model.ReadingListRequest) (*model.ReadingList, error)
+    Delete(ctx context.Context, userID, id string) error
+    AddBook(ctx context.Context, userID, id, bookID string) (*model.ReadingList, error)
+    RemoveBook(ctx context.Context, userID, id, bookID string) (*model.ReadingList, error)
+    // Share makes the list readable by anyone with its share URL, keeping
+    // the URL it already has.
+    Share(ctx context.Context, userID, id string) (*model.ReadingList, error)
+    // Unshare stops sharing the list; its old share URL stops working.
+    Unshare(ctx context.Context, userID, id string) (*model.ReadingList, error)
+    // Shared returns the list shared under token, without its owner.
+    Shared(ctx context.Context, token string) (*model.ReadingList, error)
+    // Favorites returns the user's favorites list, making it if needed.
+    Favorites(ctx context.Context, userID string) (*model.ReadingList, error)
+}
+
+type readingListService struct {
+    repo     repo.ReadingListRepo
+    books    repo.BookRepo
+    shareURL string
+    logger   *slog.Logger
+}
+
+// NewReadingListService returns a ReadingListService whose share URLs are
+// shareURL followed by the list's token.
+func NewReadingListService(r repo.ReadingListRepo, books repo.BookRepo, shareURL string, logger *slog.Logger) ReadingListService {
+    return &readingListService{repo: r, books: books, shareURL: shareURL, logger: logger}
+}
+
+var errListNotFound = apperr.NotFound("reading list not found")
+
+func (s *readingListService) List(ctx context.Context, userID string) ([]model.ReadingList, error) {
+    lists, err := s.repo.ListByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    for i := range lists {
+        s.ownerView(&lists[i])
+    }
+    return lists, nil
+}
+
+func (s *readingListService) Get(ctx context.Context, userID, id string) (*model.ReadingList, error) {
+    l, err := s.owned(ctx, userID, id)
+    if err != nil {
+        return nil, err
+    }
+    s.ownerView(l)
+    return l, nil
+}
+
+// owned returns the list if userID owns it.
+func (s *readingListService) owned(ctx context.Context, userID, id string) (*model.ReadingList, error) {
+    if uuid.Validate(id) != nil {
+        return nil, errListNotFound
+    }
+    l, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if l.UserID != userID {
+        return nil, errListNotFound
+    }
+    return l, nil
+}
+
+// ownerView fills in the share URL the owner sees.
+func (s *readingListService) ownerView(l *model.ReadingList) {
+    if l.ShareToken != "" {
+        l.ShareURL = s.shareURL + l.ShareToken
+    }
+}
+
+func (s *readingListService) Create(ctx context.Context, userID string, req *model.ReadingListRequest) (*model.ReadingList, error) {
+    l := &model.ReadingList{UserID: userID, Name: req.Name}
+    if err := s.repo.Create(ctx, l); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "reading list created", "list_id", l.ID, "user_id", userID)
+    return l, nil
+}
+
+func (s *readingListService) Rename(ctx context.Context, userID, id string, req *model.ReadingListRequest) (*model.ReadingList, error) {
+    if _, err := s.owned(ctx, userID, id); err != nil {
+        return nil, err
+    }
+    if err := s.repo.Rename(ctx, id, req.Name); err != nil {
+        return nil, err
+    }
+    return s.Get(ctx, userID, id)
+}
+
+func (s *readingListService) Delete(ctx context.Context, userID, id string) error {
+    if _, err := s.owned(ctx, userID, id); err != nil {
+        return err
+    }
+    if err := s.repo.Delete(ctx, id); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "reading list deleted", "list_id", id, "user_id", userID)
+    return nil
+}
+
+func (s *readingListService) AddBook(ctx context.Context, userID, id, bookID string) (*model.ReadingList, error) {
+    if _, err := s.owned(ctx, userID, id); err != nil {
+        return nil, err
+    }
+    if uuid.Validate(bookID) != nil {
+        return nil, apperr.NotFound("book not found")
+    }
+    // Merged duplicates can still be fetched by ID in Postgres, so check the
+    // book is live before listing it.
+    if _, err := s.books.GetByID(ctx, bookID); err != nil {
+        return nil, err
+    }
+    if err := s.repo.AddBook(ctx, id, bookID); err != nil {
+        return nil, err
+    }
+    return s.Get(ctx, userID, id)
+}
+
+func (s *readingListService) RemoveBook(ctx context.Context, userID, id, bookID string) (*model.ReadingList, error) {
+    if _, err := s.owned(ctx, userID, id); err != nil {
+        return nil, err
+    }
+    if uuid.Validate(bookID) != nil {
+        return nil, apperr.NotFound("book is not on the list")
+    }
+    if err := s.repo.RemoveBook(ctx, id, bookID); err != nil {
+        return nil, err
+    }
+    return s.Get(ctx, userID, id)
+}
+
+func (s *readingListService) Share(ctx context.Context, userID, id string) (*model.ReadingList, error) {
+    l, err := s.owned(ctx, userID, id)
+    if err != nil {
+        return nil, err
+    }
+    if l.ShareToken == "" {
+        raw := make([]byte, 16)
+        if _, err := rand.Read(raw); err != nil {
+            return nil, err
+        }
+        if err := s.repo.SetShareToken(ctx, id, base64.RawURLEncoding.EncodeToString(raw)); err != nil {
+            return nil, err
+        }
+        s.logger.InfoContext(ctx, "reading list shared", "list_id", id, "user_id", userID)
+    }
+    return s.Get(ctx, userID, id)
+}
+
+func (s *readingListService) Unshare(ctx context.Context, userID, id string) (*model.ReadingList, error) {
+    if _, err := s.owned(ctx, userID, id); err != nil {
+        return nil, err
+    }
+    if err := s.repo.SetShareToken(ctx, id, ""); err != nil {
+        return nil, err
+    }
+    return s.Get(ctx, userID, id)
+}
+
+func (s *readingListService) Shared(ctx context.Context, token string) (*model.ReadingList, error) {
+    if token == "" {
+        return nil, errListNotFound
+    }
+    l, err := s.repo.GetByShareToken(ctx, token)
+    if err != nil {
+        return nil, err
+    }
+    l.UserID = ""
+    return l, nil
+}
+
+func (s *readingListService) Favorites(ctx context.Context, userID string) (*model.ReadingList, error) {
+    l, err := s.repo.Favorites(ctx, userID)
+    if !errors.Is(err, apperr.ErrNotFound) {
+        if err == nil {
+            s.ownerView(l)
+        }
+        return l, err
+    }
+    l = &model.ReadingList{UserID: userID, Name: model.FavoritesListName, Favorites: true}
+    err = s.repo.Create(ctx, l)
+    if errors.Is(err, apperr.ErrConflict) {
+        // Another request made it first.
+        return s.Favorites(ctx, userID)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return l, nil
+}
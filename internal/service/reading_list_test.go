@@ -0,0 +1,106 @@
+package service
+
+import (
+    "context"
+    "strings"
+    "testing"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestReadingListService_ListsAndSharing(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewReadingListService(repos.ReadingLists, repos.Books, "https://library.example/v1/lists/shared/", logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+
+    l, err := svc.Create(ctx, ada.ID, &model.ReadingListRequest{Name: "To read"})
+    require.NoError(t, err)
+    l, err = svc.AddBook(ctx, ada.ID, l.ID, dune.ID)
+    require.NoError(t, err)
+    _, err = svc.AddBook(ctx, ada.ID, l.ID, dune.ID)
+    require.NoError(t, err, "adding a book twice changes nothing")
+    l, err = svc.Get(ctx, ada.ID, l.ID)
+    require.NoError(t, err)
+    require.Len(t, l.Books, 1)
+    require.True(t, l.Books[0].Available)
+    require.Equal(t, 2, l.Books[0].CopiesAvailable)
+
+    _, err = svc.Get(ctx, bob.ID, l.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound, "other users' lists are hidden")
+    _, err = svc.AddBook(ctx, bob.ID, l.ID, dune.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+    _, err = svc.AddBook(ctx, ada.ID, l.ID, "00000000-0000-0000-0000-000000000000")
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    shared, err := svc.Share(ctx, ada.ID, l.ID)
+    require.NoError(t, err)
+    require.True(t, strings.HasPrefix(shared.ShareURL, "https://library.example/v1/lists/shared/"))
+    again, err := svc.Share(ctx, ada.ID, l.ID)
+    require.NoError(t, err)
+    require.Equal(t, shared.ShareURL, again.ShareURL, "sharing again keeps the URL")
+
+    token := strings.TrimPrefix(shared.ShareURL, "https://library.example/v1/lists/shared/")
+    public, err := svc.Shared(ctx, token)
+    require.NoError(t, err)
+    require.Equal(t, "To read", public.Name)
+    require.Empty(t, public.UserID)
+    require.Empty(t, public.ShareURL)
+    require.Len(t, public.Books, 1)
+
+    _, err = svc.Unshare(ctx, ada.ID, l.ID)
+    require.NoError(t, err)
+    _, err = svc.Shared(ctx, token)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    l, err = svc.RemoveBook(ctx, ada.ID, l.ID, dune.ID)
+    require.NoError(t, err)
+    require.Empty(t, l.Books)
+    _, err = svc.RemoveBook(ctx, ada.ID, l.ID, dune.ID)
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+}
+
+func TestReadingListService_Favorites(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewReadingListService(repos.ReadingLists, repos.Books, "", logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    duplicate := &model.Book{Title: "Dune", Author: "F. Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    require.NoError(t, repos.Books.Create(ctx, duplicate))
+    _, err := svc.Create(ctx, ada.ID, &model.ReadingListRequest{Name: "Later"})
+    require.NoError(t, err)
+
+    favorites, err := svc.Favorites(ctx, ada.ID)
+    require.NoError(t, err)
+    require.True(t, favorites.Favorites)
+    require.Equal(t, model.FavoritesListName, favorites.Name)
+    again, err := svc.Favorites(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, favorites.ID, again.ID, "the list is made once")
+
+    _, err = svc.AddBook(ctx, ada.ID, favorites.ID, duplicate.ID)
+    require.NoError(t, err)
+    _, err = repos.Books.Merge(ctx, duplicate.ID, dune.ID)
+    require.NoError(t, err)
+
+    lists, err := svc.List(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Len(t, lists, 2)
+    require.True(t, lists[0].Favorites, "favorites come first")
+    require.Len(t, lists[0].Books, 1)
+    require.Equal(t, dune.ID, lists[0].Books[0].ID, "a merged duplicate is replaced by the book kept")
+}
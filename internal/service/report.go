@@ -0,0 +1,195 @@
+package service
+
+import (
+    "cmp"
+    "context"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// OverdueSchedule emails the overdue report for every branch to
+// Recipients once a week, on Weekday (UTC). Without recipients nothing is
+// sent.
+type OverdueSchedule struct {
+    Weekday    time.Weekday
+    Recipients []string
+}
+
+// overdueReportRun names the weekly overdue report in the scheduled runs.
+const overdueReportRun = "overdue-report"
+
+type ReportService interface {
+    // Overdue builds the overdue report for the branch ctx is scoped to,
+    // or for every branch.
+    Overdue(ctx context.Context) (*model.OverdueReport, error)
+    // SendScheduledOverdue emails the week's overdue report unless it has
+    // been sent already, by this or another instance. It is meant to be
+    // called periodically; the report goes out on the first call on or
+    // after the scheduled weekday.
+    SendScheduledOverdue(ctx context.Context) error
+}
+
+type reportService struct {
+    bookings repo.BookingRepo
+    runs     repo.ScheduledRunRepo
+    fines    FinePolicyService
+    schedule OverdueSchedule
+    notifier *notify.Notifier
+    tx       repo.TxManager
+    logger   *slog.Logger
+    now      func() time.Time
+}
+
+// NewReportService returns the admin report service. notifier may be nil,
+// in which case scheduled reports aren't sent.
+func NewReportService(bookings repo.BookingRepo, runs repo.ScheduledRunRepo, fines FinePolicyService, schedule OverdueSchedule, notifier *notify.Notifier, tx repo.TxManager, logger *slog.Logger) ReportService {
+    return &reportService{
+        bookings: bookings,
+        runs:     runs,
+        fines:    fines,
+        schedule: schedule,
+        notifier: notifier,
+        tx:       tx,
+        logger:   logger,
+        now:      time.Now,
+    }
+}
+
+func (s *reportService) Overdue(ctx context.Context) (*model.OverdueReport, error) {
+    now := s.now().UTC()
+    policy, err := s.fines.Get(ctx)
+    if err != nil {
+        return nil, err
+    }
+    loans, err := s.bookings.Overdue(ctx, now)
+    if err != nil {
+        return nil, err
+    }
+
+    report := &model.OverdueReport{
+        GeneratedAt:     now,
+        FineGraceDays:   policy.GraceDays,
+        FinePerDayCents: policy.PerDayCents,
+        Currency:        policy.Currency,
+        Borrowers:       []model.OverdueUser{},
+    }
+    byUser := map[string]*model.OverdueUser{}
+    var order []string
+    for _, b := range loans {
+        u, ok := byUser[b.UserID]
+        if !ok {
+            u = &model.OverdueUser{UserID: b.UserID}
+            if b.User != nil {
+                u.Username, u.Email = b.User.Username, b.User.Email
+            }
+            byUser[b.UserID] = u
+            order = append(order, b.UserID)
+        }
+        days := daysLate(b.DueDate, now)
+        loan := model.OverdueLoan{
+            BookingID: b.ID,
+            BranchID:  b.BranchID,
+            BookID:    b.BookID,
+            DueDate:   b.DueDate,
+            DaysLate:  days,
+            FineCents: policy.Fine(days),
+        }
+        if b.Book != nil {
+            loan.Title = b.Book.Title
+        }
+        u.Loans = append(u.Loans, loan)
+        u.FineCents += loan.FineCents
+        report.TotalLoans++
+        report.TotalFineCents += loan.FineCents
+    }
+
+    for _, id := range order {
+        u := byUser[id]
+        slices.SortFunc(u.Loans, func(a, b model.OverdueLoan) int {
+            return cmp.Or(a.DueDate.Compare(b.DueDate), strings.Compare(a.BookingID, b.BookingID))
+        })
+        report.Borrowers = append(report.Borrowers, *u)
+    }
+    slices.SortFunc(report.Borrowers, func(a, b model.OverdueUser) int {
+        return cmp.Or(
+            cmp.Compare(b.FineCents, a.FineCents),
+            a.Loans[0].DueDate.Compare(b.Loans[0].DueDate),
+            strings.Compare(a.Username, b.Username),
+        )
+    })
+    return report, nil
+}
+
+// daysLate counts every started day between due and now.
+func daysLate(due, now time.Time) int {
+    const day = 24 * time.Hour
+    return int((now.Sub(due) + day - 1) / day)
+}
+
+func (s *reportService) SendScheduledOverdue(ctx context.Context) error {
+    if s.notifier == nil || len(s.schedule.Recipients) == 0 {
+        return nil
+    }
+    period := weekStart(s.now().UTC(), s.schedule.Weekday)
+
+    // The claim and the queued emails commit together, so a failure leaves
+    // the week to be retried on the next call.
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        claimed, err := s.runs.Claim(ctx, overdueReportRun, period)
+        if err != nil || !claimed {
+            return err
+        }
+        report, err := s.Overdue(ctx)
+        if err != nil {
+            return err
+        }
+        data := overdueEmail(report)
+        for _, to := range s.schedule.Recipients {
+            if err := s.notifier.Send(ctx, to, "", notify.TemplateOverdueReport, data); err != nil {
+                return err
+            }
+        }
+        s.logger.InfoContext(ctx, "overdue report sent", "week", period.Format("2006-01-02"), "loans", report.TotalLoans, "recipients", len(s.schedule.Recipients))
+        return nil
+    })
+}
+
+// weekStart is midnight UTC of the latest day on or before now that falls
+// on day.
+func weekStart(now time.Time, day time.Weekday) time.Time {
+    midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+    return midnight.AddDate(0, 0, -((int(now.Weekday()) - int(day) + 7) % 7))
+}
+
+func overdueEmail(r *model.OverdueReport) notify.OverdueReport {
+    data := notify.OverdueReport{
+        GeneratedAt: r.GeneratedAt,
+        Loans:       r.TotalLoans,
+        Borrowers:   len(r.Borrowers),
+        TotalFine:   formatMoney(r.TotalFineCents, r.Currency),
+    }
+    for _, u := range r.Borrowers {
+        for _, l := range u.Loans {
+            data.Rows = append(data.Rows, notify.OverdueRow{
+                Username: u.Username,
+                Email:    u.Email,
+                Title:    l.Title,
+                DueDate:  l.DueDate,
+                DaysLate: l.DaysLate,
+                Fine:     formatMoney(l.FineCents, r.Currency),
+            })
+        }
+    }
+    return data
+}
+
+func formatMoney(cents int, currency string) string {
+    return fmt.Sprintf("%d.%02d %s", cents/100, cents%100, strings.ToUpper(currency))
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestReportService_Overdue(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewReportService(repos.Bookings, repos.ScheduledRuns, NewFinePolicyService(repos.FinePolicy, repos.Audit, repos.Tx, model.FinePolicy{PerDayCents: 25, MaxCents: 100, Currency: "usd"}, logger.Discard()), OverdueSchedule{}, nil, repos.Tx, logger.Discard()).(*reportService)
+    now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+    svc.now = func() time.Time { return now }
+
+    user := func(name string) *model.User {
+        u := &model.User{Username: name, Email: name + "@example.com", Role: "user"}
+        require.NoError(t, repos.Users.Create(ctx, u))
+        return u
+    }
+    ada, bob := user("ada"), user("bob")
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 5}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen"}
+    require.NoError(t, repos.Books.Create(ctx, emma))
+    loan := func(u *model.User, b *model.Book, due time.Time, status string) {
+        require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: u.ID, BookID: b.ID, BorrowedAt: due.AddDate(0, 0, -14), DueDate: due, Status: status}))
+    }
+    loan(ada, book, now.Add(-2*time.Hour), "ACTIVE") // a started day is a day late
+    loan(bob, book, now.AddDate(0, 0, -3), "OVERDUE")
+    loan(bob, book, now.AddDate(0, 0, -30), "OVERDUE") // fine capped
+    loan(ada, book, now.AddDate(0, 0, -3), "RETURNED")
+    loan(ada, emma, now.Add(time.Hour), "ACTIVE")
+
+    report, err := svc.Overdue(ctx)
+    require.NoError(t, err)
+    require.Equal(t, 3, report.TotalLoans)
+    require.Equal(t, 25+75+100, report.TotalFineCents)
+    require.Len(t, report.Borrowers, 2, "returned and not yet due loans are left out")
+    require.Equal(t, "bob", report.Borrowers[0].Username, "most owed first")
+    require.Equal(t, 175, report.Borrowers[0].FineCents)
+    require.Equal(t, 30, report.Borrowers[0].Loans[0].DaysLate, "oldest loan first")
+    require.Equal(t, "Dune", report.Borrowers[0].Loans[0].Title)
+    require.Equal(t, 1, report.Borrowers[1].Loans[0].DaysLate)
+}
+
+func TestReportService_SendScheduledOverdueOncePerWeek(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    schedule := OverdueSchedule{Weekday: time.Monday, Recipients: []string{"desk@example.com", "head@example.com"}}
+    svc := NewReportService(repos.Bookings, repos.ScheduledRuns, NewFinePolicyService(repos.FinePolicy, repos.Audit, repos.Tx, model.FinePolicy{PerDayCents: 25, Currency: "usd"}, logger.Discard()), schedule, newTestNotifier(t, mailer), repos.Tx, logger.Discard()).(*reportService)
+    now := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC) // a Wednesday
+    svc.now = func() time.Time { return now }
+
+    require.NoError(t, svc.SendScheduledOverdue(ctx))
+    require.Len(t, mailer.sent, 2)
+    require.Equal(t, "desk@example.com", mailer.sent[0].To)
+    require.Contains(t, mailer.sent[0].Subject, "Overdue report")
+
+    now = now.AddDate(0, 0, 4) // Sunday, same week
+    require.NoError(t, svc.SendScheduledOverdue(ctx))
+    require.Len(t, mailer.sent, 2)
+
+    now = now.AddDate(0, 0, 1) // Monday
+    require.NoError(t, svc.SendScheduledOverdue(ctx))
+    require.Len(t, mailer.sent, 4)
+}
+
+func TestWeekStart(t *testing.T) {
+    monday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+    require.Equal(t, monday, weekStart(monday.Add(5*time.Hour), time.Monday))
+    require.Equal(t, monday, weekStart(monday.AddDate(0, 0, 6), time.Monday))
+    require.Equal(t, monday.AddDate(0, 0, -2), weekStart(monday, time.Saturday))
+}
+
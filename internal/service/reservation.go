@@ -0,0 +1,92 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// ReservationService manages book waitlists. Copies are offered to the
+// users on them by BookingService as they come back.
+type ReservationService interface {
+    Reserve(ctx context.Context, userID string, req *model.ReserveBookRequest) (*model.Reservation, error)
+    ListMine(ctx context.Context, userID string) ([]model.Reservation, error)
+    Cancel(ctx context.Context, userID, id string) error
+}
+
+type reservationService struct {
+    reservations repo.ReservationRepo
+    books        repo.BookRepo
+    bookings     repo.BookingRepo
+    users        repo.UserRepo
+    logger       *slog.Logger
+}
+
+func NewReservationService(reservations repo.ReservationRepo, books repo.BookRepo, bookings repo.BookingRepo, users repo.UserRepo, logger *slog.Logger) ReservationService {
+    return &reservationService{reservations: reservations, books: books, bookings: bookings, users: users, logger: logger}
+}
+
+// Reserve puts the user at the back of the book's waitlist. A book with a
+// copy free and nobody waiting should be borrowed instead, and a user who
+// has the book on loan or on offer can't join its waitlist.
+func (s *reservationService) Reserve(ctx context.Context, userID string, req *model.ReserveBookRequest) (*model.Reservation, error) {
+    user, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if user.IsSuspended(time.Now()) {
+        return nil, apperr.Forbidden("your account is suspended")
+    }
+    book, err := s.books.GetByID(ctx, req.BookID)
+    if err != nil {
+        return nil, err
+    }
+
+    if _, err := s.bookings.GetActive(ctx, userID, book.ID); err == nil {
+        return nil, apperr.Conflict("you already have this book on loan")
+    } else if !errors.Is(err, apperr.ErrNotFound) {
+        return nil, err
+    }
+    offered, err := s.bookings.GetByUser(ctx, userID, model.PageRequest{Limit: 1},
+        model.BookingFilter{Status: "OFFERED", BookID: book.ID}, model.BookingExpand{})
+    if err != nil {
+        return nil, err
+    }
+    if offered.Total > 0 {
+        return nil, apperr.Conflict("a copy of this book is already offered to you")
+    }
+
+    if book.Available {
+        waiting, err := s.reservations.CountWaiting(ctx, book.ID)
+        if err != nil {
+            return nil, err
+        }
+        if waiting == 0 {
+            return nil, apperr.Conflict("a copy of this book is available to borrow now")
+        }
+    }
+
+    res := &model.Reservation{BookID: book.ID, UserID: userID}
+    if err := s.reservations.Create(ctx, res); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "book reserved", "reservation_id", res.ID, "book_id", book.ID, "position", res.Position)
+    return res, nil
+}
+
+func (s *reservationService) ListMine(ctx context.Context, userID string) ([]model.Reservation, error) {
+    return s.reservations.ListByUser(ctx, userID)
+}
+
+func (s *reservationService) Cancel(ctx context.Context, userID, id string) error {
+    if err := s.reservations.Delete(ctx, userID, id); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "reservation cancelled", "reservation_id", id)
+    return nil
+}
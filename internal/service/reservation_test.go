@@ -0,0 +1,124 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestWaitlist_OffersReturnedCopiesInOrder(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    bookings := NewBookingService(repos.Bookings, repos.Books, repos.Users, repos.LoanPolicies, model.LoanDuration{}, repos.Reservations, repos.Closures, nil, nil, nil, nil, time.Hour, repos.Tx, logger.Discard())
+    reservations := NewReservationService(repos.Reservations, repos.Books, repos.Bookings, repos.Users, logger.Discard())
+    ctx := context.Background()
+
+    users := map[string]*model.User{}
+    for _, name := range []string{"alice", "bob", "carol", "dave"} {
+        u := &model.User{Username: name, Email: name + "@example.com", Role: "user"}
+        require.NoError(t, repos.Users.Create(ctx, u))
+        users[name] = u
+    }
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+
+    _, err := reservations.Reserve(ctx, users["bob"].ID, &model.ReserveBookRequest{BookID: book.ID})
+    require.ErrorIs(t, err, apperr.ErrConflict, "a free copy should be borrowed, not reserved")
+
+    receipt, err := bookings.Borrow(ctx, users["alice"].ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.NoError(t, err)
+    loan := receipt.Booking
+    _, err = reservations.Reserve(ctx, users["alice"].ID, &model.ReserveBookRequest{BookID: book.ID})
+    require.ErrorIs(t, err, apperr.ErrConflict, "alice has the book on loan")
+    for i, name := range []string{"bob", "carol"} {
+        res, err := reservations.Reserve(ctx, users[name].ID, &model.ReserveBookRequest{BookID: book.ID})
+        require.NoError(t, err)
+        require.Equal(t, i+1, res.Position)
+    }
+
+    offerTo := func(name string) *model.Booking {
+        t.Helper()
+        page, err := repos.Bookings.GetByUser(ctx, users[name].ID, model.PageRequest{Limit: 1},
+            model.BookingFilter{Status: "OFFERED", BookID: book.ID}, model.BookingExpand{})
+        require.NoError(t, err)
+        require.Len(t, page.Items, 1, "%s should have an offer", name)
+        return &page.Items[0]
+    }
+
+    // The returned copy goes to bob, the head of the line, and stays held.
+    _, err = bookings.Return(ctx, users["alice"].ID, loan.ID, false)
+    require.NoError(t, err)
+    offer := offerTo("bob")
+    require.NotNil(t, offer.OfferExpiresAt)
+    _, err = bookings.Borrow(ctx, users["dave"].ID, &model.BorrowBookRequest{BookID: book.ID, BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+    _, err = bookings.AcceptOffer(ctx, users["carol"].ID, offer.ID, &model.AcceptOfferRequest{BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+
+    accepted, err := bookings.AcceptOffer(ctx, users["bob"].ID, offer.ID, &model.AcceptOfferRequest{BorrowDays: 7})
+    require.NoError(t, err)
+    require.Equal(t, "ACTIVE", accepted.Status)
+    require.Nil(t, accepted.OfferExpiresAt)
+    _, err = bookings.DeclineOffer(ctx, users["bob"].ID, offer.ID)
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    _, err = reservations.Reserve(ctx, users["dave"].ID, &model.ReserveBookRequest{BookID: book.ID})
+    require.NoError(t, err)
+    mine, err := reservations.ListMine(ctx, users["dave"].ID)
+    require.NoError(t, err)
+    require.Len(t, mine, 1)
+    require.Equal(t, 2, mine[0].Position)
+
+    // Carol declines, so the copy moves on to dave.
+    _, err = bookings.Return(ctx, users["bob"].ID, accepted.ID, false)
+    require.NoError(t, err)
+    declined, err := bookings.DeclineOffer(ctx, users["carol"].ID, offerTo("carol").ID)
+    require.NoError(t, err)
+    require.Equal(t, "DECLINED", declined.Status)
+
+    // Dave lets his offer lapse, leaving the copy free with nobody waiting.
+    offer = offerTo("dave")
+    _, err = repos.Bookings.Update(ctx, offer.ID, model.UpdateBookingPatch{OfferExpiresAt: model.Ptr(time.Now().UTC().Add(-time.Minute))})
+    require.NoError(t, err)
+    _, err = bookings.AcceptOffer(ctx, users["dave"].ID, offer.ID, &model.AcceptOfferRequest{BorrowDays: 7})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+    require.NoError(t, bookings.ExpireOffers(ctx))
+
+    expired, err := repos.Bookings.GetByID(ctx, offer.ID)
+    require.NoError(t, err)
+    require.Equal(t, "EXPIRED", expired.Status)
+    got, err := repos.Books.GetByID(ctx, book.ID)
+    require.NoError(t, err)
+    require.True(t, got.Available)
+}
+
+func TestReservationService_Cancel(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewReservationService(repos.Reservations, repos.Books, repos.Bookings, repos.Users, logger.Discard())
+    ctx := context.Background()
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 1}
+    require.NoError(t, repos.Books.Create(ctx, book))
+    now := time.Now().UTC()
+    require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now.AddDate(0, 0, 7), Status: "ACTIVE"}))
+
+    res, err := svc.Reserve(ctx, bob.ID, &model.ReserveBookRequest{BookID: book.ID})
+    require.NoError(t, err)
+    _, err = svc.Reserve(ctx, bob.ID, &model.ReserveBookRequest{BookID: book.ID})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    require.ErrorIs(t, svc.Cancel(ctx, alice.ID, res.ID), apperr.ErrNotFound, "alice can't cancel bob's reservation")
+    require.NoError(t, svc.Cancel(ctx, bob.ID, res.ID))
+    mine, err := svc.ListMine(ctx, bob.ID)
+    require.NoError(t, err)
+    require.Empty(t, mine)
+}
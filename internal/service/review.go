@@ -0,0 +1,104 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// ReviewService manages book ratings and reviews. Users review books they
+// have returned; admins remove abusive reviews.
+type ReviewService interface {
+    Create(ctx context.Context, userID, bookID string, req *model.CreateReviewRequest) (*model.Review, error)
+    // ListByBook returns the book's reviews, or a NotFound error for an
+    // unknown book.
+    ListByBook(ctx context.Context, bookID string, p model.PageRequest) (model.Page[model.Review], error)
+    List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error)
+    // Remove deletes a review on behalf of the moderator actorID, keeping
+    // its content in the audit log.
+    Remove(ctx context.Context, actorID, id string) error
+}
+
+type reviewService struct {
+    reviews  repo.ReviewRepo
+    books    repo.BookRepo
+    bookings repo.BookingRepo
+    users    repo.UserRepo
+    audit    repo.AuditRepo
+    tx       repo.TxManager
+    logger   *slog.Logger
+}
+
+func NewReviewService(reviews repo.ReviewRepo, books repo.BookRepo, bookings repo.BookingRepo, users repo.UserRepo, audit repo.AuditRepo, tx repo.TxManager, logger *slog.Logger) ReviewService {
+    return &reviewService{reviews: reviews, books: books, bookings: bookings, users: users, audit: audit, tx: tx, logger: logger}
+}
+
+// Create requires a returned booking of the book, so only readers who have
+// had it in hand can rate it. Each user reviews a book once.
+func (s *reviewService) Create(ctx context.Context, userID, bookID string, req *model.CreateReviewRequest) (*model.Review, error) {
+    user, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if user.IsSuspended(time.Now()) {
+        return nil, apperr.Forbidden("your account is suspended")
+    }
+    if _, err := s.books.GetByID(ctx, bookID); err != nil {
+        return nil, err
+    }
+
+    returned, err := s.bookings.GetByUser(ctx, userID, model.PageRequest{Limit: 1},
+        model.BookingFilter{Status: "RETURNED", BookID: bookID}, model.BookingExpand{})
+    if err != nil {
+        return nil, err
+    }
+    if returned.Total == 0 {
+        return nil, apperr.Forbidden("you can only review books you have borrowed and returned")
+    }
+
+    rv := &model.Review{BookID: bookID, UserID: userID, Rating: req.Rating, Text: req.Text}
+    if err := s.reviews.Create(ctx, rv); err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "review created", "review_id", rv.ID, "book_id", bookID, "rating", rv.Rating)
+    return rv, nil
+}
+
+func (s *reviewService) ListByBook(ctx context.Context, bookID string, p model.PageRequest) (model.Page[model.Review], error) {
+    if _, err := s.books.GetByID(ctx, bookID); err != nil {
+        return model.Page[model.Review]{}, err
+    }
+    return s.reviews.List(ctx, p, model.ReviewFilter{BookID: bookID})
+}
+
+func (s *reviewService) List(ctx context.Context, p model.PageRequest, f model.ReviewFilter) (model.Page[model.Review], error) {
+    return s.reviews.List(ctx, p, f)
+}
+
+func (s *reviewService) Remove(ctx context.Context, actorID, id string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        rv, err := s.reviews.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := s.reviews.Delete(ctx, id); err != nil {
+            return err
+        }
+        return s.audit.Record(ctx, &model.AuditEntry{
+            ActorID:    actorID,
+            Action:     model.AuditReviewRemoved,
+            TargetType: "review",
+            TargetID:   id,
+            Details: map[string]interface{}{
+                "book_id": rv.BookID,
+                "user_id": rv.UserID,
+                "rating":  rv.Rating,
+                "text":    rv.Text,
+            },
+        })
+    })
+}
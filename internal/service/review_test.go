@@ -0,0 +1,61 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestReviewService_OnlyAfterReturn(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    audit := &recordingAudit{}
+    svc := NewReviewService(repos.Reviews, repos.Books, repos.Bookings, repos.Users, audit, repos.Tx, logger.Discard())
+    ctx := context.Background()
+
+    alice := &model.User{Username: "alice", Email: "alice@example.com", Role: "user"}
+    bob := &model.User{Username: "bob", Email: "bob@example.com", Role: "user"}
+    require.NoError(t, repos.Users.Create(ctx, alice))
+    require.NoError(t, repos.Users.Create(ctx, bob))
+    book := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 2}
+    require.NoError(t, repos.Books.Create(ctx, book))
+
+    now := time.Now().UTC()
+    require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: alice.ID, BookID: book.ID, BorrowedAt: now, DueDate: now, ReturnedAt: &now, Status: "RETURNED"}))
+    require.NoError(t, repos.Bookings.Create(ctx, &model.Booking{UserID: bob.ID, BookID: book.ID, BorrowedAt: now, DueDate: now, Status: "ACTIVE"}))
+
+    _, err := svc.Create(ctx, bob.ID, book.ID, &model.CreateReviewRequest{Rating: 5})
+    require.ErrorIs(t, err, apperr.ErrForbidden, "bob hasn't returned the book yet")
+
+    rv, err := svc.Create(ctx, alice.ID, book.ID, &model.CreateReviewRequest{Rating: 4, Text: "Sand everywhere"})
+    require.NoError(t, err)
+    require.Equal(t, "alice", rv.Username)
+    _, err = svc.Create(ctx, alice.ID, book.ID, &model.CreateReviewRequest{Rating: 1})
+    require.ErrorIs(t, err, apperr.ErrConflict)
+
+    got, err := repos.Books.GetByID(ctx, book.ID)
+    require.NoError(t, err)
+    require.Equal(t, 4.0, got.AverageRating)
+    require.Equal(t, 1, got.ReviewCount)
+
+    page, err := svc.ListByBook(ctx, book.ID, model.PageRequest{Limit: 10})
+    require.NoError(t, err)
+    require.Equal(t, 1, page.Total)
+    _, err = svc.ListByBook(ctx, "missing", model.PageRequest{Limit: 10})
+    require.ErrorIs(t, err, apperr.ErrNotFound)
+
+    require.NoError(t, svc.Remove(ctx, "admin-1", rv.ID))
+    require.Len(t, audit.entries, 1)
+    require.Equal(t, model.AuditReviewRemoved, audit.entries[0].Action)
+    require.Equal(t, "Sand everywhere", audit.entries[0].Details["text"])
+    require.ErrorIs(t, svc.Remove(ctx, "admin-1", rv.ID), apperr.ErrNotFound)
+
+    got, err = repos.Books.GetByID(ctx, book.ID)
+    require.NoError(t, err)
+    require.Zero(t, got.ReviewCount)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// revokedSessions caches the IDs of revoked, unexpired sessions so that
+// validating a token doesn't take a query. The set is reloaded once it is
+// older than ttl; sessions revoked through this instance are added to it
+// straight away.
+type revokedSessions struct {
+    repo repo.SessionRepo
+    ttl  time.Duration
+
+    mu       sync.Mutex
+    ids      map[string]struct{}
+    loadedAt time.Time
+}
+
+func newRevokedSessions(r repo.SessionRepo, ttl time.Duration) *revokedSessions {
+    return &revokedSessions{repo: r, ttl: ttl}
+}
+
+func (c *revokedSessions) contains(ctx context.Context, id string) (bool, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    now := time.Now()
+    if c.ids == nil || now.Sub(c.loadedAt) >= c.ttl {
+        ids, err := c.repo.RevokedIDs(ctx, now.UTC())
+        if err != nil {
+            return false, err
+        }
+        c.ids = make(map[string]struct{}, len(ids))
+        for _, id := range ids {
+            c.ids[id] = struct{}{}
+        }
+        c.loadedAt = now
+    }
+    _, ok := c.ids[id]
+    return ok, nil
+}
+
+func (c *revokedSessions) add(id string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.ids != nil {
+        c.ids[id] = struct{}{}
+    }
+}
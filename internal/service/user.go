@@ -3,10 +3,18 @@ package service
 import (
     "context"
     "errors"
+    "log/slog"
+    "net/url"
+    "slices"
+    "strings"
+    "sync"
+    "time"
 
-    "golang.org/x/crypto/bcrypt"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
 )
 
 type UserService interface {
@@ -15,42 +23,84 @@ type UserService interface {
     GetByID(ctx context.Context, id string) (*model.User, error)
     GetByUsername(ctx context.Context, username string) (*model.User, error)
     GetByEmail(ctx context.Context, email string) (*model.User, error)
-    Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
+    // Update applies p, except for a password change, which goes through
+    // ChangePassword.
+    Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
     Delete(ctx context.Context, id string) error
     ValidatePassword(ctx context.Context, username, password string) (*model.User, error)
-    List(ctx context.Context, limit, offset int) ([]model.User, error)
+    Login(ctx context.Context, username, password, clientIP string) (*model.User, error)
+    ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error
+    List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
+    // AdminUpdate changes a user's email, role or status on an admin's
+    // behalf. It refuses to demote or suspend the last active admin, and
+    // fails with ErrPreconditionFailed when req.Version is set and the user
+    // has moved on from it.
+    AdminUpdate(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error)
+    // Suspend stops a user logging in or borrowing until until, or until
+    // Unsuspend when it is nil, and revokes the tokens they hold. The last
+    // active admin can't be suspended.
+    Suspend(ctx context.Context, id string, until *time.Time) (*model.User, error)
+    Unsuspend(ctx context.Context, id string) (*model.User, error)
+    // GetPreferences returns how the user wants due-date reminders.
+    GetPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error)
+    // UpdatePreferences replaces them. A webhook must be an https URL.
+    UpdatePreferences(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error)
 }
 
 type userService struct {
-    repo repo.UserRepo
+    repo        repo.UserRepo
+    attempts    repo.LoginAttemptRepo
+    revocations repo.TokenRevocationRepo
+    outbox      repo.OutboxRepo
+    lockout     LockoutPolicy
+    passwords   PasswordPolicy
+    emails      EmailPolicy
+    tx          repo.TxManager
+    logger      *slog.Logger
+    // dummyHash is checked against when there is no user to check, so a
+    // login for a username that doesn't exist takes as long as a wrong
+    // password and the response time doesn't reveal which usernames exist.
+    dummyHash func() string
 }
 
-func NewUserService(r repo.UserRepo) UserService {
-    return &userService{repo: r}
+// NewUserService builds the user service. attempts may be nil when lockout
+// is disabled, and revocations when tokens can't be revoked, in which case
+// a suspended user keeps the tokens they hold until these expire. outbox
+// may be nil, in which case registrations are not announced.
+func NewUserService(r repo.UserRepo, attempts repo.LoginAttemptRepo, revocations repo.TokenRevocationRepo, outbox repo.OutboxRepo, lockout LockoutPolicy, passwords PasswordPolicy, emails EmailPolicy, tx repo.TxManager, logger *slog.Logger) UserService {
+    dummyHash := sync.OnceValue(func() string {
+        h, err := passwords.Hasher.Hash("no user has this password")
+        if err != nil {
+            panic(err)
+        }
+        return h
+    })
+    return &userService{repo: r, attempts: attempts, revocations: revocations, outbox: outbox, lockout: lockout, passwords: passwords, emails: emails, tx: tx, logger: logger, dummyHash: dummyHash}
 }
 
 func (s *userService) RegisterAdmin(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
     if req.Username == "" || req.Email == "" || req.Password == "" {
-        return nil, errors.New("username, email, and password are required")
+        return nil, apperr.Validation("username, email, and password are required")
     }
-
-    if len(req.Password) < 8 {
-        return nil, errors.New("password must be at least 8 characters")
+    email, err := s.checkEmail(ctx, req.Email)
+    if err != nil {
+        return nil, err
     }
 
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+    hashedPassword, err := s.hashPassword(req.Password, req.Username)
     if err != nil {
-        return nil, errors.New("failed to hash password")
+        return nil, err
     }
 
     u := &model.User{
         Username: req.Username,
-        Email:    req.Email,
-        Password: string(hashedPassword),
-        Role:     "admin",
+        Email:    email,
+        Password: hashedPassword,
+        Role:     model.RoleAdmin,
+        BranchID: tenant.BranchID(ctx),
     }
 
-    if err := s.repo.Create(ctx, u); err != nil {
+    if err := s.create(ctx, u); err != nil {
         return nil, err
     }
 
@@ -60,26 +110,27 @@ func (s *userService) RegisterAdmin(ctx context.Context, req *model.RegisterRequ
 func (s *userService) Register(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
     // Validate input
     if req.Username == "" || req.Email == "" || req.Password == "" {
-        return nil, errors.New("username, email, and password are required")
+        return nil, apperr.Validation("username, email, and password are required")
     }
-
-    if len(req.Password) < 8 {
-        return nil, errors.New("password must be at least 8 characters")
+    email, err := s.checkEmail(ctx, req.Email)
+    if err != nil {
+        return nil, err
     }
 
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+    hashedPassword, err := s.hashPassword(req.Password, req.Username)
     if err != nil {
-        return nil, errors.New("failed to hash password")
+        return nil, err
     }
 
     u := &model.User{
         Username: req.Username,
-        Email:    req.Email,
-        Password: string(hashedPassword),
-        Role:     "user",
+        Email:    email,
+        Password: hashedPassword,
+        Role:     model.RoleUser,
+        BranchID: tenant.BranchID(ctx),
     }
 
-    if err := s.repo.Create(ctx, u); err != nil {
+    if err := s.create(ctx, u); err != nil {
         return nil, err
     }
 
@@ -87,6 +138,80 @@ func (s *userService) Register(ctx context.Context, req *model.RegisterRequest)
     return u, nil
 }
 
+// create stores a new user and records their registration with it.
+func (s *userService) create(ctx context.Context, u *model.User) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Create(ctx, u); err != nil {
+            return err
+        }
+        return recordEvent(ctx, s.outbox, model.EventUserRegistered, u.ID, u)
+    })
+}
+
+func (s *userService) checkEmail(ctx context.Context, email string) (string, error) {
+    return checkEmail(ctx, s.emails, s.logger, email)
+}
+
+// checkEmail applies the email policy and returns the normalized address.
+// When the domain can't be looked up right now the address is accepted,
+// rather than turning users away while DNS is down.
+func checkEmail(ctx context.Context, policy EmailPolicy, logger *slog.Logger, email string) (string, error) {
+    normalized, err := policy.Check(ctx, email)
+    if err != nil && !errors.Is(err, apperr.ErrValidation) {
+        logger.WarnContext(ctx, "email domain lookup failed, accepting address", "error", err)
+        return normalized, nil
+    }
+    return normalized, err
+}
+
+func (s *userService) hashPassword(password, username string) (string, error) {
+    return hashPassword(s.passwords, password, username)
+}
+
+// hashPassword checks password against the policy and returns its hash.
+func hashPassword(policy PasswordPolicy, password, username string) (string, error) {
+    if err := policy.Check(password, username); err != nil {
+        return "", err
+    }
+    hashed, err := policy.Hasher.Hash(password)
+    if err != nil {
+        return "", errors.New("failed to hash password")
+    }
+    return string(hashed), nil
+}
+
+// ChangePassword replaces the user's password after verifying the current
+// one. The new password must satisfy the policy and differ from the old one.
+func (s *userService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+    u, err := s.repo.GetByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    // GetByID never loads the hash; the username lookup does.
+    u, err = s.repo.GetByUsername(ctx, u.Username)
+    if err != nil {
+        return err
+    }
+
+    if !s.passwords.Hasher.Matches(u.Password, currentPassword) {
+        s.logger.WarnContext(ctx, "change password rejected: wrong current password", "user_id", userID)
+        return apperr.Forbidden("current password is incorrect")
+    }
+    if currentPassword == newPassword {
+        return apperr.Validation("new password must differ from the current password")
+    }
+
+    hashed, err := s.hashPassword(newPassword, u.Username)
+    if err != nil {
+        return err
+    }
+    if _, err := s.repo.Update(ctx, userID, model.UpdateUserPatch{PasswordHash: &hashed}); err != nil {
+        return err
+    }
+    s.logger.InfoContext(ctx, "password changed", "user_id", userID)
+    return nil
+}
+
 // GetByID retrieves a user by ID
 func (s *userService) GetByID(ctx context.Context, id string) (*model.User, error) {
     return s.repo.GetByID(ctx, id)
@@ -99,35 +224,283 @@ func (s *userService) GetByUsername(ctx context.Context, username string) (*mode
 
 // GetByEmail retrieves a user by email
 func (s *userService) GetByEmail(ctx context.Context, email string) (*model.User, error) {
-    return s.repo.GetByEmail(ctx, email)
+    return s.repo.GetByEmail(ctx, validate.NormalizeEmail(email))
 }
 
 // Update updates user information
-func (s *userService) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    delete(updates, "password_hash")
-    delete(updates, "id")
+func (s *userService) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    p.PasswordHash = nil
+    if p.Email != nil {
+        checked, err := s.checkEmail(ctx, *p.Email)
+        if err != nil {
+            return nil, err
+        }
+        p.Email = &checked
+    }
 
-    return s.repo.Update(ctx, id, updates)
+    return s.repo.Update(ctx, id, p)
 }
 
+// Delete removes a user. The last active admin can't be deleted.
 func (s *userService) Delete(ctx context.Context, id string) error {
-    return s.repo.Delete(ctx, id)
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        u, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        // Deleting an admin takes them out of the count like suspending.
+        if err := s.keepAnAdmin(ctx, u, "", model.UserStatusSuspended); err != nil {
+            return err
+        }
+        return s.repo.Delete(ctx, id)
+    })
+}
+
+func (s *userService) GetPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    return s.repo.GetNotificationPreferences(ctx, userID)
+}
+
+func (s *userService) UpdatePreferences(ctx context.Context, userID string, req *model.NotificationPreferencesRequest) (model.NotificationPreferences, error) {
+    p := model.NotificationPreferences{UserID: userID, Channel: req.Channel, ReminderLeadHours: req.ReminderLeadHours}
+    switch req.Channel {
+    case model.NotifyWebhook:
+        if req.WebhookURL == "" {
+            return p, apperr.Validation("webhook_url is required for the webhook channel")
+        }
+        u, err := url.Parse(req.WebhookURL)
+        if err != nil || u.Scheme != "https" || u.Host == "" {
+            return p, apperr.Validation("webhook_url must be an https URL")
+        }
+        p.WebhookURL = u.String()
+    case model.NotifyEmail, model.NotifyNone:
+    default:
+        return p, apperr.Validation("channel must be one of: email, webhook, none")
+    }
+    if err := s.repo.SaveNotificationPreferences(ctx, &p); err != nil {
+        return p, err
+    }
+    return p, nil
+}
+
+var (
+    validStatuses = []string{model.UserStatusActive, model.UserStatusSuspended}
+)
+
+func (s *userService) AdminUpdate(ctx context.Context, id string, req *model.AdminUpdateUserRequest) (*model.User, error) {
+    if req.Email == "" && req.Role == "" && req.Status == "" {
+        return nil, apperr.Validation("no fields to update")
+    }
+    patch := model.UpdateUserPatch{Version: req.Version}
+    if req.Email != "" {
+        email, err := s.checkEmail(ctx, req.Email)
+        if err != nil {
+            return nil, err
+        }
+        patch.Email = &email
+    }
+    if req.Role != "" {
+        if !req.Role.Valid() {
+            return nil, apperr.Validation("role must be one of: " + model.RoleNames())
+        }
+        patch.Role = &req.Role
+    }
+    if req.Status != "" {
+        if !slices.Contains(validStatuses, req.Status) {
+            return nil, apperr.Validation("status must be one of: " + strings.Join(validStatuses, ", "))
+        }
+        // Set this way, a suspension lasts until it is lifted.
+        patch.Status = &req.Status
+    }
+
+    var updated *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        u, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := s.keepAnAdmin(ctx, u, req.Role, req.Status); err != nil {
+            return err
+        }
+        updated, err = s.repo.Update(ctx, id, patch)
+        if err != nil {
+            return err
+        }
+        // Tokens carry the role they were issued with, so a role change
+        // voids them like a suspension does.
+        if req.Status == model.UserStatusSuspended || (req.Role != "" && req.Role != u.Role) {
+            return s.revokeTokens(ctx, id)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "user updated by admin", "target_user_id", id, "role", updated.Role, "status", updated.Status)
+    return updated, nil
+}
+
+func (s *userService) Suspend(ctx context.Context, id string, until *time.Time) (*model.User, error) {
+    if until != nil && !until.After(time.Now()) {
+        return nil, apperr.Validation("until must be in the future")
+    }
+
+    var updated *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        u, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := s.keepAnAdmin(ctx, u, "", model.UserStatusSuspended); err != nil {
+            return err
+        }
+        updated, err = s.repo.Update(ctx, id, model.UpdateUserPatch{
+            Status:         model.Ptr(model.UserStatusSuspended),
+            SuspendedUntil: until,
+        })
+        if err != nil {
+            return err
+        }
+        return s.revokeTokens(ctx, id)
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "user suspended", "target_user_id", id, "until", until)
+    return updated, nil
+}
+
+func (s *userService) Unsuspend(ctx context.Context, id string) (*model.User, error) {
+    updated, err := s.repo.Update(ctx, id, model.UpdateUserPatch{Status: model.Ptr(model.UserStatusActive)})
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "user unsuspended", "target_user_id", id)
+    return updated, nil
+}
+
+// revokeTokens voids the tokens issued to the user so far, when revocation
+// is configured.
+func (s *userService) revokeTokens(ctx context.Context, id string) error {
+    if s.revocations == nil {
+        s.logger.WarnContext(ctx, "token revocation is not configured; existing tokens stay valid", "target_user_id", id)
+        return nil
+    }
+    return s.revocations.Revoke(ctx, id, time.Now().UTC())
+}
+
+// keepAnAdmin fails when giving u the role and status (either may be "" to
+// leave it alone) would leave no active admin.
+func (s *userService) keepAnAdmin(ctx context.Context, u *model.User, role model.Role, status string) error {
+    if u.Role != model.RoleAdmin || u.Status != model.UserStatusActive {
+        return nil
+    }
+    if (role == "" || role == model.RoleAdmin) && (status == "" || status == model.UserStatusActive) {
+        return nil
+    }
+    admins, err := s.repo.CountActiveAdminsForUpdate(ctx)
+    if err != nil {
+        return err
+    }
+    if admins <= 1 {
+        return apperr.Conflict("this is the last active admin; promote another user first")
+    }
+    return nil
 }
 
 func (s *userService) ValidatePassword(ctx context.Context, username, password string) (*model.User, error) {
     u, err := s.repo.GetByUsername(ctx, username)
     if err != nil {
+        _ = s.passwords.Hasher.Matches(s.dummyHash(), password)
+        // The caller only sees a generic message; keep the real reason here.
+        s.logger.DebugContext(ctx, "login lookup failed", "username", username, "error", err)
         return nil, errors.New("invalid username or password")
     }
 
-    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+    if !s.passwords.Hasher.Matches(u.Password, password) {
+        s.logger.DebugContext(ctx, "password mismatch", "user_id", u.ID)
         return nil, errors.New("invalid username or password")
     }
+    if s.passwords.Hasher.NeedsRehash(u.Password) {
+        s.rehash(ctx, u, password)
+    }
 
     u.Password = ""
     return u, nil
 }
 
-func (s *userService) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    return s.repo.List(ctx, limit, offset)
+// rehash replaces u's hash, which password was just checked against, with
+// one under the current scheme and parameters. It only logs failures; the
+// old hash still works and is replaced at the next login.
+func (s *userService) rehash(ctx context.Context, u *model.User, password string) {
+    hashed, err := s.passwords.Hasher.Hash(password)
+    if err == nil {
+        err = s.repo.ReplacePasswordHash(ctx, u.ID, u.Password, hashed)
+    }
+    if err != nil {
+        s.logger.WarnContext(ctx, "rehashing password failed", "user_id", u.ID, "error", err)
+        return
+    }
+    s.logger.InfoContext(ctx, "password rehashed", "user_id", u.ID, "scheme", s.passwords.Hasher.Scheme)
+}
+
+// Login checks the credentials like ValidatePassword, subject to the lockout
+// policy: while the username or clientIP is locked out it fails with a
+// *LockedError without checking the password, and a failure that reaches the
+// limit starts a lockout. A successful login clears the username's counter.
+// Suspended users are refused with a forbidden error.
+func (s *userService) Login(ctx context.Context, username, password, clientIP string) (*model.User, error) {
+    u, err := s.checkCredentials(ctx, username, password, clientIP)
+    if err != nil {
+        return nil, err
+    }
+    if u.IsSuspended(time.Now()) {
+        s.logger.WarnContext(ctx, "login refused: account suspended", "user_id", u.ID)
+        return nil, apperr.Forbidden("account is suspended")
+    }
+    return u, nil
+}
+
+func (s *userService) checkCredentials(ctx context.Context, username, password, clientIP string) (*model.User, error) {
+    if !s.lockout.enabled() || s.attempts == nil {
+        return s.ValidatePassword(ctx, username, password)
+    }
+
+    keys := s.lockoutKeys(username, clientIP)
+    names := make([]string, len(keys))
+    for i, k := range keys {
+        names[i] = k.key
+    }
+    until, err := s.attempts.LockedUntil(ctx, names, time.Now().UTC())
+    if err != nil {
+        return nil, err
+    }
+    if !until.IsZero() {
+        return nil, &LockedError{Until: until}
+    }
+
+    u, err := s.ValidatePassword(ctx, username, password)
+    if err != nil {
+        until, recErr := s.recordFailure(ctx, keys)
+        if recErr != nil {
+            return nil, recErr
+        }
+        if !until.IsZero() {
+            return nil, &LockedError{Until: until}
+        }
+        return nil, err
+    }
+
+    // The IP counter is left alone so an attacker cannot clear it by
+    // interleaving logins to an account they own.
+    if s.lockout.MaxFailures > 0 {
+        name := keys[0].key
+        if err := s.attempts.Reset(ctx, name); err != nil {
+            s.logger.WarnContext(ctx, "clearing login failures failed", "key", name, "error", err)
+        }
+    }
+    return u, nil
+}
+
+func (s *userService) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    return s.repo.List(ctx, p)
 }
\ No newline at end of file
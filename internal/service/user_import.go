@@ -0,0 +1,258 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "log/slog"
+    "math/big"
+    "net/url"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/notify"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/tenant"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/validate"
+)
+
+// UserImportService onboards an existing patron database in bulk, and lets
+// invited users choose their password.
+type UserImportService interface {
+    // Import creates a user for every valid row, each with a temporary
+    // password or an invite link as opts says, and reports every row.
+    // Invalid rows, and rows whose username or email is taken, are
+    // reported and skipped; the rest are still created.
+    Import(ctx context.Context, rows []model.ImportUserRow, opts model.UserImportOptions) (*model.UserImportReport, error)
+    // AcceptInvitation sets the password of the user whose invite link
+    // carried token. Unknown, used and expired tokens are refused.
+    AcceptInvitation(ctx context.Context, token, password string) (*model.User, error)
+}
+
+type userImportService struct {
+    users       repo.UserRepo
+    invitations repo.UserInvitationRepo
+    outbox      repo.OutboxRepo
+    passwords   PasswordPolicy
+    emails      EmailPolicy
+    notifier    *notify.Notifier
+    inviteURL   string
+    ttl         time.Duration
+    tx          repo.TxManager
+    logger      *slog.Logger
+}
+
+// NewUserImportService returns the service. Invite links are inviteURL
+// with the token added as ?token=, and work for ttl. outbox may be nil, in
+// which case imported users are not announced.
+func NewUserImportService(users repo.UserRepo, invitations repo.UserInvitationRepo, outbox repo.OutboxRepo, passwords PasswordPolicy, emails EmailPolicy, notifier *notify.Notifier, inviteURL string, ttl time.Duration, tx repo.TxManager, logger *slog.Logger) UserImportService {
+    return &userImportService{users: users, invitations: invitations, outbox: outbox, passwords: passwords, emails: emails, notifier: notifier, inviteURL: inviteURL, ttl: ttl, tx: tx, logger: logger}
+}
+
+func (s *userImportService) Import(ctx context.Context, rows []model.ImportUserRow, opts model.UserImportOptions) (*model.UserImportReport, error) {
+    switch opts.Mode {
+    case "":
+        opts.Mode = model.UserImportPassword
+    case model.UserImportPassword, model.UserImportInvite:
+    default:
+        return nil, apperr.Validation("mode must be one of: password, invite")
+    }
+
+    report := &model.UserImportReport{
+        Total:   len(rows),
+        Results: make([]model.UserImportRowResult, len(rows)),
+    }
+    // Rows earlier in the file, by username and by email, so a repeat is
+    // reported against the row it repeats.
+    usernames := map[string]int{}
+    emails := map[string]int{}
+    for i, row := range rows {
+        res := &report.Results[i]
+        res.Row = i + 1
+        row.Normalize()
+        res.Username = row.Username
+
+        err := s.checkRow(ctx, res.Row, &row, usernames, emails)
+        if err == nil {
+            err = s.importRow(ctx, &row, opts, res)
+        }
+        if err != nil {
+            var appErr *apperr.Error
+            if !errors.As(err, &appErr) {
+                s.logger.ErrorContext(ctx, "import row failed", "row", res.Row, "error", err)
+                err = errors.New("user could not be created")
+            }
+            res.Status = "error"
+            res.Error = err.Error()
+            report.Failed++
+            continue
+        }
+        res.Status = "created"
+        report.Created++
+    }
+    s.logger.InfoContext(ctx, "users imported", "mode", opts.Mode, "created", report.Created, "failed", report.Failed)
+    return report, nil
+}
+
+// checkRow validates row n, normalizing its email and role, and remembers
+// its username and email against repeats later in the file.
+func (s *userImportService) checkRow(ctx context.Context, n int, row *model.ImportUserRow, usernames, emails map[string]int) error {
+    if errs := validate.Struct(row); len(errs) > 0 {
+        return apperr.Validation(errs.String())
+    }
+    if row.Role == "" {
+        row.Role = model.RoleUser
+    }
+    if !row.Role.Valid() {
+        return apperr.Validation("role must be one of: " + model.RoleNames())
+    }
+    email, err := checkEmail(ctx, s.emails, s.logger, row.Email)
+    if err != nil {
+        return err
+    }
+    row.Email = email
+
+    if first, ok := usernames[row.Username]; ok {
+        return apperr.Conflict(fmt.Sprintf("username repeats row %d", first))
+    }
+    if first, ok := emails[row.Email]; ok {
+        return apperr.Conflict(fmt.Sprintf("email repeats row %d", first))
+    }
+    usernames[row.Username] = n
+    emails[row.Email] = n
+    return nil
+}
+
+// importRow creates the user of a checked row, with their invitation, and
+// emails them when asked to, all or nothing.
+func (s *userImportService) importRow(ctx context.Context, row *model.ImportUserRow, opts model.UserImportOptions, res *model.UserImportRowResult) error {
+    u := &model.User{
+        Username: row.Username,
+        Email:    row.Email,
+        Role:     row.Role,
+        BranchID: tenant.BranchID(ctx),
+    }
+    invite := notify.Invitation{Username: row.Username}
+    var inv *model.UserInvitation
+    if opts.Mode == model.UserImportPassword {
+        password, err := temporaryPassword(s.passwords, row.Username)
+        if err != nil {
+            return err
+        }
+        if u.Password, err = hashPassword(s.passwords, password, row.Username); err != nil {
+            return err
+        }
+        invite.Password = password
+    } else {
+        // Without a password hash nobody can log in as the user until
+        // they accept the invitation.
+        token, err := newInvitationToken()
+        if err != nil {
+            return err
+        }
+        inv = &model.UserInvitation{TokenHash: hashToken(token), ExpiresAt: time.Now().UTC().Add(s.ttl)}
+        invite.Link = s.inviteURL + "?" + url.Values{"token": {token}}.Encode()
+        invite.ExpiresAt = inv.ExpiresAt
+    }
+
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.users.Create(ctx, u); err != nil {
+            return err
+        }
+        if err := recordEvent(ctx, s.outbox, model.EventUserRegistered, u.ID, u); err != nil {
+            return err
+        }
+        if inv != nil {
+            inv.UserID = u.ID
+            if err := s.invitations.Save(ctx, inv); err != nil {
+                return err
+            }
+        }
+        if !opts.SendEmail {
+            return nil
+        }
+        return s.notifier.Send(ctx, u.Email, "", notify.TemplateInvitation, invite)
+    })
+    if err != nil {
+        return err
+    }
+
+    res.UserID = u.ID
+    if opts.SendEmail {
+        res.Emailed = true
+    } else {
+        res.TemporaryPassword = invite.Password
+        res.InviteLink = invite.Link
+    }
+    return nil
+}
+
+func (s *userImportService) AcceptInvitation(ctx context.Context, token, password string) (*model.User, error) {
+    if token == "" {
+        return nil, apperr.Validation("token is required")
+    }
+    var updated *model.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        inv, err := s.invitations.Take(ctx, hashToken(token), time.Now().UTC())
+        if errors.Is(err, apperr.ErrNotFound) {
+            return apperr.NotFound("this invitation link is invalid or has expired")
+        }
+        if err != nil {
+            return err
+        }
+        u, err := s.users.GetByID(ctx, inv.UserID)
+        if err != nil {
+            return err
+        }
+        hashed, err := hashPassword(s.passwords, password, u.Username)
+        if err != nil {
+            return err
+        }
+        updated, err = s.users.Update(ctx, u.ID, model.UpdateUserPatch{PasswordHash: &hashed})
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.logger.InfoContext(ctx, "invitation accepted", "user_id", updated.ID)
+    updated.Password = ""
+    return updated, nil
+}
+
+func newInvitationToken() (string, error) {
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// temporaryPasswordAlphabet leaves out characters that are easily confused
+// when read out or copied by hand, such as O and 0.
+const temporaryPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789!@#$%*?"
+
+// temporaryPassword returns a random password of at least 16 characters
+// that satisfies policy.
+func temporaryPassword(policy PasswordPolicy, username string) (string, error) {
+    n := max(policy.MinLength, 16)
+    limit := big.NewInt(int64(len(temporaryPasswordAlphabet)))
+    // A random password misses a required kind of character only rarely,
+    // so drawing again soon finds one that passes.
+    for range 100 {
+        b := make([]byte, n)
+        for i := range b {
+            j, err := rand.Int(rand.Reader, limit)
+            if err != nil {
+                return "", err
+            }
+            b[i] = temporaryPasswordAlphabet[j.Int64()]
+        }
+        if policy.Check(string(b), username) == nil {
+            return string(b), nil
+        }
+    }
+    return "", errors.New("could not generate a password that satisfies the password policy")
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+    "context"
+    "net/url"
+    "regexp"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+    "golang.org/x/crypto/bcrypt"
+)
+
+func newTestUserImportService(t *testing.T, repos repo.Repos, mailer *fakeMailer) UserImportService {
+    t.Helper()
+    return NewUserImportService(repos.Users, repos.Invitations, repos.Outbox, DefaultPasswordPolicy(), EmailPolicy{},
+        newTestNotifier(t, mailer), "https://library.example.com/v1/auth/accept-invitation", time.Hour, repos.Tx, logger.Discard())
+}
+
+func TestUserImportService_ReportsEveryRow(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    require.NoError(t, repos.Users.Create(ctx, &model.User{Username: "grace", Email: "grace@example.com", Role: model.RoleUser}))
+    svc := newTestUserImportService(t, repos, &fakeMailer{})
+
+    report, err := svc.Import(ctx, []model.ImportUserRow{
+        {Username: " ada ", Email: "Ada@Example.com"},
+        {Username: "grace", Email: "grace@example.org"},
+        {Username: "ada", Email: "ada@example.net"},
+        {Username: "linus", Email: "not an email"},
+        {Username: "barbara", Email: "barbara@example.com", Role: "Admin"},
+        {Username: "ken", Email: "ken@example.com", Role: "librarian"},
+    }, model.UserImportOptions{})
+    require.NoError(t, err)
+    require.Equal(t, 6, report.Total)
+    require.Equal(t, 2, report.Created)
+    require.Equal(t, 4, report.Failed)
+
+    statuses := make([]string, len(report.Results))
+    for i, res := range report.Results {
+        require.Equal(t, i+1, res.Row)
+        statuses[i] = res.Status
+    }
+    require.Equal(t, []string{"created", "error", "error", "error", "created", "error"}, statuses)
+    require.Contains(t, report.Results[2].Error, "repeats row 1")
+
+    ada := report.Results[0]
+    require.NotEmpty(t, ada.UserID)
+    require.NoError(t, DefaultPasswordPolicy().Check(ada.TemporaryPassword, "ada"))
+    u, err := repos.Users.GetByUsername(ctx, "ada")
+    require.NoError(t, err)
+    require.Equal(t, "ada@example.com", u.Email)
+    require.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(ada.TemporaryPassword)))
+
+    barbara, err := repos.Users.GetByID(ctx, report.Results[4].UserID)
+    require.NoError(t, err)
+    require.Equal(t, model.RoleAdmin, barbara.Role)
+
+    _, err = svc.Import(ctx, []model.ImportUserRow{{Username: "ken", Email: "ken@example.com"}}, model.UserImportOptions{Mode: "magic"})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+var inviteTokenRE = regexp.MustCompile(`accept-invitation\?token=([\w-]+)`)
+
+func TestUserImportService_InvitedUsersChooseTheirPassword(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    mailer := &fakeMailer{}
+    svc := newTestUserImportService(t, repos, mailer)
+    users := NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, repos.Tx, logger.Discard())
+
+    report, err := svc.Import(ctx, []model.ImportUserRow{{Username: "ada", Email: "ada@example.com"}},
+        model.UserImportOptions{Mode: model.UserImportInvite, SendEmail: true})
+    require.NoError(t, err)
+    res := report.Results[0]
+    require.Equal(t, "created", res.Status)
+    require.True(t, res.Emailed)
+    require.Empty(t, res.InviteLink, "an emailed link was also returned")
+
+    require.Len(t, mailer.sent, 1)
+    require.Equal(t, "ada@example.com", mailer.sent[0].To)
+    m := inviteTokenRE.FindStringSubmatch(mailer.sent[0].HTML)
+    require.NotNil(t, m, "no invite link sent")
+    token, err := url.QueryUnescape(m[1])
+    require.NoError(t, err)
+
+    _, err = users.ValidatePassword(ctx, "ada", "")
+    require.Error(t, err, "an invited user could log in before choosing a password")
+
+    _, err = svc.AcceptInvitation(ctx, token, "password")
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    u, err := svc.AcceptInvitation(ctx, token, "Correct-Horse-42")
+    require.NoError(t, err)
+    require.Equal(t, res.UserID, u.ID)
+    _, err = users.ValidatePassword(ctx, "ada", "Correct-Horse-42")
+    require.NoError(t, err)
+
+    _, err = svc.AcceptInvitation(ctx, token, "Another-Horse-42")
+    require.ErrorIs(t, err, apperr.ErrNotFound, "an invite link worked twice")
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+)
+
+// Shape of the user stats: the chart covers statsMonths months, this one
+// included, and statsTop authors and categories are ranked.
+const (
+    statsMonths = 12
+    statsTop    = 5
+)
+
+// UserStatsService sums up users' borrowing. Results are cached per user,
+// so they may be up to the cache TTL old.
+type UserStatsService interface {
+    Get(ctx context.Context, userID string) (*model.UserStats, error)
+}
+
+type userStatsService struct {
+    repo   repo.UserStatsRepo
+    cache  *listingCache[*model.UserStats]
+    logger *slog.Logger
+}
+
+func NewUserStatsService(r repo.UserStatsRepo, cacheTTL time.Duration, logger *slog.Logger) UserStatsService {
+    return &userStatsService{repo: r, cache: newListingCache[*model.UserStats](cacheTTL), logger: logger}
+}
+
+func (s *userStatsService) Get(ctx context.Context, userID string) (*model.UserStats, error) {
+    return s.cache.get(ctx, userID, func(ctx context.Context) (*model.UserStats, error) {
+        return s.load(ctx, userID, time.Now().UTC())
+    })
+}
+
+func (s *userStatsService) load(ctx context.Context, userID string, now time.Time) (*model.UserStats, error) {
+    first := time.Date(now.Year(), now.Month()-statsMonths+1, 1, 0, 0, 0, 0, time.UTC)
+    stats, err := s.repo.UserStats(ctx, userID, now, first, statsTop)
+    if err != nil {
+        return nil, err
+    }
+    if stats.Returned > 0 {
+        rate := float64(stats.ReturnedOnTime) / float64(stats.Returned)
+        stats.OnTimeRate = &rate
+    }
+
+    counts := map[string]int{}
+    for _, m := range stats.Monthly {
+        counts[m.Month] = m.Count
+    }
+    stats.Monthly = make([]model.MonthCount, statsMonths)
+    for i := range stats.Monthly {
+        month := first.AddDate(0, i, 0).Format("2006-01")
+        stats.Monthly[i] = model.MonthCount{Month: month, Count: counts[month]}
+    }
+    stats.GeneratedAt = now
+    return stats, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
+    "github.com/stretchr/testify/require"
+)
+
+func TestUserStatsService_Get(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewUserStatsService(repos.UserStats, time.Minute, logger.Discard())
+
+    ada := &model.User{Username: "ada", Email: "ada@example.com"}
+    require.NoError(t, repos.Users.Create(ctx, ada))
+    scifi := &model.Category{Name: "Science fiction"}
+    require.NoError(t, repos.Categories.Create(ctx, scifi))
+    dune := &model.Book{Title: "Dune", Author: "Frank Herbert", TotalCopies: 3, Categories: []model.Category{*scifi}}
+    emma := &model.Book{Title: "Emma", Author: "Jane Austen", TotalCopies: 3}
+    require.NoError(t, repos.Books.Create(ctx, dune))
+    require.NoError(t, repos.Books.Create(ctx, emma))
+
+    now := time.Now().UTC()
+    loan := func(book *model.Book, borrowed time.Time, status string, returnedLate time.Duration) {
+        b := &model.Booking{UserID: ada.ID, BookID: book.ID, BorrowedAt: borrowed, DueDate: borrowed.AddDate(0, 0, 14), Status: status}
+        if status == "RETURNED" {
+            returned := b.DueDate.Add(returnedLate)
+            b.ReturnedAt = &returned
+        }
+        require.NoError(t, repos.Bookings.Create(ctx, b))
+    }
+    loan(dune, now.AddDate(0, -2, 0), "RETURNED", -time.Hour)
+    loan(dune, now.AddDate(0, -1, 0), "RETURNED", 48*time.Hour)
+    loan(emma, now.AddDate(0, -1, 0), "RETURNED", -time.Hour)
+    loan(dune, now.AddDate(0, 0, -20), "ACTIVE", 0)
+    loan(emma, now.AddDate(-2, 0, 0), "RETURNED", 0)
+    loan(emma, now, "OFFERED", 0)
+    require.NoError(t, repos.Fines.Create(ctx, &model.Fine{UserID: ada.ID, BookingID: "b1", DaysLate: 2, AmountCents: 50, Currency: "usd"}))
+    require.NoError(t, repos.Fines.Create(ctx, &model.Fine{UserID: ada.ID, BookingID: "b2", DaysLate: 1, AmountCents: 25, Currency: "usd"}))
+
+    stats, err := svc.Get(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, 5, stats.TotalBorrowed, "waitlist offers aren't loans")
+    require.Equal(t, 1, stats.CurrentlyBorrowed)
+    require.Equal(t, 1, stats.Overdue)
+    require.Equal(t, 4, stats.Returned)
+    require.Equal(t, 3, stats.ReturnedOnTime)
+    require.NotNil(t, stats.OnTimeRate)
+    require.InDelta(t, 0.75, *stats.OnTimeRate, 1e-9)
+    require.Equal(t, []model.FineTotal{{Currency: "usd", AmountCents: 75, Count: 2}}, stats.UnpaidFines)
+    require.Equal(t, []model.StatCount{{Name: "Frank Herbert", Count: 3}, {Name: "Jane Austen", Count: 2}}, stats.TopAuthors)
+    require.Equal(t, []model.StatCount{{Name: "Science fiction", Count: 3}}, stats.TopCategories)
+
+    require.Len(t, stats.Monthly, statsMonths)
+    require.Equal(t, now.Format("2006-01"), stats.Monthly[statsMonths-1].Month)
+    total := 0
+    for _, m := range stats.Monthly {
+        total += m.Count
+    }
+    require.Equal(t, 4, total, "the loan two years ago is off the chart")
+
+    loan(emma, now, "ACTIVE", 0)
+    cached, err := svc.Get(ctx, ada.ID)
+    require.NoError(t, err)
+    require.Equal(t, 5, cached.TotalBorrowed, "answers are cached")
+}
+
+func TestUserStatsService_NoLoans(t *testing.T) {
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewUserStatsService(repos.UserStats, time.Minute, logger.Discard())
+
+    stats, err := svc.Get(context.Background(), "nobody")
+    require.NoError(t, err)
+    require.Zero(t, stats.TotalBorrowed)
+    require.Nil(t, stats.OnTimeRate)
+    require.Empty(t, stats.UnpaidFines)
+    require.NotNil(t, stats.TopAuthors)
+    require.Len(t, stats.Monthly, statsMonths)
+}
@@ -3,8 +3,13 @@ package service
 import (
     "context"
     "errors"
+    "net"
+    "strings"
     "testing"
+    "time"
 
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/repo"
     "github.com/stretchr/testify/require"
@@ -17,9 +22,12 @@ type mockUserRepo struct {
     getByIDFn       func(ctx context.Context, id string) (*model.User, error)
     getByUsernameFn func(ctx context.Context, username string) (*model.User, error)
     getByEmailFn    func(ctx context.Context, email string) (*model.User, error)
-    updateFn        func(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error)
-    listFn          func(ctx context.Context, limit, offset int) ([]model.User, error)
+    updateFn        func(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error)
+    listFn          func(ctx context.Context, p model.PageRequest) (model.Page[model.User], error)
     deleteFn        func(ctx context.Context, id string) error
+    anonymizeFn     func(ctx context.Context, id string) error
+    countAdminsFn   func(ctx context.Context) (int, error)
+    savePrefsFn     func(ctx context.Context, p *model.NotificationPreferences) error
 }
 
 func (m *mockUserRepo) Create(ctx context.Context, u *model.User) error {
@@ -38,18 +46,38 @@ func (m *mockUserRepo) GetByEmail(ctx context.Context, email string) (*model.Use
     return m.getByEmailFn(ctx, email)
 }
 
-func (m *mockUserRepo) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.User, error) {
-    return m.updateFn(ctx, id, updates)
+func (m *mockUserRepo) Update(ctx context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+    return m.updateFn(ctx, id, p)
 }
 
-func (m *mockUserRepo) List(ctx context.Context, limit, offset int) ([]model.User, error) {
-    return m.listFn(ctx, limit, offset)
+func (m *mockUserRepo) List(ctx context.Context, p model.PageRequest) (model.Page[model.User], error) {
+    return m.listFn(ctx, p)
+}
+
+func (m *mockUserRepo) ReplacePasswordHash(ctx context.Context, id, old, new string) error {
+    return nil
 }
 
 func (m *mockUserRepo) Delete(ctx context.Context, id string) error {
     return m.deleteFn(ctx, id)
 }
 
+func (m *mockUserRepo) Anonymize(ctx context.Context, id string) error {
+    return m.anonymizeFn(ctx, id)
+}
+
+func (m *mockUserRepo) CountActiveAdminsForUpdate(ctx context.Context) (int, error) {
+    return m.countAdminsFn(ctx)
+}
+
+func (m *mockUserRepo) GetNotificationPreferences(ctx context.Context, userID string) (model.NotificationPreferences, error) {
+    return model.DefaultNotificationPreferences(userID), nil
+}
+
+func (m *mockUserRepo) SaveNotificationPreferences(ctx context.Context, p *model.NotificationPreferences) error {
+    return m.savePrefsFn(ctx, p)
+}
+
 var _ repo.UserRepo = (*mockUserRepo)(nil)
 
 func TestUserService_Register_Success(t *testing.T) {
@@ -60,11 +88,10 @@ func TestUserService_Register_Success(t *testing.T) {
         },
         createFn: func(_ context.Context, u *model.User) error {
             u.ID = "user-1"
-            u.Role = "USER"
             return nil
         },
     }
-    svc := NewUserService(mock)
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
 
     req := &model.RegisterRequest{
         Username: "john",
@@ -75,7 +102,81 @@ func TestUserService_Register_Success(t *testing.T) {
 
     require.NoError(t, err)
     require.Equal(t, "john", user.Username)
-    require.Equal(t, "USER", user.Role)
+    require.Equal(t, model.RoleUser, user.Role)
+}
+
+// fakeResolver answers MX lookups from mx and host lookups from hosts;
+// names in neither don't exist.
+type fakeResolver struct {
+    mx    map[string][]*net.MX
+    hosts map[string][]string
+    err   error
+}
+
+func (r fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+    if r.err != nil {
+        return nil, r.err
+    }
+    if mx, ok := r.mx[name]; ok {
+        return mx, nil
+    }
+    return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+    if addrs, ok := r.hosts[host]; ok {
+        return addrs, nil
+    }
+    return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestUserService_EmailPolicy(t *testing.T) {
+    ctx := context.Background()
+    var created *model.User
+    mock := &mockUserRepo{
+        createFn: func(_ context.Context, u *model.User) error {
+            created = u
+            return nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+            return &model.User{ID: id, Email: *p.Email}, nil
+        },
+    }
+    resolver := fakeResolver{
+        mx:    map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}, "null.example": {{Host: "."}}},
+        hosts: map[string][]string{"direct.example": {"192.0.2.1"}},
+    }
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{CheckMX: true, Resolver: resolver}, &mockTxManager{}, logger.Discard())
+    register := func(email string) error {
+        _, err := svc.Register(ctx, &model.RegisterRequest{Username: "john", Email: email, Password: "SecurePass123"})
+        return err
+    }
+
+    require.NoError(t, register("  John.Doe@Example.COM "))
+    require.Equal(t, "john.doe@example.com", created.Email)
+    require.NoError(t, register("john@direct.example"), "an address record stands in for a missing MX")
+
+    for email, msg := range map[string]string{
+        "a@b.":                 "invalid email format",
+        "John <j@example.com>": "invalid email format",
+        "j@localhost":          "invalid email format",
+        "j@missing.example":    "email domain does not accept email",
+        "j@null.example":       "email domain does not accept email",
+    } {
+        err := register(email)
+        require.ErrorIs(t, err, apperr.ErrValidation, email)
+        require.EqualError(t, err, msg, email)
+    }
+
+    u, err := svc.Update(ctx, "user-1", model.UpdateUserPatch{Email: model.Ptr("Jane@Example.com")})
+    require.NoError(t, err)
+    require.Equal(t, "jane@example.com", u.Email)
+    _, err = svc.AdminUpdate(ctx, "user-1", &model.AdminUpdateUserRequest{Email: "jane@missing.example"})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    // While DNS is failing, addresses are accepted rather than refused.
+    svc = NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{CheckMX: true, Resolver: fakeResolver{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}}, &mockTxManager{}, logger.Discard())
+    require.NoError(t, register("john@missing.example"))
 }
 
 func TestUserService_ValidatePassword_Success(t *testing.T) {
@@ -90,11 +191,11 @@ func TestUserService_ValidatePassword_Success(t *testing.T) {
                 ID:       "user-1",
                 Username: username,
                 Password: string(hashedPassword),
-                Role:     "USER",
+                Role:     model.RoleUser,
             }, nil
         },
     }
-    svc := NewUserService(mock)
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
 
     user, err := svc.ValidatePassword(ctx, "john", "SecurePass123")
     require.NoError(t, err)
@@ -112,11 +213,11 @@ func TestUserService_ValidatePassword_WrongPassword(t *testing.T) {
                 ID:       "user-1",
                 Username: username,
                 Password: string(hashedPassword),
-                Role:     "USER",
+                Role:     model.RoleUser,
             }, nil
         },
     }
-    svc := NewUserService(mock)
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
 
     user, err := svc.ValidatePassword(ctx, "john", "WrongPassword")
     require.Error(t, err)
@@ -130,7 +231,7 @@ func TestUserService_GetByID_NotFound(t *testing.T) {
             return nil, errors.New("not found")
         },
     }
-    svc := NewUserService(mock)
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
 
     user, err := svc.GetByID(ctx, "nonexistent")
     require.Error(t, err)
@@ -145,11 +246,11 @@ func TestUserService_GetByID_Success(t *testing.T) {
                 ID:       id,
                 Username: "john",
                 Email:    "john@example.com",
-                Role:     "USER",
+                Role:     model.RoleUser,
             }, nil
         },
     }
-    svc := NewUserService(mock)
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
 
     user, err := svc.GetByID(ctx, "user-1")
     require.NoError(t, err)
@@ -159,16 +260,417 @@ func TestUserService_GetByID_Success(t *testing.T) {
 func TestUserService_List_Success(t *testing.T) {
     ctx := context.Background()
     mock := &mockUserRepo{
-        listFn: func(_ context.Context, limit, offset int) ([]model.User, error) {
-            return []model.User{
-                {ID: "1", Username: "user1", Role: "USER"},
-                {ID: "2", Username: "user2", Role: "ADMIN"},
-            }, nil
+        listFn: func(_ context.Context, p model.PageRequest) (model.Page[model.User], error) {
+            return model.Page[model.User]{Items: []model.User{
+                {ID: "1", Username: "user1", Role: model.RoleUser},
+                {ID: "2", Username: "user2", Role: model.RoleAdmin},
+            }, Total: 2}, nil
+        },
+    }
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+
+    users, err := svc.List(ctx, model.PageRequest{Limit: 10})
+    require.NoError(t, err)
+    require.Len(t, users.Items, 2)
+}
+// fakeLoginAttempts is an in-memory repo.LoginAttemptRepo.
+type fakeLoginAttempts struct {
+    failures map[string]int
+    locked   map[string]time.Time
+}
+
+func newFakeLoginAttempts() *fakeLoginAttempts {
+    return &fakeLoginAttempts{failures: map[string]int{}, locked: map[string]time.Time{}}
+}
+
+func (f *fakeLoginAttempts) LockedUntil(_ context.Context, keys []string, now time.Time) (time.Time, error) {
+    var until time.Time
+    for _, k := range keys {
+        if t := f.locked[k]; t.After(now) && t.After(until) {
+            until = t
+        }
+    }
+    return until, nil
+}
+
+func (f *fakeLoginAttempts) RecordFailure(_ context.Context, key string, _, _ time.Time) (int, error) {
+    f.failures[key]++
+    return f.failures[key], nil
+}
+
+func (f *fakeLoginAttempts) Lock(_ context.Context, key string, until time.Time) error {
+    f.locked[key] = until
+    f.failures[key] = 0
+    return nil
+}
+
+func (f *fakeLoginAttempts) Reset(_ context.Context, key string) error {
+    delete(f.failures, key)
+    delete(f.locked, key)
+    return nil
+}
+
+var _ repo.LoginAttemptRepo = (*fakeLoginAttempts)(nil)
+
+func newLockoutTestService(t *testing.T, attempts *fakeLoginAttempts) UserService {
+    hashed, err := bcrypt.GenerateFromPassword([]byte("SecurePass123"), bcrypt.MinCost)
+    require.NoError(t, err)
+    mock := &mockUserRepo{
+        getByUsernameFn: func(_ context.Context, username string) (*model.User, error) {
+            return &model.User{ID: "user-1", Username: username, Password: string(hashed)}, nil
         },
     }
-    svc := NewUserService(mock)
+    policy := LockoutPolicy{MaxFailures: 3, MaxFailuresPerIP: 10, Window: time.Minute, Duration: time.Minute}
+    return NewUserService(mock, attempts, nil, nil, policy, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+}
+
+func TestUserService_Login_LocksAfterMaxFailures(t *testing.T) {
+    ctx := context.Background()
+    svc := newLockoutTestService(t, newFakeLoginAttempts())
+
+    for i := 0; i < 2; i++ {
+        _, err := svc.Login(ctx, "john", "wrong", "10.0.0.1")
+        require.Error(t, err)
+        require.NotErrorIs(t, err, apperr.ErrLocked)
+    }
+
+    _, err := svc.Login(ctx, "john", "wrong", "10.0.0.1")
+    var locked *LockedError
+    require.ErrorAs(t, err, &locked)
+    require.ErrorIs(t, err, apperr.ErrLocked)
+
+    // Locked out even with the right password, and from another address.
+    _, err = svc.Login(ctx, "John", "SecurePass123", "10.0.0.2")
+    require.ErrorIs(t, err, apperr.ErrLocked)
+}
+
+func TestUserService_Login_SuccessClearsUserFailures(t *testing.T) {
+    ctx := context.Background()
+    attempts := newFakeLoginAttempts()
+    svc := newLockoutTestService(t, attempts)
+
+    _, err := svc.Login(ctx, "john", "wrong", "10.0.0.1")
+    require.Error(t, err)
+
+    user, err := svc.Login(ctx, "john", "SecurePass123", "10.0.0.1")
+    require.NoError(t, err)
+    require.Equal(t, "user-1", user.ID)
+    require.Zero(t, attempts.failures["user:john"])
+    require.Equal(t, 1, attempts.failures["ip:10.0.0.1"])
+}
 
-    users, err := svc.List(ctx, 10, 0)
+func TestPasswordHasher(t *testing.T) {
+    argon := PasswordHasher{Scheme: HashArgon2id, Argon2: Argon2Params{Memory: 1024, Time: 1, Threads: 1}}
+    hashed, err := argon.Hash("SecurePass123")
     require.NoError(t, err)
-    require.Len(t, users, 2)
-}
\ No newline at end of file
+    require.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=1024,t=1,p=1$"))
+    require.True(t, argon.Matches(hashed, "SecurePass123"))
+    require.False(t, argon.Matches(hashed, "SecurePass124"))
+    require.False(t, argon.NeedsRehash(hashed))
+
+    stronger := argon
+    stronger.Argon2.Time = 2
+    require.True(t, stronger.Matches(hashed, "SecurePass123"), "hashes keep the parameters they were made with")
+    require.True(t, stronger.NeedsRehash(hashed))
+
+    bcryptHasher := PasswordHasher{Scheme: HashBcrypt, BcryptCost: bcrypt.MinCost}
+    require.True(t, bcryptHasher.Matches(hashed, "SecurePass123"), "either scheme verifies both")
+    require.True(t, bcryptHasher.NeedsRehash(hashed))
+    hashed, err = bcryptHasher.Hash("SecurePass123")
+    require.NoError(t, err)
+    require.True(t, argon.Matches(hashed, "SecurePass123"))
+    require.True(t, argon.NeedsRehash(hashed))
+    require.True(t, PasswordHasher{}.NeedsRehash(hashed), "the zero value hashes at bcrypt's default cost")
+
+    require.False(t, argon.Matches("$argon2id$v=19$m=1024$c2FsdA$a2V5", "SecurePass123"))
+    require.False(t, argon.Matches("", ""))
+}
+
+func TestUserService_Login_RehashesPassword(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    policy := DefaultPasswordPolicy()
+    policy.Hasher = PasswordHasher{Scheme: HashBcrypt, BcryptCost: bcrypt.MinCost}
+    before := NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, policy, EmailPolicy{}, repos.Tx, logger.Discard())
+    registered, err := before.Register(ctx, &model.RegisterRequest{Username: "ada", Email: "ada@example.com", Password: "SecurePass123"})
+    require.NoError(t, err)
+
+    policy.Hasher = PasswordHasher{Scheme: HashArgon2id, Argon2: Argon2Params{Memory: 1024, Time: 1, Threads: 1}}
+    after := NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, policy, EmailPolicy{}, repos.Tx, logger.Discard())
+    _, err = after.Login(ctx, "ada", "wrong", "10.0.0.1")
+    require.Error(t, err)
+    stored, err := repos.Users.GetByUsername(ctx, "ada")
+    require.NoError(t, err)
+    require.True(t, strings.HasPrefix(stored.Password, "$2a$"), "a failed login rehashed")
+
+    _, err = after.Login(ctx, "ada", "SecurePass123", "10.0.0.1")
+    require.NoError(t, err)
+    stored, err = repos.Users.GetByUsername(ctx, "ada")
+    require.NoError(t, err)
+    require.True(t, strings.HasPrefix(stored.Password, "$argon2id$"))
+    require.Equal(t, registered.Version, stored.Version, "a rehash isn't a profile change")
+
+    _, err = after.Login(ctx, "ada", "SecurePass123", "10.0.0.1")
+    require.NoError(t, err)
+    _, err = before.Login(ctx, "ada", "SecurePass123", "10.0.0.1")
+    require.NoError(t, err, "rolling the scheme back still lets users in")
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+    p := DefaultPasswordPolicy()
+
+    require.NoError(t, p.Check("SecurePass123", "john"))
+    require.ErrorIs(t, p.Check("Sh0rt", "john"), apperr.ErrValidation)
+    require.ErrorIs(t, p.Check("alllowercase1", "john"), apperr.ErrValidation)
+    require.ErrorIs(t, p.Check("Password123", "john"), apperr.ErrValidation)
+    require.ErrorIs(t, p.Check("John12345X", "john12345x"), apperr.ErrValidation)
+
+    err := p.Check("abc", "john")
+    require.EqualError(t, err, "password must be at least 8 characters, contain an upper-case letter, contain a digit")
+
+    p.RequireSymbol = true
+    require.Error(t, p.Check("SecurePass123", "john"))
+    require.NoError(t, p.Check("SecurePass123!", "john"))
+}
+
+func TestUserService_Register_RejectsBreachedPassword(t *testing.T) {
+    svc := NewUserService(&mockUserRepo{}, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+
+    _, err := svc.Register(context.Background(), &model.RegisterRequest{
+        Username: "john",
+        Email:    "john@example.com",
+        Password: "Password123",
+    })
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func newChangePasswordTestService(t *testing.T, updated **model.UpdateUserPatch) UserService {
+    hashed, err := bcrypt.GenerateFromPassword([]byte("SecurePass123"), bcrypt.MinCost)
+    require.NoError(t, err)
+    mock := &mockUserRepo{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Username: "john"}, nil
+        },
+        getByUsernameFn: func(_ context.Context, username string) (*model.User, error) {
+            return &model.User{ID: "user-1", Username: username, Password: string(hashed)}, nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+            *updated = &p
+            return &model.User{ID: id}, nil
+        },
+    }
+    return NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+}
+
+func TestUserService_ChangePassword_Success(t *testing.T) {
+    var updated *model.UpdateUserPatch
+    svc := newChangePasswordTestService(t, &updated)
+
+    err := svc.ChangePassword(context.Background(), "user-1", "SecurePass123", "EvenBetter456")
+    require.NoError(t, err)
+
+    require.NoError(t, bcrypt.CompareHashAndPassword([]byte(*updated.PasswordHash), []byte("EvenBetter456")))
+}
+
+func TestUserService_ChangePassword_WrongCurrent(t *testing.T) {
+    var updated *model.UpdateUserPatch
+    svc := newChangePasswordTestService(t, &updated)
+
+    err := svc.ChangePassword(context.Background(), "user-1", "NotMyPass1", "EvenBetter456")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+    require.Nil(t, updated)
+}
+
+func TestUserService_ChangePassword_PolicyViolation(t *testing.T) {
+    var updated *model.UpdateUserPatch
+    svc := newChangePasswordTestService(t, &updated)
+
+    err := svc.ChangePassword(context.Background(), "user-1", "SecurePass123", "weakpassword")
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    require.Nil(t, updated)
+}
+
+func newAdminUpdateTestService(target *model.User, admins int, updated *model.UpdateUserPatch) UserService {
+    mock := &mockUserRepo{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            u := *target
+            return &u, nil
+        },
+        countAdminsFn: func(ctx context.Context) (int, error) {
+            if !inTx(ctx) {
+                return 0, errors.New("admins counted outside the transaction")
+            }
+            return admins, nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+            *updated = p
+            u := *target
+            if p.Role != nil {
+                u.Role = *p.Role
+            }
+            return &u, nil
+        },
+    }
+    return NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+}
+
+func TestUserService_AdminUpdate(t *testing.T) {
+    admin := &model.User{ID: "admin-1", Role: "admin", Status: model.UserStatusActive}
+    ctx := context.Background()
+
+    var updated model.UpdateUserPatch
+    svc := newAdminUpdateTestService(admin, 2, &updated)
+    u, err := svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Role: "user", Email: "a@example.com"})
+    require.NoError(t, err)
+    require.Equal(t, model.RoleUser, u.Role)
+    require.Equal(t, model.UpdateUserPatch{Role: model.Ptr(model.RoleUser), Email: model.Ptr("a@example.com")}, updated)
+
+    svc = newAdminUpdateTestService(admin, 1, &updated)
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Role: "user"})
+    require.ErrorIs(t, err, apperr.ErrConflict, "the last admin can't be demoted")
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Status: model.UserStatusSuspended})
+    require.ErrorIs(t, err, apperr.ErrConflict, "the last admin can't be suspended")
+    require.ErrorIs(t, svc.Delete(ctx, "admin-1"), apperr.ErrConflict, "the last admin can't be deleted")
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Email: "a@example.com"})
+    require.NoError(t, err)
+
+    version := 4
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Email: "a@example.com", Version: &version})
+    require.NoError(t, err)
+    require.Equal(t, model.UpdateUserPatch{Email: model.Ptr("a@example.com"), Version: &version}, updated, "the version is passed on for the repo to check")
+
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{Role: "owner"})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+    _, err = svc.AdminUpdate(ctx, "admin-1", &model.AdminUpdateUserRequest{})
+    require.ErrorIs(t, err, apperr.ErrValidation)
+}
+
+func TestUserService_AdminUpdate_RoleChangeRevokesTokens(t *testing.T) {
+    ctx := context.Background()
+    admin := &model.User{ID: "admin-1", Username: "root", Role: model.RoleAdmin, Status: model.UserStatusActive}
+    mock := &mockUserRepo{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            u := *admin
+            return &u, nil
+        },
+        countAdminsFn: func(context.Context) (int, error) { return 2, nil },
+        updateFn: func(_ context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+            u := *admin
+            u.Role = *p.Role
+            return &u, nil
+        },
+    }
+    revocations := fakeRevocations{}
+    auth := NewAuthService([]SigningKey{newKey}, time.Hour, TokenPolicy{}, revocations, nil, 0)
+    svc := NewUserService(mock, nil, revocations, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+
+    token, _, err := auth.GenerateToken(admin.ID, admin.Username, admin.Role, "")
+    require.NoError(t, err)
+    _, err = svc.AdminUpdate(ctx, admin.ID, &model.AdminUpdateUserRequest{Role: model.RoleAdmin})
+    require.NoError(t, err)
+    _, err = auth.ValidateToken(ctx, token)
+    require.NoError(t, err, "keeping the same role leaves tokens alone")
+
+    _, err = svc.AdminUpdate(ctx, admin.ID, &model.AdminUpdateUserRequest{Role: model.RoleUser})
+    require.NoError(t, err)
+    _, err = auth.ValidateToken(ctx, token)
+    require.ErrorIs(t, err, ErrTokenRevoked, "a demoted admin's old token is refused")
+}
+
+func TestUserService_Login_RefusesSuspendedUser(t *testing.T) {
+    hashed, err := bcrypt.GenerateFromPassword([]byte("SecurePass123"), bcrypt.MinCost)
+    require.NoError(t, err)
+    mock := &mockUserRepo{
+        getByUsernameFn: func(_ context.Context, username string) (*model.User, error) {
+            return &model.User{ID: "user-1", Username: username, Password: string(hashed), Status: model.UserStatusSuspended}, nil
+        },
+    }
+    svc := NewUserService(mock, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+
+    _, err = svc.Login(context.Background(), "john", "SecurePass123", "10.0.0.1")
+    require.ErrorIs(t, err, apperr.ErrForbidden)
+}
+
+func TestUserService_Suspend(t *testing.T) {
+    ctx := context.Background()
+    var updated model.UpdateUserPatch
+    mock := &mockUserRepo{
+        getByIDFn: func(_ context.Context, id string) (*model.User, error) {
+            return &model.User{ID: id, Role: "user", Status: model.UserStatusActive}, nil
+        },
+        updateFn: func(_ context.Context, id string, p model.UpdateUserPatch) (*model.User, error) {
+            updated = p
+            return &model.User{ID: id}, nil
+        },
+    }
+    revocations := fakeRevocations{}
+    svc := NewUserService(mock, nil, revocations, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, &mockTxManager{}, logger.Discard())
+
+    until := time.Now().Add(24 * time.Hour)
+    _, err := svc.Suspend(ctx, "user-1", &until)
+    require.NoError(t, err)
+    require.Equal(t, model.UserStatusSuspended, *updated.Status)
+    require.Equal(t, &until, updated.SuspendedUntil)
+    require.False(t, revocations["user-1"].IsZero(), "the user's tokens are revoked")
+
+    past := time.Now().Add(-time.Minute)
+    _, err = svc.Suspend(ctx, "user-1", &past)
+    require.ErrorIs(t, err, apperr.ErrValidation)
+
+    _, err = svc.Unsuspend(ctx, "user-1")
+    require.NoError(t, err)
+    require.Equal(t, model.UserStatusActive, *updated.Status)
+    require.Nil(t, updated.SuspendedUntil)
+}
+
+func TestUser_IsSuspended(t *testing.T) {
+    now := time.Now()
+    later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+    require.False(t, (&model.User{Status: model.UserStatusActive}).IsSuspended(now))
+    require.True(t, (&model.User{Status: model.UserStatusSuspended}).IsSuspended(now))
+    require.True(t, (&model.User{Status: model.UserStatusSuspended, SuspendedUntil: &later}).IsSuspended(now))
+    require.False(t, (&model.User{Status: model.UserStatusSuspended, SuspendedUntil: &earlier}).IsSuspended(now), "a suspension lapses")
+}
+
+func TestUserService_UpdatePreferences(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewUserService(repos.Users, nil, nil, nil, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, repos.Tx, logger.Discard())
+    user := &model.User{Username: "ada", Email: "ada@example.com", Role: model.RoleUser}
+    require.NoError(t, repos.Users.Create(ctx, user))
+
+    prefs, err := svc.GetPreferences(ctx, user.ID)
+    require.NoError(t, err)
+    require.Equal(t, model.NotifyEmail, prefs.Channel, "reminders are emailed by default")
+
+    for _, hook := range []string{"", "http://hooks.example.com/x", "https:///x", "hooks.example.com"} {
+        _, err := svc.UpdatePreferences(ctx, user.ID, &model.NotificationPreferencesRequest{Channel: model.NotifyWebhook, WebhookURL: hook})
+        require.ErrorIs(t, err, apperr.ErrValidation, hook)
+    }
+
+    _, err = svc.UpdatePreferences(ctx, user.ID, &model.NotificationPreferencesRequest{Channel: model.NotifyWebhook, WebhookURL: "https://hooks.example.com/x", ReminderLeadHours: 48})
+    require.NoError(t, err)
+    prefs, err = svc.UpdatePreferences(ctx, user.ID, &model.NotificationPreferencesRequest{Channel: model.NotifyEmail, WebhookURL: "https://hooks.example.com/x"})
+    require.NoError(t, err)
+    require.Empty(t, prefs.WebhookURL, "a webhook is only kept for the webhook channel")
+    got, err := svc.GetPreferences(ctx, user.ID)
+    require.NoError(t, err)
+    require.Equal(t, prefs, got)
+}
+
+func TestUserService_Register_RecordsEvent(t *testing.T) {
+    ctx := context.Background()
+    repos := repo.NewMemoryRepos(repo.NewMemoryStore())
+    svc := NewUserService(repos.Users, nil, nil, repos.Outbox, LockoutPolicy{}, DefaultPasswordPolicy(), EmailPolicy{}, repos.Tx, logger.Discard())
+
+    user, err := svc.Register(ctx, &model.RegisterRequest{Username: "ada", Email: "ada@example.com", Password: "SecurePass123"})
+    require.NoError(t, err)
+
+    events, err := repos.Outbox.Pending(ctx, 10)
+    require.NoError(t, err)
+    require.Len(t, events, 1)
+    require.Equal(t, model.EventUserRegistered, events[0].Type)
+    require.Equal(t, user.ID, events[0].Subject)
+    require.NotContains(t, string(events[0].Data), "SecurePass123")
+}
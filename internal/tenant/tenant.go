@@ -0,0 +1,21 @@
+// Package tenant carries the library branch a request is scoped to. The
+// HTTP and gRPC layers resolve it and the repositories filter by it, so a
+// request made for one branch never sees another branch's books or
+// bookings.
+package tenant
+
+import "context"
+
+type contextKey struct{}
+
+// WithBranch returns a copy of ctx scoped to the branch with the given ID.
+func WithBranch(ctx context.Context, branchID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, branchID)
+}
+
+// BranchID returns the branch ctx is scoped to, or "" when it isn't scoped
+// and spans every branch.
+func BranchID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
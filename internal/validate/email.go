@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+)
+
+// Limits from RFC 5321 on the path a mail server accepts.
+const (
+	maxEmailLength = 254
+	maxLocalLength = 64
+	maxLabelLength = 63
+)
+
+// ErrNoMailServer means an address's domain publishes no server that
+// accepts mail for it.
+var ErrNoMailServer = errors.New("domain does not accept email")
+
+// NormalizeEmail trims surrounding whitespace and lower-cases s, so the
+// same mailbox is always stored and looked up the same way.
+func NormalizeEmail(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// IsEmail reports whether s is a bare RFC 5322 address such as
+// ada@example.com: no display name or angle brackets, and a domain of at
+// least two DNS labels. Internationalized domains must be in their ASCII
+// (punycode) form.
+func IsEmail(s string) bool {
+	if len(s) > maxEmailLength {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return false
+	}
+	at := strings.LastIndexByte(s, '@')
+	return at <= maxLocalLength && isDomain(s[at+1:])
+}
+
+func isDomain(s string) bool {
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if !isLabel(l) {
+			return false
+		}
+	}
+	// Top-level domains are never numeric; this also rejects bare IPs.
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+func isLabel(l string) bool {
+	if l == "" || len(l) > maxLabelLength || l[0] == '-' || l[len(l)-1] == '-' {
+		return false
+	}
+	for _, r := range l {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolver looks up the DNS records CheckMX needs; *net.Resolver is one.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// CheckMX returns ErrNoMailServer unless the domain of email, a valid
+// address, has an MX record or, failing that, an address record mail can
+// be delivered to (RFC 5321 section 5.1). A domain declaring a null MX
+// (RFC 7505) accepts no mail. Other lookup failures are returned as they
+// are, since they say nothing about the address.
+func CheckMX(ctx context.Context, r Resolver, email string) error {
+	domain := email[strings.LastIndexByte(email, '@')+1:]
+	mxs, err := r.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		if len(mxs) == 1 && mxs[0].Host == "." {
+			return ErrNoMailServer
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("look up MX for %s: %w", domain, err)
+	}
+
+	_, err = r.LookupHost(ctx, domain)
+	switch {
+	case err == nil:
+		return nil
+	case isNotFound(err):
+		return ErrNoMailServer
+	}
+	return fmt.Errorf("look up host %s: %w", domain, err)
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
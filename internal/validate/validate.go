@@ -0,0 +1,110 @@
+// Package validate evaluates the `validate` struct tags of request types
+// with go-playground/validator, reporting failures per field in plain
+// English.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Errors maps a field's JSON name to what is wrong with it.
+type Errors map[string]string
+
+var tags = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(jsonName)
+	// The built-in email rule accepts addresses such as "a@b." that no
+	// mail server would.
+	_ = v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		return IsEmail(fl.Field().String())
+	})
+	return v
+}
+
+// Struct evaluates the validate tags of v, a struct or a pointer to one,
+// including those of nested structs. It returns no errors for other values.
+func Struct(v any) Errors {
+	errs := Errors{}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return errs
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if err := tags.Struct(v); !errors.As(err, &fieldErrs) {
+		return errs
+	}
+	for _, fe := range fieldErrs {
+		// The namespace starts with the Go type name; nested fields keep
+		// their path, e.g. "items[0].name".
+		_, key, _ := strings.Cut(fe.Namespace(), ".")
+		if _, seen := errs[key]; !seen {
+			errs[key] = message(rv.Type(), fe)
+		}
+	}
+	return errs
+}
+
+// String joins the messages in field order, for callers that report a
+// single string.
+func (errs Errors) String() string {
+	msgs := make([]string, 0, len(errs))
+	for _, field := range slices.Sorted(maps.Keys(errs)) {
+		msgs = append(msgs, errs[field])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func message(root reflect.Type, fe validator.FieldError) string {
+	name := fe.Field()
+	switch fe.Tag() {
+	case "required":
+		return name + " is required"
+	case "required_without":
+		other := strings.ToLower(fe.Param())
+		if f, ok := root.FieldByName(fe.Param()); ok {
+			other = jsonName(f)
+		}
+		return fmt.Sprintf("%s is required when %s is not given", name, other)
+	case "email":
+		return "invalid email format"
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s%s", name, fe.Param(), unit(fe.Kind()))
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s%s", name, fe.Param(), unit(fe.Kind()))
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s%s", name, fe.Param(), unit(fe.Kind()))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", name, strings.ReplaceAll(fe.Param(), " ", ", "))
+	}
+	return fmt.Sprintf("%s is invalid (%s)", name, fe.Tag())
+}
+
+// unit names what min and max count for a kind of field; numbers are
+// compared by value.
+func unit(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " items"
+	}
+	return ""
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
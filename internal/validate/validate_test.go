@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type request struct {
+	Name     string    `json:"name" validate:"required_without=Nickname,max=5"`
+	Nickname string    `json:"nickname"`
+	Email    string    `json:"email" validate:"omitempty,email"`
+	Age      *int      `json:"age,omitempty" validate:"omitempty,min=18"`
+	Tags     []string  `json:"tags" validate:"max=2"`
+	Role     string    `json:"role" validate:"omitempty,oneof=admin user"`
+	Home     address   `json:"home"`
+	Others   []address `json:"others" validate:"dive"`
+	internal string
+}
+
+func TestStruct_MessagesByJSONField(t *testing.T) {
+	age := 12
+	errs := Struct(&request{
+		Email:  "nope",
+		Age:    &age,
+		Tags:   []string{"a", "b", "c"},
+		Role:   "owner",
+		Others: []address{{City: "Oslo"}, {}},
+	})
+	require.Equal(t, Errors{
+		"name":           "name is required when nickname is not given",
+		"email":          "invalid email format",
+		"age":            "age must be at least 18",
+		"tags":           "tags must be at most 2 items",
+		"role":           "role must be one of: admin, user",
+		"home.city":      "city is required",
+		"others[1].city": "city is required",
+	}, errs)
+}
+
+func TestStruct_Valid(t *testing.T) {
+	require.Empty(t, Struct(request{Nickname: "al", Home: address{City: "Oslo"}}))
+	require.Empty(t, Struct("not a struct"))
+	require.Equal(t, Errors{"name": "name must be at most 5 characters"}, Struct(request{Name: "Alexander", Home: address{City: "Oslo"}}))
+}
+
+func TestErrors_String(t *testing.T) {
+	require.Equal(t, "b is bad; c is bad", Errors{"c": "c is bad", "b": "b is bad"}.String())
+	require.Empty(t, Errors{}.String())
+}
+
+func TestIsEmail(t *testing.T) {
+	for _, s := range []string{"ada@example.com", "a.b+tag@mail.example.co.uk", "x@xn--bcher-kva.example", "o'neil@example.org"} {
+		require.True(t, IsEmail(s), s)
+	}
+	for _, s := range []string{
+		"", "ada", "a@b.", "a@b", "a@.com", "a@-b.com", "a@b-.com", "a@b..com", "a@1.2.3.4",
+		"Ada <ada@example.com>", "<ada@example.com>", "ada@example.com (home)", "a b@example.com",
+		"a@bücher.example", strings.Repeat("a", 65) + "@example.com",
+	} {
+		require.False(t, IsEmail(s), s)
+	}
+}
+
+func TestStruct_EmailTag(t *testing.T) {
+	type req struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	require.Equal(t, Errors{"email": "invalid email format"}, Struct(req{Email: "a@b."}))
+	require.Empty(t, Struct(req{Email: "ada@example.com"}))
+}
@@ -10,12 +10,14 @@ import (
     "testing"
 
     "github.com/go-chi/chi/v5"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/apperr"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/handler"
+    "github.com/praveen-anandh-jeyaraman/digicert/internal/logger"
     "github.com/praveen-anandh-jeyaraman/digicert/internal/model"
     "github.com/stretchr/testify/require"
 )
 
-// Helper to add request ID to context - FIXED: Use RequestIDKey from handler package
+// Helper to add request ID to context
 func createRequestWithID(method, path string, body *bytes.Buffer, requestID string) *http.Request {
     var req *http.Request
     if body != nil {
@@ -24,8 +26,7 @@ func createRequestWithID(method, path string, body *bytes.Buffer, requestID stri
         req = httptest.NewRequest(method, path, nil)
     }
 
-    // Use the typed RequestIDKey from handler package
-    ctx := context.WithValue(req.Context(), handler.RequestIDKey, requestID)
+    ctx := handler.WithRequestID(req.Context(), requestID)
     return req.WithContext(ctx)
 }
 
@@ -35,49 +36,103 @@ type mockBookService struct {
     idCount int
 }
 
-func (m *mockBookService) List(ctx context.Context, limit, offset int) ([]model.Book, error) {
+func (m *mockBookService) List(ctx context.Context, p model.PageRequest, f model.BookFilter) (model.Page[model.Book], error) {
     books := make([]model.Book, 0)
     for _, b := range m.books {
         books = append(books, *b)
     }
-    return books, nil
+    return model.Page[model.Book]{Items: books, Total: len(books)}, nil
 }
 
 func (m *mockBookService) GetByID(ctx context.Context, id string) (model.Book, error) {
     if b, ok := m.books[id]; ok {
         return *b, nil
     }
-    return model.Book{}, fmt.Errorf("book not found")
+    return model.Book{}, apperr.NotFound("book not found")
 }
 
-func (m *mockBookService) Create(ctx context.Context, b *model.Book) error {
+func (m *mockBookService) Create(ctx context.Context, _ string, b *model.Book) error {
     m.idCount++
     b.ID = fmt.Sprintf("book-%d", m.idCount)
     m.books[b.ID] = b
     return nil
 }
 
-func (m *mockBookService) Update(ctx context.Context, id string, updates map[string]interface{}) (*model.Book, error) {
+func (m *mockBookService) Update(ctx context.Context, _, id string, p model.UpdateBookPatch) (*model.Book, error) {
     if _, ok := m.books[id]; !ok {
-        return nil, fmt.Errorf("book not found")
+        return nil, apperr.NotFound("book not found")
     }
-    if title, ok := updates["title"].(string); ok {
-        m.books[id].Title = title
+    if p.Title != nil {
+        m.books[id].Title = *p.Title
     }
-    if author, ok := updates["author"].(string); ok {
-        m.books[id].Author = author
+    if p.Author != nil {
+        m.books[id].Author = *p.Author
     }
     return m.books[id], nil
 }
 
-func (m *mockBookService) Delete(ctx context.Context, id string) error {
+func (m *mockBookService) Delete(ctx context.Context, _, id string) error {
     if _, ok := m.books[id]; !ok {
-        return fmt.Errorf("book not found")
+        return apperr.NotFound("book not found")
     }
     delete(m.books, id)
     return nil
 }
 
+func (m *mockBookService) Import(ctx context.Context, actorID string, rows []model.CreateBookRequest) (*model.ImportReport, error) {
+    report := &model.ImportReport{Total: len(rows)}
+    for i, row := range rows {
+        b := &model.Book{Title: row.Title, Author: row.Author, PublishedYear: row.PublishedYear, ISBN: row.ISBN}
+        _ = m.Create(ctx, actorID, b)
+        report.Created++
+        report.Results = append(report.Results, model.ImportRowResult{Row: i + 1, Status: "created", BookID: b.ID})
+    }
+    return report, nil
+}
+
+func (m *mockBookService) Merge(ctx context.Context, _, id, targetID string) (*model.Book, error) {
+    if _, ok := m.books[id]; !ok {
+        return nil, apperr.NotFound("book not found")
+    }
+    target, ok := m.books[targetID]
+    if !ok {
+        return nil, apperr.NotFound("book not found")
+    }
+    delete(m.books, id)
+    return target, nil
+}
+
+func (m *mockBookService) Restore(ctx context.Context, _, id string) (*model.Book, error) {
+    return nil, apperr.NotFound("book not found")
+}
+
+func (m *mockBookService) History(ctx context.Context, id string) ([]model.BookChange, error) {
+    if _, ok := m.books[id]; !ok {
+        return nil, apperr.NotFound("book not found")
+    }
+    return []model.BookChange{}, nil
+}
+
+func (m *mockBookService) Enrich(ctx context.Context, _, id string) (*model.Book, error) {
+    if _, ok := m.books[id]; !ok {
+        return nil, apperr.NotFound("book not found")
+    }
+    return m.books[id], nil
+}
+
+func (m *mockBookService) Stream(ctx context.Context, afterID string, fn func(*model.Book) error) error {
+    return m.Export(ctx, fn)
+}
+
+func (m *mockBookService) Export(ctx context.Context, fn func(*model.Book) error) error {
+    for _, b := range m.books {
+        if err := fn(b); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
 func newMockBookService() *mockBookService {
     return &mockBookService{books: make(map[string]*model.Book), idCount: 0}
 }
@@ -86,7 +141,7 @@ func newMockBookService() *mockBookService {
 
 func TestIntegration_CreateAndRetrieveBook(t *testing.T) {
     svc := newMockBookService()
-    h := handler.NewBookHandler(svc)
+    h := handler.NewBookHandler(svc, logger.Discard())
 
     // Create a book
     createBody := `{"title":"Go Programming","author":"John Doe","published_year":2020}`
@@ -120,7 +175,7 @@ func TestIntegration_CreateAndRetrieveBook(t *testing.T) {
 
 func TestIntegration_CreateUpdateDelete(t *testing.T) {
     svc := newMockBookService()
-    h := handler.NewBookHandler(svc)
+    h := handler.NewBookHandler(svc, logger.Discard())
 
     // Create
     createBody := `{"title":"Rust Book","author":"Jane Smith"}`
@@ -137,7 +192,7 @@ func TestIntegration_CreateUpdateDelete(t *testing.T) {
     // Update
     chiCtx := chi.NewRouteContext()
     chiCtx.URLParams.Add("id", created.ID)
-    updateBody := `{"title":"Rust Book 2nd Edition","author":"Jane Smith","published_year":2023}`
+    updateBody := `{"title":"Rust Book 2nd Edition","author":"Jane Smith","published_year":2023,"version":1}`
     updateReq := createRequestWithID("PUT", "/books/"+created.ID, bytes.NewBufferString(updateBody), "integration-cud-002")
     updateReq = updateReq.WithContext(context.WithValue(updateReq.Context(), chi.RouteCtxKey, chiCtx))
     updateRec := httptest.NewRecorder()
@@ -156,7 +211,7 @@ func TestIntegration_CreateUpdateDelete(t *testing.T) {
 
 func TestIntegration_ListBooks(t *testing.T) {
     svc := newMockBookService()
-    h := handler.NewBookHandler(svc)
+    h := handler.NewBookHandler(svc, logger.Discard())
 
     // Create multiple books
     for i := 1; i <= 3; i++ {
@@ -174,7 +229,7 @@ func TestIntegration_ListBooks(t *testing.T) {
     h.List(listRec, listReq)
     require.Equal(t, http.StatusOK, listRec.Code)
 
-    var books []model.Book
+    var books model.Page[model.Book]
     require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &books))
-    require.Len(t, books, 3)
+    require.Len(t, books.Items, 3)
 }
\ No newline at end of file